@@ -0,0 +1,317 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+
+	"iu-chaincode/events"
+)
+
+// auditDocType marks audit entries so rich queries can select them
+const auditDocType = "auditRecord"
+
+const maxAuditPageSize = 200
+
+// auditTimeLayout writes UTC times at a fixed width, so that they compare
+// correctly as strings; RFC3339Nano drops trailing zeros of the fraction
+const auditTimeLayout = "2006-01-02T15:04:05.000000000Z"
+
+// maxAuditBackfill bounds the records one BackfillAuditRecords call rewrites
+const maxAuditBackfill = 500
+
+// AuditBackfillResult reports one batch of BackfillAuditRecords
+type AuditBackfillResult struct {
+	Scanned int    `json:"scanned"`
+	Updated int    `json:"updated"`
+	NextKey string `json:"nextKey"` // empty once every audit record has been scanned
+}
+
+// AuditQueryResult is one page of audit records and the bookmark for the next
+type AuditQueryResult struct {
+	Records      []AuditRecord `json:"records"`
+	FetchedCount int32         `json:"fetchedCount"`
+	Bookmark     string        `json:"bookmark"`
+}
+
+// writeAuditRecord stores an AuditRecord for refID keyed by action and TxID,
+// so that transactions committed in the same second keep their own records
+func writeAuditRecord(ctx contractapi.TransactionContextInterface, refID, action, actor, details, complianceStatus string, ts time.Time) error {
+	return putAuditRecord(ctx, &AuditRecord{
+		ID:               fmt.Sprintf("AUDIT_%s_%s_%s", refID, action, ctx.GetStub().GetTxID()),
+		TransactionID:    refID,
+		Action:           action,
+		Actor:            actor,
+		Timestamp:        ts,
+		Details:          details,
+		ComplianceStatus: complianceStatus,
+	})
+}
+
+func putAuditRecord(ctx contractapi.TransactionContextInterface, audit *AuditRecord) error {
+	audit.DocType = auditDocType
+	audit.TimestampKey = audit.Timestamp.UTC().Format(auditTimeLayout)
+	audit.TxID = ctx.GetStub().GetTxID()
+	auditJSON, err := json.Marshal(audit)
+	if err != nil {
+		return err
+	}
+	return ctx.GetStub().PutState(audit.ID, auditJSON)
+}
+
+// anchorWriters are the orgs that may anchor the hash of an off-chain file
+// of each type with RecordAuditEvent
+var anchorWriters = map[string][]string{
+	"REGULATORY_RETURN": {"AdminMSP"},                // returns signed by the IU
+	"CIC_EXPORT":        {"CreditorMSP", "AdminMSP"}, // files a lender sends to a CIC
+}
+
+// RecordAuditEvent writes an audit event on the channel. Events mirrored from
+// another channel carry the source TxID and block number; each source
+// transaction can be recorded only once, which makes relaying idempotent.
+// Only AdminMSP, which runs the relay, may record events, except that the
+// orgs in anchorWriters may anchor files of their type.
+func (s *IUContract) RecordAuditEvent(ctx contractapi.TransactionContextInterface, eventType, refId, hash, details, sourceTxId string, sourceBlock uint64) error {
+	if eventType == "" || refId == "" {
+		return fmt.Errorf("eventType and refId are required")
+	}
+	mspid, err := getMSPID(ctx)
+	if err != nil {
+		return err
+	}
+	writers := []string{"AdminMSP"}
+	if anchor, ok := anchorWriters[eventType]; ok && sourceTxId == "" {
+		writers = anchor
+	}
+	allowed := false
+	for _, w := range writers {
+		allowed = allowed || w == mspid
+	}
+	if !allowed {
+		return fmt.Errorf("only %s can record %s audit events", strings.Join(writers, " or "), eventType)
+	}
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	audit := &AuditRecord{
+		ID:               fmt.Sprintf("AUDIT_%s_EVT_%s_%d", refId, eventType, now.UnixNano()),
+		TransactionID:    refId,
+		Action:           eventType,
+		Actor:            mspid,
+		Timestamp:        now,
+		Details:          details,
+		ComplianceStatus: "RECORDED",
+		Hash:             hash,
+		SourceTxID:       sourceTxId,
+		SourceBlock:      sourceBlock,
+	}
+	if sourceTxId != "" {
+		existing, err := ctx.GetStub().GetState(mirrorKey(sourceTxId))
+		if err != nil {
+			return fmt.Errorf("failed to read from world state: %v", err)
+		}
+		if existing != nil {
+			return fmt.Errorf("source transaction %s already mirrored as %s", sourceTxId, existing)
+		}
+		audit.ID = fmt.Sprintf("AUDIT_%s_EVT_%s_%s", refId, eventType, sourceTxId)
+		if err := ctx.GetStub().PutState(mirrorKey(sourceTxId), []byte(audit.ID)); err != nil {
+			return err
+		}
+	}
+	if err := putAuditRecord(ctx, audit); err != nil {
+		return err
+	}
+	return emitEvent(ctx, events.AuditEventRecorded, audit.ID, audit)
+}
+
+func mirrorKey(sourceTxID string) string {
+	return fmt.Sprintf("MIRROR_%s", sourceTxID)
+}
+
+// MirroredEventExists reports whether a source transaction has been mirrored
+func (s *IUContract) MirroredEventExists(ctx contractapi.TransactionContextInterface, sourceTxId string) (bool, error) {
+	auditID, err := ctx.GetStub().GetState(mirrorKey(sourceTxId))
+	if err != nil {
+		return false, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	return auditID != nil, nil
+}
+
+// GetMirroredEvent returns the audit record mirrored from a source transaction
+func (s *IUContract) GetMirroredEvent(ctx contractapi.TransactionContextInterface, sourceTxId string) (*AuditRecord, error) {
+	auditID, err := ctx.GetStub().GetState(mirrorKey(sourceTxId))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if auditID == nil {
+		return nil, fmt.Errorf("source transaction %s has not been mirrored", sourceTxId)
+	}
+	return s.GetAuditRecord(ctx, string(auditID))
+}
+
+// GetAuditRecord returns the audit record with given id
+func (s *IUContract) GetAuditRecord(ctx contractapi.TransactionContextInterface, id string) (*AuditRecord, error) {
+	if !strings.HasPrefix(id, "AUDIT_") {
+		return nil, fmt.Errorf("%s is not an audit record id", id)
+	}
+	val, err := ctx.GetStub().GetState(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if val == nil {
+		return nil, fmt.Errorf("audit record %s does not exist", id)
+	}
+	var audit AuditRecord
+	if err := json.Unmarshal(val, &audit); err != nil {
+		return nil, err
+	}
+	return &audit, nil
+}
+
+// GetAuditTrail returns every audit record written about refId, oldest first
+func (s *IUContract) GetAuditTrail(ctx contractapi.TransactionContextInterface, refId string) ([]AuditRecord, error) {
+	if refId == "" {
+		return nil, fmt.Errorf("refId is required")
+	}
+	records, err := auditRecordsFor(ctx, refId)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Timestamp.Before(records[j].Timestamp)
+	})
+	return records, nil
+}
+
+// QueryAuditRecords pages through audit records by actor, action and time range.
+// Empty filters match everything; from and to are RFC3339 and inclusive.
+// Records written before timestampKey existed are found only once
+// BackfillAuditRecords has run over them.
+func (s *IUContract) QueryAuditRecords(ctx contractapi.TransactionContextInterface, actor, action, from, to string, pageSize int32, bookmark string) (*AuditQueryResult, error) {
+	if pageSize <= 0 || pageSize > maxAuditPageSize {
+		return nil, fmt.Errorf("pageSize must be between 1 and %d", maxAuditPageSize)
+	}
+	selector := map[string]interface{}{"docType": auditDocType}
+	if actor != "" {
+		selector["actor"] = actor
+	}
+	if action != "" {
+		selector["action"] = action
+	}
+	timestamp := map[string]string{}
+	for op, v := range map[string]string{"$gte": from, "$lte": to} {
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid time %q: %v", v, err)
+		}
+		timestamp[op] = t.UTC().Format(auditTimeLayout)
+	}
+	if len(timestamp) > 0 {
+		selector["timestampKey"] = timestamp
+	}
+	query, err := json.Marshal(map[string]interface{}{
+		"selector":  selector,
+		"sort":      []map[string]string{{"docType": "asc"}, {"timestampKey": "asc"}},
+		"use_index": []string{"_design/indexAuditTimeDoc", "indexAuditTime"},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resultsIterator, metadata, err := ctx.GetStub().GetQueryResultWithPagination(string(query), pageSize, bookmark)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	result := &AuditQueryResult{Records: []AuditRecord{}}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		var audit AuditRecord
+		if err := json.Unmarshal(queryResponse.Value, &audit); err != nil {
+			return nil, err
+		}
+		result.Records = append(result.Records, audit)
+	}
+	result.FetchedCount = metadata.GetFetchedRecordsCount()
+	result.Bookmark = metadata.GetBookmark()
+	return result, nil
+}
+
+// BackfillAuditRecords adds docType and timestampKey to audit records
+// written before they existed, so that QueryAuditRecords finds them. It
+// scans at most limit records from startKey, empty for the first; call it
+// again with NextKey until NextKey is empty.
+func (s *IUContract) BackfillAuditRecords(ctx contractapi.TransactionContextInterface, startKey string, limit int) (*AuditBackfillResult, error) {
+	mspid, err := getMSPID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if mspid != "AdminMSP" {
+		return nil, fmt.Errorf("only AdminMSP can backfill audit records")
+	}
+	if limit <= 0 || limit > maxAuditBackfill {
+		return nil, fmt.Errorf("limit must be between 1 and %d", maxAuditBackfill)
+	}
+	if startKey == "" {
+		startKey = "AUDIT_"
+	} else if !strings.HasPrefix(startKey, "AUDIT_") {
+		return nil, fmt.Errorf("%s is not an audit record id", startKey)
+	}
+	resultsIterator, err := ctx.GetStub().GetStateByRange(startKey, "AUDIT_~")
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	result := &AuditBackfillResult{}
+	var keys []string
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		if result.Scanned == limit {
+			result.NextKey = queryResponse.Key
+			break
+		}
+		result.Scanned++
+		var audit AuditRecord
+		if err := json.Unmarshal(queryResponse.Value, &audit); err != nil {
+			continue
+		}
+		if audit.DocType == auditDocType && audit.TimestampKey != "" {
+			continue
+		}
+		// TxID is left empty: the writing transaction of a legacy record is
+		// in the key's history, not in the record
+		audit.DocType = auditDocType
+		audit.TimestampKey = audit.Timestamp.UTC().Format(auditTimeLayout)
+		b, err := json.Marshal(audit)
+		if err != nil {
+			return nil, err
+		}
+		if err := ctx.GetStub().PutState(queryResponse.Key, b); err != nil {
+			return nil, err
+		}
+		result.Updated++
+		keys = append(keys, queryResponse.Key)
+	}
+	if len(keys) > 0 {
+		if err := emitEvent(ctx, events.AuditRecordsBackfilled, "", keys); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+
+	"iu-chaincode/chaincodetest"
+	"iu-chaincode/events"
+)
+
+func recordAuditEvent(refID, hash, sourceTxID string) ctxFunc {
+	return func(ctx contractapi.TransactionContextInterface) error {
+		return contract.RecordAuditEvent(ctx, events.TransactionCreated, refID, hash, "mirrored", sourceTxID, 7)
+	}
+}
+
+func anchor(eventType, sourceTxID string) ctxFunc {
+	return func(ctx contractapi.TransactionContextInterface) error {
+		return contract.RecordAuditEvent(ctx, eventType, "FILE1", "ab12", "anchored", sourceTxID, 0)
+	}
+}
+
+func TestRecordAuditEvent(t *testing.T) {
+	setup := func(t *testing.T, stub *chaincodetest.Stub) {
+		mustSubmit(t, stub, chaincodetest.Admin, recordAuditEvent("TX1", "h1", "src1"))
+	}
+	runCases(t, setup, []txCase{
+		{name: "mirrored event", id: chaincodetest.Admin, call: recordAuditEvent("TX2", "h2", "src2"), event: events.AuditEventRecorded,
+			check: func(t *testing.T, stub *chaincodetest.Stub) {
+				key := "AUDIT_TX2_EVT_TRANSACTION_CREATED_src2"
+				if string(stub.State(mirrorKey("src2"))) != key {
+					t.Fatalf("mirror index = %q, want %q", stub.State(mirrorKey("src2")), key)
+				}
+				var audit AuditRecord
+				readState(t, stub, key, &audit)
+				if audit.Hash != "h2" || audit.SourceBlock != 7 || audit.DocType != auditDocType {
+					t.Fatalf("unexpected record %+v", audit)
+				}
+			}},
+		{name: "local event", id: chaincodetest.Admin, call: recordAuditEvent("TX2", "h2", ""),
+			check: func(t *testing.T, stub *chaincodetest.Stub) {
+				if keys := stub.Keys("AUDIT_TX2_EVT_"); len(keys) != 1 {
+					t.Fatalf("audit keys = %v", keys)
+				}
+				// A local event omits the source fields and must still match
+				// the schema when returned
+				cc, err := contractapi.NewChaincode(&IUContract{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if resp := stub.Invoke(cc, chaincodetest.Debtor, []string{"GetAuditRecord", stub.Keys("AUDIT_TX2_EVT_")[0]}); resp.Status != 200 {
+					t.Fatalf("GetAuditRecord: %s", resp.Message)
+				}
+			}},
+		{name: "creditor cannot mirror", id: chaincodetest.Creditor, call: recordAuditEvent("TX2", "h2", "src2"), wantErr: "only AdminMSP can record TRANSACTION_CREATED audit events",
+			check: func(t *testing.T, stub *chaincodetest.Stub) {
+				if stub.State(mirrorKey("src2")) != nil {
+					t.Fatal("mirror index written")
+				}
+			}},
+		{name: "creditor cannot record local events", id: chaincodetest.Creditor, call: recordAuditEvent("TX2", "h2", ""), wantErr: "only AdminMSP can record"},
+		{name: "creditor anchors a CIC file", id: chaincodetest.Creditor, call: anchor("CIC_EXPORT", "")},
+		{name: "anchors are not mirrors", id: chaincodetest.Creditor, call: anchor("CIC_EXPORT", "src2"), wantErr: "only AdminMSP can record CIC_EXPORT"},
+		{name: "creditor cannot anchor a return", id: chaincodetest.Creditor, call: anchor("REGULATORY_RETURN", ""), wantErr: "only AdminMSP can record REGULATORY_RETURN"},
+		{name: "debtor cannot anchor a CIC file", id: chaincodetest.Debtor, call: anchor("CIC_EXPORT", ""), wantErr: "only CreditorMSP or AdminMSP can record CIC_EXPORT"},
+		{name: "MirroredEventExists", id: chaincodetest.Debtor, call: func(ctx contractapi.TransactionContextInterface) error {
+			mirrored, err := contract.MirroredEventExists(ctx, "src1")
+			other, _ := contract.MirroredEventExists(ctx, "src9")
+			if !mirrored || other {
+				t.Errorf("mirrored = %v and %v, want true and false", mirrored, other)
+			}
+			return err
+		}},
+		{name: "source mirrored twice", id: chaincodetest.Admin, call: recordAuditEvent("TX1", "h1", "src1"), wantErr: "already mirrored"},
+		{name: "refId required", id: chaincodetest.Admin, call: recordAuditEvent("", "h", ""), wantErr: "are required"},
+		{name: "GetMirroredEvent", id: chaincodetest.Debtor, call: func(ctx contractapi.TransactionContextInterface) error {
+			audit, err := contract.GetMirroredEvent(ctx, "src1")
+			if err == nil && audit.TransactionID != "TX1" {
+				t.Errorf("refId = %s", audit.TransactionID)
+			}
+			return err
+		}},
+		{name: "GetMirroredEvent missing", id: chaincodetest.Debtor, wantErr: "has not been mirrored", call: func(ctx contractapi.TransactionContextInterface) error {
+			_, err := contract.GetMirroredEvent(ctx, "src9")
+			return err
+		}},
+		{name: "GetAuditRecord not an audit key", id: chaincodetest.Debtor, wantErr: "is not an audit record id", call: func(ctx contractapi.TransactionContextInterface) error {
+			_, err := contract.GetAuditRecord(ctx, "TX1")
+			return err
+		}},
+		{name: "GetAuditRecord missing", id: chaincodetest.Debtor, wantErr: "does not exist", call: func(ctx contractapi.TransactionContextInterface) error {
+			_, err := contract.GetAuditRecord(ctx, "AUDIT_TX9")
+			return err
+		}},
+	})
+}
+
+func TestGetAuditTrail(t *testing.T) {
+	setup := func(t *testing.T, stub *chaincodetest.Stub) {
+		mustSubmit(t, stub, chaincodetest.Creditor, createTx("TX1", "C1", "D1", 1000, "DEBIT"))
+		mustSubmit(t, stub, chaincodetest.Admin, complianceCheck("TX1", true))
+		mustSubmit(t, stub, chaincodetest.Admin, func(ctx contractapi.TransactionContextInterface) error {
+			return contract.ProcessTransaction(ctx, "TX1")
+		})
+	}
+	runCases(t, setup, []txCase{
+		{name: "oldest first", id: chaincodetest.Debtor, call: func(ctx contractapi.TransactionContextInterface) error {
+			trail, err := contract.GetAuditTrail(ctx, "TX1")
+			if err != nil {
+				return err
+			}
+			want := []string{"CREATE_TRANSACTION", "COMPLIANCE_CHECK", "PROCESS_TRANSACTION"}
+			if len(trail) != len(want) {
+				t.Fatalf("trail = %+v", trail)
+			}
+			for i, a := range trail {
+				if a.Action != want[i] {
+					t.Errorf("action %d = %s, want %s", i, a.Action, want[i])
+				}
+			}
+			return nil
+		}},
+		{name: "same second", id: chaincodetest.Debtor, call: func(ctx contractapi.TransactionContextInterface) error {
+			trail, err := contract.GetAuditTrail(ctx, "TX2")
+			if err == nil && len(trail) != 3 {
+				t.Errorf("trail = %+v", trail)
+			}
+			return err
+		}, setup: func(t *testing.T, stub *chaincodetest.Stub) {
+			// Compliance checks of the same transaction within one second
+			second := stub.Now().Truncate(time.Second)
+			mustSubmit(t, stub, chaincodetest.Creditor, createTx("TX2", "C1", "D1", 1000, "DEBIT"))
+			for _, approved := range []bool{true, false} {
+				stub.SetTime(second.Add(100 * time.Millisecond))
+				mustSubmit(t, stub, chaincodetest.Admin, complianceCheck("TX2", approved))
+			}
+		}},
+		{name: "refId required", id: chaincodetest.Debtor, wantErr: "refId is required", call: func(ctx contractapi.TransactionContextInterface) error {
+			_, err := contract.GetAuditTrail(ctx, "")
+			return err
+		}},
+	})
+}
+
+func TestQueryAuditRecords(t *testing.T) {
+	setup := func(t *testing.T, stub *chaincodetest.Stub) {
+		for _, id := range []string{"TX1", "TX2", "TX3"} {
+			mustSubmit(t, stub, chaincodetest.Creditor, createTx(id, "C1", "D1", 1000, "CREDIT"))
+			mustSubmit(t, stub, chaincodetest.Admin, complianceCheck(id, true))
+		}
+	}
+	query := func(actor, action, from, to string, pageSize int32, bookmark string, want ...string) ctxFunc {
+		return func(ctx contractapi.TransactionContextInterface) error {
+			result, err := contract.QueryAuditRecords(ctx, actor, action, from, to, pageSize, bookmark)
+			if err != nil {
+				return err
+			}
+			if int(result.FetchedCount) != len(want) || len(result.Records) != len(want) {
+				t.Fatalf("fetched %d records, want %d", result.FetchedCount, len(want))
+			}
+			for i, a := range result.Records {
+				if a.TransactionID != want[i] {
+					t.Errorf("record %d is about %s, want %s", i, a.TransactionID, want[i])
+				}
+			}
+			return nil
+		}
+	}
+	at := func(minutes int) string {
+		return chaincodetest.Start.Add(time.Duration(minutes) * time.Minute).Format(time.RFC3339)
+	}
+	runCases(t, setup, []txCase{
+		{name: "by actor", id: chaincodetest.Admin, call: query("AdminMSP", "", "", "", 10, "", "TX1", "TX2", "TX3")},
+		{name: "by action", id: chaincodetest.Admin, call: query("", "CREATE_TRANSACTION", "", "", 10, "", "TX1", "TX2", "TX3")},
+		{name: "time range", id: chaincodetest.Admin, call: query("", "", at(1), at(3), 10, "", "TX1", "TX2", "TX2")},
+		{name: "first page", id: chaincodetest.Admin, call: query("", "", "", "", 4, "", "TX1", "TX1", "TX2", "TX2")},
+		{name: "next page", id: chaincodetest.Admin, call: func(ctx contractapi.TransactionContextInterface) error {
+			first, err := contract.QueryAuditRecords(ctx, "", "", "", "", 4, "")
+			if err != nil {
+				return err
+			}
+			return query("", "", "", "", 4, first.Bookmark, "TX3", "TX3")(ctx)
+		}},
+		{name: "sub-second timestamps", id: chaincodetest.Admin, call: query("", "CREATE_TRANSACTION", at(6), "", 10, "", "TX4"),
+			setup: func(t *testing.T, stub *chaincodetest.Stub) {
+				stub.SetTime(chaincodetest.Start.Add(6*time.Minute + 500*time.Millisecond))
+				mustSubmit(t, stub, chaincodetest.Creditor, createTx("TX4", "C1", "D1", 1000, "CREDIT"))
+			}},
+		{name: "inclusive upper bound", id: chaincodetest.Admin, call: query("", "CREATE_TRANSACTION", at(5), at(6), 10, ""),
+			setup: func(t *testing.T, stub *chaincodetest.Stub) {
+				stub.SetTime(chaincodetest.Start.Add(6*time.Minute + 500*time.Millisecond))
+				mustSubmit(t, stub, chaincodetest.Creditor, createTx("TX4", "C1", "D1", 1000, "CREDIT"))
+			}},
+		{name: "page size", id: chaincodetest.Admin, call: query("", "", "", "", 0, ""), wantErr: "pageSize must be between 1 and 200"},
+		{name: "invalid time", id: chaincodetest.Admin, call: query("", "", "yesterday", "", 10, ""), wantErr: "invalid time"},
+	})
+}
+
+func TestBackfillAuditRecords(t *testing.T) {
+	// Records written before docType and timestampKey were added
+	legacy := func(t *testing.T, stub *chaincodetest.Stub) {
+		for i, id := range []string{"OLD1", "OLD2", "OLD3"} {
+			record := fmt.Sprintf(`{"id":"AUDIT_%s_CREATE_TRANSACTION_1","transactionId":%q,"action":"CREATE_TRANSACTION","actor":"CreditorMSP","timestamp":"2023-06-0%dT10:00:00.5Z","details":"","complianceStatus":"PENDING"}`, id, id, i+1)
+			if err := stub.Seed(chaincodetest.Admin, func(ctx contractapi.TransactionContextInterface) error {
+				return ctx.GetStub().PutState("AUDIT_"+id+"_CREATE_TRANSACTION_1", []byte(record))
+			}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	backfill := func(startKey string, limit int, want AuditBackfillResult) ctxFunc {
+		return func(ctx contractapi.TransactionContextInterface) error {
+			result, err := contract.BackfillAuditRecords(ctx, startKey, limit)
+			if err == nil && *result != want {
+				t.Errorf("result = %+v, want %+v", result, want)
+			}
+			return err
+		}
+	}
+	runCases(t, legacy, []txCase{
+		{name: "first batch", id: chaincodetest.Admin, call: backfill("", 2, AuditBackfillResult{Scanned: 2, Updated: 2, NextKey: "AUDIT_OLD3_CREATE_TRANSACTION_1"}),
+			event: events.AuditRecordsBackfilled,
+			check: func(t *testing.T, stub *chaincodetest.Stub) {
+				if lastEvent(t, stub).PayloadHash != sha256Hex([]byte(`["AUDIT_OLD1_CREATE_TRANSACTION_1","AUDIT_OLD2_CREATE_TRANSACTION_1"]`)) {
+					t.Fatal("event does not list the updated keys")
+				}
+				var audit AuditRecord
+				readState(t, stub, "AUDIT_OLD1_CREATE_TRANSACTION_1", &audit)
+				if audit.DocType != auditDocType || audit.TimestampKey != "2023-06-01T10:00:00.500000000Z" || audit.TxID != "" {
+					t.Fatalf("unexpected record %+v", audit)
+				}
+			}},
+		{name: "last batch", id: chaincodetest.Admin, call: backfill("AUDIT_OLD3_CREATE_TRANSACTION_1", 2, AuditBackfillResult{Scanned: 1, Updated: 1})},
+		{name: "found by queries once backfilled", id: chaincodetest.Admin,
+			setup: func(t *testing.T, stub *chaincodetest.Stub) {
+				mustSubmit(t, stub, chaincodetest.Admin, backfill("", 10, AuditBackfillResult{Scanned: 3, Updated: 3}))
+			},
+			call: func(ctx contractapi.TransactionContextInterface) error {
+				result, err := contract.QueryAuditRecords(ctx, "", "", "2023-06-02T10:00:00Z", "", 10, "")
+				if err == nil && (len(result.Records) != 2 || result.Records[0].TransactionID != "OLD2") {
+					t.Errorf("records = %+v", result.Records)
+				}
+				return err
+			}},
+		{name: "current records are left alone", id: chaincodetest.Admin, call: backfill("", 10, AuditBackfillResult{Scanned: 3}),
+			setup: func(t *testing.T, stub *chaincodetest.Stub) {
+				mustSubmit(t, stub, chaincodetest.Admin, backfill("", 10, AuditBackfillResult{Scanned: 3, Updated: 3}))
+			}},
+		{name: "creditor cannot backfill", id: chaincodetest.Creditor, call: backfill("", 10, AuditBackfillResult{}), wantErr: "only AdminMSP"},
+		{name: "limit", id: chaincodetest.Admin, call: backfill("", 0, AuditBackfillResult{}), wantErr: "limit must be between 1 and 500"},
+		{name: "start key outside audit records", id: chaincodetest.Admin, call: backfill("TX1", 10, AuditBackfillResult{}), wantErr: "is not an audit record id"},
+	})
+}
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+
+	"iu-chaincode/events"
+)
+
+// importBatchTransientKey carries the JSON array of ImportRow in a bulk import
+const importBatchTransientKey = "batch"
+
+// maxImportRows bounds a batch so that its write set stays within block limits
+const maxImportRows = 1000
+
+// maxImportErrorsReported bounds the row errors listed in a rejected atomic batch
+const maxImportErrorsReported = 20
+
+// ImportRow is one historical transaction of a loan book being brought onto the IU
+type ImportRow struct {
+	ID              string  `json:"id"`
+	CreditorID      string  `json:"creditorId"`
+	DebtorID        string  `json:"debtorId"`
+	Amount          float64 `json:"amount"`
+	Currency        string  `json:"currency"`
+	TransactionType string  `json:"transactionType"` // DEBIT, CREDIT, TRANSFER
+	Status          string  `json:"status"`          // PENDING, COMPLETED, FAILED; empty means COMPLETED
+	Timestamp       string  `json:"timestamp"`       // booking time, RFC3339 or YYYY-MM-DD; empty means the tx time
+	Description     string  `json:"description"`
+}
+
+// ImportRowError is why a row of a batch was not imported. Row is 1-based.
+type ImportRowError struct {
+	Row   int    `json:"row"`
+	ID    string `json:"id"`
+	Error string `json:"error"`
+}
+
+// ImportBatch records a committed bulk import, so a batch is applied once
+// and an importer can tell after a failure whether it went through
+type ImportBatch struct {
+	BatchID    string           `json:"batchId"`
+	BatchHash  string           `json:"batchHash"` // hex SHA-256 of the transient batch
+	Atomic     bool             `json:"atomic"`
+	Rows       int              `json:"rows"`
+	Imported   int              `json:"imported"`
+	Errors     []ImportRowError `json:"errors"`
+	ImportedBy string           `json:"importedBy"`
+	ImportedAt time.Time        `json:"importedAt"`
+	TxID       string           `json:"txId"`
+}
+
+func importBatchKey(batchID string) string {
+	return fmt.Sprintf("IMPORT_BATCH_%s", batchID)
+}
+
+// BulkImportTransactions imports a batch of historical transactions passed
+// in the transient field 'batch' as a JSON array of ImportRow. batchHash is
+// the hex SHA-256 of those bytes. Every row is validated; an atomic batch is
+// rejected as a whole if any row is invalid, otherwise the valid rows are
+// written and the invalid ones reported.
+func (s *IUContract) BulkImportTransactions(ctx contractapi.TransactionContextInterface, batchID, batchHash string, atomic bool) (*ImportBatch, error) {
+	mspid, err := getMSPID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if mspid != "CreditorMSP" && mspid != "AdminMSP" {
+		return nil, fmt.Errorf("only CreditorMSP or AdminMSP can import transactions")
+	}
+	if batchID == "" || batchHash == "" {
+		return nil, fmt.Errorf("batchID and batchHash are required")
+	}
+	key := importBatchKey(batchID)
+	exists, err := s.TransactionExists(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, fmt.Errorf("batch %s already imported", batchID)
+	}
+
+	transient, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transient: %v", err)
+	}
+	batchBytes, ok := transient[importBatchTransientKey]
+	if !ok || len(batchBytes) == 0 {
+		return nil, fmt.Errorf("transient field '%s' is required", importBatchTransientKey)
+	}
+	if got := sha256Hex(batchBytes); !strings.EqualFold(got, batchHash) {
+		return nil, fmt.Errorf("batch hash mismatch: transient batch hashes to %s, not %s", got, batchHash)
+	}
+	var rows []ImportRow
+	if err := json.Unmarshal(batchBytes, &rows); err != nil {
+		return nil, fmt.Errorf("batch must be a JSON array of rows: %v", err)
+	}
+	if len(rows) == 0 || len(rows) > maxImportRows {
+		return nil, fmt.Errorf("batch must have between 1 and %d rows, has %d", maxImportRows, len(rows))
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	batch := &ImportBatch{
+		BatchID:    batchID,
+		BatchHash:  strings.ToLower(batchHash),
+		Atomic:     atomic,
+		Rows:       len(rows),
+		Errors:     []ImportRowError{},
+		ImportedBy: mspid,
+		ImportedAt: now,
+		TxID:       ctx.GetStub().GetTxID(),
+	}
+
+	type importedRow struct {
+		row int
+		tx  *Transaction
+	}
+	var valid []importedRow
+	seen := map[string]bool{}
+	for i := range rows {
+		tx, err := s.importRow(ctx, &rows[i], seen, mspid, now)
+		if err != nil {
+			batch.Errors = append(batch.Errors, ImportRowError{Row: i + 1, ID: rows[i].ID, Error: err.Error()})
+			continue
+		}
+		valid = append(valid, importedRow{row: i + 1, tx: tx})
+	}
+
+	// Rows are chained in booking order, after the latest transaction of
+	// their creditor and debtor on the ledger, so a row booked before that
+	// transaction cannot be imported
+	sort.SliceStable(valid, func(i, j int) bool { return valid[i].tx.Timestamp.Before(valid[j].tx.Timestamp) })
+	latest := map[string]*Transaction{}
+	chained := valid[:0]
+	for _, v := range valid {
+		pair := v.tx.CreditorID + "\x00" + v.tx.DebtorID
+		last, ok := latest[pair]
+		if !ok {
+			last = latestTransaction(ctx, v.tx.CreditorID, v.tx.DebtorID)
+			latest[pair] = last
+		}
+		if last != nil && v.tx.Timestamp.Before(last.Timestamp) {
+			batch.Errors = append(batch.Errors, ImportRowError{Row: v.row, ID: v.tx.ID,
+				Error: fmt.Sprintf("booked before %s, the latest transaction of %s and %s on the ledger", last.ID, last.CreditorID, last.DebtorID)})
+			continue
+		}
+		chained = append(chained, v)
+	}
+	sort.Slice(batch.Errors, func(i, j int) bool { return batch.Errors[i].Row < batch.Errors[j].Row })
+	if atomic && len(batch.Errors) > 0 {
+		return nil, fmt.Errorf("batch %s rejected: %s", batchID, describeImportErrors(batch.Errors, len(rows)))
+	}
+
+	// Chain rows of a creditor and debtor to each other, since writes in
+	// this transaction are not visible to the ledger query
+	lastHash := map[string]string{}
+	for pair, tx := range latest {
+		if tx != nil {
+			lastHash[pair] = tx.Hash
+		}
+	}
+	keys := []string{}
+	for _, v := range chained {
+		tx := v.tx
+		pair := tx.CreditorID + "\x00" + tx.DebtorID
+		tx.PreviousHash = lastHash[pair]
+		tx.Hash = transactionHash(tx.ID, tx.CreditorID, tx.DebtorID, tx.Amount, tx.Currency, tx.Timestamp)
+		lastHash[pair] = tx.Hash
+
+		b, err := json.Marshal(tx)
+		if err != nil {
+			return nil, err
+		}
+		if err := ctx.GetStub().PutState(tx.ID, b); err != nil {
+			return nil, err
+		}
+		owner, err := s.creditorOrg(ctx, tx.CreditorID)
+		if err != nil {
+			return nil, err
+		}
+		if err := setKeyEndorsers(ctx, tx.ID, owner, "AdminMSP"); err != nil {
+			return nil, err
+		}
+		if err := writeAuditRecord(ctx, tx.ID, "IMPORT_TRANSACTION", mspid,
+			fmt.Sprintf("Transaction imported in batch %s: %s to %s, Amount: %f %s", batchID, tx.CreditorID, tx.DebtorID, tx.Amount, tx.Currency),
+			tx.Status, now); err != nil {
+			return nil, err
+		}
+		keys = append(keys, tx.ID)
+	}
+	batch.Imported = len(chained)
+
+	if err := putJSON(ctx, key, batch); err != nil {
+		return nil, err
+	}
+	status := "IMPORTED"
+	if len(batch.Errors) > 0 {
+		status = "PARTIALLY_IMPORTED"
+	}
+	if err := writeAuditRecord(ctx, batchID, "BULK_IMPORT", mspid,
+		fmt.Sprintf("Batch %s: %d of %d rows imported", batch.BatchHash, batch.Imported, batch.Rows), status, now); err != nil {
+		return nil, err
+	}
+	if err := emitEvent(ctx, events.TransactionsImported, "", append(keys, key)); err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("✅ Batch %s imported: %d of %d rows\n", batchID, batch.Imported, batch.Rows)
+	return batch, nil
+}
+
+// importRow validates a row and returns the transaction it imports as.
+// seen holds the IDs of earlier rows in the batch.
+func (s *IUContract) importRow(ctx contractapi.TransactionContextInterface, row *ImportRow, seen map[string]bool, mspid string, now time.Time) (*Transaction, error) {
+	if row.ID == "" || row.CreditorID == "" || row.DebtorID == "" {
+		return nil, fmt.Errorf("id, creditorId and debtorId are required")
+	}
+	if seen[row.ID] {
+		return nil, fmt.Errorf("transaction %s appears more than once in the batch", row.ID)
+	}
+	seen[row.ID] = true
+	if row.Amount <= 0 {
+		return nil, fmt.Errorf("amount must be positive")
+	}
+	if row.Currency == "" {
+		return nil, fmt.Errorf("currency is required")
+	}
+	switch row.TransactionType {
+	case "DEBIT", "CREDIT", "TRANSFER":
+	default:
+		return nil, fmt.Errorf("invalid transactionType %q (want DEBIT, CREDIT or TRANSFER)", row.TransactionType)
+	}
+	status := row.Status
+	if status == "" {
+		status = "COMPLETED"
+	}
+	switch status {
+	case "PENDING", "COMPLETED", "FAILED":
+	default:
+		return nil, fmt.Errorf("invalid status %q (want PENDING, COMPLETED or FAILED)", row.Status)
+	}
+	ts := now
+	if row.Timestamp != "" {
+		var err error
+		if ts, err = time.Parse(time.RFC3339, row.Timestamp); err != nil {
+			if ts, err = time.Parse(dateLayout, row.Timestamp); err != nil {
+				return nil, fmt.Errorf("invalid timestamp %q (want RFC3339 or YYYY-MM-DD)", row.Timestamp)
+			}
+		}
+		if ts.After(now) {
+			return nil, fmt.Errorf("timestamp %s is later than the transaction timestamp", row.Timestamp)
+		}
+	}
+
+	exists, err := s.TransactionExists(ctx, row.ID)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, fmt.Errorf("transaction %s already exists", row.ID)
+	}
+	if err := s.requireCreditor(ctx, row.CreditorID, mspid); err != nil {
+		return nil, err
+	}
+	// Historical rows may name borrowers suspended since
+	if err := s.requireDebtor(ctx, row.DebtorID, false); err != nil {
+		return nil, err
+	}
+	// The moratorium applies on the date the row was booked
+	if row.TransactionType == "DEBIT" && status != "FAILED" {
+		if err := s.enforceMoratoriumAt(ctx, row.DebtorID, "DEBIT_TRANSACTION", row.ID, ts); err != nil {
+			return nil, err
+		}
+	}
+
+	tx := &Transaction{
+		ID:              row.ID,
+		CreditorID:      row.CreditorID,
+		DebtorID:        row.DebtorID,
+		Amount:          row.Amount,
+		Currency:        row.Currency,
+		TransactionType: row.TransactionType,
+		Status:          status,
+		Timestamp:       ts.UTC(),
+		Description:     row.Description,
+	}
+	// Settled history was checked by the bank it comes from
+	if status != "PENDING" {
+		tx.ComplianceChecked = true
+		tx.ValidatedBy = mspid
+	}
+	return tx, nil
+}
+
+func describeImportErrors(errs []ImportRowError, rows int) string {
+	parts := []string{}
+	for i, e := range errs {
+		if i == maxImportErrorsReported {
+			parts = append(parts, fmt.Sprintf("and %d more", len(errs)-i))
+			break
+		}
+		parts = append(parts, fmt.Sprintf("row %d (%s): %s", e.Row, e.ID, e.Error))
+	}
+	return fmt.Sprintf("%d of %d rows invalid: %s", len(errs), rows, strings.Join(parts, "; "))
+}
+
+// GetImportBatch returns the record of a committed bulk import
+func (s *IUContract) GetImportBatch(ctx contractapi.TransactionContextInterface, batchID string) (*ImportBatch, error) {
+	val, err := ctx.GetStub().GetState(importBatchKey(batchID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if val == nil {
+		return nil, fmt.Errorf("batch %s does not exist", batchID)
+	}
+	var batch ImportBatch
+	if err := json.Unmarshal(val, &batch); err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+
+	"iu-chaincode/chaincodetest"
+	"iu-chaincode/events"
+)
+
+func importRows(rows ...ImportRow) []byte {
+	b, err := json.Marshal(rows)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+var (
+	bookRow1 = ImportRow{ID: "OLD1", CreditorID: "C1", DebtorID: "D1", Amount: 500000, Currency: "INR", TransactionType: "DEBIT", Timestamp: "2019-06-30"}
+	bookRow2 = ImportRow{ID: "OLD2", CreditorID: "C1", DebtorID: "D1", Amount: 100000, Currency: "INR", TransactionType: "CREDIT", Timestamp: "2020-01-15T10:00:00Z"}
+	badRow   = ImportRow{ID: "OLD3", CreditorID: "C1", DebtorID: "D2", Amount: -5, Currency: "INR", TransactionType: "DEBIT"}
+)
+
+// bulkImport imports batch as batchID with the batch's own hash
+func bulkImport(batchID string, atomic bool, batch []byte) (ctxFunc, chaincodetest.TxOption) {
+	call := func(ctx contractapi.TransactionContextInterface) error {
+		_, err := contract.BulkImportTransactions(ctx, batchID, sha256Hex(batch), atomic)
+		return err
+	}
+	return call, chaincodetest.WithTransient(map[string][]byte{importBatchTransientKey: batch})
+}
+
+func importCase(name string, id *chaincodetest.Identity, batchID string, atomic bool, batch []byte) txCase {
+	call, opt := bulkImport(batchID, atomic, batch)
+	return txCase{name: name, id: id, call: call, opts: []chaincodetest.TxOption{opt}}
+}
+
+func TestBulkImportTransactions(t *testing.T) {
+	good := importRows(bookRow1, bookRow2)
+	mixed := importRows(bookRow1, badRow, bookRow1)
+
+	imported := importCase("imports rows", chaincodetest.Creditor, "B1", true, good)
+	imported.event = events.TransactionsImported
+	imported.check = func(t *testing.T, stub *chaincodetest.Stub) {
+		var first, second Transaction
+		readState(t, stub, "OLD1", &first)
+		readState(t, stub, "OLD2", &second)
+		if first.Status != "COMPLETED" || !first.ComplianceChecked || first.Timestamp.Format(dateLayout) != "2019-06-30" {
+			t.Fatalf("unexpected transaction %+v", first)
+		}
+		if first.PreviousHash != "" || second.PreviousHash != first.Hash {
+			t.Fatalf("rows are not chained: %q -> %q, %q", first.Hash, second.PreviousHash, second.Hash)
+		}
+		var batch ImportBatch
+		readState(t, stub, importBatchKey("B1"), &batch)
+		if batch.Rows != 2 || batch.Imported != 2 || batch.BatchHash != sha256Hex(good) {
+			t.Fatalf("unexpected batch %+v", batch)
+		}
+		if len(stub.Keys("AUDIT_OLD1_IMPORT_TRANSACTION_")) != 1 {
+			t.Fatal("no audit record for imported row")
+		}
+	}
+
+	partial := importCase("non-atomic reports row errors", chaincodetest.Admin, "B1", false, mixed)
+	partial.check = func(t *testing.T, stub *chaincodetest.Stub) {
+		var batch ImportBatch
+		readState(t, stub, importBatchKey("B1"), &batch)
+		if batch.Imported != 1 || len(batch.Errors) != 2 || batch.Errors[0].Row != 2 || batch.Errors[1].Row != 3 {
+			t.Fatalf("unexpected batch %+v", batch)
+		}
+		if stub.State("OLD1") == nil || stub.State("OLD3") != nil {
+			t.Fatal("valid row not imported or invalid row imported")
+		}
+	}
+
+	atomicBad := importCase("atomic batch with an invalid row", chaincodetest.Creditor, "B1", true, mixed)
+	atomicBad.wantErr = "2 of 3 rows invalid: row 2 (OLD3): amount must be positive; row 3 (OLD1): transaction OLD1 appears more than once"
+	atomicBad.check = func(t *testing.T, stub *chaincodetest.Stub) {
+		if stub.State("OLD1") != nil || stub.State(importBatchKey("B1")) != nil {
+			t.Fatal("rejected batch left writes")
+		}
+	}
+
+	existing := importCase("row already on the ledger", chaincodetest.Creditor, "B1", true, importRows(ImportRow{ID: "TX1", CreditorID: "C1", DebtorID: "D1", Amount: 1, Currency: "INR", TransactionType: "DEBIT"}))
+	existing.setup = func(t *testing.T, stub *chaincodetest.Stub) {
+		mustSubmit(t, stub, chaincodetest.Creditor, createTx("TX1", "C1", "D1", 1000, "DEBIT"))
+	}
+	existing.wantErr = "transaction TX1 already exists"
+
+	moratorium := importCase("debit booked under moratorium", chaincodetest.Creditor, "B1", true, good)
+	moratorium.setup = func(t *testing.T, stub *chaincodetest.Stub) {
+		mustSubmit(t, stub, chaincodetest.Admin, registerCase("CASE1", "D1", "2019-01-01", "2019-12-31"))
+	}
+	moratorium.wantErr = "row 1 (OLD1): moratorium in force"
+
+	beforeMoratorium := importCase("debit booked before the moratorium", chaincodetest.Creditor, "B1", true, good)
+	beforeMoratorium.setup = func(t *testing.T, stub *chaincodetest.Stub) {
+		mustSubmit(t, stub, chaincodetest.Admin, registerCase("CASE1", "D1", "2024-03-01", ""))
+	}
+
+	reordered := importCase("rows chained in booking order", chaincodetest.Creditor, "B1", true, importRows(bookRow2, bookRow1))
+	reordered.check = func(t *testing.T, stub *chaincodetest.Stub) {
+		var first, second Transaction
+		readState(t, stub, "OLD1", &first)
+		readState(t, stub, "OLD2", &second)
+		if first.PreviousHash != "" || second.PreviousHash != first.Hash {
+			t.Fatalf("rows are not chained in booking order: %q -> %q, %q", first.Hash, second.PreviousHash, second.Hash)
+		}
+	}
+
+	backdated := importCase("booked before the ledger's latest transaction", chaincodetest.Creditor, "B1", false, importRows(bookRow1, bookRow2, ImportRow{ID: "OLD4", CreditorID: "C1", DebtorID: "D2", Amount: 1, Currency: "INR", TransactionType: "CREDIT", Timestamp: "2019-06-30"}))
+	backdated.setup = func(t *testing.T, stub *chaincodetest.Stub) {
+		mustSubmit(t, stub, chaincodetest.Creditor, createTx("TX1", "C1", "D1", 1000, "CREDIT"))
+	}
+	backdated.check = func(t *testing.T, stub *chaincodetest.Stub) {
+		var batch ImportBatch
+		readState(t, stub, importBatchKey("B1"), &batch)
+		if batch.Imported != 1 || len(batch.Errors) != 2 || batch.Errors[0].Row != 1 || batch.Errors[1].Row != 2 {
+			t.Fatalf("unexpected batch %+v", batch)
+		}
+		if batch.Errors[0].Error != "booked before TX1, the latest transaction of C1 and D1 on the ledger" {
+			t.Fatalf("error = %q", batch.Errors[0].Error)
+		}
+		if stub.State("OLD1") != nil || stub.State("OLD4") == nil {
+			t.Fatal("backdated row imported or other pair's row not imported")
+		}
+	}
+
+	replayed := importCase("batch ID reused", chaincodetest.Creditor, "B1", true, good)
+	replayed.setup = func(t *testing.T, stub *chaincodetest.Stub) {
+		call, opt := bulkImport("B1", true, good)
+		mustSubmit(t, stub, chaincodetest.Creditor, call, opt)
+	}
+	replayed.wantErr = "batch B1 already imported"
+
+	hashMismatch := importCase("hash mismatch", chaincodetest.Creditor, "B1", true, good)
+	hashMismatch.call = func(ctx contractapi.TransactionContextInterface) error {
+		_, err := contract.BulkImportTransactions(ctx, "B1", sha256Hex([]byte("other")), true)
+		return err
+	}
+	hashMismatch.wantErr = "batch hash mismatch"
+
+	rejected := func(name string, id *chaincodetest.Identity, batch []byte, wantErr string) txCase {
+		tc := importCase(name, id, "B1", true, batch)
+		tc.wantErr = wantErr
+		return tc
+	}
+	row := ImportRow{ID: "OLD1", CreditorID: "C1", DebtorID: "D1", Amount: 1, Currency: "INR", TransactionType: "CREDIT"}
+	future, settled := row, row
+	future.Timestamp = "2030-01-01"
+	settled.Status = "SETTLED"
+
+	runCases(t, nil, []txCase{
+		imported,
+		partial,
+		atomicBad,
+		existing,
+		moratorium,
+		beforeMoratorium,
+		reordered,
+		backdated,
+		replayed,
+		hashMismatch,
+		rejected("debtor cannot import", chaincodetest.Debtor, good, "only CreditorMSP or AdminMSP"),
+		rejected("not an array", chaincodetest.Creditor, []byte(`{"id":"OLD1"}`), "must be a JSON array"),
+		rejected("empty batch", chaincodetest.Creditor, []byte(`[]`), "between 1 and 1000 rows"),
+		rejected("future timestamp", chaincodetest.Creditor, importRows(future), "later than the transaction timestamp"),
+		rejected("invalid status", chaincodetest.Creditor, importRows(settled), "invalid status"),
+		{name: "no transient batch", id: chaincodetest.Creditor, wantErr: "transient field 'batch' is required", call: func(ctx contractapi.TransactionContextInterface) error {
+			_, err := contract.BulkImportTransactions(ctx, "B1", "abc", true)
+			return err
+		}},
+	})
+}
+
+func TestGetImportBatch(t *testing.T) {
+	batch := importRows(bookRow1)
+	call, opt := bulkImport("B1", false, batch)
+	runCases(t, func(t *testing.T, stub *chaincodetest.Stub) {
+		mustSubmit(t, stub, chaincodetest.Creditor, call, opt)
+	}, []txCase{
+		{name: "committed batch", id: chaincodetest.Debtor, call: func(ctx contractapi.TransactionContextInterface) error {
+			got, err := contract.GetImportBatch(ctx, "B1")
+			if err == nil && (got.BatchHash != sha256Hex(batch) || got.Imported != 1 || got.ImportedBy != "CreditorMSP") {
+				t.Errorf("unexpected batch %+v", got)
+			}
+			return err
+		}},
+		{name: "unknown batch", id: chaincodetest.Debtor, wantErr: "batch B2 does not exist", call: func(ctx contractapi.TransactionContextInterface) error {
+			_, err := contract.GetImportBatch(ctx, "B2")
+			return err
+		}},
+	})
+}
@@ -0,0 +1,80 @@
+package chaincodetest
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+)
+
+// Collection is a private data collection definition. Once any collection
+// is defined, only defined collections can be used and member-only access is
+// enforced against the transaction creator's MSP.
+type Collection struct {
+	Name            string `json:"name"`
+	Policy          string `json:"policy"`
+	MemberOnlyRead  bool   `json:"memberOnlyRead"`
+	MemberOnlyWrite bool   `json:"memberOnlyWrite"`
+	members         map[string]bool
+}
+
+var policyMember = regexp.MustCompile(`'([^'.]+)\.(?:member|peer|client|admin)'`)
+
+// LoadCollections defines the collections in a collections_config.json file
+func (s *Stub) LoadCollections(path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var collections []*Collection
+	if err := json.Unmarshal(b, &collections); err != nil {
+		return fmt.Errorf("invalid collections config %s: %v", path, err)
+	}
+	for _, c := range collections {
+		c.members = map[string]bool{}
+		for _, m := range policyMember.FindAllStringSubmatch(c.Policy, -1) {
+			c.members[m[1]] = true
+		}
+		s.collections[c.Name] = c
+	}
+	return nil
+}
+
+func (s *Stub) collection(name string) (*Collection, error) {
+	if len(s.collections) == 0 {
+		return &Collection{Name: name}, nil
+	}
+	c, ok := s.collections[name]
+	if !ok {
+		return nil, fmt.Errorf("collection %s could not be found", name)
+	}
+	return c, nil
+}
+
+// checkCollection enforces memberOnlyRead or memberOnlyWrite for the creator
+func (s *Stub) checkCollection(name string, write bool) error {
+	c, err := s.collection(name)
+	if err != nil {
+		return err
+	}
+	if (write && !c.MemberOnlyWrite) || (!write && !c.MemberOnlyRead) {
+		return nil
+	}
+	if err := s.inTx(); err != nil {
+		return err
+	}
+	if c.members[s.tx.creator.MSPID] {
+		return nil
+	}
+	access := "read"
+	if write {
+		access = "write"
+	}
+	return fmt.Errorf("tx creator does not have %s access permission on privatedata in collectionName:%s", access, name)
+}
+
+func hash(b []byte) []byte {
+	h := sha256.Sum256(b)
+	return h[:]
+}
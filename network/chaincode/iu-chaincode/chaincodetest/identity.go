@@ -0,0 +1,100 @@
+package chaincodetest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-protos-go/msp"
+)
+
+// Identity is a client of an org, backed by a self-signed certificate so
+// cid and contractapi see it exactly as they would a real Fabric identity
+type Identity struct {
+	MSPID string
+	Cert  *x509.Certificate
+	pem   []byte
+}
+
+// Client identities of the IU network's orgs
+var (
+	Creditor = NewIdentity("CreditorMSP", "Admin@creditor.iu-network.com")
+	Debtor   = NewIdentity("DebtorMSP", "Admin@debtor.iu-network.com")
+	Admin    = NewIdentity("AdminMSP", "Admin@admin.iu-network.com")
+)
+
+// NewIdentity creates a client identity of mspID with the given common name
+func NewIdentity(mspID, commonName string) *Identity {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName, Organization: []string{mspID}, OrganizationalUnit: []string{"client"}},
+		NotBefore:    time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:     time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		panic(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		panic(err)
+	}
+	return &Identity{
+		MSPID: mspID,
+		Cert:  cert,
+		pem:   pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	}
+}
+
+// creator is the minimal stub cid needs to parse an identity
+type creator []byte
+
+func (c creator) GetCreator() ([]byte, error) { return c, nil }
+
+// ID returns the identity's ID as ClientIdentity.GetID reports it in chaincode
+func (id *Identity) ID() string {
+	clientID, err := ClientID(id.MSPID, id.pem)
+	if err != nil {
+		panic(err)
+	}
+	return clientID
+}
+
+// Serialize returns the msp.SerializedIdentity that GetCreator reports
+func (id *Identity) Serialize() []byte {
+	b, err := serialize(id.MSPID, id.pem)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// ClientID returns the ID that ClientIdentity.GetID reports in chaincode for
+// a client of mspID with the PEM certificate certPEM
+func ClientID(mspID string, certPEM []byte) (string, error) {
+	b, err := serialize(mspID, certPEM)
+	if err != nil {
+		return "", err
+	}
+	client, err := cid.New(creator(b))
+	if err != nil {
+		return "", err
+	}
+	return client.GetID()
+}
+
+func serialize(mspID string, certPEM []byte) ([]byte, error) {
+	return proto.Marshal(&msp.SerializedIdentity{Mspid: mspID, IdBytes: certPEM})
+}
@@ -0,0 +1,358 @@
+package chaincodetest
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+)
+
+// richQuery emulates CouchDB's Mango queries over the JSON values of m:
+// selectors with the usual condition and combination operators, dotted
+// field paths, sort, skip and limit. Non-JSON values are never matched, as
+// in CouchDB. Strings compare by bytes rather than ICU collation, and
+// use_index is ignored. Paginated queries use the result offset as bookmark.
+func richQuery(m map[string][]byte, query string, pageSize int32, bookmark string) ([]*queryresult.KV, string, error) {
+	var q struct {
+		Selector map[string]interface{} `json:"selector"`
+		Sort     []interface{}          `json:"sort"`
+		Limit    *int                   `json:"limit"`
+		Skip     int                    `json:"skip"`
+	}
+	if err := json.Unmarshal([]byte(query), &q); err != nil {
+		return nil, "", fmt.Errorf("invalid query %s: %v", query, err)
+	}
+	if q.Selector == nil {
+		return nil, "", fmt.Errorf("query %s has no selector", query)
+	}
+
+	type doc struct {
+		kv    *queryresult.KV
+		value map[string]interface{}
+	}
+	var docs []doc
+	for _, k := range sortedKeys(m) {
+		if strings.HasPrefix(k, compositeKeyNamespace) {
+			continue
+		}
+		var value map[string]interface{}
+		if json.Unmarshal(m[k], &value) != nil || value == nil {
+			continue
+		}
+		value["_id"] = k
+		ok, err := matchSelector(value, q.Selector)
+		if err != nil {
+			return nil, "", err
+		}
+		if ok {
+			docs = append(docs, doc{kv: &queryresult.KV{Key: k, Value: m[k]}, value: value})
+		}
+	}
+
+	var fields []string
+	var desc []bool
+	for _, s := range q.Sort {
+		switch v := s.(type) {
+		case string:
+			fields, desc = append(fields, v), append(desc, false)
+		case map[string]interface{}:
+			for f, dir := range v {
+				fields, desc = append(fields, f), append(desc, dir == "desc")
+			}
+		default:
+			return nil, "", fmt.Errorf("invalid sort %v", s)
+		}
+	}
+	sort.SliceStable(docs, func(i, j int) bool {
+		for n, f := range fields {
+			a, _ := lookup(docs[i].value, f)
+			b, _ := lookup(docs[j].value, f)
+			if c := collate(a, b); c != 0 {
+				return (c < 0) != desc[n]
+			}
+		}
+		return false
+	})
+
+	if q.Skip > 0 {
+		if q.Skip > len(docs) {
+			q.Skip = len(docs)
+		}
+		docs = docs[q.Skip:]
+	}
+	if q.Limit != nil && *q.Limit < len(docs) {
+		docs = docs[:*q.Limit]
+	}
+	next := ""
+	if pageSize > 0 {
+		start := 0
+		if bookmark != "" {
+			var err error
+			if start, err = strconv.Atoi(bookmark); err != nil || start < 0 {
+				return nil, "", fmt.Errorf("invalid bookmark %q", bookmark)
+			}
+		}
+		if start > len(docs) {
+			start = len(docs)
+		}
+		end := start + int(pageSize)
+		if end > len(docs) {
+			end = len(docs)
+		}
+		docs = docs[start:end]
+		next = strconv.Itoa(end)
+	}
+
+	kvs := make([]*queryresult.KV, len(docs))
+	for i, d := range docs {
+		kvs[i] = d.kv
+	}
+	return kvs, next, nil
+}
+
+func matchSelector(doc interface{}, selector map[string]interface{}) (bool, error) {
+	for field, cond := range selector {
+		var ok bool
+		var err error
+		switch field {
+		case "$and", "$or", "$nor":
+			ok, err = matchCombination(doc, field, cond)
+		case "$not":
+			sub, isMap := cond.(map[string]interface{})
+			if !isMap {
+				return false, fmt.Errorf("$not needs a selector")
+			}
+			ok, err = matchSelector(doc, sub)
+			ok = !ok
+		default:
+			value, exists := lookup(doc, field)
+			ok, err = matchCondition(value, exists, cond)
+		}
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func matchCombination(doc interface{}, op string, cond interface{}) (bool, error) {
+	list, ok := cond.([]interface{})
+	if !ok {
+		return false, fmt.Errorf("%s needs an array of selectors", op)
+	}
+	matched := 0
+	for _, c := range list {
+		sub, ok := c.(map[string]interface{})
+		if !ok {
+			return false, fmt.Errorf("%s needs an array of selectors", op)
+		}
+		ok, err := matchSelector(doc, sub)
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			matched++
+		}
+	}
+	switch op {
+	case "$and":
+		return matched == len(list), nil
+	case "$or":
+		return matched > 0, nil
+	}
+	return matched == 0, nil
+}
+
+func isOperatorMap(cond interface{}) (map[string]interface{}, bool) {
+	m, ok := cond.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	for k := range m {
+		if strings.HasPrefix(k, "$") {
+			return m, true
+		}
+	}
+	return nil, false
+}
+
+func matchCondition(value interface{}, exists bool, cond interface{}) (bool, error) {
+	ops, ok := isOperatorMap(cond)
+	if !ok {
+		if sub, isMap := cond.(map[string]interface{}); isMap {
+			// {"a": {"b": 1}} selects on a.b
+			if !exists {
+				return false, nil
+			}
+			return matchSelector(value, sub)
+		}
+		return exists && collate(value, cond) == 0, nil
+	}
+	for op, arg := range ops {
+		var ok bool
+		switch op {
+		case "$exists":
+			want, isBool := arg.(bool)
+			if !isBool {
+				return false, fmt.Errorf("$exists needs a boolean")
+			}
+			ok = exists == want
+		case "$eq":
+			ok = exists && collate(value, arg) == 0
+		case "$ne":
+			ok = exists && collate(value, arg) != 0
+		case "$gt":
+			ok = exists && collate(value, arg) > 0
+		case "$gte":
+			ok = exists && collate(value, arg) >= 0
+		case "$lt":
+			ok = exists && collate(value, arg) < 0
+		case "$lte":
+			ok = exists && collate(value, arg) <= 0
+		case "$in", "$nin":
+			list, isList := arg.([]interface{})
+			if !isList {
+				return false, fmt.Errorf("%s needs an array", op)
+			}
+			found := false
+			for _, v := range list {
+				if collate(value, v) == 0 {
+					found = true
+				}
+			}
+			ok = exists && found == (op == "$in")
+		case "$regex":
+			pattern, isString := arg.(string)
+			s, valueIsString := value.(string)
+			if !isString {
+				return false, fmt.Errorf("$regex needs a string")
+			}
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return false, err
+			}
+			ok = valueIsString && re.MatchString(s)
+		case "$size":
+			list, isList := value.([]interface{})
+			n, isNumber := arg.(float64)
+			ok = isList && isNumber && float64(len(list)) == n
+		case "$type":
+			ok = exists && typeName(value) == arg
+		case "$all":
+			list, isList := value.([]interface{})
+			want, wantList := arg.([]interface{})
+			if !wantList {
+				return false, fmt.Errorf("$all needs an array")
+			}
+			ok = isList
+			for _, w := range want {
+				found := false
+				for _, v := range list {
+					if collate(v, w) == 0 {
+						found = true
+					}
+				}
+				ok = ok && found
+			}
+		case "$elemMatch":
+			list, isList := value.([]interface{})
+			for _, v := range list {
+				match, err := matchCondition(v, true, arg)
+				if err != nil {
+					return false, err
+				}
+				if match {
+					ok = true
+					break
+				}
+			}
+			ok = ok && isList
+		default:
+			return false, fmt.Errorf("unsupported query operator %s", op)
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// lookup resolves a dotted field path
+func lookup(doc interface{}, path string) (interface{}, bool) {
+	value := doc
+	for _, part := range strings.Split(path, ".") {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = m[part]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+func typeName(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	}
+	return "object"
+}
+
+// collate orders JSON values as CouchDB does: null, booleans, numbers,
+// strings, arrays, then objects
+func collate(a, b interface{}) int {
+	rank := map[string]int{"null": 0, "boolean": 1, "number": 2, "string": 3, "array": 4, "object": 5}
+	ta, tb := typeName(a), typeName(b)
+	if ta != tb {
+		return rank[ta] - rank[tb]
+	}
+	switch av := a.(type) {
+	case nil:
+		return 0
+	case bool:
+		bv := b.(bool)
+		if av == bv {
+			return 0
+		}
+		if !av {
+			return -1
+		}
+		return 1
+	case float64:
+		bv := b.(float64)
+		if av < bv {
+			return -1
+		}
+		if av > bv {
+			return 1
+		}
+		return 0
+	case string:
+		return strings.Compare(av, b.(string))
+	case []interface{}:
+		bv := b.([]interface{})
+		for i := 0; i < len(av) && i < len(bv); i++ {
+			if c := collate(av[i], bv[i]); c != 0 {
+				return c
+			}
+		}
+		return len(av) - len(bv)
+	}
+	ja, _ := json.Marshal(a)
+	jb, _ := json.Marshal(b)
+	return strings.Compare(string(ja), string(jb))
+}
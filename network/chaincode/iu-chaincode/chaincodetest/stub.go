@@ -0,0 +1,729 @@
+// Package chaincodetest runs iu-chaincode's contract functions against an
+// in-memory ledger in unit tests. Stub implements shim.ChaincodeStubInterface
+// with world state, private data collections, composite keys, key history,
+// CouchDB rich queries, transient data, events and transaction timestamps.
+//
+// Each Tx or Invoke is one transaction: reads see only committed state, as on
+// a peer, and its writes and event are committed together when it succeeds
+// or discarded when it fails.
+package chaincodetest
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Start is the timestamp of a new stub's first transaction
+var Start = time.Date(2024, 4, 1, 10, 0, 0, 0, time.UTC)
+
+// Stub is an in-memory ledger for one channel
+type Stub struct {
+	channel string
+	clock   time.Time
+	step    time.Duration
+	seq     int
+
+	state       map[string][]byte
+	history     map[string][]*queryresult.KeyModification // oldest first
+	validation  map[string][]byte
+	private     map[string]map[string][]byte
+	collections map[string]*Collection
+	chaincodes  map[string]registered
+	events      []*pb.ChaincodeEvent
+
+	tx *transaction
+}
+
+type registered struct {
+	chaincode shim.Chaincode
+	stub      *Stub
+}
+
+type write struct {
+	value  []byte
+	delete bool
+}
+
+// transaction holds one transaction's proposal and write set until commit
+type transaction struct {
+	id         string
+	timestamp  time.Time
+	creator    *Identity
+	args       [][]byte
+	transient  map[string][]byte
+	writes     map[string]*write
+	validation map[string][]byte
+	private    map[string]map[string]*write
+	event      *pb.ChaincodeEvent
+}
+
+// NewStub returns an empty ledger for channel. Transactions are timestamped
+// from Start, one minute apart.
+func NewStub(channel string) *Stub {
+	return &Stub{
+		channel:     channel,
+		clock:       Start,
+		step:        time.Minute,
+		state:       map[string][]byte{},
+		history:     map[string][]*queryresult.KeyModification{},
+		validation:  map[string][]byte{},
+		private:     map[string]map[string][]byte{},
+		collections: map[string]*Collection{},
+		chaincodes:  map[string]registered{},
+	}
+}
+
+// SetTime sets the timestamp of the next transaction
+func (s *Stub) SetTime(t time.Time) {
+	s.clock = t
+}
+
+// SetStep sets how far apart transactions are timestamped
+func (s *Stub) SetStep(d time.Duration) {
+	s.step = d
+}
+
+// Now returns the timestamp the next transaction will get
+func (s *Stub) Now() time.Time {
+	return s.clock
+}
+
+// TxOption customises a transaction's proposal
+type TxOption func(*transaction)
+
+// WithTransient passes transient data, as a client's WithTransient does
+func WithTransient(transient map[string][]byte) TxOption {
+	return func(tx *transaction) {
+		tx.transient = transient
+	}
+}
+
+// WithTxID sets the transaction ID instead of the generated one
+func WithTxID(txID string) TxOption {
+	return func(tx *transaction) {
+		tx.id = txID
+	}
+}
+
+// Tx runs fn as one transaction submitted by id and commits its writes if
+// fn returns nil
+func (s *Stub) Tx(id *Identity, fn func(ctx contractapi.TransactionContextInterface) error, opts ...TxOption) error {
+	s.begin(id, nil, opts)
+	defer s.end()
+	ctx, err := s.context()
+	if err != nil {
+		return err
+	}
+	if err := fn(ctx); err != nil {
+		return err
+	}
+	s.commit()
+	return nil
+}
+
+// Seed runs fn as a transaction committed before the stub's first one, to
+// load fixtures. It takes no transaction number or time step, so the
+// transactions that follow get the same TxIDs and timestamps as on an
+// unseeded stub.
+func (s *Stub) Seed(id *Identity, fn func(ctx contractapi.TransactionContextInterface) error) error {
+	seq, clock := s.seq, s.clock
+	s.clock = clock.Add(-s.step)
+	err := s.Tx(id, fn, WithTxID("seed"))
+	s.seq, s.clock = seq, clock
+	return err
+}
+
+// Invoke calls the chaincode with args, the function name first, through its
+// Invoke entry point as a peer would, and commits if it succeeds
+func (s *Stub) Invoke(cc shim.Chaincode, id *Identity, args []string, opts ...TxOption) pb.Response {
+	s.begin(id, byteArgs(args), opts)
+	defer s.end()
+	response := cc.Invoke(s)
+	if response.Status < shim.ERRORTHRESHOLD {
+		s.commit()
+	}
+	return response
+}
+
+// Evaluate calls the chaincode like Invoke but discards its writes, as a
+// gateway evaluation does. It does not use up a transaction timestamp.
+func (s *Stub) Evaluate(cc shim.Chaincode, id *Identity, args []string) pb.Response {
+	clock, seq := s.clock, s.seq
+	s.begin(id, byteArgs(args), nil)
+	defer func() {
+		s.end()
+		s.clock, s.seq = clock, seq
+	}()
+	return cc.Invoke(s)
+}
+
+// RegisterChaincode makes cc, running on target's ledger, reachable through
+// InvokeChaincode. Calls to another channel are read-only, as on a peer.
+func (s *Stub) RegisterChaincode(name string, cc shim.Chaincode, target *Stub) {
+	s.chaincodes[name+"/"+target.channel] = registered{chaincode: cc, stub: target}
+}
+
+func byteArgs(args []string) [][]byte {
+	b := make([][]byte, len(args))
+	for i, a := range args {
+		b[i] = []byte(a)
+	}
+	return b
+}
+
+func (s *Stub) begin(id *Identity, args [][]byte, opts []TxOption) {
+	if s.tx != nil {
+		panic("chaincodetest: transaction already in progress")
+	}
+	s.seq++
+	tx := &transaction{
+		id:         fmt.Sprintf("tx%04d", s.seq),
+		timestamp:  s.clock,
+		creator:    id,
+		args:       args,
+		transient:  map[string][]byte{},
+		writes:     map[string]*write{},
+		validation: map[string][]byte{},
+		private:    map[string]map[string]*write{},
+	}
+	for _, opt := range opts {
+		opt(tx)
+	}
+	s.clock = s.clock.Add(s.step)
+	s.tx = tx
+}
+
+func (s *Stub) end() {
+	s.tx = nil
+}
+
+func (s *Stub) context() (*contractapi.TransactionContext, error) {
+	ctx := new(contractapi.TransactionContext)
+	ctx.SetStub(s)
+	clientIdentity, err := cid.New(s)
+	if err != nil {
+		return nil, err
+	}
+	ctx.SetClientIdentity(clientIdentity)
+	return ctx, nil
+}
+
+func (s *Stub) commit() {
+	tx := s.tx
+	ts := timestamppb.New(tx.timestamp)
+	for _, key := range sortedKeys(tx.writes) {
+		w := tx.writes[key]
+		if w.delete {
+			delete(s.state, key)
+			delete(s.validation, key)
+		} else {
+			s.state[key] = w.value
+		}
+		s.history[key] = append(s.history[key], &queryresult.KeyModification{
+			TxId:      tx.id,
+			Value:     w.value,
+			Timestamp: ts,
+			IsDelete:  w.delete,
+		})
+	}
+	for key, ep := range tx.validation {
+		s.validation[key] = ep
+	}
+	for collection, writes := range tx.private {
+		if s.private[collection] == nil {
+			s.private[collection] = map[string][]byte{}
+		}
+		for key, w := range writes {
+			if w.delete {
+				delete(s.private[collection], key)
+			} else {
+				s.private[collection][key] = w.value
+			}
+		}
+	}
+	if tx.event != nil {
+		s.events = append(s.events, tx.event)
+	}
+}
+
+func (s *Stub) inTx() error {
+	if s.tx == nil {
+		return fmt.Errorf("no transaction in progress")
+	}
+	return nil
+}
+
+// State returns the committed value of key
+func (s *Stub) State(key string) []byte {
+	return s.state[key]
+}
+
+// PrivateState returns the committed value of key in collection
+func (s *Stub) PrivateState(collection, key string) []byte {
+	return s.private[collection][key]
+}
+
+// KeyHistory returns every committed version of key, newest first
+func (s *Stub) KeyHistory(key string) []*queryresult.KeyModification {
+	versions := s.history[key]
+	out := make([]*queryresult.KeyModification, len(versions))
+	for i, v := range versions {
+		out[len(versions)-1-i] = v
+	}
+	return out
+}
+
+// ValidationParameter returns the committed key-level endorsement policy of key
+func (s *Stub) ValidationParameter(key string) []byte {
+	return s.validation[key]
+}
+
+// Events returns the events of committed transactions, oldest first
+func (s *Stub) Events() []*pb.ChaincodeEvent {
+	return s.events
+}
+
+// LastEvent returns the event of the last committed transaction that set one
+func (s *Stub) LastEvent() *pb.ChaincodeEvent {
+	if len(s.events) == 0 {
+		return nil
+	}
+	return s.events[len(s.events)-1]
+}
+
+// Keys returns the committed keys starting with prefix, in order
+func (s *Stub) Keys(prefix string) []string {
+	var keys []string
+	for _, k := range sortedKeys(s.state) {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// shim.ChaincodeStubInterface
+
+func (s *Stub) GetArgs() [][]byte {
+	if s.tx == nil {
+		return nil
+	}
+	return s.tx.args
+}
+
+func (s *Stub) GetStringArgs() []string {
+	args := s.GetArgs()
+	out := make([]string, len(args))
+	for i, a := range args {
+		out[i] = string(a)
+	}
+	return out
+}
+
+func (s *Stub) GetFunctionAndParameters() (string, []string) {
+	args := s.GetStringArgs()
+	if len(args) == 0 {
+		return "", []string{}
+	}
+	return args[0], args[1:]
+}
+
+func (s *Stub) GetArgsSlice() ([]byte, error) {
+	return bytes.Join(s.GetArgs(), nil), nil
+}
+
+func (s *Stub) GetTxID() string {
+	if s.tx == nil {
+		return ""
+	}
+	return s.tx.id
+}
+
+func (s *Stub) GetChannelID() string {
+	return s.channel
+}
+
+// InvokeChaincode calls a chaincode registered with RegisterChaincode. On the
+// same channel the callee shares this transaction; on another channel it runs
+// as a read-only query whose writes are discarded.
+func (s *Stub) InvokeChaincode(chaincodeName string, args [][]byte, channel string) pb.Response {
+	if err := s.inTx(); err != nil {
+		return shim.Error(err.Error())
+	}
+	if channel == "" {
+		channel = s.channel
+	}
+	r, ok := s.chaincodes[chaincodeName+"/"+channel]
+	if !ok {
+		return shim.Error(fmt.Sprintf("chaincode %s is not registered on channel %s", chaincodeName, channel))
+	}
+	if r.stub == s {
+		saved := s.tx.args
+		s.tx.args = args
+		defer func() { s.tx.args = saved }()
+		return r.chaincode.Invoke(s)
+	}
+	callee := r.stub
+	callee.begin(s.tx.creator, args, []TxOption{WithTxID(s.tx.id), WithTransient(s.tx.transient)})
+	callee.tx.timestamp = s.tx.timestamp
+	callee.clock = callee.clock.Add(-callee.step)
+	defer callee.end()
+	return r.chaincode.Invoke(callee)
+}
+
+func (s *Stub) GetState(key string) ([]byte, error) {
+	return s.state[key], nil
+}
+
+func (s *Stub) PutState(key string, value []byte) error {
+	if err := s.inTx(); err != nil {
+		return err
+	}
+	if key == "" {
+		return fmt.Errorf("key must not be an empty string")
+	}
+	if !utf8.ValidString(key) {
+		return fmt.Errorf("key %q is not valid UTF-8", key)
+	}
+	s.tx.writes[key] = &write{value: value}
+	return nil
+}
+
+func (s *Stub) DelState(key string) error {
+	if err := s.inTx(); err != nil {
+		return err
+	}
+	s.tx.writes[key] = &write{delete: true}
+	return nil
+}
+
+func (s *Stub) SetStateValidationParameter(key string, ep []byte) error {
+	if err := s.inTx(); err != nil {
+		return err
+	}
+	s.tx.validation[key] = ep
+	return nil
+}
+
+func (s *Stub) GetStateValidationParameter(key string) ([]byte, error) {
+	return s.validation[key], nil
+}
+
+func (s *Stub) GetStateByRange(startKey, endKey string) (shim.StateQueryIteratorInterface, error) {
+	kvs, _ := s.rangeKVs(s.state, startKey, endKey, 0, "")
+	return &stateIterator{kvs: kvs}, nil
+}
+
+func (s *Stub) GetStateByRangeWithPagination(startKey, endKey string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
+	kvs, next := s.rangeKVs(s.state, startKey, endKey, pageSize, bookmark)
+	return &stateIterator{kvs: kvs}, &pb.QueryResponseMetadata{FetchedRecordsCount: int32(len(kvs)), Bookmark: next}, nil
+}
+
+func (s *Stub) GetStateByPartialCompositeKey(objectType string, keys []string) (shim.StateQueryIteratorInterface, error) {
+	prefix, err := s.CreateCompositeKey(objectType, keys)
+	if err != nil {
+		return nil, err
+	}
+	return &stateIterator{kvs: prefixKVs(s.state, prefix, 0, "")}, nil
+}
+
+func (s *Stub) GetStateByPartialCompositeKeyWithPagination(objectType string, keys []string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
+	prefix, err := s.CreateCompositeKey(objectType, keys)
+	if err != nil {
+		return nil, nil, err
+	}
+	kvs := prefixKVs(s.state, prefix, pageSize+1, bookmark)
+	next := ""
+	if pageSize > 0 && int32(len(kvs)) > pageSize {
+		next = kvs[pageSize].Key
+		kvs = kvs[:pageSize]
+	}
+	return &stateIterator{kvs: kvs}, &pb.QueryResponseMetadata{FetchedRecordsCount: int32(len(kvs)), Bookmark: next}, nil
+}
+
+const (
+	compositeKeyNamespace = "\x00"
+	maxUnicodeRune        = string(utf8.MaxRune)
+)
+
+func (s *Stub) CreateCompositeKey(objectType string, attributes []string) (string, error) {
+	if err := validateCompositeKeyAttribute(objectType); err != nil {
+		return "", err
+	}
+	key := compositeKeyNamespace + objectType + "\x00"
+	for _, a := range attributes {
+		if err := validateCompositeKeyAttribute(a); err != nil {
+			return "", err
+		}
+		key += a + "\x00"
+	}
+	return key, nil
+}
+
+func validateCompositeKeyAttribute(str string) error {
+	if !utf8.ValidString(str) {
+		return fmt.Errorf("not a valid utf8 string: [%x]", str)
+	}
+	if strings.ContainsAny(str, "\x00"+maxUnicodeRune) {
+		return fmt.Errorf("input contains unicode %#U or %#U starting at position [0]. %#U and %#U are not allowed in the input attribute of a composite key", 0, utf8.MaxRune, 0, utf8.MaxRune)
+	}
+	return nil
+}
+
+func (s *Stub) SplitCompositeKey(compositeKey string) (string, []string, error) {
+	if !strings.HasPrefix(compositeKey, compositeKeyNamespace) {
+		return "", nil, fmt.Errorf("%q is not a composite key", compositeKey)
+	}
+	parts := strings.Split(compositeKey[1:], "\x00")
+	if len(parts) < 2 {
+		return "", nil, fmt.Errorf("%q is not a composite key", compositeKey)
+	}
+	return parts[0], parts[1 : len(parts)-1], nil
+}
+
+func (s *Stub) GetQueryResult(query string) (shim.StateQueryIteratorInterface, error) {
+	kvs, _, err := richQuery(s.state, query, 0, "")
+	if err != nil {
+		return nil, err
+	}
+	return &stateIterator{kvs: kvs}, nil
+}
+
+func (s *Stub) GetQueryResultWithPagination(query string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
+	kvs, next, err := richQuery(s.state, query, pageSize, bookmark)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &stateIterator{kvs: kvs}, &pb.QueryResponseMetadata{FetchedRecordsCount: int32(len(kvs)), Bookmark: next}, nil
+}
+
+func (s *Stub) GetHistoryForKey(key string) (shim.HistoryQueryIteratorInterface, error) {
+	return &historyIterator{mods: s.KeyHistory(key)}, nil
+}
+
+func (s *Stub) GetPrivateData(collection, key string) ([]byte, error) {
+	if err := s.checkCollection(collection, false); err != nil {
+		return nil, err
+	}
+	return s.private[collection][key], nil
+}
+
+func (s *Stub) GetPrivateDataHash(collection, key string) ([]byte, error) {
+	if _, err := s.collection(collection); err != nil {
+		return nil, err
+	}
+	value := s.private[collection][key]
+	if value == nil {
+		return nil, nil
+	}
+	return hash(value), nil
+}
+
+func (s *Stub) PutPrivateData(collection, key string, value []byte) error {
+	if err := s.inTx(); err != nil {
+		return err
+	}
+	if key == "" {
+		return fmt.Errorf("key must not be an empty string")
+	}
+	if len(value) == 0 {
+		return fmt.Errorf("value must not be empty")
+	}
+	return s.writePrivate(collection, key, &write{value: value})
+}
+
+func (s *Stub) DelPrivateData(collection, key string) error {
+	if err := s.inTx(); err != nil {
+		return err
+	}
+	return s.writePrivate(collection, key, &write{delete: true})
+}
+
+func (s *Stub) PurgePrivateData(collection, key string) error {
+	return s.DelPrivateData(collection, key)
+}
+
+func (s *Stub) writePrivate(collection, key string, w *write) error {
+	if err := s.checkCollection(collection, true); err != nil {
+		return err
+	}
+	if s.tx.private[collection] == nil {
+		s.tx.private[collection] = map[string]*write{}
+	}
+	s.tx.private[collection][key] = w
+	return nil
+}
+
+func (s *Stub) SetPrivateDataValidationParameter(collection, key string, ep []byte) error {
+	if err := s.inTx(); err != nil {
+		return err
+	}
+	s.tx.validation[collection+"\x00"+key] = ep
+	return nil
+}
+
+func (s *Stub) GetPrivateDataValidationParameter(collection, key string) ([]byte, error) {
+	return s.validation[collection+"\x00"+key], nil
+}
+
+func (s *Stub) GetPrivateDataByRange(collection, startKey, endKey string) (shim.StateQueryIteratorInterface, error) {
+	if err := s.checkCollection(collection, false); err != nil {
+		return nil, err
+	}
+	kvs, _ := s.rangeKVs(s.private[collection], startKey, endKey, 0, "")
+	return &stateIterator{kvs: kvs}, nil
+}
+
+func (s *Stub) GetPrivateDataByPartialCompositeKey(collection, objectType string, keys []string) (shim.StateQueryIteratorInterface, error) {
+	if err := s.checkCollection(collection, false); err != nil {
+		return nil, err
+	}
+	prefix, err := s.CreateCompositeKey(objectType, keys)
+	if err != nil {
+		return nil, err
+	}
+	return &stateIterator{kvs: prefixKVs(s.private[collection], prefix, 0, "")}, nil
+}
+
+func (s *Stub) GetPrivateDataQueryResult(collection, query string) (shim.StateQueryIteratorInterface, error) {
+	if err := s.checkCollection(collection, false); err != nil {
+		return nil, err
+	}
+	kvs, _, err := richQuery(s.private[collection], query, 0, "")
+	if err != nil {
+		return nil, err
+	}
+	return &stateIterator{kvs: kvs}, nil
+}
+
+func (s *Stub) GetCreator() ([]byte, error) {
+	if err := s.inTx(); err != nil {
+		return nil, err
+	}
+	return s.tx.creator.Serialize(), nil
+}
+
+func (s *Stub) GetTransient() (map[string][]byte, error) {
+	if err := s.inTx(); err != nil {
+		return nil, err
+	}
+	return s.tx.transient, nil
+}
+
+func (s *Stub) GetBinding() ([]byte, error) {
+	return nil, fmt.Errorf("GetBinding is not supported by the test stub")
+}
+
+func (s *Stub) GetDecorations() map[string][]byte {
+	return map[string][]byte{}
+}
+
+func (s *Stub) GetSignedProposal() (*pb.SignedProposal, error) {
+	return nil, fmt.Errorf("GetSignedProposal is not supported by the test stub")
+}
+
+func (s *Stub) GetTxTimestamp() (*timestamppb.Timestamp, error) {
+	if err := s.inTx(); err != nil {
+		return nil, err
+	}
+	return timestamppb.New(s.tx.timestamp), nil
+}
+
+// SetEvent sets the transaction's event; as on a peer, only the last one set is kept
+func (s *Stub) SetEvent(name string, payload []byte) error {
+	if err := s.inTx(); err != nil {
+		return err
+	}
+	if name == "" {
+		return fmt.Errorf("event name can not be empty string")
+	}
+	s.tx.event = &pb.ChaincodeEvent{TxId: s.tx.id, EventName: name, Payload: payload}
+	return nil
+}
+
+// rangeKVs returns the non-composite keys in [startKey, endKey), resuming at
+// bookmark, and the bookmark of the next page
+func (s *Stub) rangeKVs(m map[string][]byte, startKey, endKey string, pageSize int32, bookmark string) ([]*queryresult.KV, string) {
+	if bookmark != "" {
+		startKey = bookmark
+	}
+	var kvs []*queryresult.KV
+	for _, k := range sortedKeys(m) {
+		if strings.HasPrefix(k, compositeKeyNamespace) || k < startKey || (endKey != "" && k >= endKey) {
+			continue
+		}
+		if pageSize > 0 && int32(len(kvs)) == pageSize {
+			return kvs, k
+		}
+		kvs = append(kvs, &queryresult.KV{Key: k, Value: m[k]})
+	}
+	return kvs, ""
+}
+
+func prefixKVs(m map[string][]byte, prefix string, limit int32, bookmark string) []*queryresult.KV {
+	var kvs []*queryresult.KV
+	for _, k := range sortedKeys(m) {
+		if !strings.HasPrefix(k, prefix) || k < bookmark {
+			continue
+		}
+		if limit > 0 && int32(len(kvs)) == limit {
+			break
+		}
+		kvs = append(kvs, &queryresult.KV{Key: k, Value: m[k]})
+	}
+	return kvs
+}
+
+type stateIterator struct {
+	kvs []*queryresult.KV
+	i   int
+}
+
+func (it *stateIterator) HasNext() bool { return it.i < len(it.kvs) }
+func (it *stateIterator) Close() error  { return nil }
+
+func (it *stateIterator) Next() (*queryresult.KV, error) {
+	if !it.HasNext() {
+		return nil, fmt.Errorf("no more results")
+	}
+	it.i++
+	return it.kvs[it.i-1], nil
+}
+
+type historyIterator struct {
+	mods []*queryresult.KeyModification
+	i    int
+}
+
+func (it *historyIterator) HasNext() bool { return it.i < len(it.mods) }
+func (it *historyIterator) Close() error  { return nil }
+
+func (it *historyIterator) Next() (*queryresult.KeyModification, error) {
+	if !it.HasNext() {
+		return nil, fmt.Errorf("no more results")
+	}
+	it.i++
+	return it.mods[it.i-1], nil
+}
+
+var _ shim.ChaincodeStubInterface = (*Stub)(nil)
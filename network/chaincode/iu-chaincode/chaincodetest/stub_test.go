@@ -0,0 +1,248 @@
+package chaincodetest
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+func put(key, value string) func(ctx contractapi.TransactionContextInterface) error {
+	return func(ctx contractapi.TransactionContextInterface) error {
+		return ctx.GetStub().PutState(key, []byte(value))
+	}
+}
+
+func keys(it shim.StateQueryIteratorInterface) []string {
+	defer it.Close()
+	var out []string
+	for it.HasNext() {
+		kv, _ := it.Next()
+		out = append(out, kv.Key)
+	}
+	return out
+}
+
+func TestTransactionIsolation(t *testing.T) {
+	s := NewStub("ch")
+	err := s.Tx(Creditor, func(ctx contractapi.TransactionContextInterface) error {
+		stub := ctx.GetStub()
+		stub.PutState("A", []byte("1"))
+		if v, _ := stub.GetState("A"); v != nil {
+			t.Errorf("uncommitted write visible: %s", v)
+		}
+		return stub.SetEvent("E", []byte("{}"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(s.State("A")) != "1" || len(s.Events()) != 1 {
+		t.Fatal("writes not committed")
+	}
+
+	err = s.Tx(Creditor, func(ctx contractapi.TransactionContextInterface) error {
+		ctx.GetStub().PutState("A", []byte("2"))
+		ctx.GetStub().SetEvent("E", []byte("{}"))
+		return errors.New("endorsement failed")
+	})
+	if err == nil || string(s.State("A")) != "1" || len(s.Events()) != 1 {
+		t.Fatal("failed transaction was committed")
+	}
+	if err := s.PutState("A", []byte("3")); err == nil {
+		t.Fatal("write outside a transaction accepted")
+	}
+}
+
+func TestHistoryAndTime(t *testing.T) {
+	s := NewStub("ch")
+	s.Tx(Creditor, put("A", "1"))
+	s.SetTime(Start.Add(time.Hour))
+	s.Tx(Creditor, put("A", "2"))
+	s.Tx(Creditor, func(ctx contractapi.TransactionContextInterface) error {
+		ts, _ := ctx.GetStub().GetTxTimestamp()
+		if !ts.AsTime().Equal(Start.Add(time.Hour + time.Minute)) {
+			t.Errorf("timestamp = %v", ts.AsTime())
+		}
+		return ctx.GetStub().DelState("A")
+	})
+
+	history := s.KeyHistory("A")
+	var got []string
+	for _, m := range history {
+		got = append(got, fmt.Sprintf("%s:%s:%v", m.TxId, m.Value, m.IsDelete))
+	}
+	want := []string{"tx0003::true", "tx0002:2:false", "tx0001:1:false"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("history = %v, want %v", got, want)
+	}
+	if s.State("A") != nil {
+		t.Fatal("deleted key still in state")
+	}
+}
+
+func TestSeed(t *testing.T) {
+	s := NewStub("ch")
+	if err := s.Seed(Admin, put("A", "0")); err != nil {
+		t.Fatal(err)
+	}
+	s.Tx(Creditor, put("A", "1"))
+	history := s.KeyHistory("A")
+	if len(history) != 2 || history[0].TxId != "tx0001" || !history[0].Timestamp.AsTime().Equal(Start) ||
+		history[1].TxId != "seed" || !history[1].Timestamp.AsTime().Before(Start) {
+		t.Fatalf("history = %v", history)
+	}
+}
+
+func TestRangeAndCompositeKeys(t *testing.T) {
+	s := NewStub("ch")
+	s.Tx(Creditor, func(ctx contractapi.TransactionContextInterface) error {
+		stub := ctx.GetStub()
+		for _, k := range []string{"A1", "A2", "A3", "B1"} {
+			stub.PutState(k, []byte("{}"))
+		}
+		for _, attrs := range [][]string{{"L1", "G1"}, {"L1", "G2"}, {"L2", "G3"}} {
+			key, _ := stub.CreateCompositeKey("LOAN", attrs)
+			stub.PutState(key, []byte{0})
+		}
+		return nil
+	})
+	s.Tx(Creditor, func(ctx contractapi.TransactionContextInterface) error {
+		stub := ctx.GetStub()
+		it, _ := stub.GetStateByRange("", "")
+		if got := keys(it); !reflect.DeepEqual(got, []string{"A1", "A2", "A3", "B1"}) {
+			t.Errorf("full range = %q", got)
+		}
+		it, meta, _ := stub.GetStateByRangeWithPagination("A", "B", 2, "")
+		if got := keys(it); len(got) != 2 || meta.Bookmark != "A3" {
+			t.Errorf("first page = %v bookmark %q", got, meta.Bookmark)
+		}
+		it, meta, _ = stub.GetStateByRangeWithPagination("A", "B", 2, meta.Bookmark)
+		if got := keys(it); !reflect.DeepEqual(got, []string{"A3"}) || meta.Bookmark != "" {
+			t.Errorf("last page = %v bookmark %q", got, meta.Bookmark)
+		}
+
+		it, _ = stub.GetStateByPartialCompositeKey("LOAN", []string{"L1"})
+		got := keys(it)
+		if len(got) != 2 {
+			t.Fatalf("partial key matched %q", got)
+		}
+		objectType, attrs, err := stub.SplitCompositeKey(got[1])
+		if err != nil || objectType != "LOAN" || !reflect.DeepEqual(attrs, []string{"L1", "G2"}) {
+			t.Errorf("split = %s %v %v", objectType, attrs, err)
+		}
+		if _, err := stub.CreateCompositeKey("LOAN", []string{"a\x00b"}); err == nil {
+			t.Error("attribute with U+0000 accepted")
+		}
+		return nil
+	})
+}
+
+func TestRichQuery(t *testing.T) {
+	docs := map[string][]byte{
+		"D1": []byte(`{"docType":"doc","loanId":"L1","size":10,"tags":["kyc","pan"],"meta":{"pages":3}}`),
+		"D2": []byte(`{"docType":"doc","loanId":"L1","size":200,"tags":["sanction"]}`),
+		"D3": []byte(`{"docType":"doc","loanId":"L2","size":50,"tags":[]}`),
+		"T1": []byte(`{"docType":"tx","amount":5}`),
+		"X":  []byte(`not json`),
+	}
+	cases := []struct {
+		query string
+		want  []string
+	}{
+		{`{"selector":{"loanId":"L1"}}`, []string{"D1", "D2"}},
+		{`{"selector":{"size":{"$gt":10,"$lte":200}}}`, []string{"D2", "D3"}},
+		{`{"selector":{"$or":[{"loanId":"L2"},{"amount":{"$exists":true}}]}}`, []string{"D3", "T1"}},
+		{`{"selector":{"docType":"doc","$not":{"loanId":"L1"}}}`, []string{"D3"}},
+		{`{"selector":{"loanId":{"$in":["L2","L3"]}}}`, []string{"D3"}},
+		{`{"selector":{"docType":"doc","loanId":{"$nin":["L2"]}}}`, []string{"D1", "D2"}},
+		{`{"selector":{"tags":{"$all":["pan","kyc"]}}}`, []string{"D1"}},
+		{`{"selector":{"tags":{"$elemMatch":{"$eq":"sanction"}}}}`, []string{"D2"}},
+		{`{"selector":{"tags":{"$size":0}}}`, []string{"D3"}},
+		{`{"selector":{"meta.pages":3}}`, []string{"D1"}},
+		{`{"selector":{"loanId":{"$regex":"^L[0-9]$"},"size":{"$ne":10}}}`, []string{"D2", "D3"}},
+		{`{"selector":{"amount":{"$type":"number"}}}`, []string{"T1"}},
+		{`{"selector":{"docType":"doc"},"sort":[{"size":"desc"}]}`, []string{"D2", "D3", "D1"}},
+		{`{"selector":{"docType":"doc"},"sort":[{"size":"asc"}],"skip":1,"limit":1}`, []string{"D3"}},
+	}
+	for _, tc := range cases {
+		kvs, _, err := richQuery(docs, tc.query, 0, "")
+		if err != nil {
+			t.Errorf("%s: %v", tc.query, err)
+			continue
+		}
+		var got []string
+		for _, kv := range kvs {
+			got = append(got, kv.Key)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s matched %v, want %v", tc.query, got, tc.want)
+		}
+	}
+
+	page, bookmark, _ := richQuery(docs, `{"selector":{"docType":"doc"}}`, 2, "")
+	rest, last, _ := richQuery(docs, `{"selector":{"docType":"doc"}}`, 2, bookmark)
+	// Like CouchDB, the last page still returns a bookmark, which yields nothing
+	empty, _, _ := richQuery(docs, `{"selector":{"docType":"doc"}}`, 2, last)
+	if len(page) != 2 || len(rest) != 1 || rest[0].Key != "D3" || len(empty) != 0 {
+		t.Errorf("pages of %d, %d and %d records", len(page), len(rest), len(empty))
+	}
+	if _, _, err := richQuery(docs, `{"selector":{"size":{"$near":1}}}`, 0, ""); err == nil {
+		t.Error("unknown operator accepted")
+	}
+}
+
+func TestPrivateData(t *testing.T) {
+	s := NewStub("ch")
+	if err := s.LoadCollections("../collections_config.json"); err != nil {
+		t.Fatal(err)
+	}
+	form := map[string][]byte{"formc": []byte("secret")}
+	write := func(ctx contractapi.TransactionContextInterface) error {
+		stub := ctx.GetStub()
+		transient, _ := stub.GetTransient()
+		return stub.PutPrivateData("formc_admin_only", "K1", transient["formc"])
+	}
+	if err := s.Tx(Admin, write, WithTransient(form)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Tx(Creditor, write, WithTransient(form)); err == nil || !strings.Contains(err.Error(), "does not have write access") {
+		t.Fatalf("non-member write: %v", err)
+	}
+	s.Tx(Creditor, func(ctx contractapi.TransactionContextInterface) error {
+		if _, err := ctx.GetStub().GetPrivateData("formc_admin_only", "K1"); err == nil {
+			t.Error("non-member read allowed")
+		}
+		if h, err := ctx.GetStub().GetPrivateDataHash("formc_admin_only", "K1"); err != nil || len(h) != 32 {
+			t.Errorf("hash = %x, %v", h, err)
+		}
+		if _, err := ctx.GetStub().GetPrivateData("unknown", "K1"); err == nil {
+			t.Error("undefined collection accepted")
+		}
+		return nil
+	})
+	if string(s.PrivateState("formc_admin_only", "K1")) != "secret" || s.State("K1") != nil {
+		t.Fatal("private data not kept apart from world state")
+	}
+}
+
+func TestIdentities(t *testing.T) {
+	s := NewStub("ch")
+	for _, id := range []*Identity{Creditor, Debtor, Admin} {
+		s.Tx(id, func(ctx contractapi.TransactionContextInterface) error {
+			msp, _ := ctx.GetClientIdentity().GetMSPID()
+			clientID, _ := ctx.GetClientIdentity().GetID()
+			if msp != id.MSPID || clientID != id.ID() {
+				t.Errorf("identity %s/%s, want %s/%s", msp, clientID, id.MSPID, id.ID())
+			}
+			return nil
+		})
+	}
+	if Creditor.ID() == NewIdentity("CreditorMSP", "User1@creditor.iu-network.com").ID() {
+		t.Error("different users share a client ID")
+	}
+}
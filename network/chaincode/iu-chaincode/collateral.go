@@ -0,0 +1,465 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+
+	"iu-chaincode/events"
+)
+
+// Charge statuses, following CERSAI charge creation, modification and satisfaction
+const (
+	ChargeStatusActive    = "ACTIVE"
+	ChargeStatusSatisfied = "SATISFIED"
+)
+
+// Indexes from an asset to its collateral records and from collateral to its charges
+const (
+	assetCollateralIndex  = "ASSET_COLLATERAL"
+	collateralChargeIndex = "COLLATERAL_CHARGE"
+)
+
+// Valuation is one valuation of a collateral asset
+type Valuation struct {
+	Amount     float64   `json:"amount"`
+	Currency   string    `json:"currency"`
+	ValuedOn   string    `json:"valuedOn"`
+	Remarks    string    `json:"remarks"`
+	RecordedBy string    `json:"recordedBy"`
+	RecordedAt time.Time `json:"recordedAt"`
+}
+
+// Collateral represents an asset offered as security for one or more loans
+type Collateral struct {
+	ID              string         `json:"collateralId"`
+	AssetType       string         `json:"assetType"` // PROPERTY, GOLD, VEHICLE, RECEIVABLES, ...
+	AssetIdentifier string         `json:"assetIdentifier"`
+	Description     string         `json:"description"`
+	OwnerID         string         `json:"ownerId"`
+	OwnershipDocs   []DocumentHash `json:"ownershipDocs"`
+	LoanIDs         []string       `json:"loanIds"`
+	Valuations      []Valuation    `json:"valuations"`
+	RegisteredBy    string         `json:"registeredBy"`
+	RegisteredAt    time.Time      `json:"registeredAt"`
+}
+
+// ChargeEvent is a creation, modification or satisfaction of a charge
+type ChargeEvent struct {
+	Type    string    `json:"type"` // CREATION, MODIFICATION, SATISFACTION
+	Amount  float64   `json:"amount"`
+	Remarks string    `json:"remarks"`
+	By      string    `json:"by"`
+	At      time.Time `json:"at"`
+}
+
+// Charge represents a security interest created over collateral for a loan
+type Charge struct {
+	ID           string        `json:"chargeId"`
+	CollateralID string        `json:"collateralId"`
+	LoanID       string        `json:"loanId"`
+	CreditorID   string        `json:"creditorId"`
+	Amount       float64       `json:"amount"`
+	Rank         int           `json:"rank"` // 1 for first charge, 2 for second, ...
+	Status       string        `json:"status"`
+	CreatedBy    string        `json:"createdBy"`
+	Events       []ChargeEvent `json:"events"`
+}
+
+// CollateralCharges groups a collateral record with the charges registered on it
+type CollateralCharges struct {
+	Collateral Collateral `json:"collateral"`
+	Charges    []Charge   `json:"charges"`
+}
+
+// AssetCharges is the result of a pre-sanction search on an asset
+type AssetCharges struct {
+	AssetIdentifier string              `json:"assetIdentifier"`
+	Encumbered      bool                `json:"encumbered"`
+	ActiveCharges   int                 `json:"activeCharges"`
+	Collaterals     []CollateralCharges `json:"collaterals"`
+}
+
+func collateralKey(id string) string {
+	return fmt.Sprintf("COLLATERAL_%s", id)
+}
+
+func chargeKey(id string) string {
+	return fmt.Sprintf("CHARGE_%s", id)
+}
+
+// normalizeAssetIdentifier makes registry lookups insensitive to case, spaces and hyphens
+func normalizeAssetIdentifier(id string) string {
+	return strings.NewReplacer(" ", "", "-", "", "/", "").Replace(strings.ToUpper(strings.TrimSpace(id)))
+}
+
+func putJSON(ctx contractapi.TransactionContextInterface, key string, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return ctx.GetStub().PutState(key, b)
+}
+
+// assertLender checks that the caller is a lender org, naming the refused
+// action in the error
+func assertLender(ctx contractapi.TransactionContextInterface, action string) (string, error) {
+	mspid, err := getMSPID(ctx)
+	if err != nil {
+		return "", err
+	}
+	if mspid != "CreditorMSP" && mspid != "AdminMSP" {
+		return "", fmt.Errorf("only CreditorMSP or AdminMSP can %s", action)
+	}
+	return mspid, nil
+}
+
+// RegisterCollateral records an asset, its first valuation and its ownership documents
+func (s *IUContract) RegisterCollateral(ctx contractapi.TransactionContextInterface, id, assetType, assetIdentifier, description, ownerID string, valuation float64, currency, valuedOn string, ownershipDocIDs []string) error {
+	mspid, err := assertLender(ctx, "manage collateral")
+	if err != nil {
+		return err
+	}
+	if id == "" || assetType == "" || assetIdentifier == "" || ownerID == "" {
+		return fmt.Errorf("id, assetType, assetIdentifier and ownerID are required")
+	}
+	if valuation <= 0 {
+		return fmt.Errorf("valuation must be positive")
+	}
+	// The owner may be the borrower or a third party pledging the asset
+	if err := s.requireParty(ctx, ownerID); err != nil {
+		return err
+	}
+	exists, err := s.TransactionExists(ctx, collateralKey(id))
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("collateral %s already exists", id)
+	}
+
+	docs := []DocumentHash{}
+	for _, docID := range ownershipDocIDs {
+		d, err := s.GetDocument(ctx, docID)
+		if err != nil {
+			return fmt.Errorf("ownership document %s: %v", docID, err)
+		}
+		docs = append(docs, DocumentHash{DocID: d.DocID, Role: "OWNERSHIP", Type: d.Type, Hash: d.Hash})
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	identifier := normalizeAssetIdentifier(assetIdentifier)
+	collateral := Collateral{
+		ID:              id,
+		AssetType:       strings.ToUpper(assetType),
+		AssetIdentifier: identifier,
+		Description:     description,
+		OwnerID:         ownerID,
+		OwnershipDocs:   docs,
+		LoanIDs:         []string{},
+		Valuations: []Valuation{{
+			Amount:     valuation,
+			Currency:   currency,
+			ValuedOn:   valuedOn,
+			Remarks:    "Valuation at registration",
+			RecordedBy: mspid,
+			RecordedAt: now,
+		}},
+		RegisteredBy: mspid,
+		RegisteredAt: now,
+	}
+	if err := putJSON(ctx, collateralKey(id), collateral); err != nil {
+		return err
+	}
+	indexKey, err := ctx.GetStub().CreateCompositeKey(assetCollateralIndex, []string{identifier, id})
+	if err != nil {
+		return err
+	}
+	if err := ctx.GetStub().PutState(indexKey, []byte{0x00}); err != nil {
+		return err
+	}
+
+	if err := writeAuditRecord(ctx, id, "REGISTER_COLLATERAL", mspid,
+		fmt.Sprintf("%s %s owned by %s valued at %f %s", collateral.AssetType, identifier, ownerID, valuation, currency), "REGISTERED", now); err != nil {
+		return err
+	}
+	return emitEvent(ctx, events.CollateralRegistered, collateralKey(id), collateral)
+}
+
+// GetCollateral returns the collateral with given id
+func (s *IUContract) GetCollateral(ctx contractapi.TransactionContextInterface, id string) (*Collateral, error) {
+	val, err := ctx.GetStub().GetState(collateralKey(id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if val == nil {
+		return nil, fmt.Errorf("collateral %s does not exist", id)
+	}
+	var collateral Collateral
+	if err := json.Unmarshal(val, &collateral); err != nil {
+		return nil, err
+	}
+	return &collateral, nil
+}
+
+// RevalueCollateral appends a periodic revaluation to the collateral
+func (s *IUContract) RevalueCollateral(ctx contractapi.TransactionContextInterface, id string, valuation float64, currency, valuedOn, remarks string) error {
+	mspid, err := assertLender(ctx, "manage collateral")
+	if err != nil {
+		return err
+	}
+	if valuation <= 0 {
+		return fmt.Errorf("valuation must be positive")
+	}
+	collateral, err := s.GetCollateral(ctx, id)
+	if err != nil {
+		return err
+	}
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	collateral.Valuations = append(collateral.Valuations, Valuation{
+		Amount:     valuation,
+		Currency:   currency,
+		ValuedOn:   valuedOn,
+		Remarks:    remarks,
+		RecordedBy: mspid,
+		RecordedAt: now,
+	})
+	if err := putJSON(ctx, collateralKey(id), collateral); err != nil {
+		return err
+	}
+
+	if err := writeAuditRecord(ctx, id, "REVALUE_COLLATERAL", mspid,
+		fmt.Sprintf("Revalued at %f %s on %s", valuation, currency, valuedOn), "REVALUED", now); err != nil {
+		return err
+	}
+	return emitEvent(ctx, events.CollateralRevalued, collateralKey(id), collateral)
+}
+
+// CreateCharge registers a security interest over collateral for a loan
+func (s *IUContract) CreateCharge(ctx contractapi.TransactionContextInterface, chargeID, collateralID, loanID, creditorID string, amount float64, remarks string) error {
+	mspid, err := assertLender(ctx, "manage collateral")
+	if err != nil {
+		return err
+	}
+	if chargeID == "" || loanID == "" || creditorID == "" {
+		return fmt.Errorf("chargeID, loanID and creditorID are required")
+	}
+	if amount <= 0 {
+		return fmt.Errorf("charge amount must be positive")
+	}
+	if err := s.requireCreditor(ctx, creditorID, mspid); err != nil {
+		return err
+	}
+	exists, err := s.TransactionExists(ctx, chargeKey(chargeID))
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("charge %s already exists", chargeID)
+	}
+	collateral, err := s.GetCollateral(ctx, collateralID)
+	if err != nil {
+		return err
+	}
+	existing, err := s.chargesOnCollateral(ctx, collateralID)
+	if err != nil {
+		return err
+	}
+	// A new charge ranks after every active one; satisfied charges leave
+	// gaps rather than promoting the charges behind them
+	rank := 1
+	for _, c := range existing {
+		if c.Status == ChargeStatusActive && c.Rank >= rank {
+			rank = c.Rank + 1
+		}
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	charge := Charge{
+		ID:           chargeID,
+		CollateralID: collateralID,
+		LoanID:       loanID,
+		CreditorID:   creditorID,
+		Amount:       amount,
+		Rank:         rank,
+		Status:       ChargeStatusActive,
+		CreatedBy:    mspid,
+		Events:       []ChargeEvent{{Type: "CREATION", Amount: amount, Remarks: remarks, By: mspid, At: now}},
+	}
+	if err := putJSON(ctx, chargeKey(chargeID), charge); err != nil {
+		return err
+	}
+	indexKey, err := ctx.GetStub().CreateCompositeKey(collateralChargeIndex, []string{collateralID, chargeID})
+	if err != nil {
+		return err
+	}
+	if err := ctx.GetStub().PutState(indexKey, []byte{0x00}); err != nil {
+		return err
+	}
+
+	linked := false
+	for _, id := range collateral.LoanIDs {
+		if id == loanID {
+			linked = true
+		}
+	}
+	if !linked {
+		collateral.LoanIDs = append(collateral.LoanIDs, loanID)
+		if err := putJSON(ctx, collateralKey(collateralID), collateral); err != nil {
+			return err
+		}
+	}
+
+	if err := writeAuditRecord(ctx, chargeID, "CREATE_CHARGE", mspid,
+		fmt.Sprintf("Charge of rank %d for %f on %s in favour of %s for loan %s", rank, amount, collateralID, creditorID, loanID), ChargeStatusActive, now); err != nil {
+		return err
+	}
+	return emitEvent(ctx, events.ChargeCreated, chargeKey(chargeID), charge)
+}
+
+// GetCharge returns the charge with given id
+func (s *IUContract) GetCharge(ctx contractapi.TransactionContextInterface, id string) (*Charge, error) {
+	val, err := ctx.GetStub().GetState(chargeKey(id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if val == nil {
+		return nil, fmt.Errorf("charge %s does not exist", id)
+	}
+	var charge Charge
+	if err := json.Unmarshal(val, &charge); err != nil {
+		return nil, err
+	}
+	return &charge, nil
+}
+
+func (s *IUContract) updateCharge(ctx contractapi.TransactionContextInterface, chargeID, eventType string, amount float64, remarks string) error {
+	mspid, err := assertLender(ctx, "manage collateral")
+	if err != nil {
+		return err
+	}
+	charge, err := s.GetCharge(ctx, chargeID)
+	if err != nil {
+		return err
+	}
+	if charge.Status != ChargeStatusActive {
+		return fmt.Errorf("charge %s is %s", chargeID, charge.Status)
+	}
+	if mspid != charge.CreatedBy && mspid != "AdminMSP" {
+		return fmt.Errorf("only the charge holder or AdminMSP can update charge %s", chargeID)
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	chargeEvent := events.ChargeModified
+	if eventType == "SATISFACTION" {
+		charge.Status = ChargeStatusSatisfied
+		chargeEvent = events.ChargeSatisfied
+	} else {
+		charge.Amount = amount
+	}
+	charge.Events = append(charge.Events, ChargeEvent{Type: eventType, Amount: amount, Remarks: remarks, By: mspid, At: now})
+	if err := putJSON(ctx, chargeKey(chargeID), charge); err != nil {
+		return err
+	}
+
+	if err := writeAuditRecord(ctx, chargeID, eventType+"_CHARGE", mspid,
+		fmt.Sprintf("Charge %s %s: %f %s", chargeID, strings.ToLower(eventType), amount, remarks), charge.Status, now); err != nil {
+		return err
+	}
+	return emitEvent(ctx, chargeEvent, chargeKey(chargeID), charge)
+}
+
+// ModifyCharge changes the amount secured by an active charge
+func (s *IUContract) ModifyCharge(ctx contractapi.TransactionContextInterface, chargeID string, amount float64, remarks string) error {
+	if amount <= 0 {
+		return fmt.Errorf("charge amount must be positive")
+	}
+	return s.updateCharge(ctx, chargeID, "MODIFICATION", amount, remarks)
+}
+
+// SatisfyCharge releases a charge once the secured loan is repaid
+func (s *IUContract) SatisfyCharge(ctx contractapi.TransactionContextInterface, chargeID, remarks string) error {
+	return s.updateCharge(ctx, chargeID, "SATISFACTION", 0, remarks)
+}
+
+func (s *IUContract) chargesOnCollateral(ctx contractapi.TransactionContextInterface, collateralID string) ([]Charge, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(collateralChargeIndex, []string{collateralID})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	charges := []Charge{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		_, attrs, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil {
+			return nil, err
+		}
+		charge, err := s.GetCharge(ctx, attrs[1])
+		if err != nil {
+			return nil, err
+		}
+		charges = append(charges, *charge)
+	}
+	return charges, nil
+}
+
+// FindChargesOnAsset lets a lender check whether an asset is already pledged before sanctioning
+func (s *IUContract) FindChargesOnAsset(ctx contractapi.TransactionContextInterface, assetIdentifier string) (*AssetCharges, error) {
+	identifier := normalizeAssetIdentifier(assetIdentifier)
+	if identifier == "" {
+		return nil, fmt.Errorf("assetIdentifier is required")
+	}
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(assetCollateralIndex, []string{identifier})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	result := &AssetCharges{AssetIdentifier: identifier, Collaterals: []CollateralCharges{}}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		_, attrs, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil {
+			return nil, err
+		}
+		collateral, err := s.GetCollateral(ctx, attrs[1])
+		if err != nil {
+			return nil, err
+		}
+		charges, err := s.chargesOnCollateral(ctx, collateral.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range charges {
+			if c.Status == ChargeStatusActive {
+				result.ActiveCharges++
+			}
+		}
+		result.Collaterals = append(result.Collaterals, CollateralCharges{Collateral: *collateral, Charges: charges})
+	}
+	result.Encumbered = result.ActiveCharges > 0
+	return result, nil
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+
+	"iu-chaincode/chaincodetest"
+	"iu-chaincode/events"
+)
+
+func registerCollateral(id, assetIdentifier string, docIDs ...string) ctxFunc {
+	return func(ctx contractapi.TransactionContextInterface) error {
+		return contract.RegisterCollateral(ctx, id, "property", assetIdentifier, "Flat 4B, Andheri East", "D1", 9500000, "INR", "2024-03-15", docIDs)
+	}
+}
+
+func createCharge(chargeID, collateralID, loanID string, amount float64) ctxFunc {
+	return func(ctx contractapi.TransactionContextInterface) error {
+		return contract.CreateCharge(ctx, chargeID, collateralID, loanID, "C1", amount, "mortgage")
+	}
+}
+
+func chargeState(t *testing.T, stub *chaincodetest.Stub, id string) Charge {
+	t.Helper()
+	var charge Charge
+	readState(t, stub, chargeKey(id), &charge)
+	return charge
+}
+
+func TestRegisterCollateral(t *testing.T) {
+	setup := func(t *testing.T, stub *chaincodetest.Stub) {
+		mustSubmit(t, stub, chaincodetest.Debtor, submitDoc("L1", "TITLE1"))
+		mustSubmit(t, stub, chaincodetest.Creditor, registerCollateral("COL1", "MH-02-SURVEY-114/2"))
+	}
+	runCases(t, setup, []txCase{
+		{name: "creditor registers", id: chaincodetest.Creditor, call: registerCollateral("COL2", "mh 02 survey 200", "TITLE1"), event: events.CollateralRegistered,
+			check: func(t *testing.T, stub *chaincodetest.Stub) {
+				var c Collateral
+				readState(t, stub, collateralKey("COL2"), &c)
+				if c.AssetIdentifier != "MH02SURVEY200" || c.AssetType != "PROPERTY" || len(c.OwnershipDocs) != 1 || len(c.Valuations) != 1 {
+					t.Fatalf("unexpected collateral %+v", c)
+				}
+			}},
+		{name: "debtor cannot register", id: chaincodetest.Debtor, call: registerCollateral("COL2", "X1"), wantErr: "only CreditorMSP or AdminMSP can manage collateral"},
+		{name: "duplicate", id: chaincodetest.Creditor, call: registerCollateral("COL1", "X1"), wantErr: "already exists"},
+		{name: "unknown ownership document", id: chaincodetest.Creditor, call: registerCollateral("COL2", "X1", "DOC9"), wantErr: "ownership document DOC9"},
+		{name: "third-party owner", id: chaincodetest.Creditor, call: func(ctx contractapi.TransactionContextInterface) error {
+			return contract.RegisterCollateral(ctx, "COL2", "vehicle", "MH-01-AB-1234", "", "P1", 800000, "INR", "2024-03-15", nil)
+		}},
+		{name: "unregistered owner", id: chaincodetest.Creditor, wantErr: "party X9 is not a registered borrower or institution", call: func(ctx contractapi.TransactionContextInterface) error {
+			return contract.RegisterCollateral(ctx, "COL2", "vehicle", "MH-01-AB-1234", "", "X9", 800000, "INR", "2024-03-15", nil)
+		}},
+		{name: "non-positive valuation", id: chaincodetest.Creditor, wantErr: "valuation must be positive", call: func(ctx contractapi.TransactionContextInterface) error {
+			return contract.RegisterCollateral(ctx, "COL2", "GOLD", "G1", "", "D1", 0, "INR", "2024-03-15", nil)
+		}},
+		{name: "GetCollateral", id: chaincodetest.Debtor, call: func(ctx contractapi.TransactionContextInterface) error {
+			c, err := contract.GetCollateral(ctx, "COL1")
+			if err == nil && c.AssetIdentifier != "MH02SURVEY1142" {
+				t.Errorf("assetIdentifier = %s", c.AssetIdentifier)
+			}
+			return err
+		}},
+		{name: "GetCollateral missing", id: chaincodetest.Debtor, wantErr: "does not exist", call: func(ctx contractapi.TransactionContextInterface) error {
+			_, err := contract.GetCollateral(ctx, "COL9")
+			return err
+		}},
+	})
+}
+
+func TestRevalueCollateral(t *testing.T) {
+	setup := func(t *testing.T, stub *chaincodetest.Stub) {
+		mustSubmit(t, stub, chaincodetest.Creditor, registerCollateral("COL1", "P1"))
+	}
+	revalue := func(id string, amount float64) ctxFunc {
+		return func(ctx contractapi.TransactionContextInterface) error {
+			return contract.RevalueCollateral(ctx, id, amount, "INR", "2025-03-15", "annual revaluation")
+		}
+	}
+	runCases(t, setup, []txCase{
+		{name: "admin revalues", id: chaincodetest.Admin, call: revalue("COL1", 9000000), event: events.CollateralRevalued,
+			check: func(t *testing.T, stub *chaincodetest.Stub) {
+				var c Collateral
+				readState(t, stub, collateralKey("COL1"), &c)
+				if len(c.Valuations) != 2 || c.Valuations[1].Amount != 9000000 || c.Valuations[1].RecordedBy != "AdminMSP" {
+					t.Fatalf("valuations = %+v", c.Valuations)
+				}
+			}},
+		{name: "debtor cannot revalue", id: chaincodetest.Debtor, call: revalue("COL1", 1), wantErr: "only CreditorMSP or AdminMSP"},
+		{name: "non-positive valuation", id: chaincodetest.Creditor, call: revalue("COL1", -1), wantErr: "must be positive"},
+		{name: "missing", id: chaincodetest.Creditor, call: revalue("COL9", 1), wantErr: "does not exist"},
+	})
+}
+
+func TestCharges(t *testing.T) {
+	setup := func(t *testing.T, stub *chaincodetest.Stub) {
+		mustSubmit(t, stub, chaincodetest.Creditor, registerCollateral("COL1", "P1"))
+		mustSubmit(t, stub, chaincodetest.Creditor, createCharge("CH1", "COL1", "L1", 5000000))
+	}
+	modify := func(id string, amount float64) ctxFunc {
+		return func(ctx contractapi.TransactionContextInterface) error {
+			return contract.ModifyCharge(ctx, id, amount, "top-up")
+		}
+	}
+	chargeFor := func(creditorID string) ctxFunc {
+		return func(ctx contractapi.TransactionContextInterface) error {
+			return contract.CreateCharge(ctx, "CH2", "COL1", "L2", creditorID, 1, "mortgage")
+		}
+	}
+	satisfy := func(id string) ctxFunc {
+		return func(ctx contractapi.TransactionContextInterface) error {
+			return contract.SatisfyCharge(ctx, id, "loan closed")
+		}
+	}
+	runCases(t, setup, []txCase{
+		{name: "second charge ranks second", id: chaincodetest.Admin, call: createCharge("CH2", "COL1", "L2", 2000000), event: events.ChargeCreated,
+			check: func(t *testing.T, stub *chaincodetest.Stub) {
+				if charge := chargeState(t, stub, "CH2"); charge.Rank != 2 || charge.Status != ChargeStatusActive {
+					t.Fatalf("unexpected charge %+v", charge)
+				}
+				var c Collateral
+				readState(t, stub, collateralKey("COL1"), &c)
+				if len(c.LoanIDs) != 2 {
+					t.Fatalf("loanIds = %v", c.LoanIDs)
+				}
+			}},
+		{name: "satisfied charges do not rank", id: chaincodetest.Creditor, call: createCharge("CH2", "COL1", "L2", 1),
+			setup: func(t *testing.T, stub *chaincodetest.Stub) {
+				mustSubmit(t, stub, chaincodetest.Creditor, satisfy("CH1"))
+			},
+			check: func(t *testing.T, stub *chaincodetest.Stub) {
+				if rank := chargeState(t, stub, "CH2").Rank; rank != 1 {
+					t.Fatalf("rank = %d, want 1", rank)
+				}
+			}},
+		{name: "ranks after the lowest active charge", id: chaincodetest.Creditor, call: createCharge("CH3", "COL1", "L3", 1),
+			setup: func(t *testing.T, stub *chaincodetest.Stub) {
+				mustSubmit(t, stub, chaincodetest.Creditor, createCharge("CH2", "COL1", "L2", 1))
+				mustSubmit(t, stub, chaincodetest.Creditor, satisfy("CH1"))
+			},
+			check: func(t *testing.T, stub *chaincodetest.Stub) {
+				if rank := chargeState(t, stub, "CH3").Rank; rank != 3 {
+					t.Fatalf("rank = %d, want 3", rank)
+				}
+			}},
+		{name: "duplicate", id: chaincodetest.Creditor, call: createCharge("CH1", "COL1", "L1", 1), wantErr: "already exists"},
+		{name: "unknown collateral", id: chaincodetest.Creditor, call: createCharge("CH2", "COL9", "L1", 1), wantErr: "does not exist"},
+		{name: "debtor cannot charge", id: chaincodetest.Debtor, call: createCharge("CH2", "COL1", "L1", 1), wantErr: "only CreditorMSP or AdminMSP"},
+		{name: "unregistered creditor", id: chaincodetest.Creditor, call: chargeFor("C9"), wantErr: "creditor C9 is not a registered institution"},
+		{name: "creditor of another MSP", id: chaincodetest.Creditor, call: chargeFor("OTHER"),
+			setup: admin(registerInstitution("OTHER", "AAACO1234D", "OtherMSP")), wantErr: "creditor OTHER is bound to OtherMSP, not CreditorMSP"},
+		{name: "suspended creditor", id: chaincodetest.Creditor, call: chargeFor("C2"), setup: admin(suspendInstitution("C2")), wantErr: "creditor C2 is SUSPENDED"},
+		{name: "holder modifies", id: chaincodetest.Creditor, call: modify("CH1", 6000000), event: events.ChargeModified,
+			check: func(t *testing.T, stub *chaincodetest.Stub) {
+				if charge := chargeState(t, stub, "CH1"); charge.Amount != 6000000 || len(charge.Events) != 2 {
+					t.Fatalf("unexpected charge %+v", charge)
+				}
+			}},
+		{name: "non-positive modification", id: chaincodetest.Creditor, call: modify("CH1", 0), wantErr: "must be positive"},
+		{name: "admin satisfies", id: chaincodetest.Admin, call: satisfy("CH1"), event: events.ChargeSatisfied,
+			check: func(t *testing.T, stub *chaincodetest.Stub) {
+				if status := chargeState(t, stub, "CH1").Status; status != ChargeStatusSatisfied {
+					t.Fatalf("status = %s", status)
+				}
+			}},
+		{name: "only the holder", id: chaincodetest.Creditor, call: satisfy("CH2"), wantErr: "only the charge holder or AdminMSP",
+			setup: func(t *testing.T, stub *chaincodetest.Stub) {
+				mustSubmit(t, stub, chaincodetest.Admin, createCharge("CH2", "COL1", "L2", 1))
+			}},
+		{name: "already satisfied", id: chaincodetest.Creditor, call: modify("CH1", 1), wantErr: "charge CH1 is SATISFIED",
+			setup: func(t *testing.T, stub *chaincodetest.Stub) {
+				mustSubmit(t, stub, chaincodetest.Creditor, satisfy("CH1"))
+			}},
+		{name: "GetCharge", id: chaincodetest.Debtor, call: func(ctx contractapi.TransactionContextInterface) error {
+			charge, err := contract.GetCharge(ctx, "CH1")
+			if err == nil && charge.Rank != 1 {
+				t.Errorf("rank = %d", charge.Rank)
+			}
+			return err
+		}},
+		{name: "GetCharge missing", id: chaincodetest.Debtor, wantErr: "does not exist", call: func(ctx contractapi.TransactionContextInterface) error {
+			_, err := contract.GetCharge(ctx, "CH9")
+			return err
+		}},
+	})
+}
+
+func TestFindChargesOnAsset(t *testing.T) {
+	setup := func(t *testing.T, stub *chaincodetest.Stub) {
+		mustSubmit(t, stub, chaincodetest.Creditor, registerCollateral("COL1", "MH-02/114"))
+		mustSubmit(t, stub, chaincodetest.Creditor, registerCollateral("COL2", "mh02 114"))
+		mustSubmit(t, stub, chaincodetest.Creditor, createCharge("CH1", "COL1", "L1", 1))
+		mustSubmit(t, stub, chaincodetest.Creditor, createCharge("CH2", "COL2", "L2", 1))
+		mustSubmit(t, stub, chaincodetest.Creditor, func(ctx contractapi.TransactionContextInterface) error {
+			return contract.SatisfyCharge(ctx, "CH2", "")
+		})
+	}
+	find := func(identifier string, collaterals, active int) ctxFunc {
+		return func(ctx contractapi.TransactionContextInterface) error {
+			result, err := contract.FindChargesOnAsset(ctx, identifier)
+			if err == nil && (len(result.Collaterals) != collaterals || result.ActiveCharges != active || result.Encumbered != (active > 0)) {
+				t.Errorf("unexpected result %+v", result)
+			}
+			return err
+		}
+	}
+	runCases(t, setup, []txCase{
+		{name: "normalised match", id: chaincodetest.Creditor, call: find("mh-02-114", 2, 1)},
+		{name: "unencumbered", id: chaincodetest.Creditor, call: find("KA01", 0, 0)},
+		{name: "identifier required", id: chaincodetest.Creditor, call: find(" - ", 0, 0), wantErr: "assetIdentifier is required"},
+	})
+}
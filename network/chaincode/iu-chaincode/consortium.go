@@ -0,0 +1,363 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+
+	"iu-chaincode/events"
+)
+
+// Events allocated pro-rata across a consortium
+const (
+	AllocationRepayment = "REPAYMENT"
+	AllocationDefault   = "DEFAULT"
+	AllocationClaim     = "CLAIM"
+)
+
+const consortiumAllocationIndex = "CONSORTIUM_ALLOCATION"
+
+var allocationEvents = map[string]string{
+	AllocationRepayment: events.ConsortiumRepaymentRecorded,
+	AllocationDefault:   events.ConsortiumDefaultAllocated,
+	AllocationClaim:     events.ConsortiumClaimAllocated,
+}
+
+// Participant is a member bank of a consortium with its share of the loan
+type Participant struct {
+	CreditorID   string  `json:"creditorId"`
+	MSPID        string  `json:"mspId"`
+	SharePercent float64 `json:"sharePercent"`
+}
+
+// ConsortiumLoan is a loan syndicated across several banks under a lead bank
+type ConsortiumLoan struct {
+	LoanID           string        `json:"loanId"`
+	DebtorID         string        `json:"debtorId"`
+	LeadCreditorID   string        `json:"leadCreditorId"`
+	Participants     []Participant `json:"participants"`
+	SanctionedAmount float64       `json:"sanctionedAmount"`
+	Outstanding      float64       `json:"outstanding"`
+	Currency         string        `json:"currency"`
+	Status           string        `json:"status"` // ACTIVE, CLOSED
+	CreatedBy        string        `json:"createdBy"`
+	CreatedAt        time.Time     `json:"createdAt"`
+	LastUpdated      time.Time     `json:"lastUpdated"`
+}
+
+// ParticipantAllocation is one participant's part of an allocated amount
+type ParticipantAllocation struct {
+	CreditorID string  `json:"creditorId"`
+	Amount     float64 `json:"amount"`
+}
+
+// ConsortiumAllocation splits a repayment, default or claim across participants
+type ConsortiumAllocation struct {
+	LoanID      string                  `json:"loanId"`
+	EventID     string                  `json:"eventId"`
+	EventType   string                  `json:"eventType"` // REPAYMENT, DEFAULT, CLAIM
+	Amount      float64                 `json:"amount"`
+	Allocations []ParticipantAllocation `json:"allocations"`
+	RecordedBy  string                  `json:"recordedBy"`
+	RecordedAt  time.Time               `json:"recordedAt"`
+}
+
+func consortiumKey(loanID string) string {
+	return fmt.Sprintf("CONSORTIUM_%s", loanID)
+}
+
+func consortiumEndorsers(loan *ConsortiumLoan) []string {
+	msps := []string{"AdminMSP"}
+	for _, p := range loan.Participants {
+		msps = append(msps, p.MSPID)
+	}
+	return msps
+}
+
+// CreateConsortiumLoan records a syndicated loan; shares must add up to 100 percent
+// and each participant's mspId must be the org its institution is bound to.
+// Every participant org and AdminMSP must then endorse changes to the loan record.
+func (s *IUContract) CreateConsortiumLoan(ctx contractapi.TransactionContextInterface, loanID, debtorID, leadCreditorID string, sanctionedAmount float64, currency string, participants []Participant) error {
+	mspid, err := assertLender(ctx, "create a consortium loan")
+	if err != nil {
+		return err
+	}
+	if loanID == "" || debtorID == "" || leadCreditorID == "" {
+		return fmt.Errorf("loanID, debtorID and leadCreditorID are required")
+	}
+	if sanctionedAmount <= 0 {
+		return fmt.Errorf("sanctioned amount must be positive")
+	}
+	if len(participants) < 2 {
+		return fmt.Errorf("a consortium needs at least two participants")
+	}
+	if err := s.requireCreditor(ctx, leadCreditorID, mspid); err != nil {
+		return err
+	}
+	if err := s.requireDebtor(ctx, debtorID, true); err != nil {
+		return err
+	}
+	total := 0.0
+	seen := map[string]bool{}
+	leadFound := false
+	for _, p := range participants {
+		if p.CreditorID == "" || p.MSPID == "" {
+			return fmt.Errorf("each participant needs creditorId and mspId")
+		}
+		if p.SharePercent <= 0 {
+			return fmt.Errorf("share of %s must be positive", p.CreditorID)
+		}
+		if seen[p.CreditorID] {
+			return fmt.Errorf("participant %s listed twice", p.CreditorID)
+		}
+		institution, err := s.GetInstitution(ctx, p.CreditorID)
+		if err != nil {
+			return fmt.Errorf("participant %s is not a registered institution", p.CreditorID)
+		}
+		if institution.MSPID != p.MSPID {
+			return fmt.Errorf("participant %s is bound to %s, not %s", p.CreditorID, institution.MSPID, p.MSPID)
+		}
+		if institution.Status != PartyStatusActive {
+			return fmt.Errorf("participant %s is %s", p.CreditorID, institution.Status)
+		}
+		seen[p.CreditorID] = true
+		if p.CreditorID == leadCreditorID {
+			leadFound = true
+		}
+		total += p.SharePercent
+	}
+	if !leadFound {
+		return fmt.Errorf("lead creditor %s must be a participant", leadCreditorID)
+	}
+	if math.Abs(total-100) > 0.0001 {
+		return fmt.Errorf("participant shares add up to %f, not 100", total)
+	}
+
+	key := consortiumKey(loanID)
+	exists, err := s.TransactionExists(ctx, key)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("consortium loan %s already exists", loanID)
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	loan := ConsortiumLoan{
+		LoanID:           loanID,
+		DebtorID:         debtorID,
+		LeadCreditorID:   leadCreditorID,
+		Participants:     participants,
+		SanctionedAmount: sanctionedAmount,
+		Outstanding:      sanctionedAmount,
+		Currency:         currency,
+		Status:           "ACTIVE",
+		CreatedBy:        mspid,
+		CreatedAt:        now,
+		LastUpdated:      now,
+	}
+	if err := putJSON(ctx, key, loan); err != nil {
+		return err
+	}
+	if err := setKeyEndorsers(ctx, key, consortiumEndorsers(&loan)...); err != nil {
+		return err
+	}
+
+	if err := writeAuditRecord(ctx, loanID, "CREATE_CONSORTIUM_LOAN", mspid,
+		fmt.Sprintf("Consortium loan of %f %s to %s led by %s with %d participants", sanctionedAmount, currency, debtorID, leadCreditorID, len(participants)),
+		"ACTIVE", now); err != nil {
+		return err
+	}
+	return emitEvent(ctx, events.ConsortiumLoanCreated, key, loan)
+}
+
+// GetConsortiumLoan returns the consortium loan with given id
+func (s *IUContract) GetConsortiumLoan(ctx contractapi.TransactionContextInterface, loanID string) (*ConsortiumLoan, error) {
+	val, err := ctx.GetStub().GetState(consortiumKey(loanID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if val == nil {
+		return nil, fmt.Errorf("consortium loan %s does not exist", loanID)
+	}
+	var loan ConsortiumLoan
+	if err := json.Unmarshal(val, &loan); err != nil {
+		return nil, err
+	}
+	return &loan, nil
+}
+
+// allocateProRata splits amount by share, rounding to paise and giving any
+// rounding difference to the lead bank so the parts always sum to amount
+func allocateProRata(loan *ConsortiumLoan, amount float64) []ParticipantAllocation {
+	allocations := make([]ParticipantAllocation, 0, len(loan.Participants))
+	allocated := 0.0
+	lead := 0
+	for i, p := range loan.Participants {
+		part := math.Round(amount*p.SharePercent) / 100
+		allocations = append(allocations, ParticipantAllocation{CreditorID: p.CreditorID, Amount: part})
+		allocated += part
+		if p.CreditorID == loan.LeadCreditorID {
+			lead = i
+		}
+	}
+	allocations[lead].Amount = math.Round((allocations[lead].Amount+amount-allocated)*100) / 100
+	return allocations
+}
+
+func (s *IUContract) recordAllocation(ctx contractapi.TransactionContextInterface, loan *ConsortiumLoan, eventID, eventType string, amount float64) (*ConsortiumAllocation, error) {
+	mspid, err := assertLender(ctx, "allocate across a consortium")
+	if err != nil {
+		return nil, err
+	}
+	if eventID == "" {
+		return nil, fmt.Errorf("eventID is required")
+	}
+	if amount <= 0 {
+		return nil, fmt.Errorf("amount must be positive")
+	}
+	key, err := ctx.GetStub().CreateCompositeKey(consortiumAllocationIndex, []string{loan.LoanID, eventType, eventID})
+	if err != nil {
+		return nil, err
+	}
+	existing, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if existing != nil {
+		return nil, fmt.Errorf("%s %s already allocated on loan %s", eventType, eventID, loan.LoanID)
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	allocation := &ConsortiumAllocation{
+		LoanID:      loan.LoanID,
+		EventID:     eventID,
+		EventType:   eventType,
+		Amount:      amount,
+		Allocations: allocateProRata(loan, amount),
+		RecordedBy:  mspid,
+		RecordedAt:  now,
+	}
+	if err := putJSON(ctx, key, allocation); err != nil {
+		return nil, err
+	}
+	if err := setKeyEndorsers(ctx, key, consortiumEndorsers(loan)...); err != nil {
+		return nil, err
+	}
+	if err := writeAuditRecord(ctx, loan.LoanID, "ALLOCATE_"+eventType, mspid,
+		fmt.Sprintf("%s %s of %f %s allocated across %d participants", eventType, eventID, amount, loan.Currency, len(loan.Participants)),
+		"ALLOCATED", now); err != nil {
+		return nil, err
+	}
+	if err := emitEvent(ctx, allocationEvents[eventType], key, allocation); err != nil {
+		return nil, err
+	}
+	return allocation, nil
+}
+
+// RecordConsortiumRepayment allocates a repayment to participants and reduces the outstanding amount
+func (s *IUContract) RecordConsortiumRepayment(ctx contractapi.TransactionContextInterface, loanID, paymentID string, amount float64) (*ConsortiumAllocation, error) {
+	loan, err := s.GetConsortiumLoan(ctx, loanID)
+	if err != nil {
+		return nil, err
+	}
+	if loan.Status != "ACTIVE" {
+		return nil, fmt.Errorf("consortium loan %s is %s", loanID, loan.Status)
+	}
+	if amount > loan.Outstanding+0.005 {
+		return nil, fmt.Errorf("repayment %f exceeds outstanding %f", amount, loan.Outstanding)
+	}
+	allocation, err := s.recordAllocation(ctx, loan, paymentID, AllocationRepayment, amount)
+	if err != nil {
+		return nil, err
+	}
+
+	loan.Outstanding = math.Max(math.Round((loan.Outstanding-amount)*100)/100, 0)
+	if loan.Outstanding == 0 {
+		loan.Status = "CLOSED"
+	}
+	loan.LastUpdated = allocation.RecordedAt
+	if err := putJSON(ctx, consortiumKey(loanID), loan); err != nil {
+		return nil, err
+	}
+	return allocation, nil
+}
+
+// AllocateConsortiumDefault splits a default filed on the consortium loan across participants
+func (s *IUContract) AllocateConsortiumDefault(ctx contractapi.TransactionContextInterface, loanID, defaultID string) (*ConsortiumAllocation, error) {
+	loan, err := s.GetConsortiumLoan(ctx, loanID)
+	if err != nil {
+		return nil, err
+	}
+	record, err := s.GetDefault(ctx, defaultID)
+	if err != nil {
+		return nil, err
+	}
+	if record.LoanID != loanID {
+		return nil, fmt.Errorf("default %s is filed on loan %s, not %s", defaultID, record.LoanID, loanID)
+	}
+	return s.recordAllocation(ctx, loan, defaultID, AllocationDefault, record.Amount)
+}
+
+// AllocateConsortiumClaim splits the consortium's claim in an insolvency case
+// across participants. The claim is the total admitted to the participants in
+// the case's claims register.
+func (s *IUContract) AllocateConsortiumClaim(ctx contractapi.TransactionContextInterface, loanID, caseID string) (*ConsortiumAllocation, error) {
+	loan, err := s.GetConsortiumLoan(ctx, loanID)
+	if err != nil {
+		return nil, err
+	}
+	register, err := s.GetClaimsRegister(ctx, caseID)
+	if err != nil {
+		return nil, err
+	}
+	if register.Case.DebtorID != loan.DebtorID {
+		return nil, fmt.Errorf("insolvency case %s is against %s, not %s", caseID, register.Case.DebtorID, loan.DebtorID)
+	}
+	participants := map[string]bool{}
+	for _, p := range loan.Participants {
+		participants[p.CreditorID] = true
+	}
+	claimAmount := 0.0
+	for _, claim := range register.Claims {
+		if participants[claim.CreditorID] && claim.Status == ClaimStatusAdmitted {
+			claimAmount += claim.AdmittedAmount
+		}
+	}
+	if claimAmount == 0 {
+		return nil, fmt.Errorf("no claim by a participant of consortium loan %s is admitted in case %s", loanID, caseID)
+	}
+	return s.recordAllocation(ctx, loan, caseID, AllocationClaim, claimAmount)
+}
+
+// GetConsortiumAllocations lists every allocation recorded on a consortium loan
+func (s *IUContract) GetConsortiumAllocations(ctx contractapi.TransactionContextInterface, loanID string) ([]ConsortiumAllocation, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(consortiumAllocationIndex, []string{loanID})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	allocations := []ConsortiumAllocation{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		var allocation ConsortiumAllocation
+		if err := json.Unmarshal(queryResponse.Value, &allocation); err != nil {
+			return nil, err
+		}
+		allocations = append(allocations, allocation)
+	}
+	return allocations, nil
+}
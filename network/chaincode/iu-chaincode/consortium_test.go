@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+
+	"iu-chaincode/chaincodetest"
+	"iu-chaincode/events"
+)
+
+var syndicate = []Participant{
+	{CreditorID: "SBI", MSPID: "CreditorMSP", SharePercent: 50},
+	{CreditorID: "PNB", MSPID: "CreditorMSP", SharePercent: 30},
+	{CreditorID: "BOB", MSPID: "CreditorMSP", SharePercent: 20},
+}
+
+// registerSyndicate onboards the banks of the syndicate
+func registerSyndicate(t *testing.T, stub *chaincodetest.Stub) {
+	for i, p := range syndicate {
+		mustSubmit(t, stub, chaincodetest.Admin, registerInstitution(p.CreditorID, fmt.Sprintf("AAACS%04dA", i), p.MSPID))
+	}
+}
+
+func createConsortium(loanID string, participants []Participant) ctxFunc {
+	return func(ctx contractapi.TransactionContextInterface) error {
+		return contract.CreateConsortiumLoan(ctx, loanID, "D1", "SBI", 10000000, "INR", participants)
+	}
+}
+
+func repay(loanID, paymentID string, amount float64) ctxFunc {
+	return func(ctx contractapi.TransactionContextInterface) error {
+		_, err := contract.RecordConsortiumRepayment(ctx, loanID, paymentID, amount)
+		return err
+	}
+}
+
+func consortiumState(t *testing.T, stub *chaincodetest.Stub, loanID string) ConsortiumLoan {
+	t.Helper()
+	var loan ConsortiumLoan
+	readState(t, stub, consortiumKey(loanID), &loan)
+	return loan
+}
+
+func TestCreateConsortiumLoan(t *testing.T) {
+	setup := func(t *testing.T, stub *chaincodetest.Stub) {
+		registerSyndicate(t, stub)
+		mustSubmit(t, stub, chaincodetest.Creditor, createConsortium("CL1", syndicate))
+	}
+	runCases(t, setup, []txCase{
+		{name: "creditor creates", id: chaincodetest.Creditor, call: createConsortium("CL2", syndicate), event: events.ConsortiumLoanCreated,
+			check: func(t *testing.T, stub *chaincodetest.Stub) {
+				if loan := consortiumState(t, stub, "CL2"); loan.Outstanding != 10000000 || loan.Status != "ACTIVE" {
+					t.Fatalf("unexpected loan %+v", loan)
+				}
+				if stub.ValidationParameter(consortiumKey("CL2")) == nil {
+					t.Fatal("no key-level endorsement policy")
+				}
+			}},
+		{name: "debtor cannot create", id: chaincodetest.Debtor, call: createConsortium("CL2", syndicate), wantErr: "only CreditorMSP or AdminMSP"},
+		{name: "duplicate", id: chaincodetest.Creditor, call: createConsortium("CL1", syndicate), wantErr: "already exists"},
+		{name: "single participant", id: chaincodetest.Creditor, call: createConsortium("CL2", syndicate[:1]), wantErr: "at least two participants"},
+		{name: "shares not 100", id: chaincodetest.Creditor, call: createConsortium("CL2", syndicate[:2]), wantErr: "not 100"},
+		{name: "lead not participant", id: chaincodetest.Creditor, call: createConsortium("CL2", syndicate[1:]), wantErr: "must be a participant"},
+		{name: "participant twice", id: chaincodetest.Creditor, wantErr: "listed twice",
+			call: createConsortium("CL2", []Participant{syndicate[0], syndicate[1], syndicate[1]})},
+		{name: "unregistered participant", id: chaincodetest.Creditor, wantErr: "participant UCO is not a registered institution",
+			call: createConsortium("CL2", []Participant{syndicate[0], {CreditorID: "UCO", MSPID: "CreditorMSP", SharePercent: 50}})},
+		{name: "participant MSP mismatch", id: chaincodetest.Creditor, wantErr: "participant PNB is bound to CreditorMSP, not AdminMSP",
+			call: createConsortium("CL2", []Participant{syndicate[0], {CreditorID: "PNB", MSPID: "AdminMSP", SharePercent: 50}})},
+		{name: "suspended participant", id: chaincodetest.Creditor, call: createConsortium("CL2", syndicate),
+			setup: admin(suspendInstitution("PNB")), wantErr: "participant PNB is SUSPENDED"},
+		{name: "lead of another MSP", id: chaincodetest.Creditor, wantErr: "creditor OTHER is bound to OtherMSP, not CreditorMSP",
+			setup: admin(registerInstitution("OTHER", "AAACO1234D", "OtherMSP")),
+			call: func(ctx contractapi.TransactionContextInterface) error {
+				return contract.CreateConsortiumLoan(ctx, "CL2", "D1", "OTHER", 10000000, "INR",
+					[]Participant{{CreditorID: "OTHER", MSPID: "OtherMSP", SharePercent: 50}, {CreditorID: "PNB", MSPID: "CreditorMSP", SharePercent: 50}})
+			}},
+		{name: "unregistered debtor", id: chaincodetest.Creditor, wantErr: "debtor D9 is not a registered borrower or institution",
+			call: func(ctx contractapi.TransactionContextInterface) error {
+				return contract.CreateConsortiumLoan(ctx, "CL2", "D9", "SBI", 10000000, "INR", syndicate)
+			}},
+		{name: "suspended debtor", id: chaincodetest.Creditor, call: createConsortium("CL2", syndicate),
+			setup: admin(suspendBorrower("D1")), wantErr: "debtor D1 is SUSPENDED"},
+		{name: "GetConsortiumLoan", id: chaincodetest.Debtor, call: func(ctx contractapi.TransactionContextInterface) error {
+			loan, err := contract.GetConsortiumLoan(ctx, "CL1")
+			if err == nil && len(loan.Participants) != 3 {
+				t.Errorf("participants = %v", loan.Participants)
+			}
+			return err
+		}},
+		{name: "GetConsortiumLoan missing", id: chaincodetest.Debtor, wantErr: "does not exist", call: func(ctx contractapi.TransactionContextInterface) error {
+			_, err := contract.GetConsortiumLoan(ctx, "CL9")
+			return err
+		}},
+	})
+}
+
+func TestConsortiumAllocations(t *testing.T) {
+	setup := func(t *testing.T, stub *chaincodetest.Stub) {
+		registerSyndicate(t, stub)
+		mustSubmit(t, stub, chaincodetest.Creditor, createConsortium("CL1", syndicate))
+		mustSubmit(t, stub, chaincodetest.Creditor, repay("CL1", "PAY1", 1000000))
+	}
+	allocation := func(eventType, eventID string, want ...float64) func(t *testing.T, stub *chaincodetest.Stub) {
+		return func(t *testing.T, stub *chaincodetest.Stub) {
+			var a ConsortiumAllocation
+			readState(t, stub, "\x00"+consortiumAllocationIndex+"\x00CL1\x00"+eventType+"\x00"+eventID+"\x00", &a)
+			for i, p := range a.Allocations {
+				if p.Amount != want[i] {
+					t.Fatalf("allocations = %+v, want %v", a.Allocations, want)
+				}
+			}
+		}
+	}
+	allocateClaim := func(caseID string) ctxFunc {
+		return func(ctx contractapi.TransactionContextInterface) error {
+			_, err := contract.AllocateConsortiumClaim(ctx, "CL1", caseID)
+			return err
+		}
+	}
+	runCases(t, setup, []txCase{
+		{name: "repayment", id: chaincodetest.Creditor, call: repay("CL1", "PAY2", 2000000), event: events.ConsortiumRepaymentRecorded,
+			check: func(t *testing.T, stub *chaincodetest.Stub) {
+				allocation(AllocationRepayment, "PAY2", 1000000, 600000, 400000)(t, stub)
+				if loan := consortiumState(t, stub, "CL1"); loan.Outstanding != 7000000 {
+					t.Fatalf("outstanding = %f", loan.Outstanding)
+				}
+			}},
+		{name: "rounding goes to the lead", id: chaincodetest.Creditor, call: repay("CL2", "PAY1", 0.1),
+			setup: func(t *testing.T, stub *chaincodetest.Stub) {
+				mustSubmit(t, stub, chaincodetest.Creditor, func(ctx contractapi.TransactionContextInterface) error {
+					return contract.CreateConsortiumLoan(ctx, "CL2", "D1", "PNB", 100, "INR", []Participant{
+						{CreditorID: "SBI", MSPID: "CreditorMSP", SharePercent: 33.33},
+						{CreditorID: "PNB", MSPID: "CreditorMSP", SharePercent: 33.33},
+						{CreditorID: "BOB", MSPID: "CreditorMSP", SharePercent: 33.34},
+					})
+				})
+			},
+			check: func(t *testing.T, stub *chaincodetest.Stub) {
+				var a ConsortiumAllocation
+				readState(t, stub, "\x00"+consortiumAllocationIndex+"\x00CL2\x00REPAYMENT\x00PAY1\x00", &a)
+				if a.Allocations[0].Amount != 0.03 || a.Allocations[1].Amount != 0.04 || a.Allocations[2].Amount != 0.03 {
+					t.Fatalf("allocations = %+v", a.Allocations)
+				}
+			}},
+		{name: "full repayment closes", id: chaincodetest.Admin, call: repay("CL1", "PAY2", 9000000),
+			check: func(t *testing.T, stub *chaincodetest.Stub) {
+				if loan := consortiumState(t, stub, "CL1"); loan.Status != "CLOSED" || loan.Outstanding != 0 {
+					t.Fatalf("unexpected loan %+v", loan)
+				}
+			}},
+		{name: "exceeds outstanding", id: chaincodetest.Creditor, call: repay("CL1", "PAY2", 9000001), wantErr: "exceeds outstanding"},
+		{name: "payment allocated twice", id: chaincodetest.Creditor, call: repay("CL1", "PAY1", 1), wantErr: "already allocated"},
+		{name: "debtor cannot allocate", id: chaincodetest.Debtor, call: repay("CL1", "PAY2", 1), wantErr: "only CreditorMSP or AdminMSP"},
+		{name: "closed loan", id: chaincodetest.Creditor, call: repay("CL1", "PAY3", 1), wantErr: "consortium loan CL1 is CLOSED",
+			setup: func(t *testing.T, stub *chaincodetest.Stub) {
+				mustSubmit(t, stub, chaincodetest.Creditor, repay("CL1", "PAY2", 9000000))
+			}},
+		{name: "default", id: chaincodetest.Creditor, event: events.ConsortiumDefaultAllocated,
+			setup: func(t *testing.T, stub *chaincodetest.Stub) {
+				mustSubmit(t, stub, chaincodetest.Creditor, fileDefault("DEF1", "CL1", "D1"))
+			},
+			call: func(ctx contractapi.TransactionContextInterface) error {
+				_, err := contract.AllocateConsortiumDefault(ctx, "CL1", "DEF1")
+				return err
+			},
+			check: allocation(AllocationDefault, "DEF1", 125000, 75000, 50000)},
+		{name: "default on another loan", id: chaincodetest.Creditor, wantErr: "is filed on loan L1",
+			setup: func(t *testing.T, stub *chaincodetest.Stub) {
+				mustSubmit(t, stub, chaincodetest.Creditor, fileDefault("DEF1", "L1", "D1"))
+			},
+			call: func(ctx contractapi.TransactionContextInterface) error {
+				_, err := contract.AllocateConsortiumDefault(ctx, "CL1", "DEF1")
+				return err
+			}},
+		{name: "claim", id: chaincodetest.Creditor, event: events.ConsortiumClaimAllocated,
+			setup: func(t *testing.T, stub *chaincodetest.Stub) {
+				mustSubmit(t, stub, chaincodetest.Admin, registerCase("CASE1", "D1", "2024-03-01", ""))
+				mustSubmit(t, stub, chaincodetest.Creditor, submitClaim("CASE1", "SBI", 6000000), withClaimForm)
+				mustSubmit(t, stub, chaincodetest.Creditor, submitClaim("CASE1", "PNB", 4000000), withClaimForm)
+				mustSubmit(t, stub, chaincodetest.Creditor, submitClaim("CASE1", "C1", 5000000), withClaimForm)
+				for creditorID, amount := range map[string]float64{"SBI": 5000000, "PNB": 4000000, "C1": 5000000} {
+					creditorID, amount := creditorID, amount
+					mustSubmit(t, stub, rp, func(ctx contractapi.TransactionContextInterface) error {
+						return contract.AdmitClaim(ctx, "CASE1", creditorID, amount, "")
+					})
+				}
+			},
+			call:  allocateClaim("CASE1"),
+			check: allocation(AllocationClaim, "CASE1", 4500000, 2700000, 1800000)},
+		{name: "claim not yet admitted", id: chaincodetest.Creditor, call: allocateClaim("CASE1"), wantErr: "no claim by a participant of consortium loan CL1 is admitted in case CASE1",
+			setup: func(t *testing.T, stub *chaincodetest.Stub) {
+				mustSubmit(t, stub, chaincodetest.Admin, registerCase("CASE1", "D1", "2024-03-01", ""))
+				mustSubmit(t, stub, chaincodetest.Creditor, submitClaim("CASE1", "SBI", 6000000), withClaimForm)
+			}},
+		{name: "claim against another debtor", id: chaincodetest.Creditor, call: allocateClaim("CASE1"), wantErr: "is against D2",
+			setup: func(t *testing.T, stub *chaincodetest.Stub) {
+				mustSubmit(t, stub, chaincodetest.Admin, registerCase("CASE1", "D2", "2024-03-01", ""))
+			}},
+		{name: "GetConsortiumAllocations", id: chaincodetest.Debtor, call: func(ctx contractapi.TransactionContextInterface) error {
+			allocations, err := contract.GetConsortiumAllocations(ctx, "CL1")
+			if err == nil && (len(allocations) != 1 || allocations[0].EventID != "PAY1") {
+				t.Errorf("allocations = %+v", allocations)
+			}
+			return err
+		}},
+	})
+}
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+
+	"iu-chaincode/events"
+)
+
+// Authentication statuses for a default record, following the IU
+// regulations: the debtor confirms or disputes filed information, and
+// silence past the deadline makes it deemed authenticated.
+const (
+	AuthStatusPending             = "PENDING_AUTHENTICATION"
+	AuthStatusAuthenticated       = "AUTHENTICATED"
+	AuthStatusDisputed            = "DISPUTED"
+	AuthStatusDeemedAuthenticated = "DEEMED_AUTHENTICATED"
+)
+
+// defaultDeemedAuthDays is used until AdminMSP configures the window
+const defaultDeemedAuthDays = 30
+
+const deemedAuthDaysKey = "CONFIG_DEEMED_AUTH_DAYS"
+
+// DefaultRecord represents information of default filed by a creditor
+type DefaultRecord struct {
+	ID             string    `json:"defaultId"`
+	LoanID         string    `json:"loanId"`
+	CreditorID     string    `json:"creditorId"`
+	DebtorID       string    `json:"debtorId"`
+	Amount         float64   `json:"amount"`
+	Currency       string    `json:"currency"`
+	DefaultDate    string    `json:"defaultDate"`
+	Details        string    `json:"details"`
+	Guarantors     []string  `json:"guarantors"`
+	GuaranteeID    string    `json:"guaranteeId,omitempty" metadata:",optional"` // set when raised by invoking a guarantee
+	FiledBy        string    `json:"filedBy"`
+	FiledAt        time.Time `json:"filedAt"`
+	AuthStatus     string    `json:"authStatus"` // PENDING_AUTHENTICATION, AUTHENTICATED, DISPUTED, DEEMED_AUTHENTICATED
+	RespondedBy    string    `json:"respondedBy"`
+	RespondedAt    time.Time `json:"respondedAt"`
+	DisputeReason  string    `json:"disputeReason"`
+	EvidenceDocIDs []string  `json:"evidenceDocIds"`
+}
+
+func defaultKey(id string) string {
+	return fmt.Sprintf("DEFAULT_%s", id)
+}
+
+// getTxTime returns the transaction timestamp so that all endorsers agree on time
+func getTxTime(ctx contractapi.TransactionContextInterface) (time.Time, error) {
+	ts, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get tx timestamp: %v", err)
+	}
+	return ts.AsTime(), nil
+}
+
+// FileDefault records information of default against a debtor for authentication
+func (s *IUContract) FileDefault(ctx contractapi.TransactionContextInterface, id, loanID, creditorID, debtorID string, amount float64, currency, defaultDate, details string) error {
+	mspid, err := getMSPID(ctx)
+	if err != nil {
+		return err
+	}
+	if mspid != "CreditorMSP" && mspid != "AdminMSP" {
+		return fmt.Errorf("only CreditorMSP or AdminMSP can file a default")
+	}
+	if id == "" || loanID == "" || creditorID == "" || debtorID == "" {
+		return fmt.Errorf("id, loanID, creditorID and debtorID are required")
+	}
+	if amount <= 0 {
+		return fmt.Errorf("default amount must be positive")
+	}
+
+	guarantors, err := s.loanGuarantors(ctx, loanID)
+	if err != nil {
+		return err
+	}
+	record := &DefaultRecord{
+		ID:          id,
+		LoanID:      loanID,
+		CreditorID:  creditorID,
+		DebtorID:    debtorID,
+		Amount:      amount,
+		Currency:    currency,
+		DefaultDate: defaultDate,
+		Details:     details,
+		Guarantors:  guarantors,
+		FiledBy:     mspid,
+	}
+	if err := s.createDefault(ctx, record); err != nil {
+		return err
+	}
+	return emitEvent(ctx, events.DefaultFiled, defaultKey(id), record)
+}
+
+// createDefault stores a new default record pending authentication by its debtor
+func (s *IUContract) createDefault(ctx contractapi.TransactionContextInterface, record *DefaultRecord) error {
+	key := defaultKey(record.ID)
+	exists, err := s.TransactionExists(ctx, key)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("default %s already exists", record.ID)
+	}
+	if err := s.requireCreditor(ctx, record.CreditorID, record.FiledBy); err != nil {
+		return err
+	}
+	if err := s.requireDebtor(ctx, record.DebtorID, false); err != nil {
+		return err
+	}
+	if err := s.enforceMoratorium(ctx, record.DebtorID, "FILE_DEFAULT", record.ID); err != nil {
+		return err
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	record.FiledAt = now
+	record.AuthStatus = AuthStatusPending
+	record.EvidenceDocIDs = []string{}
+	if err := putDefault(ctx, record); err != nil {
+		return err
+	}
+	owner, err := s.creditorOrg(ctx, record.CreditorID)
+	if err != nil {
+		return err
+	}
+	if err := setKeyEndorsers(ctx, key, owner, "AdminMSP"); err != nil {
+		return err
+	}
+
+	return writeAuditRecord(ctx, record.ID, "FILE_DEFAULT", record.FiledBy,
+		fmt.Sprintf("Default filed by %s against %s, Amount: %f %s", record.CreditorID, record.DebtorID, record.Amount, record.Currency),
+		AuthStatusPending, now)
+}
+
+// GetDefault returns the default record with given id
+func (s *IUContract) GetDefault(ctx contractapi.TransactionContextInterface, id string) (*DefaultRecord, error) {
+	val, err := ctx.GetStub().GetState(defaultKey(id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if val == nil {
+		return nil, fmt.Errorf("default %s does not exist", id)
+	}
+	var record DefaultRecord
+	if err := json.Unmarshal(val, &record); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+func putDefault(ctx contractapi.TransactionContextInterface, record *DefaultRecord) error {
+	b, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return ctx.GetStub().PutState(defaultKey(record.ID), b)
+}
+
+// ConfirmDefault lets the debtor confirm the filed default information. Only
+// the client bound to a borrower, or the org of a debtor institution, may.
+func (s *IUContract) ConfirmDefault(ctx contractapi.TransactionContextInterface, defaultID string) error {
+	record, err := s.GetDefault(ctx, defaultID)
+	if err != nil {
+		return err
+	}
+	mspid, err := s.requireRespondent(ctx, record.DebtorID, "confirm a default")
+	if err != nil {
+		return err
+	}
+	if record.AuthStatus != AuthStatusPending {
+		return fmt.Errorf("default %s is not pending authentication (status %s)", defaultID, record.AuthStatus)
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	record.AuthStatus = AuthStatusAuthenticated
+	record.RespondedBy = mspid
+	record.RespondedAt = now
+	if err := putDefault(ctx, record); err != nil {
+		return err
+	}
+
+	if err := writeAuditRecord(ctx, defaultID, "CONFIRM_DEFAULT", mspid,
+		"Default confirmed by debtor", AuthStatusAuthenticated, now); err != nil {
+		return err
+	}
+	return emitEvent(ctx, events.DefaultConfirmed, defaultKey(defaultID), record)
+}
+
+// DisputeDefault lets the debtor dispute the filed default with supporting
+// documents, under the same binding as ConfirmDefault
+func (s *IUContract) DisputeDefault(ctx contractapi.TransactionContextInterface, defaultID, reason string, evidenceDocIDs []string) error {
+	if reason == "" {
+		return fmt.Errorf("reason is required")
+	}
+	record, err := s.GetDefault(ctx, defaultID)
+	if err != nil {
+		return err
+	}
+	mspid, err := s.requireRespondent(ctx, record.DebtorID, "dispute a default")
+	if err != nil {
+		return err
+	}
+	if record.AuthStatus != AuthStatusPending {
+		return fmt.Errorf("default %s is not pending authentication (status %s)", defaultID, record.AuthStatus)
+	}
+	for _, docID := range evidenceDocIDs {
+		if _, err := s.GetDocument(ctx, docID); err != nil {
+			return fmt.Errorf("evidence document %s: %v", docID, err)
+		}
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	record.AuthStatus = AuthStatusDisputed
+	record.RespondedBy = mspid
+	record.RespondedAt = now
+	record.DisputeReason = reason
+	record.EvidenceDocIDs = evidenceDocIDs
+	if err := putDefault(ctx, record); err != nil {
+		return err
+	}
+
+	if err := writeAuditRecord(ctx, defaultID, "DISPUTE_DEFAULT", mspid,
+		fmt.Sprintf("Default disputed by debtor: %s (evidence: %v)", reason, evidenceDocIDs), AuthStatusDisputed, now); err != nil {
+		return err
+	}
+	return emitEvent(ctx, events.DefaultDisputed, defaultKey(defaultID), record)
+}
+
+// SetDeemedAuthenticationDays configures the days a debtor has to respond before deemed authentication
+func (s *IUContract) SetDeemedAuthenticationDays(ctx contractapi.TransactionContextInterface, days int) error {
+	mspid, err := getMSPID(ctx)
+	if err != nil {
+		return err
+	}
+	if mspid != "AdminMSP" {
+		return fmt.Errorf("only AdminMSP can configure the authentication window")
+	}
+	if days <= 0 {
+		return fmt.Errorf("days must be positive")
+	}
+	value := []byte(strconv.Itoa(days))
+	if err := ctx.GetStub().PutState(deemedAuthDaysKey, value); err != nil {
+		return err
+	}
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	if err := writeAuditRecord(ctx, deemedAuthDaysKey, "SET_DEEMED_AUTH_DAYS", mspid,
+		fmt.Sprintf("Deemed authentication after %d days", days), "CONFIGURED", now); err != nil {
+		return err
+	}
+	return emitEvent(ctx, events.DeemedAuthDaysSet, deemedAuthDaysKey, value)
+}
+
+// GetDeemedAuthenticationDays returns the configured authentication window in days
+func (s *IUContract) GetDeemedAuthenticationDays(ctx contractapi.TransactionContextInterface) (int, error) {
+	val, err := ctx.GetStub().GetState(deemedAuthDaysKey)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if val == nil {
+		return defaultDeemedAuthDays, nil
+	}
+	return strconv.Atoi(string(val))
+}
+
+// ApplyDeemedAuthentication marks unanswered defaults past the window as deemed authenticated.
+// asOf is an RFC3339 time no later than the transaction timestamp; empty means the tx time.
+func (s *IUContract) ApplyDeemedAuthentication(ctx contractapi.TransactionContextInterface, asOf string) (int, error) {
+	mspid, err := getMSPID(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if mspid != "AdminMSP" {
+		return 0, fmt.Errorf("only AdminMSP can apply deemed authentication")
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return 0, err
+	}
+	cutoff := now
+	if asOf != "" {
+		cutoff, err = time.Parse(time.RFC3339, asOf)
+		if err != nil {
+			return 0, fmt.Errorf("invalid asOf: %v", err)
+		}
+		if cutoff.After(now) {
+			return 0, fmt.Errorf("asOf %s is later than the transaction timestamp", asOf)
+		}
+	}
+
+	days, err := s.GetDeemedAuthenticationDays(ctx)
+	if err != nil {
+		return 0, err
+	}
+	window := time.Duration(days) * 24 * time.Hour
+
+	resultsIterator, err := ctx.GetStub().GetStateByRange("DEFAULT_", "DEFAULT_~")
+	if err != nil {
+		return 0, err
+	}
+	defer resultsIterator.Close()
+
+	var due []*DefaultRecord
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return 0, err
+		}
+		var record DefaultRecord
+		if err := json.Unmarshal(queryResponse.Value, &record); err != nil {
+			return 0, err
+		}
+		if record.AuthStatus == AuthStatusPending && !cutoff.Before(record.FiledAt.Add(window)) {
+			due = append(due, &record)
+		}
+	}
+
+	keys := []string{}
+	for _, record := range due {
+		keys = append(keys, defaultKey(record.ID))
+		record.AuthStatus = AuthStatusDeemedAuthenticated
+		record.RespondedBy = mspid
+		record.RespondedAt = now
+		if err := putDefault(ctx, record); err != nil {
+			return 0, err
+		}
+		details := fmt.Sprintf("No response from debtor within %d days of filing on %s", days, record.FiledAt.Format(time.RFC3339))
+		if err := writeAuditRecord(ctx, record.ID, "DEEMED_AUTHENTICATION", mspid, details, AuthStatusDeemedAuthenticated, now); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := emitEvent(ctx, events.DeemedAuthenticationApplied, "", keys); err != nil {
+		return 0, err
+	}
+	return len(due), nil
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+
+	"iu-chaincode/chaincodetest"
+	"iu-chaincode/events"
+)
+
+func fileDefault(id, loanID, debtorID string) ctxFunc {
+	return func(ctx contractapi.TransactionContextInterface) error {
+		return contract.FileDefault(ctx, id, loanID, "C1", debtorID, 250000, "INR", "2024-03-31", "90 days past due")
+	}
+}
+
+func defaultStatus(id, want string) func(t *testing.T, stub *chaincodetest.Stub) {
+	return func(t *testing.T, stub *chaincodetest.Stub) {
+		var record DefaultRecord
+		readState(t, stub, defaultKey(id), &record)
+		if record.AuthStatus != want {
+			t.Fatalf("authStatus = %s, want %s", record.AuthStatus, want)
+		}
+	}
+}
+
+func TestFileDefault(t *testing.T) {
+	setup := func(t *testing.T, stub *chaincodetest.Stub) {
+		mustSubmit(t, stub, chaincodetest.Creditor, fileDefault("DEF1", "L1", "D1"))
+	}
+	runCases(t, setup, []txCase{
+		{name: "creditor files", id: chaincodetest.Creditor, call: fileDefault("DEF2", "L1", "D1"), event: events.DefaultFiled,
+			check: func(t *testing.T, stub *chaincodetest.Stub) {
+				defaultStatus("DEF2", AuthStatusPending)(t, stub)
+				// The contract schema has evidenceDocIds as an array, never null
+				var record DefaultRecord
+				readState(t, stub, defaultKey("DEF2"), &record)
+				if record.EvidenceDocIDs == nil {
+					t.Fatal("evidenceDocIds is null")
+				}
+				// A record not raised by a guarantee omits guaranteeId and
+				// must still match the schema when returned
+				cc, err := contractapi.NewChaincode(&IUContract{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if resp := stub.Invoke(cc, chaincodetest.Debtor, []string{"GetDefault", "DEF2"}); resp.Status != 200 {
+					t.Fatalf("GetDefault: %s", resp.Message)
+				}
+			}},
+		{name: "admin files", id: chaincodetest.Admin, call: fileDefault("DEF2", "L1", "D1"), check: defaultStatus("DEF2", AuthStatusPending)},
+		{name: "debtor cannot file", id: chaincodetest.Debtor, call: fileDefault("DEF2", "L1", "D1"), wantErr: "only CreditorMSP or AdminMSP"},
+		{name: "duplicate", id: chaincodetest.Creditor, call: fileDefault("DEF1", "L1", "D1"), wantErr: "already exists"},
+		{name: "non-positive amount", id: chaincodetest.Creditor, wantErr: "must be positive", call: func(ctx contractapi.TransactionContextInterface) error {
+			return contract.FileDefault(ctx, "DEF2", "L1", "C1", "D1", 0, "INR", "2024-03-31", "")
+		}},
+		{name: "GetDefault", id: chaincodetest.Debtor, call: func(ctx contractapi.TransactionContextInterface) error {
+			record, err := contract.GetDefault(ctx, "DEF1")
+			if err == nil && (record.FiledBy != "CreditorMSP" || !record.FiledAt.Equal(chaincodetest.Start)) {
+				t.Errorf("unexpected record %+v", record)
+			}
+			return err
+		}},
+		{name: "GetDefault missing", id: chaincodetest.Debtor, wantErr: "does not exist", call: func(ctx contractapi.TransactionContextInterface) error {
+			_, err := contract.GetDefault(ctx, "DEF9")
+			return err
+		}},
+	})
+}
+
+// otherDebtor is a debtor-org client no test borrower is bound to
+var otherDebtor = chaincodetest.NewIdentity("DebtorMSP", "user2@debtor.iu-network.com")
+
+func TestConfirmAndDisputeDefault(t *testing.T) {
+	setup := func(t *testing.T, stub *chaincodetest.Stub) {
+		mustSubmit(t, stub, chaincodetest.Creditor, fileDefault("DEF1", "L1", "D1"))
+		mustSubmit(t, stub, chaincodetest.Debtor, submitDoc("L1", "EV1"))
+	}
+	confirm := func(ctx contractapi.TransactionContextInterface) error {
+		return contract.ConfirmDefault(ctx, "DEF1")
+	}
+	dispute := func(docs ...string) ctxFunc {
+		return func(ctx contractapi.TransactionContextInterface) error {
+			return contract.DisputeDefault(ctx, "DEF1", "repaid on 2024-03-15", docs)
+		}
+	}
+	runCases(t, setup, []txCase{
+		{name: "debtor confirms", id: chaincodetest.Debtor, call: confirm, event: events.DefaultConfirmed, check: defaultStatus("DEF1", AuthStatusAuthenticated)},
+		{name: "creditor cannot confirm", id: chaincodetest.Creditor, call: confirm, wantErr: "only DebtorMSP can confirm"},
+		{name: "debtor disputes", id: chaincodetest.Debtor, call: dispute("EV1"), event: events.DefaultDisputed,
+			check: func(t *testing.T, stub *chaincodetest.Stub) {
+				var record DefaultRecord
+				readState(t, stub, defaultKey("DEF1"), &record)
+				if record.AuthStatus != AuthStatusDisputed || len(record.EvidenceDocIDs) != 1 {
+					t.Fatalf("unexpected record %+v", record)
+				}
+			}},
+		{name: "admin cannot dispute", id: chaincodetest.Admin, call: dispute(), wantErr: "only DebtorMSP can dispute"},
+		{name: "another debtor-org client cannot confirm", id: otherDebtor, call: confirm, wantErr: "only the client bound to borrower D1 can confirm"},
+		{name: "another debtor-org client cannot dispute", id: otherDebtor, call: dispute(), wantErr: "only the client bound to borrower D1 can dispute"},
+		{name: "unbound borrower cannot confirm", id: chaincodetest.Debtor, wantErr: "only the client bound to borrower B1",
+			call: func(ctx contractapi.TransactionContextInterface) error {
+				return contract.ConfirmDefault(ctx, "DEF2")
+			},
+			setup: func(t *testing.T, stub *chaincodetest.Stub) {
+				mustSubmit(t, stub, chaincodetest.Admin, registerBorrower("B1", BorrowerTypeIndividual, "ABCPR1234K", "", ""))
+				mustSubmit(t, stub, chaincodetest.Creditor, fileDefault("DEF2", "L1", "B1"))
+			}},
+		{name: "debtor institution confirms through its org", id: chaincodetest.Creditor, check: defaultStatus("DEF2", AuthStatusAuthenticated),
+			call: func(ctx contractapi.TransactionContextInterface) error {
+				return contract.ConfirmDefault(ctx, "DEF2")
+			},
+			setup: func(t *testing.T, stub *chaincodetest.Stub) {
+				mustSubmit(t, stub, chaincodetest.Creditor, fileDefault("DEF2", "L1", "C2"))
+			}},
+		{name: "unknown evidence", id: chaincodetest.Debtor, call: dispute("EV9"), wantErr: "evidence document EV9"},
+		{name: "dispute after confirm", id: chaincodetest.Debtor, call: dispute(), wantErr: "is not pending authentication",
+			setup: func(t *testing.T, stub *chaincodetest.Stub) { mustSubmit(t, stub, chaincodetest.Debtor, confirm) }},
+	})
+}
+
+func TestDeemedAuthentication(t *testing.T) {
+	setDays := func(days int) ctxFunc {
+		return func(ctx contractapi.TransactionContextInterface) error {
+			return contract.SetDeemedAuthenticationDays(ctx, days)
+		}
+	}
+	apply := func(asOf string, want int) ctxFunc {
+		return func(ctx contractapi.TransactionContextInterface) error {
+			n, err := contract.ApplyDeemedAuthentication(ctx, asOf)
+			if err == nil && n != want {
+				t.Errorf("applied to %d defaults, want %d", n, want)
+			}
+			return err
+		}
+	}
+	days := func(want int) ctxFunc {
+		return func(ctx contractapi.TransactionContextInterface) error {
+			n, err := contract.GetDeemedAuthenticationDays(ctx)
+			if err == nil && n != want {
+				t.Errorf("days = %d, want %d", n, want)
+			}
+			return err
+		}
+	}
+	later := func(d time.Duration) func(t *testing.T, stub *chaincodetest.Stub) {
+		return func(t *testing.T, stub *chaincodetest.Stub) { stub.SetTime(chaincodetest.Start.Add(d)) }
+	}
+	setup := func(t *testing.T, stub *chaincodetest.Stub) {
+		mustSubmit(t, stub, chaincodetest.Creditor, fileDefault("DEF1", "L1", "D1"))
+	}
+	runCases(t, setup, []txCase{
+		{name: "default window", id: chaincodetest.Debtor, call: days(defaultDeemedAuthDays)},
+		{name: "admin sets window", id: chaincodetest.Admin, call: setDays(7), event: events.DeemedAuthDaysSet,
+			check: func(t *testing.T, stub *chaincodetest.Stub) {
+				mustSubmit(t, stub, chaincodetest.Debtor, days(7))
+				if len(stub.Keys("AUDIT_"+deemedAuthDaysKey+"_SET_DEEMED_AUTH_DAYS_")) != 1 {
+					t.Fatal("no audit record written")
+				}
+			}},
+		{name: "creditor cannot set window", id: chaincodetest.Creditor, call: setDays(7), wantErr: "only AdminMSP"},
+		{name: "non-positive window", id: chaincodetest.Admin, call: setDays(0), wantErr: "must be positive"},
+		{name: "within window", id: chaincodetest.Admin, setup: later(29 * 24 * time.Hour), call: apply("", 0),
+			check: defaultStatus("DEF1", AuthStatusPending)},
+		{name: "past window", id: chaincodetest.Admin, setup: later(30 * 24 * time.Hour), call: apply("", 1),
+			event: events.DeemedAuthenticationApplied, check: defaultStatus("DEF1", AuthStatusDeemedAuthenticated)},
+		{name: "asOf before window", id: chaincodetest.Admin, setup: later(40 * 24 * time.Hour),
+			call: apply(chaincodetest.Start.Add(10*24*time.Hour).Format(time.RFC3339), 0)},
+		{name: "asOf in the future", id: chaincodetest.Admin, call: apply("2030-01-01T00:00:00Z", 0), wantErr: "later than the transaction timestamp"},
+		{name: "debtor cannot apply", id: chaincodetest.Debtor, call: apply("", 0), wantErr: "only AdminMSP"},
+	})
+}
+
+func TestGetRecordOfDefault(t *testing.T) {
+	setup := func(t *testing.T, stub *chaincodetest.Stub) {
+		mustSubmit(t, stub, chaincodetest.Creditor, submitDoc("L1", "SL1"))
+		mustSubmit(t, stub, chaincodetest.Creditor, fileDefault("DEF1", "L1", "D1"))
+		mustSubmit(t, stub, chaincodetest.Debtor, submitDoc("L9", "EV1"))
+		mustSubmit(t, stub, chaincodetest.Debtor, func(ctx contractapi.TransactionContextInterface) error {
+			return contract.DisputeDefault(ctx, "DEF1", "repaid", []string{"EV1"})
+		})
+	}
+	runCases(t, setup, []txCase{
+		{name: "certificate", id: chaincodetest.Creditor, call: func(ctx contractapi.TransactionContextInterface) error {
+			out, err := contract.GetRecordOfDefault(ctx, "DEF1")
+			if err != nil {
+				return err
+			}
+			var rod RecordOfDefault
+			if err := json.Unmarshal([]byte(out), &rod); err != nil {
+				return err
+			}
+			if rod.CertificateType != RecordOfDefaultType || rod.AuthStatus != AuthStatusDisputed || rod.ChannelID != sourceChannel {
+				t.Errorf("unexpected certificate header %+v", rod)
+			}
+			if len(rod.Documents) != 2 || rod.Documents[0].Role != "LOAN" || rod.Documents[1].Role != "EVIDENCE" {
+				t.Errorf("documents = %+v", rod.Documents)
+			}
+			if len(rod.History) != 2 || len(rod.AuditTrail) != 2 {
+				t.Errorf("history %d audit trail %d, want 2 and 2", len(rod.History), len(rod.AuditTrail))
+			}
+			return nil
+		}},
+		{name: "missing", id: chaincodetest.Creditor, wantErr: "does not exist", call: func(ctx contractapi.TransactionContextInterface) error {
+			_, err := contract.GetRecordOfDefault(ctx, "DEF9")
+			return err
+		}},
+	})
+}
+
+func TestGetRecordEvidence(t *testing.T) {
+	setup := func(t *testing.T, stub *chaincodetest.Stub) {
+		mustSubmit(t, stub, chaincodetest.Creditor, createTx("TX1", "C1", "D1", 1000, "DEBIT"))
+		mustSubmit(t, stub, chaincodetest.Admin, complianceCheck("TX1", true))
+		mustSubmit(t, stub, chaincodetest.Creditor, submitDoc("L1", "SL1"))
+		mustSubmit(t, stub, chaincodetest.Creditor, fileDefault("DEF1", "L1", "D1"))
+	}
+	evidence := func(recordType, id, key string, versions int) ctxFunc {
+		return func(ctx contractapi.TransactionContextInterface) error {
+			ev, err := contract.GetRecordEvidence(ctx, recordType, id)
+			if err != nil {
+				return err
+			}
+			if ev.Key != key || ev.ValueHash != sha256Hex([]byte(ev.Value)) || len(ev.History) != versions {
+				t.Errorf("unexpected evidence %+v", ev)
+			}
+			if ev.History[0].ValueHash != ev.ValueHash {
+				t.Errorf("latest version hash %s does not match the current value", ev.History[0].ValueHash)
+			}
+			return nil
+		}
+	}
+	runCases(t, setup, []txCase{
+		{name: "transaction", id: chaincodetest.Admin, call: evidence("TRANSACTION", "TX1", "TX1", 2)},
+		{name: "document", id: chaincodetest.Admin, call: evidence("DOCUMENT", "SL1", "DOC_SL1", 1)},
+		{name: "default", id: chaincodetest.Admin, call: evidence("DEFAULT", "DEF1", "DEFAULT_DEF1", 1)},
+		{name: "unsupported type", id: chaincodetest.Admin, call: evidence("ACCOUNT", "ACC001", "", 0), wantErr: "unsupported record type"},
+		{name: "missing", id: chaincodetest.Admin, call: evidence("DEFAULT", "DEF9", "", 0), wantErr: "does not exist"},
+	})
+}
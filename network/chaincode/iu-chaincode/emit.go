@@ -0,0 +1,47 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+
+	"iu-chaincode/events"
+)
+
+// emitEvent sets the transaction's chaincode event. value is the state value
+// written under entityKey, as raw bytes or as the struct passed to putJSON;
+// its hash matches what GetVersionHashes reports for this version.
+func emitEvent(ctx contractapi.TransactionContextInterface, eventType, entityKey string, value interface{}) error {
+	b, ok := value.([]byte)
+	if !ok {
+		var err error
+		if b, err = json.Marshal(value); err != nil {
+			return err
+		}
+	}
+	mspid, err := getMSPID(ctx)
+	if err != nil {
+		return err
+	}
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	envelope, err := json.Marshal(events.Envelope{
+		Type:          eventType,
+		SchemaVersion: events.SchemaVersion,
+		EntityKey:     entityKey,
+		ActorMSP:      mspid,
+		TxID:          ctx.GetStub().GetTxID(),
+		Timestamp:     now,
+		PayloadHash:   sha256Hex(b),
+	})
+	if err != nil {
+		return err
+	}
+	if err := ctx.GetStub().SetEvent(eventType, envelope); err != nil {
+		return fmt.Errorf("failed to set event %s: %v", eventType, err)
+	}
+	return nil
+}
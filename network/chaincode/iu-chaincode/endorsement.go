@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/statebased"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+
+	"iu-chaincode/events"
+)
+
+// setKeyEndorsers requires peers of every given org to endorse future changes to key
+func setKeyEndorsers(ctx contractapi.TransactionContextInterface, key string, msps ...string) error {
+	ep, err := statebased.NewStateEP(nil)
+	if err != nil {
+		return err
+	}
+	unique := map[string]bool{}
+	for _, m := range msps {
+		unique[m] = true
+	}
+	orgs := make([]string, 0, len(unique))
+	for m := range unique {
+		orgs = append(orgs, m)
+	}
+	sort.Strings(orgs)
+	if err := ep.AddOrgs(statebased.RoleTypePeer, orgs...); err != nil {
+		return err
+	}
+	policy, err := ep.Policy()
+	if err != nil {
+		return err
+	}
+	if err := ctx.GetStub().SetStateValidationParameter(key, policy); err != nil {
+		return fmt.Errorf("failed to set endorsement policy for %s: %v", key, err)
+	}
+	return nil
+}
+
+// creditorOrg is the org that owns a creditor-side record: the MSP the
+// creditor institution is registered with, also when AdminMSP created the
+// record on the creditor's behalf
+func (s *IUContract) creditorOrg(ctx contractapi.TransactionContextInterface, creditorID string) (string, error) {
+	institution, err := s.GetInstitution(ctx, creditorID)
+	if err != nil {
+		return "", err
+	}
+	return institution.MSPID, nil
+}
+
+// EndorsementPolicy lists the orgs whose peers must endorse changes to a key
+type EndorsementPolicy struct {
+	Key  string   `json:"key"`
+	Orgs []string `json:"orgs"`
+}
+
+// GetEndorsementPolicy returns the key-level endorsement policy of a record.
+// An empty org list means only the chaincode-level policy applies.
+func (s *IUContract) GetEndorsementPolicy(ctx contractapi.TransactionContextInterface, key string) (*EndorsementPolicy, error) {
+	mspid, err := getMSPID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if mspid != "AdminMSP" {
+		return nil, fmt.Errorf("only AdminMSP can inspect endorsement policies")
+	}
+	exists, err := s.TransactionExists(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("key %s does not exist", key)
+	}
+	param, err := ctx.GetStub().GetStateValidationParameter(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get endorsement policy for %s: %v", key, err)
+	}
+	ep, err := statebased.NewStateEP(param)
+	if err != nil {
+		return nil, err
+	}
+	orgs := ep.ListOrgs()
+	sort.Strings(orgs)
+	return &EndorsementPolicy{Key: key, Orgs: orgs}, nil
+}
+
+// RotateEndorsementPolicy replaces the orgs that must endorse changes to a key.
+// The rotation itself is validated against the current policy, so the orgs
+// named there have to endorse it.
+func (s *IUContract) RotateEndorsementPolicy(ctx contractapi.TransactionContextInterface, key string, orgs []string) error {
+	mspid, err := getMSPID(ctx)
+	if err != nil {
+		return err
+	}
+	if mspid != "AdminMSP" {
+		return fmt.Errorf("only AdminMSP can rotate endorsement policies")
+	}
+	if len(orgs) == 0 {
+		return fmt.Errorf("at least one org is required")
+	}
+	current, err := s.GetEndorsementPolicy(ctx, key)
+	if err != nil {
+		return err
+	}
+	if err := setKeyEndorsers(ctx, key, orgs...); err != nil {
+		return err
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	if err := writeAuditRecord(ctx, key, "ROTATE_ENDORSEMENT_POLICY", mspid,
+		fmt.Sprintf("Endorsers changed from %v to %v", current.Orgs, orgs), "ROTATED", now); err != nil {
+		return err
+	}
+	// The key's value is unchanged, so the event names it in a list rather
+	// than as its entity, whose hash would have to match a new version
+	return emitEvent(ctx, events.EndorsementPolicyRotated, "", []string{key})
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+
+	"iu-chaincode/chaincodetest"
+	"iu-chaincode/events"
+)
+
+func TestEndorsementPolicy(t *testing.T) {
+	setup := func(t *testing.T, stub *chaincodetest.Stub) {
+		mustSubmit(t, stub, chaincodetest.Admin, createTx("TX1", "C1", "D1", 1000, "DEBIT"))
+		mustSubmit(t, stub, chaincodetest.Admin, func(ctx contractapi.TransactionContextInterface) error {
+			return ctx.GetStub().PutState("CONFIG_X", []byte("1"))
+		})
+	}
+	policy := func(key string, want ...string) ctxFunc {
+		return func(ctx contractapi.TransactionContextInterface) error {
+			p, err := contract.GetEndorsementPolicy(ctx, key)
+			if err == nil && !reflect.DeepEqual(p.Orgs, want) {
+				t.Errorf("orgs = %v, want %v", p.Orgs, want)
+			}
+			return err
+		}
+	}
+	rotate := func(key string, orgs ...string) ctxFunc {
+		return func(ctx contractapi.TransactionContextInterface) error {
+			return contract.RotateEndorsementPolicy(ctx, key, orgs)
+		}
+	}
+	runCases(t, setup, []txCase{
+		{name: "creditor and admin", id: chaincodetest.Admin, call: policy("TX1", "AdminMSP", "CreditorMSP")},
+		{name: "owned by the creditor's registered org", id: chaincodetest.Admin, call: policy("TX2", "AdminMSP", "OtherMSP"),
+			setup: func(t *testing.T, stub *chaincodetest.Stub) {
+				mustSubmit(t, stub, chaincodetest.Admin, registerInstitution("OTHER", "AAACO1234D", "OtherMSP"))
+				mustSubmit(t, stub, chaincodetest.Admin, createTx("TX2", "OTHER", "D1", 1000, "DEBIT"))
+			}},
+		{name: "chaincode-level only", id: chaincodetest.Admin, call: policy("CONFIG_X", []string{}...)},
+		{name: "creditor cannot inspect", id: chaincodetest.Creditor, call: policy("TX1"), wantErr: "only AdminMSP"},
+		{name: "missing key", id: chaincodetest.Admin, call: policy("TX9"), wantErr: "key TX9 does not exist"},
+		{name: "admin rotates", id: chaincodetest.Admin, call: rotate("TX1", "DebtorMSP", "AdminMSP"), event: events.EndorsementPolicyRotated,
+			check: func(t *testing.T, stub *chaincodetest.Stub) {
+				// TX1's value did not change, so the event does not name it as its entity
+				if env := lastEvent(t, stub); env.EntityKey != "" || env.PayloadHash != sha256Hex([]byte(`["TX1"]`)) {
+					t.Fatalf("unexpected event %+v", env)
+				}
+				mustSubmit(t, stub, chaincodetest.Admin, policy("TX1", "AdminMSP", "DebtorMSP"))
+				if len(stub.Keys("AUDIT_TX1_ROTATE_ENDORSEMENT_POLICY_")) != 1 {
+					t.Fatal("rotation not audited")
+				}
+			}},
+		{name: "no orgs", id: chaincodetest.Admin, call: rotate("TX1"), wantErr: "at least one org"},
+		{name: "debtor cannot rotate", id: chaincodetest.Debtor, call: rotate("TX1", "DebtorMSP"), wantErr: "only AdminMSP"},
+	})
+}
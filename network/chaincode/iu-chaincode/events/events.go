@@ -0,0 +1,117 @@
+// Package events defines the chaincode event that iu-chaincode emits from
+// every function that changes state. Fabric keeps one event per
+// transaction, so each transaction emits a single Envelope whose name is
+// its Type. The envelope names the changed record and carries a hash of the
+// value written to it rather than the value, so listeners never receive PII.
+// Off-chain listeners import this package to decode and filter events.
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// SchemaVersion is the version of Envelope emitted by the chaincode
+const SchemaVersion = 1
+
+// Transactions and accounts
+const (
+	LedgerInitialized            = "LEDGER_INITIALIZED"
+	TransactionCreated           = "TRANSACTION_CREATED"
+	TransactionProcessed         = "TRANSACTION_PROCESSED"
+	TransactionComplianceChecked = "TRANSACTION_COMPLIANCE_CHECKED"
+	TransactionsImported         = "TRANSACTIONS_IMPORTED"
+	AccountSuspended             = "ACCOUNT_SUSPENDED"
+)
+
+// Documents and KYC
+const (
+	DocumentSubmitted = "DOC_SUBMITTED"
+	KYCSubmitted      = "KYC_SUBMITTED"
+	KYCApproved       = "KYC_APPROVED"
+	KYCRejected       = "KYC_REJECTED"
+)
+
+// Defaults
+const (
+	DefaultFiled                = "DEFAULT_FILED"
+	DefaultConfirmed            = "DEFAULT_CONFIRMED"
+	DefaultDisputed             = "DEFAULT_DISPUTED"
+	DeemedAuthenticationApplied = "DEEMED_AUTHENTICATION_APPLIED"
+	DeemedAuthDaysSet           = "DEEMED_AUTH_DAYS_SET"
+)
+
+// Collateral, guarantees and consortium loans
+const (
+	CollateralRegistered        = "COLLATERAL_REGISTERED"
+	CollateralRevalued          = "COLLATERAL_REVALUED"
+	ChargeCreated               = "CHARGE_CREATED"
+	ChargeModified              = "CHARGE_MODIFIED"
+	ChargeSatisfied             = "CHARGE_SATISFIED"
+	GuaranteeRegistered         = "GUARANTEE_REGISTERED"
+	GuaranteeInvoked            = "GUARANTEE_INVOKED"
+	GuaranteeReleased           = "GUARANTEE_RELEASED"
+	ConsortiumLoanCreated       = "CONSORTIUM_LOAN_CREATED"
+	ConsortiumRepaymentRecorded = "CONSORTIUM_REPAYMENT_RECORDED"
+	ConsortiumDefaultAllocated  = "CONSORTIUM_DEFAULT_ALLOCATED"
+	ConsortiumClaimAllocated    = "CONSORTIUM_CLAIM_ALLOCATED"
+)
+
+// Insolvency
+const (
+	InsolvencyCaseRegistered = "INSOLVENCY_CASE_REGISTERED"
+	ClaimSubmitted           = "CLAIM_SUBMITTED"
+	ClaimAdmitted            = "CLAIM_ADMITTED"
+	ClaimRejected            = "CLAIM_REJECTED"
+)
+
+// Institution and borrower registry
+const (
+	InstitutionRegistered = "INSTITUTION_REGISTERED"
+	InstitutionSuspended  = "INSTITUTION_SUSPENDED"
+	InstitutionReinstated = "INSTITUTION_REINSTATED"
+	BorrowerRegistered    = "BORROWER_REGISTERED"
+	BorrowerSuspended     = "BORROWER_SUSPENDED"
+	BorrowerReinstated    = "BORROWER_REINSTATED"
+	BorrowerClientBound   = "BORROWER_CLIENT_BOUND"
+)
+
+// Audit and administration
+const (
+	AuditEventRecorded       = "AUDIT_EVENT_RECORDED"
+	AuditRecordsBackfilled   = "AUDIT_RECORDS_BACKFILLED"
+	MirrorVerified           = "MIRROR_VERIFIED"
+	MirrorMismatch           = "MIRROR_MISMATCH"
+	EndorsementPolicyRotated = "ENDORSEMENT_POLICY_ROTATED"
+	SnapshotRootCommitted    = "SNAPSHOT_ROOT_COMMITTED"
+)
+
+// Envelope is the payload of every iu-chaincode event. EntityKey is the
+// world state key of the record the transaction changed, and PayloadHash is
+// the hex SHA-256 of the value written to it. Transactions that change many
+// records at once leave EntityKey empty and hash the JSON list of their keys.
+type Envelope struct {
+	Type          string    `json:"type"`
+	SchemaVersion int       `json:"schemaVersion"`
+	EntityKey     string    `json:"entityKey"`
+	ActorMSP      string    `json:"actorMsp"`
+	TxID          string    `json:"txId"`
+	Timestamp     time.Time `json:"timestamp"`
+	PayloadHash   string    `json:"payloadHash"`
+}
+
+// Decode parses an event payload, rejecting envelopes of a newer schema
+func Decode(payload []byte) (*Envelope, error) {
+	var env Envelope
+	if err := json.Unmarshal(payload, &env); err != nil {
+		return nil, fmt.Errorf("invalid event envelope: %v", err)
+	}
+	if env.SchemaVersion < 1 || env.SchemaVersion > SchemaVersion {
+		return nil, fmt.Errorf("unsupported event schema version %d", env.SchemaVersion)
+	}
+	if env.Type == "" {
+		return nil, fmt.Errorf("event envelope has no type")
+	}
+	return &env, nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// RecordVersion is one committed version of a ledger key with its integrity hash
+type RecordVersion struct {
+	TxID      string    `json:"txId"`
+	Timestamp time.Time `json:"timestamp"`
+	IsDelete  bool      `json:"isDelete"`
+	Value     string    `json:"value"`
+	ValueHash string    `json:"valueHash"`
+}
+
+// RecordEvidence carries the exact stored bytes and history of a record for
+// electronic evidence certification
+type RecordEvidence struct {
+	RecordType string          `json:"recordType"` // TRANSACTION, DOCUMENT, DEFAULT
+	RecordID   string          `json:"recordId"`
+	Key        string          `json:"key"`
+	Value      string          `json:"value"`
+	ValueHash  string          `json:"valueHash"`
+	History    []RecordVersion `json:"history"`
+}
+
+func evidenceKey(recordType, id string) (string, error) {
+	switch recordType {
+	case "TRANSACTION":
+		return id, nil
+	case "DOCUMENT":
+		return fmt.Sprintf("DOC_%s", id), nil
+	case "DEFAULT":
+		return defaultKey(id), nil
+	}
+	return "", fmt.Errorf("unsupported record type %s (want TRANSACTION, DOCUMENT or DEFAULT)", recordType)
+}
+
+func sha256Hex(b []byte) string {
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:])
+}
+
+// GetRecordEvidence returns the raw state value and full history of a record
+func (s *IUContract) GetRecordEvidence(ctx contractapi.TransactionContextInterface, recordType, id string) (*RecordEvidence, error) {
+	key, err := evidenceKey(recordType, id)
+	if err != nil {
+		return nil, err
+	}
+	val, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if val == nil {
+		return nil, fmt.Errorf("%s %s does not exist", recordType, id)
+	}
+
+	history, err := keyHistory(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RecordEvidence{
+		RecordType: recordType,
+		RecordID:   id,
+		Key:        key,
+		Value:      string(val),
+		ValueHash:  sha256Hex(val),
+		History:    history,
+	}, nil
+}
+
+// keyHistory returns every committed version of key with its value hash
+func keyHistory(ctx contractapi.TransactionContextInterface, key string) ([]RecordVersion, error) {
+	resultsIterator, err := ctx.GetStub().GetHistoryForKey(key)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	history := []RecordVersion{}
+	for resultsIterator.HasNext() {
+		response, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		version := RecordVersion{
+			TxID:     response.TxId,
+			IsDelete: response.IsDelete,
+			Value:    string(response.Value),
+		}
+		if response.Timestamp != nil {
+			version.Timestamp = response.Timestamp.AsTime()
+		}
+		if !response.IsDelete {
+			version.ValueHash = sha256Hex(response.Value)
+		}
+		history = append(history, version)
+	}
+
+	return history, nil
+}
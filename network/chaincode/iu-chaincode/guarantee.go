@@ -0,0 +1,393 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+
+	"iu-chaincode/events"
+)
+
+// Guarantee types; a co-borrower is jointly liable rather than a surety
+const (
+	GuaranteeTypePersonal   = "PERSONAL"
+	GuaranteeTypeCorporate  = "CORPORATE"
+	GuaranteeTypeCoBorrower = "CO_BORROWER"
+)
+
+// Guarantee statuses
+const (
+	GuaranteeStatusActive   = "ACTIVE"
+	GuaranteeStatusInvoked  = "INVOKED"
+	GuaranteeStatusReleased = "RELEASED"
+)
+
+// Indexes from a loan and from a party to their guarantees
+const (
+	loanGuaranteeIndex  = "LOAN_GUARANTEE"
+	partyGuaranteeIndex = "PARTY_GUARANTEE"
+)
+
+// Guarantee links a guarantor or co-borrower to a loan with a cap on liability
+type Guarantee struct {
+	ID             string    `json:"guaranteeId"`
+	LoanID         string    `json:"loanId"`
+	BorrowerID     string    `json:"borrowerId"`
+	GuarantorID    string    `json:"guarantorId"`
+	Type           string    `json:"type"` // PERSONAL, CORPORATE, CO_BORROWER
+	CreditorID     string    `json:"creditorId"`
+	CapAmount      float64   `json:"capAmount"`
+	Currency       string    `json:"currency"`
+	Status         string    `json:"status"` // ACTIVE, INVOKED, RELEASED
+	InvocationDate string    `json:"invocationDate"`
+	InvokedAmount  float64   `json:"invokedAmount"`
+	DefaultID      string    `json:"defaultId"`
+	CreatedBy      string    `json:"createdBy"`
+	CreatedAt      time.Time `json:"createdAt"`
+}
+
+// PartyExposure sums what a party owes directly and as guarantor
+type PartyExposure struct {
+	PartyID            string      `json:"partyId"`
+	DirectExposure     float64     `json:"directExposure"`
+	CoBorrowerExposure float64     `json:"coBorrowerExposure"`
+	GuaranteedExposure float64     `json:"guaranteedExposure"`
+	TotalExposure      float64     `json:"totalExposure"`
+	Guarantees         []Guarantee `json:"guarantees"`
+}
+
+func guaranteeKey(id string) string {
+	return fmt.Sprintf("GUARANTEE_%s", id)
+}
+
+// RegisterGuarantee records a personal or corporate guarantee, or a co-borrower, on a loan
+func (s *IUContract) RegisterGuarantee(ctx contractapi.TransactionContextInterface, id, loanID, borrowerID, guarantorID, guaranteeType, creditorID string, capAmount float64, currency string) error {
+	mspid, err := assertLender(ctx, "register a guarantee")
+	if err != nil {
+		return err
+	}
+	if id == "" || loanID == "" || borrowerID == "" || guarantorID == "" || creditorID == "" {
+		return fmt.Errorf("id, loanID, borrowerID, guarantorID and creditorID are required")
+	}
+	if guaranteeType != GuaranteeTypePersonal && guaranteeType != GuaranteeTypeCorporate && guaranteeType != GuaranteeTypeCoBorrower {
+		return fmt.Errorf("invalid guarantee type %s (want PERSONAL, CORPORATE or CO_BORROWER)", guaranteeType)
+	}
+	if guarantorID == borrowerID {
+		return fmt.Errorf("guarantor cannot be the borrower")
+	}
+	if capAmount <= 0 {
+		return fmt.Errorf("cap amount must be positive")
+	}
+	if err := s.requireCreditor(ctx, creditorID, mspid); err != nil {
+		return err
+	}
+	if err := s.requireDebtor(ctx, borrowerID, false); err != nil {
+		return err
+	}
+	if err := s.requireParty(ctx, guarantorID); err != nil {
+		return err
+	}
+	exists, err := s.TransactionExists(ctx, guaranteeKey(id))
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("guarantee %s already exists", id)
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	guarantee := Guarantee{
+		ID:          id,
+		LoanID:      loanID,
+		BorrowerID:  borrowerID,
+		GuarantorID: guarantorID,
+		Type:        guaranteeType,
+		CreditorID:  creditorID,
+		CapAmount:   capAmount,
+		Currency:    currency,
+		Status:      GuaranteeStatusActive,
+		CreatedBy:   mspid,
+		CreatedAt:   now,
+	}
+	if err := putJSON(ctx, guaranteeKey(id), guarantee); err != nil {
+		return err
+	}
+	for _, index := range [][]string{{loanGuaranteeIndex, loanID, id}, {partyGuaranteeIndex, guarantorID, id}} {
+		indexKey, err := ctx.GetStub().CreateCompositeKey(index[0], index[1:])
+		if err != nil {
+			return err
+		}
+		if err := ctx.GetStub().PutState(indexKey, []byte{0x00}); err != nil {
+			return err
+		}
+	}
+
+	if err := writeAuditRecord(ctx, id, "REGISTER_GUARANTEE", mspid,
+		fmt.Sprintf("%s guarantee by %s for loan %s of %s capped at %f %s", guaranteeType, guarantorID, loanID, borrowerID, capAmount, currency),
+		GuaranteeStatusActive, now); err != nil {
+		return err
+	}
+	return emitEvent(ctx, events.GuaranteeRegistered, guaranteeKey(id), guarantee)
+}
+
+// GetGuarantee returns the guarantee with given id
+func (s *IUContract) GetGuarantee(ctx contractapi.TransactionContextInterface, id string) (*Guarantee, error) {
+	val, err := ctx.GetStub().GetState(guaranteeKey(id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if val == nil {
+		return nil, fmt.Errorf("guarantee %s does not exist", id)
+	}
+	var guarantee Guarantee
+	if err := json.Unmarshal(val, &guarantee); err != nil {
+		return nil, err
+	}
+	return &guarantee, nil
+}
+
+func (s *IUContract) guaranteesByIndex(ctx contractapi.TransactionContextInterface, index, id string) ([]Guarantee, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(index, []string{id})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	guarantees := []Guarantee{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		_, attrs, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil {
+			return nil, err
+		}
+		guarantee, err := s.GetGuarantee(ctx, attrs[1])
+		if err != nil {
+			return nil, err
+		}
+		guarantees = append(guarantees, *guarantee)
+	}
+	return guarantees, nil
+}
+
+// GetLoanGuarantees lists the guarantees and co-borrowers on a loan
+func (s *IUContract) GetLoanGuarantees(ctx contractapi.TransactionContextInterface, loanID string) ([]Guarantee, error) {
+	return s.guaranteesByIndex(ctx, loanGuaranteeIndex, loanID)
+}
+
+// loanGuarantors returns the parties with an active guarantee on the loan, for naming in default filings
+func (s *IUContract) loanGuarantors(ctx contractapi.TransactionContextInterface, loanID string) ([]string, error) {
+	guarantees, err := s.GetLoanGuarantees(ctx, loanID)
+	if err != nil {
+		return nil, err
+	}
+	guarantors := []string{}
+	for _, g := range guarantees {
+		if g.Status == GuaranteeStatusActive && g.Type != GuaranteeTypeCoBorrower {
+			guarantors = append(guarantors, g.GuarantorID)
+		}
+	}
+	return guarantors, nil
+}
+
+// authenticatedDefault returns the latest filed default of a borrower on a
+// loan that is authenticated or deemed authenticated, or nil if there is none
+func authenticatedDefault(ctx contractapi.TransactionContextInterface, loanID, borrowerID string) (*DefaultRecord, error) {
+	query := fmt.Sprintf(`{"selector":{"loanId":"%s","debtorId":"%s","authStatus":{"$in":["%s","%s"]}}}`,
+		loanID, borrowerID, AuthStatusAuthenticated, AuthStatusDeemedAuthenticated)
+	resultsIterator, err := ctx.GetStub().GetQueryResult(query)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	var latest *DefaultRecord
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		var record DefaultRecord
+		if err := json.Unmarshal(queryResponse.Value, &record); err != nil {
+			continue
+		}
+		if latest == nil || record.FiledAt.After(latest.FiledAt) {
+			latest = &record
+		}
+	}
+	return latest, nil
+}
+
+// InvokeGuarantee invokes a guarantor's guarantee on a loan once the
+// borrower's default on it is authenticated, and files a linked default
+// against the guarantor for the defaulted amount up to the guarantee's cap.
+// The latest authenticated default is used when there are several.
+func (s *IUContract) InvokeGuarantee(ctx contractapi.TransactionContextInterface, loanID, guarantorID string) (string, error) {
+	mspid, err := assertLender(ctx, "invoke a guarantee")
+	if err != nil {
+		return "", err
+	}
+	guarantees, err := s.GetLoanGuarantees(ctx, loanID)
+	if err != nil {
+		return "", err
+	}
+	var guarantee *Guarantee
+	for i := range guarantees {
+		g := &guarantees[i]
+		if g.GuarantorID == guarantorID && g.Type != GuaranteeTypeCoBorrower && (guarantee == nil || g.Status == GuaranteeStatusActive) {
+			guarantee = g
+		}
+	}
+	if guarantee == nil {
+		return "", fmt.Errorf("no guarantee by %s on loan %s", guarantorID, loanID)
+	}
+	if guarantee.Status != GuaranteeStatusActive {
+		return "", fmt.Errorf("guarantee %s is %s", guarantee.ID, guarantee.Status)
+	}
+
+	record, err := authenticatedDefault(ctx, loanID, guarantee.BorrowerID)
+	if err != nil {
+		return "", err
+	}
+	if record == nil {
+		return "", fmt.Errorf("borrower %s has no authenticated default on loan %s: only an authenticated default can invoke a guarantee", guarantee.BorrowerID, loanID)
+	}
+	defaultID := record.ID
+	amount := math.Min(guarantee.CapAmount, record.Amount)
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return "", err
+	}
+	invokedID := fmt.Sprintf("%s_INVOKED", guarantee.ID)
+	if err := s.createDefault(ctx, &DefaultRecord{
+		ID:          invokedID,
+		LoanID:      loanID,
+		CreditorID:  guarantee.CreditorID,
+		DebtorID:    guarantorID,
+		Amount:      amount,
+		Currency:    guarantee.Currency,
+		DefaultDate: now.UTC().Format(dateLayout),
+		Details:     fmt.Sprintf("Invocation of %s guarantee %s for loan %s of %s on default %s", guarantee.Type, guarantee.ID, loanID, guarantee.BorrowerID, defaultID),
+		Guarantors:  []string{},
+		GuaranteeID: guarantee.ID,
+		FiledBy:     mspid,
+	}); err != nil {
+		return "", err
+	}
+
+	guarantee.Status = GuaranteeStatusInvoked
+	guarantee.InvocationDate = now.UTC().Format(dateLayout)
+	guarantee.InvokedAmount = amount
+	guarantee.DefaultID = invokedID
+	if err := putJSON(ctx, guaranteeKey(guarantee.ID), guarantee); err != nil {
+		return "", err
+	}
+	if err := writeAuditRecord(ctx, guarantee.ID, "INVOKE_GUARANTEE", mspid,
+		fmt.Sprintf("Guarantee invoked for %f %s on default %s, default %s filed against %s", amount, guarantee.Currency, defaultID, invokedID, guarantorID),
+		GuaranteeStatusInvoked, now); err != nil {
+		return "", err
+	}
+	if err := emitEvent(ctx, events.GuaranteeInvoked, guaranteeKey(guarantee.ID), guarantee); err != nil {
+		return "", err
+	}
+	return invokedID, nil
+}
+
+// ReleaseGuarantee discharges an active guarantee, for example once the loan is repaid
+func (s *IUContract) ReleaseGuarantee(ctx contractapi.TransactionContextInterface, id, remarks string) error {
+	mspid, err := assertLender(ctx, "release a guarantee")
+	if err != nil {
+		return err
+	}
+	guarantee, err := s.GetGuarantee(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.requireCreditor(ctx, guarantee.CreditorID, mspid); err != nil {
+		return err
+	}
+	if guarantee.Status != GuaranteeStatusActive {
+		return fmt.Errorf("guarantee %s is %s", id, guarantee.Status)
+	}
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	guarantee.Status = GuaranteeStatusReleased
+	if err := putJSON(ctx, guaranteeKey(id), guarantee); err != nil {
+		return err
+	}
+	if err := writeAuditRecord(ctx, id, "RELEASE_GUARANTEE", mspid, fmt.Sprintf("Guarantee released: %s", remarks), GuaranteeStatusReleased, now); err != nil {
+		return err
+	}
+	return emitEvent(ctx, events.GuaranteeReleased, guaranteeKey(id), guarantee)
+}
+
+// directExposure nets disbursements (DEBIT) against repayments (CREDIT) for a debtor
+func directExposure(ctx contractapi.TransactionContextInterface, partyID string) (float64, error) {
+	query := fmt.Sprintf(`{"selector":{"debtorId":"%s","transactionType":{"$exists":true}}}`, partyID)
+	resultsIterator, err := ctx.GetStub().GetQueryResult(query)
+	if err != nil {
+		return 0, err
+	}
+	defer resultsIterator.Close()
+
+	exposure := 0.0
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return 0, err
+		}
+		var tx Transaction
+		if err := json.Unmarshal(queryResponse.Value, &tx); err != nil || tx.Status == "FAILED" {
+			continue
+		}
+		switch tx.TransactionType {
+		case "DEBIT":
+			exposure += tx.Amount
+		case "CREDIT":
+			exposure -= tx.Amount
+		}
+	}
+	return math.Max(exposure, 0), nil
+}
+
+// GetExposureByParty sums a party's direct exposure and the exposure it carries as guarantor or co-borrower
+func (s *IUContract) GetExposureByParty(ctx contractapi.TransactionContextInterface, partyID string) (*PartyExposure, error) {
+	if partyID == "" {
+		return nil, fmt.Errorf("partyID is required")
+	}
+	direct, err := directExposure(ctx, partyID)
+	if err != nil {
+		return nil, err
+	}
+	guarantees, err := s.guaranteesByIndex(ctx, partyGuaranteeIndex, partyID)
+	if err != nil {
+		return nil, err
+	}
+
+	exposure := &PartyExposure{PartyID: partyID, DirectExposure: direct, Guarantees: guarantees}
+	for _, g := range guarantees {
+		switch {
+		case g.Status == GuaranteeStatusReleased:
+		case g.Type == GuaranteeTypeCoBorrower:
+			exposure.CoBorrowerExposure += g.CapAmount
+		case g.Status == GuaranteeStatusInvoked:
+			// the invoked amount is already a default filed against the guarantor
+			exposure.GuaranteedExposure += g.InvokedAmount
+		default:
+			exposure.GuaranteedExposure += g.CapAmount
+		}
+	}
+	exposure.TotalExposure = exposure.DirectExposure + exposure.CoBorrowerExposure + exposure.GuaranteedExposure
+	return exposure, nil
+}
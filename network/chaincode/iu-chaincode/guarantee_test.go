@@ -0,0 +1,212 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+
+	"iu-chaincode/chaincodetest"
+	"iu-chaincode/events"
+)
+
+func registerGuarantee(id, loanID, guarantorID, guaranteeType string, capAmount float64) ctxFunc {
+	return func(ctx contractapi.TransactionContextInterface) error {
+		return contract.RegisterGuarantee(ctx, id, loanID, "D1", guarantorID, guaranteeType, "C1", capAmount, "INR")
+	}
+}
+
+func invokeGuarantee(loanID, guarantorID, wantDefault string) ctxFunc {
+	return func(ctx contractapi.TransactionContextInterface) error {
+		defaultID, err := contract.InvokeGuarantee(ctx, loanID, guarantorID)
+		if err == nil && defaultID != wantDefault {
+			return fmt.Errorf("default id = %s, want %s", defaultID, wantDefault)
+		}
+		return err
+	}
+}
+
+func guaranteeStatus(id, want string) func(t *testing.T, stub *chaincodetest.Stub) {
+	return func(t *testing.T, stub *chaincodetest.Stub) {
+		var g Guarantee
+		readState(t, stub, guaranteeKey(id), &g)
+		if g.Status != want {
+			t.Fatalf("status = %s, want %s", g.Status, want)
+		}
+	}
+}
+
+func TestRegisterGuarantee(t *testing.T) {
+	setup := func(t *testing.T, stub *chaincodetest.Stub) {
+		mustSubmit(t, stub, chaincodetest.Creditor, registerGuarantee("G1", "L1", "P1", GuaranteeTypePersonal, 1000000))
+	}
+	guaranteeBetween := func(borrowerID, creditorID string) ctxFunc {
+		return func(ctx contractapi.TransactionContextInterface) error {
+			return contract.RegisterGuarantee(ctx, "G2", "L1", borrowerID, "P2", GuaranteeTypePersonal, creditorID, 1, "INR")
+		}
+	}
+	runCases(t, setup, []txCase{
+		{name: "creditor registers", id: chaincodetest.Creditor, call: registerGuarantee("G2", "L1", "CO1", GuaranteeTypeCorporate, 2000000),
+			event: events.GuaranteeRegistered, check: guaranteeStatus("G2", GuaranteeStatusActive)},
+		{name: "debtor cannot register", id: chaincodetest.Debtor, call: registerGuarantee("G2", "L1", "P2", GuaranteeTypePersonal, 1), wantErr: "only CreditorMSP or AdminMSP"},
+		{name: "invalid type", id: chaincodetest.Creditor, call: registerGuarantee("G2", "L1", "P2", "SURETY", 1), wantErr: "invalid guarantee type"},
+		{name: "borrower as guarantor", id: chaincodetest.Creditor, call: registerGuarantee("G2", "L1", "D1", GuaranteeTypePersonal, 1), wantErr: "cannot be the borrower"},
+		{name: "non-positive cap", id: chaincodetest.Creditor, call: registerGuarantee("G2", "L1", "P2", GuaranteeTypePersonal, 0), wantErr: "must be positive"},
+		{name: "duplicate", id: chaincodetest.Creditor, call: registerGuarantee("G1", "L1", "P2", GuaranteeTypePersonal, 1), wantErr: "already exists"},
+		{name: "unregistered guarantor", id: chaincodetest.Creditor, call: registerGuarantee("G2", "L1", "X9", GuaranteeTypePersonal, 1),
+			wantErr: "party X9 is not a registered borrower or institution"},
+		{name: "unregistered borrower", id: chaincodetest.Creditor, call: guaranteeBetween("D9", "C1"), wantErr: "debtor D9 is not a registered borrower or institution"},
+		{name: "unregistered creditor", id: chaincodetest.Creditor, call: guaranteeBetween("D1", "C9"), wantErr: "creditor C9 is not a registered institution"},
+		{name: "creditor of another MSP", id: chaincodetest.Creditor, call: guaranteeBetween("D1", "OTHER"),
+			setup: admin(registerInstitution("OTHER", "AAACO1234D", "OtherMSP")), wantErr: "creditor OTHER is bound to OtherMSP, not CreditorMSP"},
+		{name: "admin registers for any creditor", id: chaincodetest.Admin, call: guaranteeBetween("D1", "OTHER"),
+			setup: admin(registerInstitution("OTHER", "AAACO1234D", "OtherMSP"))},
+		{name: "guarantors named in defaults", id: chaincodetest.Creditor, call: fileDefault("DEF1", "L1", "D1"),
+			setup: func(t *testing.T, stub *chaincodetest.Stub) {
+				mustSubmit(t, stub, chaincodetest.Creditor, registerGuarantee("G2", "L1", "CB1", GuaranteeTypeCoBorrower, 1))
+			},
+			check: func(t *testing.T, stub *chaincodetest.Stub) {
+				var record DefaultRecord
+				readState(t, stub, defaultKey("DEF1"), &record)
+				if len(record.Guarantors) != 1 || record.Guarantors[0] != "P1" {
+					t.Fatalf("guarantors = %v, want [P1]", record.Guarantors)
+				}
+			}},
+		{name: "GetGuarantee", id: chaincodetest.Debtor, call: func(ctx contractapi.TransactionContextInterface) error {
+			g, err := contract.GetGuarantee(ctx, "G1")
+			if err == nil && g.GuarantorID != "P1" {
+				t.Errorf("guarantorId = %s", g.GuarantorID)
+			}
+			return err
+		}},
+		{name: "GetGuarantee missing", id: chaincodetest.Debtor, wantErr: "does not exist", call: func(ctx contractapi.TransactionContextInterface) error {
+			_, err := contract.GetGuarantee(ctx, "G9")
+			return err
+		}},
+		{name: "GetLoanGuarantees", id: chaincodetest.Debtor, call: func(ctx contractapi.TransactionContextInterface) error {
+			gs, err := contract.GetLoanGuarantees(ctx, "L1")
+			other, _ := contract.GetLoanGuarantees(ctx, "L2")
+			if len(gs) != 1 || len(other) != 0 {
+				t.Errorf("guarantees = %d and %d, want 1 and 0", len(gs), len(other))
+			}
+			return err
+		}},
+	})
+}
+
+func TestInvokeAndReleaseGuarantee(t *testing.T) {
+	confirm := func(id string) ctxFunc {
+		return func(ctx contractapi.TransactionContextInterface) error {
+			return contract.ConfirmDefault(ctx, id)
+		}
+	}
+	setup := func(t *testing.T, stub *chaincodetest.Stub) {
+		mustSubmit(t, stub, chaincodetest.Creditor, registerGuarantee("G1", "L1", "P1", GuaranteeTypePersonal, 1000000))
+		mustSubmit(t, stub, chaincodetest.Creditor, registerGuarantee("G2", "L1", "CB1", GuaranteeTypeCoBorrower, 1000000))
+		mustSubmit(t, stub, chaincodetest.Creditor, fileDefault("DEF1", "L1", "D1"))
+		mustSubmit(t, stub, chaincodetest.Debtor, confirm("DEF1"))
+	}
+	release := func(id string) ctxFunc {
+		return func(ctx contractapi.TransactionContextInterface) error {
+			return contract.ReleaseGuarantee(ctx, id, "loan repaid")
+		}
+	}
+	invokedDefault := func(id string, amount float64) func(t *testing.T, stub *chaincodetest.Stub) {
+		return func(t *testing.T, stub *chaincodetest.Stub) {
+			var g Guarantee
+			readState(t, stub, guaranteeKey(id), &g)
+			if g.Status != GuaranteeStatusInvoked || g.InvokedAmount != amount {
+				t.Fatalf("unexpected guarantee %+v", g)
+			}
+			var record DefaultRecord
+			readState(t, stub, defaultKey(id+"_INVOKED"), &record)
+			if record.DebtorID != "P1" || record.GuaranteeID != id || record.Amount != amount || record.AuthStatus != AuthStatusPending {
+				t.Fatalf("unexpected default %+v", record)
+			}
+		}
+	}
+	runCases(t, setup, []txCase{
+		{name: "creditor invokes for the defaulted amount", id: chaincodetest.Creditor, call: invokeGuarantee("L1", "P1", "G1_INVOKED"), event: events.GuaranteeInvoked,
+			check: invokedDefault("G1", 250000)},
+		{name: "invoked amount capped", id: chaincodetest.Creditor, call: invokeGuarantee("L1", "P1", "G1B_INVOKED"), check: invokedDefault("G1B", 100000),
+			setup: func(t *testing.T, stub *chaincodetest.Stub) {
+				mustSubmit(t, stub, chaincodetest.Creditor, release("G1"))
+				mustSubmit(t, stub, chaincodetest.Creditor, registerGuarantee("G1B", "L1", "P1", GuaranteeTypePersonal, 100000))
+			}},
+		{name: "latest authenticated default", id: chaincodetest.Creditor, call: invokeGuarantee("L1", "P1", "G1_INVOKED"), check: invokedDefault("G1", 300000),
+			setup: func(t *testing.T, stub *chaincodetest.Stub) {
+				mustSubmit(t, stub, chaincodetest.Creditor, func(ctx contractapi.TransactionContextInterface) error {
+					return contract.FileDefault(ctx, "DEF2", "L1", "C1", "D1", 300000, "INR", "2024-03-31", "90 days past due")
+				})
+				mustSubmit(t, stub, chaincodetest.Debtor, confirm("DEF2"))
+				mustSubmit(t, stub, chaincodetest.Creditor, fileDefault("DEF3", "L1", "D1"))
+			}},
+		{name: "no authenticated default", id: chaincodetest.Creditor, call: invokeGuarantee("L2", "P2", ""),
+			wantErr: "borrower D1 has no authenticated default on loan L2: only an authenticated default can invoke a guarantee",
+			setup: func(t *testing.T, stub *chaincodetest.Stub) {
+				mustSubmit(t, stub, chaincodetest.Creditor, registerGuarantee("G3", "L2", "P2", GuaranteeTypePersonal, 1000000))
+				mustSubmit(t, stub, chaincodetest.Creditor, fileDefault("DEF2", "L2", "D1"))
+				mustSubmit(t, stub, chaincodetest.Creditor, fileDefault("DEF3", "L2", "D1"))
+				mustSubmit(t, stub, chaincodetest.Debtor, func(ctx contractapi.TransactionContextInterface) error {
+					return contract.DisputeDefault(ctx, "DEF3", "repaid", nil)
+				})
+				mustSubmit(t, stub, chaincodetest.Creditor, fileDefault("DEF4", "L2", "D2"))
+				mustSubmit(t, stub, chaincodetest.Debtor, confirm("DEF4"))
+			}},
+		{name: "co-borrower is not a guarantee", id: chaincodetest.Creditor, call: invokeGuarantee("L1", "CB1", ""), wantErr: "no guarantee by CB1"},
+		{name: "invoked twice", id: chaincodetest.Creditor, call: invokeGuarantee("L1", "P1", ""), wantErr: "guarantee G1 is INVOKED",
+			setup: func(t *testing.T, stub *chaincodetest.Stub) {
+				mustSubmit(t, stub, chaincodetest.Creditor, invokeGuarantee("L1", "P1", "G1_INVOKED"))
+			}},
+		{name: "debtor cannot invoke", id: chaincodetest.Debtor, call: invokeGuarantee("L1", "P1", ""), wantErr: "only CreditorMSP or AdminMSP"},
+		{name: "guarantor under moratorium", id: chaincodetest.Creditor, call: invokeGuarantee("L1", "P1", ""), wantErr: "moratorium in force for debtor P1",
+			setup: func(t *testing.T, stub *chaincodetest.Stub) {
+				mustSubmit(t, stub, chaincodetest.Admin, registerCase("CASE1", "P1", "2024-03-01", ""))
+			}},
+		{name: "admin releases", id: chaincodetest.Admin, call: release("G1"), event: events.GuaranteeReleased, check: guaranteeStatus("G1", GuaranteeStatusReleased)},
+		{name: "creditor cannot release another creditor's guarantee", id: chaincodetest.Creditor, call: release("G3"),
+			wantErr: "creditor OTHER is bound to OtherMSP, not CreditorMSP",
+			setup: func(t *testing.T, stub *chaincodetest.Stub) {
+				mustSubmit(t, stub, chaincodetest.Admin, registerInstitution("OTHER", "AAACO1234D", "OtherMSP"))
+				mustSubmit(t, stub, chaincodetest.Admin, func(ctx contractapi.TransactionContextInterface) error {
+					return contract.RegisterGuarantee(ctx, "G3", "L1", "D1", "P2", GuaranteeTypePersonal, "OTHER", 1, "INR")
+				})
+			}},
+		{name: "released cannot be invoked", id: chaincodetest.Creditor, call: invokeGuarantee("L1", "P1", ""), wantErr: "guarantee G1 is RELEASED",
+			setup: func(t *testing.T, stub *chaincodetest.Stub) {
+				mustSubmit(t, stub, chaincodetest.Creditor, release("G1"))
+			}},
+		{name: "release missing", id: chaincodetest.Creditor, call: release("G9"), wantErr: "does not exist"},
+	})
+}
+
+func TestGetExposureByParty(t *testing.T) {
+	setup := func(t *testing.T, stub *chaincodetest.Stub) {
+		mustSubmit(t, stub, chaincodetest.Creditor, createTx("TX1", "C1", "P1", 500000, "DEBIT"))
+		mustSubmit(t, stub, chaincodetest.Creditor, createTx("TX2", "C1", "P1", 200000, "CREDIT"))
+		mustSubmit(t, stub, chaincodetest.Creditor, createTx("TX3", "C1", "P1", 900000, "DEBIT"))
+		mustSubmit(t, stub, chaincodetest.Admin, complianceCheck("TX3", false))
+		mustSubmit(t, stub, chaincodetest.Creditor, registerGuarantee("G1", "L1", "P1", GuaranteeTypePersonal, 1000000))
+		mustSubmit(t, stub, chaincodetest.Creditor, registerGuarantee("G2", "L2", "P1", GuaranteeTypeCoBorrower, 400000))
+		mustSubmit(t, stub, chaincodetest.Creditor, registerGuarantee("G3", "L3", "P1", GuaranteeTypeCorporate, 700000))
+		mustSubmit(t, stub, chaincodetest.Creditor, func(ctx contractapi.TransactionContextInterface) error {
+			return contract.ReleaseGuarantee(ctx, "G3", "")
+		})
+	}
+	runCases(t, setup, []txCase{
+		{name: "sums exposures", id: chaincodetest.Creditor, call: func(ctx contractapi.TransactionContextInterface) error {
+			e, err := contract.GetExposureByParty(ctx, "P1")
+			if err != nil {
+				return err
+			}
+			if e.DirectExposure != 300000 || e.CoBorrowerExposure != 400000 || e.GuaranteedExposure != 1000000 || e.TotalExposure != 1700000 || len(e.Guarantees) != 3 {
+				t.Errorf("unexpected exposure %+v", e)
+			}
+			return nil
+		}},
+		{name: "party required", id: chaincodetest.Creditor, wantErr: "partyID is required", call: func(ctx contractapi.TransactionContextInterface) error {
+			_, err := contract.GetExposureByParty(ctx, "")
+			return err
+		}},
+	})
+}
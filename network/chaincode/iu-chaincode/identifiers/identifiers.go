@@ -0,0 +1,268 @@
+// Package identifiers validates the Indian identifiers that iu-chaincode
+// records carry: PAN, GSTIN, CIN, LEI and IFSC. A typo in one of these
+// would be written permanently into default records, so each is checked for
+// structure and, where the scheme has one, its check character.
+//
+// Validators take raw input and return it normalized: upper case with all
+// whitespace removed. An invalid identifier is reported as an *Error.
+package identifiers
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Identifier kinds
+const (
+	PAN   = "PAN"
+	GSTIN = "GSTIN"
+	CIN   = "CIN"
+	LEI   = "LEI"
+	IFSC  = "IFSC"
+)
+
+// Error is why an identifier is invalid
+type Error struct {
+	Kind   string `json:"kind"`
+	Value  string `json:"value"` // normalized
+	Reason string `json:"reason"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("invalid %s %s: %s", e.Kind, e.Value, e.Reason)
+}
+
+func invalid(kind, value, format string, a ...interface{}) *Error {
+	return &Error{Kind: kind, Value: value, Reason: fmt.Sprintf(format, a...)}
+}
+
+// Normalize upper-cases id and removes all whitespace from it
+func Normalize(id string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return unicode.ToUpper(r)
+	}, id)
+}
+
+// Validate validates id as an identifier of kind
+func Validate(kind, id string) (string, error) {
+	switch kind {
+	case PAN:
+		return ValidatePAN(id)
+	case GSTIN:
+		return ValidateGSTIN(id)
+	case CIN:
+		return ValidateCIN(id)
+	case LEI:
+		return ValidateLEI(id)
+	case IFSC:
+		return ValidateIFSC(id)
+	}
+	return "", fmt.Errorf("unknown identifier kind %s", kind)
+}
+
+// pattern reports whether s matches a template in which 'A' stands for a
+// letter, '9' for a digit and '*' for either
+func pattern(s, template string) bool {
+	if len(s) != len(template) {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		letter, digit := c >= 'A' && c <= 'Z', c >= '0' && c <= '9'
+		switch template[i] {
+		case 'A':
+			if !letter {
+				return false
+			}
+		case '9':
+			if !digit {
+				return false
+			}
+		case '*':
+			if !letter && !digit {
+				return false
+			}
+		default:
+			if c != template[i] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// panHolderTypes are the holder types encoded in a PAN's fourth character
+var panHolderTypes = map[byte]string{
+	'A': "association of persons",
+	'B': "body of individuals",
+	'C': "company",
+	'F': "firm or LLP",
+	'G': "government",
+	'H': "Hindu undivided family",
+	'J': "artificial juridical person",
+	'L': "local authority",
+	'P': "individual",
+	'T': "trust",
+}
+
+// ValidatePAN validates a Permanent Account Number, AAAAA9999A. The
+// algorithm of its final check letter is not published by the Income Tax
+// Department, so the holder type in the fourth character is the only
+// internal consistency that can be checked.
+func ValidatePAN(id string) (string, error) {
+	id = Normalize(id)
+	if !pattern(id, "AAAAA9999A") {
+		return id, invalid(PAN, id, "want 5 letters, 4 digits and a letter")
+	}
+	if _, ok := panHolderTypes[id[3]]; !ok {
+		return id, invalid(PAN, id, "unknown holder type %c", id[3])
+	}
+	return id, nil
+}
+
+// PANHolderType returns the holder type of a valid PAN, such as
+// "individual" or "company"
+func PANHolderType(pan string) string {
+	pan = Normalize(pan)
+	if len(pan) < 4 {
+		return ""
+	}
+	return panHolderTypes[pan[3]]
+}
+
+// gstStateCode reports whether code is a state or union territory code of
+// a GSTIN; 97 is other territory and 99 the centre
+func gstStateCode(code string) bool {
+	n := int(code[0]-'0')*10 + int(code[1]-'0')
+	return (n >= 1 && n <= 38) || n == 97 || n == 99
+}
+
+const base36 = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+// gstinCheck computes the check character of the first 14 characters of a
+// GSTIN: a Luhn mod 36 over the base-36 values
+func gstinCheck(body string) byte {
+	sum := 0
+	for i := 0; i < len(body); i++ {
+		v := strings.IndexByte(base36, body[i]) * (1 + i%2)
+		sum += v/36 + v%36
+	}
+	return base36[(36-sum%36)%36]
+}
+
+// ValidateGSTIN validates a GST Identification Number: a state code, the
+// holder's PAN, an entity number, a default letter and a check character
+func ValidateGSTIN(id string) (string, error) {
+	id = Normalize(id)
+	if !pattern(id, "99AAAAA9999A***") {
+		return id, invalid(GSTIN, id, "want a 2-digit state code, a PAN and 3 alphanumeric characters")
+	}
+	if !gstStateCode(id[:2]) {
+		return id, invalid(GSTIN, id, "unknown state code %s", id[:2])
+	}
+	if _, err := ValidatePAN(id[2:12]); err != nil {
+		return id, invalid(GSTIN, id, "embedded PAN: %s", err.(*Error).Reason)
+	}
+	if id[12] == '0' {
+		return id, invalid(GSTIN, id, "entity number must be 1-9 or A-Z")
+	}
+	if want := gstinCheck(id[:14]); id[14] != want {
+		return id, invalid(GSTIN, id, "check character is %c, want %c", id[14], want)
+	}
+	return id, nil
+}
+
+// GSTINPAN returns the PAN embedded in a GSTIN
+func GSTINPAN(gstin string) string {
+	gstin = Normalize(gstin)
+	if len(gstin) < 12 {
+		return ""
+	}
+	return gstin[2:12]
+}
+
+// cinStates are the state codes of the Registrar of Companies in a CIN
+var cinStates = map[string]bool{
+	"AN": true, "AP": true, "AR": true, "AS": true, "BR": true, "CH": true, "CT": true, "CG": true,
+	"DD": true, "DL": true, "DN": true, "GA": true, "GJ": true, "HP": true, "HR": true, "JH": true,
+	"JK": true, "KA": true, "KL": true, "LA": true, "LD": true, "MH": true, "ML": true, "MN": true,
+	"MP": true, "MZ": true, "NL": true, "OR": true, "OD": true, "PB": true, "PY": true, "RJ": true,
+	"SK": true, "TG": true, "TN": true, "TR": true, "UP": true, "UR": true, "UT": true, "WB": true,
+}
+
+// cinOwnership are the ownership classes of a CIN
+var cinOwnership = map[string]bool{
+	"PLC": true, // public limited
+	"PTC": true, // private limited
+	"OPC": true, // one person company
+	"GOI": true, // Government of India
+	"SGC": true, // state government
+	"FLC": true, // financial lease company
+	"FTC": true, // subsidiary of a foreign company
+	"GAP": true, // guarantee and association, public
+	"GAT": true, // guarantee and association, private
+	"NPL": true, // not for profit
+	"ULL": true, // unlimited, public
+	"ULT": true, // unlimited, private
+}
+
+// ValidateCIN validates a Corporate Identity Number: listing status, NIC
+// industry code, state, year of incorporation, ownership class and
+// registration number. CINs have no check character.
+func ValidateCIN(id string) (string, error) {
+	id = Normalize(id)
+	if !pattern(id, "A99999AA9999AAA999999") {
+		return id, invalid(CIN, id, "want L or U, 5 digits, 2 letters, 4 digits, 3 letters and 6 digits")
+	}
+	if id[0] != 'L' && id[0] != 'U' {
+		return id, invalid(CIN, id, "listing status must be L or U")
+	}
+	if !cinStates[id[6:8]] {
+		return id, invalid(CIN, id, "unknown state %s", id[6:8])
+	}
+	if year := id[8:12]; year < "1850" || year > "2099" {
+		return id, invalid(CIN, id, "implausible year of incorporation %s", year)
+	}
+	if !cinOwnership[id[12:15]] {
+		return id, invalid(CIN, id, "unknown ownership class %s", id[12:15])
+	}
+	return id, nil
+}
+
+// ValidateLEI validates a Legal Entity Identifier: 18 alphanumeric
+// characters and 2 check digits under ISO 7064 mod 97-10 (ISO 17442)
+func ValidateLEI(id string) (string, error) {
+	id = Normalize(id)
+	if !pattern(id, "******************99") {
+		return id, invalid(LEI, id, "want 18 alphanumeric characters and 2 check digits")
+	}
+	rem := 0
+	for i := 0; i < len(id); i++ {
+		v := strings.IndexByte(base36, id[i])
+		if v >= 10 {
+			rem = rem * 100 % 97
+		} else {
+			rem = rem * 10 % 97
+		}
+		rem = (rem + v) % 97
+	}
+	if rem != 1 {
+		return id, invalid(LEI, id, "check digits do not verify")
+	}
+	return id, nil
+}
+
+// ValidateIFSC validates an Indian Financial System Code: a 4-letter bank
+// code, a zero and a 6-character branch code. IFSCs have no check
+// character.
+func ValidateIFSC(id string) (string, error) {
+	id = Normalize(id)
+	if !pattern(id, "AAAA0******") {
+		return id, invalid(IFSC, id, "want a 4-letter bank code, 0 and a 6-character branch code")
+	}
+	return id, nil
+}
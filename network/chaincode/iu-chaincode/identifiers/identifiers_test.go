@@ -0,0 +1,74 @@
+package identifiers
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		kind, id string
+		want     string // normalized value
+		wantErr  string
+	}{
+		{PAN, " abcpr 1234k\t", "ABCPR1234K", ""},
+		{PAN, "AAACB0001A", "AAACB0001A", ""},
+		{PAN, "ABCPR1234", "ABCPR1234", "want 5 letters"},
+		{PAN, "ABCPR12345", "ABCPR12345", "want 5 letters"},
+		{PAN, "ABCXR1234K", "ABCXR1234K", "unknown holder type X"},
+
+		{GSTIN, "27aapfu0939f1zv", "27AAPFU0939F1ZV", ""},
+		{GSTIN, "29AAGCB7383J1Z4", "29AAGCB7383J1Z4", ""},
+		{GSTIN, "27AAPFU0939F1ZW", "27AAPFU0939F1ZW", "check character is W, want V"},
+		{GSTIN, "27AAPFU9039F1ZV", "27AAPFU9039F1ZV", "check character"},
+		{GSTIN, "40AAPFU0939F1ZV", "40AAPFU0939F1ZV", "unknown state code 40"},
+		{GSTIN, "27AAPXU0939F1ZV", "27AAPXU0939F1ZV", "embedded PAN: unknown holder type X"},
+		{GSTIN, "27AAPFU0939F0ZV", "27AAPFU0939F0ZV", "entity number"},
+		{GSTIN, "27AAPFU0939F1Z", "27AAPFU0939F1Z", "want a 2-digit state code"},
+
+		{CIN, "u12345mh2010ptc123456", "U12345MH2010PTC123456", ""},
+		{CIN, "L65110MH1994PLC080618", "L65110MH1994PLC080618", ""},
+		{CIN, "X12345MH2010PTC123456", "X12345MH2010PTC123456", "listing status"},
+		{CIN, "U12345XX2010PTC123456", "U12345XX2010PTC123456", "unknown state XX"},
+		{CIN, "U12345MH1010PTC123456", "U12345MH1010PTC123456", "implausible year"},
+		{CIN, "U12345MH2010ABC123456", "U12345MH2010ABC123456", "unknown ownership class ABC"},
+		{CIN, "U1234MH2010PTC123456", "U1234MH2010PTC123456", "want L or U"},
+
+		{LEI, "5493001kjtiigc8y1r12", "5493001KJTIIGC8Y1R12", ""},
+		{LEI, "HWUPKR0MPOU8FGXBT394", "HWUPKR0MPOU8FGXBT394", ""},
+		{LEI, "HWUPKR0MPOU8FGXBT349", "HWUPKR0MPOU8FGXBT349", "check digits"},
+		{LEI, "HWUPKR0MPOU8FGXBT3X4", "HWUPKR0MPOU8FGXBT3X4", "want 18 alphanumeric"},
+
+		{IFSC, "sbin 0000 691", "SBIN0000691", ""},
+		{IFSC, "HDFC0ABC123", "HDFC0ABC123", ""},
+		{IFSC, "SBIN1000691", "SBIN1000691", "want a 4-letter bank code"},
+	}
+	for _, tt := range tests {
+		got, err := Validate(tt.kind, tt.id)
+		if got != tt.want {
+			t.Errorf("Validate(%s, %q) = %q, want %q", tt.kind, tt.id, got, tt.want)
+		}
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("Validate(%s, %q): %v", tt.kind, tt.id, err)
+			}
+			continue
+		}
+		e, ok := err.(*Error)
+		if !ok || e.Kind != tt.kind || e.Value != tt.want || !strings.Contains(e.Reason, tt.wantErr) {
+			t.Errorf("Validate(%s, %q) error = %v, want %q", tt.kind, tt.id, err, tt.wantErr)
+		}
+	}
+	if _, err := Validate("SSN", "1"); err == nil {
+		t.Error("unknown kind accepted")
+	}
+}
+
+func TestHolders(t *testing.T) {
+	if got := PANHolderType("abcpr1234k"); got != "individual" {
+		t.Errorf("PANHolderType = %q", got)
+	}
+	if got := GSTINPAN("27AAPFU0939F1ZV"); got != "AAPFU0939F" {
+		t.Errorf("GSTINPAN = %q", got)
+	}
+}
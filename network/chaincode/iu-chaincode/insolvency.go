@@ -0,0 +1,426 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+
+	"iu-chaincode/events"
+	"iu-chaincode/identifiers"
+)
+
+// Claim statuses during the corporate insolvency resolution process
+const (
+	ClaimStatusSubmitted = "SUBMITTED"
+	ClaimStatusAdmitted  = "ADMITTED"
+	ClaimStatusRejected  = "REJECTED"
+)
+
+const claimObjectType = "CLAIM"
+
+// claimsCollection holds Form C proofs of claim, readable by creditors and the IU
+const claimsCollection = "formc_claims"
+
+// claimsCollectionMembers are the orgs in claimsCollection's policy in
+// collections_config.json. A resolution professional must act through one
+// of them to read the claims they admit or reject.
+var claimsCollectionMembers = []string{"CreditorMSP", "AdminMSP"}
+
+const dateLayout = "2006-01-02"
+
+// InsolvencyCase represents a corporate debtor admitted into CIRP by the NCLT
+type InsolvencyCase struct {
+	CaseID           string    `json:"caseId"`
+	DebtorID         string    `json:"debtorId"`
+	CIN              string    `json:"cin"`
+	NCLTCaseID       string    `json:"ncltCaseId"`
+	AdmissionDate    string    `json:"admissionDate"`
+	RPName           string    `json:"rpName"`
+	RPRegistrationNo string    `json:"rpRegistrationNo"` // IBBI registration of the resolution professional
+	RPMSPID          string    `json:"rpMspId"`
+	RPClientID       string    `json:"rpClientId"` // x509 identity of the resolution professional
+	MoratoriumStart  string    `json:"moratoriumStart"`
+	MoratoriumEnd    string    `json:"moratoriumEnd"` // empty while the moratorium is open-ended
+	Status           string    `json:"status"`        // ADMITTED, CLOSED
+	RegisteredBy     string    `json:"registeredBy"`
+	RegisteredAt     time.Time `json:"registeredAt"`
+}
+
+// Claim is a financial creditor's proof of claim (Form C) in a CIRP
+type Claim struct {
+	CaseID         string    `json:"caseId"`
+	CreditorID     string    `json:"creditorId"`
+	CreditorMSP    string    `json:"creditorMsp"`
+	ClaimAmount    float64   `json:"claimAmount"`
+	AdmittedAmount float64   `json:"admittedAmount"`
+	FormCHash      string    `json:"formcHash"`
+	Status         string    `json:"status"` // SUBMITTED, ADMITTED, REJECTED
+	SubmittedAt    time.Time `json:"submittedAt"`
+	DecidedBy      string    `json:"decidedBy"`
+	DecidedAt      time.Time `json:"decidedAt"`
+	Remarks        string    `json:"remarks"`
+}
+
+// ClaimsRegisterEntry is a claim with the creditor's share of CoC voting
+type ClaimsRegisterEntry struct {
+	Claim
+	VotingShare float64 `json:"votingShare"` // percent of total admitted claims
+}
+
+// ClaimsRegister is the list of claims maintained by the resolution professional
+type ClaimsRegister struct {
+	Case          InsolvencyCase        `json:"case"`
+	Claims        []ClaimsRegisterEntry `json:"claims"`
+	TotalClaimed  float64               `json:"totalClaimed"`
+	TotalAdmitted float64               `json:"totalAdmitted"`
+}
+
+func insolvencyCaseKey(caseID string) string {
+	return fmt.Sprintf("CASE_%s", caseID)
+}
+
+func getClientID(ctx contractapi.TransactionContextInterface) (string, error) {
+	id, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return "", fmt.Errorf("failed to get client identity: %v", err)
+	}
+	return id, nil
+}
+
+// RegisterInsolvencyCase records the NCLT admission of a corporate debtor into CIRP.
+// The CIN is validated and stored normalized, and the resolution professional
+// must act through an org that can read the claims collection.
+func (s *IUContract) RegisterInsolvencyCase(ctx contractapi.TransactionContextInterface, caseID, debtorID, cin, ncltCaseID, admissionDate, rpName, rpRegistrationNo, rpMSPID, rpClientID, moratoriumStart, moratoriumEnd string) error {
+	mspid, err := getMSPID(ctx)
+	if err != nil {
+		return err
+	}
+	if mspid != "AdminMSP" {
+		return fmt.Errorf("only AdminMSP can register an insolvency case")
+	}
+	if caseID == "" || debtorID == "" || cin == "" || ncltCaseID == "" || rpMSPID == "" || rpClientID == "" {
+		return fmt.Errorf("caseID, debtorID, cin, ncltCaseID, rpMSPID and rpClientID are required")
+	}
+	checks := &identifierChecks{}
+	cin = checks.check("cin", identifiers.CIN, cin)
+	if err := checks.err(); err != nil {
+		return err
+	}
+	rpMember := false
+	for _, member := range claimsCollectionMembers {
+		rpMember = rpMember || member == rpMSPID
+	}
+	if !rpMember {
+		return fmt.Errorf("rpMSPID %s must be a member of the %s collection (%s) for the resolution professional to read the claims",
+			rpMSPID, claimsCollection, strings.Join(claimsCollectionMembers, ", "))
+	}
+	if err := s.requireDebtor(ctx, debtorID, false); err != nil {
+		return err
+	}
+	if _, err := time.Parse(dateLayout, admissionDate); err != nil {
+		return fmt.Errorf("invalid admissionDate: %v", err)
+	}
+	start, err := time.Parse(dateLayout, moratoriumStart)
+	if err != nil {
+		return fmt.Errorf("invalid moratoriumStart: %v", err)
+	}
+	if moratoriumEnd != "" {
+		end, err := time.Parse(dateLayout, moratoriumEnd)
+		if err != nil {
+			return fmt.Errorf("invalid moratoriumEnd: %v", err)
+		}
+		if end.Before(start) {
+			return fmt.Errorf("moratoriumEnd is before moratoriumStart")
+		}
+	}
+
+	key := insolvencyCaseKey(caseID)
+	exists, err := s.TransactionExists(ctx, key)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("insolvency case %s already exists", caseID)
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	c := InsolvencyCase{
+		CaseID:           caseID,
+		DebtorID:         debtorID,
+		CIN:              cin,
+		NCLTCaseID:       ncltCaseID,
+		AdmissionDate:    admissionDate,
+		RPName:           rpName,
+		RPRegistrationNo: rpRegistrationNo,
+		RPMSPID:          rpMSPID,
+		RPClientID:       rpClientID,
+		MoratoriumStart:  moratoriumStart,
+		MoratoriumEnd:    moratoriumEnd,
+		Status:           "ADMITTED",
+		RegisteredBy:     mspid,
+		RegisteredAt:     now,
+	}
+	b, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	if err := ctx.GetStub().PutState(key, b); err != nil {
+		return err
+	}
+	if err := putCaseDebtorIndex(ctx, debtorID, caseID); err != nil {
+		return err
+	}
+
+	if err := writeAuditRecord(ctx, caseID, "REGISTER_INSOLVENCY_CASE", mspid,
+		fmt.Sprintf("CIRP admitted for %s (%s) in %s, RP %s", debtorID, cin, ncltCaseID, rpRegistrationNo), "ADMITTED", now); err != nil {
+		return err
+	}
+	return emitEvent(ctx, events.InsolvencyCaseRegistered, key, b)
+}
+
+// GetInsolvencyCase returns the insolvency case with given id
+func (s *IUContract) GetInsolvencyCase(ctx contractapi.TransactionContextInterface, caseID string) (*InsolvencyCase, error) {
+	val, err := ctx.GetStub().GetState(insolvencyCaseKey(caseID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if val == nil {
+		return nil, fmt.Errorf("insolvency case %s does not exist", caseID)
+	}
+	var c InsolvencyCase
+	if err := json.Unmarshal(val, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// SubmitClaim files a financial creditor's Form C proof of claim with the RP.
+// The caller's MSP must be the one creditorID is registered to. The full form is passed in the transient field 'formc' and kept in a private collection.
+func (s *IUContract) SubmitClaim(ctx contractapi.TransactionContextInterface, caseID, creditorID string, claimAmount float64) error {
+	mspid, err := getMSPID(ctx)
+	if err != nil {
+		return err
+	}
+	if mspid != "CreditorMSP" {
+		return fmt.Errorf("only CreditorMSP can submit a claim")
+	}
+	if creditorID == "" {
+		return fmt.Errorf("creditorID is required")
+	}
+	if err := s.requireCreditor(ctx, creditorID, mspid); err != nil {
+		return err
+	}
+	if claimAmount <= 0 {
+		return fmt.Errorf("claim amount must be positive")
+	}
+	c, err := s.GetInsolvencyCase(ctx, caseID)
+	if err != nil {
+		return err
+	}
+	if c.Status != "ADMITTED" {
+		return fmt.Errorf("insolvency case %s is %s and not accepting claims", caseID, c.Status)
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey(claimObjectType, []string{caseID, creditorID})
+	if err != nil {
+		return err
+	}
+	existing, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if existing != nil {
+		return fmt.Errorf("claim by %s in case %s already exists", creditorID, caseID)
+	}
+
+	transient, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return fmt.Errorf("failed to get transient: %v", err)
+	}
+	formBytes, ok := transient["formc"]
+	if !ok || len(formBytes) == 0 {
+		return fmt.Errorf("transient field 'formc' is required")
+	}
+	if err := ctx.GetStub().PutPrivateData(claimsCollection, key, formBytes); err != nil {
+		return fmt.Errorf("put private data failed: %v", err)
+	}
+	h := sha256.Sum256(formBytes)
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	claim := Claim{
+		CaseID:      caseID,
+		CreditorID:  creditorID,
+		CreditorMSP: mspid,
+		ClaimAmount: claimAmount,
+		FormCHash:   fmt.Sprintf("%x", h[:]),
+		Status:      ClaimStatusSubmitted,
+		SubmittedAt: now,
+	}
+	b, err := json.Marshal(claim)
+	if err != nil {
+		return err
+	}
+	if err := ctx.GetStub().PutState(key, b); err != nil {
+		return err
+	}
+
+	if err := writeAuditRecord(ctx, caseID, "SUBMIT_CLAIM", mspid,
+		fmt.Sprintf("Claim of %f submitted by %s", claimAmount, creditorID), ClaimStatusSubmitted, now); err != nil {
+		return err
+	}
+	return emitEvent(ctx, events.ClaimSubmitted, key, b)
+}
+
+// assertResolutionProfessional checks that the caller is the RP appointed for the case
+func assertResolutionProfessional(ctx contractapi.TransactionContextInterface, c *InsolvencyCase) (string, error) {
+	mspid, err := getMSPID(ctx)
+	if err != nil {
+		return "", err
+	}
+	clientID, err := getClientID(ctx)
+	if err != nil {
+		return "", err
+	}
+	if mspid != c.RPMSPID || clientID != c.RPClientID {
+		return "", fmt.Errorf("only the resolution professional of case %s can decide claims", c.CaseID)
+	}
+	return mspid, nil
+}
+
+func (s *IUContract) decideClaim(ctx contractapi.TransactionContextInterface, caseID, creditorID, status string, admittedAmount float64, remarks string) error {
+	c, err := s.GetInsolvencyCase(ctx, caseID)
+	if err != nil {
+		return err
+	}
+	mspid, err := assertResolutionProfessional(ctx, c)
+	if err != nil {
+		return err
+	}
+	claim, key, err := getClaim(ctx, caseID, creditorID)
+	if err != nil {
+		return err
+	}
+	if claim.Status != ClaimStatusSubmitted {
+		return fmt.Errorf("claim by %s in case %s is already %s", creditorID, caseID, claim.Status)
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	claimEvent := events.ClaimAdmitted
+	if status == ClaimStatusRejected {
+		claimEvent = events.ClaimRejected
+	}
+	claim.Status = status
+	claim.AdmittedAmount = admittedAmount
+	claim.DecidedBy = mspid
+	claim.DecidedAt = now
+	claim.Remarks = remarks
+	b, err := json.Marshal(claim)
+	if err != nil {
+		return err
+	}
+	if err := ctx.GetStub().PutState(key, b); err != nil {
+		return err
+	}
+
+	if err := writeAuditRecord(ctx, caseID, status+"_CLAIM", mspid,
+		fmt.Sprintf("Claim by %s %s, admitted amount %f: %s", creditorID, status, admittedAmount, remarks), status, now); err != nil {
+		return err
+	}
+	return emitEvent(ctx, claimEvent, key, b)
+}
+
+// AdmitClaim lets the RP admit a claim wholly or in part
+func (s *IUContract) AdmitClaim(ctx contractapi.TransactionContextInterface, caseID, creditorID string, admittedAmount float64, remarks string) error {
+	if admittedAmount <= 0 {
+		return fmt.Errorf("admitted amount must be positive")
+	}
+	claim, _, err := getClaim(ctx, caseID, creditorID)
+	if err != nil {
+		return err
+	}
+	if admittedAmount > claim.ClaimAmount {
+		return fmt.Errorf("admitted amount %f exceeds claimed amount %f", admittedAmount, claim.ClaimAmount)
+	}
+	return s.decideClaim(ctx, caseID, creditorID, ClaimStatusAdmitted, admittedAmount, remarks)
+}
+
+// RejectClaim lets the RP reject a claim with reasons
+func (s *IUContract) RejectClaim(ctx contractapi.TransactionContextInterface, caseID, creditorID, reason string) error {
+	if reason == "" {
+		return fmt.Errorf("reason is required")
+	}
+	return s.decideClaim(ctx, caseID, creditorID, ClaimStatusRejected, 0, reason)
+}
+
+func getClaim(ctx contractapi.TransactionContextInterface, caseID, creditorID string) (*Claim, string, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(claimObjectType, []string{caseID, creditorID})
+	if err != nil {
+		return nil, "", err
+	}
+	val, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if val == nil {
+		return nil, "", fmt.Errorf("claim by %s in case %s does not exist", creditorID, caseID)
+	}
+	var claim Claim
+	if err := json.Unmarshal(val, &claim); err != nil {
+		return nil, "", err
+	}
+	return &claim, key, nil
+}
+
+// GetClaimsRegister lists all claims in a case with each creditor's CoC voting share
+func (s *IUContract) GetClaimsRegister(ctx contractapi.TransactionContextInterface, caseID string) (*ClaimsRegister, error) {
+	c, err := s.GetInsolvencyCase(ctx, caseID)
+	if err != nil {
+		return nil, err
+	}
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(claimObjectType, []string{caseID})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	register := &ClaimsRegister{Case: *c, Claims: []ClaimsRegisterEntry{}}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		var claim Claim
+		if err := json.Unmarshal(queryResponse.Value, &claim); err != nil {
+			return nil, err
+		}
+		register.Claims = append(register.Claims, ClaimsRegisterEntry{Claim: claim})
+		register.TotalClaimed += claim.ClaimAmount
+		if claim.Status == ClaimStatusAdmitted {
+			register.TotalAdmitted += claim.AdmittedAmount
+		}
+	}
+
+	if register.TotalAdmitted > 0 {
+		for i := range register.Claims {
+			if register.Claims[i].Status == ClaimStatusAdmitted {
+				share := register.Claims[i].AdmittedAmount / register.TotalAdmitted * 100
+				register.Claims[i].VotingShare = math.Round(share*10000) / 10000
+			}
+		}
+	}
+	return register, nil
+}
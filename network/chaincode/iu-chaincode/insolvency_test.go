@@ -0,0 +1,260 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+
+	"iu-chaincode/chaincodetest"
+	"iu-chaincode/events"
+)
+
+// rp is the resolution professional appointed in the test cases
+var rp = chaincodetest.NewIdentity("AdminMSP", "rp.ibbi-00123@admin.iu-network.com")
+
+func registerCase(caseID, debtorID, moratoriumStart, moratoriumEnd string) ctxFunc {
+	return func(ctx contractapi.TransactionContextInterface) error {
+		return contract.RegisterInsolvencyCase(ctx, caseID, debtorID, "U12345MH2010PTC123456", "CP(IB)-101/MB/2024", moratoriumStart,
+			"R. Iyer", "IBBI/IPA-001/IP-P00123/2017-18/10234", rp.MSPID, rp.ID(), moratoriumStart, moratoriumEnd)
+	}
+}
+
+func submitClaim(caseID, creditorID string, amount float64) ctxFunc {
+	return func(ctx contractapi.TransactionContextInterface) error {
+		return contract.SubmitClaim(ctx, caseID, creditorID, amount)
+	}
+}
+
+var withClaimForm = chaincodetest.WithTransient(map[string][]byte{"formc": []byte(`{"claim":"principal and interest"}`)})
+
+func claimState(t *testing.T, stub *chaincodetest.Stub, caseID, creditorID string) Claim {
+	t.Helper()
+	var claim Claim
+	readState(t, stub, "\x00"+claimObjectType+"\x00"+caseID+"\x00"+creditorID+"\x00", &claim)
+	return claim
+}
+
+func TestRegisterInsolvencyCase(t *testing.T) {
+	setup := func(t *testing.T, stub *chaincodetest.Stub) {
+		mustSubmit(t, stub, chaincodetest.Admin, registerCase("CASE1", "D1", "2024-03-01", ""))
+	}
+	caseWith := func(cin, rpMSPID string) ctxFunc {
+		return func(ctx contractapi.TransactionContextInterface) error {
+			return contract.RegisterInsolvencyCase(ctx, "CASE2", "D2", cin, "CP(IB)-102/MB/2024", "2024-03-01",
+				"R. Iyer", "IBBI/IPA-001/IP-P00123/2017-18/10234", rpMSPID, rp.ID(), "2024-03-01", "")
+		}
+	}
+	runCases(t, setup, []txCase{
+		{name: "admin registers", id: chaincodetest.Admin, call: registerCase("CASE2", "D2", "2024-03-01", "2024-09-01"), event: events.InsolvencyCaseRegistered,
+			check: func(t *testing.T, stub *chaincodetest.Stub) {
+				var c InsolvencyCase
+				readState(t, stub, insolvencyCaseKey("CASE2"), &c)
+				if c.Status != "ADMITTED" || c.RPClientID != rp.ID() {
+					t.Fatalf("unexpected case %+v", c)
+				}
+				if len(stub.Keys("\x00"+caseDebtorIndex+"\x00D2\x00")) != 1 {
+					t.Fatal("debtor index not written")
+				}
+			}},
+		{name: "creditor cannot register", id: chaincodetest.Creditor, call: registerCase("CASE2", "D2", "2024-03-01", ""), wantErr: "only AdminMSP"},
+		{name: "duplicate", id: chaincodetest.Admin, call: registerCase("CASE1", "D1", "2024-03-01", ""), wantErr: "already exists"},
+		{name: "invalid date", id: chaincodetest.Admin, call: registerCase("CASE2", "D2", "01/03/2024", ""), wantErr: "invalid admissionDate"},
+		{name: "end before start", id: chaincodetest.Admin, call: registerCase("CASE2", "D2", "2024-03-01", "2024-02-01"), wantErr: "before moratoriumStart"},
+		{name: "unregistered debtor", id: chaincodetest.Admin, call: registerCase("CASE2", "D9", "2024-03-01", ""), wantErr: "debtor D9 is not a registered borrower or institution"},
+		{name: "suspended debtor", id: chaincodetest.Admin, call: registerCase("CASE2", "D2", "2024-03-01", ""), setup: admin(suspendBorrower("D2"))},
+		{name: "CIN normalized", id: chaincodetest.Admin, call: caseWith("u12345mh2010ptc123456", rp.MSPID),
+			check: func(t *testing.T, stub *chaincodetest.Stub) {
+				var c InsolvencyCase
+				readState(t, stub, insolvencyCaseKey("CASE2"), &c)
+				if c.CIN != "U12345MH2010PTC123456" {
+					t.Fatalf("cin = %s", c.CIN)
+				}
+			}},
+		{name: "invalid CIN", id: chaincodetest.Admin, call: caseWith("U12345MH2010XYZ123456", rp.MSPID), wantErr: "invalid cin U12345MH2010XYZ123456"},
+		{name: "RP outside the claims collection", id: chaincodetest.Admin, call: caseWith("U12345MH2010PTC123456", "DebtorMSP"),
+			wantErr: "rpMSPID DebtorMSP must be a member of the formc_claims collection (CreditorMSP, AdminMSP)"},
+		{name: "GetInsolvencyCase", id: chaincodetest.Debtor, call: func(ctx contractapi.TransactionContextInterface) error {
+			c, err := contract.GetInsolvencyCase(ctx, "CASE1")
+			if err == nil && c.DebtorID != "D1" {
+				t.Errorf("debtorId = %s", c.DebtorID)
+			}
+			return err
+		}},
+		{name: "GetInsolvencyCase missing", id: chaincodetest.Debtor, wantErr: "does not exist", call: func(ctx contractapi.TransactionContextInterface) error {
+			_, err := contract.GetInsolvencyCase(ctx, "CASE9")
+			return err
+		}},
+	})
+}
+
+func TestSubmitClaim(t *testing.T) {
+	setup := func(t *testing.T, stub *chaincodetest.Stub) {
+		mustSubmit(t, stub, chaincodetest.Admin, registerCase("CASE1", "D1", "2024-03-01", ""))
+		mustSubmit(t, stub, chaincodetest.Creditor, submitClaim("CASE1", "C1", 500000), withClaimForm)
+	}
+	runCases(t, setup, []txCase{
+		{name: "creditor submits", id: chaincodetest.Creditor, opts: []chaincodetest.TxOption{withClaimForm}, call: submitClaim("CASE1", "C2", 300000), event: events.ClaimSubmitted,
+			check: func(t *testing.T, stub *chaincodetest.Stub) {
+				claim := claimState(t, stub, "CASE1", "C2")
+				if claim.Status != ClaimStatusSubmitted || claim.FormCHash == "" {
+					t.Fatalf("unexpected claim %+v", claim)
+				}
+				if stub.PrivateState(claimsCollection, "\x00CLAIM\x00CASE1\x00C2\x00") == nil {
+					t.Fatal("Form C not stored in the claims collection")
+				}
+			}},
+		{name: "debtor cannot submit", id: chaincodetest.Debtor, opts: []chaincodetest.TxOption{withClaimForm}, call: submitClaim("CASE1", "C2", 1), wantErr: "only CreditorMSP"},
+		{name: "missing form", id: chaincodetest.Creditor, call: submitClaim("CASE1", "C2", 1), wantErr: "transient field 'formc' is required"},
+		{name: "duplicate", id: chaincodetest.Creditor, opts: []chaincodetest.TxOption{withClaimForm}, call: submitClaim("CASE1", "C1", 1), wantErr: "already exists"},
+		{name: "unknown case", id: chaincodetest.Creditor, opts: []chaincodetest.TxOption{withClaimForm}, call: submitClaim("CASE9", "C2", 1), wantErr: "does not exist"},
+		{name: "non-positive amount", id: chaincodetest.Creditor, opts: []chaincodetest.TxOption{withClaimForm}, call: submitClaim("CASE1", "C2", 0), wantErr: "must be positive"},
+		{name: "unregistered creditor", id: chaincodetest.Creditor, opts: []chaincodetest.TxOption{withClaimForm}, call: submitClaim("CASE1", "C9", 1), wantErr: "creditor C9 is not a registered institution"},
+		{name: "creditor of another MSP", id: chaincodetest.Creditor, opts: []chaincodetest.TxOption{withClaimForm}, call: submitClaim("CASE1", "OTHER", 1),
+			setup: admin(registerInstitution("OTHER", "AAACO1234D", "OtherMSP")), wantErr: "creditor OTHER is bound to OtherMSP, not CreditorMSP"},
+		{name: "suspended creditor", id: chaincodetest.Creditor, opts: []chaincodetest.TxOption{withClaimForm}, call: submitClaim("CASE1", "C2", 1),
+			setup: admin(suspendInstitution("C2")), wantErr: "creditor C2 is SUSPENDED"},
+	})
+}
+
+func TestDecideClaim(t *testing.T) {
+	setup := func(t *testing.T, stub *chaincodetest.Stub) {
+		mustSubmit(t, stub, chaincodetest.Admin, registerCase("CASE1", "D1", "2024-03-01", ""))
+		mustSubmit(t, stub, chaincodetest.Creditor, submitClaim("CASE1", "C1", 600000), withClaimForm)
+		mustSubmit(t, stub, chaincodetest.Creditor, submitClaim("CASE1", "C2", 400000), withClaimForm)
+	}
+	admit := func(creditorID string, amount float64) ctxFunc {
+		return func(ctx contractapi.TransactionContextInterface) error {
+			return contract.AdmitClaim(ctx, "CASE1", creditorID, amount, "verified")
+		}
+	}
+	reject := func(creditorID, reason string) ctxFunc {
+		return func(ctx contractapi.TransactionContextInterface) error {
+			return contract.RejectClaim(ctx, "CASE1", creditorID, reason)
+		}
+	}
+	runCases(t, setup, []txCase{
+		{name: "rp admits", id: rp, call: admit("C1", 450000), event: events.ClaimAdmitted,
+			check: func(t *testing.T, stub *chaincodetest.Stub) {
+				if claim := claimState(t, stub, "CASE1", "C1"); claim.Status != ClaimStatusAdmitted || claim.AdmittedAmount != 450000 {
+					t.Fatalf("unexpected claim %+v", claim)
+				}
+			}},
+		{name: "rp rejects", id: rp, call: reject("C2", "no proof of disbursement"), event: events.ClaimRejected,
+			check: func(t *testing.T, stub *chaincodetest.Stub) {
+				if claim := claimState(t, stub, "CASE1", "C2"); claim.Status != ClaimStatusRejected {
+					t.Fatalf("status = %s", claim.Status)
+				}
+			}},
+		{name: "other AdminMSP identity", id: chaincodetest.Admin, call: admit("C1", 1), wantErr: "only the resolution professional of case CASE1"},
+		{name: "creditor cannot admit", id: chaincodetest.Creditor, call: admit("C1", 1), wantErr: "only the resolution professional"},
+		{name: "exceeds claim", id: rp, call: admit("C1", 700000), wantErr: "exceeds claimed amount"},
+		{name: "reason required", id: rp, call: reject("C1", ""), wantErr: "reason is required"},
+		{name: "already decided", id: rp, call: reject("C1", "late"), wantErr: "is already ADMITTED",
+			setup: func(t *testing.T, stub *chaincodetest.Stub) { mustSubmit(t, stub, rp, admit("C1", 1)) }},
+		{name: "unknown claim", id: rp, call: admit("C9", 1), wantErr: "does not exist"},
+	})
+}
+
+func TestGetClaimsRegister(t *testing.T) {
+	setup := func(t *testing.T, stub *chaincodetest.Stub) {
+		mustSubmit(t, stub, chaincodetest.Admin, registerCase("CASE1", "D1", "2024-03-01", ""))
+		mustSubmit(t, stub, chaincodetest.Creditor, submitClaim("CASE1", "C1", 600000), withClaimForm)
+		mustSubmit(t, stub, chaincodetest.Creditor, submitClaim("CASE1", "C2", 400000), withClaimForm)
+		mustSubmit(t, stub, chaincodetest.Admin, registerInstitution("C3", "AAACB0009A", "CreditorMSP"))
+		mustSubmit(t, stub, chaincodetest.Creditor, submitClaim("CASE1", "C3", 100000), withClaimForm)
+		mustSubmit(t, stub, rp, func(ctx contractapi.TransactionContextInterface) error {
+			return contract.AdmitClaim(ctx, "CASE1", "C1", 300000, "")
+		})
+		mustSubmit(t, stub, rp, func(ctx contractapi.TransactionContextInterface) error {
+			return contract.AdmitClaim(ctx, "CASE1", "C2", 100000, "")
+		})
+	}
+	runCases(t, setup, []txCase{
+		{name: "voting shares", id: chaincodetest.Creditor, call: func(ctx contractapi.TransactionContextInterface) error {
+			register, err := contract.GetClaimsRegister(ctx, "CASE1")
+			if err != nil {
+				return err
+			}
+			if register.TotalClaimed != 1100000 || register.TotalAdmitted != 400000 || len(register.Claims) != 3 {
+				t.Errorf("unexpected totals %+v", register)
+			}
+			shares := []float64{75, 25, 0}
+			for i, c := range register.Claims {
+				if c.VotingShare != shares[i] {
+					t.Errorf("%s voting share = %v, want %v", c.CreditorID, c.VotingShare, shares[i])
+				}
+			}
+			return nil
+		}},
+		{name: "unknown case", id: chaincodetest.Creditor, wantErr: "does not exist", call: func(ctx contractapi.TransactionContextInterface) error {
+			_, err := contract.GetClaimsRegister(ctx, "CASE9")
+			return err
+		}},
+	})
+}
+
+func TestMoratorium(t *testing.T) {
+	setup := func(t *testing.T, stub *chaincodetest.Stub) {
+		mustSubmit(t, stub, chaincodetest.Admin, initLedger)
+		mustSubmit(t, stub, chaincodetest.Admin, registerCase("CASE1", "DEBTOR001", "2024-03-01", ""))
+		mustSubmit(t, stub, chaincodetest.Admin, registerCase("CASE2", "D2", "2023-01-01", "2023-12-31"))
+	}
+	courtOrder := chaincodetest.WithTransient(map[string][]byte{courtOrderTransientKey: []byte(strings.Repeat("C0FFEE00", 8))})
+	shortOrder := chaincodetest.WithTransient(map[string][]byte{courtOrderTransientKey: []byte("c0ffee")})
+	runCases(t, setup, []txCase{
+		{name: "blocks debit", id: chaincodetest.Creditor, call: createTx("TX1", "C1", "DEBTOR001", 1000, "DEBIT"), wantErr: "moratorium in force for debtor DEBTOR001"},
+		{name: "allows credit", id: chaincodetest.Creditor, call: createTx("TX1", "C1", "DEBTOR001", 1000, "CREDIT")},
+		{name: "blocks default", id: chaincodetest.Creditor, call: fileDefault("DEF1", "L1", "DEBTOR001"), wantErr: "FILE_DEFAULT is not permitted"},
+		{name: "blocks suspension", id: chaincodetest.Creditor, wantErr: "moratorium in force", call: func(ctx contractapi.TransactionContextInterface) error {
+			return contract.SuspendAccount(ctx, "ACC002", "recovery")
+		}},
+		{name: "expired moratorium", id: chaincodetest.Creditor, call: fileDefault("DEF1", "L1", "D2")},
+		{name: "creditor cannot override", id: chaincodetest.Creditor, opts: []chaincodetest.TxOption{courtOrder},
+			call: fileDefault("DEF1", "L1", "DEBTOR001"), wantErr: "only AdminMSP can override"},
+		{name: "court order hash must be SHA-256", id: chaincodetest.Admin, opts: []chaincodetest.TxOption{shortOrder},
+			call: fileDefault("DEF1", "L1", "DEBTOR001"), wantErr: "courtOrderHash must be the SHA-256 hash"},
+		{name: "admin overrides with court order", id: chaincodetest.Admin, opts: []chaincodetest.TxOption{courtOrder},
+			call: fileDefault("DEF1", "L1", "DEBTOR001"), event: events.DefaultFiled,
+			check: func(t *testing.T, stub *chaincodetest.Stub) {
+				keys := stub.Keys("AUDIT_DEF1_MORATORIUM_OVERRIDE_")
+				if len(keys) != 1 {
+					t.Fatal("override not recorded")
+				}
+				var a AuditRecord
+				readState(t, stub, keys[0], &a)
+				if !strings.Contains(a.Details, strings.Repeat("c0ffee00", 8)) {
+					t.Fatalf("court order not recorded: %s", a.Details)
+				}
+			}},
+	})
+}
+
+func TestClaimsCollectionMembers(t *testing.T) {
+	b, err := os.ReadFile("collections_config.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var collections []struct{ Name, Policy string }
+	if err := json.Unmarshal(b, &collections); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range collections {
+		if c.Name != claimsCollection {
+			continue
+		}
+		var members []string
+		for _, m := range regexp.MustCompile(`'([^'.]+)\.member'`).FindAllStringSubmatch(c.Policy, -1) {
+			members = append(members, m[1])
+		}
+		if !reflect.DeepEqual(members, claimsCollectionMembers) {
+			t.Fatalf("%s policy members = %v, claimsCollectionMembers = %v", claimsCollection, members, claimsCollectionMembers)
+		}
+		return
+	}
+	t.Fatalf("%s is not in collections_config.json", claimsCollection)
+}
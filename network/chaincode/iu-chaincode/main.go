@@ -5,9 +5,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+
+	"iu-chaincode/events"
 )
 
 // IUContract provides functions for managing Information Utility transactions
@@ -44,15 +47,22 @@ type Account struct {
 	LastUpdated time.Time `json:"lastUpdated"`
 }
 
-// AuditRecord represents an audit trail entry
+// AuditRecord represents an audit trail entry, written both for contract
+// actions and for events recorded through RecordAuditEvent
 type AuditRecord struct {
+	DocType          string    `json:"docType"` // always auditDocType
 	ID               string    `json:"id"`
-	TransactionID    string    `json:"transactionId"`
+	TransactionID    string    `json:"transactionId"` // id of the record the entry is about
 	Action           string    `json:"action"`
 	Actor            string    `json:"actor"`
 	Timestamp        time.Time `json:"timestamp"`
+	TimestampKey     string    `json:"timestampKey"` // Timestamp in auditTimeLayout, for time range queries
 	Details          string    `json:"details"`
 	ComplianceStatus string    `json:"complianceStatus"`
+	Hash             string    `json:"hash,omitempty" metadata:",optional"`
+	TxID             string    `json:"txId"`
+	SourceTxID       string    `json:"sourceTxId,omitempty" metadata:",optional"` // set on events mirrored from another channel
+	SourceBlock      uint64    `json:"sourceBlock,omitempty" metadata:",optional"`
 }
 
 // Document represents an uploaded document metadata and integrity hash
@@ -83,6 +93,14 @@ type KYCReference struct {
 // InitLedger adds a base set of data to the ledger
 func (s *IUContract) InitLedger(ctx contractapi.TransactionContextInterface) error {
 	fmt.Println("🚀 Initializing Information Utility Ledger")
+	mspid, err := getMSPID(ctx)
+	if err != nil {
+		return err
+	}
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
 
 	// Create initial accounts
 	accounts := []Account{
@@ -93,8 +111,8 @@ func (s *IUContract) InitLedger(ctx contractapi.TransactionContextInterface) err
 			Currency:    "USD",
 			AccountType: "CREDITOR",
 			Status:      "ACTIVE",
-			CreatedAt:   time.Now(),
-			LastUpdated: time.Now(),
+			CreatedAt:   now,
+			LastUpdated: now,
 		},
 		{
 			ID:          "ACC002",
@@ -103,8 +121,8 @@ func (s *IUContract) InitLedger(ctx contractapi.TransactionContextInterface) err
 			Currency:    "USD",
 			AccountType: "DEBTOR",
 			Status:      "ACTIVE",
-			CreatedAt:   time.Now(),
-			LastUpdated: time.Now(),
+			CreatedAt:   now,
+			LastUpdated: now,
 		},
 		{
 			ID:          "ACC003",
@@ -113,11 +131,12 @@ func (s *IUContract) InitLedger(ctx contractapi.TransactionContextInterface) err
 			Currency:    "USD",
 			AccountType: "ADMIN",
 			Status:      "ACTIVE",
-			CreatedAt:   time.Now(),
-			LastUpdated: time.Now(),
+			CreatedAt:   now,
+			LastUpdated: now,
 		},
 	}
 
+	keys := []string{}
 	for _, account := range accounts {
 		accountJSON, err := json.Marshal(account)
 		if err != nil {
@@ -128,6 +147,51 @@ func (s *IUContract) InitLedger(ctx contractapi.TransactionContextInterface) err
 		if err != nil {
 			return fmt.Errorf("failed to put account to world state: %v", err)
 		}
+		keys = append(keys, account.ID)
+	}
+
+	// Onboard the owners of the creditor and debtor accounts, unless a
+	// previous run already did
+	if _, err := s.GetInstitution(ctx, "CREDITOR001"); err != nil {
+		if err := putInstitution(ctx, &FinancialInstitution{
+			InstitutionID:      "CREDITOR001",
+			Name:               "Sample Bank Ltd",
+			RegistrationNumber: "RBI-SAMPLE-001",
+			Type:               InstitutionTypeBank,
+			PAN:                "AAACS0001A",
+			MSPID:              "CreditorMSP",
+			Status:             PartyStatusActive,
+			OnboardedBy:        mspid,
+			OnboardedAt:        now,
+			UpdatedAt:          now,
+		}); err != nil {
+			return err
+		}
+		keys = append(keys, institutionKey("CREDITOR001"))
+	}
+	if _, err := s.GetBorrower(ctx, "DEBTOR001"); err != nil {
+		if err := putBorrower(ctx, &Borrower{
+			BorrowerID:  "DEBTOR001",
+			Name:        "Sample Borrower",
+			Type:        BorrowerTypeIndividual,
+			PAN:         "ABCPS0001B",
+			AadhaarRef:  "XXXXXXXX0001",
+			Status:      PartyStatusActive,
+			OnboardedBy: mspid,
+			OnboardedAt: now,
+			UpdatedAt:   now,
+		}); err != nil {
+			return err
+		}
+		keys = append(keys, borrowerKey("DEBTOR001"))
+	}
+
+	if err := writeAuditRecord(ctx, "LEDGER", "INIT_LEDGER", mspid,
+		fmt.Sprintf("Ledger initialized with %v", keys), "INITIALIZED", now); err != nil {
+		return err
+	}
+	if err := emitEvent(ctx, events.LedgerInitialized, "", keys); err != nil {
+		return err
 	}
 
 	fmt.Println("✅ Information Utility Ledger initialized successfully")
@@ -143,24 +207,31 @@ func (s *IUContract) CreateTransaction(ctx contractapi.TransactionContextInterfa
 	if exists {
 		return fmt.Errorf("transaction %s already exists", id)
 	}
-
-	// Get previous transaction for hash chaining
-	previousHash := ""
-	queryString := fmt.Sprintf(`{"selector":{"creditorId":"%s","debtorId":"%s"}}`, creditorId, debtorId)
-	resultsIterator, err := ctx.GetStub().GetQueryResult(queryString)
-	if err == nil {
-		defer resultsIterator.Close()
-		if resultsIterator.HasNext() {
-			queryResult, _ := resultsIterator.Next()
-			var lastTx Transaction
-			json.Unmarshal(queryResult.Value, &lastTx)
-			previousHash = lastTx.Hash
+	mspid, err := getMSPID(ctx)
+	if err != nil {
+		return err
+	}
+	if err := s.requireCreditor(ctx, creditorId, mspid); err != nil {
+		return err
+	}
+	if err := s.requireDebtor(ctx, debtorId, transactionType == "DEBIT"); err != nil {
+		return err
+	}
+	if transactionType == "DEBIT" {
+		if err := s.enforceMoratorium(ctx, debtorId, "DEBIT_TRANSACTION", id); err != nil {
+			return err
 		}
 	}
 
-	// Create transaction hash
-	hashInput := fmt.Sprintf("%s%s%s%f%s%s", id, creditorId, debtorId, amount, currency, time.Now().String())
-	hash := fmt.Sprintf("HASH_%s", hashInput[0:16]) // Simplified hash for demo
+	// Stamp with the tx time so transactions chain in ledger order
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Get previous transaction for hash chaining
+	previousHash := previousTransactionHash(ctx, creditorId, debtorId)
+	hash := transactionHash(id, creditorId, debtorId, amount, currency, now)
 
 	transaction := Transaction{
 		ID:                id,
@@ -170,7 +241,7 @@ func (s *IUContract) CreateTransaction(ctx contractapi.TransactionContextInterfa
 		Currency:          currency,
 		TransactionType:   transactionType,
 		Status:            "PENDING",
-		Timestamp:         time.Now(),
+		Timestamp:         now,
 		Description:       description,
 		Hash:              hash,
 		PreviousHash:      previousHash,
@@ -188,29 +259,21 @@ func (s *IUContract) CreateTransaction(ctx contractapi.TransactionContextInterfa
 		return err
 	}
 
-	// Create audit record
-	mspid, err := getMSPID(ctx)
+	// Changes to the transaction need its creditor org and the regulator
+	owner, err := s.creditorOrg(ctx, creditorId)
 	if err != nil {
 		return err
 	}
-	auditID := fmt.Sprintf("AUDIT_%s_%d", id, time.Now().Unix())
-	audit := AuditRecord{
-		ID:               auditID,
-		TransactionID:    id,
-		Action:           "CREATE_TRANSACTION",
-		Actor:            mspid,
-		Timestamp:        time.Now(),
-		Details:          fmt.Sprintf("Transaction created: %s to %s, Amount: %f %s", creditorId, debtorId, amount, currency),
-		ComplianceStatus: "PENDING_REVIEW",
+	if err := setKeyEndorsers(ctx, id, owner, "AdminMSP"); err != nil {
+		return err
 	}
-
-	auditJSON, err := json.Marshal(audit)
+	err = writeAuditRecord(ctx, id, "CREATE_TRANSACTION", mspid,
+		fmt.Sprintf("Transaction created: %s to %s, Amount: %f %s", creditorId, debtorId, amount, currency),
+		"PENDING_REVIEW", now)
 	if err != nil {
 		return err
 	}
-
-	err = ctx.GetStub().PutState(auditID, auditJSON)
-	if err != nil {
+	if err := emitEvent(ctx, events.TransactionCreated, id, transactionJSON); err != nil {
 		return err
 	}
 
@@ -218,6 +281,50 @@ func (s *IUContract) CreateTransaction(ctx contractapi.TransactionContextInterfa
 	return nil
 }
 
+// previousTransactionHash returns the hash of the latest transaction between
+// the creditor and debtor to chain a new one to, or "" if there is none
+func previousTransactionHash(ctx contractapi.TransactionContextInterface, creditorID, debtorID string) string {
+	if tx := latestTransaction(ctx, creditorID, debtorID); tx != nil {
+		return tx.Hash
+	}
+	return ""
+}
+
+// latestTransaction returns the most recently booked transaction between a
+// creditor and a debtor, or nil when there is none. Timestamps are compared
+// once decoded, since RFC 3339 text with sub-second digits does not sort in
+// time order.
+func latestTransaction(ctx contractapi.TransactionContextInterface, creditorID, debtorID string) *Transaction {
+	// Defaults also carry creditorId and debtorId, so select transactions only
+	queryString := fmt.Sprintf(`{"selector":{"creditorId":"%s","debtorId":"%s","transactionType":{"$exists":true}}}`, creditorID, debtorID)
+	resultsIterator, err := ctx.GetStub().GetQueryResult(queryString)
+	if err != nil {
+		return nil
+	}
+	defer resultsIterator.Close()
+	var lastTx *Transaction
+	for resultsIterator.HasNext() {
+		queryResult, err := resultsIterator.Next()
+		if err != nil {
+			return nil
+		}
+		var tx Transaction
+		if err := json.Unmarshal(queryResult.Value, &tx); err != nil {
+			continue
+		}
+		if lastTx == nil || tx.Timestamp.After(lastTx.Timestamp) {
+			lastTx = &tx
+		}
+	}
+	return lastTx
+}
+
+// transactionHash returns the hash recorded on a transaction
+func transactionHash(id, creditorID, debtorID string, amount float64, currency string, ts time.Time) string {
+	hashInput := fmt.Sprintf("%s%s%s%f%s%s", id, creditorID, debtorID, amount, currency, ts.String())
+	return fmt.Sprintf("HASH_%s", hashInput[0:16]) // Simplified hash for demo
+}
+
 // ProcessTransaction validates and processes a pending transaction
 func (s *IUContract) ProcessTransaction(ctx contractapi.TransactionContextInterface, id string) error {
 	transaction, err := s.ReadTransaction(ctx, id)
@@ -255,26 +362,18 @@ func (s *IUContract) ProcessTransaction(ctx contractapi.TransactionContextInterf
 	}
 
 	// Create audit record
-	auditID := fmt.Sprintf("AUDIT_%s_PROCESSED_%d", id, time.Now().Unix())
-	audit := AuditRecord{
-		ID:               auditID,
-		TransactionID:    id,
-		Action:           "PROCESS_TRANSACTION",
-		Actor:            mspid,
-		Timestamp:        time.Now(),
-		Details:          fmt.Sprintf("Transaction processed and completed by %s", mspid),
-		ComplianceStatus: "APPROVED",
-	}
-
-	auditJSON, err := json.Marshal(audit)
+	now, err := getTxTime(ctx)
 	if err != nil {
 		return err
 	}
-
-	err = ctx.GetStub().PutState(auditID, auditJSON)
+	err = writeAuditRecord(ctx, id, "PROCESS_TRANSACTION", mspid,
+		fmt.Sprintf("Transaction processed and completed by %s", mspid), "APPROVED", now)
 	if err != nil {
 		return err
 	}
+	if err := emitEvent(ctx, events.TransactionProcessed, id, transactionJSON); err != nil {
+		return err
+	}
 
 	fmt.Printf("✅ Transaction %s processed successfully\n", id)
 	return nil
@@ -286,6 +385,9 @@ func (s *IUContract) PerformComplianceCheck(ctx contractapi.TransactionContextIn
 	if err != nil {
 		return err
 	}
+	if transaction.Status != "PENDING" {
+		return fmt.Errorf("transaction %s is not in PENDING status", id)
+	}
 
 	transaction.ComplianceChecked = true
 	if !approved {
@@ -303,7 +405,6 @@ func (s *IUContract) PerformComplianceCheck(ctx contractapi.TransactionContextIn
 	}
 
 	// Create audit record
-	auditID := fmt.Sprintf("AUDIT_%s_COMPLIANCE_%d", id, time.Now().Unix())
 	status := "APPROVED"
 	if !approved {
 		status = "REJECTED"
@@ -314,25 +415,18 @@ func (s *IUContract) PerformComplianceCheck(ctx contractapi.TransactionContextIn
 		return err
 	}
 
-	audit := AuditRecord{
-		ID:               auditID,
-		TransactionID:    id,
-		Action:           "COMPLIANCE_CHECK",
-		Actor:            mspid,
-		Timestamp:        time.Now(),
-		Details:          fmt.Sprintf("Compliance check result: %s", status),
-		ComplianceStatus: status,
-	}
-
-	auditJSON, err := json.Marshal(audit)
+	now, err := getTxTime(ctx)
 	if err != nil {
 		return err
 	}
-
-	err = ctx.GetStub().PutState(auditID, auditJSON)
+	err = writeAuditRecord(ctx, id, "COMPLIANCE_CHECK", mspid,
+		fmt.Sprintf("Compliance check result: %s", status), status, now)
 	if err != nil {
 		return err
 	}
+	if err := emitEvent(ctx, events.TransactionComplianceChecked, id, transactionJSON); err != nil {
+		return err
+	}
 
 	fmt.Printf("✅ Compliance check completed for transaction %s: %s\n", id, status)
 	return nil
@@ -367,9 +461,22 @@ func (s *IUContract) TransactionExists(ctx contractapi.TransactionContextInterfa
 	return transactionJSON != nil, nil
 }
 
+// transactionSelector matches transactions and no other record type
+const transactionSelector = `{"selector":{"transactionType":{"$exists":true}}}`
+
+// maxTransactionPageSize is the largest page QueryTransactions returns
+const maxTransactionPageSize = 500
+
+// TransactionQueryResult is one page of QueryTransactions
+type TransactionQueryResult struct {
+	Transactions []*Transaction `json:"transactions"`
+	FetchedCount int32          `json:"fetchedCount"`
+	Bookmark     string         `json:"bookmark"`
+}
+
 // GetAllTransactions returns all transactions found in world state
 func (s *IUContract) GetAllTransactions(ctx contractapi.TransactionContextInterface) ([]*Transaction, error) {
-	resultsIterator, err := ctx.GetStub().GetStateByRange("", "")
+	resultsIterator, err := ctx.GetStub().GetQueryResult(transactionSelector)
 	if err != nil {
 		return nil, err
 	}
@@ -385,7 +492,7 @@ func (s *IUContract) GetAllTransactions(ctx contractapi.TransactionContextInterf
 		var transaction Transaction
 		err = json.Unmarshal(queryResponse.Value, &transaction)
 		if err != nil {
-			continue // Skip non-transaction records
+			return nil, err
 		}
 
 		transactions = append(transactions, &transaction)
@@ -394,6 +501,36 @@ func (s *IUContract) GetAllTransactions(ctx contractapi.TransactionContextInterf
 	return transactions, nil
 }
 
+// QueryTransactions pages through all transactions. Pass the returned
+// bookmark to read the next page; a page with fewer than pageSize
+// transactions is the last.
+func (s *IUContract) QueryTransactions(ctx contractapi.TransactionContextInterface, pageSize int32, bookmark string) (*TransactionQueryResult, error) {
+	if pageSize <= 0 || pageSize > maxTransactionPageSize {
+		return nil, fmt.Errorf("pageSize must be between 1 and %d", maxTransactionPageSize)
+	}
+	resultsIterator, metadata, err := ctx.GetStub().GetQueryResultWithPagination(transactionSelector, pageSize, bookmark)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	result := &TransactionQueryResult{Transactions: []*Transaction{}}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		var transaction Transaction
+		if err := json.Unmarshal(queryResponse.Value, &transaction); err != nil {
+			return nil, err
+		}
+		result.Transactions = append(result.Transactions, &transaction)
+	}
+	result.FetchedCount = metadata.GetFetchedRecordsCount()
+	result.Bookmark = metadata.GetBookmark()
+	return result, nil
+}
+
 // GetTransactionHistory returns the transaction history for a given transaction ID
 func (s *IUContract) GetTransactionHistory(ctx contractapi.TransactionContextInterface, id string) (string, error) {
 	resultsIterator, err := ctx.GetStub().GetHistoryForKey(id)
@@ -434,6 +571,70 @@ func (s *IUContract) GetTransactionHistory(ctx contractapi.TransactionContextInt
 	return string(historyJSON), nil
 }
 
+// ReadAccount returns the account stored in the world state with given id
+func (s *IUContract) ReadAccount(ctx contractapi.TransactionContextInterface, id string) (*Account, error) {
+	accountJSON, err := ctx.GetStub().GetState(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if accountJSON == nil {
+		return nil, fmt.Errorf("account %s does not exist", id)
+	}
+
+	var account Account
+	err = json.Unmarshal(accountJSON, &account)
+	if err != nil {
+		return nil, err
+	}
+
+	return &account, nil
+}
+
+// SuspendAccount suspends an active account, subject to any insolvency moratorium on its owner
+func (s *IUContract) SuspendAccount(ctx contractapi.TransactionContextInterface, id string, reason string) error {
+	mspid, err := getMSPID(ctx)
+	if err != nil {
+		return err
+	}
+	if mspid != "CreditorMSP" && mspid != "AdminMSP" {
+		return fmt.Errorf("only CreditorMSP or AdminMSP can suspend an account")
+	}
+
+	account, err := s.ReadAccount(ctx, id)
+	if err != nil {
+		return err
+	}
+	if account.Status != "ACTIVE" {
+		return fmt.Errorf("account %s is not in ACTIVE status", id)
+	}
+	if account.AccountType == "DEBTOR" {
+		if err := s.enforceMoratorium(ctx, account.OwnerID, "SUSPEND_ACCOUNT", id); err != nil {
+			return err
+		}
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	account.Status = "SUSPENDED"
+	account.LastUpdated = now
+
+	accountJSON, err := json.Marshal(account)
+	if err != nil {
+		return err
+	}
+	err = ctx.GetStub().PutState(id, accountJSON)
+	if err != nil {
+		return err
+	}
+
+	if err := writeAuditRecord(ctx, id, "SUSPEND_ACCOUNT", mspid, fmt.Sprintf("Account suspended: %s", reason), "SUSPENDED", now); err != nil {
+		return err
+	}
+	return emitEvent(ctx, events.AccountSuspended, id, accountJSON)
+}
+
 func getMSPID(ctx contractapi.TransactionContextInterface) (string, error) {
 	id := ctx.GetClientIdentity()
 	mspid, err := id.GetMSPID()
@@ -443,7 +644,8 @@ func getMSPID(ctx contractapi.TransactionContextInterface) (string, error) {
 	return mspid, nil
 }
 
-// SubmitLoanDocument records document metadata and integrity hash on-ledger
+// SubmitLoanDocument records document metadata and integrity hash on-ledger.
+// Identifiers in JSON metadata are validated and stored normalized.
 func (s *IUContract) SubmitLoanDocument(ctx contractapi.TransactionContextInterface, loanID, docID, hash, docType, mime, sizeStr, metadata string) error {
 	if loanID == "" || docID == "" || hash == "" {
 		return fmt.Errorf("loanID, docID and hash are required")
@@ -458,11 +660,18 @@ func (s *IUContract) SubmitLoanDocument(ctx contractapi.TransactionContextInterf
 		}
 		sz = val
 	}
+	metadata, err := metadataIdentifiers(metadata)
+	if err != nil {
+		return err
+	}
 
 	mspid, err := getMSPID(ctx)
 	if err != nil {
 		return err
 	}
+	if err := s.requireOnboardedOrg(ctx, mspid); err != nil {
+		return err
+	}
 
 	docKey := fmt.Sprintf("DOC_%s", docID)
 	exists, err := s.TransactionExists(ctx, docKey)
@@ -473,6 +682,10 @@ func (s *IUContract) SubmitLoanDocument(ctx contractapi.TransactionContextInterf
 		return fmt.Errorf("document %s already exists", docID)
 	}
 
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
 	doc := Document{
 		DocID:      docID,
 		LoanID:     loanID,
@@ -481,7 +694,7 @@ func (s *IUContract) SubmitLoanDocument(ctx contractapi.TransactionContextInterf
 		Mime:       mime,
 		Size:       sz,
 		OwnerOrg:   mspid,
-		UploadedAt: time.Now(),
+		UploadedAt: now,
 		Status:     "SUBMITTED",
 		Metadata:   metadata,
 	}
@@ -489,9 +702,11 @@ func (s *IUContract) SubmitLoanDocument(ctx contractapi.TransactionContextInterf
 	if err := ctx.GetStub().PutState(docKey, b); err != nil {
 		return err
 	}
-	// emit event
-	_ = ctx.GetStub().SetEvent("DOC_SUBMITTED", b)
-	return nil
+	if err := writeAuditRecord(ctx, docID, "SUBMIT_DOCUMENT", mspid,
+		fmt.Sprintf("%s document for loan %s, hash %s", docType, loanID, hash), "SUBMITTED", now); err != nil {
+		return err
+	}
+	return emitEvent(ctx, events.DocumentSubmitted, docKey, b)
 }
 
 // GetDocument returns document metadata by docID
@@ -513,25 +728,33 @@ func (s *IUContract) GetDocument(ctx contractapi.TransactionContextInterface, do
 
 // GetLoanDocuments lists documents for a loan
 func (s *IUContract) GetLoanDocuments(ctx contractapi.TransactionContextInterface, loanID string) (string, error) {
+	out, err := queryLoanDocuments(ctx, loanID)
+	if err != nil {
+		return "", err
+	}
+	b, _ := json.Marshal(out)
+	return string(b), nil
+}
+
+func queryLoanDocuments(ctx contractapi.TransactionContextInterface, loanID string) ([]Document, error) {
 	query := fmt.Sprintf(`{"selector":{"loanId":"%s"}}`, loanID)
 	it, err := ctx.GetStub().GetQueryResult(query)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	defer it.Close()
 	var out []Document
 	for it.HasNext() {
 		qr, err := it.Next()
 		if err != nil {
-			return "", err
+			return nil, err
 		}
 		var d Document
 		if err := json.Unmarshal(qr.Value, &d); err == nil && d.DocID != "" {
 			out = append(out, d)
 		}
 	}
-	b, _ := json.Marshal(out)
-	return string(b), nil
+	return out, nil
 }
 
 // SubmitKYCFormC stores private Form-C in admin-only collection and public hash reference
@@ -546,6 +769,9 @@ func (s *IUContract) SubmitKYCFormC(ctx contractapi.TransactionContextInterface,
 	if loanID == "" || kycID == "" || partyID == "" {
 		return fmt.Errorf("loanID, kycID, partyID are required")
 	}
+	if err := s.requireParty(ctx, partyID); err != nil {
+		return err
+	}
 	transient, err := ctx.GetStub().GetTransient()
 	if err != nil {
 		return fmt.Errorf("failed to get transient: %v", err)
@@ -562,21 +788,31 @@ func (s *IUContract) SubmitKYCFormC(ctx contractapi.TransactionContextInterface,
 	// compute hash of full form for public reference
 	h := sha256.Sum256(formBytes)
 	hashHex := fmt.Sprintf("%x", h[:])
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
 	ref := KYCReference{
 		KYCID:     kycID,
 		LoanID:    loanID,
 		PartyID:   partyID,
 		Hash:      hashHex,
 		Status:    "SUBMITTED",
-		Timestamp: time.Now(),
+		Timestamp: now,
 		Remarks:   "",
 	}
 	b, _ := json.Marshal(ref)
 	if err := ctx.GetStub().PutState(fmt.Sprintf("KYC_%s", kycID), b); err != nil {
 		return err
 	}
-	_ = ctx.GetStub().SetEvent("KYC_SUBMITTED", b)
-	return nil
+	if err := setKeyEndorsers(ctx, fmt.Sprintf("KYC_%s", kycID), "AdminMSP"); err != nil {
+		return err
+	}
+	if err := writeAuditRecord(ctx, kycID, "SUBMIT_KYC", mspid,
+		fmt.Sprintf("Form-C for %s on loan %s, hash %s", partyID, loanID, hashHex), "SUBMITTED", now); err != nil {
+		return err
+	}
+	return emitEvent(ctx, events.KYCSubmitted, fmt.Sprintf("KYC_%s", kycID), b)
 }
 
 // ApproveKYC allows AdminMSP to approve/reject KYC
@@ -600,36 +836,32 @@ func (s *IUContract) ApproveKYC(ctx contractapi.TransactionContextInterface, kyc
 	if err := json.Unmarshal(val, &ref); err != nil {
 		return err
 	}
+	if ref.Status != "SUBMITTED" {
+		return fmt.Errorf("kyc %s is not pending approval (status %s)", kycID, ref.Status)
+	}
+	eventType := events.KYCApproved
+	action := "APPROVE_KYC"
 	if approved {
 		ref.Status = "APPROVED"
 	} else {
 		ref.Status = "REJECTED"
+		eventType = events.KYCRejected
+		action = "REJECT_KYC"
+	}
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
 	}
-	ref.Timestamp = time.Now()
+	ref.Timestamp = now
 	ref.Remarks = remarks
 	b, _ := json.Marshal(ref)
 	if err := ctx.GetStub().PutState(key, b); err != nil {
 		return err
 	}
-	_ = ctx.GetStub().SetEvent("KYC_APPROVED", b)
-	return nil
-}
-
-// RecordAuditEvent writes minimal audit event on channel (for mirroring)
-func (s *IUContract) RecordAuditEvent(ctx contractapi.TransactionContextInterface, eventType, refId, hash, details string) error {
-	if eventType == "" || refId == "" {
-		return fmt.Errorf("eventType and refId are required")
-	}
-	rec := map[string]string{
-		"eventType": eventType,
-		"refId":     refId,
-		"hash":      hash,
-		"details":   details,
-		"timestamp": time.Now().Format(time.RFC3339Nano),
-	}
-	b, _ := json.Marshal(rec)
-	key := fmt.Sprintf("AUDIT_EVT_%s_%d", refId, time.Now().UnixNano())
-	return ctx.GetStub().PutState(key, b)
+	if err := writeAuditRecord(ctx, kycID, action, mspid, fmt.Sprintf("KYC %s: %s", strings.ToLower(ref.Status), remarks), ref.Status, now); err != nil {
+		return err
+	}
+	return emitEvent(ctx, eventType, key, b)
 }
 
 func main() {
@@ -0,0 +1,533 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+
+	"iu-chaincode/chaincodetest"
+	"iu-chaincode/events"
+)
+
+var contract = &IUContract{}
+
+type ctxFunc = func(ctx contractapi.TransactionContextInterface) error
+
+// txCase is one call of a contract function on a fresh ledger
+type txCase struct {
+	name    string
+	id      *chaincodetest.Identity
+	setup   func(t *testing.T, stub *chaincodetest.Stub)
+	opts    []chaincodetest.TxOption
+	call    ctxFunc
+	wantErr string // substring of the expected error; empty means success
+	event   string // event type the call must emit
+	check   func(t *testing.T, stub *chaincodetest.Stub)
+}
+
+// runCases runs each case on its own ledger prepared by setup
+func runCases(t *testing.T, setup func(t *testing.T, stub *chaincodetest.Stub), cases []txCase) {
+	t.Helper()
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			stub := newTestStub(t)
+			if setup != nil {
+				setup(t, stub)
+			}
+			if tc.setup != nil {
+				tc.setup(t, stub)
+			}
+			before := len(stub.Events())
+			err := stub.Tx(tc.id, tc.call, tc.opts...)
+			checkErr(t, err, tc.wantErr)
+			if tc.wantErr != "" && len(stub.Events()) != before {
+				t.Fatalf("failed call emitted an event")
+			}
+			if tc.event != "" {
+				if got := lastEventType(t, stub); got != tc.event {
+					t.Fatalf("event = %s, want %s", got, tc.event)
+				}
+			}
+			if tc.check != nil {
+				tc.check(t, stub)
+			}
+		})
+	}
+}
+
+func newTestStub(t *testing.T) *chaincodetest.Stub {
+	t.Helper()
+	stub := chaincodetest.NewStub(sourceChannel)
+	if err := stub.LoadCollections("collections_config.json"); err != nil {
+		t.Fatal(err)
+	}
+	if err := stub.Seed(chaincodetest.Admin, seedParties); err != nil {
+		t.Fatal(err)
+	}
+	return stub
+}
+
+// seedParties onboards the creditors, borrowers and guarantors that tests
+// name. Other IDs are unregistered.
+func seedParties(ctx contractapi.TransactionContextInterface) error {
+	for i, id := range []string{"C1", "C2"} {
+		if err := putInstitution(ctx, &FinancialInstitution{InstitutionID: id, Name: "Bank " + id, RegistrationNumber: "RBI-" + id,
+			Type: InstitutionTypeBank, PAN: fmt.Sprintf("AAACB%04dA", i), MSPID: "CreditorMSP", Status: PartyStatusActive}); err != nil {
+			return err
+		}
+	}
+	for i, id := range []string{"D1", "D2", "D3", "D4", "D5", "P1", "P2", "G1", "G2", "G3", "CO1", "CB1"} {
+		if err := putBorrower(ctx, &Borrower{BorrowerID: id, Name: "Borrower " + id, Type: BorrowerTypeIndividual,
+			PAN: fmt.Sprintf("AAAPB%04dA", i), ClientID: chaincodetest.Debtor.ID(), Status: PartyStatusActive}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkErr(t *testing.T, err error, wantErr string) {
+	t.Helper()
+	switch {
+	case wantErr == "" && err != nil:
+		t.Fatalf("unexpected error: %v", err)
+	case wantErr != "" && err == nil:
+		t.Fatalf("expected error containing %q", wantErr)
+	case wantErr != "" && !strings.Contains(err.Error(), wantErr):
+		t.Fatalf("error %q does not contain %q", err, wantErr)
+	}
+}
+
+// mustSubmit runs fn as id and fails the test if it returns an error
+func mustSubmit(t *testing.T, stub *chaincodetest.Stub, id *chaincodetest.Identity, fn ctxFunc, opts ...chaincodetest.TxOption) {
+	t.Helper()
+	if err := stub.Tx(id, fn, opts...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readState decodes the committed JSON value of key into v
+func readState(t *testing.T, stub *chaincodetest.Stub, key string, v interface{}) {
+	t.Helper()
+	b := stub.State(key)
+	if b == nil {
+		t.Fatalf("%s is not on the ledger", key)
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func lastEvent(t *testing.T, stub *chaincodetest.Stub) *events.Envelope {
+	t.Helper()
+	ev := stub.LastEvent()
+	if ev == nil {
+		t.Fatal("no event emitted")
+	}
+	env, err := events.Decode(ev.Payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env.Type != ev.EventName {
+		t.Fatalf("event name %s does not match envelope type %s", ev.EventName, env.Type)
+	}
+	return env
+}
+
+func lastEventType(t *testing.T, stub *chaincodetest.Stub) string {
+	t.Helper()
+	return lastEvent(t, stub).Type
+}
+
+func createTx(id, creditorID, debtorID string, amount float64, txType string) ctxFunc {
+	return func(ctx contractapi.TransactionContextInterface) error {
+		return contract.CreateTransaction(ctx, id, creditorID, debtorID, amount, "INR", txType, "term loan")
+	}
+}
+
+func complianceCheck(id string, approved bool) ctxFunc {
+	return func(ctx contractapi.TransactionContextInterface) error {
+		return contract.PerformComplianceCheck(ctx, id, approved)
+	}
+}
+
+func submitDoc(loanID, docID string) ctxFunc {
+	return func(ctx contractapi.TransactionContextInterface) error {
+		return contract.SubmitLoanDocument(ctx, loanID, docID, sha256Hex([]byte(docID)), "SANCTION_LETTER", "application/pdf", "2048", `{"pages":3}`)
+	}
+}
+
+func initLedger(ctx contractapi.TransactionContextInterface) error {
+	return contract.InitLedger(ctx)
+}
+
+func TestContractMetadata(t *testing.T) {
+	if _, err := contractapi.NewChaincode(&IUContract{}); err != nil {
+		t.Fatalf("contract does not build: %v", err)
+	}
+}
+
+func TestInitLedger(t *testing.T) {
+	runCases(t, nil, []txCase{
+		{name: "creates accounts", id: chaincodetest.Admin, call: initLedger, event: events.LedgerInitialized,
+			check: func(t *testing.T, stub *chaincodetest.Stub) {
+				if keys := stub.Keys("ACC"); len(keys) != 3 {
+					t.Fatalf("accounts = %v, want 3", keys)
+				}
+				var account Account
+				readState(t, stub, "ACC001", &account)
+				if at := lastEvent(t, stub).Timestamp; !account.CreatedAt.Equal(at) || !account.LastUpdated.Equal(at) {
+					t.Fatalf("account times %s, %s, want the transaction time %s", account.CreatedAt, account.LastUpdated, at)
+				}
+				if len(stub.Keys("AUDIT_LEDGER_INIT_LEDGER_")) != 1 {
+					t.Fatal("no audit record written")
+				}
+			}},
+	})
+}
+
+func TestCreateTransaction(t *testing.T) {
+	setup := func(t *testing.T, stub *chaincodetest.Stub) {
+		mustSubmit(t, stub, chaincodetest.Creditor, createTx("TX1", "C1", "D1", 1000, "DEBIT"))
+	}
+	runCases(t, setup, []txCase{
+		{name: "creditor creates", id: chaincodetest.Creditor, call: createTx("TX2", "C1", "D1", 500, "CREDIT"), event: events.TransactionCreated,
+			check: func(t *testing.T, stub *chaincodetest.Stub) {
+				var tx Transaction
+				readState(t, stub, "TX2", &tx)
+				if tx.Status != "PENDING" || tx.Amount != 500 {
+					t.Fatalf("unexpected transaction %+v", tx)
+				}
+				var first Transaction
+				readState(t, stub, "TX1", &first)
+				if tx.PreviousHash != first.Hash {
+					t.Fatalf("previousHash = %q, want %q", tx.PreviousHash, first.Hash)
+				}
+				if len(stub.Keys("AUDIT_TX2_CREATE_TRANSACTION_")) != 1 {
+					t.Fatal("no audit record written")
+				}
+				if lastEvent(t, stub).PayloadHash != sha256Hex(stub.State("TX2")) {
+					t.Fatal("event hash does not match the stored value")
+				}
+			}},
+		{name: "sets key endorsers", id: chaincodetest.Admin, call: createTx("TX3", "C1", "D2", 100, "CREDIT"),
+			check: func(t *testing.T, stub *chaincodetest.Stub) {
+				if stub.ValidationParameter("TX3") == nil {
+					t.Fatal("no key-level endorsement policy")
+				}
+			}},
+		{name: "chains to the latest transaction", id: chaincodetest.Creditor, call: createTx("TX2", "C1", "D1", 100, "CREDIT"),
+			setup: func(t *testing.T, stub *chaincodetest.Stub) {
+				// TX0 sorts first by key but was created after TX1
+				mustSubmit(t, stub, chaincodetest.Creditor, createTx("TX0", "C1", "D1", 200, "DEBIT"))
+			},
+			check: func(t *testing.T, stub *chaincodetest.Stub) {
+				var tx, latest Transaction
+				readState(t, stub, "TX2", &tx)
+				readState(t, stub, "TX0", &latest)
+				if tx.PreviousHash != latest.Hash {
+					t.Fatalf("previousHash = %q, want %q", tx.PreviousHash, latest.Hash)
+				}
+			}},
+		{name: "defaults are not chained", id: chaincodetest.Creditor, call: createTx("TX3", "C1", "D1", 100, "DEBIT"),
+			setup: func(t *testing.T, stub *chaincodetest.Stub) {
+				mustSubmit(t, stub, chaincodetest.Creditor, fileDefault("DEF1", "L1", "D1"))
+			},
+			check: func(t *testing.T, stub *chaincodetest.Stub) {
+				var tx, first Transaction
+				readState(t, stub, "TX3", &tx)
+				readState(t, stub, "TX1", &first)
+				if tx.PreviousHash != first.Hash {
+					t.Fatalf("previousHash = %q, want %q", tx.PreviousHash, first.Hash)
+				}
+			}},
+		{name: "duplicate", id: chaincodetest.Creditor, call: createTx("TX1", "C1", "D1", 1000, "DEBIT"), wantErr: "already exists"},
+	})
+}
+
+func TestPerformComplianceCheck(t *testing.T) {
+	setup := func(t *testing.T, stub *chaincodetest.Stub) {
+		mustSubmit(t, stub, chaincodetest.Creditor, createTx("TX1", "C1", "D1", 1000, "DEBIT"))
+	}
+	status := func(want string, checked bool) func(t *testing.T, stub *chaincodetest.Stub) {
+		return func(t *testing.T, stub *chaincodetest.Stub) {
+			var tx Transaction
+			readState(t, stub, "TX1", &tx)
+			if tx.Status != want || tx.ComplianceChecked != checked {
+				t.Fatalf("status %s checked %v, want %s %v", tx.Status, tx.ComplianceChecked, want, checked)
+			}
+		}
+	}
+	runCases(t, setup, []txCase{
+		{name: "approved", id: chaincodetest.Admin, call: complianceCheck("TX1", true), event: events.TransactionComplianceChecked, check: status("PENDING", true)},
+		{name: "rejected", id: chaincodetest.Admin, call: complianceCheck("TX1", false), check: status("FAILED", true)},
+		{name: "already failed", id: chaincodetest.Admin, call: complianceCheck("TX1", true), wantErr: "not in PENDING status",
+			setup: func(t *testing.T, stub *chaincodetest.Stub) {
+				mustSubmit(t, stub, chaincodetest.Admin, complianceCheck("TX1", false))
+			}},
+		{name: "missing", id: chaincodetest.Admin, call: complianceCheck("TX9", true), wantErr: "does not exist"},
+	})
+}
+
+func TestProcessTransaction(t *testing.T) {
+	setup := func(t *testing.T, stub *chaincodetest.Stub) {
+		mustSubmit(t, stub, chaincodetest.Creditor, createTx("TX1", "C1", "D1", 1000, "DEBIT"))
+		mustSubmit(t, stub, chaincodetest.Creditor, createTx("TX2", "C1", "D1", 1000, "DEBIT"))
+		mustSubmit(t, stub, chaincodetest.Admin, complianceCheck("TX1", true))
+	}
+	process := func(id string) ctxFunc {
+		return func(ctx contractapi.TransactionContextInterface) error {
+			return contract.ProcessTransaction(ctx, id)
+		}
+	}
+	runCases(t, setup, []txCase{
+		{name: "completes", id: chaincodetest.Admin, call: process("TX1"), event: events.TransactionProcessed,
+			check: func(t *testing.T, stub *chaincodetest.Stub) {
+				var tx Transaction
+				readState(t, stub, "TX1", &tx)
+				if tx.Status != "COMPLETED" || tx.ValidatedBy != "AdminMSP" {
+					t.Fatalf("unexpected transaction %+v", tx)
+				}
+			}},
+		{name: "not compliance checked", id: chaincodetest.Admin, call: process("TX2"), wantErr: "has not passed compliance check"},
+		{name: "already completed", id: chaincodetest.Admin, call: process("TX1"), wantErr: "not in PENDING status",
+			setup: func(t *testing.T, stub *chaincodetest.Stub) { mustSubmit(t, stub, chaincodetest.Admin, process("TX1")) }},
+		{name: "missing", id: chaincodetest.Admin, call: process("TX9"), wantErr: "does not exist"},
+	})
+}
+
+func TestTransactionQueries(t *testing.T) {
+	setup := func(t *testing.T, stub *chaincodetest.Stub) {
+		mustSubmit(t, stub, chaincodetest.Creditor, createTx("TX1", "C1", "D1", 1000, "DEBIT"))
+		mustSubmit(t, stub, chaincodetest.Admin, complianceCheck("TX1", true))
+		mustSubmit(t, stub, chaincodetest.Creditor, createTx("TX2", "C1", "D2", 500, "DEBIT"))
+		mustSubmit(t, stub, chaincodetest.Creditor, createTx("TX3", "C2", "D1", 250, "CREDIT"))
+		mustSubmit(t, stub, chaincodetest.Creditor, fileDefault("DEF1", "L1", "D1"))
+	}
+	page := func(size int32, bookmark string, want int, next *string) ctxFunc {
+		return func(ctx contractapi.TransactionContextInterface) error {
+			result, err := contract.QueryTransactions(ctx, size, bookmark)
+			if err != nil {
+				return err
+			}
+			if len(result.Transactions) != want {
+				t.Errorf("page of %d transactions, want %d", len(result.Transactions), want)
+			}
+			if next != nil {
+				*next = result.Bookmark
+			}
+			return nil
+		}
+	}
+	runCases(t, setup, []txCase{
+		{name: "ReadTransaction", id: chaincodetest.Debtor, call: func(ctx contractapi.TransactionContextInterface) error {
+			tx, err := contract.ReadTransaction(ctx, "TX1")
+			if err == nil && tx.DebtorID != "D1" {
+				t.Errorf("debtorId = %s", tx.DebtorID)
+			}
+			return err
+		}},
+		{name: "ReadTransaction missing", id: chaincodetest.Debtor, wantErr: "does not exist", call: func(ctx contractapi.TransactionContextInterface) error {
+			_, err := contract.ReadTransaction(ctx, "TX9")
+			return err
+		}},
+		{name: "TransactionExists", id: chaincodetest.Debtor, call: func(ctx contractapi.TransactionContextInterface) error {
+			yes, err := contract.TransactionExists(ctx, "TX1")
+			no, _ := contract.TransactionExists(ctx, "TX9")
+			if !yes || no {
+				t.Errorf("exists = %v, %v", yes, no)
+			}
+			return err
+		}},
+		{name: "GetAllTransactions", id: chaincodetest.Admin, call: func(ctx contractapi.TransactionContextInterface) error {
+			txs, err := contract.GetAllTransactions(ctx)
+			found := false
+			for _, tx := range txs {
+				found = found || tx.ID == "TX1"
+				if tx.TransactionType == "" {
+					t.Errorf("non-transaction record %+v", tx)
+				}
+			}
+			if !found || len(txs) != 3 {
+				t.Errorf("TX1 not among %d results", len(txs))
+			}
+			return err
+		}},
+		{name: "QueryTransactions pages", id: chaincodetest.Admin, call: func(ctx contractapi.TransactionContextInterface) error {
+			var bookmark string
+			if err := page(2, "", 2, &bookmark)(ctx); err != nil {
+				return err
+			}
+			return page(2, bookmark, 1, nil)(ctx)
+		}},
+		{name: "QueryTransactions page size", id: chaincodetest.Admin, call: page(maxTransactionPageSize+1, "", 0, nil), wantErr: "pageSize must be between"},
+		{name: "GetTransactionHistory", id: chaincodetest.Admin, call: func(ctx contractapi.TransactionContextInterface) error {
+			history, err := contract.GetTransactionHistory(ctx, "TX1")
+			var versions []map[string]interface{}
+			json.Unmarshal([]byte(history), &versions)
+			if len(versions) != 2 || versions[0]["txId"] != "tx0002" {
+				t.Errorf("history = %s, want two versions newest first", history)
+			}
+			return err
+		}},
+	})
+}
+
+func TestAccounts(t *testing.T) {
+	setup := func(t *testing.T, stub *chaincodetest.Stub) {
+		mustSubmit(t, stub, chaincodetest.Admin, initLedger)
+	}
+	suspend := func(id string) ctxFunc {
+		return func(ctx contractapi.TransactionContextInterface) error {
+			return contract.SuspendAccount(ctx, id, "fraud review")
+		}
+	}
+	runCases(t, setup, []txCase{
+		{name: "ReadAccount", id: chaincodetest.Creditor, call: func(ctx contractapi.TransactionContextInterface) error {
+			acc, err := contract.ReadAccount(ctx, "ACC001")
+			if err == nil && acc.OwnerID != "CREDITOR001" {
+				t.Errorf("owner = %s", acc.OwnerID)
+			}
+			return err
+		}},
+		{name: "ReadAccount missing", id: chaincodetest.Creditor, wantErr: "does not exist", call: func(ctx contractapi.TransactionContextInterface) error {
+			_, err := contract.ReadAccount(ctx, "ACC999")
+			return err
+		}},
+		{name: "creditor suspends", id: chaincodetest.Creditor, call: suspend("ACC002"), event: events.AccountSuspended,
+			check: func(t *testing.T, stub *chaincodetest.Stub) {
+				var acc Account
+				readState(t, stub, "ACC002", &acc)
+				if acc.Status != "SUSPENDED" {
+					t.Fatalf("status = %s", acc.Status)
+				}
+			}},
+		{name: "debtor cannot suspend", id: chaincodetest.Debtor, call: suspend("ACC002"), wantErr: "only CreditorMSP or AdminMSP"},
+		{name: "already suspended", id: chaincodetest.Admin, call: suspend("ACC002"), wantErr: "not in ACTIVE status",
+			setup: func(t *testing.T, stub *chaincodetest.Stub) {
+				mustSubmit(t, stub, chaincodetest.Admin, suspend("ACC002"))
+			}},
+	})
+}
+
+func TestDocuments(t *testing.T) {
+	setup := func(t *testing.T, stub *chaincodetest.Stub) {
+		mustSubmit(t, stub, chaincodetest.Creditor, submitDoc("L1", "D1"))
+		mustSubmit(t, stub, chaincodetest.Creditor, submitDoc("L1", "D2"))
+		mustSubmit(t, stub, chaincodetest.Creditor, submitDoc("L2", "D3"))
+	}
+	runCases(t, setup, []txCase{
+		{name: "submit", id: chaincodetest.Debtor, call: submitDoc("L1", "D4"), event: events.DocumentSubmitted,
+			check: func(t *testing.T, stub *chaincodetest.Stub) {
+				var doc Document
+				readState(t, stub, "DOC_D4", &doc)
+				if doc.OwnerOrg != "DebtorMSP" || doc.Size != 2048 || doc.Status != "SUBMITTED" || !doc.UploadedAt.Equal(lastEvent(t, stub).Timestamp) {
+					t.Fatalf("unexpected document %+v", doc)
+				}
+				if len(stub.Keys("AUDIT_D4_SUBMIT_DOCUMENT_")) != 1 {
+					t.Fatal("no audit record written")
+				}
+			}},
+		{name: "duplicate", id: chaincodetest.Creditor, call: submitDoc("L1", "D1"), wantErr: "already exists"},
+		{name: "missing hash", id: chaincodetest.Creditor, wantErr: "required", call: func(ctx contractapi.TransactionContextInterface) error {
+			return contract.SubmitLoanDocument(ctx, "L1", "D5", "", "KYC", "application/pdf", "1", "")
+		}},
+		{name: "invalid size", id: chaincodetest.Creditor, wantErr: "invalid size", call: func(ctx contractapi.TransactionContextInterface) error {
+			return contract.SubmitLoanDocument(ctx, "L1", "D5", "abc", "KYC", "application/pdf", "big", "")
+		}},
+		{name: "GetDocument", id: chaincodetest.Admin, call: func(ctx contractapi.TransactionContextInterface) error {
+			doc, err := contract.GetDocument(ctx, "D1")
+			if err == nil && doc.Hash != sha256Hex([]byte("D1")) {
+				t.Errorf("hash = %s", doc.Hash)
+			}
+			return err
+		}},
+		{name: "GetDocument missing", id: chaincodetest.Admin, wantErr: "not found", call: func(ctx contractapi.TransactionContextInterface) error {
+			_, err := contract.GetDocument(ctx, "D9")
+			return err
+		}},
+		{name: "GetLoanDocuments", id: chaincodetest.Admin, call: func(ctx contractapi.TransactionContextInterface) error {
+			list, err := contract.GetLoanDocuments(ctx, "L1")
+			var docs []Document
+			json.Unmarshal([]byte(list), &docs)
+			if len(docs) != 2 || docs[0].DocID != "D1" || docs[1].DocID != "D2" {
+				t.Errorf("documents = %s", list)
+			}
+			return err
+		}},
+	})
+}
+
+func TestSubmitKYCFormC(t *testing.T) {
+	form := []byte(`{"name":"Asha Rao","pan":"ABCPR1234K"}`)
+	submit := func(ctx contractapi.TransactionContextInterface) error {
+		return contract.SubmitKYCFormC(ctx, "L1", "K1", "D1")
+	}
+	withForm := []chaincodetest.TxOption{chaincodetest.WithTransient(map[string][]byte{"formc": form})}
+	runCases(t, nil, []txCase{
+		{name: "admin submits", id: chaincodetest.Admin, opts: withForm, call: submit, event: events.KYCSubmitted,
+			check: func(t *testing.T, stub *chaincodetest.Stub) {
+				if string(stub.PrivateState("formc_admin_only", "K1")) != string(form) {
+					t.Fatal("Form C not stored in the admin-only collection")
+				}
+				var ref KYCReference
+				readState(t, stub, "KYC_K1", &ref)
+				if ref.Hash != sha256Hex(form) || ref.Status != "SUBMITTED" || !ref.Timestamp.Equal(lastEvent(t, stub).Timestamp) {
+					t.Fatalf("unexpected reference %+v", ref)
+				}
+				if strings.Contains(string(stub.State("KYC_K1")), "ABCPR1234K") {
+					t.Fatal("Form C leaked into public state")
+				}
+				if stub.ValidationParameter("KYC_K1") == nil {
+					t.Fatal("no key-level endorsement policy")
+				}
+				if len(stub.Keys("AUDIT_K1_SUBMIT_KYC_")) != 1 {
+					t.Fatal("no audit record written")
+				}
+			}},
+		{name: "creditor rejected", id: chaincodetest.Creditor, opts: withForm, call: submit, wantErr: "only AdminMSP can submit KYC Form-C"},
+		{name: "debtor rejected", id: chaincodetest.Debtor, opts: withForm, call: submit, wantErr: "only AdminMSP can submit KYC Form-C"},
+		{name: "missing transient", id: chaincodetest.Admin, call: submit, wantErr: "transient field 'formc' is required"},
+		{name: "missing ids", id: chaincodetest.Admin, opts: withForm, wantErr: "are required", call: func(ctx contractapi.TransactionContextInterface) error {
+			return contract.SubmitKYCFormC(ctx, "L1", "", "D1")
+		}},
+	})
+}
+
+func TestApproveKYC(t *testing.T) {
+	setup := func(t *testing.T, stub *chaincodetest.Stub) {
+		mustSubmit(t, stub, chaincodetest.Admin, func(ctx contractapi.TransactionContextInterface) error {
+			return contract.SubmitKYCFormC(ctx, "L1", "K1", "D1")
+		}, chaincodetest.WithTransient(map[string][]byte{"formc": []byte("form")}))
+	}
+	approve := func(id string, approved bool) ctxFunc {
+		return func(ctx contractapi.TransactionContextInterface) error {
+			return contract.ApproveKYC(ctx, id, approved, "checked")
+		}
+	}
+	status := func(want string) func(t *testing.T, stub *chaincodetest.Stub) {
+		return func(t *testing.T, stub *chaincodetest.Stub) {
+			var ref KYCReference
+			readState(t, stub, "KYC_K1", &ref)
+			if ref.Status != want || ref.Remarks != "checked" {
+				t.Fatalf("status %s remarks %q, want %s", ref.Status, ref.Remarks, want)
+			}
+			if at := lastEvent(t, stub).Timestamp; !ref.Timestamp.Equal(at) {
+				t.Fatalf("timestamp %s, want the transaction time %s", ref.Timestamp, at)
+			}
+		}
+	}
+	runCases(t, setup, []txCase{
+		{name: "admin approves", id: chaincodetest.Admin, call: approve("K1", true), event: events.KYCApproved, check: status("APPROVED")},
+		{name: "admin rejects", id: chaincodetest.Admin, call: approve("K1", false), event: events.KYCRejected, check: status("REJECTED")},
+		{name: "already decided", id: chaincodetest.Admin, call: approve("K1", false), wantErr: "is not pending approval",
+			setup: func(t *testing.T, stub *chaincodetest.Stub) {
+				mustSubmit(t, stub, chaincodetest.Admin, approve("K1", true))
+			}},
+		{name: "creditor rejected", id: chaincodetest.Creditor, call: approve("K1", true), wantErr: "only AdminMSP can approve KYC"},
+		{name: "debtor rejected", id: chaincodetest.Debtor, call: approve("K1", true), wantErr: "only AdminMSP can approve KYC"},
+		{name: "missing", id: chaincodetest.Admin, call: approve("K9", true), wantErr: "kyc K9 not found"},
+	})
+}
@@ -0,0 +1,122 @@
+// Package merkle builds the Merkle trees of iu-chaincode's periodic ledger
+// snapshots and checks inclusion proofs against their roots. Leaves and
+// inner nodes are hashed with distinct prefixes, as in RFC 6962, so a leaf
+// can never be passed off as an inner node. A level with an odd number of
+// nodes promotes its last node unchanged rather than duplicating it.
+//
+// The trees are built off-chain, where the chaincode's key history is
+// indexed, and only their roots are committed. Verifiers import this
+// package so that a proof handed to a court can be checked against a
+// committed root without access to the ledger.
+package merkle
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// Step is one sibling on the path from a leaf to the root
+type Step struct {
+	Hash string `json:"hash"` // hex
+	Left bool   `json:"left"` // the sibling is the left child
+}
+
+// ParsePeriod returns the bounds of a snapshot period, a month (YYYY-MM) or
+// a day (YYYY-MM-DD). The end is exclusive.
+func ParsePeriod(period string) (time.Time, time.Time, error) {
+	if from, err := time.Parse("2006-01", period); err == nil {
+		return from, from.AddDate(0, 1, 0), nil
+	}
+	if from, err := time.Parse("2006-01-02", period); err == nil {
+		return from, from.AddDate(0, 0, 1), nil
+	}
+	return time.Time{}, time.Time{}, fmt.Errorf("invalid period %q (want YYYY-MM or YYYY-MM-DD)", period)
+}
+
+// LeafData is the content of the leaf of one record version: the world
+// state key, the ID of the transaction that wrote it and the hex SHA-256
+// of the value written
+func LeafData(key, txID, valueHash string) []byte {
+	return []byte(key + "\n" + txID + "\n" + valueHash)
+}
+
+// LeafHash hashes leaf content
+func LeafHash(data []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{0x00})
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+func nodeHash(left, right []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{0x01})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+// Root returns the root of the tree over leaf hashes. The root of no
+// leaves is the SHA-256 of nothing.
+func Root(leaves [][]byte) []byte {
+	if len(leaves) == 0 {
+		sum := sha256.Sum256(nil)
+		return sum[:]
+	}
+	level := leaves
+	for len(level) > 1 {
+		level = parents(level)
+	}
+	return level[0]
+}
+
+func parents(level [][]byte) [][]byte {
+	next := make([][]byte, 0, (len(level)+1)/2)
+	for i := 0; i < len(level); i += 2 {
+		if i+1 == len(level) {
+			next = append(next, level[i])
+			continue
+		}
+		next = append(next, nodeHash(level[i], level[i+1]))
+	}
+	return next
+}
+
+// Proof returns the path from the leaf at index to the root
+func Proof(leaves [][]byte, index int) ([]Step, error) {
+	if index < 0 || index >= len(leaves) {
+		return nil, fmt.Errorf("leaf %d is not in a tree of %d leaves", index, len(leaves))
+	}
+	path := []Step{}
+	level := leaves
+	for len(level) > 1 {
+		sibling := index ^ 1
+		if sibling < len(level) {
+			path = append(path, Step{Hash: hex.EncodeToString(level[sibling]), Left: sibling < index})
+		}
+		level = parents(level)
+		index /= 2
+	}
+	return path, nil
+}
+
+// Verify reports whether path leads from the leaf of data to root, hex
+// encoded. It hashes the leaf itself, so an inner node cannot be passed off
+// as a leaf.
+func Verify(root string, data []byte, path []Step) (bool, error) {
+	h := LeafHash(data)
+	for i, step := range path {
+		sibling, err := hex.DecodeString(step.Hash)
+		if err != nil {
+			return false, fmt.Errorf("invalid hash at step %d: %v", i, err)
+		}
+		if step.Left {
+			h = nodeHash(sibling, h)
+		} else {
+			h = nodeHash(h, sibling)
+		}
+	}
+	return hex.EncodeToString(h) == root, nil
+}
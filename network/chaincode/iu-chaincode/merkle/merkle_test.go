@@ -0,0 +1,86 @@
+package merkle
+
+import (
+	"encoding/hex"
+	"fmt"
+	"testing"
+)
+
+func leafData(i int) []byte {
+	return LeafData(fmt.Sprintf("K%d", i), "tx", "h")
+}
+
+func leaves(n int) [][]byte {
+	var out [][]byte
+	for i := 0; i < n; i++ {
+		out = append(out, LeafHash(leafData(i)))
+	}
+	return out
+}
+
+func TestProofs(t *testing.T) {
+	for n := 1; n <= 9; n++ {
+		l := leaves(n)
+		root := hex.EncodeToString(Root(l))
+		for i := range l {
+			path, err := Proof(l, i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok, err := Verify(root, leafData(i), path); err != nil || !ok {
+				t.Fatalf("%d leaves: proof of leaf %d does not verify (%v)", n, i, err)
+			}
+			// The proof is bound to its leaf
+			if ok, _ := Verify(root, leafData(i+1), path); ok {
+				t.Fatalf("%d leaves: proof of leaf %d verifies another leaf", n, i)
+			}
+		}
+	}
+}
+
+func TestRoot(t *testing.T) {
+	l := leaves(3)
+	// Three leaves: the third is promoted to pair with the first two's node
+	want := nodeHash(nodeHash(l[0], l[1]), l[2])
+	if hex.EncodeToString(Root(l)) != hex.EncodeToString(want) {
+		t.Fatal("unexpected root of three leaves")
+	}
+	if hex.EncodeToString(Root(nil)) != "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855" {
+		t.Fatal("root of no leaves is not the SHA-256 of nothing")
+	}
+	// The children of an inner node, presented as leaf data, do not verify
+	inner := append(append([]byte{}, l[0]...), l[1]...)
+	if ok, _ := Verify(hex.EncodeToString(Root(l)), inner, []Step{{Hash: hex.EncodeToString(l[2])}}); ok {
+		t.Fatal("inner node accepted as a leaf")
+	}
+	if _, err := Proof(l, 3); err == nil {
+		t.Fatal("proof of a missing leaf")
+	}
+	if _, err := Verify("", leafData(0), []Step{{Hash: "zz"}}); err == nil {
+		t.Fatal("invalid hex accepted")
+	}
+}
+
+func TestParsePeriod(t *testing.T) {
+	for _, tt := range []struct {
+		period   string
+		from, to string
+	}{
+		{"2024-02", "2024-02-01", "2024-03-01"},
+		{"2024-12", "2024-12-01", "2025-01-01"},
+		{"2024-02-29", "2024-02-29", "2024-03-01"},
+		{"2024-13", "", ""},
+		{"April 2024", "", ""},
+	} {
+		from, to, err := ParsePeriod(tt.period)
+		if tt.from == "" {
+			if err == nil {
+				t.Errorf("%s: expected an error", tt.period)
+			}
+			continue
+		}
+		if err != nil || from.Format("2006-01-02") != tt.from || to.Format("2006-01-02") != tt.to {
+			t.Errorf("%s: got %s to %s (%v), want %s to %s", tt.period, from, to, err, tt.from, tt.to)
+		}
+	}
+}
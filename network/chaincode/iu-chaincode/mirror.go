@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+
+	"iu-chaincode/events"
+)
+
+// Mirrored events are verified against this chaincode on the operations
+// channel, which must be installed on the same peer
+const (
+	sourceChannel   = "financial-operations-channel"
+	sourceChaincode = "iu-chaincode"
+)
+
+// Outcomes of verifying a mirrored event
+const (
+	MirrorVerified = "VERIFIED"
+	MirrorMismatch = "MISMATCH"
+)
+
+// MirrorVerification is the result of checking a mirrored event against its source record
+type MirrorVerification struct {
+	AuditKey    string    `json:"auditKey"`
+	SourceKey   string    `json:"sourceKey"`
+	SourceTxID  string    `json:"sourceTxId"`
+	Hash        string    `json:"hash"`
+	Status      string    `json:"status"` // VERIFIED, MISMATCH
+	MatchedTxID string    `json:"matchedTxId,omitempty" metadata:",optional"`
+	Reason      string    `json:"reason,omitempty" metadata:",optional"`
+	VerifiedBy  string    `json:"verifiedBy"`
+	VerifiedAt  time.Time `json:"verifiedAt"`
+}
+
+// GetVersionHashes returns the TxID and value hash of every version of a key,
+// without the values, so another channel can check a hash without seeing PII
+func (s *IUContract) GetVersionHashes(ctx contractapi.TransactionContextInterface, key string) ([]RecordVersion, error) {
+	history, err := keyHistory(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	for i := range history {
+		history[i].Value = ""
+	}
+	return history, nil
+}
+
+// VerifyMirroredEvent checks that the hash stored by RecordAuditEvent under
+// auditKey matches a version of the source record on the operations channel,
+// read through a same-peer InvokeChaincode query. The version written by the
+// source TxID is checked first; any other version with that hash also counts.
+// The result is stored under MIRROR_CHECK_<auditKey>, or ALERT_MIRROR_<auditKey>
+// with a MIRROR_MISMATCH event when no version matches.
+func (s *IUContract) VerifyMirroredEvent(ctx contractapi.TransactionContextInterface, auditKey string) (*MirrorVerification, error) {
+	mspid, err := getMSPID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if mspid != "AdminMSP" {
+		return nil, fmt.Errorf("only AdminMSP can verify mirrored events")
+	}
+	audit, err := s.GetAuditRecord(ctx, auditKey)
+	if err != nil {
+		return nil, err
+	}
+	if audit.SourceTxID == "" {
+		return nil, fmt.Errorf("audit record %s was not mirrored from another channel", auditKey)
+	}
+
+	// The relay records an event's entity key as its refId, or the source
+	// TxID when the event changed many records
+	sourceKey := audit.TransactionID
+	if sourceKey == audit.SourceTxID {
+		return nil, fmt.Errorf("mirrored event %s is not about a single record", auditKey)
+	}
+	response := ctx.GetStub().InvokeChaincode(sourceChaincode,
+		[][]byte{[]byte("GetVersionHashes"), []byte(sourceKey)}, sourceChannel)
+	if response.Status != http.StatusOK {
+		return nil, fmt.Errorf("failed to read %s on %s: %s", sourceKey, sourceChannel, response.Message)
+	}
+	var versions []RecordVersion
+	if err := json.Unmarshal(response.Payload, &versions); err != nil {
+		return nil, fmt.Errorf("invalid version hashes for %s: %v", sourceKey, err)
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := &MirrorVerification{
+		AuditKey:   auditKey,
+		SourceKey:  sourceKey,
+		SourceTxID: audit.SourceTxID,
+		Hash:       audit.Hash,
+		Status:     MirrorMismatch,
+		VerifiedBy: mspid,
+		VerifiedAt: now,
+	}
+	for _, v := range versions {
+		if v.TxID == audit.SourceTxID {
+			if v.ValueHash == audit.Hash {
+				result.Status = MirrorVerified
+				result.MatchedTxID = v.TxID
+			} else {
+				result.Reason = fmt.Sprintf("source tx %s wrote hash %s", v.TxID, v.ValueHash)
+			}
+			break
+		}
+	}
+	if result.Status != MirrorVerified {
+		for _, v := range versions {
+			if v.ValueHash == audit.Hash {
+				result.Status = MirrorVerified
+				result.MatchedTxID = v.TxID
+				result.Reason = ""
+				break
+			}
+		}
+	}
+	if result.Status != MirrorVerified && result.Reason == "" {
+		result.Reason = fmt.Sprintf("no version of %s among %d has hash %s", sourceKey, len(versions), audit.Hash)
+	}
+
+	resultKey := fmt.Sprintf("MIRROR_CHECK_%s", auditKey)
+	eventType := events.MirrorVerified
+	if result.Status == MirrorMismatch {
+		resultKey = fmt.Sprintf("ALERT_MIRROR_%s", auditKey)
+		eventType = events.MirrorMismatch
+	}
+	if err := putJSON(ctx, resultKey, result); err != nil {
+		return nil, err
+	}
+	if err := writeAuditRecord(ctx, auditKey, "VERIFY_MIRRORED_EVENT", mspid,
+		fmt.Sprintf("Source %s tx %s: %s %s", sourceKey, audit.SourceTxID, result.Status, result.Reason),
+		result.Status, now); err != nil {
+		return nil, err
+	}
+	if err := emitEvent(ctx, eventType, resultKey, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+
+	"iu-chaincode/chaincodetest"
+	"iu-chaincode/events"
+)
+
+const auditChannel = "audit-compliance-channel"
+
+func TestGetVersionHashes(t *testing.T) {
+	setup := func(t *testing.T, stub *chaincodetest.Stub) {
+		mustSubmit(t, stub, chaincodetest.Creditor, createTx("TX1", "C1", "D1", 1000, "DEBIT"))
+		mustSubmit(t, stub, chaincodetest.Admin, complianceCheck("TX1", true))
+	}
+	runCases(t, setup, []txCase{
+		{name: "hashes without values", id: chaincodetest.Debtor, call: func(ctx contractapi.TransactionContextInterface) error {
+			versions, err := contract.GetVersionHashes(ctx, "TX1")
+			if err != nil {
+				return err
+			}
+			if len(versions) != 2 || versions[0].TxID != "tx0002" || versions[0].Value != "" {
+				t.Fatalf("versions = %+v", versions)
+			}
+			if versions[0].ValueHash != sha256Hex(ctx.GetStub().(*chaincodetest.Stub).State("TX1")) {
+				t.Errorf("latest hash does not match the current value")
+			}
+			return nil
+		}},
+	})
+}
+
+func TestVerifyMirroredEvent(t *testing.T) {
+	// The audit channel reads the operations channel's ledger through InvokeChaincode
+	source := newTestStub(t)
+	mustSubmit(t, source, chaincodetest.Creditor, createTx("TX1", "C1", "D1", 1000, "DEBIT"))
+	mustSubmit(t, source, chaincodetest.Admin, complianceCheck("TX1", true))
+	created := source.KeyHistory("TX1")[1]
+	cc, err := contractapi.NewChaincode(&IUContract{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	setup := func(t *testing.T, stub *chaincodetest.Stub) {
+		stub.RegisterChaincode(sourceChaincode, cc, source)
+		mustSubmit(t, stub, chaincodetest.Admin, recordAuditEvent("TX1", sha256Hex(created.Value), created.TxId))
+		mustSubmit(t, stub, chaincodetest.Admin, recordAuditEvent("TX1", sha256Hex(created.Value), "tx0002"))
+		mustSubmit(t, stub, chaincodetest.Admin, recordAuditEvent("TX1", "forged", "tx0099"))
+		mustSubmit(t, stub, chaincodetest.Admin, recordAuditEvent("TX1", "local", ""))
+	}
+	verify := func(auditKey, want string) ctxFunc {
+		return func(ctx contractapi.TransactionContextInterface) error {
+			result, err := contract.VerifyMirroredEvent(ctx, auditKey)
+			if err == nil && result.Status != want {
+				t.Errorf("status = %s (%s), want %s", result.Status, result.Reason, want)
+			}
+			return err
+		}
+	}
+	key := func(sourceTxID string) string { return "AUDIT_TX1_EVT_TRANSACTION_CREATED_" + sourceTxID }
+
+	for _, tc := range []txCase{
+		{name: "verified", id: chaincodetest.Admin, call: verify(key(created.TxId), MirrorVerified), event: events.MirrorVerified,
+			check: func(t *testing.T, stub *chaincodetest.Stub) {
+				var result MirrorVerification
+				readState(t, stub, "MIRROR_CHECK_"+key(created.TxId), &result)
+				if result.MatchedTxID != created.TxId || result.SourceKey != "TX1" {
+					t.Fatalf("unexpected result %+v", result)
+				}
+			}},
+		{name: "hash of an earlier version", id: chaincodetest.Admin, call: verify(key("tx0002"), MirrorVerified),
+			check: func(t *testing.T, stub *chaincodetest.Stub) {
+				var result MirrorVerification
+				readState(t, stub, "MIRROR_CHECK_"+key("tx0002"), &result)
+				if result.MatchedTxID != created.TxId {
+					t.Fatalf("matched %s, want %s", result.MatchedTxID, created.TxId)
+				}
+			}},
+		{name: "mismatch", id: chaincodetest.Admin, call: verify(key("tx0099"), MirrorMismatch), event: events.MirrorMismatch,
+			check: func(t *testing.T, stub *chaincodetest.Stub) {
+				if stub.State("ALERT_MIRROR_"+key("tx0099")) == nil {
+					t.Fatal("no alert stored")
+				}
+				// A mismatch has no matched transaction and must still match
+				// the schema when returned
+				if resp := stub.Invoke(cc, chaincodetest.Admin, []string{"VerifyMirroredEvent", key("tx0099")}); resp.Status != 200 {
+					t.Fatalf("VerifyMirroredEvent: %s", resp.Message)
+				}
+			}},
+		{name: "not mirrored", id: chaincodetest.Admin, call: verify(key(fmt.Sprint(chaincodetest.Start.Add(3*time.Minute).UnixNano())), ""),
+			wantErr: "was not mirrored from another channel"},
+		{name: "creditor cannot verify", id: chaincodetest.Creditor, call: verify(key(created.TxId), ""), wantErr: "only AdminMSP"},
+		{name: "unknown audit key", id: chaincodetest.Admin, call: verify(key("tx0100"), ""), wantErr: "does not exist"},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			stub := chaincodetest.NewStub(auditChannel)
+			setup(t, stub)
+			err := stub.Tx(tc.id, tc.call)
+			checkErr(t, err, tc.wantErr)
+			if tc.event != "" {
+				if got := lastEventType(t, stub); got != tc.event {
+					t.Fatalf("event = %s, want %s", got, tc.event)
+				}
+			}
+			if tc.check != nil {
+				tc.check(t, stub)
+			}
+			if len(source.KeyHistory("TX1")) != 2 {
+				t.Fatal("verification wrote to the operations channel")
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// caseDebtorIndex maps a debtor to its insolvency cases for moratorium checks
+const caseDebtorIndex = "CASE_DEBTOR"
+
+// courtOrderTransientKey carries the hash of the court order authorising an override
+const courtOrderTransientKey = "courtOrderHash"
+
+// validDocumentHash reports whether h is a SHA-256 hash in 64 hex characters
+func validDocumentHash(h string) bool {
+	b, err := hex.DecodeString(h)
+	return err == nil && len(b) == 32
+}
+
+func putCaseDebtorIndex(ctx contractapi.TransactionContextInterface, debtorID, caseID string) error {
+	key, err := ctx.GetStub().CreateCompositeKey(caseDebtorIndex, []string{debtorID, caseID})
+	if err != nil {
+		return err
+	}
+	return ctx.GetStub().PutState(key, []byte{0x00})
+}
+
+// activeMoratorium returns the admitted case whose moratorium covers the debtor
+// on the date of at, or nil when recovery actions were allowed then
+func (s *IUContract) activeMoratorium(ctx contractapi.TransactionContextInterface, debtorID string, at time.Time) (*InsolvencyCase, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(caseDebtorIndex, []string{debtorID})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	today := at.UTC().Format(dateLayout)
+
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		_, attrs, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil {
+			return nil, err
+		}
+		c, err := s.GetInsolvencyCase(ctx, attrs[1])
+		if err != nil {
+			return nil, err
+		}
+		// dates are YYYY-MM-DD so string comparison orders them
+		if c.Status == "ADMITTED" && today >= c.MoratoriumStart && (c.MoratoriumEnd == "" || today <= c.MoratoriumEnd) {
+			return c, nil
+		}
+	}
+	return nil, nil
+}
+
+// enforceMoratorium blocks a recovery action against a debtor under moratorium.
+// AdminMSP may proceed by passing the SHA-256 hash of the court order, in 64
+// hex characters, in the transient field 'courtOrderHash'; the override is
+// recorded as an AuditRecord. A blocked attempt must fail so that the client
+// gets a clear error, and failing endorsement discards its writes, so it
+// cannot be recorded on the ledger: it is logged to the chaincode container's
+// log, and the REST gateway and iuctl append it to their blocked-attempt log
+// when they see the error (iu-tools internal/moratorium).
+func (s *IUContract) enforceMoratorium(ctx contractapi.TransactionContextInterface, debtorID, action, refID string) error {
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	return s.enforceMoratoriumAt(ctx, debtorID, action, refID, now)
+}
+
+// enforceMoratoriumAt is enforceMoratorium for an action booked at a time
+// other than the transaction's, such as an imported historical transaction
+func (s *IUContract) enforceMoratoriumAt(ctx contractapi.TransactionContextInterface, debtorID, action, refID string, at time.Time) error {
+	c, err := s.activeMoratorium(ctx, debtorID, at)
+	if err != nil {
+		return err
+	}
+	if c == nil {
+		return nil
+	}
+
+	mspid, err := getMSPID(ctx)
+	if err != nil {
+		return err
+	}
+	transient, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return fmt.Errorf("failed to get transient: %v", err)
+	}
+	courtOrderHash := strings.ToLower(string(transient[courtOrderTransientKey]))
+
+	if mspid == "AdminMSP" && validDocumentHash(courtOrderHash) {
+		now, err := getTxTime(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("⚠️ Moratorium override for %s on %s under case %s (court order %s)\n", action, refID, c.CaseID, courtOrderHash)
+		return writeAuditRecord(ctx, refID, "MORATORIUM_OVERRIDE", mspid,
+			fmt.Sprintf("%s against %s permitted during moratorium of case %s by court order %s", action, debtorID, c.CaseID, courtOrderHash),
+			"OVERRIDDEN", now)
+	}
+
+	fmt.Printf("⛔ Moratorium blocked %s on %s by %s: debtor %s under case %s (%s)\n", action, refID, mspid, debtorID, c.CaseID, c.NCLTCaseID)
+	switch {
+	case courtOrderHash != "" && mspid != "AdminMSP":
+		return fmt.Errorf("moratorium in force for debtor %s under case %s: only AdminMSP can override with a court order", debtorID, c.CaseID)
+	case courtOrderHash != "":
+		return fmt.Errorf("moratorium in force for debtor %s under case %s: courtOrderHash must be the SHA-256 hash of the court order in 64 hex characters", debtorID, c.CaseID)
+	}
+	return fmt.Errorf("moratorium in force for debtor %s under case %s (%s) since %s: %s is not permitted", debtorID, c.CaseID, c.NCLTCaseID, c.MoratoriumStart, action)
+}
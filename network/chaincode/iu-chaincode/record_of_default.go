@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// RecordOfDefaultType identifies the certificate format so verifiers can reject other payloads
+const RecordOfDefaultType = "IU_RECORD_OF_DEFAULT"
+
+// RecordOfDefaultVersion is bumped whenever the certificate layout changes
+const RecordOfDefaultVersion = "1.0"
+
+// DocumentHash is a document reference included in a record of default
+type DocumentHash struct {
+	DocID string `json:"docId"`
+	Role  string `json:"role"` // LOAN, EVIDENCE
+	Type  string `json:"type"`
+	Hash  string `json:"hash"`
+}
+
+// DefaultHistoryEntry is one committed version of a default record
+type DefaultHistoryEntry struct {
+	TxID      string         `json:"txId"`
+	Timestamp time.Time      `json:"timestamp"`
+	IsDelete  bool           `json:"isDelete"`
+	Value     *DefaultRecord `json:"value,omitempty"`
+}
+
+// RecordOfDefault is the IU certificate a creditor attaches to an NCLT application
+type RecordOfDefault struct {
+	CertificateType string                `json:"certificateType"`
+	Version         string                `json:"version"`
+	ChannelID       string                `json:"channelId"`
+	IssuedAt        time.Time             `json:"issuedAt"`
+	AuthStatus      string                `json:"authStatus"`
+	Default         DefaultRecord         `json:"default"`
+	Documents       []DocumentHash        `json:"documents"`
+	History         []DefaultHistoryEntry `json:"history"`
+	AuditTrail      []AuditRecord         `json:"auditTrail"`
+}
+
+// GetRecordOfDefault assembles the canonical record of default certificate as JSON
+func (s *IUContract) GetRecordOfDefault(ctx contractapi.TransactionContextInterface, defaultID string) (string, error) {
+	record, err := s.GetDefault(ctx, defaultID)
+	if err != nil {
+		return "", err
+	}
+	issuedAt, err := getTxTime(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	documents := []DocumentHash{}
+	loanDocs, err := queryLoanDocuments(ctx, record.LoanID)
+	if err != nil {
+		return "", err
+	}
+	for _, d := range loanDocs {
+		documents = append(documents, DocumentHash{DocID: d.DocID, Role: "LOAN", Type: d.Type, Hash: d.Hash})
+	}
+	for _, docID := range record.EvidenceDocIDs {
+		d, err := s.GetDocument(ctx, docID)
+		if err != nil {
+			return "", err
+		}
+		documents = append(documents, DocumentHash{DocID: d.DocID, Role: "EVIDENCE", Type: d.Type, Hash: d.Hash})
+	}
+
+	history, err := defaultHistory(ctx, defaultID)
+	if err != nil {
+		return "", err
+	}
+	auditTrail, err := auditRecordsFor(ctx, defaultID)
+	if err != nil {
+		return "", err
+	}
+
+	certificate := RecordOfDefault{
+		CertificateType: RecordOfDefaultType,
+		Version:         RecordOfDefaultVersion,
+		ChannelID:       ctx.GetStub().GetChannelID(),
+		IssuedAt:        issuedAt,
+		AuthStatus:      record.AuthStatus,
+		Default:         *record,
+		Documents:       documents,
+		History:         history,
+		AuditTrail:      auditTrail,
+	}
+	b, err := json.Marshal(certificate)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func defaultHistory(ctx contractapi.TransactionContextInterface, defaultID string) ([]DefaultHistoryEntry, error) {
+	resultsIterator, err := ctx.GetStub().GetHistoryForKey(defaultKey(defaultID))
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	history := []DefaultHistoryEntry{}
+	for resultsIterator.HasNext() {
+		response, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		entry := DefaultHistoryEntry{
+			TxID:     response.TxId,
+			IsDelete: response.IsDelete,
+		}
+		if response.Timestamp != nil {
+			entry.Timestamp = response.Timestamp.AsTime()
+		}
+		if len(response.Value) > 0 {
+			var value DefaultRecord
+			if err := json.Unmarshal(response.Value, &value); err != nil {
+				return nil, err
+			}
+			entry.Value = &value
+		}
+		history = append(history, entry)
+	}
+	return history, nil
+}
+
+// auditRecordsFor returns the AuditRecords written for refID in key order
+func auditRecordsFor(ctx contractapi.TransactionContextInterface, refID string) ([]AuditRecord, error) {
+	prefix := fmt.Sprintf("AUDIT_%s_", refID)
+	resultsIterator, err := ctx.GetStub().GetStateByRange(prefix, prefix+"~")
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	records := []AuditRecord{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		var audit AuditRecord
+		if err := json.Unmarshal(queryResponse.Value, &audit); err != nil {
+			continue // Skip non-audit records sharing the prefix
+		}
+		if audit.TransactionID == refID {
+			records = append(records, audit)
+		}
+	}
+	return records, nil
+}
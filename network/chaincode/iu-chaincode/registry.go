@@ -0,0 +1,589 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+
+	"iu-chaincode/events"
+	"iu-chaincode/identifiers"
+)
+
+// Institution types
+const (
+	InstitutionTypeBank            = "BANK"
+	InstitutionTypeCooperativeBank = "COOPERATIVE_BANK"
+	InstitutionTypeNBFC            = "NBFC"
+	InstitutionTypeHFC             = "HFC"
+	InstitutionTypeARC             = "ARC"
+)
+
+// Borrower types
+const (
+	BorrowerTypeIndividual = "INDIVIDUAL"
+	BorrowerTypeCorporate  = "CORPORATE"
+)
+
+// Registry statuses of institutions and borrowers
+const (
+	PartyStatusActive    = "ACTIVE"
+	PartyStatusSuspended = "SUSPENDED"
+)
+
+// partyIdentifierIndex maps a PAN, GSTIN or CIN to the party registered with
+// it, so that one legal entity cannot be onboarded twice
+const partyIdentifierIndex = "PARTY_IDENTIFIER"
+
+// institutionMSPIndex maps an MSP to the institutions bound to it
+const institutionMSPIndex = "INSTITUTION_MSP"
+
+// FinancialInstitution is a lender onboarded by the IU. Its MSP binding is
+// the org whose submissions may name it as creditor.
+type FinancialInstitution struct {
+	InstitutionID      string    `json:"institutionId"`
+	Name               string    `json:"name"`
+	RegistrationNumber string    `json:"registrationNumber"` // RBI licence or certificate of registration
+	Type               string    `json:"type"`               // BANK, COOPERATIVE_BANK, NBFC, HFC, ARC
+	PAN                string    `json:"PAN"`
+	GSTIN              string    `json:"GSTIN"`
+	CIN                string    `json:"CIN"`
+	MSPID              string    `json:"mspId"`
+	Status             string    `json:"status"` // ACTIVE, SUSPENDED
+	StatusReason       string    `json:"statusReason"`
+	OnboardedBy        string    `json:"onboardedBy"`
+	OnboardedAt        time.Time `json:"onboardedAt"`
+	UpdatedAt          time.Time `json:"updatedAt"`
+}
+
+// Borrower is an individual or corporate debtor onboarded by the IU. Only a
+// masked Aadhaar reference is kept; contact details stay with the KYC Form-C
+// in the private collection.
+type Borrower struct {
+	BorrowerID   string    `json:"borrowerId"`
+	Name         string    `json:"name"`
+	Type         string    `json:"type"` // INDIVIDUAL, CORPORATE
+	PAN          string    `json:"PAN"`
+	CIN          string    `json:"CIN"`
+	AadhaarRef   string    `json:"aadhaarRef"` // XXXXXXXX1234
+	ClientID     string    `json:"clientId"`   // DebtorMSP identity that answers for the borrower
+	Status       string    `json:"status"`     // ACTIVE, SUSPENDED
+	StatusReason string    `json:"statusReason"`
+	OnboardedBy  string    `json:"onboardedBy"`
+	OnboardedAt  time.Time `json:"onboardedAt"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+}
+
+func institutionKey(id string) string {
+	return fmt.Sprintf("FI_%s", id)
+}
+
+func borrowerKey(id string) string {
+	return fmt.Sprintf("BORROWER_%s", id)
+}
+
+// maskAadhaarRef normalizes a masked Aadhaar reference to XXXXXXXX1234. A
+// full Aadhaar number is refused: only its last four digits may be stored.
+func maskAadhaarRef(ref string) (string, error) {
+	ref = strings.ReplaceAll(identifiers.Normalize(ref), "-", "")
+	if ref == "" {
+		return "", nil
+	}
+	if len(ref) != 12 || strings.Trim(ref[:8], "X") != "" || strings.Trim(ref[8:], "0123456789") != "" {
+		return "", fmt.Errorf("must be masked to its last four digits, as XXXXXXXX1234")
+	}
+	return ref, nil
+}
+
+func assertRegistrar(ctx contractapi.TransactionContextInterface, action string) (string, error) {
+	mspid, err := getMSPID(ctx)
+	if err != nil {
+		return "", err
+	}
+	if mspid != "AdminMSP" {
+		return "", fmt.Errorf("only AdminMSP can %s", action)
+	}
+	return mspid, nil
+}
+
+// claimIdentifiers indexes a party's identifiers, failing if another party
+// holds one of them
+func claimIdentifiers(ctx contractapi.TransactionContextInterface, partyKey string, ids map[string]string) error {
+	for _, kind := range []string{"PAN", "GSTIN", "CIN"} {
+		value := ids[kind]
+		if value == "" {
+			continue
+		}
+		indexKey, err := ctx.GetStub().CreateCompositeKey(partyIdentifierIndex, []string{kind, value})
+		if err != nil {
+			return err
+		}
+		holder, err := ctx.GetStub().GetState(indexKey)
+		if err != nil {
+			return fmt.Errorf("failed to read from world state: %v", err)
+		}
+		if holder != nil {
+			return fmt.Errorf("%s %s is already registered to %s", kind, value, holder)
+		}
+		if err := ctx.GetStub().PutState(indexKey, []byte(partyKey)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// putInstitution stores a new institution with its identifier and MSP
+// indexes. Only AdminMSP may change it afterwards.
+func putInstitution(ctx contractapi.TransactionContextInterface, institution *FinancialInstitution) error {
+	key := institutionKey(institution.InstitutionID)
+	if err := claimIdentifiers(ctx, key, map[string]string{"PAN": institution.PAN, "GSTIN": institution.GSTIN, "CIN": institution.CIN}); err != nil {
+		return err
+	}
+	if err := putJSON(ctx, key, institution); err != nil {
+		return err
+	}
+	indexKey, err := ctx.GetStub().CreateCompositeKey(institutionMSPIndex, []string{institution.MSPID, institution.InstitutionID})
+	if err != nil {
+		return err
+	}
+	if err := ctx.GetStub().PutState(indexKey, []byte{0x00}); err != nil {
+		return err
+	}
+	return setKeyEndorsers(ctx, key, "AdminMSP")
+}
+
+// putBorrower stores a new borrower with its identifier index. Only
+// AdminMSP may change it afterwards.
+func putBorrower(ctx contractapi.TransactionContextInterface, borrower *Borrower) error {
+	key := borrowerKey(borrower.BorrowerID)
+	if err := claimIdentifiers(ctx, key, map[string]string{"PAN": borrower.PAN, "CIN": borrower.CIN}); err != nil {
+		return err
+	}
+	if err := putJSON(ctx, key, borrower); err != nil {
+		return err
+	}
+	return setKeyEndorsers(ctx, key, "AdminMSP")
+}
+
+// RegisterInstitution onboards a lender and binds it to the MSP it submits from
+func (s *IUContract) RegisterInstitution(ctx contractapi.TransactionContextInterface, institutionID, name, registrationNumber, institutionType, pan, gstin, cin, mspID string) error {
+	mspid, err := assertRegistrar(ctx, "onboard institutions")
+	if err != nil {
+		return err
+	}
+	if institutionID == "" || name == "" || registrationNumber == "" || pan == "" || mspID == "" {
+		return fmt.Errorf("institutionID, name, registrationNumber, PAN and mspID are required")
+	}
+	switch institutionType {
+	case InstitutionTypeBank, InstitutionTypeCooperativeBank, InstitutionTypeNBFC, InstitutionTypeHFC, InstitutionTypeARC:
+	default:
+		return fmt.Errorf("invalid institution type %s (want BANK, COOPERATIVE_BANK, NBFC, HFC or ARC)", institutionType)
+	}
+	key := institutionKey(institutionID)
+	exists, err := s.TransactionExists(ctx, key)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("institution %s already exists", institutionID)
+	}
+	c := &identifierChecks{}
+	pan = c.check("pan", identifiers.PAN, pan)
+	gstin = c.check("gstin", identifiers.GSTIN, gstin)
+	cin = c.check("cin", identifiers.CIN, cin)
+	if err := c.err(); err != nil {
+		return err
+	}
+	if gstin != "" && identifiers.GSTINPAN(gstin) != pan {
+		c.fail("gstin", identifiers.GSTIN, gstin, fmt.Sprintf("holds PAN %s, not the institution's PAN %s", identifiers.GSTINPAN(gstin), pan))
+		return c.err()
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	institution := FinancialInstitution{
+		InstitutionID:      institutionID,
+		Name:               strings.TrimSpace(name),
+		RegistrationNumber: identifiers.Normalize(registrationNumber),
+		Type:               institutionType,
+		PAN:                pan,
+		GSTIN:              gstin,
+		CIN:                cin,
+		MSPID:              mspID,
+		Status:             PartyStatusActive,
+		OnboardedBy:        mspid,
+		OnboardedAt:        now,
+		UpdatedAt:          now,
+	}
+	if err := putInstitution(ctx, &institution); err != nil {
+		return err
+	}
+
+	if err := writeAuditRecord(ctx, institutionID, "REGISTER_INSTITUTION", mspid,
+		fmt.Sprintf("%s %s (%s) onboarded, bound to %s", institutionType, institution.Name, institution.RegistrationNumber, mspID),
+		PartyStatusActive, now); err != nil {
+		return err
+	}
+	return emitEvent(ctx, events.InstitutionRegistered, key, institution)
+}
+
+// GetInstitution returns the institution with given id
+func (s *IUContract) GetInstitution(ctx contractapi.TransactionContextInterface, institutionID string) (*FinancialInstitution, error) {
+	val, err := ctx.GetStub().GetState(institutionKey(institutionID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if val == nil {
+		return nil, fmt.Errorf("institution %s is not registered", institutionID)
+	}
+	var institution FinancialInstitution
+	if err := json.Unmarshal(val, &institution); err != nil {
+		return nil, err
+	}
+	return &institution, nil
+}
+
+// SuspendInstitution stops an institution from being named as creditor on
+// new transactions, documents and defaults
+func (s *IUContract) SuspendInstitution(ctx contractapi.TransactionContextInterface, institutionID, reason string) error {
+	return s.setInstitutionStatus(ctx, institutionID, PartyStatusSuspended, reason)
+}
+
+// ReinstateInstitution returns a suspended institution to ACTIVE
+func (s *IUContract) ReinstateInstitution(ctx contractapi.TransactionContextInterface, institutionID, remarks string) error {
+	return s.setInstitutionStatus(ctx, institutionID, PartyStatusActive, remarks)
+}
+
+func (s *IUContract) setInstitutionStatus(ctx contractapi.TransactionContextInterface, institutionID, status, reason string) error {
+	action, eventType := "SUSPEND_INSTITUTION", events.InstitutionSuspended
+	if status == PartyStatusActive {
+		action, eventType = "REINSTATE_INSTITUTION", events.InstitutionReinstated
+	}
+	mspid, err := assertRegistrar(ctx, "change an institution's status")
+	if err != nil {
+		return err
+	}
+	if reason == "" {
+		return fmt.Errorf("a reason is required")
+	}
+	institution, err := s.GetInstitution(ctx, institutionID)
+	if err != nil {
+		return err
+	}
+	if institution.Status == status {
+		return fmt.Errorf("institution %s is already %s", institutionID, status)
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	institution.Status = status
+	institution.StatusReason = reason
+	institution.UpdatedAt = now
+	key := institutionKey(institutionID)
+	if err := putJSON(ctx, key, institution); err != nil {
+		return err
+	}
+	if err := writeAuditRecord(ctx, institutionID, action, mspid, reason, status, now); err != nil {
+		return err
+	}
+	return emitEvent(ctx, eventType, key, institution)
+}
+
+// RegisterBorrower onboards an individual with a PAN, or a company with a
+// CIN or PAN. clientID is the DebtorMSP identity that may confirm or dispute
+// the borrower's defaults; without one they can only be deemed authenticated.
+func (s *IUContract) RegisterBorrower(ctx contractapi.TransactionContextInterface, borrowerID, name, borrowerType, pan, cin, aadhaarRef, clientID string) error {
+	mspid, err := assertRegistrar(ctx, "onboard borrowers")
+	if err != nil {
+		return err
+	}
+	if borrowerID == "" || name == "" {
+		return fmt.Errorf("borrowerID and name are required")
+	}
+	switch borrowerType {
+	case BorrowerTypeIndividual:
+		if pan == "" {
+			return fmt.Errorf("PAN is required for an individual")
+		}
+		if cin != "" {
+			return fmt.Errorf("an individual has no CIN")
+		}
+	case BorrowerTypeCorporate:
+		if pan == "" && cin == "" {
+			return fmt.Errorf("CIN or PAN is required for a corporate borrower")
+		}
+		if identifiers.Normalize(aadhaarRef) != "" {
+			return fmt.Errorf("a corporate borrower has no Aadhaar")
+		}
+	default:
+		return fmt.Errorf("invalid borrower type %s (want INDIVIDUAL or CORPORATE)", borrowerType)
+	}
+	key := borrowerKey(borrowerID)
+	exists, err := s.TransactionExists(ctx, key)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("borrower %s already exists", borrowerID)
+	}
+	c := &identifierChecks{}
+	pan = c.check("pan", identifiers.PAN, pan)
+	cin = c.check("cin", identifiers.CIN, cin)
+	if len(c.fields) == 0 && pan != "" {
+		// The fourth character of a PAN is its holder type
+		if individual := identifiers.PANHolderType(pan) == "individual"; individual != (borrowerType == BorrowerTypeIndividual) {
+			c.fail("pan", identifiers.PAN, pan, fmt.Sprintf("holder type %s does not match borrower type %s", identifiers.PANHolderType(pan), borrowerType))
+		}
+	}
+	if aadhaarRef, err = maskAadhaarRef(aadhaarRef); err != nil {
+		c.fail("aadhaarRef", "AADHAAR_REF", "", err.Error())
+	}
+	if err := c.err(); err != nil {
+		return err
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	borrower := Borrower{
+		BorrowerID:  borrowerID,
+		Name:        strings.TrimSpace(name),
+		Type:        borrowerType,
+		PAN:         pan,
+		CIN:         cin,
+		AadhaarRef:  aadhaarRef,
+		ClientID:    clientID,
+		Status:      PartyStatusActive,
+		OnboardedBy: mspid,
+		OnboardedAt: now,
+		UpdatedAt:   now,
+	}
+	if err := putBorrower(ctx, &borrower); err != nil {
+		return err
+	}
+
+	if err := writeAuditRecord(ctx, borrowerID, "REGISTER_BORROWER", mspid,
+		fmt.Sprintf("%s borrower %s onboarded", borrowerType, borrower.Name), PartyStatusActive, now); err != nil {
+		return err
+	}
+	return emitEvent(ctx, events.BorrowerRegistered, key, borrower)
+}
+
+// GetBorrower returns the borrower with given id
+func (s *IUContract) GetBorrower(ctx contractapi.TransactionContextInterface, borrowerID string) (*Borrower, error) {
+	val, err := ctx.GetStub().GetState(borrowerKey(borrowerID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if val == nil {
+		return nil, fmt.Errorf("borrower %s is not registered", borrowerID)
+	}
+	var borrower Borrower
+	if err := json.Unmarshal(val, &borrower); err != nil {
+		return nil, err
+	}
+	return &borrower, nil
+}
+
+// SuspendBorrower stops new loans to a borrower. Repayments, documents and
+// defaults naming the borrower are still accepted.
+func (s *IUContract) SuspendBorrower(ctx contractapi.TransactionContextInterface, borrowerID, reason string) error {
+	return s.setBorrowerStatus(ctx, borrowerID, PartyStatusSuspended, reason)
+}
+
+// ReinstateBorrower returns a suspended borrower to ACTIVE
+func (s *IUContract) ReinstateBorrower(ctx contractapi.TransactionContextInterface, borrowerID, remarks string) error {
+	return s.setBorrowerStatus(ctx, borrowerID, PartyStatusActive, remarks)
+}
+
+func (s *IUContract) setBorrowerStatus(ctx contractapi.TransactionContextInterface, borrowerID, status, reason string) error {
+	action, eventType := "SUSPEND_BORROWER", events.BorrowerSuspended
+	if status == PartyStatusActive {
+		action, eventType = "REINSTATE_BORROWER", events.BorrowerReinstated
+	}
+	mspid, err := assertRegistrar(ctx, "change a borrower's status")
+	if err != nil {
+		return err
+	}
+	if reason == "" {
+		return fmt.Errorf("a reason is required")
+	}
+	borrower, err := s.GetBorrower(ctx, borrowerID)
+	if err != nil {
+		return err
+	}
+	if borrower.Status == status {
+		return fmt.Errorf("borrower %s is already %s", borrowerID, status)
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	borrower.Status = status
+	borrower.StatusReason = reason
+	borrower.UpdatedAt = now
+	key := borrowerKey(borrowerID)
+	if err := putJSON(ctx, key, borrower); err != nil {
+		return err
+	}
+	if err := writeAuditRecord(ctx, borrowerID, action, mspid, reason, status, now); err != nil {
+		return err
+	}
+	return emitEvent(ctx, eventType, key, borrower)
+}
+
+// BindBorrowerClient binds the DebtorMSP identity that may confirm or dispute
+// a borrower's defaults, replacing any bound before. Borrowers onboarded
+// without one, such as the one InitLedger seeds, are bound this way.
+func (s *IUContract) BindBorrowerClient(ctx contractapi.TransactionContextInterface, borrowerID, clientID string) error {
+	mspid, err := assertRegistrar(ctx, "bind a borrower's client")
+	if err != nil {
+		return err
+	}
+	if clientID == "" {
+		return fmt.Errorf("clientID is required")
+	}
+	borrower, err := s.GetBorrower(ctx, borrowerID)
+	if err != nil {
+		return err
+	}
+	if borrower.ClientID == clientID {
+		return fmt.Errorf("borrower %s is already bound to that client", borrowerID)
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	details := "Client bound"
+	if borrower.ClientID != "" {
+		details = "Client replaced"
+	}
+	borrower.ClientID = clientID
+	borrower.UpdatedAt = now
+	key := borrowerKey(borrowerID)
+	if err := putJSON(ctx, key, borrower); err != nil {
+		return err
+	}
+	if err := writeAuditRecord(ctx, borrowerID, "BIND_BORROWER_CLIENT", mspid, details, borrower.Status, now); err != nil {
+		return err
+	}
+	return emitEvent(ctx, events.BorrowerClientBound, key, borrower)
+}
+
+// requireCreditor checks that creditorID is an active institution that the
+// caller's MSP may act for. AdminMSP may act for any institution.
+func (s *IUContract) requireCreditor(ctx contractapi.TransactionContextInterface, creditorID, mspid string) error {
+	institution, err := s.GetInstitution(ctx, creditorID)
+	if err != nil {
+		return fmt.Errorf("creditor %s is not a registered institution", creditorID)
+	}
+	if institution.Status != PartyStatusActive {
+		return fmt.Errorf("creditor %s is %s", creditorID, institution.Status)
+	}
+	if mspid != "AdminMSP" && institution.MSPID != mspid {
+		return fmt.Errorf("creditor %s is bound to %s, not %s", creditorID, institution.MSPID, mspid)
+	}
+	return nil
+}
+
+// requireDebtor checks that debtorID is a registered borrower, or a
+// registered institution borrowing from another. A new loan also needs the
+// debtor to be active; repayments and defaults do not.
+func (s *IUContract) requireDebtor(ctx contractapi.TransactionContextInterface, debtorID string, newLoan bool) error {
+	status := ""
+	if borrower, err := s.GetBorrower(ctx, debtorID); err == nil {
+		status = borrower.Status
+	} else if institution, err := s.GetInstitution(ctx, debtorID); err == nil {
+		status = institution.Status
+	} else {
+		return fmt.Errorf("debtor %s is not a registered borrower or institution", debtorID)
+	}
+	if newLoan && status != PartyStatusActive {
+		return fmt.Errorf("debtor %s is %s", debtorID, status)
+	}
+	return nil
+}
+
+// requireRespondent checks that the caller may confirm or dispute a default
+// against debtorID: the client bound to a borrower at onboarding, or the
+// org a debtor institution is bound to
+func (s *IUContract) requireRespondent(ctx contractapi.TransactionContextInterface, debtorID, action string) (string, error) {
+	mspid, err := getMSPID(ctx)
+	if err != nil {
+		return "", err
+	}
+	if institution, err := s.GetInstitution(ctx, debtorID); err == nil {
+		if mspid != institution.MSPID {
+			return "", fmt.Errorf("only %s can %s of institution %s", institution.MSPID, action, debtorID)
+		}
+		return mspid, nil
+	}
+	if mspid != "DebtorMSP" {
+		return "", fmt.Errorf("only DebtorMSP can %s", action)
+	}
+	borrower, err := s.GetBorrower(ctx, debtorID)
+	if err != nil {
+		return "", err
+	}
+	clientID, err := getClientID(ctx)
+	if err != nil {
+		return "", err
+	}
+	if borrower.ClientID == "" || clientID != borrower.ClientID {
+		return "", fmt.Errorf("only the client bound to borrower %s can %s", debtorID, action)
+	}
+	return mspid, nil
+}
+
+// requireParty checks that partyID is a registered borrower or institution
+func (s *IUContract) requireParty(ctx contractapi.TransactionContextInterface, partyID string) error {
+	if _, err := s.GetBorrower(ctx, partyID); err == nil {
+		return nil
+	}
+	if _, err := s.GetInstitution(ctx, partyID); err == nil {
+		return nil
+	}
+	return fmt.Errorf("party %s is not a registered borrower or institution", partyID)
+}
+
+// requireOnboardedOrg checks that a lender org submitting on its own behalf
+// has an active institution bound to it. AdminMSP acts for the IU and
+// DebtorMSP for borrowers, which are not bound to an MSP.
+func (s *IUContract) requireOnboardedOrg(ctx contractapi.TransactionContextInterface, mspid string) error {
+	if mspid == "AdminMSP" || mspid == "DebtorMSP" {
+		return nil
+	}
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(institutionMSPIndex, []string{mspid})
+	if err != nil {
+		return err
+	}
+	defer resultsIterator.Close()
+
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return err
+		}
+		_, attrs, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil {
+			return err
+		}
+		institution, err := s.GetInstitution(ctx, attrs[1])
+		if err != nil {
+			return err
+		}
+		if institution.Status == PartyStatusActive {
+			return nil
+		}
+	}
+	return fmt.Errorf("no active institution is bound to %s", mspid)
+}
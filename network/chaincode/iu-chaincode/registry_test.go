@@ -0,0 +1,219 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+
+	"iu-chaincode/chaincodetest"
+	"iu-chaincode/events"
+)
+
+func registerInstitution(id, pan, mspID string) ctxFunc {
+	return func(ctx contractapi.TransactionContextInterface) error {
+		return contract.RegisterInstitution(ctx, id, "Canara Bank", "rbi-0042", InstitutionTypeBank, pan, "", "", mspID)
+	}
+}
+
+func registerBorrower(id, borrowerType, pan, cin, aadhaarRef string) ctxFunc {
+	return func(ctx contractapi.TransactionContextInterface) error {
+		return contract.RegisterBorrower(ctx, id, "Asha Rao", borrowerType, pan, cin, aadhaarRef, "")
+	}
+}
+
+func suspendInstitution(id string) ctxFunc {
+	return func(ctx contractapi.TransactionContextInterface) error {
+		return contract.SuspendInstitution(ctx, id, "licence cancelled")
+	}
+}
+
+func suspendBorrower(id string) ctxFunc {
+	return func(ctx contractapi.TransactionContextInterface) error {
+		return contract.SuspendBorrower(ctx, id, "fraud review")
+	}
+}
+
+func TestRegisterInstitution(t *testing.T) {
+	runCases(t, nil, []txCase{
+		{name: "admin onboards", id: chaincodetest.Admin, call: registerInstitution("CAN1", " aaacc1234d ", "CreditorMSP"), event: events.InstitutionRegistered,
+			check: func(t *testing.T, stub *chaincodetest.Stub) {
+				var fi FinancialInstitution
+				readState(t, stub, "FI_CAN1", &fi)
+				if fi.PAN != "AAACC1234D" || fi.RegistrationNumber != "RBI-0042" || fi.Status != PartyStatusActive || fi.MSPID != "CreditorMSP" {
+					t.Fatalf("institution %+v", fi)
+				}
+				if stub.ValidationParameter("FI_CAN1") == nil || len(stub.Keys("AUDIT_CAN1_REGISTER_INSTITUTION_")) != 1 {
+					t.Fatal("institution not protected or not audited")
+				}
+			}},
+		{name: "creditor cannot onboard", id: chaincodetest.Creditor, call: registerInstitution("CAN1", "AAACC1234D", "CreditorMSP"), wantErr: "only AdminMSP"},
+		{name: "duplicate ID", id: chaincodetest.Admin, call: registerInstitution("C1", "AAACC1234D", "CreditorMSP"), wantErr: "institution C1 already exists"},
+		{name: "duplicate PAN", id: chaincodetest.Admin, call: registerInstitution("CAN1", "AAACB0000A", "CreditorMSP"), wantErr: "PAN AAACB0000A is already registered to FI_C1"},
+		{name: "PAN required", id: chaincodetest.Admin, call: registerInstitution("CAN1", "", "CreditorMSP"), wantErr: "are required"},
+		{name: "invalid type", id: chaincodetest.Admin, wantErr: "invalid institution type", call: func(ctx contractapi.TransactionContextInterface) error {
+			return contract.RegisterInstitution(ctx, "CAN1", "Canara Bank", "RBI-0042", "Bank", "AAACC1234D", "", "", "CreditorMSP")
+		}},
+		{name: "GSTIN of the institution's PAN", id: chaincodetest.Admin, call: func(ctx contractapi.TransactionContextInterface) error {
+			return contract.RegisterInstitution(ctx, "CAN1", "Canara Bank", "RBI-0042", InstitutionTypeBank, "AAPFU0939F", "27aapfu0939f1zv", "L65110MH1994PLC080618", "CreditorMSP")
+		}},
+		{name: "GSTIN of another PAN", id: chaincodetest.Admin, wantErr: "holds PAN AAPFU0939F, not the institution's PAN AAACC1234D", call: func(ctx contractapi.TransactionContextInterface) error {
+			return contract.RegisterInstitution(ctx, "CAN1", "Canara Bank", "RBI-0042", InstitutionTypeBank, "AAACC1234D", "27AAPFU0939F1ZV", "", "CreditorMSP")
+		}},
+		{name: "invalid identifiers", id: chaincodetest.Admin, call: func(ctx contractapi.TransactionContextInterface) error {
+			err := contract.RegisterInstitution(ctx, "CAN1", "Canara Bank", "RBI-0042", InstitutionTypeBank, "AAAXC1234D", "27AAPFU0939F1ZW", "U12345MH2010XYZ123456", "CreditorMSP")
+			want := []FieldError{
+				{Field: "pan", Kind: "PAN", Value: "AAAXC1234D", Reason: "unknown holder type X"},
+				{Field: "gstin", Kind: "GSTIN", Value: "27AAPFU0939F1ZW", Reason: "check character is W, want V"},
+				{Field: "cin", Kind: "CIN", Value: "U12345MH2010XYZ123456", Reason: "unknown ownership class XYZ"},
+			}
+			if ve, ok := err.(*ValidationError); !ok || !reflect.DeepEqual(ve.Fields, want) {
+				t.Errorf("error %v, want fields %+v", err, want)
+			}
+			return nil
+		}},
+		{name: "GetInstitution", id: chaincodetest.Debtor, call: func(ctx contractapi.TransactionContextInterface) error {
+			fi, err := contract.GetInstitution(ctx, "C1")
+			if err == nil && fi.Name != "Bank C1" {
+				t.Errorf("institution %+v", fi)
+			}
+			return err
+		}},
+		{name: "GetInstitution missing", id: chaincodetest.Debtor, wantErr: "is not registered", call: func(ctx contractapi.TransactionContextInterface) error {
+			_, err := contract.GetInstitution(ctx, "C9")
+			return err
+		}},
+	})
+}
+
+func TestRegisterBorrower(t *testing.T) {
+	runCases(t, nil, []txCase{
+		{name: "individual", id: chaincodetest.Admin, call: registerBorrower("B1", BorrowerTypeIndividual, "abcpr1234k", "", "xxxx xxxx 4321"), event: events.BorrowerRegistered,
+			check: func(t *testing.T, stub *chaincodetest.Stub) {
+				var b Borrower
+				readState(t, stub, "BORROWER_B1", &b)
+				if b.PAN != "ABCPR1234K" || b.AadhaarRef != "XXXXXXXX4321" || b.Status != PartyStatusActive {
+					t.Fatalf("borrower %+v", b)
+				}
+			}},
+		{name: "corporate with CIN", id: chaincodetest.Admin, call: registerBorrower("B1", BorrowerTypeCorporate, "", "U12345MH2010PTC123456", "")},
+		{name: "full Aadhaar", id: chaincodetest.Admin, call: registerBorrower("B1", BorrowerTypeIndividual, "ABCPR1234K", "", "1234 5678 9012"), wantErr: "must be masked"},
+		{name: "individual without PAN", id: chaincodetest.Admin, call: registerBorrower("B1", BorrowerTypeIndividual, "", "", ""), wantErr: "PAN is required"},
+		{name: "corporate without identifiers", id: chaincodetest.Admin, call: registerBorrower("B1", BorrowerTypeCorporate, "", "", ""), wantErr: "CIN or PAN is required"},
+		{name: "corporate with Aadhaar", id: chaincodetest.Admin, call: registerBorrower("B1", BorrowerTypeCorporate, "AAACR1234K", "", "XXXXXXXX4321"), wantErr: "has no Aadhaar"},
+		{name: "company PAN for an individual", id: chaincodetest.Admin, call: registerBorrower("B1", BorrowerTypeIndividual, "ABCCR1234K", "", ""),
+			wantErr: "holder type company does not match borrower type INDIVIDUAL"},
+		{name: "personal PAN for a company", id: chaincodetest.Admin, call: registerBorrower("B1", BorrowerTypeCorporate, "ABCPR1234K", "", ""),
+			wantErr: "holder type individual does not match borrower type CORPORATE"},
+		{name: "invalid CIN", id: chaincodetest.Admin, call: registerBorrower("B1", BorrowerTypeCorporate, "", "U12345ZZ2010PTC123456", ""), wantErr: `"reason":"unknown state ZZ"`},
+		{name: "Aadhaar is not echoed", id: chaincodetest.Admin, call: func(ctx contractapi.TransactionContextInterface) error {
+			err := contract.RegisterBorrower(ctx, "B1", "Asha Rao", BorrowerTypeIndividual, "ABCPR1234K", "", "1234 5678 9012", "")
+			if err == nil || strings.Contains(err.Error(), "9012") {
+				t.Errorf("error %v", err)
+			}
+			return nil
+		}},
+		{name: "duplicate PAN", id: chaincodetest.Admin, call: registerBorrower("B1", BorrowerTypeIndividual, "AAAPB0000A", "", ""), wantErr: "already registered to BORROWER_D1"},
+		{name: "creditor cannot onboard", id: chaincodetest.Creditor, call: registerBorrower("B1", BorrowerTypeIndividual, "ABCPR1234K", "", ""), wantErr: "only AdminMSP"},
+	})
+}
+
+func TestPartyStatus(t *testing.T) {
+	runCases(t, nil, []txCase{
+		{name: "suspend institution", id: chaincodetest.Admin, call: suspendInstitution("C1"), event: events.InstitutionSuspended,
+			check: func(t *testing.T, stub *chaincodetest.Stub) {
+				var fi FinancialInstitution
+				readState(t, stub, "FI_C1", &fi)
+				if fi.Status != PartyStatusSuspended || fi.StatusReason != "licence cancelled" || len(stub.Keys("AUDIT_C1_SUSPEND_INSTITUTION_")) != 1 {
+					t.Fatalf("institution %+v", fi)
+				}
+			}},
+		{name: "reinstate institution", id: chaincodetest.Admin, setup: admin(suspendInstitution("C1")), event: events.InstitutionReinstated,
+			call: func(ctx contractapi.TransactionContextInterface) error {
+				return contract.ReinstateInstitution(ctx, "C1", "licence restored")
+			}},
+		{name: "already active", id: chaincodetest.Admin, wantErr: "already ACTIVE", call: func(ctx contractapi.TransactionContextInterface) error {
+			return contract.ReinstateInstitution(ctx, "C1", "licence restored")
+		}},
+		{name: "reason required", id: chaincodetest.Admin, wantErr: "reason is required", call: func(ctx contractapi.TransactionContextInterface) error {
+			return contract.SuspendInstitution(ctx, "C1", "")
+		}},
+		{name: "creditor cannot suspend", id: chaincodetest.Creditor, call: suspendInstitution("C1"), wantErr: "only AdminMSP"},
+		{name: "suspend borrower", id: chaincodetest.Admin, call: suspendBorrower("D1"), event: events.BorrowerSuspended},
+		{name: "reinstate borrower", id: chaincodetest.Admin, setup: admin(suspendBorrower("D1")), event: events.BorrowerReinstated,
+			call: func(ctx contractapi.TransactionContextInterface) error {
+				return contract.ReinstateBorrower(ctx, "D1", "cleared")
+			}},
+		{name: "unknown borrower", id: chaincodetest.Admin, call: suspendBorrower("D9"), wantErr: "borrower D9 is not registered"},
+	})
+}
+
+func TestBindBorrowerClient(t *testing.T) {
+	// InitLedger seeds DEBTOR001 without a client
+	setup := func(t *testing.T, stub *chaincodetest.Stub) {
+		mustSubmit(t, stub, chaincodetest.Admin, contract.InitLedger)
+		mustSubmit(t, stub, chaincodetest.Creditor, fileDefault("DEF1", "L1", "DEBTOR001"))
+	}
+	bind := func(borrowerID, clientID string) ctxFunc {
+		return func(ctx contractapi.TransactionContextInterface) error {
+			return contract.BindBorrowerClient(ctx, borrowerID, clientID)
+		}
+	}
+	confirm := func(ctx contractapi.TransactionContextInterface) error {
+		return contract.ConfirmDefault(ctx, "DEF1")
+	}
+	runCases(t, setup, []txCase{
+		{name: "unbound borrower cannot respond", id: chaincodetest.Debtor, call: confirm, wantErr: "only the client bound to borrower DEBTOR001"},
+		{name: "admin binds", id: chaincodetest.Admin, call: bind("DEBTOR001", chaincodetest.Debtor.ID()), event: events.BorrowerClientBound,
+			check: func(t *testing.T, stub *chaincodetest.Stub) {
+				var b Borrower
+				readState(t, stub, borrowerKey("DEBTOR001"), &b)
+				if b.ClientID != chaincodetest.Debtor.ID() || len(stub.Keys("AUDIT_DEBTOR001_BIND_BORROWER_CLIENT_")) != 1 {
+					t.Fatalf("borrower %+v", b)
+				}
+			}},
+		{name: "bound client confirms", id: chaincodetest.Debtor, call: confirm, event: events.DefaultConfirmed,
+			setup: admin(bind("DEBTOR001", chaincodetest.Debtor.ID()))},
+		{name: "creditor cannot bind", id: chaincodetest.Creditor, call: bind("DEBTOR001", "x"), wantErr: "only AdminMSP"},
+		{name: "client required", id: chaincodetest.Admin, call: bind("DEBTOR001", ""), wantErr: "clientID is required"},
+		{name: "already bound", id: chaincodetest.Admin, call: bind("D1", chaincodetest.Debtor.ID()), wantErr: "already bound to that client"},
+		{name: "unknown borrower", id: chaincodetest.Admin, call: bind("D9", "x"), wantErr: "borrower D9 is not registered"},
+	})
+}
+
+// admin returns a case setup that submits fn as AdminMSP
+func admin(fn ctxFunc) func(t *testing.T, stub *chaincodetest.Stub) {
+	return func(t *testing.T, stub *chaincodetest.Stub) {
+		mustSubmit(t, stub, chaincodetest.Admin, fn)
+	}
+}
+
+func TestPartyValidation(t *testing.T) {
+	form := chaincodetest.WithTransient(map[string][]byte{"formc": []byte(`{}`)})
+	otherBank := admin(registerInstitution("OTHER", "AAACO1234D", "OtherMSP"))
+	runCases(t, nil, []txCase{
+		{name: "unregistered creditor", id: chaincodetest.Creditor, call: createTx("TX1", "C9", "D1", 1000, "DEBIT"), wantErr: "creditor C9 is not a registered institution"},
+		{name: "unregistered debtor", id: chaincodetest.Creditor, call: createTx("TX1", "C1", "D9", 1000, "DEBIT"), wantErr: "debtor D9 is not a registered borrower or institution"},
+		{name: "interbank loan", id: chaincodetest.Creditor, call: createTx("TX1", "C1", "C2", 1000, "DEBIT")},
+		{name: "creditor bound to another MSP", id: chaincodetest.Creditor, setup: otherBank, call: createTx("TX1", "OTHER", "D1", 1000, "DEBIT"),
+			wantErr: "creditor OTHER is bound to OtherMSP, not CreditorMSP"},
+		{name: "admin acts for any creditor", id: chaincodetest.Admin, setup: otherBank, call: createTx("TX1", "OTHER", "D1", 1000, "DEBIT")},
+		{name: "suspended creditor", id: chaincodetest.Creditor, setup: admin(suspendInstitution("C1")), call: createTx("TX1", "C1", "D1", 1000, "CREDIT"), wantErr: "creditor C1 is SUSPENDED"},
+		{name: "no loan to suspended borrower", id: chaincodetest.Creditor, setup: admin(suspendBorrower("D1")), call: createTx("TX1", "C1", "D1", 1000, "DEBIT"), wantErr: "debtor D1 is SUSPENDED"},
+		{name: "repayment by suspended borrower", id: chaincodetest.Creditor, setup: admin(suspendBorrower("D1")), call: createTx("TX1", "C1", "D1", 1000, "CREDIT")},
+		{name: "default against suspended borrower", id: chaincodetest.Creditor, setup: admin(suspendBorrower("D1")), call: fileDefault("DEF1", "L1", "D1")},
+		{name: "default against unregistered debtor", id: chaincodetest.Creditor, call: fileDefault("DEF1", "L1", "D9"), wantErr: "debtor D9 is not a registered"},
+		{name: "default by suspended creditor", id: chaincodetest.Creditor, setup: admin(suspendInstitution("C1")), call: fileDefault("DEF1", "L1", "D1"), wantErr: "creditor C1 is SUSPENDED"},
+		{name: "KYC of unregistered party", id: chaincodetest.Admin, opts: []chaincodetest.TxOption{form}, wantErr: "party D9 is not a registered", call: func(ctx contractapi.TransactionContextInterface) error {
+			return contract.SubmitKYCFormC(ctx, "L1", "K1", "D9")
+		}},
+		{name: "document from org without active institution", id: chaincodetest.Creditor, call: submitDoc("L1", "DOC1"), wantErr: "no active institution is bound to CreditorMSP",
+			setup: func(t *testing.T, stub *chaincodetest.Stub) {
+				mustSubmit(t, stub, chaincodetest.Admin, suspendInstitution("C1"))
+				mustSubmit(t, stub, chaincodetest.Admin, suspendInstitution("C2"))
+			}},
+		{name: "document while one institution is active", id: chaincodetest.Creditor, setup: admin(suspendInstitution("C1")), call: submitDoc("L1", "DOC1")},
+		{name: "document from borrower", id: chaincodetest.Debtor, call: submitDoc("L1", "DOC1")},
+	})
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+
+	"iu-chaincode/simulator"
+)
+
+var (
+	simSeed    = flag.Int64("sim.seed", 1, "first scenario seed")
+	simRuns    = flag.Int("sim.runs", 3, "number of seeds to simulate, starting at -sim.seed")
+	simLoans   = flag.Int("sim.loans", 12, "loans per scenario")
+	simVerbose = flag.Bool("sim.v", false, "log every simulated step")
+)
+
+// TestSimulation replays seeded multi-org loan lifecycles through the
+// contract and checks the ledger invariants after each scenario
+func TestSimulation(t *testing.T) {
+	cc, err := contractapi.NewChaincode(&IUContract{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Transactions a minute apart, and 10ms apart so that many share a
+	// second and their timestamps, which drop trailing zeros, no longer
+	// sort as text: .1 sorts after .11
+	for _, step := range []time.Duration{time.Minute, 10 * time.Millisecond} {
+		for seed := *simSeed; seed < *simSeed+int64(*simRuns); seed++ {
+			t.Run(fmt.Sprintf("step=%s/seed=%d", step, seed), func(t *testing.T) {
+				cfg := simulator.DefaultConfig(seed)
+				cfg.Loans = *simLoans
+				if *simVerbose {
+					cfg.Logf = t.Logf
+				}
+				stub := newTestStub(t)
+				stub.SetStep(step)
+				report, err := simulator.Run(simulator.NewStubDriver(stub, cc), cfg)
+				if err != nil {
+					t.Fatal(err)
+				}
+				t.Log(report.Summary())
+				for _, v := range report.Violations {
+					t.Error(v)
+				}
+				if !report.OK() {
+					t.Logf("replay with: go test -run 'TestSimulation/step=%s/seed=%d' -sim.seed=%d -sim.runs=1 -sim.v", step, seed, seed)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,433 @@
+package simulator
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Ledger records as the contract returns them, decoded with only the fields
+// the invariants need
+type (
+	ledgerTx struct {
+		ID              string  `json:"id"`
+		CreditorID      string  `json:"creditorId"`
+		DebtorID        string  `json:"debtorId"`
+		Amount          float64 `json:"amount"`
+		TransactionType string  `json:"transactionType"`
+		Status          string  `json:"status"`
+		Hash            string  `json:"hash"`
+		PreviousHash    string  `json:"previousHash"`
+	}
+
+	ledgerDefault struct {
+		Amount     float64 `json:"amount"`
+		AuthStatus string  `json:"authStatus"`
+	}
+
+	recordVersion struct {
+		TxID      string    `json:"txId"`
+		Timestamp time.Time `json:"timestamp"`
+		IsDelete  bool      `json:"isDelete"`
+		Value     string    `json:"value"`
+		ValueHash string    `json:"valueHash"`
+	}
+
+	recordEvidence struct {
+		Value     string          `json:"value"`
+		ValueHash string          `json:"valueHash"`
+		History   []recordVersion `json:"history"`
+	}
+
+	auditRecord struct {
+		TransactionID    string    `json:"transactionId"`
+		Action           string    `json:"action"`
+		Timestamp        time.Time `json:"timestamp"`
+		Details          string    `json:"details"`
+		ComplianceStatus string    `json:"complianceStatus"`
+		TxID             string    `json:"txId"`
+	}
+
+	claimsRegister struct {
+		Claims []struct {
+			CreditorID     string  `json:"creditorId"`
+			ClaimAmount    float64 `json:"claimAmount"`
+			AdmittedAmount float64 `json:"admittedAmount"`
+			FormCHash      string  `json:"formcHash"`
+			Status         string  `json:"status"`
+			VotingShare    float64 `json:"votingShare"`
+		} `json:"claims"`
+		TotalClaimed  float64 `json:"totalClaimed"`
+		TotalAdmitted float64 `json:"totalAdmitted"`
+	}
+)
+
+// transitions lists the statuses each record kind may move to; "" is the
+// status a record must be created with
+var transitions = map[string]map[string][]string{
+	"transaction": {
+		"":        {"PENDING"},
+		"PENDING": {"PENDING", "FAILED", "COMPLETED"}, // a passed compliance check keeps it PENDING
+	},
+	"default": {
+		"":                       {"PENDING_AUTHENTICATION"},
+		"PENDING_AUTHENTICATION": {"AUTHENTICATED", "DISPUTED", "DEEMED_AUTHENTICATED"},
+	},
+	"kyc": {
+		"":          {"SUBMITTED"},
+		"SUBMITTED": {"APPROVED", "REJECTED"},
+	},
+	"claim": {
+		"":          {"SUBMITTED"},
+		"SUBMITTED": {"ADMITTED", "REJECTED"},
+	},
+}
+
+// tolerance absorbs float rounding in sums of amounts
+const tolerance = 0.005
+
+func (s *sim) claimsRegister(caseID string) (*claimsRegister, error) {
+	var register claimsRegister
+	if err := s.evaluate(&register, s.admin, "GetClaimsRegister", caseID); err != nil {
+		return nil, err
+	}
+	return &register, nil
+}
+
+// checkInvariants reads the ledger back and compares it with the model
+func (s *sim) checkInvariants() {
+	ledger := s.checkTransactions()
+	s.checkConservation(ledger)
+	s.checkHashChains(ledger)
+	s.checkEvidence()
+	s.checkDefaults()
+	s.checkKYC()
+	s.checkClaims()
+	s.checkAudit()
+}
+
+// checkTransactions reads every transaction of the scenario and compares its status with the model
+func (s *sim) checkTransactions() map[string]*ledgerTx {
+	ledger := map[string]*ledgerTx{}
+	for _, id := range s.txOrder {
+		var tx ledgerTx
+		if err := s.evaluate(&tx, s.admin, "ReadTransaction", id); err != nil {
+			s.violate("conservation", "transaction "+id, err.Error(), 0)
+			continue
+		}
+		ledger[id] = &tx
+		want := s.txs[id]
+		if tx.Status != want.status || tx.TransactionType != want.typ || math.Abs(tx.Amount-want.amount) > tolerance {
+			s.violate("conservation", "transaction "+id, fmt.Sprintf("ledger has %s %s %.2f, model has %s %s %.2f",
+				tx.TransactionType, tx.Status, tx.Amount, want.typ, want.status, want.amount), 0)
+		}
+	}
+	return ledger
+}
+
+// checkConservation checks that disbursed money is either repaid or
+// outstanding on every loan and borrower, and that seeded balances are untouched
+func (s *sim) checkConservation(ledger map[string]*ledgerTx) {
+	outstanding := map[string]float64{} // loan to completed DEBIT less CREDIT
+	exposure := map[string]float64{}    // borrower to non-failed DEBIT less CREDIT
+	for _, id := range s.txOrder {
+		tx, ok := ledger[id]
+		if !ok {
+			continue
+		}
+		sign := 1.0
+		if tx.TransactionType == "CREDIT" {
+			sign = -1
+		}
+		if tx.Status == "COMPLETED" {
+			outstanding[s.txs[id].loan] += sign * tx.Amount
+		}
+		if tx.Status != "FAILED" {
+			exposure[tx.DebtorID] += sign * tx.Amount
+		}
+	}
+
+	for _, l := range s.loans {
+		if math.Abs(l.disbursed-l.repaid-l.balance) > tolerance {
+			s.violate("conservation", l.id, fmt.Sprintf("model disbursed %.2f but repaid %.2f and outstanding %.2f", l.disbursed, l.repaid, l.balance), 0)
+		}
+		if got := outstanding[l.id]; math.Abs(got-l.balance) > tolerance {
+			s.violate("conservation", l.id, fmt.Sprintf("ledger outstanding %.2f, model outstanding %.2f", got, l.balance), 0)
+		}
+		if l.balance < -tolerance {
+			s.violate("conservation", l.id, fmt.Sprintf("repaid %.2f more than disbursed", -l.balance), 0)
+		}
+	}
+
+	for _, b := range s.borrowers {
+		var got struct {
+			DirectExposure float64 `json:"directExposure"`
+		}
+		if err := s.evaluate(&got, s.admin, "GetExposureByParty", b.Name); err != nil {
+			s.violate("conservation", "exposure of "+b.Name, err.Error(), 0)
+			continue
+		}
+		if want := math.Max(exposure[b.Name], 0); math.Abs(got.DirectExposure-want) > tolerance {
+			s.violate("conservation", "exposure of "+b.Name, fmt.Sprintf("contract reports %.2f, transactions sum to %.2f", got.DirectExposure, want), 0)
+		}
+	}
+
+	for _, id := range seededAccounts {
+		var account struct {
+			Balance float64 `json:"balance"`
+		}
+		if err := s.evaluate(&account, s.admin, "ReadAccount", id); err != nil {
+			s.violate("conservation", "account "+id, err.Error(), 0)
+			continue
+		}
+		if math.Abs(account.Balance-s.balances[id]) > tolerance {
+			s.violate("conservation", "account "+id, fmt.Sprintf("balance changed from %.2f to %.2f", s.balances[id], account.Balance), 0)
+		}
+	}
+}
+
+// checkHashChains checks that each transaction links to the transaction
+// created just before it between the same creditor and debtor, or to nothing
+// if it is the first
+func (s *sim) checkHashChains(ledger map[string]*ledgerTx) {
+	last := map[string]*ledgerTx{} // creditor/debtor to the transaction created last
+	for _, id := range s.txOrder {
+		tx, ok := ledger[id]
+		if !ok {
+			continue
+		}
+		pair := tx.CreditorID + "/" + tx.DebtorID
+		prev := last[pair]
+		switch {
+		case prev == nil && tx.PreviousHash != "":
+			s.violate("hash-chain", "transaction "+id, fmt.Sprintf("first transaction of %s links to %s", pair, tx.PreviousHash), 0)
+		case prev != nil && tx.PreviousHash != prev.Hash:
+			s.violate("hash-chain", "transaction "+id, fmt.Sprintf("previous hash %q is not the hash %q of %s, the transaction of %s before it", tx.PreviousHash, prev.Hash, prev.ID, pair), 0)
+		}
+		last[pair] = tx
+	}
+}
+
+// checkEvidence checks every version of the records the scenario wrote:
+// value hashes match the stored bytes, the history holds exactly the model's
+// writes and statuses only moved along allowed transitions
+func (s *sim) checkEvidence() {
+	keys := make([]string, 0, len(s.writers))
+	for key := range s.writers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		recordType, id, _ := strings.Cut(key, " ")
+		var ev recordEvidence
+		if err := s.evaluate(&ev, s.admin, "GetRecordEvidence", recordType, id); err != nil {
+			s.violate("hash-chain", key, err.Error(), 0)
+			continue
+		}
+		if ev.ValueHash != hashHex([]byte(ev.Value)) {
+			s.violate("hash-chain", key, "value hash does not match the stored value", 0)
+		}
+
+		writers := s.writers[key]
+		if len(ev.History) != len(writers) {
+			s.violate("hash-chain", key, fmt.Sprintf("history has %d versions, model wrote %d", len(ev.History), len(writers)), 0)
+		}
+		history := append([]recordVersion(nil), ev.History...)
+		sort.SliceStable(history, func(i, j int) bool { return history[i].Timestamp.Before(history[j].Timestamp) })
+		var statuses []string
+		for _, v := range history {
+			if !contains(writers, v.TxID) {
+				s.violate("hash-chain", key, fmt.Sprintf("version written by %s, which the model did not submit", v.TxID), 0)
+			}
+			if v.IsDelete {
+				s.violate("status-transition", key, fmt.Sprintf("deleted by %s", v.TxID), 0)
+				continue
+			}
+			if v.ValueHash != hashHex([]byte(v.Value)) {
+				s.violate("hash-chain", key, fmt.Sprintf("value hash of version %s does not match its value", v.TxID), 0)
+			}
+			var status struct {
+				Status     string `json:"status"`
+				AuthStatus string `json:"authStatus"`
+			}
+			if err := json.Unmarshal([]byte(v.Value), &status); err != nil {
+				s.violate("hash-chain", key, fmt.Sprintf("version %s is not JSON: %v", v.TxID, err), 0)
+				continue
+			}
+			statuses = append(statuses, status.Status+status.AuthStatus)
+		}
+		if len(history) > 0 && history[len(history)-1].TxID != writers[len(writers)-1] {
+			s.violate("hash-chain", key, fmt.Sprintf("latest version written by %s, model's last write was %s", history[len(history)-1].TxID, writers[len(writers)-1]), 0)
+		}
+
+		switch recordType {
+		case "TRANSACTION":
+			s.checkTransitions("transaction", key, statuses)
+		case "DEFAULT":
+			s.checkTransitions("default", key, statuses)
+		case "DOCUMENT":
+			var doc struct {
+				Hash string `json:"hash"`
+			}
+			if err := json.Unmarshal([]byte(ev.Value), &doc); err == nil && doc.Hash != s.docs[id] {
+				s.violate("hash-chain", key, fmt.Sprintf("document hash %s, submitted %s", doc.Hash, s.docs[id]), 0)
+			}
+		}
+	}
+}
+
+// checkTransitions checks a record's statuses, oldest first, against the allowed transitions
+func (s *sim) checkTransitions(kind, subject string, statuses []string) {
+	prev := ""
+	for _, status := range statuses {
+		if !contains(transitions[kind][prev], status) {
+			from := prev
+			if from == "" {
+				from = "(new)"
+			}
+			s.violate("status-transition", subject, fmt.Sprintf("%s -> %s is not allowed for a %s", from, status, kind), 0)
+		}
+		prev = status
+	}
+}
+
+func (s *sim) checkDefaults() {
+	for _, id := range s.defOrder {
+		want := s.defaults[id]
+		var got ledgerDefault
+		if err := s.evaluate(&got, s.admin, "GetDefault", id); err != nil {
+			s.violate("conservation", "default "+id, err.Error(), 0)
+			continue
+		}
+		if math.Abs(got.Amount-want.amount) > tolerance {
+			s.violate("conservation", "default "+id, fmt.Sprintf("amount %.2f, outstanding when filed %.2f", got.Amount, want.amount), 0)
+		}
+		if got.AuthStatus != want.status {
+			s.violate("status-transition", "default "+id, fmt.Sprintf("ledger status %s, model status %s", got.AuthStatus, want.status), 0)
+		}
+	}
+}
+
+// checkKYC follows KYC references through their audit trail, since the
+// contract has no query for them
+func (s *sim) checkKYC() {
+	for _, id := range s.kycOrder {
+		kyc := s.kycs[id]
+		var trail []auditRecord
+		if err := s.evaluate(&trail, s.admin, "GetAuditTrail", id); err != nil {
+			s.violate("audit", "kyc "+id, err.Error(), 0)
+			continue
+		}
+		var statuses []string
+		for _, r := range trail {
+			switch r.Action {
+			case "SUBMIT_KYC":
+				if !strings.HasSuffix(r.Details, "hash "+hashHex(kyc.formc)) {
+					s.violate("hash-chain", "kyc "+id, "submitted hash does not match the Form-C sent", 0)
+				}
+				fallthrough
+			case "APPROVE_KYC", "REJECT_KYC":
+				statuses = append(statuses, r.ComplianceStatus)
+			}
+		}
+		s.checkTransitions("kyc", "kyc "+id, statuses)
+		if len(statuses) == 0 || statuses[len(statuses)-1] != kyc.status {
+			s.violate("status-transition", "kyc "+id, fmt.Sprintf("audit trail %v does not end in model status %s", statuses, kyc.status), 0)
+		}
+	}
+}
+
+func (s *sim) checkClaims() {
+	byCase := map[string][]*claimModel{}
+	var caseIDs []string
+	for _, key := range s.claimKeys {
+		claim := s.claims[key]
+		if _, ok := byCase[claim.caseID]; !ok {
+			caseIDs = append(caseIDs, claim.caseID)
+		}
+		byCase[claim.caseID] = append(byCase[claim.caseID], claim)
+		s.checkTransitions("claim", "claim "+key, claim.observed)
+	}
+
+	for _, caseID := range caseIDs {
+		register, err := s.claimsRegister(caseID)
+		if err != nil {
+			s.violate("conservation", "case "+caseID, err.Error(), 0)
+			continue
+		}
+		claimed, admitted, shares := 0.0, 0.0, 0.0
+		for _, c := range register.Claims {
+			claimed += c.ClaimAmount
+			admitted += c.AdmittedAmount
+			shares += c.VotingShare
+			if c.AdmittedAmount > c.ClaimAmount+tolerance {
+				s.violate("conservation", "claim "+claimKey(caseID, c.CreditorID), fmt.Sprintf("admitted %.2f exceeds claimed %.2f", c.AdmittedAmount, c.ClaimAmount), 0)
+			}
+			want, ok := s.claims[claimKey(caseID, c.CreditorID)]
+			if !ok {
+				continue
+			}
+			if c.FormCHash != hashHex(want.formc) {
+				s.violate("hash-chain", "claim "+claimKey(caseID, c.CreditorID), "Form-C hash does not match the form sent", 0)
+			}
+			if c.Status != want.status || math.Abs(c.AdmittedAmount-want.admitted) > tolerance {
+				s.violate("conservation", "claim "+claimKey(caseID, c.CreditorID), fmt.Sprintf("ledger %s %.2f, model %s %.2f", c.Status, c.AdmittedAmount, want.status, want.admitted), 0)
+			}
+		}
+		if len(register.Claims) != len(byCase[caseID]) {
+			s.violate("conservation", "case "+caseID, fmt.Sprintf("register has %d claims, model submitted %d", len(register.Claims), len(byCase[caseID])), 0)
+		}
+		if math.Abs(register.TotalClaimed-claimed) > tolerance || math.Abs(register.TotalAdmitted-admitted) > tolerance {
+			s.violate("conservation", "case "+caseID, fmt.Sprintf("totals %.2f claimed and %.2f admitted, claims sum to %.2f and %.2f",
+				register.TotalClaimed, register.TotalAdmitted, claimed, admitted), 0)
+		}
+		if admitted > 0 && math.Abs(shares-100) > 0.01 {
+			s.violate("conservation", "case "+caseID, fmt.Sprintf("voting shares sum to %.4f%%", shares), 0)
+		}
+	}
+}
+
+// checkAudit checks that every committed mutation wrote at least one audit
+// record in its own transaction
+func (s *sim) checkAudit() {
+	audited := map[string]bool{}
+	from := s.started.Add(-5 * time.Minute).UTC().Format(time.RFC3339)
+	bookmark := ""
+	for {
+		var page struct {
+			Records      []auditRecord `json:"records"`
+			FetchedCount int32         `json:"fetchedCount"`
+			Bookmark     string        `json:"bookmark"`
+		}
+		if err := s.evaluate(&page, s.admin, "QueryAuditRecords", "", "", from, "", "200", bookmark); err != nil {
+			s.violate("audit", "QueryAuditRecords", err.Error(), 0)
+			return
+		}
+		for _, r := range page.Records {
+			audited[r.TxID] = true
+		}
+		if len(page.Records) < 200 || page.Bookmark == "" {
+			break
+		}
+		bookmark = page.Bookmark
+	}
+
+	for _, m := range s.mutations {
+		if !audited[m.txID] {
+			s.violate("audit", m.subject, fmt.Sprintf("transaction %s left no audit record", m.txID), m.step)
+		}
+	}
+}
+
+func contains(list []string, v string) bool {
+	for _, s := range list {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,121 @@
+package simulator
+
+import "fmt"
+
+// probe returns an action the contract must reject given the model's state,
+// or nil when the ledger holds nothing to probe yet. Probes never change the
+// model: a probe that commits is a violation.
+func (s *sim) probe() *action {
+	var probes []func() *action
+
+	var settled []*txModel
+	for _, id := range s.txOrder {
+		if tx := s.txs[id]; tx.status != "PENDING" {
+			settled = append(settled, tx)
+		}
+	}
+	if len(settled) > 0 {
+		tx := settled[s.rng.Intn(len(settled))]
+		probes = append(probes,
+			func() *action {
+				return &action{actor: s.admin, function: "ProcessTransaction", args: []string{tx.id}, want: "is not in PENDING status"}
+			},
+			func() *action {
+				return &action{actor: s.admin, function: "PerformComplianceCheck", args: []string{tx.id, "true"}, want: "is not in PENDING status"}
+			},
+		)
+	}
+	if len(s.txOrder) > 0 {
+		tx := s.txs[s.txOrder[s.rng.Intn(len(s.txOrder))]]
+		probes = append(probes, func() *action {
+			return &action{
+				actor:    Actor{Name: tx.creditor, MSPID: "CreditorMSP"},
+				function: "CreateTransaction",
+				args:     []string{tx.id, tx.creditor, tx.debtor, "1000.00", "INR", "CREDIT", "replayed transaction"},
+				want:     "already exists",
+			}
+		})
+	}
+
+	if len(s.kycOrder) > 0 {
+		kyc := s.kycs[s.kycOrder[s.rng.Intn(len(s.kycOrder))]]
+		borrower := s.borrowers[s.rng.Intn(len(s.borrowers))]
+		bank := s.banks[s.rng.Intn(len(s.banks))]
+		probes = append(probes,
+			func() *action {
+				return &action{actor: borrower, function: "ApproveKYC", args: []string{kyc.id, "true", "self approval"}, want: "only AdminMSP can approve KYC"}
+			},
+			func() *action {
+				return &action{
+					actor:     bank,
+					function:  "SubmitKYCFormC",
+					args:      []string{kyc.loan, kyc.id + "-BANK", borrower.Name},
+					transient: map[string][]byte{"formc": []byte(`{"submittedBy":"bank"}`)},
+					want:      "only AdminMSP can submit KYC Form-C",
+				}
+			},
+		)
+		if kyc.status != "SUBMITTED" {
+			probes = append(probes, func() *action {
+				return &action{actor: s.admin, function: "ApproveKYC", args: []string{kyc.id, "true", "second decision"}, want: "is not pending approval"}
+			})
+		}
+	}
+
+	var decided []*defaultModel
+	for _, id := range s.defOrder {
+		if def := s.defaults[id]; def.status != "PENDING_AUTHENTICATION" {
+			decided = append(decided, def)
+		}
+	}
+	if len(decided) > 0 {
+		def := decided[s.rng.Intn(len(decided))]
+		probes = append(probes, func() *action {
+			return &action{actor: Actor{Name: def.debtor, MSPID: "DebtorMSP"}, function: "ConfirmDefault", args: []string{def.id}, want: "is not pending authentication"}
+		})
+	}
+	if len(s.loans) > 0 {
+		l := s.loans[s.rng.Intn(len(s.loans))]
+		probes = append(probes, func() *action {
+			return &action{
+				actor:    l.borrower,
+				function: "FileDefault",
+				args:     []string{l.id + "-SELF", l.id, l.bank.Name, l.borrower.Name, "1000.00", "INR", s.d.Now().UTC().Format("2006-01-02"), "filed by borrower"},
+				want:     "only CreditorMSP or AdminMSP can file a default",
+			}
+		}, func() *action {
+			return &action{
+				actor:    l.bank,
+				function: "CreateTransaction",
+				args:     []string{l.id + "-UNREG", l.bank.Name, l.borrower.Name + "-UNREG", "1000.00", "INR", "DEBIT", "unregistered borrower"},
+				want:     "is not a registered borrower or institution",
+			}
+		})
+	}
+
+	if len(s.claimKeys) > 0 {
+		claim := s.claims[s.claimKeys[s.rng.Intn(len(s.claimKeys))]]
+		if !s.adminIsRP {
+			probes = append(probes, func() *action {
+				return &action{
+					actor:    s.admin,
+					function: "AdmitClaim",
+					args:     []string{claim.caseID, claim.creditor, amount(claim.amount), "decided by the IU"},
+					want:     fmt.Sprintf("only the resolution professional of case %s", claim.caseID),
+				}
+			})
+		}
+		if claim.status != "SUBMITTED" {
+			probes = append(probes, func() *action {
+				return &action{actor: s.rp, function: "RejectClaim", args: []string{claim.caseID, claim.creditor, "second decision"}, want: "is already " + claim.status}
+			})
+		}
+	}
+
+	if len(probes) == 0 {
+		return nil
+	}
+	a := probes[s.rng.Intn(len(probes))]()
+	a.probe = true
+	return a
+}
@@ -0,0 +1,798 @@
+package simulator
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"time"
+)
+
+// Loan lifecycle phases, in the order a loan moves through them
+const (
+	phaseSanction = iota
+	phaseSanctionCheck
+	phaseSanctionProcess
+	phaseDocuments
+	phaseKYCSubmit
+	phaseKYCDecide
+	phaseRepay
+	phaseRepayCheck
+	phaseRepayProcess
+	phaseDefault
+	phaseEvidence
+	phaseRespond
+	phaseDeemed
+	phaseRegisterCase
+	phaseClaim
+	phaseDecideClaim
+)
+
+// How a loan's borrower behaves once the loan is sanctioned
+const (
+	planRepay   = "repay"
+	planConfirm = "confirm"
+	planDispute = "dispute"
+	planSilent  = "silent"
+)
+
+// Accounts seeded by InitLedger, whose balances no lifecycle may change
+var seededAccounts = []string{"ACC001", "ACC002", "ACC003"}
+
+type loan struct {
+	id         string
+	bank       Actor
+	borrower   Actor
+	amount     float64
+	plan       string
+	claim      bool // escalate an authenticated default to an insolvency claim
+	phase      int
+	docs       int // documents still to submit
+	repayments int // repayments still to make
+	repaid     float64
+	balance    float64 // outstanding principal
+	disbursed  float64
+	seq        int    // transactions created for the loan
+	pending    string // transaction awaiting compliance and processing
+	pendingAmt float64
+	kycID      string
+	defaultID  string
+	evidence   []string
+	waitUntil  time.Time
+	done       bool
+	outcome    string
+}
+
+func (l *loan) finish(outcome string) {
+	l.done = true
+	l.outcome = outcome
+}
+
+type mutation struct {
+	txID    string
+	step    int
+	subject string
+}
+
+type txModel struct {
+	id       string
+	loan     string
+	creditor string
+	debtor   string
+	typ      string
+	amount   float64
+	status   string
+}
+
+type kycModel struct {
+	id     string
+	loan   string
+	formc  []byte
+	status string
+}
+
+type defaultModel struct {
+	id      string
+	loan    string
+	debtor  string
+	amount  float64
+	filedAt time.Time
+	status  string
+}
+
+type claimModel struct {
+	caseID   string
+	creditor string
+	amount   float64
+	admitted float64
+	formc    []byte
+	status   string
+	observed []string // statuses read from the claims register after each step
+}
+
+// sim is the model of the ledger that the scenario's expectations and the
+// invariant checks are computed from
+type sim struct {
+	cfg     Config
+	d       Driver
+	clock   Clock // nil when the driver cannot advance ledger time
+	rng     *rand.Rand
+	report  *Report
+	started time.Time
+
+	admin      Actor
+	rp         Actor
+	rpClientID string
+	adminIsRP  bool
+	banks      []Actor
+	borrowers  []Actor
+	loans      []*loan
+	deemedDays int
+	balances   map[string]float64
+
+	txs       map[string]*txModel
+	txOrder   []string
+	docs      map[string]string // document ID to submitted hash
+	kycs      map[string]*kycModel
+	kycOrder  []string
+	defaults  map[string]*defaultModel
+	defOrder  []string
+	cases     map[string]string // debtor to insolvency case
+	claims    map[string]*claimModel
+	claimKeys []string
+	writers   map[string][]string // evidence record to the tx IDs that wrote it
+	mutations []mutation
+}
+
+func newSim(d Driver, cfg Config) (*sim, error) {
+	s := &sim{
+		cfg:      cfg,
+		d:        d,
+		rng:      rand.New(rand.NewSource(cfg.Seed)),
+		report:   &Report{Seed: cfg.Seed, Prefix: cfg.Prefix, Steps: []Step{}, Outcomes: map[string]string{}, Violations: []Violation{}},
+		started:  d.Now(),
+		admin:    Actor{Name: cfg.Prefix + "-IU", MSPID: "AdminMSP"},
+		rp:       Actor{Name: cfg.Prefix + "-RP", MSPID: "AdminMSP"},
+		balances: map[string]float64{},
+		txs:      map[string]*txModel{},
+		docs:     map[string]string{},
+		kycs:     map[string]*kycModel{},
+		defaults: map[string]*defaultModel{},
+		cases:    map[string]string{},
+		claims:   map[string]*claimModel{},
+		writers:  map[string][]string{},
+	}
+	if c, ok := d.(Clock); ok {
+		s.clock = c
+	}
+	for i := 0; i < cfg.Creditors; i++ {
+		s.banks = append(s.banks, Actor{Name: fmt.Sprintf("%s-BANK%02d", cfg.Prefix, i+1), MSPID: "CreditorMSP"})
+	}
+	for i := 0; i < cfg.Debtors; i++ {
+		s.borrowers = append(s.borrowers, Actor{Name: fmt.Sprintf("%s-BORR%02d", cfg.Prefix, i+1), MSPID: "DebtorMSP"})
+	}
+
+	var err error
+	if s.rpClientID, err = d.ClientID(s.rp); err != nil {
+		return nil, fmt.Errorf("failed to get client ID of %s: %v", s.rp.Name, err)
+	}
+	adminID, err := d.ClientID(s.admin)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get client ID of %s: %v", s.admin.Name, err)
+	}
+	s.adminIsRP = adminID == s.rpClientID
+
+	b, err := d.Evaluate(s.admin, "GetDeemedAuthenticationDays")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read deemed authentication days: %v", err)
+	}
+	if s.deemedDays, err = strconv.Atoi(string(b)); err != nil {
+		return nil, fmt.Errorf("invalid deemed authentication days %q: %v", b, err)
+	}
+
+	if _, err := d.Evaluate(s.admin, "ReadAccount", seededAccounts[0]); err != nil {
+		s.exec(&action{actor: s.admin, function: "InitLedger", args: []string{}, mutates: true})
+		if !s.report.OK() {
+			return nil, fmt.Errorf("failed to initialize ledger: %s", s.report.Violations[0].Detail)
+		}
+	}
+	for _, id := range seededAccounts {
+		var account struct {
+			Balance float64 `json:"balance"`
+		}
+		if err := s.evaluate(&account, s.admin, "ReadAccount", id); err != nil {
+			return nil, err
+		}
+		s.balances[id] = account.Balance
+	}
+	if err := s.onboard(); err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < cfg.Loans; i++ {
+		l := &loan{
+			id:       fmt.Sprintf("%s-L%03d", cfg.Prefix, i+1),
+			bank:     s.banks[s.rng.Intn(len(s.banks))],
+			borrower: s.borrowers[s.rng.Intn(len(s.borrowers))],
+			amount:   between(s.rng, 1e6, 5e7, 1e5),
+			docs:     1 + s.rng.Intn(3),
+			claim:    s.rng.Float64() < 0.5,
+		}
+		switch r := s.rng.Float64(); {
+		case r < 0.35:
+			l.plan = planRepay
+			l.repayments = 1 + s.rng.Intn(4)
+		case r < 0.6:
+			l.plan = planConfirm
+		case r < 0.8:
+			l.plan = planDispute
+		default:
+			l.plan = planSilent
+			if s.clock == nil {
+				l.plan = planConfirm
+			}
+		}
+		if l.plan != planRepay {
+			l.repayments = s.rng.Intn(3)
+		}
+		s.loans = append(s.loans, l)
+	}
+	return s, nil
+}
+
+// onboard registers the banks and borrowers that an earlier run with the
+// same prefix has not
+func (s *sim) onboard() error {
+	for _, bank := range s.banks {
+		if _, err := s.d.Evaluate(s.admin, "GetInstitution", bank.Name); err == nil {
+			continue
+		}
+		s.exec(&action{
+			actor:    s.admin,
+			function: "RegisterInstitution",
+			args:     []string{bank.Name, bank.Name + " Ltd", "RBI-" + bank.Name, "BANK", pan(bank.Name, 'C'), "", "", bank.MSPID},
+			mutates:  true,
+		})
+	}
+	for _, borrower := range s.borrowers {
+		if _, err := s.d.Evaluate(s.admin, "GetBorrower", borrower.Name); err == nil {
+			continue
+		}
+		// Bind the borrower to the identity it answers defaults with
+		clientID, err := s.d.ClientID(borrower)
+		if err != nil {
+			return fmt.Errorf("failed to get client ID of %s: %v", borrower.Name, err)
+		}
+		s.exec(&action{
+			actor:    s.admin,
+			function: "RegisterBorrower",
+			args:     []string{borrower.Name, borrower.Name, "INDIVIDUAL", pan(borrower.Name, 'P'), "", "", clientID},
+			mutates:  true,
+		})
+	}
+	if !s.report.OK() {
+		return fmt.Errorf("failed to onboard parties: %s", s.report.Violations[0].Detail)
+	}
+	return nil
+}
+
+// pan derives a well-formed PAN for a simulated party from its name. The
+// fourth letter is the holder type: C for a company, P for a person.
+func pan(name string, holder byte) string {
+	h := sha256.Sum256([]byte(name))
+	b := make([]byte, 10)
+	for i := 0; i < 5; i++ {
+		b[i] = 'A' + h[i]%26
+	}
+	b[3] = holder
+	for i := 5; i < 9; i++ {
+		b[i] = '0' + h[i]%10
+	}
+	b[9] = 'A' + h[9]%26
+	return string(b)
+}
+
+// evaluate runs a query and decodes its JSON result into v
+func (s *sim) evaluate(v interface{}, actor Actor, function string, args ...string) error {
+	b, err := s.d.Evaluate(actor, function, args...)
+	if err != nil {
+		return fmt.Errorf("%s %v: %v", function, args, err)
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("%s %v: invalid result: %v", function, args, err)
+	}
+	return nil
+}
+
+// run interleaves the loans' lifecycles until every loan has finished
+func (s *sim) run() {
+	maxSteps := s.cfg.Loans * 80
+	for len(s.report.Steps) < maxSteps {
+		now := s.d.Now()
+		var ready []*loan
+		var wake time.Time
+		active := 0
+		for _, l := range s.loans {
+			if l.done {
+				continue
+			}
+			active++
+			if now.Before(l.waitUntil) {
+				if wake.IsZero() || l.waitUntil.Before(wake) {
+					wake = l.waitUntil
+				}
+				continue
+			}
+			ready = append(ready, l)
+		}
+		if active == 0 {
+			break
+		}
+		if len(ready) == 0 {
+			// only loans waiting out the authentication window are left
+			s.clock.Advance(wake.Sub(now))
+			continue
+		}
+
+		if s.rng.Float64() < s.cfg.ProbeRate {
+			if p := s.probe(); p != nil {
+				s.exec(p)
+			}
+		}
+		l := ready[s.rng.Intn(len(ready))]
+		if a := s.next(l); a != nil {
+			a.loan = l
+			s.exec(a)
+		}
+		if s.clock != nil && s.rng.Float64() < 0.1 {
+			s.clock.Advance(time.Duration(1+s.rng.Intn(5)) * 24 * time.Hour)
+		}
+	}
+
+	for _, l := range s.loans {
+		if !l.done {
+			s.violate("expectation", l.id, fmt.Sprintf("lifecycle did not finish within %d steps (phase %d)", maxSteps, l.phase), 0)
+			l.finish("unfinished")
+		}
+		s.report.Outcomes[l.id] = l.outcome
+	}
+}
+
+// next returns the loan's next transaction, or nil when the loan only moved
+// on in the model
+func (s *sim) next(l *loan) *action {
+	switch l.phase {
+	case phaseSanction:
+		return s.createTx(l, "DEBIT", l.amount)
+	case phaseSanctionCheck, phaseRepayCheck:
+		return s.complianceCheck(l)
+	case phaseSanctionProcess, phaseRepayProcess:
+		return s.processTx(l)
+	case phaseDocuments:
+		return s.submitDocument(l)
+	case phaseKYCSubmit:
+		return s.submitKYC(l)
+	case phaseKYCDecide:
+		return s.decideKYC(l)
+	case phaseRepay:
+		if l.repayments == 0 || l.balance == 0 {
+			if l.balance == 0 {
+				l.finish("repaid")
+				return nil
+			}
+			l.phase = phaseDefault
+			return nil
+		}
+		pay := between(s.rng, math.Min(1e5, l.balance), l.balance/2, 1e3)
+		if l.plan == planRepay && l.repayments == 1 {
+			pay = l.balance
+		}
+		return s.createTx(l, "CREDIT", pay)
+	case phaseDefault:
+		return s.fileDefault(l)
+	case phaseEvidence:
+		return s.submitEvidence(l)
+	case phaseRespond:
+		return s.respond(l)
+	case phaseDeemed:
+		return s.applyDeemed(l)
+	case phaseRegisterCase:
+		return s.registerCase(l)
+	case phaseClaim:
+		return s.submitClaim(l)
+	case phaseDecideClaim:
+		return s.decideClaim(l)
+	}
+	panic(fmt.Sprintf("simulator: loan %s in unknown phase %d", l.id, l.phase))
+}
+
+// wrote records txID as the latest writer of an evidence record
+func (s *sim) wrote(recordType, id, txID string) {
+	key := recordType + " " + id
+	s.writers[key] = append(s.writers[key], txID)
+}
+
+func (s *sim) underMoratorium(debtor string) bool {
+	_, ok := s.cases[debtor]
+	return ok
+}
+
+func (s *sim) createTx(l *loan, typ string, amt float64) *action {
+	l.seq++
+	id := fmt.Sprintf("%s-T%02d", l.id, l.seq)
+	a := &action{
+		actor:    l.bank,
+		function: "CreateTransaction",
+		args:     []string{id, l.bank.Name, l.borrower.Name, amount(amt), "INR", typ, fmt.Sprintf("%s %s", l.id, typ)},
+		mutates:  true,
+	}
+	if typ == "DEBIT" && s.underMoratorium(l.borrower.Name) {
+		a.want = "moratorium in force"
+		a.onReject = func() { l.finish("sanction blocked by moratorium") }
+		return a
+	}
+	a.onSuccess = func(txID string, _ []byte) {
+		s.txs[id] = &txModel{id: id, loan: l.id, creditor: l.bank.Name, debtor: l.borrower.Name, typ: typ, amount: amt, status: "PENDING"}
+		s.txOrder = append(s.txOrder, id)
+		s.wrote("TRANSACTION", id, txID)
+		l.pending, l.pendingAmt = id, amt
+		l.phase++
+	}
+	return a
+}
+
+func (s *sim) complianceCheck(l *loan) *action {
+	approved := s.rng.Float64() >= 0.1
+	id := l.pending
+	return &action{
+		actor:    s.admin,
+		function: "PerformComplianceCheck",
+		args:     []string{id, strconv.FormatBool(approved)},
+		mutates:  true,
+		onSuccess: func(txID string, _ []byte) {
+			s.wrote("TRANSACTION", id, txID)
+			if approved {
+				l.phase++
+				return
+			}
+			s.txs[id].status = "FAILED"
+			l.pending = ""
+			if l.phase == phaseSanctionCheck {
+				l.finish("sanction failed compliance")
+				return
+			}
+			l.repayments--
+			l.phase = phaseRepay
+		},
+	}
+}
+
+func (s *sim) processTx(l *loan) *action {
+	id := l.pending
+	return &action{
+		actor:    s.admin,
+		function: "ProcessTransaction",
+		args:     []string{id},
+		mutates:  true,
+		onSuccess: func(txID string, _ []byte) {
+			s.wrote("TRANSACTION", id, txID)
+			s.txs[id].status = "COMPLETED"
+			l.pending = ""
+			if l.phase == phaseSanctionProcess {
+				l.disbursed = l.pendingAmt
+				l.balance = l.pendingAmt
+				l.phase = phaseDocuments
+				return
+			}
+			l.repaid += l.pendingAmt
+			l.balance -= l.pendingAmt
+			l.repayments--
+			l.phase = phaseRepay
+		},
+	}
+}
+
+var documentTypes = []string{"SANCTION_LETTER", "LOAN_AGREEMENT", "HYPOTHECATION_DEED", "BANK_STATEMENT"}
+
+func (s *sim) submitDocument(l *loan) *action {
+	docID := fmt.Sprintf("%s-DOC%d", l.id, l.docs)
+	docType := documentTypes[s.rng.Intn(len(documentTypes))]
+	hash := hashHex([]byte(docID + docType))
+	actor := l.bank
+	if s.rng.Intn(3) == 0 {
+		actor = l.borrower
+	}
+	return &action{
+		actor:    actor,
+		function: "SubmitLoanDocument",
+		args:     []string{l.id, docID, hash, docType, "application/pdf", strconv.Itoa(1024 * (1 + s.rng.Intn(512))), `{"source":"simulator"}`},
+		mutates:  true,
+		onSuccess: func(txID string, _ []byte) {
+			s.docs[docID] = hash
+			s.wrote("DOCUMENT", docID, txID)
+			l.docs--
+			if l.docs == 0 {
+				l.phase = phaseKYCSubmit
+			}
+		},
+	}
+}
+
+func (s *sim) submitKYC(l *loan) *action {
+	kycID := l.id + "-KYC"
+	formc := []byte(fmt.Sprintf(`{"kycId":%q,"party":%q,"pan":"AAAPL%04dC"}`, kycID, l.borrower.Name, s.rng.Intn(10000)))
+	return &action{
+		actor:     s.admin,
+		function:  "SubmitKYCFormC",
+		args:      []string{l.id, kycID, l.borrower.Name},
+		transient: map[string][]byte{"formc": formc},
+		mutates:   true,
+		onSuccess: func(string, []byte) {
+			s.kycs[kycID] = &kycModel{id: kycID, loan: l.id, formc: formc, status: "SUBMITTED"}
+			s.kycOrder = append(s.kycOrder, kycID)
+			l.kycID = kycID
+			l.phase = phaseKYCDecide
+		},
+	}
+}
+
+func (s *sim) decideKYC(l *loan) *action {
+	approved := s.rng.Float64() < 0.85
+	remarks := "documents verified"
+	if !approved {
+		remarks = "address proof mismatch"
+	}
+	return &action{
+		actor:    s.admin,
+		function: "ApproveKYC",
+		args:     []string{l.kycID, strconv.FormatBool(approved), remarks},
+		mutates:  true,
+		onSuccess: func(string, []byte) {
+			status := "APPROVED"
+			if !approved {
+				status = "REJECTED"
+			}
+			s.kycs[l.kycID].status = status
+			l.phase = phaseRepay
+		},
+	}
+}
+
+func (s *sim) fileDefault(l *loan) *action {
+	defaultID := l.id + "-DEF"
+	filedAt := s.d.Now()
+	a := &action{
+		actor:    l.bank,
+		function: "FileDefault",
+		args:     []string{defaultID, l.id, l.bank.Name, l.borrower.Name, amount(l.balance), "INR", filedAt.UTC().Format("2006-01-02"), "overdue beyond 90 days"},
+		mutates:  true,
+	}
+	if s.underMoratorium(l.borrower.Name) {
+		a.want = "moratorium in force"
+		a.onReject = func() { l.phase = phaseClaim }
+		return a
+	}
+	a.onSuccess = func(txID string, _ []byte) {
+		s.defaults[defaultID] = &defaultModel{id: defaultID, loan: l.id, debtor: l.borrower.Name, amount: l.balance, filedAt: filedAt, status: "PENDING_AUTHENTICATION"}
+		s.defOrder = append(s.defOrder, defaultID)
+		s.wrote("DEFAULT", defaultID, txID)
+		l.defaultID = defaultID
+		switch l.plan {
+		case planDispute:
+			l.phase = phaseEvidence
+		case planSilent:
+			l.phase = phaseDeemed
+			l.waitUntil = filedAt.Add(s.window())
+		default:
+			l.phase = phaseRespond
+		}
+	}
+	return a
+}
+
+func (s *sim) window() time.Duration {
+	return time.Duration(s.deemedDays) * 24 * time.Hour
+}
+
+func (s *sim) submitEvidence(l *loan) *action {
+	docID := fmt.Sprintf("%s-EVD%d", l.id, len(l.evidence)+1)
+	hash := hashHex([]byte(docID))
+	return &action{
+		actor:    l.borrower,
+		function: "SubmitLoanDocument",
+		args:     []string{l.id, docID, hash, "REPAYMENT_PROOF", "application/pdf", "4096", `{"source":"simulator"}`},
+		mutates:  true,
+		onSuccess: func(txID string, _ []byte) {
+			s.docs[docID] = hash
+			s.wrote("DOCUMENT", docID, txID)
+			l.evidence = append(l.evidence, docID)
+			l.phase = phaseRespond
+		},
+	}
+}
+
+// respond has the borrower confirm or dispute the default as planned
+func (s *sim) respond(l *loan) *action {
+	def := s.defaults[l.defaultID]
+	a := &action{actor: l.borrower, mutates: true}
+	status := "AUTHENTICATED"
+	if l.plan == planDispute {
+		evidence, _ := json.Marshal(l.evidence)
+		a.function = "DisputeDefault"
+		a.args = []string{l.defaultID, "amount already repaid", string(evidence)}
+		status = "DISPUTED"
+	} else {
+		a.function = "ConfirmDefault"
+		a.args = []string{l.defaultID}
+	}
+	if def.status != "PENDING_AUTHENTICATION" {
+		a.want = "is not pending authentication"
+		a.onReject = func() { s.afterAuthentication(l) }
+		return a
+	}
+	a.onSuccess = func(txID string, _ []byte) {
+		def.status = status
+		s.wrote("DEFAULT", l.defaultID, txID)
+		s.afterAuthentication(l)
+	}
+	return a
+}
+
+// applyDeemed sweeps defaults past the authentication window once the
+// loan's own default is due
+func (s *sim) applyDeemed(l *loan) *action {
+	if s.defaults[l.defaultID].status != "PENDING_AUTHENTICATION" {
+		// an earlier sweep already covered it
+		s.afterAuthentication(l)
+		return nil
+	}
+	now := s.d.Now()
+	var due []*defaultModel
+	for _, id := range s.defOrder {
+		def := s.defaults[id]
+		if def.status == "PENDING_AUTHENTICATION" && !now.Before(def.filedAt.Add(s.window())) {
+			due = append(due, def)
+		}
+	}
+	return &action{
+		actor:    s.admin,
+		function: "ApplyDeemedAuthentication",
+		args:     []string{""},
+		mutates:  len(due) > 0,
+		onSuccess: func(txID string, result []byte) {
+			if n, err := strconv.Atoi(string(result)); err != nil || n != len(due) {
+				s.violate("expectation", l.id+" ApplyDeemedAuthentication", fmt.Sprintf("deemed %s defaults, model expects %d", result, len(due)), len(s.report.Steps))
+			}
+			for _, def := range due {
+				def.status = "DEEMED_AUTHENTICATED"
+				s.wrote("DEFAULT", def.id, txID)
+			}
+			s.afterAuthentication(l)
+		},
+	}
+}
+
+// afterAuthentication ends the loan or escalates its authenticated default to a claim
+func (s *sim) afterAuthentication(l *loan) {
+	status := s.defaults[l.defaultID].status
+	switch {
+	case status == "DISPUTED":
+		l.finish("default disputed")
+	case !l.claim:
+		l.finish("default " + map[string]string{"AUTHENTICATED": "authenticated", "DEEMED_AUTHENTICATED": "deemed authenticated"}[status])
+	case s.underMoratorium(l.borrower.Name):
+		l.phase = phaseClaim
+	default:
+		l.phase = phaseRegisterCase
+	}
+}
+
+func (s *sim) registerCase(l *loan) *action {
+	debtor := l.borrower.Name
+	if s.underMoratorium(debtor) {
+		l.phase = phaseClaim
+		return nil
+	}
+	caseID := "CASE-" + debtor
+	today := s.d.Now().UTC().Format("2006-01-02")
+	return &action{
+		actor:    s.admin,
+		function: "RegisterInsolvencyCase",
+		args: []string{caseID, debtor, fmt.Sprintf("U%05dMH2015PTC%06d", s.rng.Intn(100000), s.rng.Intn(1000000)),
+			fmt.Sprintf("CP(IB)/%d/MB/2024", 100+s.rng.Intn(900)), today, "Simulated RP", "IBBI/IPA-001/IP-P00001/2017-18/10001",
+			s.rp.MSPID, s.rpClientID, today, ""},
+		mutates: true,
+		onSuccess: func(string, []byte) {
+			s.cases[debtor] = caseID
+			l.phase = phaseClaim
+		},
+	}
+}
+
+func claimKey(caseID, creditor string) string {
+	return caseID + "/" + creditor
+}
+
+func (s *sim) submitClaim(l *loan) *action {
+	caseID := s.cases[l.borrower.Name]
+	key := claimKey(caseID, l.bank.Name)
+	formc := []byte(fmt.Sprintf(`{"caseId":%q,"creditor":%q,"loan":%q,"amount":%.2f}`, caseID, l.bank.Name, l.id, l.balance))
+	a := &action{
+		actor:     l.bank,
+		function:  "SubmitClaim",
+		args:      []string{caseID, l.bank.Name, amount(l.balance)},
+		transient: map[string][]byte{"formc": formc},
+		mutates:   true,
+	}
+	if _, ok := s.claims[key]; ok {
+		a.want = "already exists"
+		a.onReject = func() { l.finish("claim already filed by creditor") }
+		return a
+	}
+	a.onSuccess = func(string, []byte) {
+		s.claims[key] = &claimModel{caseID: caseID, creditor: l.bank.Name, amount: l.balance, formc: formc, status: "SUBMITTED"}
+		s.claimKeys = append(s.claimKeys, key)
+		s.observeClaim(key)
+		l.phase = phaseDecideClaim
+	}
+	return a
+}
+
+func (s *sim) decideClaim(l *loan) *action {
+	key := claimKey(s.cases[l.borrower.Name], l.bank.Name)
+	claim := s.claims[key]
+	if s.rng.Float64() < 0.3 {
+		return &action{
+			actor:    s.rp,
+			function: "RejectClaim",
+			args:     []string{claim.caseID, claim.creditor, "not supported by records of default"},
+			mutates:  true,
+			onSuccess: func(string, []byte) {
+				claim.status = "REJECTED"
+				s.observeClaim(key)
+				l.finish("claim rejected")
+			},
+		}
+	}
+	admitted := math.Floor(claim.amount * (0.5 + 0.5*s.rng.Float64()))
+	if admitted <= 0 {
+		admitted = claim.amount
+	}
+	return &action{
+		actor:    s.rp,
+		function: "AdmitClaim",
+		args:     []string{claim.caseID, claim.creditor, amount(admitted), "verified against record of default"},
+		mutates:  true,
+		onSuccess: func(string, []byte) {
+			claim.status = "ADMITTED"
+			claim.admitted = admitted
+			s.observeClaim(key)
+			l.finish("claim admitted")
+		},
+	}
+}
+
+// observeClaim records the claim's status as the claims register reports it
+func (s *sim) observeClaim(key string) {
+	claim := s.claims[key]
+	register, err := s.claimsRegister(claim.caseID)
+	if err != nil {
+		s.violate("status-transition", "claim "+key, err.Error(), len(s.report.Steps))
+		return
+	}
+	for _, c := range register.Claims {
+		if c.CreditorID == claim.creditor {
+			claim.observed = append(claim.observed, c.Status)
+			return
+		}
+	}
+	s.violate("status-transition", "claim "+key, "missing from the claims register", len(s.report.Steps))
+}
+
+func hashHex(b []byte) string {
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:])
+}
@@ -0,0 +1,249 @@
+// Package simulator replays randomized but seeded loan lifecycles against
+// IUContract with several creditors, debtors and the IU, then checks ledger
+// invariants: balances conserve, hash chains verify, statuses only move along
+// allowed transitions and every mutation leaves an audit record.
+//
+// A Driver runs the contract. StubDriver uses the in-process test stub; the
+// iu-sim tool drives a live network through the Fabric gateway.
+package simulator
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Actor is a client that submits transactions: a bank, a borrower, the IU or
+// a resolution professional. Name is also the party ID used in records.
+type Actor struct {
+	Name  string `json:"name"`
+	MSPID string `json:"mspId"`
+}
+
+// Driver submits and evaluates contract functions as an actor
+type Driver interface {
+	// Submit runs function as a transaction and returns its ID and result
+	Submit(actor Actor, function string, args []string, transient map[string][]byte) (txID string, result []byte, err error)
+	// Evaluate runs function as a query without committing
+	Evaluate(actor Actor, function string, args ...string) ([]byte, error)
+	// ClientID returns the ID the contract sees for actor's identity
+	ClientID(actor Actor) (string, error)
+	// Now returns the time the next transaction will be stamped with
+	Now() time.Time
+}
+
+// Clock is implemented by drivers whose ledger time can be moved forward.
+// Without it scenarios never wait out the deemed authentication window.
+type Clock interface {
+	Advance(d time.Duration)
+}
+
+// Config sizes a scenario
+type Config struct {
+	Seed      int64
+	Loans     int
+	Creditors int
+	Debtors   int
+	// Prefix starts every party and record ID so runs on a shared ledger
+	// do not collide; it defaults to S<seed>
+	Prefix string
+	// ProbeRate is the share of steps that try an action the contract must reject
+	ProbeRate float64
+	// Logf, when set, receives a line per step
+	Logf func(format string, args ...interface{})
+}
+
+// DefaultConfig returns the scenario size used when a field is left zero
+func DefaultConfig(seed int64) Config {
+	return Config{Seed: seed, Loans: 12, Creditors: 3, Debtors: 4, ProbeRate: 0.1}
+}
+
+// Step is one submitted transaction and its outcome
+type Step struct {
+	N        int      `json:"n"`
+	Loan     string   `json:"loan,omitempty"`
+	Actor    string   `json:"actor"`
+	MSPID    string   `json:"mspId"`
+	Function string   `json:"function"`
+	Args     []string `json:"args"`
+	Want     string   `json:"want,omitempty"` // substring of the expected error
+	Probe    bool     `json:"probe,omitempty"`
+	TxID     string   `json:"txId,omitempty"`
+	Err      string   `json:"err,omitempty"`
+}
+
+// Violation is a broken expectation or invariant
+type Violation struct {
+	Invariant string `json:"invariant"` // expectation, conservation, hash-chain, status-transition, audit
+	Subject   string `json:"subject"`
+	Detail    string `json:"detail"`
+	Step      int    `json:"step,omitempty"`
+}
+
+func (v Violation) String() string {
+	s := fmt.Sprintf("%s: %s: %s", v.Invariant, v.Subject, v.Detail)
+	if v.Step > 0 {
+		s += fmt.Sprintf(" (step %d)", v.Step)
+	}
+	return s
+}
+
+// Report is the outcome of a scenario run
+type Report struct {
+	Seed       int64             `json:"seed"`
+	Prefix     string            `json:"prefix"`
+	Steps      []Step            `json:"steps"`
+	Committed  int               `json:"committed"`
+	Rejected   int               `json:"rejected"` // expected rejections, probes included
+	Outcomes   map[string]string `json:"outcomes"` // how each loan's lifecycle ended
+	Violations []Violation       `json:"violations"`
+}
+
+// OK reports whether the run broke no expectation or invariant
+func (r *Report) OK() bool {
+	return len(r.Violations) == 0
+}
+
+// Summary is a one-line description of the run
+func (r *Report) Summary() string {
+	counts := map[string]int{}
+	for _, o := range r.Outcomes {
+		counts[o]++
+	}
+	outcomes := make([]string, 0, len(counts))
+	for o, n := range counts {
+		outcomes = append(outcomes, fmt.Sprintf("%s=%d", o, n))
+	}
+	sort.Strings(outcomes)
+	return fmt.Sprintf("seed %d: %d steps, %d committed, %d rejected as expected, %d violations (%s)",
+		r.Seed, len(r.Steps), r.Committed, r.Rejected, len(r.Violations), strings.Join(outcomes, " "))
+}
+
+// action is a transaction the scenario submits and what the model expects of it
+type action struct {
+	loan      *loan
+	actor     Actor
+	function  string
+	args      []string
+	transient map[string][]byte
+	want      string // substring of the expected error; empty means success
+	probe     bool
+	mutates   bool // a successful call must leave an audit record
+	onSuccess func(txID string, result []byte)
+	onReject  func()
+}
+
+// Run plays a scenario through d and checks the ledger afterwards. The error
+// is for failures to set the scenario up; contract misbehaviour, including
+// records that cannot be read back, is reported as violations.
+func Run(d Driver, cfg Config) (*Report, error) {
+	def := DefaultConfig(cfg.Seed)
+	if cfg.Loans <= 0 {
+		cfg.Loans = def.Loans
+	}
+	if cfg.Creditors <= 0 {
+		cfg.Creditors = def.Creditors
+	}
+	if cfg.Debtors <= 0 {
+		cfg.Debtors = def.Debtors
+	}
+	if cfg.ProbeRate < 0 {
+		cfg.ProbeRate = 0
+	}
+	if cfg.Prefix == "" {
+		cfg.Prefix = fmt.Sprintf("S%d", cfg.Seed)
+	}
+
+	s, err := newSim(d, cfg)
+	if err != nil {
+		return nil, err
+	}
+	s.run()
+	s.checkInvariants()
+	return s.report, nil
+}
+
+// exec submits a and compares the outcome with the model's expectation
+func (s *sim) exec(a *action) {
+	step := Step{
+		N:        len(s.report.Steps) + 1,
+		Actor:    a.actor.Name,
+		MSPID:    a.actor.MSPID,
+		Function: a.function,
+		Args:     a.args,
+		Want:     a.want,
+		Probe:    a.probe,
+	}
+	if a.loan != nil {
+		step.Loan = a.loan.id
+	}
+	txID, result, err := s.d.Submit(a.actor, a.function, a.args, a.transient)
+	step.TxID = txID
+	if err != nil {
+		step.Err = err.Error()
+	}
+	s.report.Steps = append(s.report.Steps, step)
+	if s.cfg.Logf != nil {
+		outcome := "ok " + txID
+		if err != nil {
+			outcome = "rejected: " + err.Error()
+		}
+		s.cfg.Logf("%4d %-12s %-24s %s %v -> %s", step.N, a.actor.Name, a.function, step.Loan, a.args, outcome)
+	}
+
+	subject := a.function
+	if a.loan != nil {
+		subject = a.loan.id + " " + a.function
+	}
+	switch {
+	case a.want == "" && err != nil:
+		s.violate("expectation", subject, fmt.Sprintf("unexpected error: %v", err), step.N)
+		s.abandon(a.loan)
+	case a.want != "" && err == nil:
+		s.violate("expectation", subject, fmt.Sprintf("committed, want error containing %q", a.want), step.N)
+		s.abandon(a.loan)
+	case a.want != "" && !strings.Contains(err.Error(), a.want):
+		s.violate("expectation", subject, fmt.Sprintf("error %q does not contain %q", err, a.want), step.N)
+		s.abandon(a.loan)
+	case a.want != "":
+		s.report.Rejected++
+		if a.onReject != nil {
+			a.onReject()
+		}
+	default:
+		s.report.Committed++
+		if a.mutates {
+			s.mutations = append(s.mutations, mutation{txID: txID, step: step.N, subject: subject})
+		}
+		if a.onSuccess != nil {
+			a.onSuccess(txID, result)
+		}
+	}
+}
+
+// abandon stops a loan whose model no longer matches the ledger
+func (s *sim) abandon(l *loan) {
+	if l != nil && !l.done {
+		l.finish("abandoned")
+	}
+}
+
+func (s *sim) violate(invariant, subject, detail string, step int) {
+	s.report.Violations = append(s.report.Violations, Violation{Invariant: invariant, Subject: subject, Detail: detail, Step: step})
+}
+
+func amount(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
+
+// between returns a random whole multiple of unit in [lo, hi]
+func between(rng *rand.Rand, lo, hi, unit float64) float64 {
+	n := int((hi - lo) / unit)
+	if n <= 0 {
+		return lo
+	}
+	return lo + float64(rng.Intn(n+1))*unit
+}
@@ -0,0 +1,74 @@
+package simulator
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+
+	"iu-chaincode/chaincodetest"
+)
+
+// StubDriver runs the contract in process on a chaincodetest ledger. Each
+// actor gets its own client identity, and ledger time can be advanced.
+type StubDriver struct {
+	stub       *chaincodetest.Stub
+	chaincode  shim.Chaincode
+	identities map[string]*chaincodetest.Identity
+	seq        int
+}
+
+// NewStubDriver drives cc on stub
+func NewStubDriver(stub *chaincodetest.Stub, cc shim.Chaincode) *StubDriver {
+	return &StubDriver{stub: stub, chaincode: cc, identities: map[string]*chaincodetest.Identity{}}
+}
+
+func (d *StubDriver) identity(actor Actor) *chaincodetest.Identity {
+	id, ok := d.identities[actor.Name]
+	if !ok {
+		id = chaincodetest.NewIdentity(actor.MSPID, strings.ToLower(actor.Name)+"@"+strings.ToLower(strings.TrimSuffix(actor.MSPID, "MSP"))+".iu-network.com")
+		d.identities[actor.Name] = id
+	}
+	return id
+}
+
+// Submit invokes function and commits its writes if it succeeds
+func (d *StubDriver) Submit(actor Actor, function string, args []string, transient map[string][]byte) (string, []byte, error) {
+	d.seq++
+	txID := fmt.Sprintf("sim%06d", d.seq)
+	opts := []chaincodetest.TxOption{chaincodetest.WithTxID(txID)}
+	if transient != nil {
+		opts = append(opts, chaincodetest.WithTransient(transient))
+	}
+	response := d.stub.Invoke(d.chaincode, d.identity(actor), append([]string{function}, args...), opts...)
+	if response.Status >= shim.ERRORTHRESHOLD {
+		return txID, nil, errors.New(response.Message)
+	}
+	return txID, response.Payload, nil
+}
+
+// Evaluate invokes function without committing
+func (d *StubDriver) Evaluate(actor Actor, function string, args ...string) ([]byte, error) {
+	response := d.stub.Evaluate(d.chaincode, d.identity(actor), append([]string{function}, args...))
+	if response.Status >= shim.ERRORTHRESHOLD {
+		return nil, errors.New(response.Message)
+	}
+	return response.Payload, nil
+}
+
+// ClientID returns the ID of actor's identity
+func (d *StubDriver) ClientID(actor Actor) (string, error) {
+	return d.identity(actor).ID(), nil
+}
+
+// Now returns the timestamp of the next transaction
+func (d *StubDriver) Now() time.Time {
+	return d.stub.Now()
+}
+
+// Advance moves ledger time forward by dur
+func (d *StubDriver) Advance(dur time.Duration) {
+	d.stub.SetTime(d.stub.Now().Add(dur))
+}
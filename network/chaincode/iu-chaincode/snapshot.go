@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+
+	"iu-chaincode/events"
+	"iu-chaincode/merkle"
+)
+
+// SnapshotRoot is the Merkle root over every version of a document,
+// transaction or default written in a period. The tree is built off-chain
+// from the key history of the index and only its root is stored; proofs
+// are served off-chain and checked against this root.
+type SnapshotRoot struct {
+	Period      string    `json:"period"`
+	From        time.Time `json:"from"`
+	To          time.Time `json:"to"` // exclusive
+	Root        string    `json:"root"`
+	LeafCount   int       `json:"leafCount"`
+	CommittedBy string    `json:"committedBy"`
+	CommittedAt time.Time `json:"committedAt"`
+	TxID        string    `json:"txId"`
+}
+
+func snapshotKey(period string) string {
+	return fmt.Sprintf("SNAPSHOT_%s", period)
+}
+
+// CommitSnapshotRoot stores the Merkle root, built off-chain, over the
+// leafCount document, transaction and default versions written in a period
+// that has ended. A period is a month (YYYY-MM) or a day (YYYY-MM-DD) and
+// can be committed once.
+func (s *IUContract) CommitSnapshotRoot(ctx contractapi.TransactionContextInterface, period, root string, leafCount int) (*SnapshotRoot, error) {
+	mspid, err := getMSPID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if mspid != "AdminMSP" {
+		return nil, fmt.Errorf("only AdminMSP can commit snapshot roots")
+	}
+	from, to, err := merkle.ParsePeriod(period)
+	if err != nil {
+		return nil, err
+	}
+	if !validDocumentHash(root) {
+		return nil, fmt.Errorf("invalid snapshot root %q (want a SHA-256 hash in 64 hex characters)", root)
+	}
+	if leafCount < 0 {
+		return nil, fmt.Errorf("invalid leaf count %d", leafCount)
+	}
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if now.Before(to) {
+		return nil, fmt.Errorf("period %s has not ended", period)
+	}
+	key := snapshotKey(period)
+	existing, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if existing != nil {
+		return nil, fmt.Errorf("snapshot root for %s is already committed", period)
+	}
+
+	snapshot := &SnapshotRoot{
+		Period:      period,
+		From:        from,
+		To:          to,
+		Root:        strings.ToLower(root),
+		LeafCount:   leafCount,
+		CommittedBy: mspid,
+		CommittedAt: now,
+		TxID:        ctx.GetStub().GetTxID(),
+	}
+	if err := putJSON(ctx, key, snapshot); err != nil {
+		return nil, err
+	}
+
+	if err := writeAuditRecord(ctx, key, "COMMIT_SNAPSHOT_ROOT", mspid,
+		fmt.Sprintf("Snapshot root %s over %d record versions", snapshot.Root, snapshot.LeafCount), "COMMITTED", now); err != nil {
+		return nil, err
+	}
+	if err := emitEvent(ctx, events.SnapshotRootCommitted, key, snapshot); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// GetSnapshotRoot returns the committed snapshot root of a period
+func (s *IUContract) GetSnapshotRoot(ctx contractapi.TransactionContextInterface, period string) (*SnapshotRoot, error) {
+	b, err := ctx.GetStub().GetState(snapshotKey(period))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if b == nil {
+		return nil, fmt.Errorf("no snapshot root is committed for %s", period)
+	}
+	var snapshot SnapshotRoot
+	if err := json.Unmarshal(b, &snapshot); err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
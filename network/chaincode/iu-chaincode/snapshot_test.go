@@ -0,0 +1,62 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+
+	"iu-chaincode/chaincodetest"
+	"iu-chaincode/events"
+)
+
+func TestSnapshotRoot(t *testing.T) {
+	root := sha256Hex([]byte("April"))
+	setup := func(t *testing.T, stub *chaincodetest.Stub) {
+		stub.SetTime(time.Date(2024, 5, 2, 9, 0, 0, 0, time.UTC))
+	}
+	commit := func(period, root string, leafCount int) ctxFunc {
+		return func(ctx contractapi.TransactionContextInterface) error {
+			_, err := contract.CommitSnapshotRoot(ctx, period, root, leafCount)
+			return err
+		}
+	}
+	committed := func(t *testing.T, stub *chaincodetest.Stub) {
+		mustSubmit(t, stub, chaincodetest.Admin, commit("2024-04", root, 4))
+	}
+	runCases(t, setup, []txCase{
+		{name: "admin commits", id: chaincodetest.Admin, call: commit("2024-04", strings.ToUpper(root), 4), event: events.SnapshotRootCommitted,
+			check: func(t *testing.T, stub *chaincodetest.Stub) {
+				var snapshot SnapshotRoot
+				readState(t, stub, "SNAPSHOT_2024-04", &snapshot)
+				if snapshot.Root != root || snapshot.LeafCount != 4 || snapshot.CommittedBy != "AdminMSP" {
+					t.Fatalf("snapshot %+v", snapshot)
+				}
+				if !snapshot.From.Equal(time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)) || !snapshot.To.Equal(time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)) {
+					t.Fatalf("period %s to %s", snapshot.From, snapshot.To)
+				}
+				if len(stub.Keys("AUDIT_SNAPSHOT_2024-04_COMMIT_SNAPSHOT_ROOT_")) != 1 {
+					t.Fatal("commit not audited")
+				}
+			}},
+		{name: "empty day", id: chaincodetest.Admin, call: commit("2024-04-15", sha256Hex(nil), 0)},
+		{name: "creditor cannot commit", id: chaincodetest.Creditor, call: commit("2024-04", root, 4), wantErr: "only AdminMSP"},
+		{name: "period not ended", id: chaincodetest.Admin, call: commit("2024-05", root, 4), wantErr: "has not ended"},
+		{name: "invalid period", id: chaincodetest.Admin, call: commit("2024-Q1", root, 4), wantErr: "invalid period"},
+		{name: "invalid root", id: chaincodetest.Admin, call: commit("2024-04", root[:40], 4), wantErr: "invalid snapshot root"},
+		{name: "negative leaf count", id: chaincodetest.Admin, call: commit("2024-04", root, -1), wantErr: "invalid leaf count"},
+		{name: "committed twice", id: chaincodetest.Admin, setup: committed, call: commit("2024-04", root, 4), wantErr: "already committed"},
+		{name: "read back", id: chaincodetest.Debtor, setup: committed, call: func(ctx contractapi.TransactionContextInterface) error {
+			snapshot, err := contract.GetSnapshotRoot(ctx, "2024-04")
+			if err == nil && snapshot.Root != root {
+				t.Errorf("root %s", snapshot.Root)
+			}
+			return err
+		}},
+		{name: "not committed", id: chaincodetest.Debtor, call: func(ctx contractapi.TransactionContextInterface) error {
+			_, err := contract.GetSnapshotRoot(ctx, "2024-04")
+			return err
+		}, wantErr: "no snapshot root is committed"},
+	})
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"iu-chaincode/identifiers"
+)
+
+// FieldError is why one argument of a call is invalid. A value that must
+// not be echoed, such as an unmasked Aadhaar number, is left out.
+type FieldError struct {
+	Field  string `json:"field"`
+	Kind   string `json:"kind"` // PAN, GSTIN, CIN, LEI, IFSC or AADHAAR_REF
+	Value  string `json:"value,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// ValidationError reports every invalid identifier of a call. Its message is
+// JSON, so that clients can read from the chaincode response which fields
+// failed and why.
+type ValidationError struct {
+	Message string       `json:"message"`
+	Fields  []FieldError `json:"fields"`
+}
+
+func (e *ValidationError) Error() string {
+	b, _ := json.Marshal(e)
+	return string(b)
+}
+
+// identifierChecks collects the invalid identifiers of a call, so that all
+// of them are reported at once
+type identifierChecks struct {
+	fields []FieldError
+}
+
+// check validates value as an identifier of kind and returns it normalized.
+// An empty value is left empty.
+func (c *identifierChecks) check(field, kind, value string) string {
+	if identifiers.Normalize(value) == "" {
+		return ""
+	}
+	id, err := identifiers.Validate(kind, value)
+	if e, ok := err.(*identifiers.Error); ok {
+		c.fail(field, e.Kind, e.Value, e.Reason)
+	}
+	return id
+}
+
+func (c *identifierChecks) fail(field, kind, value, reason string) {
+	c.fields = append(c.fields, FieldError{Field: field, Kind: kind, Value: value, Reason: reason})
+}
+
+func (c *identifierChecks) err() error {
+	if len(c.fields) == 0 {
+		return nil
+	}
+	msgs := make([]string, len(c.fields))
+	for i, f := range c.fields {
+		msgs[i] = "invalid " + f.Field
+		if f.Value != "" {
+			msgs[i] += " " + f.Value
+		}
+		msgs[i] += ": " + f.Reason
+	}
+	return &ValidationError{Message: strings.Join(msgs, "; "), Fields: c.fields}
+}
+
+// metadataIdentifiers validates the identifiers in a document's metadata and
+// returns the metadata with them normalized. Metadata that is a JSON object
+// may carry pan, gstin, cin, lei or ifsc fields, matched without regard to
+// case; it is re-encoded, with its keys sorted, only when an identifier
+// changes. Other metadata is free text and returned as sent.
+func metadataIdentifiers(metadata string) (string, error) {
+	var fields map[string]interface{}
+	decoder := json.NewDecoder(strings.NewReader(metadata))
+	// Keep numbers as written rather than rounding them through float64
+	decoder.UseNumber()
+	if decoder.Decode(&fields) != nil || decoder.More() {
+		return metadata, nil
+	}
+	c := &identifierChecks{}
+	changed := false
+	for name, v := range fields {
+		kind := strings.ToUpper(name)
+		switch kind {
+		case identifiers.PAN, identifiers.GSTIN, identifiers.CIN, identifiers.LEI, identifiers.IFSC:
+		default:
+			continue
+		}
+		value, ok := v.(string)
+		if !ok {
+			c.fail("metadata."+name, kind, "", "must be a string")
+			continue
+		}
+		if id := c.check("metadata."+name, kind, value); id != value {
+			fields[name] = id
+			changed = true
+		}
+	}
+	// Map order is random; report fields in a stable order
+	sort.Slice(c.fields, func(i, j int) bool { return c.fields[i].Field < c.fields[j].Field })
+	if err := c.err(); err != nil {
+		return "", err
+	}
+	if !changed {
+		return metadata, nil
+	}
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(fields); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+
+	"iu-chaincode/chaincodetest"
+)
+
+func TestValidationError(t *testing.T) {
+	c := &identifierChecks{}
+	if got := c.check("pan", "PAN", " abcpr1234k "); got != "ABCPR1234K" || c.err() != nil {
+		t.Fatalf("check = %q, %v", got, c.err())
+	}
+	c.check("ifsc", "IFSC", "SBIN1000691")
+	c.fail("aadhaarRef", "AADHAAR_REF", "", "must be masked")
+
+	var got ValidationError
+	if err := json.Unmarshal([]byte(c.err().Error()), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := "invalid ifsc SBIN1000691: want a 4-letter bank code, 0 and a 6-character branch code; invalid aadhaarRef: must be masked"
+	if got.Message != want || len(got.Fields) != 2 || got.Fields[0].Kind != "IFSC" {
+		t.Fatalf("error %+v", got)
+	}
+}
+
+func storedMetadata(want string) func(t *testing.T, stub *chaincodetest.Stub) {
+	return func(t *testing.T, stub *chaincodetest.Stub) {
+		var doc Document
+		readState(t, stub, "DOC_DOC1", &doc)
+		if doc.Metadata != want {
+			t.Fatalf("metadata = %s, want %s", doc.Metadata, want)
+		}
+	}
+}
+
+func TestDocumentMetadataIdentifiers(t *testing.T) {
+	submit := func(metadata string) ctxFunc {
+		return func(ctx contractapi.TransactionContextInterface) error {
+			return contract.SubmitLoanDocument(ctx, "L1", "DOC1", "abc123", "SANCTION_LETTER", "application/pdf", "100", metadata)
+		}
+	}
+	runCases(t, nil, []txCase{
+		{name: "free text", id: chaincodetest.Creditor, call: submit("signed at branch")},
+		{name: "identifiers stored normalized", id: chaincodetest.Creditor,
+			call:  submit(`{"PAN":"abcpr1234k","ifsc":"SBIN0000691","lei":"5493001KJTIIGC8Y1R12","limit":12345678901234567890,"note":"a&b"}`),
+			check: storedMetadata(`{"PAN":"ABCPR1234K","ifsc":"SBIN0000691","lei":"5493001KJTIIGC8Y1R12","limit":12345678901234567890,"note":"a&b"}`)},
+		{name: "normalized metadata stored as sent", id: chaincodetest.Creditor, call: submit(`{"pan": "ABCPR1234K", "branch": "Pune"}`),
+			check: storedMetadata(`{"pan": "ABCPR1234K", "branch": "Pune"}`)},
+		{name: "invalid identifiers", id: chaincodetest.Creditor, call: submit(`{"lei":"5493001KJTIIGC8Y1R21","gstin":"27AAPFU0939F1ZW","branch":"Pune"}`),
+			wantErr: `"fields":[{"field":"metadata.gstin","kind":"GSTIN","value":"27AAPFU0939F1ZW","reason":"check character is W, want V"},{"field":"metadata.lei","kind":"LEI"`},
+		{name: "identifier not a string", id: chaincodetest.Creditor, call: submit(`{"cin":42}`), wantErr: "invalid metadata.cin: must be a string"},
+	})
+}
@@ -0,0 +1,183 @@
+// Command audit-relay mirrors iu-chaincode events committed on
+// financial-operations-channel into audit-compliance-channel by submitting
+// RecordAuditEvent with each event's source TxID and block number. Its
+// position is kept in a checkpoint file so a restarted relay resumes after
+// the last mirrored event. The reconcile subcommand reports source events
+// that are missing or differ on the audit channel, and verify asks the audit
+// chaincode to prove a mirrored record against its source record.
+//
+//	audit-relay run -checkpoint relay.checkpoint
+//	audit-relay reconcile -from 0
+//	audit-relay verify -key AUDIT_KYC001_EVT_KYC_SUBMITTED_<txid>
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+
+	"iu-tools/internal/gateway"
+	"iu-tools/internal/relay"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var err error
+	switch os.Args[1] {
+	case "run":
+		err = runRelay(ctx, os.Args[2:])
+	case "reconcile":
+		err = runReconcile(ctx, os.Args[2:])
+	case "verify":
+		err = runVerify(os.Args[2:])
+	default:
+		usage()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: audit-relay run|reconcile|verify [flags]")
+	os.Exit(2)
+}
+
+type commonFlags struct {
+	org, orgsDir, source, target, chaincode *string
+}
+
+func addCommonFlags(fs *flag.FlagSet) commonFlags {
+	return commonFlags{
+		org:       fs.String("org", "admin", "org whose gateway peer and identity to use"),
+		orgsDir:   fs.String("orgs", "../organizations", "network organizations directory"),
+		source:    fs.String("source", gateway.FinancialChannel, "channel whose events are mirrored"),
+		target:    fs.String("target", gateway.AuditChannel, "channel the events are recorded on"),
+		chaincode: fs.String("chaincode", gateway.ChaincodeName, "chaincode name on both channels"),
+	}
+}
+
+func (f commonFlags) connect() (*gateway.Connection, error) {
+	profile, err := gateway.DefaultProfile(*f.org, *f.orgsDir)
+	if err != nil {
+		return nil, err
+	}
+	return gateway.Connect(profile)
+}
+
+func runRelay(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	cf := addCommonFlags(fs)
+	checkpointPath := fs.String("checkpoint", "audit-relay.checkpoint", "checkpoint file")
+	start := fs.Uint64("start", 0, "first source block when there is no checkpoint yet")
+	fs.Parse(args)
+
+	conn, err := cf.connect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	checkpoint, err := client.NewFileCheckpointer(*checkpointPath)
+	if err != nil {
+		return fmt.Errorf("failed to open checkpoint: %v", err)
+	}
+	defer checkpoint.Close()
+
+	r := &relay.Relay{
+		Source:     conn.GetNetwork(*cf.source),
+		Chaincode:  *cf.chaincode,
+		Target:     conn.GetNetwork(*cf.target).GetContract(*cf.chaincode),
+		Checkpoint: checkpoint,
+		StartBlock: *start,
+		Logf:       log.Printf,
+	}
+	log.Printf("relaying %s events from %s to %s, resuming at block %d", *cf.chaincode, *cf.source, *cf.target, max(checkpoint.BlockNumber(), *start))
+	return r.Run(ctx)
+}
+
+func runReconcile(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("reconcile", flag.ExitOnError)
+	cf := addCommonFlags(fs)
+	from := fs.Uint64("from", 0, "first source block to check")
+	to := fs.Uint64("to", 0, "last source block to check (default: current end of chain)")
+	asJSON := fs.Bool("json", false, "print gaps as JSON")
+	fs.Parse(args)
+
+	conn, err := cf.connect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	gaps, err := relay.Reconcile(ctx, conn.GetNetwork(*cf.source), *cf.chaincode,
+		conn.GetNetwork(*cf.target).GetContract(*cf.chaincode), *from, *to)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		b, err := json.MarshalIndent(gaps, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+	} else {
+		for _, g := range gaps {
+			fmt.Printf("block %d tx %s %s: %s\n", g.SourceBlock, g.SourceTxID, g.EventName, g.Problem)
+		}
+	}
+	if len(gaps) > 0 {
+		return fmt.Errorf("%d gap(s) between %s and %s", len(gaps), *cf.source, *cf.target)
+	}
+	fmt.Printf("✅ %s and %s are in sync\n", *cf.source, *cf.target)
+	return nil
+}
+
+func runVerify(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	cf := addCommonFlags(fs)
+	key := fs.String("key", "", "audit record key of the mirrored event")
+	fs.Parse(args)
+	if *key == "" {
+		return fmt.Errorf("-key is required")
+	}
+
+	conn, err := cf.connect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// Submitted rather than evaluated so that a mismatch alert is committed
+	b, err := conn.GetNetwork(*cf.target).GetContract(*cf.chaincode).SubmitTransaction("VerifyMirroredEvent", *key)
+	if err != nil {
+		return fmt.Errorf("VerifyMirroredEvent failed: %s", gateway.ErrorMessage(err))
+	}
+	var result struct {
+		Status      string `json:"status"`
+		SourceKey   string `json:"sourceKey"`
+		MatchedTxID string `json:"matchedTxId"`
+		Reason      string `json:"reason"`
+	}
+	if err := json.Unmarshal(b, &result); err != nil {
+		return fmt.Errorf("invalid verification result: %v", err)
+	}
+	if result.Status != "VERIFIED" {
+		return fmt.Errorf("%s does not match %s on %s: %s", *key, result.SourceKey, *cf.source, result.Reason)
+	}
+	fmt.Printf("✅ %s matches %s as written by tx %s\n", *key, result.SourceKey, result.MatchedTxID)
+	return nil
+}
@@ -0,0 +1,140 @@
+// Command bsa-certificate produces a Section 63 Bharatiya Sakshya Adhiniyam
+// certificate for a transaction, document or default record on the IU
+// ledger. It reads the record's stored value and history through the
+// gateway, locates every version's block with qscc, and writes the
+// certificate as JSON and PDF. Running it again against the same ledger
+// state with the same custodian details produces identical files.
+//
+//	bsa-certificate -type DEFAULT -id DEF001 -name "A. Sharma" \
+//	  -designation "Chief Technology Officer" -place Mumbai -out DEF001-bsa63
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/hyperledger/fabric-protos-go-apiv2/common"
+	"google.golang.org/protobuf/proto"
+
+	"iu-tools/internal/bsa"
+	"iu-tools/internal/gateway"
+)
+
+func main() {
+	org := flag.String("org", "admin", "org whose gateway peer and identity to use")
+	orgsDir := flag.String("orgs", "../organizations", "network organizations directory")
+	channel := flag.String("channel", gateway.FinancialChannel, "channel holding the record")
+	chaincode := flag.String("chaincode", gateway.ChaincodeName, "chaincode name")
+	recordType := flag.String("type", "", "record type: TRANSACTION, DOCUMENT or DEFAULT")
+	id := flag.String("id", "", "record ID")
+	name := flag.String("name", "", "custodian name")
+	designation := flag.String("designation", "", "custodian designation")
+	organisation := flag.String("organisation", "Information Utility", "custodian organisation")
+	place := flag.String("place", "", "place of signing")
+	date := flag.String("date", "", "date of certificate, YYYY-MM-DD (default: date of latest version)")
+	out := flag.String("out", "", "output path prefix (default <type>-<id>-bsa63)")
+	flag.Parse()
+
+	if err := run(*org, *orgsDir, *channel, *chaincode, strings.ToUpper(*recordType), *id, bsa.Custodian{
+		Name:         *name,
+		Designation:  *designation,
+		Organisation: *organisation,
+		Place:        *place,
+		Date:         *date,
+	}, *out); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(org, orgsDir, channel, chaincode, recordType, id string, custodian bsa.Custodian, out string) error {
+	if recordType == "" || id == "" {
+		return fmt.Errorf("-type and -id are required")
+	}
+	if custodian.Name == "" || custodian.Designation == "" || custodian.Place == "" {
+		return fmt.Errorf("-name, -designation and -place are required for the custodian's declaration")
+	}
+	if out == "" {
+		out = fmt.Sprintf("%s-%s-bsa63", strings.ToLower(recordType), id)
+	}
+
+	profile, err := gateway.DefaultProfile(org, orgsDir)
+	if err != nil {
+		return err
+	}
+	conn, err := gateway.Connect(profile)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	network := conn.GetNetwork(channel)
+
+	result, err := network.GetContract(chaincode).EvaluateTransaction("GetRecordEvidence", recordType, id)
+	if err != nil {
+		return fmt.Errorf("GetRecordEvidence failed: %v", err)
+	}
+	var ev bsa.Evidence
+	if err := json.Unmarshal(result, &ev); err != nil {
+		return fmt.Errorf("invalid evidence response: %v", err)
+	}
+
+	blocks := map[string]bsa.BlockInfo{}
+	for _, h := range ev.History {
+		block, err := gateway.BlockByTxID(network, h.TxID)
+		if err != nil {
+			return err
+		}
+		if err := checkTxInBlock(block, h.TxID); err != nil {
+			return err
+		}
+		blocks[h.TxID] = bsa.BlockInfo{
+			Number:       block.GetHeader().GetNumber(),
+			DataHash:     hex.EncodeToString(block.GetHeader().GetDataHash()),
+			PreviousHash: hex.EncodeToString(block.GetHeader().GetPreviousHash()),
+		}
+	}
+
+	custodian.MSPID = profile.MSPID
+	cert, err := bsa.Build(&ev, blocks, bsa.Record{Channel: channel, Chaincode: chaincode}, custodian)
+	if err != nil {
+		return err
+	}
+	jsonBytes, err := cert.JSON()
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(out+".json", jsonBytes, 0o644); err != nil {
+		return err
+	}
+	if err := os.WriteFile(out+".pdf", cert.PDF(), 0o644); err != nil {
+		return err
+	}
+	fmt.Printf("✅ Certificate %s written to %s.json and %s.pdf\n", cert.CertificateID, out, out)
+	return nil
+}
+
+// checkTxInBlock confirms the block returned by qscc really contains txID
+func checkTxInBlock(block *common.Block, txID string) error {
+	for _, data := range block.GetData().GetData() {
+		var env common.Envelope
+		if err := proto.Unmarshal(data, &env); err != nil {
+			continue
+		}
+		var payload common.Payload
+		if err := proto.Unmarshal(env.GetPayload(), &payload); err != nil {
+			continue
+		}
+		var header common.ChannelHeader
+		if err := proto.Unmarshal(payload.GetHeader().GetChannelHeader(), &header); err != nil {
+			continue
+		}
+		if header.GetTxId() == txID {
+			return nil
+		}
+	}
+	return fmt.Errorf("tx %s not found in block %d", txID, block.GetHeader().GetNumber())
+}
@@ -0,0 +1,124 @@
+// Command cic-export writes a lending member's monthly credit information
+// company (CIC) submission from the IU ledger. Facilities, balances and
+// asset classifications come from the ledger; borrower names, identifiers
+// and addresses come from the member's party file. The file, a validation
+// report of every record left out, and the file's SHA-256 anchored on the
+// audit channel through RecordAuditEvent together let a CIC check that a
+// submission matches the ledger.
+//
+//	cic-export -creditor C1 -member BANK000001 -name CREDITORBANK -parties parties.json
+//	cic-export -creditor C1 -member BANK000001 -name CREDITORBANK -parties parties.json -format commercial -as-of 2024-06-30
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"iu-tools/internal/cic"
+	"iu-tools/internal/gateway"
+)
+
+func main() {
+	creditor := flag.String("creditor", "", "creditor ID on the ledger whose facilities are reported")
+	member := flag.String("member", "", "member code assigned by the CIC")
+	name := flag.String("name", "", "member short name")
+	accountType := flag.String("account-type", "", "CIC account type code of the facilities (default 05)")
+	format := flag.String("format", cic.Consumer, "file format, consumer (TUDF) or commercial")
+	asOfFlag := flag.String("as-of", "", "reporting date, YYYY-MM-DD (default: today)")
+	partiesPath := flag.String("parties", "", "JSON file of borrower party records")
+	out := flag.String("out", "", "output file (default: cic-<member>-<format>-<date>.txt)")
+	anchor := flag.Bool("anchor", true, "record the file hash on the audit channel")
+	org := flag.String("org", "creditor", "org whose gateway peer and identity to use")
+	orgsDir := flag.String("orgs", "../organizations", "network organizations directory")
+	channel := flag.String("channel", gateway.FinancialChannel, "channel to read the ledger from")
+	anchorChannel := flag.String("anchor-channel", gateway.AuditChannel, "channel to anchor the file hash on")
+	chaincode := flag.String("chaincode", gateway.ChaincodeName, "chaincode name")
+	flag.Parse()
+
+	if *creditor == "" || *member == "" || *name == "" || *partiesPath == "" {
+		fmt.Fprintln(os.Stderr, "-creditor, -member, -name and -parties are required")
+		flag.Usage()
+		os.Exit(2)
+	}
+	asOf := time.Now().UTC().Truncate(24 * time.Hour)
+	if *asOfFlag != "" {
+		var err error
+		if asOf, err = time.Parse("2006-01-02", *asOfFlag); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ invalid -as-of: %v\n", err)
+			os.Exit(2)
+		}
+	}
+	// Count everything booked on the reporting date
+	cutoff := asOf.Add(24*time.Hour - time.Nanosecond)
+	if *out == "" {
+		*out = fmt.Sprintf("cic-%s-%s-%s.txt", *member, *format, asOf.Format("20060102"))
+	}
+
+	parties, err := cic.LoadParties(*partiesPath)
+	if err != nil {
+		fail(err)
+	}
+	profile, err := gateway.DefaultProfile(*org, *orgsDir)
+	if err != nil {
+		fail(err)
+	}
+	conn, err := gateway.Connect(profile)
+	if err != nil {
+		fail(err)
+	}
+	defer conn.Close()
+
+	txs, defaults, err := cic.ReadLedger(conn.GetNetwork(*channel).GetContract(*chaincode))
+	if err != nil {
+		fail(fmt.Errorf("%s", gateway.ErrorMessage(err)))
+	}
+	accounts, issues := cic.BuildAccounts(*creditor, txs, defaults, cutoff)
+	file, report, err := cic.Export(*format, cic.Member{Code: *member, ShortName: *name, AccountType: *accountType}, asOf, accounts, parties)
+	if err != nil {
+		fail(err)
+	}
+	report.Issues = append(issues, report.Issues...)
+
+	reportPath := *out + ".report.json"
+	reportJSON, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		fail(err)
+	}
+	reportJSON = append(reportJSON, '\n')
+	if err := os.WriteFile(*out, file, 0o644); err != nil {
+		fail(err)
+	}
+	if err := os.WriteFile(reportPath, reportJSON, 0o644); err != nil {
+		fail(err)
+	}
+	for _, i := range report.Issues {
+		fmt.Printf("%-7s %s %s %s: %s\n", i.Severity, i.Account, i.Party, i.Field, i.Message)
+	}
+	fmt.Printf("%s: %d accounts, %d rejected, %d of the other format; report in %s\n",
+		*out, report.Accounts, report.Rejected, report.Skipped, reportPath)
+	fmt.Printf("sha256 %s\n", report.FileHash)
+
+	if *anchor {
+		ref, err := cic.Anchor(conn.GetNetwork(*anchorChannel).GetContract(*chaincode), report, filepath.Base(*out), sha256Hex(reportJSON))
+		if err != nil {
+			fail(fmt.Errorf("%s", gateway.ErrorMessage(err)))
+		}
+		fmt.Printf("✅ anchored as %s on %s\n", ref, *anchorChannel)
+	}
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func fail(err error) {
+	fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+	os.Exit(1)
+}
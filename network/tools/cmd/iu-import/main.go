@@ -0,0 +1,158 @@
+// Command iu-import loads a bank's historical loan book from a CSV or JSON
+// file onto the IU through BulkImportTransactions. Rows are split into
+// batches bounded by -rows and -bytes and each batch is submitted in one
+// transaction. Progress is saved next to the input, so rerunning the same
+// command after a failure resumes with the first batch not yet committed.
+//
+//	iu-import -in loanbook.csv
+//	iu-import -in loanbook.json -rows 200 -atomic=false
+//	iu-import -in loanbook.csv -dry-run
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+
+	"iu-tools/internal/bulkimport"
+	"iu-tools/internal/gateway"
+)
+
+func main() {
+	in := flag.String("in", "", "CSV or JSON file of transactions")
+	format := flag.String("format", "", "input format, csv or json (default: from the file extension)")
+	org := flag.String("org", "creditor", "org to submit as (creditor or admin)")
+	orgsDir := flag.String("orgs", "../organizations", "network organizations directory")
+	channel := flag.String("channel", gateway.FinancialChannel, "channel to import into")
+	chaincode := flag.String("chaincode", gateway.ChaincodeName, "chaincode name")
+	maxRows := flag.Int("rows", 500, "maximum rows per batch (the chaincode accepts up to 1000)")
+	maxBytes := flag.Int("bytes", 256<<10, "maximum size of a batch in bytes")
+	atomic := flag.Bool("atomic", true, "reject a whole batch if any of its rows is invalid")
+	prefix := flag.String("prefix", "", "batch ID prefix (default: IMP- and the start of the input's SHA-256)")
+	progressPath := flag.String("progress", "", "progress file (default: the input path with .progress.json)")
+	dryRun := flag.Bool("dry-run", false, "read and split the input without submitting")
+	flag.Parse()
+
+	if err := run(*in, *format, *org, *orgsDir, *channel, *chaincode, *maxRows, *maxBytes, *atomic, *prefix, *progressPath, *dryRun); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(in, format, org, orgsDir, channel, chaincode string, maxRows, maxBytes int, atomic bool, prefix, progressPath string, dryRun bool) error {
+	if in == "" {
+		return fmt.Errorf("-in is required")
+	}
+	if format == "" {
+		var err error
+		if format, err = bulkimport.FormatOf(in); err != nil {
+			return err
+		}
+	}
+	sourceHash, err := bulkimport.SourceHash(in)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(in)
+	if err != nil {
+		return err
+	}
+	rows, err := bulkimport.Read(f, format)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("%s: %v", in, err)
+	}
+	if prefix == "" {
+		prefix = "IMP-" + sourceHash[:12]
+	}
+	batches, err := bulkimport.Split(rows, prefix, maxRows, maxBytes)
+	if err != nil {
+		return err
+	}
+	log.Printf("%s: %d rows in %d batches", in, len(rows), len(batches))
+	if dryRun {
+		for _, b := range batches {
+			fmt.Printf("%s\t%d rows\t%d bytes\t%s\n", b.ID, b.Rows, len(b.Payload), b.Hash)
+		}
+		return nil
+	}
+
+	profile, err := gateway.DefaultProfile(org, orgsDir)
+	if err != nil {
+		return err
+	}
+	conn, err := gateway.Connect(profile)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if progressPath == "" {
+		progressPath = strings.TrimSuffix(in, filepath.Ext(in)) + ".progress.json"
+	}
+	im := &bulkimport.Importer{
+		Contract:     &gatewayContract{conn.GetNetwork(channel).GetContract(chaincode)},
+		ProgressPath: progressPath,
+		Atomic:       atomic,
+		Logf:         log.Printf,
+	}
+	p, err := im.Run(sourceHash, batches)
+	if p != nil {
+		total, imported := p.Totals()
+		for _, b := range p.Batches {
+			for _, e := range b.Errors {
+				fmt.Printf("%s row %d (%s): %s\n", b.ID, e.Row, e.ID, e.Error)
+			}
+		}
+		fmt.Printf("%d of %d rows imported; progress in %s\n", imported, total, progressPath)
+	}
+	if err != nil {
+		return fmt.Errorf("%v; rerun to resume", err)
+	}
+	fmt.Println("✅ import complete")
+	return nil
+}
+
+// gatewayContract submits batches through the Fabric Gateway
+type gatewayContract struct {
+	*client.Contract
+}
+
+func (c *gatewayContract) SubmitImport(batchID, batchHash string, atomic bool, batch []byte) (string, []byte, error) {
+	proposal, err := c.NewProposal("BulkImportTransactions",
+		client.WithArguments(batchID, batchHash, fmt.Sprint(atomic)),
+		client.WithTransient(map[string][]byte{"batch": batch}))
+	if err != nil {
+		return "", nil, err
+	}
+	txID := proposal.TransactionID()
+	tx, err := proposal.Endorse()
+	if err != nil {
+		return txID, nil, fmt.Errorf("%s", gateway.ErrorMessage(err))
+	}
+	commit, err := tx.Submit()
+	if err != nil {
+		return txID, nil, fmt.Errorf("%s", gateway.ErrorMessage(err))
+	}
+	status, err := commit.Status()
+	if err != nil {
+		return txID, nil, err
+	}
+	if !status.Successful {
+		return txID, nil, fmt.Errorf("transaction %s failed to commit with status %s", txID, status.Code)
+	}
+	return txID, tx.Result(), nil
+}
+
+func (c *gatewayContract) EvaluateTransaction(name string, args ...string) ([]byte, error) {
+	result, err := c.Contract.EvaluateTransaction(name, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s", gateway.ErrorMessage(err))
+	}
+	return result, nil
+}
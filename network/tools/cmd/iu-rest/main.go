@@ -0,0 +1,157 @@
+// Command iu-rest serves IUContract as a REST API so off-chain systems can
+// use the IU without a Fabric SDK. Every contract function is a resource
+// (for example POST /transactions, GET /loans/{loanId}/documents and
+// POST /kyc/{kycId}/approve). Clients authenticate with an API key from the
+// -clients file, and the X-IU-Org header selects which of the client's org
+// identities signs the request. GET /openapi.json describes the API, built
+// from the deployed chaincode's metadata.
+//
+// Calls the chaincode refuses under an insolvency moratorium are appended to
+// the -blocked-log file, since a refused transaction leaves no ledger record.
+//
+//	iu-rest serve -addr :8443 -clients clients.json -cert tls.crt -key tls.key
+//	iu-rest hashkey <api-key>
+//	iu-rest openapi -out openapi.json
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+
+	"iu-tools/internal/gateway"
+	"iu-tools/internal/moratorium"
+	"iu-tools/internal/restapi"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	var err error
+	switch os.Args[1] {
+	case "serve":
+		err = runServe(os.Args[2:])
+	case "openapi":
+		err = runOpenAPI(os.Args[2:])
+	case "hashkey":
+		if len(os.Args) != 3 {
+			usage()
+		}
+		fmt.Println(restapi.HashKey(os.Args[2]))
+	default:
+		usage()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: iu-rest serve|openapi [flags] | hashkey <api-key>")
+	os.Exit(2)
+}
+
+func newInvoker(fs *flag.FlagSet, args []string) (*restapi.GatewayInvoker, string) {
+	org := fs.String("org", "creditor", "org profile that reads the contract metadata")
+	orgsDir := fs.String("orgs", "../organizations", "network organizations directory")
+	profilesPath := fs.String("profiles", "", "JSON file of named org profiles (optional)")
+	chaincode := fs.String("chaincode", gateway.ChaincodeName, "chaincode name")
+	fs.Parse(args)
+
+	invoker := &restapi.GatewayInvoker{OrgsDir: *orgsDir, Chaincode: *chaincode}
+	if *profilesPath != "" {
+		profiles, err := gateway.LoadProfiles(*profilesPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			os.Exit(1)
+		}
+		invoker.Profiles = profiles
+	}
+	return invoker, *org
+}
+
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", ":8443", "listen address")
+	clientsPath := fs.String("clients", "", "JSON file of API clients and the orgs each may sign as (required)")
+	cert := fs.String("cert", "", "TLS certificate file")
+	key := fs.String("key", "", "TLS private key file")
+	insecure := fs.Bool("insecure", false, "serve plain HTTP, so API keys cross the network in clear (for local testing)")
+	blockedLog := fs.String("blocked-log", "moratorium-blocked.jsonl", "JSON Lines file of calls refused under an insolvency moratorium; empty disables it")
+	invoker, org := newInvoker(fs, args)
+	defer invoker.Close()
+
+	if *clientsPath == "" {
+		return fmt.Errorf("-clients is required")
+	}
+	clients, err := restapi.LoadClients(*clientsPath)
+	if err != nil {
+		return err
+	}
+	if (*cert == "" || *key == "") && !*insecure {
+		return fmt.Errorf("-cert and -key are required unless -insecure is set")
+	}
+
+	// The OpenAPI document is built on first request, once the peer is reachable
+	var (
+		once   sync.Once
+		doc    []byte
+		docErr error
+	)
+	openAPI := func() ([]byte, error) {
+		once.Do(func() {
+			var md []byte
+			if md, docErr = invoker.Metadata(org, gateway.FinancialChannel); docErr == nil {
+				doc, docErr = restapi.OpenAPI(md, "IUContract")
+			}
+		})
+		return doc, docErr
+	}
+
+	server := restapi.NewServer(invoker, clients, openAPI)
+	if *blockedLog != "" {
+		server.Blocked = &moratorium.Log{Path: *blockedLog}
+	}
+	log.Printf("serving %d routes on %s for %d clients", len(restapi.Routes), *addr, len(clients))
+	if *insecure {
+		return http.ListenAndServe(*addr, server)
+	}
+	return http.ListenAndServeTLS(*addr, *cert, *key, server)
+}
+
+func runOpenAPI(args []string) error {
+	fs := flag.NewFlagSet("openapi", flag.ExitOnError)
+	metadataPath := fs.String("metadata", "", "contract metadata file; fetched from the network when empty")
+	out := fs.String("out", "", "output file (default stdout)")
+	invoker, org := newInvoker(fs, args)
+	defer invoker.Close()
+
+	var md []byte
+	var err error
+	if *metadataPath != "" {
+		md, err = os.ReadFile(*metadataPath)
+	} else {
+		md, err = invoker.Metadata(org, gateway.FinancialChannel)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read contract metadata: %v", err)
+	}
+	doc, err := restapi.OpenAPI(md, "IUContract")
+	if err != nil {
+		return err
+	}
+	if *out == "" {
+		_, err = os.Stdout.Write(append(doc, '\n'))
+		return err
+	}
+	if err := os.WriteFile(*out, doc, 0644); err != nil {
+		return err
+	}
+	fmt.Printf("✅ OpenAPI document written to %s\n", *out)
+	return nil
+}
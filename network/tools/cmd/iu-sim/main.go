@@ -0,0 +1,197 @@
+// Command iu-sim replays seeded multi-org loan lifecycles against iu-chaincode
+// on a live network and checks the ledger invariants afterwards, as the
+// TestSimulation chaincode test does on the in-process stub. Banks submit as
+// the creditor org, borrowers as the debtor org, and the IU and resolution
+// professional as the admin org.
+//
+// Record IDs start with -prefix, which is unique per run by default, so runs
+// can repeat against the same ledger. Ledger time cannot be advanced on a
+// live network, so scenarios there never reach deemed authentication.
+//
+//	iu-sim -seed 42 -loans 8
+//	iu-sim -seed 42 -report sim-42.json -v
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+
+	"iu-chaincode/chaincodetest"
+	"iu-chaincode/simulator"
+	"iu-tools/internal/gateway"
+)
+
+func main() {
+	seed := flag.Int64("seed", time.Now().Unix(), "scenario seed")
+	loans := flag.Int("loans", 8, "loans to simulate")
+	creditors := flag.Int("creditors", 3, "banks to simulate")
+	debtors := flag.Int("debtors", 4, "borrowers to simulate")
+	probes := flag.Float64("probes", 0.1, "share of steps that try an action the contract must reject")
+	prefix := flag.String("prefix", "", "prefix of party and record IDs (default: unique per run)")
+	orgsDir := flag.String("orgs", "../organizations", "network organizations directory")
+	channel := flag.String("channel", gateway.FinancialChannel, "channel to simulate on")
+	chaincode := flag.String("chaincode", gateway.ChaincodeName, "chaincode name")
+	reportPath := flag.String("report", "", "write the JSON report to this file")
+	verbose := flag.Bool("v", false, "log every step")
+	flag.Parse()
+
+	if *prefix == "" {
+		*prefix = fmt.Sprintf("SIM%d-%d", *seed, time.Now().Unix())
+	}
+	d, err := connect(*orgsDir, *channel, *chaincode)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		os.Exit(1)
+	}
+	defer d.Close()
+
+	cfg := simulator.Config{
+		Seed:      *seed,
+		Loans:     *loans,
+		Creditors: *creditors,
+		Debtors:   *debtors,
+		Prefix:    *prefix,
+		ProbeRate: *probes,
+	}
+	if *verbose {
+		cfg.Logf = log.Printf
+	}
+	log.Printf("simulating %d loans with seed %d as %s on %s", *loans, *seed, *prefix, *channel)
+	report, err := simulator.Run(d, cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		os.Exit(1)
+	}
+
+	if *reportPath != "" {
+		b, err := json.MarshalIndent(report, "", "  ")
+		if err == nil {
+			err = os.WriteFile(*reportPath, b, 0o644)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ failed to write report: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	fmt.Println(report.Summary())
+	for _, v := range report.Violations {
+		fmt.Printf("  %s\n", v)
+	}
+	if !report.OK() {
+		fmt.Fprintf(os.Stderr, "❌ %d invariant violation(s); replay with -seed %d\n", len(report.Violations), *seed)
+		os.Exit(1)
+	}
+	fmt.Println("✅ all invariants hold")
+}
+
+// gatewayDriver submits each actor's transactions through its org's gateway
+// connection, so every bank shares the creditor org's identity
+type gatewayDriver struct {
+	contracts map[string]*client.Contract // by MSP ID
+	clientIDs map[string]string
+	conns     []*gateway.Connection
+}
+
+func connect(orgsDir, channel, chaincode string) (*gatewayDriver, error) {
+	d := &gatewayDriver{contracts: map[string]*client.Contract{}, clientIDs: map[string]string{}}
+	for _, org := range []string{"creditor", "debtor", "admin"} {
+		profile, err := gateway.DefaultProfile(org, orgsDir)
+		if err != nil {
+			d.Close()
+			return nil, err
+		}
+		certPEM, err := os.ReadFile(profile.CertPath)
+		if err != nil {
+			d.Close()
+			return nil, fmt.Errorf("failed to read certificate: %v", err)
+		}
+		if d.clientIDs[profile.MSPID], err = chaincodetest.ClientID(profile.MSPID, certPEM); err != nil {
+			d.Close()
+			return nil, fmt.Errorf("failed to derive client ID of %s: %v", org, err)
+		}
+		conn, err := gateway.Connect(profile)
+		if err != nil {
+			d.Close()
+			return nil, fmt.Errorf("failed to connect as %s: %v", org, err)
+		}
+		d.conns = append(d.conns, conn)
+		d.contracts[profile.MSPID] = conn.GetNetwork(channel).GetContract(chaincode)
+	}
+	return d, nil
+}
+
+func (d *gatewayDriver) Close() {
+	for _, conn := range d.conns {
+		conn.Close()
+	}
+}
+
+func (d *gatewayDriver) contract(actor simulator.Actor) (*client.Contract, error) {
+	contract, ok := d.contracts[actor.MSPID]
+	if !ok {
+		return nil, fmt.Errorf("no connection for %s", actor.MSPID)
+	}
+	return contract, nil
+}
+
+func (d *gatewayDriver) Submit(actor simulator.Actor, function string, args []string, transient map[string][]byte) (string, []byte, error) {
+	contract, err := d.contract(actor)
+	if err != nil {
+		return "", nil, err
+	}
+	opts := []client.ProposalOption{client.WithArguments(args...)}
+	if len(transient) > 0 {
+		opts = append(opts, client.WithTransient(transient))
+	}
+	proposal, err := contract.NewProposal(function, opts...)
+	if err != nil {
+		return "", nil, err
+	}
+	txID := proposal.TransactionID()
+	tx, err := proposal.Endorse()
+	if err != nil {
+		return txID, nil, fmt.Errorf("%s", gateway.ErrorMessage(err))
+	}
+	commit, err := tx.Submit()
+	if err != nil {
+		return txID, nil, fmt.Errorf("%s", gateway.ErrorMessage(err))
+	}
+	status, err := commit.Status()
+	if err != nil {
+		return txID, nil, err
+	}
+	if !status.Successful {
+		return txID, nil, fmt.Errorf("transaction %s failed to commit with status %s", txID, status.Code)
+	}
+	return txID, tx.Result(), nil
+}
+
+func (d *gatewayDriver) Evaluate(actor simulator.Actor, function string, args ...string) ([]byte, error) {
+	contract, err := d.contract(actor)
+	if err != nil {
+		return nil, err
+	}
+	result, err := contract.EvaluateTransaction(function, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s", gateway.ErrorMessage(err))
+	}
+	return result, nil
+}
+
+func (d *gatewayDriver) ClientID(actor simulator.Actor) (string, error) {
+	id, ok := d.clientIDs[actor.MSPID]
+	if !ok {
+		return "", fmt.Errorf("no identity for %s", actor.MSPID)
+	}
+	return id, nil
+}
+
+func (d *gatewayDriver) Now() time.Time {
+	return time.Now()
+}
@@ -0,0 +1,188 @@
+// Command iu-webhooks pushes iu-chaincode events to the webhooks of
+// off-chain systems such as loan origination and collections. It listens
+// on a channel through the Fabric Gateway, resuming from a checkpoint file,
+// and posts each event envelope to the hooks whose event types and orgs
+// match. Deliveries that still fail after retries go to a dead-letter file,
+// which redeliver retries later.
+//
+//	iu-webhooks run -config webhooks.json -checkpoint iu-webhooks.checkpoint
+//	iu-webhooks redeliver -config webhooks.json
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+	"iu-chaincode/events"
+
+	"iu-tools/internal/gateway"
+	"iu-tools/internal/webhook"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var err error
+	switch os.Args[1] {
+	case "run":
+		err = runListener(ctx, os.Args[2:])
+	case "redeliver":
+		err = runRedeliver(ctx, os.Args[2:])
+	default:
+		usage()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: iu-webhooks run|redeliver [flags]")
+	os.Exit(2)
+}
+
+func runListener(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	org := fs.String("org", "admin", "org whose gateway peer and identity to use")
+	orgsDir := fs.String("orgs", "../organizations", "network organizations directory")
+	channel := fs.String("channel", gateway.FinancialChannel, "channel to listen on")
+	chaincode := fs.String("chaincode", gateway.ChaincodeName, "chaincode name")
+	configPath := fs.String("config", "webhooks.json", "webhook configuration")
+	checkpointPath := fs.String("checkpoint", "iu-webhooks.checkpoint", "checkpoint file")
+	deadLetterPath := fs.String("dead-letters", "iu-webhooks.deadletter.jsonl", "dead-letter file")
+	start := fs.Uint64("start", 0, "block to replay from when there is no checkpoint yet")
+	fs.Parse(args)
+
+	dispatcher, err := newDispatcher(*configPath, *deadLetterPath)
+	if err != nil {
+		return err
+	}
+	profile, err := gateway.DefaultProfile(*org, *orgsDir)
+	if err != nil {
+		return err
+	}
+	conn, err := gateway.Connect(profile)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	checkpoint, err := client.NewFileCheckpointer(*checkpointPath)
+	if err != nil {
+		return fmt.Errorf("failed to open checkpoint: %v", err)
+	}
+	defer checkpoint.Close()
+
+	stream, err := conn.GetNetwork(*channel).ChaincodeEvents(ctx, *chaincode,
+		client.WithStartBlock(*start), client.WithCheckpoint(checkpoint))
+	if err != nil {
+		return fmt.Errorf("failed to listen for events: %v", err)
+	}
+	log.Printf("dispatching %s events on %s to %d hook(s) from block %d", *chaincode, *channel, len(dispatcher.Hooks), max(checkpoint.BlockNumber(), *start))
+	for ev := range stream {
+		env, err := events.Decode(ev.Payload)
+		if err != nil {
+			log.Printf("skipping %s in tx %s: %v", ev.EventName, ev.TransactionID, err)
+		} else if err := dispatcher.Dispatch(ctx, webhook.Body{
+			Channel:     *channel,
+			Chaincode:   *chaincode,
+			BlockNumber: ev.BlockNumber,
+			Event:       env,
+		}); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		if err := checkpoint.CheckpointChaincodeEvent(ev); err != nil {
+			return fmt.Errorf("failed to save checkpoint: %v", err)
+		}
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+	return fmt.Errorf("event stream closed")
+}
+
+func runRedeliver(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("redeliver", flag.ExitOnError)
+	configPath := fs.String("config", "webhooks.json", "webhook configuration")
+	deadLetterPath := fs.String("dead-letters", "iu-webhooks.deadletter.jsonl", "dead-letter file")
+	fs.Parse(args)
+
+	letters, err := webhook.ReadDeadLetters(*deadLetterPath)
+	if err != nil {
+		return err
+	}
+	// Deliveries that fail again are written to a fresh file that replaces the old one
+	retryPath := *deadLetterPath + ".retry"
+	os.Remove(retryPath)
+	dispatcher, err := newDispatcher(*configPath, retryPath)
+	if err != nil {
+		return err
+	}
+	hooks := map[string]webhook.Hook{}
+	for _, h := range dispatcher.Hooks {
+		hooks[h.Name] = h
+	}
+
+	for i, d := range letters {
+		h, ok := hooks[d.Hook]
+		if !ok {
+			log.Printf("hook %s is no longer configured, keeping %s", d.Hook, d.ID)
+			if err := dispatcher.DeadLetters.Add(d); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := dispatcher.Deliver(ctx, h, d.Delivery); err != nil {
+			// Keep the letters not yet retried so nothing is lost
+			for _, rest := range letters[i:] {
+				if addErr := dispatcher.DeadLetters.Add(rest); addErr != nil {
+					return addErr
+				}
+			}
+			os.Rename(retryPath, *deadLetterPath)
+			return err
+		}
+	}
+	if _, err := os.Stat(retryPath); err == nil {
+		remaining, err := webhook.ReadDeadLetters(retryPath)
+		if err != nil {
+			return err
+		}
+		if err := os.Rename(retryPath, *deadLetterPath); err != nil {
+			return err
+		}
+		return fmt.Errorf("%d of %d deliveries still failing", len(remaining), len(letters))
+	}
+	if err := os.Remove(*deadLetterPath); err != nil {
+		return err
+	}
+	fmt.Printf("✅ Redelivered %d event(s)\n", len(letters))
+	return nil
+}
+
+func newDispatcher(configPath, deadLetterPath string) (*webhook.Dispatcher, error) {
+	cfg, err := webhook.LoadConfig(configPath)
+	if err != nil {
+		return nil, err
+	}
+	dispatcher, err := webhook.NewDispatcher(cfg, &webhook.FileDeadLetters{Path: deadLetterPath})
+	if err != nil {
+		return nil, err
+	}
+	dispatcher.Logf = log.Printf
+	return dispatcher, nil
+}
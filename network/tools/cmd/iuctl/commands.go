@@ -0,0 +1,194 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+func txCreate(args []string) error {
+	fs, o := newFlags("tx create")
+	id := fs.String("id", "", "transaction ID")
+	creditor := fs.String("creditor", "", "creditor ID")
+	debtor := fs.String("debtor", "", "debtor ID")
+	amount := fs.Float64("amount", 0, "amount")
+	currency := fs.String("currency", "INR", "currency")
+	txType := fs.String("type", "", "transaction type, e.g. CREDIT or DEBIT")
+	description := fs.String("description", "", "description")
+	if err := parse(fs, o, args, "id", "creditor", "debtor", "type"); err != nil {
+		return err
+	}
+	if *amount <= 0 {
+		return fmt.Errorf("-amount must be positive")
+	}
+	return run(o, call{
+		function: "CreateTransaction",
+		args:     []string{*id, *creditor, *debtor, strconv.FormatFloat(*amount, 'f', -1, 64), *currency, *txType, *description},
+		submit:   true,
+	})
+}
+
+func txProcess(args []string) error {
+	fs, o := newFlags("tx process")
+	id := fs.String("id", "", "transaction ID")
+	if err := parse(fs, o, args, "id"); err != nil {
+		return err
+	}
+	return run(o, call{function: "ProcessTransaction", args: []string{*id}, submit: true})
+}
+
+func txComply(args []string) error {
+	fs, o := newFlags("tx comply")
+	id := fs.String("id", "", "transaction ID")
+	approved := fs.Bool("approved", true, "compliance outcome; -approved=false flags the transaction")
+	if err := parse(fs, o, args, "id"); err != nil {
+		return err
+	}
+	return run(o, call{function: "PerformComplianceCheck", args: []string{*id, strconv.FormatBool(*approved)}, submit: true})
+}
+
+func txHistory(args []string) error {
+	fs, o := newFlags("tx history")
+	id := fs.String("id", "", "transaction ID")
+	if err := parse(fs, o, args, "id"); err != nil {
+		return err
+	}
+	return run(o, call{function: "GetTransactionHistory", args: []string{*id}})
+}
+
+func docSubmit(args []string) error {
+	fs, o := newFlags("doc submit")
+	loan := fs.String("loan", "", "loan ID")
+	id := fs.String("id", "", "document ID")
+	file := fs.String("file", "", "document file; its SHA-256, size and MIME type are recorded")
+	hash := fs.String("hash", "", "SHA-256 hex of the document, when -file is not given")
+	docType := fs.String("type", "", "document type, e.g. SANCTION_LETTER")
+	mimeType := fs.String("mime", "", "MIME type (default detected from -file)")
+	size := fs.Int64("size", 0, "size in bytes, when -file is not given")
+	metadata := fs.String("metadata", "", "free-form metadata, usually JSON")
+	if err := parse(fs, o, args, "loan", "id", "type"); err != nil {
+		return err
+	}
+	if *file != "" {
+		h, n, detected, err := describeFile(*file)
+		if err != nil {
+			return err
+		}
+		*hash, *size = h, n
+		if *mimeType == "" {
+			*mimeType = detected
+		}
+	}
+	if *hash == "" {
+		return fmt.Errorf("-file or -hash is required")
+	}
+	return run(o, call{
+		function: "SubmitLoanDocument",
+		args:     []string{*loan, *id, *hash, *docType, *mimeType, strconv.FormatInt(*size, 10), *metadata},
+		submit:   true,
+	})
+}
+
+// describeFile returns a file's SHA-256 hex, size and MIME type. The
+// document itself stays off-chain.
+func describeFile(path string) (string, int64, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, "", err
+	}
+	defer f.Close()
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", 0, "", err
+	}
+	h := sha256.New()
+	h.Write(head[:n])
+	rest, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, "", err
+	}
+	mimeType := mime.TypeByExtension(filepath.Ext(path))
+	if mimeType == "" {
+		mimeType = http.DetectContentType(head[:n])
+	}
+	return hex.EncodeToString(h.Sum(nil)), int64(n) + rest, mimeType, nil
+}
+
+func docGet(args []string) error {
+	fs, o := newFlags("doc get")
+	id := fs.String("id", "", "document ID")
+	if err := parse(fs, o, args, "id"); err != nil {
+		return err
+	}
+	return run(o, call{function: "GetDocument", args: []string{*id}})
+}
+
+func docList(args []string) error {
+	fs, o := newFlags("doc list")
+	loan := fs.String("loan", "", "loan ID")
+	if err := parse(fs, o, args, "loan"); err != nil {
+		return err
+	}
+	return run(o, call{function: "GetLoanDocuments", args: []string{*loan}})
+}
+
+func kycSubmit(args []string) error {
+	fs, o := newFlags("kyc submit")
+	loan := fs.String("loan", "", "loan ID")
+	id := fs.String("id", "", "KYC ID")
+	party := fs.String("party", "", "party ID")
+	formC := fs.String("form-c", "", "Form C file, sent as transient data and kept off the public ledger")
+	if err := parse(fs, o, args, "loan", "id", "party", "form-c"); err != nil {
+		return err
+	}
+	form, err := os.ReadFile(*formC)
+	if err != nil {
+		return err
+	}
+	if len(form) == 0 {
+		return fmt.Errorf("%s is empty", *formC)
+	}
+	return run(o, call{
+		function:  "SubmitKYCFormC",
+		args:      []string{*loan, *id, *party},
+		transient: map[string][]byte{"formc": form},
+		submit:    true,
+	})
+}
+
+func kycApprove(args []string) error {
+	fs, o := newFlags("kyc approve")
+	id := fs.String("id", "", "KYC ID")
+	approved := fs.Bool("approved", true, "approve; -approved=false rejects")
+	remarks := fs.String("remarks", "", "remarks")
+	if err := parse(fs, o, args, "id"); err != nil {
+		return err
+	}
+	return run(o, call{function: "ApproveKYC", args: []string{*id, strconv.FormatBool(*approved), *remarks}, submit: true})
+}
+
+func auditTrail(args []string) error {
+	fs, o := newFlags("audit trail")
+	ref := fs.String("ref", "", "ID of the record whose audit trail to show")
+	if err := parse(fs, o, args, "ref"); err != nil {
+		return err
+	}
+	return run(o, call{function: "GetAuditTrail", args: []string{*ref}})
+}
+
+func auditBackfill(args []string) error {
+	fs, o := newFlags("audit backfill")
+	start := fs.String("start", "", "audit record key to resume from, the nextKey of the previous batch")
+	limit := fs.Int("limit", 200, "audit records to scan in this batch")
+	if err := parse(fs, o, args); err != nil {
+		return err
+	}
+	return run(o, call{function: "BackfillAuditRecords", args: []string{*start, strconv.Itoa(*limit)}, submit: true})
+}
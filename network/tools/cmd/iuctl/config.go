@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"time"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+
+	"iu-tools/internal/gateway"
+	"iu-tools/internal/moratorium"
+	"iu-tools/internal/output"
+)
+
+// Config is the iuctl config file. Profiles not listed fall back to the
+// local network's creditor, debtor and admin Admin identities under OrgsDir.
+//
+//	{
+//	  "defaultProfile": "creditor",
+//	  "orgsDir": "../organizations",
+//	  "profiles": {
+//	    "sbi-ops": {"mspId": "CreditorMSP", "peerEndpoint": "peer0.creditor.iu-network.com:7051", ...}
+//	  }
+//	}
+type Config struct {
+	DefaultProfile string                     `json:"defaultProfile"`
+	Channel        string                     `json:"channel"`
+	Chaincode      string                     `json:"chaincode"`
+	OrgsDir        string                     `json:"orgsDir"`
+	Profiles       map[string]gateway.Profile `json:"profiles"`
+	// BlockedLog is the JSON Lines file that calls refused under an
+	// insolvency moratorium are appended to; "-" disables it
+	BlockedLog string `json:"blockedLog"`
+}
+
+func defaultConfigPath() string {
+	if p := os.Getenv("IUCTL_CONFIG"); p != "" {
+		return p
+	}
+	if dir, err := os.UserConfigDir(); err == nil {
+		return filepath.Join(dir, "iuctl", "config.json")
+	}
+	return "iuctl.json"
+}
+
+// loadConfig reads path, using the local network defaults if it does not exist
+func loadConfig(path string) (*Config, error) {
+	cfg := &Config{}
+	b, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(b, cfg); err != nil {
+			return nil, fmt.Errorf("invalid config %s: %v", path, err)
+		}
+	}
+	if cfg.DefaultProfile == "" {
+		cfg.DefaultProfile = "creditor"
+	}
+	if cfg.Channel == "" {
+		cfg.Channel = gateway.FinancialChannel
+	}
+	if cfg.Chaincode == "" {
+		cfg.Chaincode = gateway.ChaincodeName
+	}
+	if cfg.OrgsDir == "" {
+		cfg.OrgsDir = "../organizations"
+	}
+	if cfg.BlockedLog == "" {
+		cfg.BlockedLog = filepath.Join(filepath.Dir(path), "moratorium-blocked.jsonl")
+	}
+	return cfg, nil
+}
+
+func (c *Config) profile(name string) (gateway.Profile, error) {
+	if name == "" {
+		name = c.DefaultProfile
+	}
+	if p, ok := c.Profiles[name]; ok {
+		return p, nil
+	}
+	return gateway.DefaultProfile(name, c.OrgsDir)
+}
+
+// call is one chaincode invocation
+type call struct {
+	function  string
+	args      []string
+	transient map[string][]byte
+	submit    bool
+}
+
+// connect connects as the selected profile and returns the chaincode's
+// contract on the selected channel. The caller closes the connection.
+func connect(o *options) (*gateway.Connection, *client.Contract, gateway.Profile, error) {
+	cfg, err := loadConfig(o.config)
+	if err != nil {
+		return nil, nil, gateway.Profile{}, err
+	}
+	profile, err := cfg.profile(o.profile)
+	if err != nil {
+		return nil, nil, gateway.Profile{}, err
+	}
+	conn, err := gateway.Connect(profile)
+	if err != nil {
+		return nil, nil, gateway.Profile{}, err
+	}
+	return conn, conn.GetNetwork(cfg.channel(o)).GetContract(cfg.Chaincode), profile, nil
+}
+
+// recordBlocked appends c to the config's blocked log if err is the chaincode
+// refusing it under a moratorium. err is returned either way.
+func recordBlocked(o *options, c call, err error) error {
+	msg := gateway.ErrorMessage(err)
+	if !moratorium.Blocked(msg) {
+		return err
+	}
+	cfg, cfgErr := loadConfig(o.config)
+	if cfgErr != nil || cfg.BlockedLog == "-" {
+		return err
+	}
+	profile := o.profile
+	if profile == "" {
+		profile = cfg.DefaultProfile
+	}
+	username := ""
+	if u, uErr := user.Current(); uErr == nil {
+		username = u.Username
+	}
+	logErr := (&moratorium.Log{Path: cfg.BlockedLog}).Record(moratorium.Attempt{
+		Time:     time.Now().UTC(),
+		Source:   "iuctl",
+		Client:   username,
+		Org:      profile,
+		Function: c.function,
+		Args:     c.args,
+		Error:    msg,
+	})
+	if logErr != nil {
+		fmt.Fprintf(os.Stderr, "⚠️ failed to record blocked %s in %s: %v\n", c.function, cfg.BlockedLog, logErr)
+	}
+	return err
+}
+
+// channel is the channel selected by o, or the config's
+func (c *Config) channel(o *options) string {
+	if o.channel != "" {
+		return o.channel
+	}
+	return c.Channel
+}
+
+// run connects as the selected profile, invokes c and prints the result.
+// Submitted functions that return nothing print their TxID and block.
+func run(o *options, c call) error {
+	conn, contract, profile, err := connect(o)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	opts := []client.ProposalOption{client.WithArguments(c.args...)}
+	if len(c.transient) > 0 {
+		opts = append(opts, client.WithTransient(c.transient))
+	}
+	if !c.submit {
+		result, err := contract.Evaluate(c.function, opts...)
+		if err != nil {
+			return err
+		}
+		return output.Write(os.Stdout, o.output, result)
+	}
+
+	proposal, err := contract.NewProposal(c.function, opts...)
+	if err != nil {
+		return err
+	}
+	tx, err := proposal.Endorse()
+	if err != nil {
+		return recordBlocked(o, c, err)
+	}
+	commit, err := tx.Submit()
+	if err != nil {
+		return err
+	}
+	status, err := commit.Status()
+	if err != nil {
+		return err
+	}
+	if !status.Successful {
+		return fmt.Errorf("transaction %s failed to commit with status %s", status.TransactionID, status.Code)
+	}
+	result := tx.Result()
+	if len(result) == 0 {
+		result, _ = json.Marshal(map[string]interface{}{
+			"function":    c.function,
+			"txId":        status.TransactionID,
+			"blockNumber": status.BlockNumber,
+			"mspId":       profile.MSPID,
+		})
+	}
+	return output.Write(os.Stdout, o.output, result)
+}
@@ -0,0 +1,118 @@
+// Command iuctl is the operators' client for iu-chaincode, replacing
+// hand-written `peer chaincode invoke` calls in runbooks. Org identities
+// come from profiles in a config file; results print as JSON, a table or
+// CSV.
+//
+//	iuctl tx create -id TX100 -creditor C1 -debtor D1 -amount 250000 -type CREDIT
+//	iuctl tx process|comply|history -id TX100
+//	iuctl doc submit -loan LOAN001 -id DOC1 -file sanction.pdf -type SANCTION_LETTER
+//	iuctl doc get -id DOC1
+//	iuctl doc list -loan LOAN001 -output table
+//	iuctl kyc submit -profile admin -loan LOAN001 -id KYC001 -party D1 -form-c formc.json
+//	iuctl kyc approve -profile admin -id KYC001 -remarks verified
+//	iuctl audit trail -ref TX100 -output csv
+//	iuctl snapshot commit -profile admin -period 2024-04 -db sqlite:iu-index.db
+//	iuctl snapshot proof -key TX100 -period 2024-04 -db sqlite:iu-index.db > TX100-2024-04.proof.json
+//	iuctl snapshot verify -in TX100-2024-04.proof.json -root <root>
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"iu-tools/internal/gateway"
+	"iu-tools/internal/output"
+)
+
+var commands = map[string]map[string]func(args []string) error{
+	"tx": {
+		"create":  txCreate,
+		"process": txProcess,
+		"comply":  txComply,
+		"history": txHistory,
+	},
+	"doc": {
+		"submit": docSubmit,
+		"get":    docGet,
+		"list":   docList,
+	},
+	"kyc": {
+		"submit":  kycSubmit,
+		"approve": kycApprove,
+	},
+	"audit": {
+		"trail":    auditTrail,
+		"backfill": auditBackfill,
+	},
+	"snapshot": {
+		"commit": snapshotCommit,
+		"root":   snapshotRoot,
+		"proof":  snapshotProof,
+		"verify": snapshotVerify,
+	},
+}
+
+func main() {
+	if len(os.Args) < 3 {
+		usage()
+	}
+	cmd, ok := commands[os.Args[1]][os.Args[2]]
+	if !ok {
+		usage()
+	}
+	if err := cmd(os.Args[3:]); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", gateway.ErrorMessage(err))
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: iuctl <command> <action> [flags]")
+	for _, group := range []string{"tx", "doc", "kyc", "audit", "snapshot"} {
+		var actions []string
+		for a := range commands[group] {
+			actions = append(actions, a)
+		}
+		sort.Strings(actions)
+		fmt.Fprintf(os.Stderr, "  %-8s %s\n", group, strings.Join(actions, "|"))
+	}
+	os.Exit(2)
+}
+
+// options are the flags every action takes
+type options struct {
+	config  string
+	profile string
+	output  string
+	channel string
+}
+
+func newFlags(name string) (*flag.FlagSet, *options) {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	o := &options{}
+	fs.StringVar(&o.config, "config", defaultConfigPath(), "iuctl config file (env IUCTL_CONFIG)")
+	fs.StringVar(&o.profile, "profile", "", "org profile to act as (default from config)")
+	fs.StringVar(&o.output, "output", output.JSON, "output format: json, table or csv")
+	fs.StringVar(&o.channel, "channel", "", "channel (default from config)")
+	return fs, o
+}
+
+// parse parses args and checks that the required flags were given
+func parse(fs *flag.FlagSet, o *options, args []string, required ...string) error {
+	fs.Parse(args)
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+	if err := output.Check(o.output); err != nil {
+		return err
+	}
+	for _, name := range required {
+		if fs.Lookup(name).Value.String() == "" {
+			return fmt.Errorf("-%s is required", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+	"iu-chaincode/merkle"
+
+	"iu-tools/internal/indexer"
+	"iu-tools/internal/output"
+	"iu-tools/internal/snapshot"
+)
+
+// openIndex opens the iu-indexer index the snapshot trees are built from
+func openIndex(o *options, dsn string) (*indexer.Store, error) {
+	cfg, err := loadConfig(o.config)
+	if err != nil {
+		return nil, err
+	}
+	return indexer.Open(dsn, cfg.channel(o), cfg.Chaincode)
+}
+
+// snapshotCommit builds a period's tree from the index and commits its root
+func snapshotCommit(args []string) error {
+	fs, o := newFlags("snapshot commit")
+	period := fs.String("period", "", "period that has ended, YYYY-MM or YYYY-MM-DD")
+	db := fs.String("db", "sqlite:iu-index.db", "iu-indexer index, sqlite:<path> or postgres://...")
+	if err := parse(fs, o, args, "period"); err != nil {
+		return err
+	}
+	store, err := openIndex(o, *db)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	tree, err := snapshot.Build(store, *period)
+	if err != nil {
+		return err
+	}
+	return run(o, call{function: "CommitSnapshotRoot", args: []string{*period, tree.Root(), strconv.Itoa(len(tree.Leaves))}, submit: true})
+}
+
+func snapshotRoot(args []string) error {
+	fs, o := newFlags("snapshot root")
+	period := fs.String("period", "", "period")
+	if err := parse(fs, o, args, "period"); err != nil {
+		return err
+	}
+	return run(o, call{function: "GetSnapshotRoot", args: []string{*period}})
+}
+
+// snapshotProof builds a period's tree from the index and prints the proofs
+// of a key's versions once the tree reproduces the committed root
+func snapshotProof(args []string) error {
+	fs, o := newFlags("snapshot proof")
+	key := fs.String("key", "", "world state key of the record, e.g. TX100, DOC_DOC1 or DEFAULT_DEF1")
+	period := fs.String("period", "", "period")
+	db := fs.String("db", "sqlite:iu-index.db", "iu-indexer index, sqlite:<path> or postgres://...")
+	if err := parse(fs, o, args, "key", "period"); err != nil {
+		return err
+	}
+	store, err := openIndex(o, *db)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	tree, err := snapshot.Build(store, *period)
+	if err != nil {
+		return err
+	}
+
+	conn, contract, _, err := connect(o)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	b, err := contract.Evaluate("GetSnapshotRoot", client.WithArguments(*period))
+	if err != nil {
+		return err
+	}
+	var committed struct {
+		Root      string `json:"root"`
+		LeafCount int    `json:"leafCount"`
+	}
+	if err := json.Unmarshal(b, &committed); err != nil {
+		return fmt.Errorf("invalid snapshot root: %v", err)
+	}
+	if root := tree.Root(); root != committed.Root || len(tree.Leaves) != committed.LeafCount {
+		return fmt.Errorf("the index rebuilds the snapshot of %s as %s over %d versions, but %s over %d is committed",
+			*period, root, len(tree.Leaves), committed.Root, committed.LeafCount)
+	}
+
+	proofs, err := tree.Proofs(*key)
+	if err != nil {
+		return err
+	}
+	result, err := json.Marshal(proofs)
+	if err != nil {
+		return err
+	}
+	return output.Write(os.Stdout, o.output, result)
+}
+
+// snapshotVerify checks a saved proof without the ledger: against a root
+// obtained separately, and optionally against the record's bytes
+func snapshotVerify(args []string) error {
+	fs, o := newFlags("snapshot verify")
+	in := fs.String("in", "", "proof file written by snapshot proof")
+	root := fs.String("root", "", "committed snapshot root, hex")
+	value := fs.String("value", "", "file holding the record's stored value, checked against the proven version (optional)")
+	if err := parse(fs, o, args, "in", "root"); err != nil {
+		return err
+	}
+	b, err := os.ReadFile(*in)
+	if err != nil {
+		return err
+	}
+	var proofs []snapshot.Proof
+	if err := json.Unmarshal(b, &proofs); err != nil {
+		return fmt.Errorf("invalid proof file: %v", err)
+	}
+	if len(proofs) == 0 {
+		return fmt.Errorf("%s holds no proofs", *in)
+	}
+	valueHash := ""
+	if *value != "" {
+		v, err := os.ReadFile(*value)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(v)
+		valueHash = hex.EncodeToString(sum[:])
+	}
+
+	matched := false
+	for _, p := range proofs {
+		ok, err := merkle.Verify(*root, merkle.LeafData(p.Key, p.TxID, p.ValueHash), p.Path)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("version %s of %s is not in the snapshot with root %s", p.TxID, p.Key, *root)
+		}
+		fmt.Printf("✅ version %s of %s (sha256 %s) is in the %s snapshot\n", p.TxID, p.Key, p.ValueHash, p.Period)
+		matched = matched || p.ValueHash == valueHash
+	}
+	if valueHash != "" && !matched {
+		return fmt.Errorf("%s (sha256 %s) matches none of the proven versions", *value, valueHash)
+	}
+	return nil
+}
@@ -0,0 +1,256 @@
+// Command reg-report writes the IU's periodic returns to IBBI and RBI from
+// the query index kept by iu-indexer. A return for a period is written as
+// JSON with a detached signature by the IU org's key, and as CSV and XLSX.
+// The JSON's digest and the other files' hashes are anchored on
+// audit-compliance-channel, so verify can show a regulator that a return
+// was not altered after it was filed.
+//
+//	reg-report generate -regulator IBBI -from 2024-04-01 -to 2024-06-30
+//	reg-report verify -in RETURN_IBBI_20240401_20240630.json -files RETURN_IBBI_20240401_20240630.csv,RETURN_IBBI_20240401_20240630.xlsx
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"iu-tools/internal/detachedsig"
+	"iu-tools/internal/gateway"
+	"iu-tools/internal/indexer"
+	"iu-tools/internal/regreport"
+)
+
+const defaultMSPDir = "../organizations/peerOrganizations/admin.iu-network.com/users/Admin@admin.iu-network.com/msp"
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	var err error
+	switch os.Args[1] {
+	case "generate":
+		err = runGenerate(os.Args[2:])
+	case "verify":
+		err = runVerify(os.Args[2:])
+	default:
+		usage()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: reg-report generate|verify [flags]")
+	os.Exit(2)
+}
+
+type networkFlags struct {
+	org, orgsDir, channel, chaincode *string
+}
+
+func addNetworkFlags(fs *flag.FlagSet) networkFlags {
+	return networkFlags{
+		org:       fs.String("org", "admin", "org whose gateway peer and identity to use"),
+		orgsDir:   fs.String("orgs", "../organizations", "network organizations directory"),
+		channel:   fs.String("anchor-channel", gateway.AuditChannel, "channel returns are anchored on"),
+		chaincode: fs.String("chaincode", gateway.ChaincodeName, "chaincode name"),
+	}
+}
+
+func (f networkFlags) connect() (*gateway.Connection, error) {
+	profile, err := gateway.DefaultProfile(*f.org, *f.orgsDir)
+	if err != nil {
+		return nil, err
+	}
+	return gateway.Connect(profile)
+}
+
+func runGenerate(args []string) error {
+	fs := flag.NewFlagSet("generate", flag.ExitOnError)
+	nf := addNetworkFlags(fs)
+	regulator := fs.String("regulator", regreport.IBBI, "regulator the return is for, IBBI or RBI")
+	fromFlag := fs.String("from", "", "first day of the period, YYYY-MM-DD")
+	toFlag := fs.String("to", "", "last day of the period, YYYY-MM-DD")
+	window := fs.Int("window", 30, "deemed authentication window in days that turnaround is measured against")
+	db := fs.String("db", "sqlite:iu-index.db", "query index written by iu-indexer")
+	channel := fs.String("channel", gateway.FinancialChannel, "indexed channel")
+	outDir := fs.String("out-dir", ".", "directory the return files are written to")
+	mspID := fs.String("mspid", "AdminMSP", "MSP ID of the signing IU org")
+	keyPath := fs.String("key", defaultMSPDir+"/keystore/priv_sk", "signing key")
+	certPath := fs.String("cert", defaultMSPDir+"/signcerts/Admin@admin.iu-network.com-cert.pem", "signing certificate")
+	anchor := fs.Bool("anchor", true, "anchor the return's hashes on the audit channel")
+	fs.Parse(args)
+
+	if *fromFlag == "" || *toFlag == "" {
+		return fmt.Errorf("-from and -to are required")
+	}
+	from, err := time.Parse("2006-01-02", *fromFlag)
+	if err != nil {
+		return fmt.Errorf("invalid -from: %v", err)
+	}
+	to, err := time.Parse("2006-01-02", *toFlag)
+	if err != nil {
+		return fmt.Errorf("invalid -to: %v", err)
+	}
+
+	store, err := indexer.Open(*db, *channel, *nf.chaincode)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	l, err := regreport.Load(store)
+	if err != nil {
+		return err
+	}
+	report, err := regreport.Build(*regulator, from, to, *window, l)
+	if err != nil {
+		return err
+	}
+
+	doc, err := report.JSON()
+	if err != nil {
+		return err
+	}
+	csvFile, err := report.CSV()
+	if err != nil {
+		return err
+	}
+	xlsxFile, err := report.XLSX()
+	if err != nil {
+		return err
+	}
+	signer, err := detachedsig.LoadSigner(*mspID, *keyPath, *certPath)
+	if err != nil {
+		return err
+	}
+	sig, err := signer.Sign(regreport.SignatureType, doc, time.Now())
+	if err != nil {
+		return err
+	}
+	sigJSON, err := json.MarshalIndent(sig, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	ref := regreport.Ref(report)
+	base := filepath.Join(*outDir, ref)
+	var files []regreport.File
+	for _, f := range []struct {
+		path    string
+		content []byte
+	}{
+		{base + ".json", doc},
+		{base + ".json.sig", append(sigJSON, '\n')},
+		{base + ".csv", csvFile},
+		{base + ".xlsx", xlsxFile},
+	} {
+		if err := os.WriteFile(f.path, f.content, 0o644); err != nil {
+			return err
+		}
+		if ext := filepath.Ext(f.path); ext == ".csv" || ext == ".xlsx" {
+			files = append(files, regreport.File{Name: filepath.Base(f.path), SHA256: sha256Hex(f.content)})
+		}
+		fmt.Printf("wrote %s\n", f.path)
+	}
+	s := report.Summary
+	fmt.Printf("%s %s to %s, indexed to block %d: %d defaults filed (%d authenticated, %d deemed, %d disputed, %d pending)\n",
+		report.Regulator, report.From, report.To, report.IndexedToBlock, s.DefaultsFiled, s.Authenticated, s.DeemedAuthenticated, s.Disputed, s.Pending)
+	fmt.Printf("sha256 %s\n", sig.Digest)
+
+	if !*anchor {
+		return nil
+	}
+	conn, err := nf.connect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, err := regreport.Anchor(conn.GetNetwork(*nf.channel).GetContract(*nf.chaincode), report, sig.Digest, files); err != nil {
+		return fmt.Errorf("%s", gateway.ErrorMessage(err))
+	}
+	fmt.Printf("✅ anchored as %s on %s\n", ref, *nf.channel)
+	return nil
+}
+
+func runVerify(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	nf := addNetworkFlags(fs)
+	in := fs.String("in", "", "return JSON")
+	sigPath := fs.String("sig", "", "detached signature (default <in>.sig)")
+	fileList := fs.String("files", "", "comma-separated CSV or XLSX files of the return to check as well")
+	caPath := fs.String("ca", "", "CA certificate of the IU org that the signer must chain to")
+	fs.Parse(args)
+	if *in == "" {
+		return fmt.Errorf("-in is required")
+	}
+	if *caPath == "" {
+		return fmt.Errorf("-ca is required")
+	}
+	if *sigPath == "" {
+		*sigPath = *in + ".sig"
+	}
+
+	doc, err := os.ReadFile(*in)
+	if err != nil {
+		return err
+	}
+	var report regreport.Report
+	if err := json.Unmarshal(doc, &report); err != nil {
+		return fmt.Errorf("invalid return: %v", err)
+	}
+	b, err := os.ReadFile(*sigPath)
+	if err != nil {
+		return err
+	}
+	var sig detachedsig.Signature
+	if err := json.Unmarshal(b, &sig); err != nil {
+		return fmt.Errorf("invalid signature file: %v", err)
+	}
+	roots, err := detachedsig.LoadRoots(regreport.AnchorMSP, *caPath)
+	if err != nil {
+		return err
+	}
+	if err := detachedsig.Verify(doc, &sig, roots); err != nil {
+		return err
+	}
+	if sig.DocumentType != regreport.SignatureType {
+		return fmt.Errorf("signature is for a %s, not a regulatory return", sig.DocumentType)
+	}
+
+	var files []regreport.File
+	if *fileList != "" {
+		for _, path := range strings.Split(*fileList, ",") {
+			content, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			files = append(files, regreport.File{Name: filepath.Base(path), SHA256: sha256Hex(content)})
+		}
+	}
+
+	conn, err := nf.connect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	ref := regreport.Ref(&report)
+	a, err := regreport.CheckAnchor(conn.GetNetwork(*nf.channel).GetContract(*nf.chaincode), ref, sig.Digest, sig.SignerMSPID, files)
+	if err != nil {
+		return fmt.Errorf("%s", gateway.ErrorMessage(err))
+	}
+	fmt.Printf("✅ %s signed by %s and anchored as %s by %s at %s\n", *in, sig.SignerMSPID, ref, a.Actor, a.Timestamp)
+	return nil
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,142 @@
+// Command rod-sign signs a record of default certificate returned by
+// GetRecordOfDefault with the IU org's key, producing a detached signature
+// that can be attached to an NCLT application alongside the certificate.
+//
+//	rod-sign sign -in rod.json -out rod.json.sig
+//	rod-sign verify -in rod.json -sig rod.json.sig -ca ca.admin.iu-network.com-cert.pem
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"iu-tools/internal/detachedsig"
+)
+
+const (
+	certificateType = "IU_RECORD_OF_DEFAULT"
+	defaultMSPDir   = "../organizations/peerOrganizations/admin.iu-network.com/users/Admin@admin.iu-network.com/msp"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	var err error
+	switch os.Args[1] {
+	case "sign":
+		err = runSign(os.Args[2:])
+	case "verify":
+		err = runVerify(os.Args[2:])
+	default:
+		usage()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: rod-sign sign|verify [flags]")
+	os.Exit(2)
+}
+
+func runSign(args []string) error {
+	fs := flag.NewFlagSet("sign", flag.ExitOnError)
+	in := fs.String("in", "", "record of default JSON from GetRecordOfDefault")
+	out := fs.String("out", "", "signature output path (default <in>.sig)")
+	mspID := fs.String("mspid", "AdminMSP", "MSP ID of the signing IU org")
+	keyPath := fs.String("key", defaultMSPDir+"/keystore/priv_sk", "signing key")
+	certPath := fs.String("cert", defaultMSPDir+"/signcerts/Admin@admin.iu-network.com-cert.pem", "signing certificate")
+	fs.Parse(args)
+	if *in == "" {
+		return fmt.Errorf("-in is required")
+	}
+	if *out == "" {
+		*out = *in + ".sig"
+	}
+
+	doc, err := readCertificate(*in)
+	if err != nil {
+		return err
+	}
+	signer, err := detachedsig.LoadSigner(*mspID, *keyPath, *certPath)
+	if err != nil {
+		return err
+	}
+	sig, err := signer.Sign(certificateType, doc, time.Now())
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(sig, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(*out, b, 0o644); err != nil {
+		return err
+	}
+	fmt.Printf("✅ Signed %s (sha256 %s) -> %s\n", *in, sig.Digest, *out)
+	return nil
+}
+
+func runVerify(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	in := fs.String("in", "", "record of default JSON")
+	sigPath := fs.String("sig", "", "detached signature (default <in>.sig)")
+	caPath := fs.String("ca", "", "IU org CA certificate to check the signer against")
+	mspID := fs.String("mspid", "AdminMSP", "MSP ID of the org whose CA is given as -ca")
+	fs.Parse(args)
+	if *in == "" || *caPath == "" {
+		return fmt.Errorf("-in and -ca are required")
+	}
+	if *sigPath == "" {
+		*sigPath = *in + ".sig"
+	}
+
+	doc, err := readCertificate(*in)
+	if err != nil {
+		return err
+	}
+	b, err := os.ReadFile(*sigPath)
+	if err != nil {
+		return err
+	}
+	var sig detachedsig.Signature
+	if err := json.Unmarshal(b, &sig); err != nil {
+		return fmt.Errorf("invalid signature file: %v", err)
+	}
+	roots, err := detachedsig.LoadRoots(*mspID, *caPath)
+	if err != nil {
+		return err
+	}
+	if err := detachedsig.Verify(doc, &sig, roots); err != nil {
+		return err
+	}
+	if sig.DocumentType != certificateType {
+		return fmt.Errorf("signature is for %q, not a record of default", sig.DocumentType)
+	}
+	fmt.Printf("✅ Signature valid: signed by %s at %s\n", sig.SignerMSPID, sig.SignedAt.Format(time.RFC3339))
+	return nil
+}
+
+// readCertificate loads the certificate and checks it is a record of default
+func readCertificate(path string) ([]byte, error) {
+	doc, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var header struct {
+		CertificateType string `json:"certificateType"`
+	}
+	if err := json.Unmarshal(doc, &header); err != nil {
+		return nil, fmt.Errorf("%s is not valid JSON: %v", path, err)
+	}
+	if header.CertificateType != certificateType {
+		return nil, fmt.Errorf("%s is not a record of default certificate", path)
+	}
+	return doc, nil
+}
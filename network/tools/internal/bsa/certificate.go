@@ -0,0 +1,282 @@
+// Package bsa builds electronic evidence certificates under Section 63 of the
+// Bharatiya Sakshya Adhiniyam, 2023 for records held on the IU ledger.
+package bsa
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"iu-tools/internal/pdf"
+)
+
+// Title and Statute appear at the head of every certificate
+const (
+	Title         = "Certificate for Electronic Record"
+	Statute       = "Section 63(4)(c), Bharatiya Sakshya Adhiniyam, 2023"
+	HashAlgorithm = "SHA-256"
+)
+
+// Evidence mirrors the chaincode's RecordEvidence returned by GetRecordEvidence
+type Evidence struct {
+	RecordType string          `json:"recordType"`
+	RecordID   string          `json:"recordId"`
+	Key        string          `json:"key"`
+	Value      string          `json:"value"`
+	ValueHash  string          `json:"valueHash"`
+	History    []EvidenceEntry `json:"history"`
+}
+
+// EvidenceEntry mirrors the chaincode's RecordVersion
+type EvidenceEntry struct {
+	TxID      string    `json:"txId"`
+	Timestamp time.Time `json:"timestamp"`
+	IsDelete  bool      `json:"isDelete"`
+	Value     string    `json:"value"`
+	ValueHash string    `json:"valueHash"`
+}
+
+// BlockInfo locates a transaction in the channel's block chain
+type BlockInfo struct {
+	Number       uint64 `json:"blockNumber"`
+	DataHash     string `json:"blockDataHash"`
+	PreviousHash string `json:"previousBlockHash"`
+}
+
+// Version is one certified version of the record
+type Version struct {
+	TxID string `json:"txId"`
+	BlockInfo
+	Timestamp time.Time `json:"timestamp"`
+	IsDelete  bool      `json:"isDelete"`
+	ValueHash string    `json:"valueHash,omitempty"`
+}
+
+// Record identifies the certified ledger entry
+type Record struct {
+	Type      string `json:"type"`
+	ID        string `json:"id"`
+	Key       string `json:"key"`
+	Channel   string `json:"channel"`
+	Chaincode string `json:"chaincode"`
+}
+
+// System describes the computer system that produced the record
+type System struct {
+	Name        string   `json:"name"`
+	Platform    string   `json:"platform"`
+	Description []string `json:"description"`
+}
+
+// Custodian is the person in charge of the system making the declaration
+type Custodian struct {
+	Name         string `json:"name"`
+	Designation  string `json:"designation"`
+	Organisation string `json:"organisation"`
+	MSPID        string `json:"mspId"`
+	Place        string `json:"place"`
+	Date         string `json:"date"`
+}
+
+// Certificate is the complete Section 63 certificate
+type Certificate struct {
+	Title            string    `json:"title"`
+	Statute          string    `json:"statute"`
+	CertificateID    string    `json:"certificateId"`
+	Record           Record    `json:"record"`
+	HashAlgorithm    string    `json:"hashAlgorithm"`
+	CurrentValueHash string    `json:"currentValueHash"`
+	CurrentValue     string    `json:"currentValue"`
+	Versions         []Version `json:"versions"`
+	System           System    `json:"system"`
+	Custodian        Custodian `json:"custodian"`
+	Declaration      []string  `json:"declaration"`
+}
+
+// CheckHashes recomputes every hash in the evidence so a tampered response is rejected
+func CheckHashes(ev *Evidence) error {
+	if got := sha256Hex(ev.Value); got != ev.ValueHash {
+		return fmt.Errorf("current value hash mismatch for %s: ledger reported %s, computed %s", ev.Key, ev.ValueHash, got)
+	}
+	for _, h := range ev.History {
+		if h.IsDelete {
+			continue
+		}
+		if got := sha256Hex(h.Value); got != h.ValueHash {
+			return fmt.Errorf("history hash mismatch for %s in tx %s", ev.Key, h.TxID)
+		}
+	}
+	return nil
+}
+
+func sha256Hex(s string) string {
+	h := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(h[:])
+}
+
+// Build assembles the certificate; blocks maps each history TxID to its block.
+// When custodian.Date is empty the date of the latest version is used so the
+// output depends only on ledger content and the supplied inputs.
+func Build(ev *Evidence, blocks map[string]BlockInfo, record Record, custodian Custodian) (*Certificate, error) {
+	if err := CheckHashes(ev); err != nil {
+		return nil, err
+	}
+	record.Type = ev.RecordType
+	record.ID = ev.RecordID
+	record.Key = ev.Key
+
+	versions := make([]Version, 0, len(ev.History))
+	var latest time.Time
+	for _, h := range ev.History {
+		block, ok := blocks[h.TxID]
+		if !ok {
+			return nil, fmt.Errorf("no block found for tx %s", h.TxID)
+		}
+		versions = append(versions, Version{
+			TxID:      h.TxID,
+			BlockInfo: block,
+			Timestamp: h.Timestamp.UTC(),
+			IsDelete:  h.IsDelete,
+			ValueHash: h.ValueHash,
+		})
+		if h.Timestamp.After(latest) {
+			latest = h.Timestamp
+		}
+	}
+	if custodian.Date == "" {
+		custodian.Date = latest.UTC().Format("2006-01-02")
+	}
+
+	cert := &Certificate{
+		Title:            Title,
+		Statute:          Statute,
+		Record:           record,
+		HashAlgorithm:    HashAlgorithm,
+		CurrentValueHash: ev.ValueHash,
+		CurrentValue:     ev.Value,
+		Versions:         versions,
+		System:           describeSystem(record),
+		Custodian:        custodian,
+		Declaration:      declaration(custodian),
+	}
+	id, err := certificateID(cert)
+	if err != nil {
+		return nil, err
+	}
+	cert.CertificateID = id
+	return cert, nil
+}
+
+// certificateID is derived from the certificate content so that the same
+// ledger state always yields the same identifier
+func certificateID(cert *Certificate) (string, error) {
+	b, err := json.Marshal(cert)
+	if err != nil {
+		return "", err
+	}
+	return "BSA63-" + strings.ToUpper(sha256Hex(string(b))[:16]), nil
+}
+
+func describeSystem(record Record) System {
+	return System{
+		Name:     "Information Utility ledger",
+		Platform: "Hyperledger Fabric permissioned blockchain",
+		Description: []string{
+			fmt.Sprintf("The record is held in the world state and history database of channel %s, written only by chaincode %s.", record.Channel, record.Chaincode),
+			"Each transaction is endorsed by peers of the participating organisations (CreditorMSP, DebtorMSP, AdminMSP), ordered by the IU ordering service and committed to a hash-chained block ledger replicated on every peer.",
+			"Every version listed below is identified by its transaction ID and the number and data hash of the block that committed it, which can be checked independently against any peer's copy of the ledger.",
+			"Hash values are computed with " + HashAlgorithm + " over the exact bytes stored for the record.",
+		},
+	}
+}
+
+func declaration(c Custodian) []string {
+	return []string{
+		fmt.Sprintf("I, %s, %s of %s, occupy a responsible official position in relation to the operation of the system described above and the management of the relevant activities.", c.Name, c.Designation, c.Organisation),
+		"The electronic record described in this certificate was produced by the system during the period over which it was used regularly to store and process information for the activities of the Information Utility.",
+		"During that period information of the kind contained in the record was regularly fed into the system in the ordinary course of those activities.",
+		"Throughout the material part of that period the system was operating properly, and any period in which it was not operating properly was not such as to affect the electronic record or the accuracy of its contents.",
+		"The information contained in the electronic record reproduces, or is derived from, information fed into the system in the ordinary course of those activities.",
+		"The hash values stated in this certificate were generated from the ledger and identify the record and each of its versions.",
+		"The statements above are true to the best of my knowledge and belief.",
+	}
+}
+
+// JSON renders the certificate as indented JSON with a trailing newline
+func (c *Certificate) JSON() ([]byte, error) {
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+// PDF renders the certificate as a printable document
+func (c *Certificate) PDF() []byte {
+	var lines []pdf.Line
+	heading := func(text string) {
+		lines = append(lines, pdf.Line{}, pdf.Line{Text: text, Font: pdf.Bold, Size: 12})
+	}
+	field := func(label, value string) {
+		lines = append(lines, pdf.Line{Text: label + ": " + value})
+	}
+	mono := func(text string) {
+		lines = append(lines, pdf.Line{Text: text, Font: pdf.Mono, Size: 8})
+	}
+
+	lines = append(lines,
+		pdf.Line{Text: c.Title, Font: pdf.Bold, Size: 16},
+		pdf.Line{Text: "under " + c.Statute, Size: 11},
+		pdf.Line{Text: "Certificate ID: " + c.CertificateID, Font: pdf.Mono, Size: 9},
+	)
+
+	heading("1. Electronic record")
+	field("Record type", c.Record.Type)
+	field("Record ID", c.Record.ID)
+	field("Ledger key", c.Record.Key)
+	field("Channel", c.Record.Channel)
+	field("Chaincode", c.Record.Chaincode)
+
+	heading("2. Hash values (" + c.HashAlgorithm + ")")
+	field("Current value", "")
+	mono(c.CurrentValueHash)
+	for i, v := range c.Versions {
+		state := "write"
+		if v.IsDelete {
+			state = "delete"
+		}
+		lines = append(lines, pdf.Line{Text: fmt.Sprintf("Version %d (%s) at %s", i+1, state, v.Timestamp.Format(time.RFC3339))})
+		mono("tx    " + v.TxID)
+		mono(fmt.Sprintf("block %d data hash %s", v.Number, v.DataHash))
+		if v.ValueHash != "" {
+			mono("value " + v.ValueHash)
+		}
+	}
+
+	heading("3. Description of the system")
+	field("System", c.System.Name)
+	field("Platform", c.System.Platform)
+	for _, d := range c.System.Description {
+		lines = append(lines, pdf.Line{Text: d})
+	}
+
+	heading("4. Declaration of the person in charge")
+	for i, d := range c.Declaration {
+		lines = append(lines, pdf.Line{Text: fmt.Sprintf("(%d) %s", i+1, d)})
+	}
+	lines = append(lines, pdf.Line{})
+	field("Name", c.Custodian.Name)
+	field("Designation", c.Custodian.Designation)
+	field("Organisation", fmt.Sprintf("%s (%s)", c.Custodian.Organisation, c.Custodian.MSPID))
+	field("Place", c.Custodian.Place)
+	field("Date", c.Custodian.Date)
+	lines = append(lines, pdf.Line{}, pdf.Line{Text: "Signature: ______________________________"})
+
+	heading("Annexure: current value of the record")
+	mono(c.CurrentValue)
+
+	return pdf.Render(c.Title+" "+c.CertificateID, lines)
+}
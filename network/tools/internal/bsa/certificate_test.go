@@ -0,0 +1,106 @@
+package bsa
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+func evidence() (*Evidence, map[string]BlockInfo) {
+	v1 := `{"transactionId":"TX1","amount":100000,"status":"PENDING"}`
+	v2 := `{"transactionId":"TX1","amount":100000,"status":"VERIFIED"}`
+	ev := &Evidence{
+		RecordType: "TRANSACTION",
+		RecordID:   "TX1",
+		Key:        "TX1",
+		Value:      v2,
+		ValueHash:  sha256Hex(v2),
+		History: []EvidenceEntry{
+			{TxID: "tx-b", Timestamp: time.Date(2024, 4, 2, 15, 30, 0, 0, time.FixedZone("IST", 19800)), Value: v2, ValueHash: sha256Hex(v2)},
+			{TxID: "tx-a", Timestamp: time.Date(2024, 4, 1, 10, 0, 0, 0, time.UTC), Value: v1, ValueHash: sha256Hex(v1)},
+		},
+	}
+	blocks := map[string]BlockInfo{
+		"tx-a": {Number: 7, DataHash: "aa11", PreviousHash: "9f00"},
+		"tx-b": {Number: 9, DataHash: "bb22", PreviousHash: "8e00"},
+	}
+	return ev, blocks
+}
+
+var (
+	record    = Record{Channel: "iu-channel", Chaincode: "iu-chaincode"}
+	custodian = Custodian{Name: "A. Rao", Designation: "Chief Technology Officer", Organisation: "IU Admin", MSPID: "AdminMSP", Place: "Mumbai"}
+)
+
+func TestBuild(t *testing.T) {
+	ev, blocks := evidence()
+	cert, err := Build(ev, blocks, record, custodian)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, _ := Build(ev, blocks, record, custodian)
+
+	js, err := cert.JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	js2, _ := again.JSON()
+	if !bytes.Equal(js, js2) {
+		t.Fatal("JSON is not reproducible")
+	}
+	doc := cert.PDF()
+	if !bytes.Equal(doc, again.PDF()) {
+		t.Fatal("PDF is not reproducible")
+	}
+	if cert.Custodian.Date != "2024-04-02" {
+		t.Errorf("date = %s, want the latest version's", cert.Custodian.Date)
+	}
+	if !strings.HasPrefix(cert.CertificateID, "BSA63-") {
+		t.Errorf("certificate ID = %s", cert.CertificateID)
+	}
+	golden(t, "testdata/certificate.json", js)
+	golden(t, "testdata/certificate.pdf", doc)
+
+	custodian := custodian
+	custodian.Name = "B. Iyer"
+	other, _ := Build(ev, blocks, record, custodian)
+	if other.CertificateID == cert.CertificateID {
+		t.Error("certificate ID does not depend on the content")
+	}
+}
+
+func TestBuildRejects(t *testing.T) {
+	ev, blocks := evidence()
+	ev.History[1].Value = `{"transactionId":"TX1","amount":1}`
+	if _, err := Build(ev, blocks, record, custodian); err == nil || !strings.Contains(err.Error(), "history hash mismatch") {
+		t.Errorf("tampered history: err = %v", err)
+	}
+
+	ev, blocks = evidence()
+	delete(blocks, "tx-a")
+	if _, err := Build(ev, blocks, record, custodian); err == nil || !strings.Contains(err.Error(), "no block found") {
+		t.Errorf("missing block: err = %v", err)
+	}
+}
+
+// golden compares b with the file at path, rewriting it under -update
+func golden(t *testing.T, path string, b []byte) {
+	t.Helper()
+	if *update {
+		if err := os.WriteFile(path, b, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, want) {
+		t.Errorf("output differs from %s; rerun with -update if the change is intended", path)
+	}
+}
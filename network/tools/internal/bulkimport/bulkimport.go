@@ -0,0 +1,433 @@
+// Package bulkimport loads a bank's historical loan book onto the IU through
+// BulkImportTransactions. Rows are read from CSV or JSON, split into batches
+// bounded by row count and size, and submitted one batch per transaction.
+// Progress is kept in a file so an interrupted import resumes where it
+// stopped without importing a batch twice.
+package bulkimport
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Row is one historical transaction, as the chaincode's ImportRow
+type Row struct {
+	ID              string  `json:"id"`
+	CreditorID      string  `json:"creditorId"`
+	DebtorID        string  `json:"debtorId"`
+	Amount          float64 `json:"amount"`
+	Currency        string  `json:"currency"`
+	TransactionType string  `json:"transactionType"`
+	Status          string  `json:"status,omitempty"`
+	Timestamp       string  `json:"timestamp,omitempty"`
+	Description     string  `json:"description,omitempty"`
+}
+
+// RowError is why the chaincode did not import a row. Row is 1-based
+// within the batch.
+type RowError struct {
+	Row   int    `json:"row"`
+	ID    string `json:"id"`
+	Error string `json:"error"`
+}
+
+// Result is the chaincode's record of a committed batch
+type Result struct {
+	BatchID   string     `json:"batchId"`
+	BatchHash string     `json:"batchHash"`
+	Rows      int        `json:"rows"`
+	Imported  int        `json:"imported"`
+	Errors    []RowError `json:"errors"`
+	TxID      string     `json:"txId"`
+}
+
+// csvColumns are the CSV header names, the same as the JSON field names
+var csvColumns = []string{"id", "creditorId", "debtorId", "amount", "currency", "transactionType", "status", "timestamp", "description"}
+
+// FormatOf returns "csv" or "json" from the extension of path
+func FormatOf(path string) (string, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return "csv", nil
+	case ".json":
+		return "json", nil
+	}
+	return "", fmt.Errorf("cannot tell the format of %s; use a .csv or .json file or set the format", path)
+}
+
+// Read parses rows in format "csv" or "json". A CSV file starts with a
+// header row naming its columns; id, creditorId, debtorId, amount,
+// currency and transactionType are required.
+func Read(r io.Reader, format string) ([]Row, error) {
+	switch format {
+	case "csv":
+		return readCSV(r)
+	case "json":
+		var rows []Row
+		dec := json.NewDecoder(r)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&rows); err != nil {
+			return nil, fmt.Errorf("invalid JSON rows: %v", err)
+		}
+		return rows, nil
+	}
+	return nil, fmt.Errorf("unknown format %q (want csv or json)", format)
+}
+
+func readCSV(r io.Reader) ([]Row, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %v", err)
+	}
+	col := map[string]int{}
+	for i, name := range header {
+		name = strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))
+		if !contains(csvColumns, name) {
+			return nil, fmt.Errorf("unknown CSV column %q", name)
+		}
+		col[name] = i
+	}
+	for _, name := range csvColumns[:6] {
+		if _, ok := col[name]; !ok {
+			return nil, fmt.Errorf("CSV column %s is required", name)
+		}
+	}
+
+	var rows []Row
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := cr.FieldPos(0)
+		get := func(name string) string {
+			if i, ok := col[name]; ok {
+				return strings.TrimSpace(rec[i])
+			}
+			return ""
+		}
+		amount, err := strconv.ParseFloat(get("amount"), 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid amount %q", line, get("amount"))
+		}
+		rows = append(rows, Row{
+			ID:              get("id"),
+			CreditorID:      get("creditorId"),
+			DebtorID:        get("debtorId"),
+			Amount:          amount,
+			Currency:        get("currency"),
+			TransactionType: get("transactionType"),
+			Status:          get("status"),
+			Timestamp:       get("timestamp"),
+			Description:     get("description"),
+		})
+	}
+}
+
+func contains(list []string, v string) bool {
+	for _, s := range list {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+// Batch is a run of rows submitted in one transaction. Payload is the
+// compact JSON array sent as transient data and Hash its hex SHA-256.
+type Batch struct {
+	ID      string
+	Rows    int
+	Payload []byte
+	Hash    string
+}
+
+// Split cuts rows into batches of at most maxRows rows and maxBytes of
+// payload. Batch IDs are prefix-0001, prefix-0002 and so on, so the same
+// rows split the same way always produce the same batches.
+func Split(rows []Row, prefix string, maxRows, maxBytes int) ([]Batch, error) {
+	if maxRows < 1 || maxBytes < 1 {
+		return nil, fmt.Errorf("batch limits must be positive")
+	}
+	var batches []Batch
+	var payload bytes.Buffer
+	n := 0
+	flush := func() {
+		if n == 0 {
+			return
+		}
+		payload.WriteByte(']')
+		b := append([]byte(nil), payload.Bytes()...)
+		batches = append(batches, Batch{
+			ID:      fmt.Sprintf("%s-%04d", prefix, len(batches)+1),
+			Rows:    n,
+			Payload: b,
+			Hash:    sha256Hex(b),
+		})
+		payload.Reset()
+		n = 0
+	}
+	for i, row := range rows {
+		b, err := json.Marshal(row)
+		if err != nil {
+			return nil, err
+		}
+		// A row must fit in a batch of its own, between the brackets
+		if len(b)+2 > maxBytes {
+			return nil, fmt.Errorf("row %d (%s) is %d bytes, more than the batch limit of %d", i+1, row.ID, len(b), maxBytes)
+		}
+		if n == maxRows || payload.Len()+1+len(b)+1 > maxBytes {
+			flush()
+		}
+		if n == 0 {
+			payload.WriteByte('[')
+		} else {
+			payload.WriteByte(',')
+		}
+		payload.Write(b)
+		n++
+	}
+	flush()
+	return batches, nil
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// SourceHash returns the hex SHA-256 of an input file, which ties a
+// progress file to the input it was made for
+func SourceHash(path string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return sha256Hex(b), nil
+}
+
+// Batch statuses in a progress file
+const (
+	Pending   = "PENDING"
+	Submitted = "SUBMITTED" // sent; the outcome is not known yet
+	Committed = "COMMITTED"
+	Failed    = "FAILED"
+)
+
+// BatchProgress is the state of one batch of an import
+type BatchProgress struct {
+	ID       string     `json:"id"`
+	Hash     string     `json:"hash"`
+	Rows     int        `json:"rows"`
+	Status   string     `json:"status"`
+	TxID     string     `json:"txId,omitempty"`
+	Imported int        `json:"imported"`
+	Errors   []RowError `json:"errors,omitempty"`
+	Error    string     `json:"error,omitempty"`
+}
+
+// Progress is what an import has done so far. It is saved after every
+// change of a batch's status.
+type Progress struct {
+	SourceHash string          `json:"sourceHash"`
+	Atomic     bool            `json:"atomic"`
+	Batches    []BatchProgress `json:"batches"`
+}
+
+// Totals returns the rows in all batches and the rows imported so far
+func (p *Progress) Totals() (rows, imported int) {
+	for _, b := range p.Batches {
+		rows += b.Rows
+		imported += b.Imported
+	}
+	return rows, imported
+}
+
+// LoadProgress reads a progress file; a missing file is no progress
+func LoadProgress(path string) (*Progress, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var p Progress
+	if err := json.Unmarshal(b, &p); err != nil {
+		return nil, fmt.Errorf("invalid progress file %s: %v", path, err)
+	}
+	return &p, nil
+}
+
+// Save writes p to path through a temporary file, so a crash leaves either
+// the old or the new progress and never a partial file
+func (p *Progress) Save(path string) error {
+	b, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(b, '\n'), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Submitter submits BulkImportTransactions with batch as the transient
+// field 'batch' and returns its TxID and result
+type Submitter interface {
+	SubmitImport(batchID, batchHash string, atomic bool, batch []byte) (txID string, result []byte, err error)
+}
+
+// Evaluator evaluates queries against the contract
+type Evaluator interface {
+	EvaluateTransaction(name string, args ...string) ([]byte, error)
+}
+
+// Contract is the chaincode an Importer loads batches into
+type Contract interface {
+	Submitter
+	Evaluator
+}
+
+// Importer submits batches and records their progress in ProgressPath
+type Importer struct {
+	Contract     Contract
+	ProgressPath string
+	Atomic       bool // reject a whole batch if any of its rows is invalid
+	Logf         func(format string, args ...interface{})
+}
+
+func (im *Importer) logf(format string, args ...interface{}) {
+	if im.Logf != nil {
+		im.Logf(format, args...)
+	}
+}
+
+// Run imports batches of the input with sourceHash, resuming from the
+// progress file if there is one. Committed batches are skipped; a batch
+// whose last attempt failed or has no known outcome is looked up with
+// GetImportBatch before it is submitted again. Run stops at the first
+// batch that fails and returns the progress so far with the error.
+func (im *Importer) Run(sourceHash string, batches []Batch) (*Progress, error) {
+	p, err := LoadProgress(im.ProgressPath)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		p = &Progress{SourceHash: sourceHash, Atomic: im.Atomic}
+		for _, b := range batches {
+			p.Batches = append(p.Batches, BatchProgress{ID: b.ID, Hash: b.Hash, Rows: b.Rows, Status: Pending})
+		}
+		if err := p.Save(im.ProgressPath); err != nil {
+			return nil, err
+		}
+	} else if err := p.matches(sourceHash, im.Atomic, batches); err != nil {
+		return p, fmt.Errorf("%s does not belong to this import: %v", im.ProgressPath, err)
+	}
+
+	for i, b := range batches {
+		bp := &p.Batches[i]
+		if bp.Status == Committed {
+			continue
+		}
+		if bp.Status != Pending {
+			done, err := im.lookup(bp)
+			if err != nil {
+				return p, err
+			}
+			if done {
+				im.logf("batch %s was committed in %s", bp.ID, bp.TxID)
+				if err := p.Save(im.ProgressPath); err != nil {
+					return p, err
+				}
+				continue
+			}
+		}
+
+		bp.Status, bp.Error = Submitted, ""
+		if err := p.Save(im.ProgressPath); err != nil {
+			return p, err
+		}
+		txID, result, err := im.Contract.SubmitImport(b.ID, b.Hash, im.Atomic, b.Payload)
+		bp.TxID = txID
+		if err != nil {
+			bp.Status, bp.Error = Failed, err.Error()
+			if serr := p.Save(im.ProgressPath); serr != nil {
+				return p, serr
+			}
+			return p, fmt.Errorf("batch %s: %v", b.ID, err)
+		}
+		var r Result
+		if err := json.Unmarshal(result, &r); err != nil {
+			return p, fmt.Errorf("batch %s: invalid result: %v", b.ID, err)
+		}
+		im.record(bp, &r)
+		if err := p.Save(im.ProgressPath); err != nil {
+			return p, err
+		}
+		im.logf("batch %s: %d of %d rows imported in %s", bp.ID, bp.Imported, bp.Rows, bp.TxID)
+	}
+	return p, nil
+}
+
+// lookup reports whether the batch is on the ledger, and records it if so
+func (im *Importer) lookup(bp *BatchProgress) (bool, error) {
+	result, err := im.Contract.EvaluateTransaction("GetImportBatch", bp.ID)
+	if err != nil {
+		if strings.Contains(err.Error(), "does not exist") {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to look up batch %s: %v", bp.ID, err)
+	}
+	var r Result
+	if err := json.Unmarshal(result, &r); err != nil {
+		return false, fmt.Errorf("batch %s: invalid result: %v", bp.ID, err)
+	}
+	if !strings.EqualFold(r.BatchHash, bp.Hash) {
+		return false, fmt.Errorf("batch %s on the ledger has hash %s, not %s; choose another batch prefix", bp.ID, r.BatchHash, bp.Hash)
+	}
+	im.record(bp, &r)
+	return true, nil
+}
+
+func (im *Importer) record(bp *BatchProgress, r *Result) {
+	bp.Status, bp.Error = Committed, ""
+	bp.TxID = r.TxID
+	bp.Imported = r.Imported
+	bp.Errors = r.Errors
+}
+
+// matches checks that a loaded progress file was made for these batches
+func (p *Progress) matches(sourceHash string, atomic bool, batches []Batch) error {
+	if p.SourceHash != sourceHash {
+		return fmt.Errorf("it is for input %s", p.SourceHash)
+	}
+	if p.Atomic != atomic {
+		return fmt.Errorf("it was started with atomic=%t", p.Atomic)
+	}
+	if len(p.Batches) != len(batches) {
+		return fmt.Errorf("it has %d batches, not %d; use the same batch limits", len(p.Batches), len(batches))
+	}
+	for i, b := range batches {
+		if p.Batches[i].ID != b.ID || p.Batches[i].Hash != b.Hash {
+			return fmt.Errorf("batch %d is %s, not %s; use the same prefix and batch limits", i+1, p.Batches[i].ID, b.ID)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,242 @@
+package bulkimport
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const bookCSV = `id,creditorId,debtorId,amount,currency,transactionType,timestamp,description
+OLD1,C1,D1,500000,INR,DEBIT,2019-06-30,term loan
+OLD2,C1,D1,100000,INR,CREDIT,2020-01-15,"repayment, first"
+OLD3,C1,D2,75000.5,INR,DEBIT,2021-03-01,
+OLD4,C1,D2,75000.5,INR,CREDIT,2021-09-01,
+OLD5,C1,D3,20000,INR,DEBIT,2022-02-01,
+`
+
+// fakeContract commits batches in memory. failNext makes the next submit
+// fail, after committing if commitAnyway is set, as when the commit status
+// is lost.
+type fakeContract struct {
+	batches      map[string]Result
+	submits      []string
+	failNext     bool
+	commitAnyway bool
+}
+
+func (f *fakeContract) SubmitImport(batchID, batchHash string, atomic bool, batch []byte) (string, []byte, error) {
+	f.submits = append(f.submits, batchID)
+	if _, ok := f.batches[batchID]; ok {
+		return "", nil, fmt.Errorf("batch %s already imported", batchID)
+	}
+	if sha256Hex(batch) != batchHash {
+		return "", nil, errors.New("batch hash mismatch")
+	}
+	var rows []Row
+	if err := json.Unmarshal(batch, &rows); err != nil {
+		return "", nil, err
+	}
+	txID := fmt.Sprintf("tx%d", len(f.submits))
+	r := Result{BatchID: batchID, BatchHash: batchHash, Rows: len(rows), Errors: []RowError{}, TxID: txID}
+	for i, row := range rows {
+		if row.Amount <= 0 {
+			r.Errors = append(r.Errors, RowError{Row: i + 1, ID: row.ID, Error: "amount must be positive"})
+		}
+	}
+	r.Imported = r.Rows - len(r.Errors)
+	if f.failNext {
+		f.failNext = false
+		if !f.commitAnyway {
+			return txID, nil, errors.New("endorsement failed")
+		}
+		f.batches[batchID] = r
+		return txID, nil, errors.New("commit status unavailable")
+	}
+	f.batches[batchID] = r
+	b, _ := json.Marshal(r)
+	return txID, b, nil
+}
+
+func (f *fakeContract) EvaluateTransaction(name string, args ...string) ([]byte, error) {
+	r, ok := f.batches[args[0]]
+	if !ok {
+		return nil, fmt.Errorf("batch %s does not exist", args[0])
+	}
+	return json.Marshal(r)
+}
+
+func testBatches(t *testing.T, maxRows, maxBytes int) []Batch {
+	t.Helper()
+	rows, err := Read(strings.NewReader(bookCSV), "csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	batches, err := Split(rows, "IMP", maxRows, maxBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return batches
+}
+
+func TestReadCSVAndJSON(t *testing.T) {
+	rows, err := Read(strings.NewReader(bookCSV), "csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 5 || rows[1].Description != "repayment, first" || rows[2].Amount != 75000.5 || rows[0].Status != "" {
+		t.Fatalf("unexpected rows %+v", rows)
+	}
+
+	b, _ := json.Marshal(rows)
+	fromJSON, err := Read(strings.NewReader(string(b)), "json")
+	if err != nil || len(fromJSON) != 5 || fromJSON[4] != rows[4] {
+		t.Fatalf("JSON rows %+v, err %v", fromJSON, err)
+	}
+
+	for _, tc := range []struct{ in, format, wantErr string }{
+		{"id,creditorId,debtorId,amount,currency\n", "csv", "column transactionType is required"},
+		{"id,creditorId,debtorId,amount,currency,transactionType,branch\n", "csv", `unknown CSV column "branch"`},
+		{"id,creditorId,debtorId,amount,currency,transactionType\nX,C1,D1,lakh,INR,DEBIT\n", "csv", `line 2: invalid amount "lakh"`},
+		{`[{"id":"X","branch":"Fort"}]`, "json", "unknown field"},
+		{"", "xml", "unknown format"},
+	} {
+		if _, err := Read(strings.NewReader(tc.in), tc.format); err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("Read(%q) error = %v, want %q", tc.in, err, tc.wantErr)
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	byRows := testBatches(t, 2, 1<<20)
+	if len(byRows) != 3 || byRows[0].ID != "IMP-0001" || byRows[2].Rows != 1 {
+		t.Fatalf("unexpected batches %+v", byRows)
+	}
+	for _, b := range byRows {
+		var rows []Row
+		if err := json.Unmarshal(b.Payload, &rows); err != nil || len(rows) != b.Rows || sha256Hex(b.Payload) != b.Hash {
+			t.Fatalf("batch %s payload %s does not match", b.ID, b.Payload)
+		}
+	}
+
+	bySize := testBatches(t, 100, 300)
+	total := 0
+	for _, b := range bySize {
+		if len(b.Payload) > 300 {
+			t.Fatalf("batch %s is %d bytes", b.ID, len(b.Payload))
+		}
+		total += b.Rows
+	}
+	if len(bySize) < 2 || total != 5 {
+		t.Fatalf("unexpected batches %+v", bySize)
+	}
+
+	if _, err := Split([]Row{{ID: strings.Repeat("X", 100)}}, "IMP", 10, 50); err == nil {
+		t.Fatal("oversized row accepted")
+	}
+}
+
+func TestRunResumes(t *testing.T) {
+	batches := testBatches(t, 2, 1<<20)
+	progress := filepath.Join(t.TempDir(), "book.progress.json")
+	cc := &fakeContract{batches: map[string]Result{}}
+	im := &Importer{Contract: &onceFailing{fakeContract: cc, failAt: "IMP-0002"}, ProgressPath: progress, Atomic: true}
+
+	// The second batch fails at endorsement: it is recorded and Run stops
+	p, err := im.Run("src", batches)
+	if err == nil || !strings.Contains(err.Error(), "endorsement failed") {
+		t.Fatalf("err = %v", err)
+	}
+	if p.Batches[0].Status != Committed || p.Batches[1].Status != Failed || p.Batches[2].Status != Pending {
+		t.Fatalf("unexpected progress %+v", p.Batches)
+	}
+
+	// Resuming looks the failed batch up, resubmits it and finishes
+	im.Contract = cc
+	cc.submits = nil
+	p, err = im.Run("src", batches)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(cc.submits, ",") != "IMP-0002,IMP-0003" {
+		t.Fatalf("submitted %v", cc.submits)
+	}
+	if rows, imported := p.Totals(); rows != 5 || imported != 5 {
+		t.Fatalf("totals %d/%d", imported, rows)
+	}
+	saved, err := LoadProgress(progress)
+	if err != nil || saved.Batches[2].Status != Committed || saved.Batches[2].TxID == "" {
+		t.Fatalf("saved progress %+v, err %v", saved, err)
+	}
+
+	// A finished import submits nothing more
+	cc.submits = nil
+	if _, err := im.Run("src", batches); err != nil || len(cc.submits) != 0 {
+		t.Fatalf("rerun submitted %v, err %v", cc.submits, err)
+	}
+}
+
+func TestRunUnknownOutcome(t *testing.T) {
+	batches := testBatches(t, 5, 1<<20)
+	progress := filepath.Join(t.TempDir(), "progress.json")
+	cc := &fakeContract{batches: map[string]Result{}, failNext: true, commitAnyway: true}
+	im := &Importer{Contract: cc, ProgressPath: progress, Atomic: true}
+	if _, err := im.Run("src", batches); err == nil {
+		t.Fatal("lost commit status not reported")
+	}
+
+	// The batch committed although the submit failed, so it is not resent
+	cc.submits = nil
+	p, err := im.Run("src", batches)
+	if err != nil || len(cc.submits) != 0 || p.Batches[0].Status != Committed || p.Batches[0].Imported != 5 {
+		t.Fatalf("progress %+v, submitted %v, err %v", p.Batches, cc.submits, err)
+	}
+}
+
+func TestRunRejectsOtherProgress(t *testing.T) {
+	progress := filepath.Join(t.TempDir(), "progress.json")
+	cc := &fakeContract{batches: map[string]Result{}}
+	im := &Importer{Contract: cc, ProgressPath: progress, Atomic: true}
+	if _, err := im.Run("src", testBatches(t, 5, 1<<20)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := im.Run("other", testBatches(t, 5, 1<<20)); err == nil || !strings.Contains(err.Error(), "does not belong") {
+		t.Fatalf("err = %v", err)
+	}
+	if _, err := im.Run("src", testBatches(t, 2, 1<<20)); err == nil || !strings.Contains(err.Error(), "same batch limits") {
+		t.Fatalf("err = %v", err)
+	}
+
+	// A batch ID already used for other rows is not taken as this batch
+	im.ProgressPath = filepath.Join(t.TempDir(), "progress.json")
+	batches := testBatches(t, 2, 1<<20)
+	cc.batches["IMP-0001"] = Result{BatchID: "IMP-0001", BatchHash: "0000"}
+	p := &Progress{SourceHash: "src", Atomic: true}
+	for _, b := range batches {
+		p.Batches = append(p.Batches, BatchProgress{ID: b.ID, Hash: b.Hash, Rows: b.Rows, Status: Submitted})
+	}
+	if err := p.Save(im.ProgressPath); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := im.Run("src", batches); err == nil || !strings.Contains(err.Error(), "choose another batch prefix") {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+// onceFailing fails the first submit of one batch before it commits
+type onceFailing struct {
+	*fakeContract
+	failAt string
+	failed bool
+}
+
+func (o *onceFailing) SubmitImport(batchID, batchHash string, atomic bool, batch []byte) (string, []byte, error) {
+	if batchID == o.failAt && !o.failed {
+		o.failed = true
+		o.submits = append(o.submits, batchID)
+		return "tx-failed", nil, errors.New("endorsement failed")
+	}
+	return o.fakeContract.SubmitImport(batchID, batchHash, atomic, batch)
+}
@@ -0,0 +1,206 @@
+// Package cic builds the monthly data submission a lending member sends to
+// credit information companies (CICs) from the IU ledger. Credit facilities
+// are derived from a member's disbursements (DEBIT) and repayments (CREDIT)
+// to each borrower, and their RBI asset classification from the borrower's
+// authenticated defaults. Borrower demographics are not on the ledger and
+// come from the member's own party file. Files are written in the consumer
+// TUDF layout or the commercial uniform format, with a validation report of
+// every record that was left out or is incomplete.
+package cic
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// Transaction mirrors the fields of the chaincode's Transaction used here
+type Transaction struct {
+	ID              string    `json:"id"`
+	CreditorID      string    `json:"creditorId"`
+	DebtorID        string    `json:"debtorId"`
+	Amount          float64   `json:"amount"`
+	Currency        string    `json:"currency"`
+	TransactionType string    `json:"transactionType"`
+	Status          string    `json:"status"`
+	Timestamp       time.Time `json:"timestamp"`
+}
+
+// Default mirrors the fields of the chaincode's DefaultRecord used here
+type Default struct {
+	ID          string  `json:"defaultId"`
+	LoanID      string  `json:"loanId"`
+	CreditorID  string  `json:"creditorId"`
+	DebtorID    string  `json:"debtorId"`
+	Amount      float64 `json:"amount"`
+	Currency    string  `json:"currency"`
+	DefaultDate string  `json:"defaultDate"`
+	AuthStatus  string  `json:"authStatus"`
+}
+
+// Asset classifications under the RBI prudential norms (IRACP) and the
+// special mention categories of the early warning framework
+const (
+	Standard    = "STD"
+	SMA0        = "SMA-0"
+	SMA1        = "SMA-1"
+	SMA2        = "SMA-2"
+	Substandard = "SUB"
+	Doubtful    = "DBT"
+)
+
+// Classify returns the asset classification of a facility that is
+// daysPastDue days overdue. An account is an NPA after 90 days overdue,
+// substandard for its first twelve months as an NPA and doubtful after.
+func Classify(daysPastDue int) string {
+	switch {
+	case daysPastDue <= 0:
+		return Standard
+	case daysPastDue <= 30:
+		return SMA0
+	case daysPastDue <= 60:
+		return SMA1
+	case daysPastDue <= 90:
+		return SMA2
+	case daysPastDue <= 90+365:
+		return Substandard
+	}
+	return Doubtful
+}
+
+// Account is one credit facility of a member to a borrower as of a date
+type Account struct {
+	Number         string    `json:"accountNumber"`
+	CreditorID     string    `json:"creditorId"`
+	DebtorID       string    `json:"debtorId"`
+	Currency       string    `json:"currency"`
+	Opened         time.Time `json:"opened"`
+	LastPayment    time.Time `json:"lastPayment"`
+	Closed         time.Time `json:"closed"`
+	Sanctioned     float64   `json:"sanctioned"` // total disbursed
+	Balance        float64   `json:"balance"`
+	Overdue        float64   `json:"overdue"`
+	DaysPastDue    int       `json:"daysPastDue"`
+	Classification string    `json:"classification"`
+	DefaultIDs     []string  `json:"defaultIds"`
+}
+
+// Issue is a problem found while building or validating records. Errors
+// keep the account out of the file; warnings are reported only.
+type Issue struct {
+	Severity string `json:"severity"` // error or warning
+	Account  string `json:"account,omitempty"`
+	Party    string `json:"party,omitempty"`
+	Field    string `json:"field,omitempty"`
+	Message  string `json:"message"`
+}
+
+func issue(severity, account, party, field, format string, args ...interface{}) Issue {
+	return Issue{Severity: severity, Account: account, Party: party, Field: field, Message: fmt.Sprintf(format, args...)}
+}
+
+// AccountNumber is the member's account number of its facility to a
+// borrower. Ledger transactions carry no loan ID, so all of a member's
+// disbursements to one borrower form one facility.
+func AccountNumber(creditorID, debtorID string) string {
+	return creditorID + "-" + debtorID
+}
+
+// BuildAccounts derives the facilities of creditorID as of asOf. Only
+// completed transactions up to asOf count. Defaults count once the
+// borrower has confirmed them or they are deemed authenticated; the
+// overdue amount is their total, capped at the balance, and days past due
+// run from the earliest of them.
+func BuildAccounts(creditorID string, txs []Transaction, defaults []Default, asOf time.Time) ([]Account, []Issue) {
+	var issues []Issue
+	accounts := map[string]*Account{}
+	get := func(debtorID string) *Account {
+		n := AccountNumber(creditorID, debtorID)
+		a, ok := accounts[n]
+		if !ok {
+			a = &Account{Number: n, CreditorID: creditorID, DebtorID: debtorID, DefaultIDs: []string{}}
+			accounts[n] = a
+		}
+		return a
+	}
+
+	sorted := append([]Transaction(nil), txs...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Timestamp.Before(sorted[j].Timestamp) })
+	for _, tx := range sorted {
+		if tx.CreditorID != creditorID || tx.Status != "COMPLETED" || tx.Timestamp.After(asOf) {
+			continue
+		}
+		if tx.TransactionType != "DEBIT" && tx.TransactionType != "CREDIT" {
+			continue
+		}
+		a := get(tx.DebtorID)
+		if a.Currency == "" {
+			a.Currency = tx.Currency
+		}
+		if tx.Currency != a.Currency {
+			issues = append(issues, issue("warning", a.Number, tx.DebtorID, "currency",
+				"transaction %s in %s left out of an account in %s", tx.ID, tx.Currency, a.Currency))
+			continue
+		}
+		switch tx.TransactionType {
+		case "DEBIT":
+			if a.Opened.IsZero() {
+				a.Opened = tx.Timestamp
+			}
+			a.Sanctioned += tx.Amount
+			a.Balance += tx.Amount
+			a.Closed = time.Time{}
+		case "CREDIT":
+			a.LastPayment = tx.Timestamp
+			a.Balance -= tx.Amount
+			if a.Balance <= 0.005 && !a.Opened.IsZero() {
+				a.Balance = 0
+				a.Closed = tx.Timestamp
+			}
+		}
+	}
+
+	for _, d := range defaults {
+		if d.CreditorID != creditorID {
+			continue
+		}
+		a, ok := accounts[AccountNumber(creditorID, d.DebtorID)]
+		if !ok {
+			issues = append(issues, issue("warning", AccountNumber(creditorID, d.DebtorID), d.DebtorID, "",
+				"default %s has no disbursement to %s on the ledger", d.ID, d.DebtorID))
+			continue
+		}
+		if d.AuthStatus != "AUTHENTICATED" && d.AuthStatus != "DEEMED_AUTHENTICATED" {
+			issues = append(issues, issue("warning", a.Number, d.DebtorID, "",
+				"default %s is %s and not reported", d.ID, d.AuthStatus))
+			continue
+		}
+		date, err := time.Parse("2006-01-02", d.DefaultDate)
+		if err != nil || date.After(asOf) {
+			continue
+		}
+		a.Overdue += d.Amount
+		a.DefaultIDs = append(a.DefaultIDs, d.ID)
+		if dpd := int(asOf.Sub(date).Hours() / 24); dpd > a.DaysPastDue {
+			a.DaysPastDue = dpd
+		}
+	}
+
+	var out []Account
+	for _, a := range accounts {
+		if a.Opened.IsZero() {
+			continue
+		}
+		a.Sanctioned = math.Round(a.Sanctioned*100) / 100
+		a.Balance = math.Round(math.Max(a.Balance, 0)*100) / 100
+		a.Overdue = math.Min(a.Overdue, a.Balance)
+		if a.Balance == 0 {
+			a.Overdue, a.DaysPastDue = 0, 0
+		}
+		a.Classification = Classify(a.DaysPastDue)
+		out = append(out, *a)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Number < out[j].Number })
+	return out, issues
+}
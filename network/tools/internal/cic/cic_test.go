@@ -0,0 +1,228 @@
+package cic
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+var asOf = time.Date(2024, 6, 30, 0, 0, 0, 0, time.UTC)
+
+func day(s string) time.Time {
+	t, _ := time.Parse("2006-01-02", s)
+	return t
+}
+
+func ledgerTx(id, debtor, txType string, amount float64, date string) Transaction {
+	return Transaction{ID: id, CreditorID: "C1", DebtorID: debtor, Amount: amount, Currency: "INR", TransactionType: txType, Status: "COMPLETED", Timestamp: day(date)}
+}
+
+var testTxs = []Transaction{
+	ledgerTx("T1", "D1", "DEBIT", 500000, "2023-01-10"),
+	ledgerTx("T2", "D1", "CREDIT", 100000, "2023-06-10"),
+	ledgerTx("T3", "D2", "DEBIT", 200000, "2023-02-01"),
+	ledgerTx("T4", "D2", "CREDIT", 200000, "2024-01-15"),
+	ledgerTx("T5", "D3", "DEBIT", 900000, "2022-05-01"),
+	ledgerTx("T6", "D1", "DEBIT", 50000, "2024-07-15"), // after asOf
+	{ID: "T7", CreditorID: "C1", DebtorID: "D1", Amount: 1, Currency: "INR", TransactionType: "DEBIT", Status: "PENDING", Timestamp: day("2023-03-01")},
+	{ID: "T8", CreditorID: "C2", DebtorID: "D1", Amount: 1, Currency: "INR", TransactionType: "DEBIT", Status: "COMPLETED", Timestamp: day("2023-03-01")},
+}
+
+var testDefaults = []Default{
+	{ID: "DEF1", CreditorID: "C1", DebtorID: "D1", Amount: 40000, Currency: "INR", DefaultDate: "2024-05-16", AuthStatus: "AUTHENTICATED"},
+	{ID: "DEF2", CreditorID: "C1", DebtorID: "D3", Amount: 2000000, Currency: "INR", DefaultDate: "2023-01-01", AuthStatus: "DEEMED_AUTHENTICATED"},
+	{ID: "DEF3", CreditorID: "C1", DebtorID: "D1", Amount: 99999, Currency: "INR", DefaultDate: "2024-06-01", AuthStatus: "DISPUTED"},
+}
+
+func TestClassify(t *testing.T) {
+	for dpd, want := range map[int]string{0: Standard, 1: SMA0, 31: SMA1, 90: SMA2, 91: Substandard, 455: Substandard, 456: Doubtful} {
+		if got := Classify(dpd); got != want {
+			t.Errorf("Classify(%d) = %s, want %s", dpd, got, want)
+		}
+	}
+}
+
+func TestBuildAccounts(t *testing.T) {
+	accounts, issues := BuildAccounts("C1", testTxs, testDefaults, asOf)
+	if len(accounts) != 3 {
+		t.Fatalf("got %d accounts: %+v", len(accounts), accounts)
+	}
+	d1, d2, d3 := accounts[0], accounts[1], accounts[2]
+	if d1.Number != "C1-D1" || d1.Sanctioned != 500000 || d1.Balance != 400000 || d1.Overdue != 40000 ||
+		d1.DaysPastDue != 45 || d1.Classification != SMA1 || !d1.LastPayment.Equal(day("2023-06-10")) {
+		t.Fatalf("unexpected D1 account %+v", d1)
+	}
+	if d2.Balance != 0 || !d2.Closed.Equal(day("2024-01-15")) || d2.Classification != Standard {
+		t.Fatalf("unexpected D2 account %+v", d2)
+	}
+	// The overdue amount cannot exceed the balance
+	if d3.Overdue != 900000 || d3.DaysPastDue != 546 || d3.Classification != Doubtful {
+		t.Fatalf("unexpected D3 account %+v", d3)
+	}
+	if len(issues) != 1 || !strings.Contains(issues[0].Message, "DEF3 is DISPUTED") {
+		t.Fatalf("issues = %+v", issues)
+	}
+}
+
+var testParties = map[string]Party{
+	"D1": {ID: "D1", Kind: Consumer, Name: "Asha Rao", DateOfBirth: "1985-04-12", Gender: "F", PAN: "ABCPR1234K", Phone: "9820012345", Address: "12 Hill Road, Bandra", StateCode: "27", Pincode: "400050"},
+	"D2": {ID: "D2", Kind: Consumer, Name: "Vikram Shah", Address: "4 MG Road", StateCode: "29", Pincode: "560001"},
+	"D3": {ID: "D3", Kind: Commercial, Name: "Shah Textiles Pvt Ltd", PAN: "AAECS1234F", CIN: "U17110MH2010PTC123456", Constitution: "11", Address: "Plot 7, MIDC Bhiwandi", StateCode: "27", Pincode: "421302"},
+}
+
+func TestExportConsumer(t *testing.T) {
+	accounts, _ := BuildAccounts("C1", testTxs, testDefaults, asOf)
+	file, report, err := Export(Consumer, Member{Code: "BANK000001", ShortName: "CREDITORBANK"}, asOf, accounts, testParties)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(file), "\n"), "\n")
+	if len(lines) != 3 || lines[0] != "TUDF12BANK000001CREDITORBANK      30062024" || lines[2] != "TRLR" {
+		t.Fatalf("unexpected file:\n%s", file)
+	}
+	for _, want := range []string{
+		"PN03N010108ASHA RAO07081204198508011",
+		"ID03I010102010210ABCPR1234K",
+		"TL04T0010110BANK0000010212CREDITORBANK0305C1-D1040205",
+		"050110808100120230908100620231108300620241206500000130640000014054000015030452602",
+		"ES02**",
+	} {
+		if !strings.Contains(lines[1], want) {
+			t.Errorf("record lacks %q:\n%s", want, lines[1])
+		}
+	}
+
+	// D2 has neither date of birth nor PAN; D3 is commercial
+	if report.Accounts != 1 || report.Rejected != 1 || report.Skipped != 1 || report.Errors() != 1 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	if i := report.Issues[0]; i.Account != "C1-D2" || i.Field != "dateOfBirth" {
+		t.Fatalf("unexpected issue %+v", i)
+	}
+	sum := sha256.Sum256(file)
+	if report.FileHash != hex.EncodeToString(sum[:]) {
+		t.Fatalf("file hash %s does not match", report.FileHash)
+	}
+}
+
+func TestExportCommercial(t *testing.T) {
+	accounts, _ := BuildAccounts("C1", testTxs, testDefaults, asOf)
+	file, report, err := Export(Commercial, Member{Code: "BANK000001", ShortName: "CREDITORBANK", AccountType: "0100"}, asOf, accounts, testParties)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `HD|BANK000001|CREDITORBANK|30062024|1.0
+BS|BANK000001|D3|SHAH TEXTILES PVT LTD|11|AAECS1234F|U17110MH2010PTC123456||
+AS|PLOT 7, MIDC BHIWANDI|27|421302
+CR|C1-D3|0100|01052022|900000|900000|900000|546|03||
+TS|1|1
+`
+	if string(file) != want {
+		t.Fatalf("file:\n%s\nwant:\n%s", file, want)
+	}
+	if report.Accounts != 1 || report.Skipped != 2 || report.Rejected != 0 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+}
+
+func TestExportRejects(t *testing.T) {
+	accounts := []Account{
+		{Number: "C1-X", DebtorID: "X", Currency: "INR", Opened: day("2023-01-01")},
+		{Number: "C1-Y", DebtorID: "Y", Currency: "USD", Opened: day("2023-01-01")},
+	}
+	parties := map[string]Party{
+		"Y": {ID: "Y", Kind: Consumer, Name: "Y", PAN: "BADPAN", Address: "a", StateCode: "MH", Pincode: "4000"},
+	}
+	_, report, err := Export(Consumer, Member{Code: "M", ShortName: "M"}, asOf, accounts, parties)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := map[string]bool{}
+	for _, i := range report.Issues {
+		fields[i.Field] = true
+	}
+	for _, f := range []string{"pan", "stateCode", "pincode", "currency"} {
+		if !fields[f] {
+			t.Errorf("no issue for %s: %+v", f, report.Issues)
+		}
+	}
+	if report.Accounts != 0 || report.Rejected != 2 || !strings.Contains(report.Issues[0].Message, "no party record for X") {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	if _, _, err := Export("tudf", Member{Code: "M", ShortName: "M"}, asOf, nil, nil); err == nil {
+		t.Fatal("unknown format accepted")
+	}
+}
+
+// fakeLedger answers the queries ReadLedger makes and records submits
+type fakeLedger struct {
+	calls   []string
+	submits [][]string
+}
+
+func (f *fakeLedger) EvaluateTransaction(name string, args ...string) ([]byte, error) {
+	f.calls = append(f.calls, name+" "+strings.Join(args, ","))
+	switch name {
+	case "QueryTransactions":
+		// A full page of repeats, then the test transactions
+		if args[1] == "" {
+			page := make([]Transaction, transactionPageSize)
+			for i := range page {
+				page[i] = testTxs[i%len(testTxs)]
+			}
+			return json.Marshal(map[string]interface{}{"transactions": page, "bookmark": "t1"})
+		}
+		return json.Marshal(map[string]interface{}{"transactions": testTxs, "bookmark": "t2"})
+	case "QueryAuditRecords":
+		// Two full pages, the second of which repeats a default, then an empty one
+		if args[5] == "" || args[5] == "p1" {
+			records := make([]map[string]string, auditPageSize)
+			for i := range records {
+				records[i] = map[string]string{"transactionId": testDefaults[i%2].ID}
+			}
+			next := map[string]string{"": "p1", "p1": "p2"}[args[5]]
+			return json.Marshal(map[string]interface{}{"records": records, "bookmark": next})
+		}
+		return []byte(`{"records":[],"bookmark":"p3"}`), nil
+	case "GetDefault":
+		for _, d := range testDefaults {
+			if d.ID == args[0] {
+				return json.Marshal(d)
+			}
+		}
+	}
+	return nil, fmt.Errorf("unexpected %s", name)
+}
+
+func (f *fakeLedger) SubmitTransaction(name string, args ...string) ([]byte, error) {
+	f.submits = append(f.submits, append([]string{name}, args...))
+	return nil, nil
+}
+
+func TestReadLedgerAndAnchor(t *testing.T) {
+	f := &fakeLedger{}
+	txs, defaults, err := ReadLedger(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(txs) != transactionPageSize+len(testTxs) || len(defaults) != 2 || defaults[0].ID != "DEF1" || defaults[1].ID != "DEF2" {
+		t.Fatalf("read %d transactions and defaults %+v", len(txs), defaults)
+	}
+	if len(f.calls) != 7 || f.calls[0] != "QueryTransactions 500," || f.calls[1] != "QueryTransactions 500,t1" || f.calls[2] != "QueryAuditRecords ,FILE_DEFAULT,,,200," {
+		t.Fatalf("calls %q", f.calls)
+	}
+
+	report := &Report{Format: Consumer, Member: "BANK000001", AsOf: "2024-06-30", Accounts: 2, FileHash: "ab12"}
+	ref, err := Anchor(f, report, "cic.txt", "cd34")
+	if err != nil || ref != "CIC_BANK000001_consumer_20240630" {
+		t.Fatalf("ref %s, err %v", ref, err)
+	}
+	got := f.submits[0]
+	if got[0] != "RecordAuditEvent" || got[1] != AnchorEvent || got[2] != ref || got[3] != "ab12" || !strings.Contains(got[4], "report sha256 cd34") || got[5] != "" {
+		t.Fatalf("submitted %q", got)
+	}
+}
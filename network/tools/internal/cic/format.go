@@ -0,0 +1,350 @@
+package cic
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Party is what the member knows of a borrower. Consumer borrowers need a
+// name, a date of birth or PAN, and an address with state and PIN code;
+// commercial borrowers need a name, PAN and constitution.
+type Party struct {
+	ID           string `json:"id"`   // debtorId on the ledger
+	Kind         string `json:"kind"` // consumer (default) or commercial
+	Name         string `json:"name"`
+	DateOfBirth  string `json:"dateOfBirth,omitempty"` // YYYY-MM-DD
+	Gender       string `json:"gender,omitempty"`      // F, M or T
+	PAN          string `json:"pan,omitempty"`
+	CIN          string `json:"cin,omitempty"`
+	Constitution string `json:"constitution,omitempty"` // commercial only, e.g. 11 for a private limited company
+	Phone        string `json:"phone,omitempty"`
+	Email        string `json:"email,omitempty"`
+	Address      string `json:"address"`
+	StateCode    string `json:"stateCode"` // two-digit state code, e.g. 27 for Maharashtra
+	Pincode      string `json:"pincode"`
+}
+
+// LoadParties reads a JSON array of Party keyed by ID
+func LoadParties(path string) (map[string]Party, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var list []Party
+	if err := json.Unmarshal(b, &list); err != nil {
+		return nil, fmt.Errorf("invalid party file %s: %v", path, err)
+	}
+	parties := map[string]Party{}
+	for _, p := range list {
+		if p.Kind == "" {
+			p.Kind = Consumer
+		}
+		parties[p.ID] = p
+	}
+	return parties, nil
+}
+
+// Member identifies the reporting lender to the CIC
+type Member struct {
+	Code        string `json:"code"`
+	ShortName   string `json:"shortName"`
+	AccountType string `json:"accountType"` // CIC account type code of the facilities
+}
+
+// File formats
+const (
+	Consumer   = "consumer"
+	Commercial = "commercial"
+)
+
+// Report describes an export: what went into the file, what was left out
+// and why, and the file's SHA-256
+type Report struct {
+	Format   string  `json:"format"`
+	Member   string  `json:"member"`
+	AsOf     string  `json:"asOf"`
+	Accounts int     `json:"accounts"`
+	Rejected int     `json:"rejected"`
+	Skipped  int     `json:"skipped"` // borrowers of the other format
+	Issues   []Issue `json:"issues"`
+	FileHash string  `json:"fileHash"`
+}
+
+// Errors counts the issues of error severity
+func (r *Report) Errors() int {
+	n := 0
+	for _, i := range r.Issues {
+		if i.Severity == "error" {
+			n++
+		}
+	}
+	return n
+}
+
+var (
+	panPattern     = regexp.MustCompile(`^[A-Z]{5}[0-9]{4}[A-Z]$`)
+	pincodePattern = regexp.MustCompile(`^[1-9][0-9]{5}$`)
+	statePattern   = regexp.MustCompile(`^[0-9]{2}$`)
+)
+
+// validate returns the issues of reporting a as party p in format
+func validate(format string, a Account, p Party) []Issue {
+	var issues []Issue
+	bad := func(field, msg string, args ...interface{}) {
+		issues = append(issues, issue("error", a.Number, p.ID, field, msg, args...))
+	}
+	warn := func(field, msg string, args ...interface{}) {
+		issues = append(issues, issue("warning", a.Number, p.ID, field, msg, args...))
+	}
+
+	if strings.TrimSpace(p.Name) == "" {
+		bad("name", "borrower name is required")
+	}
+	if p.PAN != "" && !panPattern.MatchString(p.PAN) {
+		bad("pan", "PAN %q is not of the form AAAAA9999A", p.PAN)
+	}
+	if p.Address == "" {
+		bad("address", "address is required")
+	}
+	if !statePattern.MatchString(p.StateCode) {
+		bad("stateCode", "state code %q is not two digits", p.StateCode)
+	}
+	if !pincodePattern.MatchString(p.Pincode) {
+		bad("pincode", "PIN code %q is not six digits", p.Pincode)
+	}
+	switch format {
+	case Consumer:
+		if p.DateOfBirth == "" && p.PAN == "" {
+			bad("dateOfBirth", "date of birth or PAN is required")
+		}
+		if p.DateOfBirth != "" {
+			if _, err := time.Parse("2006-01-02", p.DateOfBirth); err != nil {
+				bad("dateOfBirth", "date of birth %q is not YYYY-MM-DD", p.DateOfBirth)
+			}
+		}
+		if p.Gender != "" && genderCode(p.Gender) == "" {
+			bad("gender", "gender %q is not F, M or T", p.Gender)
+		}
+		if p.Phone == "" && p.Email == "" {
+			warn("phone", "no phone number or email")
+		}
+	case Commercial:
+		if p.PAN == "" {
+			bad("pan", "PAN is required for a commercial borrower")
+		}
+		if p.Constitution == "" {
+			bad("constitution", "constitution is required for a commercial borrower")
+		}
+		if p.CIN == "" {
+			warn("cin", "no CIN")
+		}
+	}
+	if a.Currency != "INR" {
+		bad("currency", "amounts are in %s; CIC files are in INR", a.Currency)
+	}
+	return issues
+}
+
+// Export writes the accounts of borrowers of format as a CIC file and
+// reports every account left out. Accounts of borrowers without a party
+// record, or whose records fail validation, are rejected.
+func Export(format string, member Member, asOf time.Time, accounts []Account, parties map[string]Party) ([]byte, *Report, error) {
+	var w writer
+	switch format {
+	case Consumer:
+		w = &tudfWriter{}
+	case Commercial:
+		w = &commercialWriter{}
+	default:
+		return nil, nil, fmt.Errorf("unknown format %q (want %s or %s)", format, Consumer, Commercial)
+	}
+	if member.Code == "" || member.ShortName == "" {
+		return nil, nil, fmt.Errorf("member code and short name are required")
+	}
+	if member.AccountType == "" {
+		member.AccountType = "05"
+	}
+	report := &Report{Format: format, Member: member.Code, AsOf: asOf.Format("2006-01-02"), Issues: []Issue{}}
+
+	var buf bytes.Buffer
+	w.header(&buf, member, asOf)
+	for _, a := range accounts {
+		p, ok := parties[a.DebtorID]
+		if !ok {
+			report.Rejected++
+			report.Issues = append(report.Issues, issue("error", a.Number, a.DebtorID, "", "no party record for %s", a.DebtorID))
+			continue
+		}
+		if p.Kind != format {
+			report.Skipped++
+			continue
+		}
+		issues := validate(format, a, p)
+		report.Issues = append(report.Issues, issues...)
+		rejected := false
+		for _, i := range issues {
+			rejected = rejected || i.Severity == "error"
+		}
+		if rejected {
+			report.Rejected++
+			continue
+		}
+		w.record(&buf, member, asOf, a, p)
+		report.Accounts++
+	}
+	w.trailer(&buf, report.Accounts)
+
+	sum := sha256.Sum256(buf.Bytes())
+	report.FileHash = hex.EncodeToString(sum[:])
+	return buf.Bytes(), report, nil
+}
+
+type writer interface {
+	header(buf *bytes.Buffer, m Member, asOf time.Time)
+	record(buf *bytes.Buffer, m Member, asOf time.Time, a Account, p Party)
+	trailer(buf *bytes.Buffer, records int)
+}
+
+func cicDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format("02012006")
+}
+
+func isoToCICDate(s string) string {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return ""
+	}
+	return cicDate(t)
+}
+
+// rupees formats an amount in whole rupees, as CIC files carry them
+func rupees(v float64) string {
+	return fmt.Sprintf("%.0f", math.Round(v))
+}
+
+func genderCode(g string) string {
+	return map[string]string{"F": "1", "M": "2", "T": "3"}[strings.ToUpper(g)]
+}
+
+// assetCode maps a classification to the CIC asset classification code;
+// special mention accounts are standard assets reported as SMA
+var assetCode = map[string]string{
+	Standard:    "01",
+	Substandard: "02",
+	Doubtful:    "03",
+	SMA0:        "05",
+	SMA1:        "05",
+	SMA2:        "05",
+}
+
+// tudfWriter writes the consumer TUDF layout. Each segment starts with its
+// tag and is followed by fields of a two-digit field tag, a two-digit
+// length and the value; empty fields are omitted and longer values cut
+// to 99 bytes. A record ends with the
+// ES segment and is written on its own line.
+type tudfWriter struct{}
+
+func tlv(buf *bytes.Buffer, segment string, fields ...string) {
+	buf.WriteString(segment)
+	for i := 0; i+1 < len(fields); i += 2 {
+		if v := fields[i+1]; v != "" {
+			if len(v) > 99 {
+				v = v[:99]
+			}
+			fmt.Fprintf(buf, "%s%02d%s", fields[i], len(v), v)
+		}
+	}
+}
+
+func (tudfWriter) header(buf *bytes.Buffer, m Member, asOf time.Time) {
+	// TUDF, version 12, member code (10), short name (16), cycle (2), date reported
+	fmt.Fprintf(buf, "TUDF12%-10.10s%-16.16s%-2s%s\n", m.Code, m.ShortName, "", cicDate(asOf))
+}
+
+func (tudfWriter) record(buf *bytes.Buffer, m Member, asOf time.Time, a Account, p Party) {
+	tlv(buf, "PN03N01", "01", strings.ToUpper(p.Name), "07", isoToCICDate(p.DateOfBirth), "08", genderCode(p.Gender))
+	if p.PAN != "" {
+		tlv(buf, "ID03I01", "01", "01", "02", p.PAN)
+	}
+	if p.Phone != "" {
+		tlv(buf, "PT03T01", "01", p.Phone, "03", "01")
+	}
+	if p.Email != "" {
+		tlv(buf, "EC03C01", "01", p.Email)
+	}
+	tlv(buf, "PA03A01", "01", strings.ToUpper(p.Address), "06", p.StateCode, "07", p.Pincode, "08", "02")
+	tlv(buf, "TL04T001",
+		"01", m.Code,
+		"02", m.ShortName,
+		"03", a.Number,
+		"04", m.AccountType,
+		"05", "1", // individual ownership
+		"08", cicDate(a.Opened),
+		"09", cicDate(a.LastPayment),
+		"10", cicDate(a.Closed),
+		"11", cicDate(asOf),
+		"12", rupees(a.Sanctioned),
+		"13", rupees(a.Balance),
+		"14", overdueField(a),
+		"15", dpdField(a),
+		"26", assetCode[a.Classification],
+	)
+	buf.WriteString("ES02**\n")
+}
+
+func (tudfWriter) trailer(buf *bytes.Buffer, records int) {
+	buf.WriteString("TRLR\n")
+}
+
+func overdueField(a Account) string {
+	if a.Overdue == 0 {
+		return ""
+	}
+	return rupees(a.Overdue)
+}
+
+func dpdField(a Account) string {
+	return fmt.Sprintf("%03d", min(a.DaysPastDue, 999))
+}
+
+// commercialWriter writes the commercial uniform format: one pipe-separated
+// line per segment. Each borrower (BS) is followed by its address (AS) and
+// credit facility (CR); the trailer (TS) counts borrower and facility
+// segments.
+type commercialWriter struct{}
+
+func pipe(buf *bytes.Buffer, fields ...string) {
+	for i, f := range fields {
+		if i > 0 {
+			buf.WriteByte('|')
+		}
+		buf.WriteString(strings.ReplaceAll(f, "|", " "))
+	}
+	buf.WriteByte('\n')
+}
+
+func (commercialWriter) header(buf *bytes.Buffer, m Member, asOf time.Time) {
+	pipe(buf, "HD", m.Code, m.ShortName, cicDate(asOf), "1.0")
+}
+
+func (commercialWriter) record(buf *bytes.Buffer, m Member, asOf time.Time, a Account, p Party) {
+	pipe(buf, "BS", m.Code, a.DebtorID, strings.ToUpper(p.Name), p.Constitution, p.PAN, p.CIN, p.Phone, p.Email)
+	pipe(buf, "AS", strings.ToUpper(p.Address), p.StateCode, p.Pincode)
+	pipe(buf, "CR", a.Number, m.AccountType, cicDate(a.Opened), rupees(a.Sanctioned), rupees(a.Balance),
+		rupees(a.Overdue), dpdField(a), assetCode[a.Classification], cicDate(a.LastPayment), cicDate(a.Closed))
+}
+
+func (commercialWriter) trailer(buf *bytes.Buffer, records int) {
+	pipe(buf, "TS", fmt.Sprint(records), fmt.Sprint(records))
+}
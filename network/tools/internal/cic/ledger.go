@@ -0,0 +1,110 @@
+package cic
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Evaluator evaluates queries against the financial channel contract
+type Evaluator interface {
+	EvaluateTransaction(name string, args ...string) ([]byte, error)
+}
+
+// auditPageSize is the largest page QueryAuditRecords returns
+const auditPageSize = 200
+
+// transactionPageSize is the largest page QueryTransactions returns
+const transactionPageSize = 500
+
+// ReadLedger reads every transaction, a page at a time, and every filed
+// default. Defaults are found through their FILE_DEFAULT audit records,
+// since the contract has no query over defaults.
+func ReadLedger(e Evaluator) ([]Transaction, []Default, error) {
+	var txs []Transaction
+	bookmark := ""
+	for {
+		b, err := e.EvaluateTransaction("QueryTransactions", strconv.Itoa(transactionPageSize), bookmark)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read transactions: %w", err)
+		}
+		var page struct {
+			Transactions []Transaction `json:"transactions"`
+			Bookmark     string        `json:"bookmark"`
+		}
+		if err := json.Unmarshal(b, &page); err != nil {
+			return nil, nil, fmt.Errorf("invalid transactions: %v", err)
+		}
+		txs = append(txs, page.Transactions...)
+		if len(page.Transactions) < transactionPageSize || page.Bookmark == "" {
+			break
+		}
+		bookmark = page.Bookmark
+	}
+
+	var defaults []Default
+	seen := map[string]bool{}
+	bookmark = ""
+	for {
+		b, err := e.EvaluateTransaction("QueryAuditRecords", "", "FILE_DEFAULT", "", "", strconv.Itoa(auditPageSize), bookmark)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to query default filings: %w", err)
+		}
+		var page struct {
+			Records []struct {
+				TransactionID string `json:"transactionId"`
+			} `json:"records"`
+			Bookmark string `json:"bookmark"`
+		}
+		if err := json.Unmarshal(b, &page); err != nil {
+			return nil, nil, fmt.Errorf("invalid audit records: %v", err)
+		}
+		for _, r := range page.Records {
+			if seen[r.TransactionID] {
+				continue
+			}
+			seen[r.TransactionID] = true
+			b, err := e.EvaluateTransaction("GetDefault", r.TransactionID)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to read default %s: %w", r.TransactionID, err)
+			}
+			var d Default
+			if err := json.Unmarshal(b, &d); err != nil {
+				return nil, nil, fmt.Errorf("invalid default %s: %v", r.TransactionID, err)
+			}
+			defaults = append(defaults, d)
+		}
+		if len(page.Records) < auditPageSize || page.Bookmark == "" {
+			return txs, defaults, nil
+		}
+		bookmark = page.Bookmark
+	}
+}
+
+// Submitter submits transactions to the contract the file hash is anchored on
+type Submitter interface {
+	SubmitTransaction(name string, args ...string) ([]byte, error)
+}
+
+// AnchorEvent is the audit event type of an anchored CIC file
+const AnchorEvent = "CIC_EXPORT"
+
+// AnchorRef is the audit reference of a member's file in a format as of a
+// date, e.g. CIC_BANK000001_consumer_20240630
+func AnchorRef(r *Report) string {
+	return fmt.Sprintf("CIC_%s_%s_%s", r.Member, r.Format, strings.ReplaceAll(r.AsOf, "-", ""))
+}
+
+// Anchor records the file hash through RecordAuditEvent so a CIC can check
+// a submitted file against the ledger. The details carry the file name,
+// record counts and the hash of the validation report.
+func Anchor(s Submitter, r *Report, fileName string, reportHash string) (string, error) {
+	ref := AnchorRef(r)
+	details := fmt.Sprintf("%s file %s as of %s: %d accounts, %d rejected; report sha256 %s",
+		r.Format, fileName, r.AsOf, r.Accounts, r.Rejected, reportHash)
+	if _, err := s.SubmitTransaction("RecordAuditEvent", AnchorEvent, ref, r.FileHash, details, "", "0"); err != nil {
+		return "", fmt.Errorf("failed to anchor %s: %w", ref, err)
+	}
+	return ref, nil
+}
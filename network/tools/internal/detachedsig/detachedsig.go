@@ -0,0 +1,231 @@
+// Package detachedsig produces and checks detached ECDSA signatures over
+// canonical JSON documents using a Fabric MSP signing identity. The
+// signature covers the document's digest together with its type, the
+// signer's MSP ID and the signing time, so none of them can be changed in
+// the signature file without invalidating it.
+package detachedsig
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Algorithm is the only signature scheme produced by this package
+const Algorithm = "ECDSA-SHA256"
+
+// Signature is the detached signature file written next to a signed document
+type Signature struct {
+	DocumentType      string    `json:"documentType"`
+	Algorithm         string    `json:"algorithm"`
+	Digest            string    `json:"digest"`
+	Signature         string    `json:"signature"`
+	SignerMSPID       string    `json:"signerMspId"`
+	SignerCertificate string    `json:"signerCertificate"`
+	SignedAt          time.Time `json:"signedAt"`
+}
+
+// signedFields is the part of a Signature that the signature covers, in the
+// order it is serialized
+type signedFields struct {
+	DocumentType string    `json:"documentType"`
+	Algorithm    string    `json:"algorithm"`
+	Digest       string    `json:"digest"`
+	SignerMSPID  string    `json:"signerMspId"`
+	SignedAt     time.Time `json:"signedAt"`
+}
+
+// signedDigest returns the SHA-256 of the fields of sig that are signed
+func signedDigest(sig *Signature) ([]byte, error) {
+	b, err := json.Marshal(signedFields{
+		DocumentType: sig.DocumentType,
+		Algorithm:    sig.Algorithm,
+		Digest:       sig.Digest,
+		SignerMSPID:  sig.SignerMSPID,
+		SignedAt:     sig.SignedAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(b)
+	return sum[:], nil
+}
+
+// Roots are the CA certificates of one MSP. A signer certificate that
+// chains to them belongs to that MSP, whatever the signature file claims.
+type Roots struct {
+	MSPID string
+	Pool  *x509.CertPool
+}
+
+// Signer holds an MSP signing key and its certificate
+type Signer struct {
+	MSPID   string
+	Key     *ecdsa.PrivateKey
+	CertPEM []byte
+}
+
+// LoadSigner reads an MSP keystore key and signcert from disk
+func LoadSigner(mspID, keyPath, certPath string) (*Signer, error) {
+	keyPEM, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key: %v", err)
+	}
+	certPEM, err := os.ReadFile(certPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate: %v", err)
+	}
+	key, err := parsePrivateKey(keyPEM)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := parseCertificate(certPEM)
+	if err != nil {
+		return nil, err
+	}
+	pub, ok := cert.PublicKey.(*ecdsa.PublicKey)
+	if !ok || !pub.Equal(&key.PublicKey) {
+		return nil, fmt.Errorf("certificate does not match private key")
+	}
+	return &Signer{MSPID: mspID, Key: key, CertPEM: certPEM}, nil
+}
+
+func parsePrivateKey(keyPEM []byte) (*ecdsa.PrivateKey, error) {
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block in private key")
+	}
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		ecKey, ok := key.(*ecdsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("private key is not ECDSA")
+		}
+		return ecKey, nil
+	}
+	key, err := x509.ParseECPrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %v", err)
+	}
+	return key, nil
+}
+
+func parseCertificate(certPEM []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block in certificate")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate: %v", err)
+	}
+	return cert, nil
+}
+
+// Canonicalize compacts a JSON document so insignificant whitespace does not change the digest
+func Canonicalize(doc []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, bytes.TrimSpace(doc)); err != nil {
+		return nil, fmt.Errorf("document is not valid JSON: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Sign produces a detached signature over the canonical form of doc
+func (s *Signer) Sign(documentType string, doc []byte, signedAt time.Time) (*Signature, error) {
+	canonical, err := Canonicalize(doc)
+	if err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256(canonical)
+	result := &Signature{
+		DocumentType:      documentType,
+		Algorithm:         Algorithm,
+		Digest:            hex.EncodeToString(digest[:]),
+		SignerMSPID:       s.MSPID,
+		SignerCertificate: string(s.CertPEM),
+		SignedAt:          signedAt.UTC(),
+	}
+	signed, err := signedDigest(result)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := ecdsa.SignASN1(rand.Reader, s.Key, signed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign: %v", err)
+	}
+	result.Signature = base64.StdEncoding.EncodeToString(sig)
+	return result, nil
+}
+
+// Verify checks sig against doc and the signer certificate's chain to roots.
+// The certificate travels inside the signature, so without a trust anchor
+// anyone could sign; roots is required. The signer's MSP is that of the
+// roots, and a signature claiming any other MSP is rejected, so once Verify
+// succeeds every field of sig can be trusted.
+func Verify(doc []byte, sig *Signature, roots *Roots) error {
+	if roots == nil {
+		return fmt.Errorf("a trust anchor is required to verify a signature")
+	}
+	if sig.Algorithm != Algorithm {
+		return fmt.Errorf("unsupported algorithm %q", sig.Algorithm)
+	}
+	canonical, err := Canonicalize(doc)
+	if err != nil {
+		return err
+	}
+	digest := sha256.Sum256(canonical)
+	if hex.EncodeToString(digest[:]) != sig.Digest {
+		return fmt.Errorf("digest mismatch: document was altered")
+	}
+	cert, err := parseCertificate([]byte(sig.SignerCertificate))
+	if err != nil {
+		return err
+	}
+	opts := x509.VerifyOptions{Roots: roots.Pool, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}}
+	if _, err := cert.Verify(opts); err != nil {
+		return fmt.Errorf("signer certificate not trusted: %v", err)
+	}
+	if sig.SignerMSPID != roots.MSPID {
+		return fmt.Errorf("signature claims %s, but its certificate was issued by the %s CA", sig.SignerMSPID, roots.MSPID)
+	}
+	pub, ok := cert.PublicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("signer certificate does not carry an ECDSA key")
+	}
+	raw, err := base64.StdEncoding.DecodeString(sig.Signature)
+	if err != nil {
+		return fmt.Errorf("invalid signature encoding: %v", err)
+	}
+	signed, err := signedDigest(sig)
+	if err != nil {
+		return err
+	}
+	if !ecdsa.VerifyASN1(pub, signed, raw) {
+		return fmt.Errorf("signature verification failed")
+	}
+	return nil
+}
+
+// LoadRoots reads the PEM CA certificates of mspID
+func LoadRoots(mspID string, paths ...string) (*Roots, error) {
+	pool := x509.NewCertPool()
+	for _, p := range paths {
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA certificate: %v", err)
+		}
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("no certificates found in %s", p)
+		}
+	}
+	return &Roots{MSPID: mspID, Pool: pool}, nil
+}
@@ -0,0 +1,107 @@
+package detachedsig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+// issue returns a CA and a signer whose certificate it issued
+func issue(t *testing.T, mspID string) (*Roots, *Signer) {
+	t.Helper()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ca." + mspID},
+		NotBefore:             time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:              time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, _ := x509.ParseCertificate(caDER)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "Admin@" + mspID},
+		NotBefore:    time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:     time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := &Roots{MSPID: mspID, Pool: x509.NewCertPool()}
+	roots.Pool.AddCert(ca)
+	return roots, &Signer{MSPID: mspID, Key: key, CertPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+func TestVerify(t *testing.T) {
+	roots, signer := issue(t, "AdminMSP")
+	otherRoots, forger := issue(t, "AdminMSP")
+	doc := []byte(`{"defaultId": "DEF1", "amount": 250000}`)
+	sig, err := signer.Sign("IU_RECORD_OF_DEFAULT", doc, time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged, err := forger.Sign("IU_RECORD_OF_DEFAULT", doc, time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A key issued by the AdminMSP CA claiming to sign for another org
+	misnamed, err := (&Signer{MSPID: "CreditorMSP", Key: signer.Key, CertPEM: signer.CertPEM}).Sign("IU_RECORD_OF_DEFAULT", doc, time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	edited := func(edit func(s *Signature)) *Signature {
+		s := *sig
+		edit(&s)
+		return &s
+	}
+
+	tests := []struct {
+		name    string
+		doc     string
+		sig     *Signature
+		roots   *Roots
+		wantErr string
+	}{
+		{"valid", `{"defaultId":"DEF1","amount":250000}`, sig, roots, ""},
+		{"no trust anchor", string(doc), sig, nil, "a trust anchor is required"},
+		{"self-issued signer", string(doc), forged, roots, "signer certificate not trusted"},
+		{"forger's own CA", string(doc), forged, otherRoots, ""},
+		{"altered document", `{"defaultId":"DEF1","amount":1}`, sig, roots, "digest mismatch"},
+		{"altered document type", string(doc), edited(func(s *Signature) { s.DocumentType = "IU_REGULATORY_RETURN" }), roots, "signature verification failed"},
+		{"altered signing time", string(doc), edited(func(s *Signature) { s.SignedAt = s.SignedAt.Add(time.Hour) }), roots, "signature verification failed"},
+		{"altered signer MSP", string(doc), edited(func(s *Signature) { s.SignerMSPID = "CreditorMSP" }), roots, "issued by the AdminMSP CA"},
+		{"signed for another MSP", string(doc), misnamed, roots, "issued by the AdminMSP CA"},
+	}
+	for _, tt := range tests {
+		err := Verify([]byte(tt.doc), tt.sig, tt.roots)
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("%s: %v", tt.name, err)
+		case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+			t.Errorf("%s: error %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
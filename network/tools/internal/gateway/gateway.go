@@ -0,0 +1,217 @@
+// Package gateway connects the off-chain tools to the IU network through the
+// Fabric Gateway SDK using an org's MSP identity from the crypto material
+// under network/organizations.
+package gateway
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+	"github.com/hyperledger/fabric-gateway/pkg/identity"
+	"github.com/hyperledger/fabric-protos-go-apiv2/common"
+	gatewaypb "github.com/hyperledger/fabric-protos-go-apiv2/gateway"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// Channel and chaincode names used by the IU network
+const (
+	FinancialChannel = "financial-operations-channel"
+	AuditChannel     = "audit-compliance-channel"
+	ChaincodeName    = "iu-chaincode"
+)
+
+// Profile describes how an org's client identity reaches its gateway peer
+type Profile struct {
+	MSPID            string `json:"mspId"`
+	PeerEndpoint     string `json:"peerEndpoint"`
+	PeerHostOverride string `json:"peerHostOverride"`
+	TLSCACertPath    string `json:"tlsCaCertPath"`
+	CertPath         string `json:"certPath"`
+	KeyPath          string `json:"keyPath"` // key file or keystore directory
+}
+
+// DefaultProfile returns the Admin user profile of org (creditor, debtor or admin)
+// for the local network, with paths relative to orgsDir.
+func DefaultProfile(org, orgsDir string) (Profile, error) {
+	ports := map[string]int{"creditor": 7051, "debtor": 8051, "admin": 9051}
+	port, ok := ports[org]
+	if !ok {
+		return Profile{}, fmt.Errorf("unknown org %q (want creditor, debtor or admin)", org)
+	}
+	domain := org + ".iu-network.com"
+	mspID := map[string]string{"creditor": "CreditorMSP", "debtor": "DebtorMSP", "admin": "AdminMSP"}[org]
+	orgDir := filepath.Join(orgsDir, "peerOrganizations", domain)
+	userMSP := filepath.Join(orgDir, "users", "Admin@"+domain, "msp")
+	return Profile{
+		MSPID:            mspID,
+		PeerEndpoint:     fmt.Sprintf("localhost:%d", port),
+		PeerHostOverride: "peer0." + domain,
+		TLSCACertPath:    filepath.Join(orgDir, "peers", "peer0."+domain, "tls", "ca.crt"),
+		CertPath:         filepath.Join(userMSP, "signcerts", "Admin@"+domain+"-cert.pem"),
+		KeyPath:          filepath.Join(userMSP, "keystore"),
+	}, nil
+}
+
+// LoadProfiles reads named profiles from a JSON file of {"name": Profile}
+func LoadProfiles(path string) (map[string]Profile, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	profiles := map[string]Profile{}
+	if err := json.Unmarshal(b, &profiles); err != nil {
+		return nil, fmt.Errorf("invalid profiles file %s: %v", path, err)
+	}
+	return profiles, nil
+}
+
+// Connection bundles a gateway with the gRPC connection it owns
+type Connection struct {
+	*client.Gateway
+	conn *grpc.ClientConn
+}
+
+// Close closes the gateway and the underlying gRPC connection
+func (c *Connection) Close() error {
+	c.Gateway.Close()
+	return c.conn.Close()
+}
+
+// Connect opens a gateway connection for the profile's identity
+func Connect(p Profile) (*Connection, error) {
+	tlsPEM, err := os.ReadFile(p.TLSCACertPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read TLS CA certificate: %v", err)
+	}
+	tlsCert, err := parseCertificate(tlsPEM)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(tlsCert)
+	creds := credentials.NewClientTLSFromCert(pool, p.PeerHostOverride)
+	conn, err := grpc.NewClient(p.PeerEndpoint, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gRPC connection: %v", err)
+	}
+
+	id, sign, err := loadIdentity(p)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	gw, err := client.Connect(id,
+		client.WithSign(sign),
+		client.WithClientConnection(conn),
+		client.WithEvaluateTimeout(30*time.Second),
+		client.WithEndorseTimeout(30*time.Second),
+		client.WithSubmitTimeout(30*time.Second),
+		client.WithCommitStatusTimeout(2*time.Minute),
+	)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to connect gateway: %v", err)
+	}
+	return &Connection{Gateway: gw, conn: conn}, nil
+}
+
+func loadIdentity(p Profile) (*identity.X509Identity, identity.Sign, error) {
+	certPEM, err := os.ReadFile(p.CertPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read certificate: %v", err)
+	}
+	cert, err := parseCertificate(certPEM)
+	if err != nil {
+		return nil, nil, err
+	}
+	id, err := identity.NewX509Identity(p.MSPID, cert)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keyPath := p.KeyPath
+	if info, err := os.Stat(keyPath); err == nil && info.IsDir() {
+		entries, err := os.ReadDir(keyPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(entries) == 0 {
+			return nil, nil, fmt.Errorf("no key found in %s", keyPath)
+		}
+		keyPath = filepath.Join(keyPath, entries[0].Name())
+	}
+	keyPEM, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read private key: %v", err)
+	}
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, nil, fmt.Errorf("no PEM block in %s", keyPath)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse private key: %v", err)
+	}
+	sign, err := identity.NewPrivateKeySign(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return id, sign, nil
+}
+
+func parseCertificate(certPEM []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block in certificate")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// BlockByTxID fetches the block containing txID from the peer's query system chaincode
+func BlockByTxID(network *client.Network, txID string) (*common.Block, error) {
+	b, err := network.GetContract("qscc").EvaluateTransaction("GetBlockByTxID", network.Name(), txID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get block for tx %s: %v", txID, err)
+	}
+	var block common.Block
+	if err := proto.Unmarshal(b, &block); err != nil {
+		return nil, fmt.Errorf("failed to decode block for tx %s: %v", txID, err)
+	}
+	return &block, nil
+}
+
+// ChainHeight returns the number of blocks on the network's channel
+func ChainHeight(network *client.Network) (uint64, error) {
+	b, err := network.GetContract("qscc").EvaluateTransaction("GetChainInfo", network.Name())
+	if err != nil {
+		return 0, fmt.Errorf("failed to get chain info: %v", err)
+	}
+	var info common.BlockchainInfo
+	if err := proto.Unmarshal(b, &info); err != nil {
+		return 0, fmt.Errorf("failed to decode chain info: %v", err)
+	}
+	return info.GetHeight(), nil
+}
+
+// ErrorMessage returns err's message including the chaincode messages that
+// endorsing peers attach as gRPC status details
+func ErrorMessage(err error) string {
+	msg := err.Error()
+	if st, ok := status.FromError(err); ok {
+		for _, d := range st.Details() {
+			if detail, ok := d.(*gatewaypb.ErrorDetail); ok {
+				msg += fmt.Sprintf("; %s (%s): %s", detail.GetAddress(), detail.GetMspId(), detail.GetMessage())
+			}
+		}
+	}
+	return msg
+}
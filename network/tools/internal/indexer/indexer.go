@@ -0,0 +1,63 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+	"github.com/hyperledger/fabric-protos-go-apiv2/common"
+)
+
+// BlockSource delivers committed blocks, as a gateway network does
+type BlockSource interface {
+	BlockEvents(ctx context.Context, options ...client.BlockEventsOption) (<-chan *common.Block, error)
+}
+
+// Indexer applies the blocks of a channel to a store
+type Indexer struct {
+	Source BlockSource
+	Store  *Store
+	// Until stops the indexer once the block before it is applied, e.g. at
+	// the chain height when the indexer starts; 0 follows the chain until
+	// the context is cancelled
+	Until uint64
+	Logf  func(format string, args ...interface{})
+}
+
+// Run reads blocks from the store's checkpoint on and applies them
+func (ix *Indexer) Run(ctx context.Context) error {
+	cp, err := ix.Store.Checkpoint()
+	if err != nil {
+		return err
+	}
+	if ix.Until > 0 && cp.NextBlock >= ix.Until {
+		ix.Logf("index is at block %d, nothing to do", cp.NextBlock)
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	blocks, err := ix.Source.BlockEvents(ctx, client.WithStartBlock(cp.NextBlock))
+	if err != nil {
+		return fmt.Errorf("failed to read blocks: %v", err)
+	}
+	for block := range blocks {
+		number := block.GetHeader().GetNumber()
+		applied, writes, err := ix.Store.ApplyBlock(block)
+		if err != nil {
+			return err
+		}
+		if !applied {
+			ix.Logf("block %d already indexed, skipping", number)
+		} else if writes > 0 {
+			ix.Logf("indexed block %d: %d key(s)", number, writes)
+		}
+		if ix.Until > 0 && number+1 >= ix.Until {
+			return nil
+		}
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+	return fmt.Errorf("block stream closed")
+}
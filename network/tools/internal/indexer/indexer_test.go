@@ -0,0 +1,412 @@
+package indexer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+	"github.com/hyperledger/fabric-protos-go-apiv2/common"
+	"github.com/hyperledger/fabric-protos-go-apiv2/ledger/rwset"
+	"github.com/hyperledger/fabric-protos-go-apiv2/ledger/rwset/kvrwset"
+	"github.com/hyperledger/fabric-protos-go-apiv2/peer"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"iu-tools/internal/ledger"
+)
+
+const (
+	testChannel   = "financial-operations-channel"
+	testChaincode = "iu-chaincode"
+)
+
+func marshal(t *testing.T, m proto.Message) []byte {
+	t.Helper()
+	b, err := proto.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func jsonValue(t *testing.T, v interface{}) []byte {
+	t.Helper()
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// testTx is an endorser transaction writing to a namespace
+type testTx struct {
+	id        string
+	namespace string
+	invalid   bool
+	writes    []*kvrwset.KVWrite
+}
+
+func put(key string, value []byte) *kvrwset.KVWrite {
+	return &kvrwset.KVWrite{Key: key, Value: value}
+}
+
+// chain builds consecutive blocks whose headers link by hash
+type chain struct {
+	t      *testing.T
+	blocks []*common.Block
+}
+
+func (c *chain) add(txs ...testTx) *common.Block {
+	t := c.t
+	number := uint64(len(c.blocks))
+	header := &common.BlockHeader{Number: number, DataHash: []byte{byte(number)}}
+	if number > 0 {
+		header.PreviousHash = ledger.HeaderHash(c.blocks[number-1].GetHeader())
+	}
+	block := &common.Block{Header: header, Data: &common.BlockData{}, Metadata: &common.BlockMetadata{Metadata: make([][]byte, 5)}}
+	var filter []byte
+	for i, tx := range txs {
+		namespace := tx.namespace
+		if namespace == "" {
+			namespace = testChaincode
+		}
+		results := marshal(t, &rwset.TxReadWriteSet{NsRwset: []*rwset.NsReadWriteSet{
+			{Namespace: namespace, Rwset: marshal(t, &kvrwset.KVRWSet{Writes: tx.writes})},
+		}})
+		action := marshal(t, &peer.ChaincodeActionPayload{Action: &peer.ChaincodeEndorsedAction{
+			ProposalResponsePayload: marshal(t, &peer.ProposalResponsePayload{Extension: marshal(t, &peer.ChaincodeAction{Results: results})}),
+		}})
+		channelHeader := marshal(t, &common.ChannelHeader{
+			Type:      int32(common.HeaderType_ENDORSER_TRANSACTION),
+			TxId:      tx.id,
+			Timestamp: timestamppb.New(time.Date(2024, 6, 1, 10, 0, int(number)*10+i, 0, time.UTC)),
+		})
+		payload := marshal(t, &common.Payload{
+			Header: &common.Header{ChannelHeader: channelHeader},
+			Data:   marshal(t, &peer.Transaction{Actions: []*peer.TransactionAction{{Payload: action}}}),
+		})
+		block.Data.Data = append(block.Data.Data, marshal(t, &common.Envelope{Payload: payload}))
+		code := peer.TxValidationCode_VALID
+		if tx.invalid {
+			code = peer.TxValidationCode_MVCC_READ_CONFLICT
+		}
+		filter = append(filter, byte(code))
+	}
+	block.Metadata.Metadata[common.BlockMetadataIndex_TRANSACTIONS_FILTER] = filter
+	c.blocks = append(c.blocks, block)
+	return block
+}
+
+func transaction(id, debtor, txType string, amount float64) map[string]interface{} {
+	return map[string]interface{}{
+		"id": id, "creditorId": "C1", "debtorId": debtor, "amount": amount, "currency": "INR",
+		"transactionType": txType, "status": "COMPLETED", "timestamp": "2024-05-01T09:30:00+05:30",
+		"hash": "h-" + id, "previousHash": "", "validatedBy": "CreditorMSP", "complianceChecked": true,
+	}
+}
+
+// testChain is a genesis block and three blocks of IUContract writes
+func testChain(t *testing.T) *chain {
+	c := &chain{t: t}
+	c.add()
+	c.add(
+		testTx{id: "tx1", writes: []*kvrwset.KVWrite{
+			put("T1", jsonValue(t, transaction("T1", "D1", "DEBIT", 500000))),
+			put("ACC1", jsonValue(t, map[string]interface{}{"id": "ACC1", "ownerId": "C1", "balance": 1e6, "currency": "INR", "accountType": "CREDITOR", "status": "ACTIVE", "createdAt": "0001-01-01T00:00:00Z"})),
+			put("AUDIT_T1_CREATE_1717200000", jsonValue(t, map[string]interface{}{"docType": "auditRecord", "id": "AUDIT_T1_CREATE_1717200000", "transactionId": "T1", "action": "CREATE", "actor": "CreditorMSP", "timestamp": "2024-06-01T00:00:00Z", "complianceStatus": "COMPLIANT", "txId": "tx1"})),
+		}},
+		testTx{id: "tx2", namespace: "other", writes: []*kvrwset.KVWrite{put("T9", jsonValue(t, transaction("T9", "D9", "DEBIT", 1)))}},
+	)
+	c.add(
+		testTx{id: "tx3", writes: []*kvrwset.KVWrite{
+			put("T2", jsonValue(t, transaction("T2", "D1", "CREDIT", 100000))),
+			put("T3", jsonValue(t, transaction("T3", "D2", "DEBIT", 200000))),
+		}},
+		testTx{id: "tx4", invalid: true, writes: []*kvrwset.KVWrite{put("T4", jsonValue(t, transaction("T4", "D2", "DEBIT", 999)))}},
+		testTx{id: "tx5", writes: []*kvrwset.KVWrite{
+			put("DEFAULT_DEF1", jsonValue(t, map[string]interface{}{"defaultId": "DEF1", "loanId": "L1", "creditorId": "C1", "debtorId": "D1", "amount": 40000, "currency": "INR", "defaultDate": "2024-05-16", "authStatus": "AUTHENTICATED"})),
+			put("DEFAULT_DEF2", jsonValue(t, map[string]interface{}{"defaultId": "DEF2", "loanId": "L2", "creditorId": "C1", "debtorId": "D2", "amount": 5000, "currency": "INR", "defaultDate": "2024-08-02", "authStatus": "PENDING_AUTHENTICATION"})),
+			put("\x00CLAIM\x00CASE1\x00C1\x00", jsonValue(t, map[string]interface{}{"caseId": "CASE1", "creditorId": "C1", "claimAmount": 400000, "status": "SUBMITTED"})),
+			put("\x00CASE_DEBTOR\x00D1\x00CASE1\x00", []byte{0x00}),
+		}},
+	)
+	c.add(testTx{id: "tx6", writes: []*kvrwset.KVWrite{
+		{Key: "ACC1", IsDelete: true},
+		put("T3", jsonValue(t, transaction("T3", "D2", "DEBIT", 250000))),
+	}})
+	return c
+}
+
+func openTest(t *testing.T, path string) *Store {
+	t.Helper()
+	s, err := Open("sqlite:"+path, testChannel, testChaincode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func count(t *testing.T, s *Store, query string, args ...interface{}) int {
+	t.Helper()
+	var n int
+	if err := s.DB().QueryRow(query, args...).Scan(&n); err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+	return n
+}
+
+func TestApplyBlock(t *testing.T) {
+	s := openTest(t, filepath.Join(t.TempDir(), "index.db"))
+	c := testChain(t)
+	for _, b := range c.blocks {
+		if applied, _, err := s.ApplyBlock(b); err != nil || !applied {
+			t.Fatalf("block %d: applied %v, err %v", b.GetHeader().GetNumber(), applied, err)
+		}
+	}
+
+	if n := count(t, s, "SELECT COUNT(*) FROM transactions"); n != 3 {
+		t.Fatalf("%d transactions; want T1-T3 without the other chaincode's and the invalid one", n)
+	}
+	var amount float64
+	var timestamp string
+	var lastBlock int
+	var checked bool
+	if err := s.DB().QueryRow("SELECT amount, timestamp, last_block, compliance_checked FROM transactions WHERE key = 'T3'").
+		Scan(&amount, &timestamp, &lastBlock, &checked); err != nil {
+		t.Fatal(err)
+	}
+	if amount != 250000 || timestamp != "2024-05-01T04:00:00.000000Z" || lastBlock != 3 || !checked {
+		t.Fatalf("T3 is %v at %s from block %d, checked %v", amount, timestamp, lastBlock, checked)
+	}
+	if n := count(t, s, "SELECT COUNT(*) FROM accounts"); n != 0 {
+		t.Fatal("deleted account still indexed")
+	}
+	if n := count(t, s, "SELECT COUNT(*) FROM claims WHERE key = 'CLAIM/CASE1/C1' AND claim_amount = 400000"); n != 1 {
+		t.Fatal("claim not indexed under its composite key")
+	}
+	if n := count(t, s, "SELECT COUNT(*) FROM audit_records WHERE transaction_id = 'T1' AND tx_id = 'tx1'"); n != 1 {
+		t.Fatal("audit record not indexed")
+	}
+	if n := count(t, s, "SELECT COUNT(*) FROM key_history"); n != 11 {
+		t.Fatalf("%d key history rows", n)
+	}
+	if n := count(t, s, "SELECT COUNT(*) FROM key_history WHERE key = 'ACC1' AND deleted"); n != 1 {
+		t.Fatal("delete not in key history")
+	}
+
+	var outstanding, defaulted float64
+	var creditors int
+	if err := s.DB().QueryRow("SELECT outstanding, defaulted, creditors FROM debtor_exposure WHERE debtor_id = 'D1'").
+		Scan(&outstanding, &defaulted, &creditors); err != nil {
+		t.Fatal(err)
+	}
+	if outstanding != 400000 || defaulted != 40000 || creditors != 1 {
+		t.Fatalf("D1 exposure %v, defaulted %v, %d creditors", outstanding, defaulted, creditors)
+	}
+
+	if _, err := s.DB().Exec("INSERT INTO loan_branches (loan_id, branch) VALUES ('L1', 'Andheri')"); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := s.DB().Query("SELECT default_id, branch FROM overdue_loans ORDER BY default_id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for rows.Next() {
+		var id, branch string
+		if err := rows.Scan(&id, &branch); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, id+"@"+branch)
+	}
+	rows.Close()
+	if strings.Join(got, ",") != "DEF1@Andheri,DEF2@" {
+		t.Fatalf("overdue loans %v", got)
+	}
+	if n := count(t, s, "SELECT COUNT(*) FROM quarterly_defaults WHERE quarter = '2024-Q3'"); n != 1 {
+		t.Fatal("want DEF2 in 2024-Q3")
+	}
+
+	// Blocks already applied are skipped; blocks out of order are refused
+	if applied, _, err := s.ApplyBlock(c.blocks[2]); err != nil || applied {
+		t.Fatalf("replayed block: applied %v, err %v", applied, err)
+	}
+	next := testChain(t)
+	next.add()
+	next.add()
+	if _, _, err := s.ApplyBlock(next.blocks[5]); err == nil || !strings.Contains(err.Error(), "index is at block 4") {
+		t.Fatalf("gap: err %v", err)
+	}
+	forked := &common.Block{Header: &common.BlockHeader{Number: 4, PreviousHash: []byte("other")}}
+	if _, _, err := s.ApplyBlock(forked); err == nil || !strings.Contains(err.Error(), "does not follow") {
+		t.Fatalf("unlinked block: err %v", err)
+	}
+	if _, _, err := s.ApplyBlock(next.blocks[4]); err != nil {
+		t.Fatal(err)
+	}
+	if cp, _ := s.Checkpoint(); cp.NextBlock != 5 {
+		t.Fatalf("checkpoint %+v", cp)
+	}
+}
+
+// fakeSource delivers blocks from the store's checkpoint on, which is the
+// start block Run asks for, then blocks until cancelled as a live channel
+// would
+type fakeSource struct {
+	blocks []*common.Block
+	store  *Store
+}
+
+func (f *fakeSource) BlockEvents(ctx context.Context, options ...client.BlockEventsOption) (<-chan *common.Block, error) {
+	cp, err := f.store.Checkpoint()
+	if err != nil {
+		return nil, err
+	}
+	start := cp.NextBlock
+	out := make(chan *common.Block)
+	go func() {
+		defer close(out)
+		for _, b := range f.blocks[start:] {
+			select {
+			case out <- b:
+			case <-ctx.Done():
+				return
+			}
+		}
+		<-ctx.Done()
+	}()
+	return out, nil
+}
+
+func TestRunAndRebuild(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index.db")
+	s := openTest(t, path)
+	c := testChain(t)
+	var logs []string
+	logf := func(format string, args ...interface{}) { logs = append(logs, format) }
+
+	ix := &Indexer{Source: &fakeSource{blocks: c.blocks, store: s}, Store: s, Until: uint64(len(c.blocks)), Logf: logf}
+	if err := ix.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if cp, _ := s.Checkpoint(); cp.NextBlock != 4 {
+		t.Fatalf("checkpoint %+v", cp)
+	}
+	// At the chain height there is nothing to read
+	if err := ix.Run(context.Background()); err != nil || !strings.Contains(logs[len(logs)-1], "nothing to do") {
+		t.Fatalf("err %v, logs %q", err, logs)
+	}
+
+	// Following stops when the context is cancelled
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	s2 := openTest(t, filepath.Join(t.TempDir(), "follow.db"))
+	follow := &Indexer{Source: &fakeSource{blocks: c.blocks, store: s2}, Store: s2, Logf: logf}
+	if err := follow.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(t, s2, "SELECT COUNT(*) FROM transactions"); n != 3 {
+		t.Fatalf("followed %d transactions", n)
+	}
+
+	// A rebuild clears the index but keeps the bank's branch mapping
+	if _, err := s.DB().Exec("INSERT INTO loan_branches (loan_id, branch) VALUES ('L1', 'Andheri')"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	if cp, _ := s.Checkpoint(); cp.NextBlock != 0 || count(t, s, "SELECT COUNT(*) FROM transactions") != 0 {
+		t.Fatalf("index not reset: %+v", cp)
+	}
+	if err := ix.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(t, s, "SELECT COUNT(*) FROM overdue_loans WHERE branch = 'Andheri'"); n != 1 {
+		t.Fatal("branch mapping lost in rebuild")
+	}
+}
+
+func TestOtherChannel(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index.db")
+	s := openTest(t, path)
+	if _, _, err := s.ApplyBlock(testChain(t).blocks[0]); err != nil {
+		t.Fatal(err)
+	}
+	other, err := Open("sqlite:"+path, "audit-compliance-channel", testChaincode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	if _, err := other.Checkpoint(); err == nil || !strings.Contains(err.Error(), "index holds iu-chaincode on financial-operations-channel") {
+		t.Fatalf("err %v", err)
+	}
+	if _, err := Open("mysql://x", testChannel, testChaincode); err == nil {
+		t.Fatal("unsupported database accepted")
+	}
+}
+
+func TestVersions(t *testing.T) {
+	s := openTest(t, filepath.Join(t.TempDir(), "index.db"))
+	c := testChain(t)
+	for _, b := range c.blocks {
+		if _, _, err := s.ApplyBlock(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	from := time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC)
+	versions, err := s.Versions(from, from.Add(30*time.Second), "transactions", "defaults", "accounts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, v := range versions {
+		got = append(got, fmt.Sprintf("%s@%s/%s", v.Key, v.TxID, v.Table))
+	}
+	// T3's rewrite in block 3 is after the period and ACC1 was deleted
+	if want := "T1@tx1/transactions,T2@tx3/transactions,T3@tx3/transactions,DEFAULT_DEF1@tx5/defaults,DEFAULT_DEF2@tx5/defaults"; strings.Join(got, ",") != want {
+		t.Fatalf("versions %v, want %s", got, want)
+	}
+	sum := sha256.Sum256(jsonValue(t, transaction("T1", "D1", "DEBIT", 500000)))
+	if v := versions[0]; v.ValueHash != hex.EncodeToString(sum[:]) || !v.WrittenAt.Equal(from.Add(10*time.Second)) {
+		t.Fatalf("T1 version %+v", v)
+	}
+	if _, err := s.Versions(from, from, "nope"); err == nil {
+		t.Fatal("unknown table accepted")
+	}
+	if last, err := s.LastWrite(); err != nil || !last.Equal(from.Add(30*time.Second)) {
+		t.Fatalf("last write %v, err %v", last, err)
+	}
+}
+
+func TestVersionsNeedValueHashes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index.db")
+	s := openTest(t, path)
+	// An index from before key_history held value hashes
+	for _, stmt := range []string{
+		"DROP TABLE key_history",
+		"CREATE TABLE key_history (key TEXT NOT NULL, block BIGINT NOT NULL, tx_index BIGINT NOT NULL, tx_id TEXT NOT NULL, written_at TEXT NOT NULL, deleted BOOLEAN NOT NULL, PRIMARY KEY (block, tx_index, key))",
+		"INSERT INTO key_history VALUES ('T1', 1, 0, 'tx1', '2024-06-01T10:00:10.000000Z', false)",
+		"INSERT INTO transactions (key, last_block, last_tx_id, last_written_at, doc) VALUES ('T1', 1, 'tx1', '2024-06-01T10:00:10.000000Z', '{}')",
+	} {
+		if _, err := s.DB().Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.Close()
+
+	s = openTest(t, path)
+	from := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	if _, err := s.Versions(from, from.AddDate(0, 0, 1), "transactions"); err == nil || !strings.Contains(err.Error(), "rebuild the index") {
+		t.Fatalf("err %v", err)
+	}
+}
@@ -0,0 +1,391 @@
+package indexer
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// kind is how a JSON field is stored
+type kind int
+
+const (
+	text kind = iota
+	number
+	integer
+	boolean
+	instant // a time.Time field
+)
+
+// sqlTypes are the column types of each kind. Times are stored as
+// fixed-width UTC text so they sort and compare the same in SQLite and
+// Postgres.
+var sqlTypes = map[kind]string{
+	text:    "TEXT",
+	number:  "DOUBLE PRECISION",
+	integer: "BIGINT",
+	boolean: "BOOLEAN",
+	instant: "TEXT",
+}
+
+const timeLayout = "2006-01-02T15:04:05.000000Z"
+
+// column is a table column filled from a JSON field of the ledger value
+type column struct {
+	name  string
+	field string
+	kind  kind
+}
+
+// table is the normalized table of one kind of IUContract record. Every
+// table is keyed by the ledger key and also holds the block and transaction
+// that last wrote the record and the record's full JSON.
+type table struct {
+	name    string
+	match   func(key string, doc map[string]json.RawMessage) bool
+	columns []column
+}
+
+func prefixed(prefix string) func(string, map[string]json.RawMessage) bool {
+	return func(key string, _ map[string]json.RawMessage) bool { return strings.HasPrefix(key, prefix) }
+}
+
+func composite(objectType string) func(string, map[string]json.RawMessage) bool {
+	return prefixed(compositeSeparator + objectType + compositeSeparator)
+}
+
+// unprefixed matches records stored under their bare ID that have field,
+// as transactions and accounts are
+func unprefixed(field string) func(string, map[string]json.RawMessage) bool {
+	return func(key string, doc map[string]json.RawMessage) bool {
+		_, ok := doc[field]
+		return ok && !strings.HasPrefix(key, compositeSeparator)
+	}
+}
+
+// cols builds columns from name, JSON field and kind triples
+func cols(spec ...interface{}) []column {
+	var out []column
+	for i := 0; i+2 < len(spec); i += 3 {
+		out = append(out, column{name: spec[i].(string), field: spec[i+1].(string), kind: spec[i+2].(kind)})
+	}
+	return out
+}
+
+// tables are tried in order; a record goes into the first that matches.
+// Prefixed keys come first so that a record with a key prefix is never
+// taken for a transaction or account.
+var tables = []table{
+	{"defaults", prefixed("DEFAULT_"), cols(
+		"default_id", "defaultId", text,
+		"loan_id", "loanId", text,
+		"creditor_id", "creditorId", text,
+		"debtor_id", "debtorId", text,
+		"amount", "amount", number,
+		"currency", "currency", text,
+		"default_date", "defaultDate", text,
+		"guarantee_id", "guaranteeId", text,
+		"filed_by", "filedBy", text,
+		"filed_at", "filedAt", instant,
+		"auth_status", "authStatus", text,
+		"responded_at", "respondedAt", instant,
+	)},
+	{"insolvency_cases", prefixed("CASE_"), cols(
+		"case_id", "caseId", text,
+		"debtor_id", "debtorId", text,
+		"cin", "cin", text,
+		"nclt_case_id", "ncltCaseId", text,
+		"admission_date", "admissionDate", text,
+		"moratorium_start", "moratoriumStart", text,
+		"moratorium_end", "moratoriumEnd", text,
+		"status", "status", text,
+		"registered_at", "registeredAt", instant,
+	)},
+	{"claims", composite("CLAIM"), cols(
+		"case_id", "caseId", text,
+		"creditor_id", "creditorId", text,
+		"claim_amount", "claimAmount", number,
+		"admitted_amount", "admittedAmount", number,
+		"status", "status", text,
+		"submitted_at", "submittedAt", instant,
+		"decided_at", "decidedAt", instant,
+	)},
+	{"guarantees", prefixed("GUARANTEE_"), cols(
+		"guarantee_id", "guaranteeId", text,
+		"loan_id", "loanId", text,
+		"borrower_id", "borrowerId", text,
+		"guarantor_id", "guarantorId", text,
+		"guarantee_type", "type", text,
+		"creditor_id", "creditorId", text,
+		"cap_amount", "capAmount", number,
+		"currency", "currency", text,
+		"status", "status", text,
+		"invocation_date", "invocationDate", text,
+		"invoked_amount", "invokedAmount", number,
+		"default_id", "defaultId", text,
+	)},
+	{"collateral", prefixed("COLLATERAL_"), cols(
+		"collateral_id", "collateralId", text,
+		"asset_type", "assetType", text,
+		"asset_identifier", "assetIdentifier", text,
+		"owner_id", "ownerId", text,
+		"registered_at", "registeredAt", instant,
+	)},
+	{"charges", prefixed("CHARGE_"), cols(
+		"charge_id", "chargeId", text,
+		"collateral_id", "collateralId", text,
+		"loan_id", "loanId", text,
+		"creditor_id", "creditorId", text,
+		"amount", "amount", number,
+		"charge_rank", "rank", integer,
+		"status", "status", text,
+	)},
+	{"consortium_loans", prefixed("CONSORTIUM_"), cols(
+		"loan_id", "loanId", text,
+		"debtor_id", "debtorId", text,
+		"lead_creditor_id", "leadCreditorId", text,
+		"sanctioned_amount", "sanctionedAmount", number,
+		"outstanding", "outstanding", number,
+		"currency", "currency", text,
+		"status", "status", text,
+		"created_at", "createdAt", instant,
+		"last_updated", "lastUpdated", instant,
+	)},
+	{"documents", prefixed("DOC_"), cols(
+		"doc_id", "docId", text,
+		"loan_id", "loanId", text,
+		"hash", "hash", text,
+		"doc_type", "type", text,
+		"mime", "mime", text,
+		"size", "size", integer,
+		"owner_org", "ownerOrg", text,
+		"uploaded_at", "uploadedAt", instant,
+		"status", "status", text,
+	)},
+	{"kyc_references", prefixed("KYC_"), cols(
+		"kyc_id", "kycId", text,
+		"loan_id", "loanId", text,
+		"party_id", "partyId", text,
+		"hash", "hash", text,
+		"status", "status", text,
+		"submitted_at", "timestamp", instant,
+	)},
+	{"import_batches", prefixed("IMPORT_BATCH_"), cols(
+		"batch_id", "batchId", text,
+		"batch_hash", "batchHash", text,
+		"atomic", "atomic", boolean,
+		"row_count", "rows", integer,
+		"imported", "imported", integer,
+		"imported_by", "importedBy", text,
+		"imported_at", "importedAt", instant,
+		"tx_id", "txId", text,
+	)},
+	{"institutions", prefixed("FI_"), cols(
+		"institution_id", "institutionId", text,
+		"name", "name", text,
+		"registration_number", "registrationNumber", text,
+		"institution_type", "type", text,
+		"pan", "PAN", text,
+		"gstin", "GSTIN", text,
+		"cin", "CIN", text,
+		"msp_id", "mspId", text,
+		"status", "status", text,
+		"onboarded_at", "onboardedAt", instant,
+		"updated_at", "updatedAt", instant,
+	)},
+	{"borrowers", prefixed("BORROWER_"), cols(
+		"borrower_id", "borrowerId", text,
+		"name", "name", text,
+		"borrower_type", "type", text,
+		"pan", "PAN", text,
+		"cin", "CIN", text,
+		"status", "status", text,
+		"onboarded_at", "onboardedAt", instant,
+		"updated_at", "updatedAt", instant,
+	)},
+	{"audit_records", prefixed("AUDIT_"), cols(
+		"id", "id", text,
+		"transaction_id", "transactionId", text,
+		"action", "action", text,
+		"actor", "actor", text,
+		"timestamp", "timestamp", instant,
+		"compliance_status", "complianceStatus", text,
+		"hash", "hash", text,
+		"tx_id", "txId", text,
+		"source_tx_id", "sourceTxId", text,
+		"source_block", "sourceBlock", integer,
+	)},
+	{"transactions", unprefixed("transactionType"), cols(
+		"id", "id", text,
+		"creditor_id", "creditorId", text,
+		"debtor_id", "debtorId", text,
+		"amount", "amount", number,
+		"currency", "currency", text,
+		"transaction_type", "transactionType", text,
+		"status", "status", text,
+		"timestamp", "timestamp", instant,
+		"hash", "hash", text,
+		"previous_hash", "previousHash", text,
+		"validated_by", "validatedBy", text,
+		"compliance_checked", "complianceChecked", boolean,
+	)},
+	{"accounts", unprefixed("accountType"), cols(
+		"id", "id", text,
+		"owner_id", "ownerId", text,
+		"balance", "balance", number,
+		"currency", "currency", text,
+		"account_type", "accountType", text,
+		"status", "status", text,
+		"created_at", "createdAt", instant,
+		"last_updated", "lastUpdated", instant,
+	)},
+}
+
+// tableFor returns the table a ledger value belongs in, or nil for values
+// that are not records, such as composite index keys
+func tableFor(key string, value []byte) (*table, map[string]json.RawMessage) {
+	var doc map[string]json.RawMessage
+	if json.Unmarshal(value, &doc) != nil {
+		return nil, nil
+	}
+	for i := range tables {
+		if tables[i].match(key, doc) {
+			return &tables[i], doc
+		}
+	}
+	return nil, nil
+}
+
+// value converts a JSON field to the column's SQL value. Missing fields and
+// zero times are NULL.
+func (c column) value(doc map[string]json.RawMessage) (interface{}, error) {
+	raw, ok := doc[c.field]
+	if !ok || string(raw) == "null" {
+		return nil, nil
+	}
+	var err error
+	switch c.kind {
+	case number:
+		var v float64
+		if err = json.Unmarshal(raw, &v); err == nil {
+			return v, nil
+		}
+	case integer:
+		var v int64
+		if err = json.Unmarshal(raw, &v); err == nil {
+			return v, nil
+		}
+	case boolean:
+		var v bool
+		if err = json.Unmarshal(raw, &v); err == nil {
+			return v, nil
+		}
+	case instant:
+		var v time.Time
+		if err = json.Unmarshal(raw, &v); err == nil {
+			if v.IsZero() {
+				return nil, nil
+			}
+			return v.UTC().Format(timeLayout), nil
+		}
+	default:
+		var v string
+		if err = json.Unmarshal(raw, &v); err == nil {
+			return v, nil
+		}
+	}
+	return nil, fmt.Errorf("field %s: %v", c.field, err)
+}
+
+// compositeSeparator starts and separates the parts of a composite key
+const compositeSeparator = "\x00"
+
+// storedKey is the key a record is stored under. Postgres text cannot hold
+// NUL, so the parts of a composite key are joined with '/' instead, e.g.
+// CLAIM/CASE001/C1.
+func storedKey(key string) string {
+	if !strings.HasPrefix(key, compositeSeparator) {
+		return key
+	}
+	return strings.ReplaceAll(strings.Trim(key, compositeSeparator), compositeSeparator, "/")
+}
+
+func (t *table) createSQL() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "CREATE TABLE IF NOT EXISTS %s (\n\tkey TEXT PRIMARY KEY", t.name)
+	for _, c := range t.columns {
+		fmt.Fprintf(&b, ",\n\t%s %s", c.name, sqlTypes[c.kind])
+	}
+	b.WriteString(",\n\tlast_block BIGINT NOT NULL,\n\tlast_tx_id TEXT NOT NULL,\n\tlast_written_at TEXT NOT NULL,\n\tdoc TEXT NOT NULL\n)")
+	return b.String()
+}
+
+func (t *table) upsertSQL() string {
+	names := []string{"key"}
+	for _, c := range t.columns {
+		names = append(names, c.name)
+	}
+	names = append(names, "last_block", "last_tx_id", "last_written_at", "doc")
+	var set []string
+	for _, n := range names[1:] {
+		set = append(set, fmt.Sprintf("%s = excluded.%s", n, n))
+	}
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) ON CONFLICT (key) DO UPDATE SET %s",
+		t.name, strings.Join(names, ", "), strings.TrimSuffix(strings.Repeat("?, ", len(names)), ", "), strings.Join(set, ", "))
+}
+
+// ledgerSchema holds the tables rebuilt from the chain. loan_branches is
+// the bank's own mapping of loans to branches; it is never written from the
+// ledger and survives a rebuild.
+var ledgerSchema = []string{
+	`CREATE TABLE IF NOT EXISTS index_checkpoint (
+	channel TEXT NOT NULL,
+	chaincode TEXT NOT NULL,
+	next_block BIGINT NOT NULL,
+	last_block_hash TEXT NOT NULL,
+	updated_at TEXT NOT NULL,
+	PRIMARY KEY (channel, chaincode)
+)`,
+	`CREATE TABLE IF NOT EXISTS key_history (
+	key TEXT NOT NULL,
+	block BIGINT NOT NULL,
+	tx_index BIGINT NOT NULL,
+	tx_id TEXT NOT NULL,
+	written_at TEXT NOT NULL,
+	deleted BOOLEAN NOT NULL,
+	value_hash TEXT NOT NULL DEFAULT '',
+	PRIMARY KEY (block, tx_index, key)
+)`,
+	`CREATE INDEX IF NOT EXISTS key_history_key ON key_history (key, block)`,
+}
+
+const branchesSchema = `CREATE TABLE IF NOT EXISTS loan_branches (
+	loan_id TEXT PRIMARY KEY,
+	branch TEXT NOT NULL
+)`
+
+// views answer the common questions over the normalized tables. Exposure
+// counts completed disbursements less repayments, and defaults once they
+// are authenticated or deemed so.
+var views = []struct{ name, query string }{
+	{"debtor_exposure", `SELECT t.debtor_id, t.currency,
+	COUNT(DISTINCT t.creditor_id) AS creditors,
+	SUM(CASE WHEN t.transaction_type = 'DEBIT' THEN t.amount ELSE 0 END) AS disbursed,
+	SUM(CASE WHEN t.transaction_type = 'CREDIT' THEN t.amount ELSE 0 END) AS repaid,
+	SUM(CASE t.transaction_type WHEN 'DEBIT' THEN t.amount WHEN 'CREDIT' THEN -t.amount ELSE 0 END) AS outstanding,
+	COALESCE((SELECT SUM(d.amount) FROM defaults d
+		WHERE d.debtor_id = t.debtor_id AND d.currency = t.currency
+		AND d.auth_status IN ('AUTHENTICATED', 'DEEMED_AUTHENTICATED')), 0) AS defaulted
+FROM transactions t
+WHERE t.status = 'COMPLETED'
+GROUP BY t.debtor_id, t.currency`},
+	{"overdue_loans", `SELECT d.default_id, d.loan_id, d.creditor_id, d.debtor_id, d.amount, d.currency,
+	d.default_date, d.auth_status, COALESCE(b.branch, '') AS branch
+FROM defaults d LEFT JOIN loan_branches b ON b.loan_id = d.loan_id
+WHERE d.auth_status <> 'DISPUTED'`},
+	{"quarterly_defaults", `SELECT d.default_id, d.loan_id, d.creditor_id, d.debtor_id, d.amount, d.currency,
+	d.default_date, d.auth_status,
+	substr(d.default_date, 1, 4) || '-Q' || CAST((CAST(substr(d.default_date, 6, 2) AS INTEGER) + 2) / 3 AS TEXT) AS quarter
+FROM defaults d`},
+}
@@ -0,0 +1,346 @@
+// Package indexer keeps an off-chain SQL copy of IUContract state for
+// queries the chaincode cannot answer cheaply, such as exposure across all
+// debtors or defaults in a quarter. It reads committed blocks, decodes the
+// write sets of the contract's namespace and upserts each record into a
+// normalized table per record type. Every block is applied in one SQL
+// transaction together with the checkpoint of the next block to read, so a
+// block is applied exactly once however often the indexer restarts. Fabric
+// blocks are final, so there are no reorganizations to undo; the index is
+// rebuilt by clearing it and reading again from the genesis block.
+//
+// The store is SQLite (sqlite:<path>) or Postgres (postgres://...). Values
+// written to private data collections are not in blocks and not indexed.
+package indexer
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-protos-go-apiv2/common"
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+
+	"iu-tools/internal/ledger"
+)
+
+// Store is an index of one chaincode on one channel
+type Store struct {
+	db        *sql.DB
+	postgres  bool
+	channel   string
+	chaincode string
+}
+
+// Open opens the index at dsn for chaincode on channel, creating its
+// tables and views as needed
+func Open(dsn, channel, chaincode string) (*Store, error) {
+	s := &Store{channel: channel, chaincode: chaincode}
+	var err error
+	switch {
+	case strings.HasPrefix(dsn, "postgres://"), strings.HasPrefix(dsn, "postgresql://"):
+		s.postgres = true
+		s.db, err = sql.Open("postgres", dsn)
+	case strings.HasPrefix(dsn, "sqlite:"):
+		s.db, err = sql.Open("sqlite3", strings.TrimPrefix(dsn, "sqlite:"))
+		// One writer at a time, as SQLite allows
+		s.db.SetMaxOpenConns(1)
+	default:
+		return nil, fmt.Errorf("unsupported index %q (want sqlite:<path> or postgres://...)", dsn)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := s.migrate(); err != nil {
+		s.db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Close closes the database
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Channel returns the channel the index is of
+func (s *Store) Channel() string {
+	return s.channel
+}
+
+// DB returns the database for queries over the index
+func (s *Store) DB() *sql.DB {
+	return s.db
+}
+
+// rebind rewrites ? placeholders as $1, $2, ... for Postgres
+func (s *Store) rebind(query string) string {
+	if !s.postgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func (s *Store) migrate() error {
+	stmts := append([]string{branchesSchema}, ledgerSchema...)
+	for i := range tables {
+		stmts = append(stmts, tables[i].createSQL())
+	}
+	for _, v := range views {
+		stmts = append(stmts, "DROP VIEW IF EXISTS "+v.name, fmt.Sprintf("CREATE VIEW %s AS %s", v.name, v.query))
+	}
+	for _, stmt := range stmts {
+		if _, err := s.db.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to migrate index: %v", err)
+		}
+	}
+	// Indexes built before key_history held value hashes gain the column
+	// empty; Versions refuses them until the index is rebuilt
+	if _, err := s.db.Exec("SELECT value_hash FROM key_history WHERE 1 = 0"); err != nil {
+		if _, err := s.db.Exec("ALTER TABLE key_history ADD COLUMN value_hash TEXT NOT NULL DEFAULT ''"); err != nil {
+			return fmt.Errorf("failed to migrate index: %v", err)
+		}
+	}
+	return nil
+}
+
+// Checkpoint is the position of the index on the chain
+type Checkpoint struct {
+	NextBlock     uint64
+	LastBlockHash string // hex header hash of block NextBlock-1
+}
+
+// Checkpoint returns the next block to apply; 0 for an empty index. An
+// index of another channel or chaincode is an error.
+func (s *Store) Checkpoint() (Checkpoint, error) {
+	return s.checkpoint(s.db)
+}
+
+type queryer interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+func (s *Store) checkpoint(q queryer) (Checkpoint, error) {
+	var cp Checkpoint
+	err := q.QueryRow(s.rebind("SELECT next_block, last_block_hash FROM index_checkpoint WHERE channel = ? AND chaincode = ?"),
+		s.channel, s.chaincode).Scan(&cp.NextBlock, &cp.LastBlockHash)
+	if err == nil {
+		return cp, nil
+	}
+	if err != sql.ErrNoRows {
+		return Checkpoint{}, fmt.Errorf("failed to read checkpoint: %v", err)
+	}
+	var channel, chaincode string
+	err = q.QueryRow("SELECT channel, chaincode FROM index_checkpoint").Scan(&channel, &chaincode)
+	if err == sql.ErrNoRows {
+		return Checkpoint{}, nil
+	}
+	if err != nil {
+		return Checkpoint{}, fmt.Errorf("failed to read checkpoint: %v", err)
+	}
+	return Checkpoint{}, fmt.Errorf("index holds %s on %s, not %s on %s; rebuild it or use another database",
+		chaincode, channel, s.chaincode, s.channel)
+}
+
+// ApplyBlock indexes the chaincode's writes in block and advances the
+// checkpoint past it. A block already applied is skipped; a block past the
+// checkpoint, or one that does not follow the last applied block, is an
+// error. It returns whether the block was applied and how many keys it wrote.
+func (s *Store) ApplyBlock(block *common.Block) (applied bool, writes int, err error) {
+	number := block.GetHeader().GetNumber()
+	txs, err := ledger.NamespaceWrites(block, s.chaincode)
+	if err != nil {
+		return false, 0, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, 0, err
+	}
+	defer tx.Rollback()
+
+	cp, err := s.checkpoint(tx)
+	if err != nil {
+		return false, 0, err
+	}
+	if number < cp.NextBlock {
+		return false, 0, nil
+	}
+	if number > cp.NextBlock {
+		return false, 0, fmt.Errorf("received block %d while the index is at block %d", number, cp.NextBlock)
+	}
+	if previous := hex.EncodeToString(block.GetHeader().GetPreviousHash()); cp.LastBlockHash != "" && previous != cp.LastBlockHash {
+		return false, 0, fmt.Errorf("block %d does not follow the indexed block %d: previous hash %s, indexed %s",
+			number, number-1, previous, cp.LastBlockHash)
+	}
+
+	for _, t := range txs {
+		writtenAt := t.Timestamp.UTC().Format(timeLayout)
+		for _, w := range t.Writes {
+			key := storedKey(w.Key)
+			valueHash := ""
+			if !w.IsDelete {
+				sum := sha256.Sum256(w.Value)
+				valueHash = hex.EncodeToString(sum[:])
+			}
+			if _, err := tx.Exec(s.rebind("INSERT INTO key_history (key, block, tx_index, tx_id, written_at, deleted, value_hash) VALUES (?, ?, ?, ?, ?, ?, ?)"),
+				key, number, t.TxIndex, t.TxID, writtenAt, w.IsDelete, valueHash); err != nil {
+				return false, 0, fmt.Errorf("block %d tx %s: failed to record %s: %v", number, t.TxID, key, err)
+			}
+			if err := s.applyWrite(tx, key, w, number, t.TxID, writtenAt); err != nil {
+				return false, 0, fmt.Errorf("block %d tx %s: %v", number, t.TxID, err)
+			}
+			writes++
+		}
+	}
+
+	hash := hex.EncodeToString(ledger.HeaderHash(block.GetHeader()))
+	now := time.Now().UTC().Format(timeLayout)
+	if _, err := tx.Exec(s.rebind(`INSERT INTO index_checkpoint (channel, chaincode, next_block, last_block_hash, updated_at) VALUES (?, ?, ?, ?, ?)
+ON CONFLICT (channel, chaincode) DO UPDATE SET next_block = excluded.next_block, last_block_hash = excluded.last_block_hash, updated_at = excluded.updated_at`),
+		s.channel, s.chaincode, number+1, hash, now); err != nil {
+		return false, 0, fmt.Errorf("failed to save checkpoint: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return false, 0, fmt.Errorf("failed to commit block %d: %v", number, err)
+	}
+	return true, writes, nil
+}
+
+func (s *Store) applyWrite(tx *sql.Tx, key string, w ledger.Write, block uint64, txID, writtenAt string) error {
+	if w.IsDelete {
+		for i := range tables {
+			if _, err := tx.Exec(s.rebind(fmt.Sprintf("DELETE FROM %s WHERE key = ?", tables[i].name)), key); err != nil {
+				return fmt.Errorf("failed to delete %s: %v", key, err)
+			}
+		}
+		return nil
+	}
+	t, doc := tableFor(w.Key, w.Value)
+	if t == nil {
+		return nil
+	}
+	args := []interface{}{key}
+	for _, c := range t.columns {
+		v, err := c.value(doc)
+		if err != nil {
+			return fmt.Errorf("invalid %s record %s: %v", t.name, key, err)
+		}
+		args = append(args, v)
+	}
+	args = append(args, block, txID, writtenAt, string(w.Value))
+	if _, err := tx.Exec(s.rebind(t.upsertSQL()), args...); err != nil {
+		return fmt.Errorf("failed to index %s: %v", key, err)
+	}
+	return nil
+}
+
+// Version is one write of a record to the ledger
+type Version struct {
+	Key       string
+	Table     string // the table the record is in now
+	Block     uint64
+	TxIndex   int
+	TxID      string
+	WrittenAt time.Time
+	ValueHash string // hex SHA-256 of the value written
+}
+
+// Versions returns the writes in [from, to) of the records now in the
+// named tables, in the order they were committed. Records deleted since are
+// not in any table and are left out, as are the deletes themselves.
+func (s *Store) Versions(from, to time.Time, tableNames ...string) ([]Version, error) {
+	var selects []string
+	for _, name := range tableNames {
+		if !knownTable(name) {
+			return nil, fmt.Errorf("unknown table %s", name)
+		}
+		selects = append(selects, fmt.Sprintf(`SELECT h.key, '%s', h.block, h.tx_index, h.tx_id, h.written_at, h.value_hash
+FROM key_history h JOIN %s r ON r.key = h.key
+WHERE h.written_at >= ? AND h.written_at < ? AND NOT h.deleted`, name, name))
+	}
+	if len(selects) == 0 {
+		return nil, nil
+	}
+	var args []interface{}
+	for range selects {
+		args = append(args, from.UTC().Format(timeLayout), to.UTC().Format(timeLayout))
+	}
+	rows, err := s.db.Query(s.rebind(strings.Join(selects, "\nUNION ALL\n")+"\nORDER BY 3, 4, 1"), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key history: %v", err)
+	}
+	defer rows.Close()
+
+	var versions []Version
+	for rows.Next() {
+		var v Version
+		var writtenAt string
+		if err := rows.Scan(&v.Key, &v.Table, &v.Block, &v.TxIndex, &v.TxID, &writtenAt, &v.ValueHash); err != nil {
+			return nil, fmt.Errorf("failed to read key history: %v", err)
+		}
+		if v.ValueHash == "" {
+			return nil, fmt.Errorf("key history of %s at block %d has no value hash; rebuild the index", v.Key, v.Block)
+		}
+		if v.WrittenAt, err = time.Parse(timeLayout, writtenAt); err != nil {
+			return nil, fmt.Errorf("invalid write time of %s at block %d: %v", v.Key, v.Block, err)
+		}
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
+// LastWrite returns the time of the latest indexed write, zero for an
+// empty index
+func (s *Store) LastWrite() (time.Time, error) {
+	var last sql.NullString
+	if err := s.db.QueryRow("SELECT MAX(written_at) FROM key_history").Scan(&last); err != nil {
+		return time.Time{}, fmt.Errorf("failed to read key history: %v", err)
+	}
+	if !last.Valid {
+		return time.Time{}, nil
+	}
+	return time.Parse(timeLayout, last.String)
+}
+
+func knownTable(name string) bool {
+	for i := range tables {
+		if tables[i].name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Reset empties the index, checkpoint included, so that it can be rebuilt
+// from the genesis block. The bank's loan_branches table is kept.
+func (s *Store) Reset() error {
+	var stmts []string
+	for _, v := range views {
+		stmts = append(stmts, "DROP VIEW IF EXISTS "+v.name)
+	}
+	for i := range tables {
+		stmts = append(stmts, "DROP TABLE IF EXISTS "+tables[i].name)
+	}
+	stmts = append(stmts, "DROP TABLE IF EXISTS key_history", "DROP TABLE IF EXISTS index_checkpoint")
+	for _, stmt := range stmts {
+		if _, err := s.db.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to reset index: %v", err)
+		}
+	}
+	return s.migrate()
+}
@@ -0,0 +1,187 @@
+// Package ledger decodes blocks delivered by the peer into the chaincode
+// events and state writes of their valid transactions.
+package ledger
+
+import (
+	"crypto/sha256"
+	"encoding/asn1"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/hyperledger/fabric-protos-go-apiv2/common"
+	"github.com/hyperledger/fabric-protos-go-apiv2/ledger/rwset"
+	"github.com/hyperledger/fabric-protos-go-apiv2/ledger/rwset/kvrwset"
+	"github.com/hyperledger/fabric-protos-go-apiv2/peer"
+	"google.golang.org/protobuf/proto"
+)
+
+// Event is a chaincode event together with where it was committed
+type Event struct {
+	BlockNumber   uint64
+	TxIndex       int
+	TxID          string
+	ChaincodeName string
+	EventName     string
+	Payload       []byte
+}
+
+// ChaincodeEvents returns the events set by valid endorser transactions in block
+func ChaincodeEvents(block *common.Block) ([]Event, error) {
+	var events []Event
+	err := endorserTransactions(block, func(i int, header *common.ChannelHeader, action *peer.ChaincodeAction) error {
+		if len(action.GetEvents()) == 0 {
+			return nil
+		}
+		var ev peer.ChaincodeEvent
+		if err := proto.Unmarshal(action.GetEvents(), &ev); err != nil {
+			return fmt.Errorf("invalid chaincode event: %v", err)
+		}
+		if ev.GetEventName() == "" {
+			return nil
+		}
+		events = append(events, Event{
+			BlockNumber:   block.GetHeader().GetNumber(),
+			TxIndex:       i,
+			TxID:          header.GetTxId(),
+			ChaincodeName: ev.GetChaincodeId(),
+			EventName:     ev.GetEventName(),
+			Payload:       ev.GetPayload(),
+		})
+		return nil
+	})
+	return events, err
+}
+
+// Write is one key written to the public state of a chaincode
+type Write struct {
+	Key      string
+	Value    []byte
+	IsDelete bool
+}
+
+// TxWrites is what one valid transaction wrote to a chaincode's public state
+type TxWrites struct {
+	BlockNumber uint64
+	TxIndex     int
+	TxID        string
+	Timestamp   time.Time
+	Writes      []Write
+}
+
+// NamespaceWrites returns the public state writes that valid endorser
+// transactions in block made to namespace, in block order. Private data
+// writes are not in the block and are not returned.
+func NamespaceWrites(block *common.Block, namespace string) ([]TxWrites, error) {
+	var txs []TxWrites
+	err := endorserTransactions(block, func(i int, header *common.ChannelHeader, action *peer.ChaincodeAction) error {
+		var set rwset.TxReadWriteSet
+		if err := proto.Unmarshal(action.GetResults(), &set); err != nil {
+			return fmt.Errorf("invalid read-write set: %v", err)
+		}
+		var writes []Write
+		for _, ns := range set.GetNsRwset() {
+			if ns.GetNamespace() != namespace {
+				continue
+			}
+			var kv kvrwset.KVRWSet
+			if err := proto.Unmarshal(ns.GetRwset(), &kv); err != nil {
+				return fmt.Errorf("invalid %s read-write set: %v", namespace, err)
+			}
+			for _, w := range kv.GetWrites() {
+				writes = append(writes, Write{Key: w.GetKey(), Value: w.GetValue(), IsDelete: w.GetIsDelete()})
+			}
+		}
+		if len(writes) == 0 {
+			return nil
+		}
+		txs = append(txs, TxWrites{
+			BlockNumber: block.GetHeader().GetNumber(),
+			TxIndex:     i,
+			TxID:        header.GetTxId(),
+			Timestamp:   header.GetTimestamp().AsTime(),
+			Writes:      writes,
+		})
+		return nil
+	})
+	return txs, err
+}
+
+// endorserTransactions calls fn with the chaincode action of each action of
+// every valid endorser transaction in block
+func endorserTransactions(block *common.Block, fn func(i int, header *common.ChannelHeader, action *peer.ChaincodeAction) error) error {
+	number := block.GetHeader().GetNumber()
+	flags := block.GetMetadata().GetMetadata()
+	var filter []byte
+	if len(flags) > int(common.BlockMetadataIndex_TRANSACTIONS_FILTER) {
+		filter = flags[common.BlockMetadataIndex_TRANSACTIONS_FILTER]
+	}
+
+	for i, data := range block.GetData().GetData() {
+		if i < len(filter) && peer.TxValidationCode(filter[i]) != peer.TxValidationCode_VALID {
+			continue
+		}
+		var env common.Envelope
+		if err := proto.Unmarshal(data, &env); err != nil {
+			return fmt.Errorf("block %d tx %d: invalid envelope: %v", number, i, err)
+		}
+		var payload common.Payload
+		if err := proto.Unmarshal(env.GetPayload(), &payload); err != nil {
+			return fmt.Errorf("block %d tx %d: invalid payload: %v", number, i, err)
+		}
+		var header common.ChannelHeader
+		if err := proto.Unmarshal(payload.GetHeader().GetChannelHeader(), &header); err != nil {
+			return fmt.Errorf("block %d tx %d: invalid channel header: %v", number, i, err)
+		}
+		if header.GetType() != int32(common.HeaderType_ENDORSER_TRANSACTION) {
+			continue
+		}
+		var tx peer.Transaction
+		if err := proto.Unmarshal(payload.GetData(), &tx); err != nil {
+			return fmt.Errorf("block %d tx %s: invalid transaction: %v", number, header.GetTxId(), err)
+		}
+		for _, action := range tx.GetActions() {
+			ccAction, err := chaincodeAction(action)
+			if err == nil {
+				err = fn(i, &header, ccAction)
+			}
+			if err != nil {
+				return fmt.Errorf("block %d tx %s: %v", number, header.GetTxId(), err)
+			}
+		}
+	}
+	return nil
+}
+
+func chaincodeAction(action *peer.TransactionAction) (*peer.ChaincodeAction, error) {
+	var actionPayload peer.ChaincodeActionPayload
+	if err := proto.Unmarshal(action.GetPayload(), &actionPayload); err != nil {
+		return nil, fmt.Errorf("invalid action payload: %v", err)
+	}
+	var responsePayload peer.ProposalResponsePayload
+	if err := proto.Unmarshal(actionPayload.GetAction().GetProposalResponsePayload(), &responsePayload); err != nil {
+		return nil, fmt.Errorf("invalid proposal response payload: %v", err)
+	}
+	var ccAction peer.ChaincodeAction
+	if err := proto.Unmarshal(responsePayload.GetExtension(), &ccAction); err != nil {
+		return nil, fmt.Errorf("invalid chaincode action: %v", err)
+	}
+	return &ccAction, nil
+}
+
+// HeaderHash returns the hash of a block header, which the next block
+// carries as its previous hash. Fabric hashes the ASN.1 DER encoding of the
+// header's number, previous hash and data hash.
+func HeaderHash(header *common.BlockHeader) []byte {
+	der, err := asn1.Marshal(struct {
+		Number       *big.Int
+		PreviousHash []byte
+		DataHash     []byte
+	}{new(big.Int).SetUint64(header.GetNumber()), header.GetPreviousHash(), header.GetDataHash()})
+	if err != nil {
+		// The structure has no field asn1 cannot encode
+		panic(err)
+	}
+	sum := sha256.Sum256(der)
+	return sum[:]
+}
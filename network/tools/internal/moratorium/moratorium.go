@@ -0,0 +1,66 @@
+// Package moratorium records recovery actions that iu-chaincode refused
+// because the debtor is under an insolvency moratorium. A refused
+// transaction fails endorsement, which discards everything it wrote, so
+// the blocked attempt never reaches the ledger. The REST gateway and iuctl
+// recognise the chaincode's error and append the attempt to a JSON Lines
+// file instead.
+package moratorium
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// blockedPrefix starts every error iu-chaincode returns for an action
+// refused under a moratorium
+const blockedPrefix = "moratorium in force for debtor "
+
+// Blocked reports whether msg is the chaincode refusing an action under a
+// moratorium
+func Blocked(msg string) bool {
+	return strings.Contains(msg, blockedPrefix)
+}
+
+// Attempt is one blocked recovery action
+type Attempt struct {
+	Time           time.Time `json:"time"`
+	Source         string    `json:"source"` // "iu-rest" or "iuctl"
+	Client         string    `json:"client"` // REST API client or local user
+	Org            string    `json:"org"`    // org profile that signed the proposal
+	Function       string    `json:"function"`
+	Args           []string  `json:"args"`
+	CourtOrderHash string    `json:"courtOrderHash,omitempty"`
+	Error          string    `json:"error"`
+}
+
+// Log appends blocked attempts to a JSON Lines file
+type Log struct {
+	Path string
+	mu   sync.Mutex
+}
+
+// Record appends a to the file
+func (l *Log) Record(a Attempt) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(l.Path), 0o700); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(l.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, err := file.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	return file.Sync()
+}
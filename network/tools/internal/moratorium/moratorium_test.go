@@ -0,0 +1,43 @@
+package moratorium
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBlocked(t *testing.T) {
+	for msg, want := range map[string]bool{
+		"moratorium in force for debtor D1 under case IC1 (NCLT1) since 2024-01-01: DEBIT_TRANSACTION is not permitted": true,
+		"moratorium in force for debtor D1 under case IC1: only AdminMSP can override with a court order":               true,
+		"endorse failed (chaincode response 500, moratorium in force for debtor D1 under case IC1)":                     true,
+		"transaction TX1 already exists": false,
+	} {
+		if got := Blocked(msg); got != want {
+			t.Errorf("Blocked(%q) = %v, want %v", msg, got, want)
+		}
+	}
+}
+
+func TestRecord(t *testing.T) {
+	l := &Log{Path: filepath.Join(t.TempDir(), "iuctl", "blocked.jsonl")}
+	for _, fn := range []string{"CreateTransaction", "FileDefault"} {
+		if err := l.Record(Attempt{Source: "iuctl", Org: "creditor", Function: fn, Error: "moratorium in force for debtor D1"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b, err := os.ReadFile(l.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("%d lines, want 2", len(lines))
+	}
+	var a Attempt
+	if err := json.Unmarshal([]byte(lines[1]), &a); err != nil || a.Function != "FileDefault" || a.Org != "creditor" {
+		t.Fatalf("unexpected attempt %+v (%v)", a, err)
+	}
+}
@@ -0,0 +1,174 @@
+// Package output prints chaincode results as JSON, an aligned table or CSV
+// for iuctl. Results are JSON; an array of objects becomes one row per
+// element and a single object one row, with columns in the order the
+// chaincode wrote the fields. Nested values are shown as compact JSON.
+package output
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// Formats supported by Write
+const (
+	JSON  = "json"
+	Table = "table"
+	CSV   = "csv"
+)
+
+// Check reports an unsupported format
+func Check(format string) error {
+	switch format {
+	case JSON, Table, CSV:
+		return nil
+	}
+	return fmt.Errorf("unsupported output %q (want json, table or csv)", format)
+}
+
+// Write prints the JSON result in format
+func Write(w io.Writer, format string, result []byte) error {
+	if err := Check(format); err != nil {
+		return err
+	}
+	result = bytes.TrimSpace(result)
+	if len(result) == 0 {
+		return nil
+	}
+	if !json.Valid(result) {
+		// Plain string results, such as InvokeGuarantee's key
+		result, _ = json.Marshal(string(result))
+	}
+	if format == JSON {
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, result, "", "  "); err != nil {
+			return err
+		}
+		buf.WriteByte('\n')
+		_, err := w.Write(buf.Bytes())
+		return err
+	}
+
+	columns, rows, err := tabulate(result)
+	if err != nil {
+		return err
+	}
+	if format == CSV {
+		cw := csv.NewWriter(w)
+		cw.Write(columns)
+		cw.WriteAll(rows)
+		return cw.Error()
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.ToUpper(strings.Join(columns, "\t")))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+// object is a JSON object with its keys in document order
+type object struct {
+	keys   []string
+	values map[string]json.RawMessage
+}
+
+func (o *object) UnmarshalJSON(b []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+	o.values = map[string]json.RawMessage{}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key := tok.(string)
+		var v json.RawMessage
+		if err := dec.Decode(&v); err != nil {
+			return err
+		}
+		if _, dup := o.values[key]; !dup {
+			o.keys = append(o.keys, key)
+		}
+		o.values[key] = v
+	}
+	return nil
+}
+
+func tabulate(result []byte) ([]string, [][]string, error) {
+	var objects []object
+	switch result[0] {
+	case '[':
+		var items []json.RawMessage
+		if err := json.Unmarshal(result, &items); err != nil {
+			return nil, nil, err
+		}
+		for _, item := range items {
+			if len(item) == 0 || item[0] != '{' {
+				// An array of scalars is one column
+				rows := make([][]string, len(items))
+				for i, it := range items {
+					rows[i] = []string{cell(it)}
+				}
+				return []string{"value"}, rows, nil
+			}
+			var o object
+			if err := json.Unmarshal(item, &o); err != nil {
+				return nil, nil, err
+			}
+			objects = append(objects, o)
+		}
+	case '{':
+		var o object
+		if err := json.Unmarshal(result, &o); err != nil {
+			return nil, nil, err
+		}
+		objects = append(objects, o)
+	default:
+		return []string{"value"}, [][]string{{cell(result)}}, nil
+	}
+
+	var columns []string
+	seen := map[string]bool{}
+	for _, o := range objects {
+		for _, k := range o.keys {
+			if !seen[k] {
+				seen[k] = true
+				columns = append(columns, k)
+			}
+		}
+	}
+	rows := make([][]string, len(objects))
+	for i, o := range objects {
+		row := make([]string, len(columns))
+		for j, c := range columns {
+			row[j] = cell(o.values[c])
+		}
+		rows[i] = row
+	}
+	return columns, rows, nil
+}
+
+// cell renders a JSON value: strings unquoted, null empty, anything else compact
+func cell(v json.RawMessage) string {
+	if len(v) == 0 || string(v) == "null" {
+		return ""
+	}
+	if v[0] == '"' {
+		var s string
+		if json.Unmarshal(v, &s) == nil {
+			return s
+		}
+	}
+	var buf bytes.Buffer
+	if json.Compact(&buf, v) != nil {
+		return string(v)
+	}
+	return buf.String()
+}
@@ -0,0 +1,48 @@
+package output
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const docs = `[{"docId":"D1","loanId":"L1","size":10,"tags":["a","b"]},{"docId":"D2","loanId":"L1","size":20,"extra":null}]`
+
+func TestCSVKeepsFieldOrder(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, CSV, []byte(docs)); err != nil {
+		t.Fatal(err)
+	}
+	want := "docId,loanId,size,tags,extra\nD1,L1,10,\"[\"\"a\"\",\"\"b\"\"]\",\nD2,L1,20,,\n"
+	if buf.String() != want {
+		t.Fatalf("got\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestTable(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, Table, []byte(`{"kycId":"K1","status":"APPROVED"}`)); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "KYCID") || !strings.Contains(lines[1], "APPROVED") {
+		t.Fatalf("unexpected table:\n%s", buf.String())
+	}
+}
+
+func TestScalarsAndPlainStrings(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, CSV, []byte("GUARANTEE_G1")); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "value\nGUARANTEE_G1\n" {
+		t.Fatalf("got %q", buf.String())
+	}
+	buf.Reset()
+	if err := Write(&buf, JSON, []byte(`30`)); err != nil || buf.String() != "30\n" {
+		t.Fatalf("got %q, %v", buf.String(), err)
+	}
+	if err := Write(&buf, "yaml", []byte(`1`)); err == nil {
+		t.Fatal("expected error for unsupported format")
+	}
+}
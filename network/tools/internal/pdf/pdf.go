@@ -0,0 +1,156 @@
+// Package pdf writes simple text-only PDF documents. Output contains no
+// timestamps or random identifiers, so the same input always yields the
+// same bytes.
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Font selects one of the standard Type 1 fonts
+type Font int
+
+// Fonts available to lines
+const (
+	Regular Font = iota
+	Bold
+	Mono
+)
+
+// Line is one paragraph of text; long text is wrapped to the page width
+type Line struct {
+	Text string
+	Font Font
+	Size float64
+}
+
+const (
+	pageWidth  = 595.0 // A4 in points
+	pageHeight = 842.0
+	margin     = 50.0
+)
+
+var fontNames = []string{"Helvetica", "Helvetica-Bold", "Courier"}
+
+// Render lays out lines on A4 pages and returns the PDF bytes
+func Render(title string, lines []Line) []byte {
+	pages := layout(lines)
+
+	var objects []string
+	objects = append(objects, "<< /Type /Catalog /Pages 2 0 R >>")
+	objects = append(objects, "") // pages tree, filled once page ids are known
+	for _, name := range fontNames {
+		objects = append(objects, fmt.Sprintf("<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>", name))
+	}
+	objects = append(objects, fmt.Sprintf("<< /Title (%s) /Producer (IU Evidence Tools) >>", escape(title)))
+	infoID := len(objects)
+
+	var kids []string
+	for _, content := range pages {
+		pageID := len(objects) + 1
+		contentID := pageID + 1
+		kids = append(kids, fmt.Sprintf("%d 0 R", pageID))
+		objects = append(objects, fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] "+
+			"/Resources << /Font << /F1 3 0 R /F2 4 0 R /F3 5 0 R >> >> /Contents %d 0 R >>", pageWidth, pageHeight, contentID))
+		objects = append(objects, fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content))
+	}
+	objects[1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(kids))
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, infoID, xref)
+	return buf.Bytes()
+}
+
+// layout wraps lines and splits them into page content streams
+func layout(lines []Line) []string {
+	var pages []string
+	var page bytes.Buffer
+	y := pageHeight - margin
+	for _, line := range lines {
+		size := line.Size
+		if size == 0 {
+			size = 10
+		}
+		leading := size * 1.4
+		for _, text := range wrap(line.Text, maxChars(line.Font, size)) {
+			if y-leading < margin {
+				pages = append(pages, page.String())
+				page.Reset()
+				y = pageHeight - margin
+			}
+			y -= leading
+			fmt.Fprintf(&page, "BT /F%d %.1f Tf %.1f %.1f Td (%s) Tj ET\n", int(line.Font)+1, size, margin, y, escape(text))
+		}
+	}
+	return append(pages, page.String())
+}
+
+// maxChars estimates how many characters fit on a line for the font
+func maxChars(font Font, size float64) int {
+	avg := 0.5 * size // Helvetica average glyph width
+	if font == Mono {
+		avg = 0.6 * size
+	}
+	return int((pageWidth - 2*margin) / avg)
+}
+
+func wrap(text string, width int) []string {
+	if text == "" {
+		return []string{""}
+	}
+	var out []string
+	for _, para := range strings.Split(text, "\n") {
+		line := ""
+		for _, word := range strings.Fields(para) {
+			for len(word) > width {
+				if line != "" {
+					out = append(out, line)
+					line = ""
+				}
+				out = append(out, word[:width])
+				word = word[width:]
+			}
+			switch {
+			case line == "":
+				line = word
+			case len(line)+1+len(word) <= width:
+				line += " " + word
+			default:
+				out = append(out, line)
+				line = word
+			}
+		}
+		out = append(out, line)
+	}
+	return out
+}
+
+// escape makes text safe inside a PDF literal string, replacing non-ASCII runes
+func escape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '\\' || r == '(' || r == ')':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 32 || r > 126:
+			b.WriteByte('?')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
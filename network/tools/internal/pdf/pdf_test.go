@@ -0,0 +1,59 @@
+package pdf
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+func TestRender(t *testing.T) {
+	lines := []Line{
+		{Text: "Certificate (draft)", Font: Bold, Size: 16},
+		{Text: strings.Repeat("wrapped words ", 60)},
+		{Text: strings.Repeat("0123456789abcdef", 8), Font: Mono, Size: 8},
+		{Text: `back\slash and non-ASCII ₹`},
+	}
+	for i := 0; i < 80; i++ {
+		lines = append(lines, Line{Text: "filler line to force a second page"})
+	}
+	b := Render("Test (1)", lines)
+	if again := Render("Test (1)", lines); !bytes.Equal(b, again) {
+		t.Fatal("output is not reproducible")
+	}
+	if !bytes.Contains(b, []byte("/Count 2")) {
+		t.Error("expected two pages")
+	}
+	golden(t, "testdata/render.pdf", b)
+}
+
+func TestWrap(t *testing.T) {
+	got := wrap("aaa bbb ccccccccc", 5)
+	want := []string{"aaa", "bbb", "ccccc", "cccc"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("wrap = %q, want %q", got, want)
+	}
+	if got := escape(`a(b)\c₹`); got != `a\(b\)\\c?` {
+		t.Errorf("escape = %q", got)
+	}
+}
+
+// golden compares b with the file at path, rewriting it under -update
+func golden(t *testing.T, path string, b []byte) {
+	t.Helper()
+	if *update {
+		if err := os.WriteFile(path, b, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, want) {
+		t.Errorf("output differs from %s; rerun with -update if the change is intended", path)
+	}
+}
@@ -0,0 +1,148 @@
+package regreport
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"iu-tools/internal/indexer"
+)
+
+// Load reads the defaults, transactions and documents in the index
+func Load(store *indexer.Store) (*Ledger, error) {
+	cp, err := store.Checkpoint()
+	if err != nil {
+		return nil, err
+	}
+	l := &Ledger{Channel: store.Channel(), IndexedToBlock: cp.NextBlock}
+	for _, t := range []struct {
+		table string
+		into  interface{}
+	}{
+		{"defaults", &l.Defaults},
+		{"transactions", &l.Transactions},
+		{"documents", &l.Documents},
+	} {
+		rows, err := store.DB().Query(fmt.Sprintf("SELECT doc FROM %s ORDER BY key", t.table))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", t.table, err)
+		}
+		var docs []string
+		for rows.Next() {
+			var doc string
+			if err := rows.Scan(&doc); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to read %s: %v", t.table, err)
+			}
+			docs = append(docs, doc)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", t.table, err)
+		}
+		if err := json.Unmarshal([]byte("["+strings.Join(docs, ",")+"]"), t.into); err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", t.table, err)
+		}
+	}
+	return l, nil
+}
+
+// SignatureType is the document type of a return's detached signature
+const SignatureType = "IU_REGULATORY_RETURN"
+
+// AnchorEvent is the audit event type of an anchored return
+const AnchorEvent = "REGULATORY_RETURN"
+
+// AnchorMSP is the IU org, the only one that signs returns and that the
+// contract lets record REGULATORY_RETURN events
+const AnchorMSP = "AdminMSP"
+
+// Submitter submits transactions to the contract a return is anchored on
+type Submitter interface {
+	SubmitTransaction(name string, args ...string) ([]byte, error)
+}
+
+// File is an output file of a return and its hex SHA-256
+type File struct {
+	Name   string
+	SHA256 string
+}
+
+// Anchor records digest, the SHA-256 of the return's canonical JSON as its
+// signature carries it, through RecordAuditEvent. The details carry the
+// hashes of the other files so that each can be checked against the anchor.
+func Anchor(s Submitter, r *Report, digest string, files []File) (string, error) {
+	ref := Ref(r)
+	var parts []string
+	for _, f := range files {
+		parts = append(parts, fmt.Sprintf("%s sha256 %s", f.Name, f.SHA256))
+	}
+	details := fmt.Sprintf("%s return %s to %s, indexed to block %d: %s",
+		r.Regulator, r.From, r.To, r.IndexedToBlock, strings.Join(parts, "; "))
+	if _, err := s.SubmitTransaction("RecordAuditEvent", AnchorEvent, ref, digest, details, "", "0"); err != nil {
+		return "", fmt.Errorf("failed to anchor %s: %w", ref, err)
+	}
+	return ref, nil
+}
+
+// Evaluator evaluates queries against the contract a return is anchored on
+type Evaluator interface {
+	EvaluateTransaction(name string, args ...string) ([]byte, error)
+}
+
+// AnchorRecord is the part of an anchoring audit record that verification reads
+type AnchorRecord struct {
+	ID        string `json:"id"`
+	Action    string `json:"action"`
+	Actor     string `json:"actor"`
+	Timestamp string `json:"timestamp"`
+	Details   string `json:"details"`
+	Hash      string `json:"hash"`
+}
+
+// CheckAnchor finds the anchoring of ref with digest by signerMSPID, the
+// org whose signature on the return was verified, which must be AnchorMSP.
+// A return regenerated for the same period is anchored again under the same
+// reference, so any of its anchors may match. Each of files must be named in
+// the details of the matching anchor with its hash.
+func CheckAnchor(e Evaluator, ref, digest, signerMSPID string, files []File) (*AnchorRecord, error) {
+	if signerMSPID != AnchorMSP {
+		return nil, fmt.Errorf("return is signed by %s, but only %s signs returns", signerMSPID, AnchorMSP)
+	}
+	b, err := e.EvaluateTransaction("GetAuditTrail", ref)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the audit trail of %s: %w", ref, err)
+	}
+	var trail []AnchorRecord
+	if err := json.Unmarshal(b, &trail); err != nil {
+		return nil, fmt.Errorf("invalid audit trail: %v", err)
+	}
+	var anchored, others []string
+	for i := range trail {
+		a := &trail[i]
+		if a.Action != AnchorEvent {
+			continue
+		}
+		if a.Actor != signerMSPID {
+			others = append(others, fmt.Sprintf("%s by %s", a.ID, a.Actor))
+			continue
+		}
+		if a.Hash != digest {
+			anchored = append(anchored, a.Hash)
+			continue
+		}
+		for _, f := range files {
+			if !strings.Contains(a.Details, fmt.Sprintf("sha256 %s", f.SHA256)) {
+				return nil, fmt.Errorf("%s does not match the hash anchored with the return in %s", f.Name, a.ID)
+			}
+		}
+		return a, nil
+	}
+	if len(anchored) == 0 && len(others) > 0 {
+		return nil, fmt.Errorf("no return is anchored as %s by %s, only %s", ref, signerMSPID, strings.Join(others, ", "))
+	}
+	if len(anchored) == 0 {
+		return nil, fmt.Errorf("no return is anchored as %s", ref)
+	}
+	return nil, fmt.Errorf("return does not match %s: anchored hashes %s, return hash %s", ref, strings.Join(anchored, ", "), digest)
+}
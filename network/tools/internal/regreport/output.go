@@ -0,0 +1,112 @@
+package regreport
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"iu-tools/internal/xlsx"
+)
+
+// section is one table of a return, a sheet in XLSX and a block in CSV
+type section struct {
+	title string
+	rows  [][]interface{} // the first row is the header
+}
+
+func (r *Report) sections() []section {
+	s := r.Summary
+	t := r.Turnaround
+	summary := section{"Summary", [][]interface{}{
+		{"Measure", "Value"},
+		{"Regulator", r.Regulator},
+		{"Period from", r.From},
+		{"Period to", r.To},
+		{"Channel", r.Channel},
+		{"Indexed to block", r.IndexedToBlock},
+		{"Defaults filed", s.DefaultsFiled},
+		{"Authenticated", s.Authenticated},
+		{"Deemed authenticated", s.DeemedAuthenticated},
+		{"Disputed", s.Disputed},
+		{"Pending authentication", s.Pending},
+		{"Transactions submitted", s.TransactionsSubmitted},
+		{"Documents submitted", s.DocumentsSubmitted},
+		{"Creditors", s.Creditors},
+	}}
+	status := section{"Authentication status", [][]interface{}{{"Status", "Currency", "Count", "Amount"}}}
+	for _, c := range r.AuthStatus {
+		status.rows = append(status.rows, []interface{}{c.Status, c.Currency, c.Count, c.Amount})
+	}
+	turnaround := section{"Turnaround", [][]interface{}{
+		{"Measure", "Value"},
+		{"Window (days)", t.WindowDays},
+		{"Debtor responses", t.Responses},
+		{"Responses within window", t.WithinWindow},
+		{"Mean days to respond", t.MeanDays},
+		{"Median days to respond", t.MedianDays},
+		{"Longest days to respond", t.MaxDays},
+		{"Deemed authenticated", t.DeemedAuthenticated},
+		{"Pending past window", t.PendingPastWindow},
+	}}
+	disputes := section{"Disputes", [][]interface{}{{"Default", "Loan", "Creditor", "Debtor", "Amount", "Currency", "Filed at", "Responded at", "Reason", "Evidence documents"}}}
+	for _, d := range r.Disputes {
+		disputes.rows = append(disputes.rows, []interface{}{d.DefaultID, d.LoanID, d.CreditorID, d.DebtorID, d.Amount, d.Currency, d.FiledAt, d.RespondedAt, d.Reason, d.EvidenceCount})
+	}
+	creditors := section{"Creditors", [][]interface{}{{"Creditor", "Currency", "Transactions", "Transaction amount", "Defaults filed", "Default amount", "Disputed"}}}
+	for _, c := range r.Creditors {
+		creditors.rows = append(creditors.rows, []interface{}{c.CreditorID, c.Currency, c.Transactions, c.TransactionAmount, c.DefaultsFiled, c.DefaultAmount, c.Disputed})
+	}
+	return []section{summary, status, turnaround, disputes, creditors}
+}
+
+// JSON returns the return as indented JSON
+func (r *Report) JSON() ([]byte, error) {
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+// CSV returns the return's sections one after another, each headed by a
+// line with its title and separated by a blank line
+func (r *Report) CSV() ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	for i, s := range r.sections() {
+		if i > 0 {
+			w.Write(nil)
+		}
+		w.Write([]string{"# " + s.title})
+		for _, row := range s.rows {
+			record := make([]string, len(row))
+			for j, v := range row {
+				record[j] = cell(v)
+			}
+			w.Write(record)
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+func cell(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+// XLSX returns the return as a workbook with a sheet per section
+func (r *Report) XLSX() ([]byte, error) {
+	var sheets []xlsx.Sheet
+	for _, s := range r.sections() {
+		sheets = append(sheets, xlsx.Sheet{Name: s.title, Rows: s.rows})
+	}
+	return xlsx.Write(sheets)
+}
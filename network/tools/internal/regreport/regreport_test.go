@@ -0,0 +1,218 @@
+package regreport
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"iu-tools/internal/indexer"
+)
+
+func at(s string) time.Time {
+	t, _ := time.Parse(time.RFC3339, s)
+	return t
+}
+
+func day(s string) time.Time {
+	t, _ := time.Parse(dateLayout, s)
+	return t
+}
+
+var testLedger = &Ledger{
+	Channel:        "financial-operations-channel",
+	IndexedToBlock: 42,
+	Defaults: []Default{
+		{ID: "DEF1", CreditorID: "C1", DebtorID: "D1", Amount: 40000, Currency: "INR", FiledAt: at("2024-04-02T10:00:00Z"), AuthStatus: Authenticated, RespondedAt: at("2024-04-05T10:00:00Z")},
+		{ID: "DEF2", LoanID: "L2", CreditorID: "C1", DebtorID: "D2", Amount: 5000, Currency: "INR", FiledAt: at("2024-05-01T00:00:00Z"), AuthStatus: Disputed, RespondedAt: at("2024-06-10T00:00:00Z"), DisputeReason: "repaid in full", EvidenceDocIDs: []string{"DOC1", "DOC2"}},
+		{ID: "DEF3", CreditorID: "C2", DebtorID: "D3", Amount: 100, Currency: "USD", FiledAt: at("2024-05-10T00:00:00Z"), AuthStatus: DeemedAuthenticated, RespondedAt: at("2024-06-10T00:00:00Z")},
+		{ID: "DEF4", CreditorID: "C2", DebtorID: "D4", Amount: 7000, Currency: "INR", FiledAt: at("2024-05-20T00:00:00Z"), AuthStatus: Pending},
+		{ID: "DEF5", CreditorID: "C2", DebtorID: "D5", Amount: 9000, Currency: "INR", FiledAt: at("2024-06-30T23:00:00Z"), AuthStatus: Pending},
+		{ID: "DEF6", CreditorID: "C1", DebtorID: "D1", Amount: 1, Currency: "INR", FiledAt: at("2024-07-01T00:00:00Z"), AuthStatus: Pending}, // after the period
+	},
+	Transactions: []Transaction{
+		{ID: "T1", CreditorID: "C1", Amount: 500000, Currency: "INR", Timestamp: at("2024-04-01T00:00:00Z")},
+		{ID: "T2", CreditorID: "C1", Amount: 100000.25, Currency: "INR", Timestamp: at("2024-06-30T12:00:00Z")},
+		{ID: "T3", CreditorID: "C3", Amount: 1, Currency: "INR", Timestamp: at("2024-03-31T23:59:59Z")},
+	},
+	Documents: []Document{
+		{DocID: "DOC1", OwnerOrg: "DebtorMSP", UploadedAt: at("2024-06-09T00:00:00Z")},
+		{DocID: "DOC0", OwnerOrg: "CreditorMSP", UploadedAt: at("2024-01-01T00:00:00Z")},
+	},
+}
+
+func TestBuild(t *testing.T) {
+	r, err := Build(IBBI, day("2024-04-01"), day("2024-06-30"), 30, testLedger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Summary{DefaultsFiled: 5, Authenticated: 1, DeemedAuthenticated: 1, Disputed: 1, Pending: 2, TransactionsSubmitted: 2, DocumentsSubmitted: 1, Creditors: 2}
+	if r.Summary != want {
+		t.Fatalf("summary %+v, want %+v", r.Summary, want)
+	}
+	// DEF1 answered in 3 days, DEF2 in 40; DEF4 is past the window, DEF5 is not
+	wantTurnaround := Turnaround{WindowDays: 30, Responses: 2, WithinWindow: 1, MeanDays: 21.5, MedianDays: 21.5, MaxDays: 40, DeemedAuthenticated: 1, PendingPastWindow: 1}
+	if r.Turnaround != wantTurnaround {
+		t.Fatalf("turnaround %+v, want %+v", r.Turnaround, wantTurnaround)
+	}
+	if len(r.AuthStatus) != 4 || r.AuthStatus[3] != (StatusCount{Status: Pending, Currency: "INR", Count: 2, Amount: 16000}) {
+		t.Fatalf("status breakdown %+v", r.AuthStatus)
+	}
+	if len(r.Disputes) != 1 || r.Disputes[0].Reason != "repaid in full" || r.Disputes[0].EvidenceCount != 2 || r.Disputes[0].RespondedAt != "2024-06-10T00:00:00Z" {
+		t.Fatalf("disputes %+v", r.Disputes)
+	}
+	c1 := CreditorReturn{CreditorID: "C1", Currency: "INR", Transactions: 2, TransactionAmount: 600000.25, DefaultsFiled: 2, DefaultAmount: 45000, Disputed: 1}
+	if len(r.Creditors) != 3 || r.Creditors[0] != c1 || r.Creditors[1].Currency != "INR" || r.Creditors[2].Currency != "USD" {
+		t.Fatalf("creditors %+v", r.Creditors)
+	}
+	if Ref(r) != "RETURN_IBBI_20240401_20240630" {
+		t.Fatalf("ref %s", Ref(r))
+	}
+
+	for _, c := range []struct {
+		regulator string
+		from, to  string
+		window    int
+	}{{"SEBI", "2024-04-01", "2024-06-30", 30}, {RBI, "2024-06-30", "2024-04-01", 30}, {RBI, "2024-04-01", "2024-06-30", 0}} {
+		if _, err := Build(c.regulator, day(c.from), day(c.to), c.window, testLedger); err == nil {
+			t.Errorf("Build(%s, %s, %s, %d) succeeded", c.regulator, c.from, c.to, c.window)
+		}
+	}
+}
+
+func TestOutputs(t *testing.T) {
+	r, err := Build(RBI, day("2024-04-01"), day("2024-06-30"), 30, testLedger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := r.CSV()
+	if err != nil {
+		t.Fatal(err)
+	}
+	csv := string(b)
+	for _, want := range []string{
+		"# Summary\nMeasure,Value\nRegulator,RBI\n",
+		"Defaults filed,5\n",
+		"\n\n# Authentication status\nStatus,Currency,Count,Amount\n",
+		"DEF2,L2,C1,D2,5000,INR,2024-05-01T00:00:00Z,2024-06-10T00:00:00Z,repaid in full,2\n",
+		"C1,INR,2,600000.25,2,45000,1\n",
+	} {
+		if !strings.Contains(csv, want) {
+			t.Errorf("CSV lacks %q:\n%s", want, csv)
+		}
+	}
+
+	x1, err := r.XLSX()
+	if err != nil {
+		t.Fatal(err)
+	}
+	x2, _ := r.XLSX()
+	j1, _ := r.JSON()
+	j2, _ := r.JSON()
+	if string(x1) != string(x2) || string(j1) != string(j2) {
+		t.Fatal("outputs are not reproducible")
+	}
+	var back Report
+	if err := json.Unmarshal(j1, &back); err != nil || back.Summary != r.Summary || back.IndexedToBlock != 42 {
+		t.Fatalf("JSON round trip: %v %+v", err, back)
+	}
+}
+
+// fakeAudit records anchors as actor, AnchorMSP by default, and answers
+// GetAuditTrail with them
+type fakeAudit struct {
+	actor   string
+	records []AnchorRecord
+}
+
+func (f *fakeAudit) SubmitTransaction(name string, args ...string) ([]byte, error) {
+	if name != "RecordAuditEvent" || len(args) != 6 || args[4] != "" || args[5] != "0" {
+		return nil, fmt.Errorf("unexpected %s %q", name, args)
+	}
+	actor := f.actor
+	if actor == "" {
+		actor = AnchorMSP
+	}
+	f.records = append(f.records, AnchorRecord{ID: fmt.Sprintf("AUDIT_%s_EVT_%s_%d", args[1], args[0], len(f.records)), Action: args[0], Actor: actor, Hash: args[2], Details: args[3]})
+	return nil, nil
+}
+
+func (f *fakeAudit) EvaluateTransaction(name string, args ...string) ([]byte, error) {
+	trail := []AnchorRecord{{ID: "AUDIT_other", Action: "CREATE"}}
+	for _, r := range f.records {
+		if strings.Contains(r.ID, args[0]) {
+			trail = append(trail, r)
+		}
+	}
+	return json.Marshal(trail)
+}
+
+func TestAnchor(t *testing.T) {
+	r, _ := Build(IBBI, day("2024-04-01"), day("2024-06-30"), 30, testLedger)
+	f := &fakeAudit{}
+	if _, err := CheckAnchor(f, Ref(r), "aa", AnchorMSP, nil); err == nil || !strings.Contains(err.Error(), "no return is anchored") {
+		t.Fatalf("err %v", err)
+	}
+	files := []File{{"r.csv", "c1"}, {"r.xlsx", "x1"}}
+	ref, err := Anchor(f, r, "aa", files)
+	if err != nil || ref != "RETURN_IBBI_20240401_20240630" {
+		t.Fatalf("ref %s, err %v", ref, err)
+	}
+	if d := f.records[0].Details; d != "IBBI return 2024-04-01 to 2024-06-30, indexed to block 42: r.csv sha256 c1; r.xlsx sha256 x1" {
+		t.Fatalf("details %q", d)
+	}
+	// Regenerated for the same period: both anchors can be verified
+	if _, err := Anchor(f, r, "bb", []File{{"r.csv", "c2"}}); err != nil {
+		t.Fatal(err)
+	}
+	if a, err := CheckAnchor(f, ref, "aa", AnchorMSP, files); err != nil || a.Hash != "aa" {
+		t.Fatalf("anchor %+v, err %v", a, err)
+	}
+	if _, err := CheckAnchor(f, ref, "bb", AnchorMSP, []File{{"r.csv", "c2"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CheckAnchor(f, ref, "cc", AnchorMSP, nil); err == nil || !strings.Contains(err.Error(), "anchored hashes aa, bb") {
+		t.Fatalf("altered return: err %v", err)
+	}
+	if _, err := CheckAnchor(f, ref, "aa", AnchorMSP, []File{{"r.csv", "c9"}}); err == nil || !strings.Contains(err.Error(), "r.csv does not match") {
+		t.Fatalf("altered CSV: err %v", err)
+	}
+	if _, err := CheckAnchor(f, ref, "aa", "CreditorMSP", files); err == nil || !strings.Contains(err.Error(), "only AdminMSP signs returns") {
+		t.Fatalf("signed by another org: err %v", err)
+	}
+
+	// An anchor recorded by another org does not vouch for the return
+	forged := &fakeAudit{actor: "CreditorMSP"}
+	if _, err := Anchor(forged, r, "aa", files); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CheckAnchor(forged, ref, "aa", AnchorMSP, files); err == nil || !strings.Contains(err.Error(), "by AdminMSP, only AUDIT_RETURN_IBBI_20240401_20240630_EVT_REGULATORY_RETURN_0 by CreditorMSP") {
+		t.Fatalf("anchored by another org: err %v", err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	store, err := indexer.Open("sqlite:"+filepath.Join(t.TempDir(), "index.db"), "financial-operations-channel", "iu-chaincode")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	for _, row := range []struct{ table, key, doc string }{
+		{"defaults", "DEFAULT_DEF1", `{"defaultId":"DEF1","creditorId":"C1","amount":10,"currency":"INR","filedAt":"2024-04-02T10:00:00Z","authStatus":"PENDING_AUTHENTICATION"}`},
+		{"transactions", "T1", `{"id":"T1","creditorId":"C1","amount":5,"currency":"INR","transactionType":"DEBIT","timestamp":"2024-04-01T00:00:00Z"}`},
+		{"transactions", "T2", `{"id":"T2","creditorId":"C2","amount":6,"currency":"INR","transactionType":"DEBIT","timestamp":"2024-04-01T00:00:00Z"}`},
+	} {
+		if _, err := store.DB().Exec(fmt.Sprintf("INSERT INTO %s (key, last_block, last_tx_id, last_written_at, doc) VALUES (?, 1, 'tx', '', ?)", row.table), row.key, row.doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	l, err := Load(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l.Defaults) != 1 || l.Defaults[0].FiledAt != at("2024-04-02T10:00:00Z") || len(l.Transactions) != 2 || len(l.Documents) != 0 || l.Channel != "financial-operations-channel" {
+		t.Fatalf("loaded %+v", l)
+	}
+}
@@ -0,0 +1,299 @@
+// Package regreport computes the periodic returns the IU files with its
+// regulators, IBBI and RBI, from the query index: defaults filed in a
+// period, their authentication status, debtors' turnaround in responding,
+// disputes, and the information each creditor submitted. A return is
+// written as JSON, which is signed and whose hash is anchored on
+// audit-compliance-channel, and as CSV and XLSX for the regulator's
+// analysts, whose hashes the anchor also carries.
+package regreport
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Regulators a return can be filed with
+const (
+	IBBI = "IBBI"
+	RBI  = "RBI"
+)
+
+// Default mirrors the fields of the chaincode's DefaultRecord used here
+type Default struct {
+	ID             string    `json:"defaultId"`
+	LoanID         string    `json:"loanId"`
+	CreditorID     string    `json:"creditorId"`
+	DebtorID       string    `json:"debtorId"`
+	Amount         float64   `json:"amount"`
+	Currency       string    `json:"currency"`
+	FiledAt        time.Time `json:"filedAt"`
+	AuthStatus     string    `json:"authStatus"`
+	RespondedBy    string    `json:"respondedBy"`
+	RespondedAt    time.Time `json:"respondedAt"`
+	DisputeReason  string    `json:"disputeReason"`
+	EvidenceDocIDs []string  `json:"evidenceDocIds"`
+}
+
+// Transaction mirrors the fields of the chaincode's Transaction used here
+type Transaction struct {
+	ID         string    `json:"id"`
+	CreditorID string    `json:"creditorId"`
+	Amount     float64   `json:"amount"`
+	Currency   string    `json:"currency"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// Document mirrors the fields of the chaincode's Document used here
+type Document struct {
+	DocID      string    `json:"docId"`
+	OwnerOrg   string    `json:"ownerOrg"`
+	UploadedAt time.Time `json:"uploadedAt"`
+}
+
+// Ledger is the indexed state a return is computed from
+type Ledger struct {
+	Channel        string
+	IndexedToBlock uint64 // blocks before this one are indexed
+	Defaults       []Default
+	Transactions   []Transaction
+	Documents      []Document
+}
+
+// Authentication statuses of a default
+const (
+	Pending             = "PENDING_AUTHENTICATION"
+	Authenticated       = "AUTHENTICATED"
+	Disputed            = "DISPUTED"
+	DeemedAuthenticated = "DEEMED_AUTHENTICATED"
+)
+
+// Report is a return for a period. It holds no generation time, so the
+// same index and period always give the same report.
+type Report struct {
+	Regulator      string           `json:"regulator"`
+	From           string           `json:"from"`
+	To             string           `json:"to"`
+	Channel        string           `json:"channel"`
+	IndexedToBlock uint64           `json:"indexedToBlock"`
+	Summary        Summary          `json:"summary"`
+	AuthStatus     []StatusCount    `json:"authenticationStatus"`
+	Turnaround     Turnaround       `json:"turnaround"`
+	Disputes       []Dispute        `json:"disputes"`
+	Creditors      []CreditorReturn `json:"creditors"`
+}
+
+// Summary counts the records submitted in the period
+type Summary struct {
+	DefaultsFiled         int `json:"defaultsFiled"`
+	Authenticated         int `json:"authenticated"`
+	DeemedAuthenticated   int `json:"deemedAuthenticated"`
+	Disputed              int `json:"disputed"`
+	Pending               int `json:"pending"`
+	TransactionsSubmitted int `json:"transactionsSubmitted"`
+	DocumentsSubmitted    int `json:"documentsSubmitted"`
+	Creditors             int `json:"creditors"`
+}
+
+// StatusCount is the number and amount of defaults in one authentication
+// status and currency
+type StatusCount struct {
+	Status   string  `json:"status"`
+	Currency string  `json:"currency"`
+	Count    int     `json:"count"`
+	Amount   float64 `json:"amount"`
+}
+
+// Turnaround measures how quickly debtors responded to defaults filed in
+// the period, in days from filing to confirmation or dispute. Deemed
+// authentications are not debtor responses and are counted apart.
+type Turnaround struct {
+	WindowDays          int     `json:"windowDays"`
+	Responses           int     `json:"responses"`
+	WithinWindow        int     `json:"withinWindow"`
+	MeanDays            float64 `json:"meanDays"`
+	MedianDays          float64 `json:"medianDays"`
+	MaxDays             float64 `json:"maxDays"`
+	DeemedAuthenticated int     `json:"deemedAuthenticated"`
+	PendingPastWindow   int     `json:"pendingPastWindow"` // still pending at the end of the period
+}
+
+// Dispute is a default filed in the period that the debtor disputed
+type Dispute struct {
+	DefaultID     string  `json:"defaultId"`
+	LoanID        string  `json:"loanId"`
+	CreditorID    string  `json:"creditorId"`
+	DebtorID      string  `json:"debtorId"`
+	Amount        float64 `json:"amount"`
+	Currency      string  `json:"currency"`
+	FiledAt       string  `json:"filedAt"`
+	RespondedAt   string  `json:"respondedAt"`
+	Reason        string  `json:"reason"`
+	EvidenceCount int     `json:"evidenceCount"`
+}
+
+// CreditorReturn is what one creditor submitted in the period in one currency
+type CreditorReturn struct {
+	CreditorID        string  `json:"creditorId"`
+	Currency          string  `json:"currency"`
+	Transactions      int     `json:"transactions"`
+	TransactionAmount float64 `json:"transactionAmount"`
+	DefaultsFiled     int     `json:"defaultsFiled"`
+	DefaultAmount     float64 `json:"defaultAmount"`
+	Disputed          int     `json:"disputed"`
+}
+
+const dateLayout = "2006-01-02"
+
+// Build computes the return for the days from through to, inclusive, in
+// UTC. windowDays is the deemed authentication window the turnaround is
+// measured against.
+func Build(regulator string, from, to time.Time, windowDays int, l *Ledger) (*Report, error) {
+	if regulator != IBBI && regulator != RBI {
+		return nil, fmt.Errorf("unknown regulator %q (want %s or %s)", regulator, IBBI, RBI)
+	}
+	if to.Before(from) {
+		return nil, fmt.Errorf("period ends on %s before it starts on %s", to.Format(dateLayout), from.Format(dateLayout))
+	}
+	if windowDays <= 0 {
+		return nil, fmt.Errorf("window must be a positive number of days")
+	}
+	end := to.AddDate(0, 0, 1)
+	in := func(t time.Time) bool { return !t.Before(from) && t.Before(end) }
+
+	r := &Report{
+		Regulator:      regulator,
+		From:           from.Format(dateLayout),
+		To:             to.Format(dateLayout),
+		Channel:        l.Channel,
+		IndexedToBlock: l.IndexedToBlock,
+		AuthStatus:     []StatusCount{},
+		Turnaround:     Turnaround{WindowDays: windowDays},
+		Disputes:       []Dispute{},
+		Creditors:      []CreditorReturn{},
+	}
+	statuses := map[[2]string]*StatusCount{}
+	creditors := map[[2]string]*CreditorReturn{}
+	creditor := func(id, currency string) *CreditorReturn {
+		k := [2]string{id, currency}
+		if creditors[k] == nil {
+			creditors[k] = &CreditorReturn{CreditorID: id, Currency: currency}
+		}
+		return creditors[k]
+	}
+	window := time.Duration(windowDays) * 24 * time.Hour
+	var days []float64
+
+	for _, d := range l.Defaults {
+		if !in(d.FiledAt) {
+			continue
+		}
+		r.Summary.DefaultsFiled++
+		k := [2]string{d.AuthStatus, d.Currency}
+		if statuses[k] == nil {
+			statuses[k] = &StatusCount{Status: d.AuthStatus, Currency: d.Currency}
+		}
+		statuses[k].Count++
+		statuses[k].Amount += d.Amount
+		c := creditor(d.CreditorID, d.Currency)
+		c.DefaultsFiled++
+		c.DefaultAmount += d.Amount
+
+		switch d.AuthStatus {
+		case Authenticated, Disputed:
+			elapsed := d.RespondedAt.Sub(d.FiledAt)
+			days = append(days, elapsed.Hours()/24)
+			if elapsed <= window {
+				r.Turnaround.WithinWindow++
+			}
+			if d.AuthStatus == Authenticated {
+				r.Summary.Authenticated++
+				break
+			}
+			r.Summary.Disputed++
+			c.Disputed++
+			r.Disputes = append(r.Disputes, Dispute{
+				DefaultID: d.ID, LoanID: d.LoanID, CreditorID: d.CreditorID, DebtorID: d.DebtorID,
+				Amount: d.Amount, Currency: d.Currency,
+				FiledAt: d.FiledAt.UTC().Format(time.RFC3339), RespondedAt: d.RespondedAt.UTC().Format(time.RFC3339),
+				Reason: d.DisputeReason, EvidenceCount: len(d.EvidenceDocIDs),
+			})
+		case DeemedAuthenticated:
+			r.Summary.DeemedAuthenticated++
+			r.Turnaround.DeemedAuthenticated++
+		case Pending:
+			r.Summary.Pending++
+			if !end.Before(d.FiledAt.Add(window)) {
+				r.Turnaround.PendingPastWindow++
+			}
+		}
+	}
+
+	for _, tx := range l.Transactions {
+		if !in(tx.Timestamp) {
+			continue
+		}
+		r.Summary.TransactionsSubmitted++
+		c := creditor(tx.CreditorID, tx.Currency)
+		c.Transactions++
+		c.TransactionAmount += tx.Amount
+	}
+	for _, doc := range l.Documents {
+		if in(doc.UploadedAt) {
+			r.Summary.DocumentsSubmitted++
+		}
+	}
+
+	if len(days) > 0 {
+		sort.Float64s(days)
+		sum := 0.0
+		for _, d := range days {
+			sum += d
+		}
+		r.Turnaround.Responses = len(days)
+		r.Turnaround.MeanDays = round1(sum / float64(len(days)))
+		r.Turnaround.MedianDays = round1(median(days))
+		r.Turnaround.MaxDays = round1(days[len(days)-1])
+	}
+
+	for _, s := range statuses {
+		s.Amount = round2(s.Amount)
+		r.AuthStatus = append(r.AuthStatus, *s)
+	}
+	sort.Slice(r.AuthStatus, func(i, j int) bool {
+		a, b := r.AuthStatus[i], r.AuthStatus[j]
+		return a.Status < b.Status || a.Status == b.Status && a.Currency < b.Currency
+	})
+	ids := map[string]bool{}
+	for _, c := range creditors {
+		c.TransactionAmount, c.DefaultAmount = round2(c.TransactionAmount), round2(c.DefaultAmount)
+		r.Creditors = append(r.Creditors, *c)
+		ids[c.CreditorID] = true
+	}
+	sort.Slice(r.Creditors, func(i, j int) bool {
+		a, b := r.Creditors[i], r.Creditors[j]
+		return a.CreditorID < b.CreditorID || a.CreditorID == b.CreditorID && a.Currency < b.Currency
+	})
+	r.Summary.Creditors = len(ids)
+	sort.Slice(r.Disputes, func(i, j int) bool { return r.Disputes[i].DefaultID < r.Disputes[j].DefaultID })
+	return r, nil
+}
+
+func median(sorted []float64) float64 {
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+func round1(v float64) float64 { return math.Round(v*10) / 10 }
+func round2(v float64) float64 { return math.Round(v*100) / 100 }
+
+// Ref is the audit reference a return is anchored under, e.g.
+// RETURN_IBBI_20240401_20240630
+func Ref(r *Report) string {
+	return fmt.Sprintf("RETURN_%s_%s_%s", r.Regulator, strings.ReplaceAll(r.From, "-", ""), strings.ReplaceAll(r.To, "-", ""))
+}
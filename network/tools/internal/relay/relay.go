@@ -0,0 +1,236 @@
+// Package relay mirrors iu-chaincode events from financial-operations-channel
+// into audit-compliance-channel through RecordAuditEvent, and reconciles the
+// two channels to find events that were never mirrored or were mirrored
+// with different content.
+package relay
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+	"iu-chaincode/events"
+
+	"iu-tools/internal/gateway"
+	"iu-tools/internal/ledger"
+)
+
+// Submitter submits transactions to the audit channel contract
+type Submitter interface {
+	SubmitTransaction(name string, args ...string) ([]byte, error)
+}
+
+// Evaluator evaluates queries against the audit channel contract
+type Evaluator interface {
+	EvaluateTransaction(name string, args ...string) ([]byte, error)
+}
+
+// Contract is the audit channel contract the relay records mirrors on
+type Contract interface {
+	Submitter
+	Evaluator
+}
+
+// Mirror is what RecordAuditEvent stores for one source event
+type Mirror struct {
+	EventType   string
+	RefID       string
+	Hash        string
+	Details     string
+	SourceTxID  string
+	SourceBlock uint64
+}
+
+// MirrorOf describes the audit record for an event committed on sourceChannel.
+// The record is keyed by the envelope's entity key and keeps its payload
+// hash, which VerifyMirroredEvent checks against the source record. Events
+// that change many records, or are not envelopes, are keyed by the source
+// TxID and keep the hash of the raw payload.
+func MirrorOf(sourceChannel string, blockNumber uint64, txID, eventName string, payload []byte) Mirror {
+	m := Mirror{
+		EventType:   eventName,
+		RefID:       txID,
+		Hash:        payloadHash(payload),
+		Details:     fmt.Sprintf("Mirrored from %s block %d tx %s", sourceChannel, blockNumber, txID),
+		SourceTxID:  txID,
+		SourceBlock: blockNumber,
+	}
+	if env, err := events.Decode(payload); err == nil && env.EntityKey != "" {
+		m.RefID = env.EntityKey
+		m.Hash = env.PayloadHash
+	}
+	return m
+}
+
+func payloadHash(payload []byte) string {
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:])
+}
+
+// Record submits m to the audit channel unless its source transaction is
+// already mirrored, so replaying events is safe
+func Record(target Contract, m Mirror) (recorded bool, err error) {
+	mirrored, err := isMirrored(target, m.SourceTxID)
+	if err != nil || mirrored {
+		return false, err
+	}
+	_, err = target.SubmitTransaction("RecordAuditEvent", m.EventType, m.RefID, m.Hash, m.Details,
+		m.SourceTxID, strconv.FormatUint(m.SourceBlock, 10))
+	if err != nil {
+		return false, fmt.Errorf("RecordAuditEvent for tx %s failed: %s", m.SourceTxID, gateway.ErrorMessage(err))
+	}
+	return true, nil
+}
+
+func isMirrored(target Evaluator, sourceTxID string) (bool, error) {
+	b, err := target.EvaluateTransaction("MirroredEventExists", sourceTxID)
+	if err != nil {
+		return false, fmt.Errorf("MirroredEventExists for tx %s failed: %s", sourceTxID, gateway.ErrorMessage(err))
+	}
+	return strconv.ParseBool(string(b))
+}
+
+// Relay listens to chaincode events on the source channel and mirrors them.
+// The checkpoint only advances after an event is recorded, and the audit
+// chaincode rejects a second record of the same source TxID, so every event
+// is mirrored exactly once across restarts.
+type Relay struct {
+	Source     *client.Network
+	Chaincode  string
+	Target     Contract
+	Checkpoint *client.FileCheckpointer
+	StartBlock uint64
+	Logf       func(format string, args ...interface{})
+}
+
+// Run mirrors events until ctx is cancelled or the event stream fails
+func (r *Relay) Run(ctx context.Context) error {
+	events, err := r.Source.ChaincodeEvents(ctx, r.Chaincode,
+		client.WithStartBlock(r.StartBlock), client.WithCheckpoint(r.Checkpoint))
+	if err != nil {
+		return fmt.Errorf("failed to listen for events: %v", err)
+	}
+	for ev := range events {
+		m := MirrorOf(r.Source.Name(), ev.BlockNumber, ev.TransactionID, ev.EventName, ev.Payload)
+		recorded, err := Record(r.Target, m)
+		if err != nil {
+			return err
+		}
+		if recorded {
+			r.Logf("mirrored %s for %s from block %d tx %s", m.EventType, m.RefID, m.SourceBlock, m.SourceTxID)
+		} else {
+			r.Logf("tx %s already mirrored, skipping", m.SourceTxID)
+		}
+		if err := r.Checkpoint.CheckpointChaincodeEvent(ev); err != nil {
+			return fmt.Errorf("failed to save checkpoint: %v", err)
+		}
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+	return fmt.Errorf("event stream closed")
+}
+
+// Gap is a source event that is missing or different on the audit channel
+type Gap struct {
+	SourceBlock uint64 `json:"sourceBlock"`
+	SourceTxID  string `json:"sourceTxId"`
+	EventName   string `json:"eventName"`
+	Problem     string `json:"problem"`
+}
+
+// mirroredRecord is the part of a mirrored AuditRecord that reconciliation checks
+type mirroredRecord struct {
+	ID          string `json:"id"`
+	Action      string `json:"action"`
+	Hash        string `json:"hash"`
+	SourceBlock uint64 `json:"sourceBlock"`
+}
+
+// Reconcile checks every chaincode event committed on source in blocks
+// from..to against its mirror on the audit channel. A to of 0 means the
+// current end of the source chain.
+func Reconcile(ctx context.Context, source *client.Network, chaincode string, target Evaluator, from, to uint64) ([]Gap, error) {
+	height, err := gateway.ChainHeight(source)
+	if err != nil {
+		return nil, err
+	}
+	if height == 0 {
+		return nil, nil
+	}
+	if to == 0 || to >= height {
+		to = height - 1
+	}
+	if from > to {
+		return nil, fmt.Errorf("start block %d is past end block %d", from, to)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	blocks, err := source.BlockEvents(ctx, client.WithStartBlock(from))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blocks: %v", err)
+	}
+
+	gaps := []Gap{}
+	for block := range blocks {
+		events, err := ledger.ChaincodeEvents(block)
+		if err != nil {
+			return nil, err
+		}
+		for _, ev := range events {
+			if ev.ChaincodeName != chaincode {
+				continue
+			}
+			gap, err := check(target, MirrorOf(source.Name(), ev.BlockNumber, ev.TxID, ev.EventName, ev.Payload))
+			if err != nil {
+				return nil, err
+			}
+			if gap != nil {
+				gaps = append(gaps, *gap)
+			}
+		}
+		if block.GetHeader().GetNumber() >= to {
+			return gaps, nil
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("block stream closed before block %d", to)
+}
+
+func check(target Evaluator, want Mirror) (*Gap, error) {
+	gap := &Gap{SourceBlock: want.SourceBlock, SourceTxID: want.SourceTxID, EventName: want.EventType}
+	mirrored, err := isMirrored(target, want.SourceTxID)
+	if err != nil {
+		return nil, err
+	}
+	if !mirrored {
+		gap.Problem = "missing on audit channel"
+		return gap, nil
+	}
+	b, err := target.EvaluateTransaction("GetMirroredEvent", want.SourceTxID)
+	if err != nil {
+		return nil, fmt.Errorf("GetMirroredEvent for tx %s failed: %s", want.SourceTxID, gateway.ErrorMessage(err))
+	}
+	var got mirroredRecord
+	if err := json.Unmarshal(b, &got); err != nil {
+		return nil, fmt.Errorf("invalid audit record for tx %s: %v", want.SourceTxID, err)
+	}
+	switch {
+	case got.Hash != want.Hash:
+		gap.Problem = fmt.Sprintf("payload hash differs in %s", got.ID)
+	case got.SourceBlock != want.SourceBlock:
+		gap.Problem = fmt.Sprintf("%s records block %d", got.ID, got.SourceBlock)
+	case got.Action != want.EventType:
+		gap.Problem = fmt.Sprintf("%s records event %s", got.ID, got.Action)
+	default:
+		return nil, nil
+	}
+	return gap, nil
+}
@@ -0,0 +1,108 @@
+package relay
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"iu-chaincode/events"
+)
+
+// fakeAudit is an audit channel contract holding mirrors by source TxID
+type fakeAudit struct {
+	mirrors   map[string]mirroredRecord
+	submits   [][]string
+	submitErr error
+}
+
+func (f *fakeAudit) EvaluateTransaction(name string, args ...string) ([]byte, error) {
+	m, ok := f.mirrors[args[0]]
+	switch name {
+	case "MirroredEventExists":
+		return json.Marshal(ok)
+	case "GetMirroredEvent":
+		if !ok {
+			return nil, errors.New("source transaction has not been mirrored")
+		}
+		return json.Marshal(m)
+	}
+	return nil, errors.New("unexpected " + name)
+}
+
+func (f *fakeAudit) SubmitTransaction(name string, args ...string) ([]byte, error) {
+	f.submits = append(f.submits, append([]string{name}, args...))
+	return nil, f.submitErr
+}
+
+func TestMirrorOf(t *testing.T) {
+	env, _ := json.Marshal(events.Envelope{Type: events.TransactionCreated, SchemaVersion: 1, EntityKey: "TX1", PayloadHash: "ab12"})
+	m := MirrorOf("ops", 7, "tx1", events.TransactionCreated, env)
+	if m.RefID != "TX1" || m.Hash != "ab12" || m.SourceTxID != "tx1" || m.SourceBlock != 7 || !strings.Contains(m.Details, "ops block 7 tx tx1") {
+		t.Errorf("envelope mirror = %+v", m)
+	}
+
+	raw := []byte(`{"keys":["TX1","TX2"]}`)
+	m = MirrorOf("ops", 8, "tx2", events.TransactionsImported, raw)
+	if m.RefID != "tx2" || m.Hash != payloadHash(raw) {
+		t.Errorf("raw mirror = %+v", m)
+	}
+}
+
+func TestRecord(t *testing.T) {
+	m := Mirror{EventType: events.TransactionCreated, RefID: "TX1", Hash: "ab12", Details: "d", SourceTxID: "tx1", SourceBlock: 7}
+
+	f := &fakeAudit{mirrors: map[string]mirroredRecord{}}
+	recorded, err := Record(f, m)
+	if err != nil || !recorded {
+		t.Fatalf("Record = %v, %v", recorded, err)
+	}
+	want := "RecordAuditEvent TRANSACTION_CREATED TX1 ab12 d tx1 7"
+	if len(f.submits) != 1 || strings.Join(f.submits[0], " ") != want {
+		t.Errorf("submits = %v, want [%s]", f.submits, want)
+	}
+
+	f = &fakeAudit{mirrors: map[string]mirroredRecord{"tx1": {}}}
+	recorded, err = Record(f, m)
+	if err != nil || recorded || len(f.submits) != 0 {
+		t.Errorf("already mirrored: Record = %v, %v with %d submits", recorded, err, len(f.submits))
+	}
+
+	// A rejected submission is an error even if its message says the
+	// source is mirrored, since the relay checked that it was not
+	f = &fakeAudit{mirrors: map[string]mirroredRecord{}, submitErr: errors.New("source transaction tx1 already mirrored")}
+	if _, err := Record(f, m); err == nil || !strings.Contains(err.Error(), "RecordAuditEvent for tx tx1 failed") {
+		t.Errorf("rejected submission: err = %v", err)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	want := Mirror{EventType: events.TransactionCreated, RefID: "TX1", Hash: "ab12", SourceTxID: "tx1", SourceBlock: 7}
+	tests := []struct {
+		name   string
+		mirror *mirroredRecord
+		want   string
+	}{
+		{"matches", &mirroredRecord{ID: "A1", Action: events.TransactionCreated, Hash: "ab12", SourceBlock: 7}, ""},
+		{"missing", nil, "missing on audit channel"},
+		{"hash", &mirroredRecord{ID: "A1", Action: events.TransactionCreated, Hash: "ff", SourceBlock: 7}, "payload hash differs in A1"},
+		{"block", &mirroredRecord{ID: "A1", Action: events.TransactionCreated, Hash: "ab12", SourceBlock: 8}, "A1 records block 8"},
+		{"event", &mirroredRecord{ID: "A1", Action: events.DefaultFiled, Hash: "ab12", SourceBlock: 7}, "A1 records event DEFAULT_FILED"},
+	}
+	for _, tt := range tests {
+		f := &fakeAudit{mirrors: map[string]mirroredRecord{}}
+		if tt.mirror != nil {
+			f.mirrors["tx1"] = *tt.mirror
+		}
+		gap, err := check(f, want)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		switch {
+		case tt.want == "" && gap != nil:
+			t.Errorf("%s: unexpected gap %+v", tt.name, gap)
+		case tt.want != "" && (gap == nil || gap.Problem != tt.want || gap.SourceTxID != "tx1"):
+			t.Errorf("%s: gap = %+v, want %q", tt.name, gap, tt.want)
+		}
+	}
+}
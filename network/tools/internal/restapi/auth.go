@@ -0,0 +1,94 @@
+package restapi
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// AuthHeader carries the client's API key as "Bearer <key>"
+const AuthHeader = "Authorization"
+
+// Client is an API client and what it is entitled to do
+type Client struct {
+	KeySHA256   string   `json:"keySha256"`   // hex SHA-256 of the client's API key
+	Orgs        []string `json:"orgs"`        // org profiles the client may sign as; the first is its default
+	CourtOrders bool     `json:"courtOrders"` // may send X-IU-Court-Order-Hash
+}
+
+// Clients holds the API clients by name
+type Clients map[string]Client
+
+// errUnauthenticated is returned for a missing or unknown API key
+var errUnauthenticated = errors.New("a valid API key is required as " + AuthHeader + ": Bearer <key>")
+
+// LoadClients reads API clients from a JSON file of {"name": Client}
+func LoadClients(path string) (Clients, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	clients := Clients{}
+	if err := json.Unmarshal(b, &clients); err != nil {
+		return nil, fmt.Errorf("invalid clients file %s: %v", path, err)
+	}
+	seen := map[string]string{}
+	for name, c := range clients {
+		if h, err := hex.DecodeString(c.KeySHA256); err != nil || len(h) != sha256.Size {
+			return nil, fmt.Errorf("client %s: keySha256 must be the SHA-256 hash of its API key in 64 hex characters", name)
+		}
+		if len(c.Orgs) == 0 {
+			return nil, fmt.Errorf("client %s: at least one org is required", name)
+		}
+		key := strings.ToLower(c.KeySHA256)
+		if other, ok := seen[key]; ok {
+			return nil, fmt.Errorf("clients %s and %s share an API key", other, name)
+		}
+		seen[key] = name
+	}
+	return clients, nil
+}
+
+// HashKey returns the keySha256 value for an API key
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Authenticate returns the client whose API key the request carries
+func (cs Clients) Authenticate(r *http.Request) (string, Client, error) {
+	key, ok := strings.CutPrefix(r.Header.Get(AuthHeader), "Bearer ")
+	if !ok || key == "" {
+		return "", Client{}, errUnauthenticated
+	}
+	sum := sha256.Sum256([]byte(key))
+	for name, c := range cs {
+		want, _ := hex.DecodeString(c.KeySHA256)
+		if subtle.ConstantTimeCompare(sum[:], want) == 1 {
+			return name, c, nil
+		}
+	}
+	return "", Client{}, errUnauthenticated
+}
+
+// Authorize checks that the client may sign call as its org and, if the
+// request carries a court order hash, that it may send one
+func (c Client) Authorize(name string, r *http.Request, call Call) error {
+	allowed := false
+	for _, org := range c.Orgs {
+		allowed = allowed || org == call.Org
+	}
+	if !allowed {
+		return fmt.Errorf("client %s may not sign as org %s", name, call.Org)
+	}
+	if r.Header.Get(CourtOrderHeader) != "" && !c.CourtOrders {
+		return fmt.Errorf("client %s may not send %s", name, CourtOrderHeader)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package restapi
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+
+	"iu-tools/internal/gateway"
+)
+
+// GatewayInvoker runs calls through the Fabric Gateway, keeping one
+// connection per org profile. Profiles not in Profiles fall back to the
+// local network's creditor, debtor and admin identities under OrgsDir.
+type GatewayInvoker struct {
+	Profiles  map[string]gateway.Profile
+	OrgsDir   string
+	Chaincode string
+
+	mu    sync.Mutex
+	conns map[string]*gateway.Connection
+}
+
+// Invoke evaluates or submits c as c.Org
+func (g *GatewayInvoker) Invoke(c Call) ([]byte, error) {
+	conn, err := g.connection(c.Org)
+	if err != nil {
+		return nil, err
+	}
+	contract := conn.GetNetwork(c.Channel).GetContract(g.Chaincode)
+	opts := []client.ProposalOption{client.WithArguments(c.Args...)}
+	if len(c.Transient) > 0 {
+		opts = append(opts, client.WithTransient(c.Transient))
+	}
+	if c.Evaluate {
+		return contract.Evaluate(c.Function, opts...)
+	}
+	return contract.Submit(c.Function, opts...)
+}
+
+func (g *GatewayInvoker) connection(org string) (*gateway.Connection, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if conn, ok := g.conns[org]; ok {
+		return conn, nil
+	}
+	profile, ok := g.Profiles[org]
+	if !ok {
+		var err error
+		if profile, err = gateway.DefaultProfile(org, g.OrgsDir); err != nil {
+			return nil, err
+		}
+	}
+	conn, err := gateway.Connect(profile)
+	if err != nil {
+		return nil, fmt.Errorf("org %s: %v", org, err)
+	}
+	if g.conns == nil {
+		g.conns = map[string]*gateway.Connection{}
+	}
+	g.conns[org] = conn
+	return conn, nil
+}
+
+// Metadata fetches the contract metadata as org
+func (g *GatewayInvoker) Metadata(org, channel string) ([]byte, error) {
+	return g.Invoke(Call{
+		Org:      org,
+		Channel:  channel,
+		Function: "org.hyperledger.fabric:GetMetadata",
+		Args:     []string{},
+		Evaluate: true,
+	})
+}
+
+// Close closes every open connection
+func (g *GatewayInvoker) Close() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, conn := range g.conns {
+		conn.Close()
+	}
+	g.conns = nil
+}
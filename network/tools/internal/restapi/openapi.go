@@ -0,0 +1,228 @@
+package restapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Metadata is the subset of contractapi's GetMetadata output used here
+type Metadata struct {
+	Contracts map[string]struct {
+		Name         string                `json:"name"`
+		Transactions []MetadataTransaction `json:"transactions"`
+	} `json:"contracts"`
+	Components struct {
+		Schemas map[string]map[string]interface{} `json:"schemas"`
+	} `json:"components"`
+}
+
+// MetadataTransaction describes one contract function
+type MetadataTransaction struct {
+	Name       string   `json:"name"`
+	Tag        []string `json:"tag"`
+	Parameters []struct {
+		Name   string                 `json:"name"`
+		Schema map[string]interface{} `json:"schema"`
+	} `json:"parameters"`
+	Returns map[string]interface{} `json:"returns"`
+}
+
+// transientSchemas describes the body fields routes send as transient data
+var transientSchemas = map[string]interface{}{
+	"formC": map[string]interface{}{
+		"description": "Form C, sent to the chaincode as transient data and never written to the ledger",
+		"type":        "object",
+	},
+	"batch": map[string]interface{}{
+		"description": "Rows to import, sent to the chaincode as transient data. batchHash is the hex SHA-256 of this array as compact JSON.",
+		"type":        "array",
+		"items":       map[string]interface{}{"type": "object"},
+	},
+}
+
+// moratoriumChecked lists the functions that refuse recovery actions during
+// an insolvency moratorium unless AdminMSP passes a court order hash
+var moratoriumChecked = map[string]bool{
+	"CreateTransaction": true,
+	"SuspendAccount":    true,
+	"FileDefault":       true,
+}
+
+// OpenAPI builds an OpenAPI 3 document for Routes from contract metadata.
+// contractapi names parameters param0, param1, ...; the route's Args give
+// their names and whether they are path, query or body fields.
+func OpenAPI(metadata []byte, contract string) ([]byte, error) {
+	var md Metadata
+	if err := json.Unmarshal(metadata, &md); err != nil {
+		return nil, fmt.Errorf("invalid contract metadata: %v", err)
+	}
+	c, ok := md.Contracts[contract]
+	if !ok {
+		return nil, fmt.Errorf("contract %s not found in metadata", contract)
+	}
+	txs := map[string]MetadataTransaction{}
+	for _, tx := range c.Transactions {
+		txs[tx.Name] = tx
+	}
+
+	paths := map[string]map[string]interface{}{}
+	var tags []string
+	seenTag := map[string]bool{}
+	for _, r := range Routes {
+		tx, ok := txs[r.Function]
+		if !ok {
+			return nil, fmt.Errorf("route %s %s: function %s not in contract metadata", r.Method, r.Path, r.Function)
+		}
+		if len(tx.Parameters) != len(r.Args) {
+			return nil, fmt.Errorf("route %s %s: %s takes %d parameters, route has %d", r.Method, r.Path, r.Function, len(tx.Parameters), len(r.Args))
+		}
+		if !seenTag[r.Tag] {
+			seenTag[r.Tag] = true
+			tags = append(tags, r.Tag)
+		}
+
+		params := []interface{}{
+			map[string]string{"$ref": "#/components/parameters/Org"},
+			map[string]string{"$ref": "#/components/parameters/Channel"},
+		}
+		props := map[string]interface{}{}
+		var required []string
+		for i, a := range r.Args {
+			schema := tx.Parameters[i].Schema
+			switch a.In {
+			case "path":
+				params = append(params, map[string]interface{}{"name": a.Name, "in": "path", "required": true, "schema": schema})
+			case "query":
+				params = append(params, map[string]interface{}{"name": a.Name, "in": "query", "schema": schema})
+			case "body":
+				props[a.Name] = schema
+				required = append(required, a.Name)
+			}
+		}
+		if r.Transient != "" {
+			props[r.Transient] = transientSchemas[r.Transient]
+			required = append(required, r.Transient)
+		}
+		if moratoriumChecked[r.Function] {
+			params = append(params, map[string]string{"$ref": "#/components/parameters/CourtOrderHash"})
+		}
+
+		op := map[string]interface{}{
+			"operationId": r.Function,
+			"tags":        []string{r.Tag},
+			"parameters":  params,
+			"responses":   responses(tx),
+		}
+		if r.Evaluate() {
+			op["summary"] = "Evaluate " + r.Function
+		} else {
+			op["summary"] = "Submit " + r.Function
+		}
+		if len(props) > 0 {
+			op["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{
+						"schema": map[string]interface{}{"type": "object", "properties": props, "required": required},
+					},
+				},
+			}
+		}
+		if paths[r.Path] == nil {
+			paths[r.Path] = map[string]interface{}{}
+		}
+		paths[r.Path][strings.ToLower(r.Method)] = op
+	}
+
+	schemas := map[string]interface{}{
+		"Error": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"error":  map[string]string{"type": "string"},
+				"status": map[string]string{"type": "string"},
+				// Set when the chaincode rejects identifiers
+				"fields": map[string]interface{}{"type": "array", "items": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"field": map[string]string{"type": "string"}, "kind": map[string]string{"type": "string"},
+						"value": map[string]string{"type": "string"}, "reason": map[string]string{"type": "string"},
+					},
+				}},
+			},
+			"required": []string{"error"},
+		},
+	}
+	for name, s := range md.Components.Schemas {
+		clean := map[string]interface{}{}
+		for k, v := range s {
+			if k != "$id" {
+				clean[k] = v
+			}
+		}
+		schemas[name] = clean
+	}
+	var tagList []map[string]string
+	for _, t := range tags {
+		tagList = append(tagList, map[string]string{"name": t})
+	}
+
+	doc := map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]string{
+			"title":       "IU REST gateway",
+			"version":     "1",
+			"description": "REST resources for every " + contract + " function. GET routes are evaluated; other methods are submitted.",
+		},
+		"tags":  tagList,
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": schemas,
+			"parameters": map[string]interface{}{
+				"Org": map[string]interface{}{
+					"name": OrgHeader, "in": "header",
+					"description": "Org profile whose identity signs the request (creditor, debtor, admin or a configured profile)",
+					"schema":      map[string]string{"type": "string"},
+				},
+				"Channel": map[string]interface{}{
+					"name": ChannelHeader, "in": "header",
+					"description": "Channel to use instead of the route's default",
+					"schema":      map[string]string{"type": "string"},
+				},
+				"CourtOrderHash": map[string]interface{}{
+					"name": CourtOrderHeader, "in": "header",
+					"description": "Court order hash allowing an action during a moratorium, sent as transient data",
+					"schema":      map[string]string{"type": "string"},
+				},
+			},
+			"responses": map[string]interface{}{"Error": errorResponse("Chaincode or gateway error")},
+		},
+	}
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+func responses(tx MetadataTransaction) map[string]interface{} {
+	out := map[string]interface{}{}
+	if len(tx.Returns) == 0 {
+		out["204"] = map[string]string{"description": "Done"}
+	} else {
+		out["200"] = map[string]interface{}{
+			"description": "Result of " + tx.Name,
+			"content":     map[string]interface{}{"application/json": map[string]interface{}{"schema": tx.Returns}},
+		}
+	}
+	for _, code := range []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound, http.StatusConflict, http.StatusInternalServerError} {
+		out[fmt.Sprint(code)] = map[string]string{"$ref": "#/components/responses/Error"}
+	}
+	return out
+}
+
+func errorResponse(description string) map[string]interface{} {
+	return map[string]interface{}{
+		"description": description,
+		"content": map[string]interface{}{
+			"application/json": map[string]interface{}{"schema": map[string]string{"$ref": "#/components/schemas/Error"}},
+		},
+	}
+}
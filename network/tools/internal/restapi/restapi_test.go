@@ -0,0 +1,348 @@
+package restapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"iu-tools/internal/moratorium"
+)
+
+type fakeInvoker struct {
+	calls  []Call
+	result []byte
+	err    error
+}
+
+func (f *fakeInvoker) Invoke(c Call) ([]byte, error) {
+	f.calls = append(f.calls, c)
+	return f.result, f.err
+}
+
+// testClients has a back office that may act as creditor or admin and send
+// court orders, and a lender limited to the creditor identity
+var testClients = Clients{
+	"backoffice": {KeySHA256: HashKey("backoffice-key"), Orgs: []string{"creditor", "admin"}, CourtOrders: true},
+	"lender":     {KeySHA256: HashKey("lender-key"), Orgs: []string{"creditor"}},
+}
+
+// serve handles req as the back office unless it carries its own key
+func serve(t *testing.T, inv *fakeInvoker, req *http.Request) *httptest.ResponseRecorder {
+	t.Helper()
+	if req.Header.Get(AuthHeader) == "" {
+		req.Header.Set(AuthHeader, "Bearer backoffice-key")
+	}
+	rec := httptest.NewRecorder()
+	NewServer(inv, testClients, nil).ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAuthentication(t *testing.T) {
+	tests := []struct {
+		name, auth, org, courtOrder string
+		want                        int
+	}{
+		{"no key", "", "", "", http.StatusUnauthorized},
+		{"unknown key", "Bearer other-key", "", "", http.StatusUnauthorized},
+		{"not a bearer key", "lender-key", "", "", http.StatusUnauthorized},
+		{"default org", "Bearer lender-key", "", "", http.StatusNoContent},
+		{"entitled org", "Bearer lender-key", "creditor", "", http.StatusNoContent},
+		{"admin org", "Bearer lender-key", "admin", "", http.StatusForbidden},
+		{"court order", "Bearer lender-key", "", strings.Repeat("ab", 32), http.StatusForbidden},
+		{"entitled court order", "Bearer backoffice-key", "admin", strings.Repeat("ab", 32), http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		inv := &fakeInvoker{}
+		req := httptest.NewRequest("POST", "/defaults/DEF1/confirm", nil)
+		if tt.auth != "" {
+			req.Header.Set(AuthHeader, tt.auth)
+		} else {
+			req.Header.Set(AuthHeader, "Bearer ")
+		}
+		if tt.org != "" {
+			req.Header.Set(OrgHeader, tt.org)
+		}
+		if tt.courtOrder != "" {
+			req.Header.Set(CourtOrderHeader, tt.courtOrder)
+		}
+		rec := serve(t, inv, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d: %s", tt.name, rec.Code, tt.want, rec.Body)
+		}
+		if called := len(inv.calls) > 0; called != (tt.want == http.StatusNoContent) {
+			t.Errorf("%s: invoked = %v", tt.name, called)
+		}
+		if tt.want == http.StatusNoContent && tt.org == "" && inv.calls[0].Org != "creditor" {
+			t.Errorf("%s: org = %s, want the client's first org", tt.name, inv.calls[0].Org)
+		}
+	}
+}
+
+func TestLoadClients(t *testing.T) {
+	dir := t.TempDir()
+	write := func(body string) string {
+		path := filepath.Join(dir, "clients.json")
+		if err := os.WriteFile(path, []byte(body), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	key := HashKey("k")
+	clients, err := LoadClients(write(`{"erp":{"keySha256":"` + key + `","orgs":["creditor"]}}`))
+	if err != nil || clients["erp"].Orgs[0] != "creditor" || clients["erp"].CourtOrders {
+		t.Fatalf("LoadClients = %+v, %v", clients, err)
+	}
+	for body, want := range map[string]string{
+		`{"erp":{"keySha256":"k","orgs":["creditor"]}}`:                                                          "keySha256 must be",
+		`{"erp":{"keySha256":"` + key + `"}}`:                                                                    "at least one org",
+		`{"a":{"keySha256":"` + key + `","orgs":["creditor"]},"b":{"keySha256":"` + key + `","orgs":["admin"]}}`: "share an API key",
+	} {
+		if _, err := LoadClients(write(body)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("LoadClients(%s) error = %v, want %q", body, err, want)
+		}
+	}
+}
+
+func TestCreateTransactionArgs(t *testing.T) {
+	inv := &fakeInvoker{}
+	req := httptest.NewRequest("POST", "/transactions", strings.NewReader(
+		`{"id":"TX1","creditorId":"C1","debtorId":"D1","amount":1500.5,"currency":"INR","transactionType":"CREDIT","description":"loan"}`))
+	req.Header.Set(OrgHeader, "admin")
+	req.Header.Set(CourtOrderHeader, strings.Repeat("ab", 32))
+	rec := serve(t, inv, req)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("status = %d, want 204: %s", rec.Code, rec.Body)
+	}
+	c := inv.calls[0]
+	if c.Org != "admin" || c.Function != "CreateTransaction" || c.Evaluate || c.Channel != "financial-operations-channel" {
+		t.Fatalf("unexpected call %+v", c)
+	}
+	want := []string{"TX1", "C1", "D1", "1500.5", "INR", "CREDIT", "loan"}
+	if strings.Join(c.Args, "|") != strings.Join(want, "|") {
+		t.Fatalf("args = %q, want %q", c.Args, want)
+	}
+	if string(c.Transient["courtOrderHash"]) != strings.Repeat("ab", 32) {
+		t.Fatalf("court order hash not sent as transient data")
+	}
+}
+
+func TestPathArgsAndResult(t *testing.T) {
+	inv := &fakeInvoker{result: []byte(`[{"docId":"D1"}]`)}
+	rec := serve(t, inv, httptest.NewRequest("GET", "/loans/L1/documents", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != `[{"docId":"D1"}]` {
+		t.Fatalf("got %d %s", rec.Code, rec.Body)
+	}
+	c := inv.calls[0]
+	if c.Org != "creditor" || !c.Evaluate || c.Function != "GetLoanDocuments" || c.Args[0] != "L1" {
+		t.Fatalf("unexpected call %+v", c)
+	}
+
+	// Plain string results are returned as JSON strings
+	inv.result = []byte("GUARANTEE_G1")
+	rec = serve(t, inv, httptest.NewRequest("POST", "/loans/L1/guarantees/P1/invoke", nil))
+	if rec.Body.String() != `"GUARANTEE_G1"` {
+		t.Fatalf("body = %s", rec.Body)
+	}
+}
+
+func TestApproveKYCAndFormC(t *testing.T) {
+	inv := &fakeInvoker{}
+	serve(t, inv, httptest.NewRequest("POST", "/kyc/K1/approve", strings.NewReader(`{"approved":true,"remarks":"ok"}`)))
+	if c := inv.calls[0]; c.Function != "ApproveKYC" || strings.Join(c.Args, "|") != "K1|true|ok" {
+		t.Fatalf("unexpected call %+v", c)
+	}
+
+	rec := serve(t, inv, httptest.NewRequest("POST", "/kyc", strings.NewReader(`{"loanId":"L1","kycId":"K1","partyId":"P1"}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("missing formC: status = %d, want 400", rec.Code)
+	}
+	serve(t, inv, httptest.NewRequest("POST", "/kyc", strings.NewReader(`{"loanId":"L1","kycId":"K1","partyId":"P1","formC":{"pan":"ABCDE1234F"}}`)))
+	c := inv.calls[len(inv.calls)-1]
+	if string(c.Transient["formc"]) != `{"pan":"ABCDE1234F"}` {
+		t.Fatalf("formc transient = %s", c.Transient["formc"])
+	}
+	for _, a := range c.Args {
+		if strings.Contains(a, "ABCDE1234F") {
+			t.Fatalf("Form C leaked into arguments %q", c.Args)
+		}
+	}
+}
+
+func TestBulkImportBatch(t *testing.T) {
+	inv := &fakeInvoker{}
+	serve(t, inv, httptest.NewRequest("POST", "/imports", strings.NewReader(
+		`{"batchId":"B1","batchHash":"ab12","atomic":true,"batch":[ {"id":"OLD1", "amount":1} ]}`)))
+	c := inv.calls[0]
+	if c.Function != "BulkImportTransactions" || strings.Join(c.Args, "|") != "B1|ab12|true" {
+		t.Fatalf("unexpected call %+v", c)
+	}
+	if string(c.Transient["batch"]) != `[{"id":"OLD1","amount":1}]` {
+		t.Fatalf("batch transient = %s", c.Transient["batch"])
+	}
+}
+
+func TestErrorStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{errors.New("Error managing parameter param3. Conversion error"), http.StatusBadRequest},
+		{errors.New("invalid transactionType \"LOAN\" (want DEBIT, CREDIT or TRANSFER)"), http.StatusBadRequest},
+		{errors.New("id, creditorId and debtorId are required"), http.StatusBadRequest},
+		{errors.New("transient field 'formc' is required"), http.StatusBadRequest},
+		{errors.New("amount must be positive"), http.StatusBadRequest},
+		{errors.New("lead creditor C9 must be a participant"), http.StatusBadRequest},
+		{errors.New("repayment 500.000000 exceeds outstanding 100.000000"), http.StatusBadRequest},
+		{errors.New("moratorium in force for debtor D1 under case IC1: courtOrderHash must be the SHA-256 hash of the court order in 64 hex characters"), http.StatusBadRequest},
+		{errors.New("default DEF1 is against D2, not borrower D1"), http.StatusBadRequest},
+		{errors.New("participant PNB is bound to DebtorMSP, not CreditorMSP"), http.StatusBadRequest},
+		{errors.New("batch B1 rejected: row 2: amount must be positive"), http.StatusBadRequest},
+		{errors.New(`{"message":"invalid pan ABCXR1234K","fields":[]}`), http.StatusBadRequest},
+		{errors.New("unknown org \"auditor\" (want creditor, debtor or admin)"), http.StatusBadRequest},
+
+		{errors.New("only AdminMSP can approve KYC"), http.StatusForbidden},
+		{errors.New("moratorium in force for debtor D1 under case IC1: only AdminMSP can override with a court order"), http.StatusForbidden},
+		{errors.New("creditor C1 is bound to CreditorMSP, not DebtorMSP"), http.StatusForbidden},
+		{errors.New("no active institution is bound to DebtorMSP"), http.StatusForbidden},
+
+		{errors.New("the transaction TX9 does not exist"), http.StatusNotFound},
+		{errors.New("kyc K1 not found"), http.StatusNotFound},
+		{errors.New("borrower B9 is not registered"), http.StatusNotFound},
+		{errors.New("participant SBI is not a registered institution"), http.StatusNotFound},
+		{errors.New("source transaction tx1 has not been mirrored"), http.StatusNotFound},
+		{errors.New("no guarantee by G1 on loan L1"), http.StatusNotFound},
+
+		{errors.New("the transaction TX1 already exists"), http.StatusConflict},
+		{errors.New("source transaction tx1 already mirrored as A1"), http.StatusConflict},
+		{errors.New("claim by C1 in case IC1 is already ADMITTED"), http.StatusConflict},
+		{errors.New("moratorium in force for debtor D1 under case IC1 (NCLT1) since 2024-01-01: CreateTransaction is not permitted"), http.StatusConflict},
+		{errors.New("kyc K1 is not pending approval (status APPROVED)"), http.StatusConflict},
+		{errors.New("transaction TX1 is not in PENDING status"), http.StatusConflict},
+		{errors.New("transaction TX1 has not passed compliance check"), http.StatusConflict},
+		{errors.New("default DEF1 is FILED: only an authenticated default can invoke a guarantee"), http.StatusConflict},
+		{errors.New("borrower D1 has no authenticated default on loan L1: only an authenticated default can invoke a guarantee"), http.StatusConflict},
+		{errors.New("creditor C1 is SUSPENDED"), http.StatusConflict},
+		{errors.New("period 2024-05 has not ended"), http.StatusConflict},
+
+		{status.Error(codes.Unavailable, "connection refused"), http.StatusServiceUnavailable},
+		{status.Error(codes.DeadlineExceeded, "timed out"), http.StatusGatewayTimeout},
+		{errors.New("boom"), http.StatusInternalServerError},
+		{errors.New("failed to read from world state: only part of the value must be read"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		if got, _ := ErrorStatus(tt.err); got != tt.want {
+			t.Errorf("ErrorStatus(%q) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestBlockedLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocked.jsonl")
+	for _, err := range []error{
+		errors.New("the transaction TX1 already exists"),
+		errors.New("moratorium in force for debtor D1 under case IC1 (NCLT1) since 2024-01-01: DEBIT_TRANSACTION is not permitted"),
+	} {
+		req := httptest.NewRequest("POST", "/transactions", strings.NewReader(
+			`{"id":"TX1","creditorId":"C1","debtorId":"D1","amount":100,"currency":"INR","transactionType":"DEBIT"}`))
+		req.Header.Set(AuthHeader, "Bearer lender-key")
+		rec := httptest.NewRecorder()
+		server := NewServer(&fakeInvoker{err: err}, testClients, nil)
+		server.Blocked = &moratorium.Log{Path: path}
+		server.ServeHTTP(rec, req)
+		if rec.Code != http.StatusConflict {
+			t.Fatalf("status = %d, want 409: %s", rec.Code, rec.Body)
+		}
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var a moratorium.Attempt
+	if err := json.Unmarshal(b, &a); err != nil {
+		t.Fatalf("want one blocked attempt, got %s", b)
+	}
+	if a.Source != "iu-rest" || a.Client != "lender" || a.Org != "creditor" || a.Function != "CreateTransaction" || a.Args[0] != "TX1" {
+		t.Fatalf("unexpected attempt %+v", a)
+	}
+}
+
+func TestValidationErrorBody(t *testing.T) {
+	inv := &fakeInvoker{err: errors.New(`{"message":"invalid pan ABCXR1234K: unknown holder type X","fields":[{"field":"pan","kind":"PAN","value":"ABCXR1234K","reason":"unknown holder type X"}]}`)}
+	rec := serve(t, inv, httptest.NewRequest("POST", "/borrowers", strings.NewReader(`{"borrowerId":"B1","pan":"ABCXR1234K"}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status %d", rec.Code)
+	}
+	var body struct {
+		Error  string `json:"error"`
+		Fields []struct {
+			Field, Reason string
+		} `json:"fields"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Error != "invalid pan ABCXR1234K: unknown holder type X" || len(body.Fields) != 1 || body.Fields[0].Field != "pan" {
+		t.Fatalf("body %s", rec.Body)
+	}
+}
+
+func TestOpenAPI(t *testing.T) {
+	md, err := os.ReadFile("testdata/metadata.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := OpenAPI(md, "IUContract")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Paths map[string]map[string]struct {
+			OperationID string `json:"operationId"`
+			RequestBody struct {
+				Content map[string]struct {
+					Schema struct {
+						Properties map[string]map[string]interface{} `json:"properties"`
+					} `json:"schema"`
+				} `json:"content"`
+			} `json:"requestBody"`
+			Responses map[string]json.RawMessage `json:"responses"`
+		} `json:"paths"`
+		Components struct {
+			Schemas map[string]map[string]interface{} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		t.Fatal(err)
+	}
+	ops := 0
+	for _, methods := range doc.Paths {
+		ops += len(methods)
+	}
+	if ops != len(Routes) {
+		t.Fatalf("%d operations, want %d", ops, len(Routes))
+	}
+	create := doc.Paths["/transactions"]["post"]
+	if create.OperationID != "CreateTransaction" {
+		t.Fatalf("POST /transactions is %s", create.OperationID)
+	}
+	if typ := create.RequestBody.Content["application/json"].Schema.Properties["amount"]["type"]; typ != "number" {
+		t.Fatalf("amount type = %v, want number", typ)
+	}
+	if _, ok := doc.Paths["/kyc"]["post"].RequestBody.Content["application/json"].Schema.Properties["formC"]; !ok {
+		t.Fatalf("SubmitKYCFormC request has no formC property")
+	}
+	if _, ok := doc.Paths["/transactions/{id}"]["get"].Responses["200"]; !ok {
+		t.Fatalf("ReadTransaction has no 200 response")
+	}
+	if _, ok := doc.Components.Schemas["Transaction"]["$id"]; ok {
+		t.Fatalf("component schemas keep $id")
+	}
+}
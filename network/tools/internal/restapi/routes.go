@@ -0,0 +1,137 @@
+package restapi
+
+import "iu-tools/internal/gateway"
+
+// Arg is one chaincode argument and where in the request it comes from
+type Arg struct {
+	Name string
+	In   string // path, query or body
+}
+
+func path(name string) Arg  { return Arg{Name: name, In: "path"} }
+func query(name string) Arg { return Arg{Name: name, In: "query"} }
+func body(names ...string) []Arg {
+	args := make([]Arg, len(names))
+	for i, n := range names {
+		args[i] = Arg{Name: n, In: "body"}
+	}
+	return args
+}
+
+func args(parts ...interface{}) []Arg {
+	var out []Arg
+	for _, p := range parts {
+		switch v := p.(type) {
+		case Arg:
+			out = append(out, v)
+		case []Arg:
+			out = append(out, v...)
+		}
+	}
+	return out
+}
+
+// Route maps an HTTP method and path to a contract function. Args are in
+// the function's parameter order. GET routes are evaluated on one peer;
+// every other method is submitted for endorsement and ordering.
+type Route struct {
+	Method    string
+	Path      string
+	Function  string
+	Args      []Arg
+	Transient string // body field sent as transient data under its lowercased name; never written to the ledger
+	Channel   string // default channel; empty means financial-operations-channel
+	Tag       string
+}
+
+// Evaluate reports whether the route is a read-only query
+func (r Route) Evaluate() bool {
+	return r.Method == "GET"
+}
+
+// Routes exposes every IUContract function
+var Routes = []Route{
+	{"POST", "/ledger/init", "InitLedger", nil, "", "", "Transactions"},
+	{"POST", "/institutions", "RegisterInstitution", body("institutionId", "name", "registrationNumber", "type", "pan", "gstin", "cin", "mspId"), "", "", "Registry"},
+	{"GET", "/institutions/{institutionId}", "GetInstitution", args(path("institutionId")), "", "", "Registry"},
+	{"POST", "/institutions/{institutionId}/suspend", "SuspendInstitution", args(path("institutionId"), body("reason")), "", "", "Registry"},
+	{"POST", "/institutions/{institutionId}/reinstate", "ReinstateInstitution", args(path("institutionId"), body("remarks")), "", "", "Registry"},
+	{"POST", "/borrowers", "RegisterBorrower", body("borrowerId", "name", "type", "pan", "cin", "aadhaarRef", "clientId"), "", "", "Registry"},
+	{"GET", "/borrowers/{borrowerId}", "GetBorrower", args(path("borrowerId")), "", "", "Registry"},
+	{"POST", "/borrowers/{borrowerId}/suspend", "SuspendBorrower", args(path("borrowerId"), body("reason")), "", "", "Registry"},
+	{"POST", "/borrowers/{borrowerId}/reinstate", "ReinstateBorrower", args(path("borrowerId"), body("remarks")), "", "", "Registry"},
+	{"POST", "/borrowers/{borrowerId}/client", "BindBorrowerClient", args(path("borrowerId"), body("clientId")), "", "", "Registry"},
+
+	{"POST", "/transactions", "CreateTransaction", body("id", "creditorId", "debtorId", "amount", "currency", "transactionType", "description"), "", "", "Transactions"},
+	{"GET", "/transactions", "GetAllTransactions", nil, "", "", "Transactions"},
+	{"GET", "/transactions/pages", "QueryTransactions", args(query("pageSize"), query("bookmark")), "", "", "Transactions"},
+	{"GET", "/transactions/{id}", "ReadTransaction", args(path("id")), "", "", "Transactions"},
+	{"GET", "/transactions/{id}/exists", "TransactionExists", args(path("id")), "", "", "Transactions"},
+	{"GET", "/transactions/{id}/history", "GetTransactionHistory", args(path("id")), "", "", "Transactions"},
+	{"POST", "/imports", "BulkImportTransactions", body("batchId", "batchHash", "atomic"), "batch", "", "Transactions"},
+	{"GET", "/imports/{batchId}", "GetImportBatch", args(path("batchId")), "", "", "Transactions"},
+	{"POST", "/transactions/{id}/process", "ProcessTransaction", args(path("id")), "", "", "Transactions"},
+	{"POST", "/transactions/{id}/compliance", "PerformComplianceCheck", args(path("id"), body("approved")), "", "", "Transactions"},
+	{"GET", "/accounts/{id}", "ReadAccount", args(path("id")), "", "", "Transactions"},
+	{"POST", "/accounts/{id}/suspend", "SuspendAccount", args(path("id"), body("reason")), "", "", "Transactions"},
+
+	{"POST", "/loans/{loanId}/documents", "SubmitLoanDocument", args(path("loanId"), body("docId", "hash", "type", "mime", "size", "metadata")), "", "", "Documents"},
+	{"GET", "/loans/{loanId}/documents", "GetLoanDocuments", args(path("loanId")), "", "", "Documents"},
+	{"GET", "/documents/{docId}", "GetDocument", args(path("docId")), "", "", "Documents"},
+	{"POST", "/kyc", "SubmitKYCFormC", body("loanId", "kycId", "partyId"), "formC", "", "KYC"},
+	{"POST", "/kyc/{kycId}/approve", "ApproveKYC", args(path("kycId"), body("approved", "remarks")), "", "", "KYC"},
+
+	{"POST", "/defaults", "FileDefault", body("id", "loanId", "creditorId", "debtorId", "amount", "currency", "defaultDate", "details"), "", "", "Defaults"},
+	{"GET", "/defaults/{id}", "GetDefault", args(path("id")), "", "", "Defaults"},
+	{"POST", "/defaults/{id}/confirm", "ConfirmDefault", args(path("id")), "", "", "Defaults"},
+	{"POST", "/defaults/{id}/dispute", "DisputeDefault", args(path("id"), body("reason", "evidenceDocIds")), "", "", "Defaults"},
+	{"GET", "/defaults/{id}/record-of-default", "GetRecordOfDefault", args(path("id")), "", "", "Defaults"},
+	{"POST", "/defaults/deemed-authentication", "ApplyDeemedAuthentication", body("asOf"), "", "", "Defaults"},
+	{"GET", "/config/deemed-authentication-days", "GetDeemedAuthenticationDays", nil, "", "", "Defaults"},
+	{"PUT", "/config/deemed-authentication-days", "SetDeemedAuthenticationDays", body("days"), "", "", "Defaults"},
+	{"GET", "/evidence/{recordType}/{id}", "GetRecordEvidence", args(path("recordType"), path("id")), "", "", "Defaults"},
+
+	{"POST", "/insolvency-cases", "RegisterInsolvencyCase", body("caseId", "debtorId", "cin", "ncltCaseId", "admissionDate", "rpName", "rpRegistrationNo", "rpMspId", "rpClientId", "moratoriumStart", "moratoriumEnd"), "", "", "Insolvency"},
+	{"GET", "/insolvency-cases/{caseId}", "GetInsolvencyCase", args(path("caseId")), "", "", "Insolvency"},
+	{"POST", "/insolvency-cases/{caseId}/claims", "SubmitClaim", args(path("caseId"), body("creditorId", "claimAmount")), "formC", "", "Insolvency"},
+	{"GET", "/insolvency-cases/{caseId}/claims", "GetClaimsRegister", args(path("caseId")), "", "", "Insolvency"},
+	{"POST", "/insolvency-cases/{caseId}/claims/{creditorId}/admit", "AdmitClaim", args(path("caseId"), path("creditorId"), body("admittedAmount", "remarks")), "", "", "Insolvency"},
+	{"POST", "/insolvency-cases/{caseId}/claims/{creditorId}/reject", "RejectClaim", args(path("caseId"), path("creditorId"), body("reason")), "", "", "Insolvency"},
+
+	{"POST", "/collateral", "RegisterCollateral", body("id", "assetType", "assetIdentifier", "description", "ownerId", "valuation", "currency", "valuedOn", "ownershipDocIds"), "", "", "Collateral"},
+	{"GET", "/collateral/{id}", "GetCollateral", args(path("id")), "", "", "Collateral"},
+	{"POST", "/collateral/{id}/valuations", "RevalueCollateral", args(path("id"), body("valuation", "currency", "valuedOn", "remarks")), "", "", "Collateral"},
+	{"GET", "/assets/{assetIdentifier}/charges", "FindChargesOnAsset", args(path("assetIdentifier")), "", "", "Collateral"},
+	{"POST", "/charges", "CreateCharge", body("chargeId", "collateralId", "loanId", "creditorId", "amount", "remarks"), "", "", "Collateral"},
+	{"GET", "/charges/{id}", "GetCharge", args(path("id")), "", "", "Collateral"},
+	{"POST", "/charges/{id}/modify", "ModifyCharge", args(path("id"), body("amount", "remarks")), "", "", "Collateral"},
+	{"POST", "/charges/{id}/satisfy", "SatisfyCharge", args(path("id"), body("remarks")), "", "", "Collateral"},
+
+	{"POST", "/guarantees", "RegisterGuarantee", body("id", "loanId", "borrowerId", "guarantorId", "type", "creditorId", "capAmount", "currency"), "", "", "Guarantees"},
+	{"GET", "/guarantees/{id}", "GetGuarantee", args(path("id")), "", "", "Guarantees"},
+	{"POST", "/guarantees/{id}/release", "ReleaseGuarantee", args(path("id"), body("remarks")), "", "", "Guarantees"},
+	{"GET", "/loans/{loanId}/guarantees", "GetLoanGuarantees", args(path("loanId")), "", "", "Guarantees"},
+	{"POST", "/loans/{loanId}/guarantees/{guarantorId}/invoke", "InvokeGuarantee", args(path("loanId"), path("guarantorId")), "", "", "Guarantees"},
+	{"GET", "/parties/{partyId}/exposure", "GetExposureByParty", args(path("partyId")), "", "", "Guarantees"},
+
+	{"POST", "/consortium-loans", "CreateConsortiumLoan", body("loanId", "debtorId", "leadCreditorId", "sanctionedAmount", "currency", "participants"), "", "", "Consortium loans"},
+	{"GET", "/consortium-loans/{loanId}", "GetConsortiumLoan", args(path("loanId")), "", "", "Consortium loans"},
+	{"POST", "/consortium-loans/{loanId}/repayments", "RecordConsortiumRepayment", args(path("loanId"), body("paymentId", "amount")), "", "", "Consortium loans"},
+	{"POST", "/consortium-loans/{loanId}/default-allocations", "AllocateConsortiumDefault", args(path("loanId"), body("defaultId")), "", "", "Consortium loans"},
+	{"POST", "/consortium-loans/{loanId}/claim-allocations", "AllocateConsortiumClaim", args(path("loanId"), body("caseId")), "", "", "Consortium loans"},
+	{"GET", "/consortium-loans/{loanId}/allocations", "GetConsortiumAllocations", args(path("loanId")), "", "", "Consortium loans"},
+
+	{"GET", "/audit/trail/{refId}", "GetAuditTrail", args(path("refId")), "", "", "Audit"},
+	{"GET", "/audit/records", "QueryAuditRecords", args(query("actor"), query("action"), query("from"), query("to"), query("pageSize"), query("bookmark")), "", "", "Audit"},
+	{"GET", "/audit/records/{id}", "GetAuditRecord", args(path("id")), "", "", "Audit"},
+	{"POST", "/audit/backfill", "BackfillAuditRecords", body("startKey", "limit"), "", "", "Audit"},
+	{"POST", "/audit/events", "RecordAuditEvent", body("eventType", "refId", "hash", "details", "sourceTxId", "sourceBlock"), "", gateway.AuditChannel, "Audit"},
+	{"GET", "/audit/mirrors/{sourceTxId}", "GetMirroredEvent", args(path("sourceTxId")), "", gateway.AuditChannel, "Audit"},
+	{"GET", "/audit/mirrors/{sourceTxId}/exists", "MirroredEventExists", args(path("sourceTxId")), "", gateway.AuditChannel, "Audit"},
+	{"POST", "/audit/records/{id}/verify", "VerifyMirroredEvent", args(path("id")), "", gateway.AuditChannel, "Audit"},
+	{"GET", "/state/{key}/versions", "GetVersionHashes", args(path("key")), "", "", "Audit"},
+	{"POST", "/snapshots", "CommitSnapshotRoot", body("period", "root", "leafCount"), "", "", "Audit"},
+	{"GET", "/snapshots/{period}", "GetSnapshotRoot", args(path("period")), "", "", "Audit"},
+	{"GET", "/endorsement-policies/{key}", "GetEndorsementPolicy", args(path("key")), "", "", "Administration"},
+	{"PUT", "/endorsement-policies/{key}", "RotateEndorsementPolicy", args(path("key"), body("orgs")), "", "", "Administration"},
+}
@@ -0,0 +1,352 @@
+// Package restapi serves IUContract over HTTP. Each contract function is a
+// REST route (see Routes); clients authenticate with an API key and pick
+// one of the org identities they are entitled to with the X-IU-Org header,
+// Form C is passed to the chaincode as transient data, and chaincode errors
+// become HTTP status codes. An OpenAPI document is built from the contract
+// metadata.
+package restapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"iu-tools/internal/gateway"
+	"iu-tools/internal/moratorium"
+)
+
+// Request headers understood by the server
+const (
+	OrgHeader        = "X-IU-Org"              // profile name, e.g. creditor, debtor or admin; defaults to the client's first org
+	ChannelHeader    = "X-IU-Channel"          // overrides the route's channel
+	CourtOrderHeader = "X-IU-Court-Order-Hash" // sent as transient courtOrderHash for moratorium overrides
+)
+
+// Call is one contract invocation built from an HTTP request
+type Call struct {
+	Org       string
+	Channel   string
+	Function  string
+	Args      []string
+	Transient map[string][]byte
+	Evaluate  bool
+}
+
+// Invoker runs calls against the network
+type Invoker interface {
+	Invoke(c Call) ([]byte, error)
+}
+
+// Server is the REST gateway's HTTP handler. Calls the chaincode refuses
+// under an insolvency moratorium are appended to Blocked when it is set.
+type Server struct {
+	Invoker Invoker
+	Clients Clients
+	OpenAPI func() ([]byte, error)
+	Blocked *moratorium.Log
+	mux     *http.ServeMux
+}
+
+// NewServer registers every route. Only the clients may call the contract;
+// the OpenAPI document is public.
+func NewServer(invoker Invoker, clients Clients, openAPI func() ([]byte, error)) *Server {
+	s := &Server{Invoker: invoker, Clients: clients, OpenAPI: openAPI, mux: http.NewServeMux()}
+	for _, r := range Routes {
+		route := r
+		s.mux.HandleFunc(route.Method+" "+route.Path, func(w http.ResponseWriter, req *http.Request) {
+			s.serveRoute(w, req, route)
+		})
+	}
+	s.mux.HandleFunc("GET /openapi.json", s.serveOpenAPI)
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) serveOpenAPI(w http.ResponseWriter, r *http.Request) {
+	doc, err := s.OpenAPI()
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, fmt.Sprintf("contract metadata unavailable: %v", err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(doc)
+}
+
+func (s *Server) serveRoute(w http.ResponseWriter, r *http.Request, route Route) {
+	name, client, err := s.Clients.Authenticate(r)
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	call, err := BuildCall(r, route, client.Orgs[0])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := client.Authorize(name, r, call); err != nil {
+		writeError(w, http.StatusForbidden, err.Error())
+		return
+	}
+	result, err := s.Invoker.Invoke(call)
+	if err != nil {
+		code, msg := ErrorStatus(err)
+		if s.Blocked != nil && moratorium.Blocked(msg) {
+			s.recordBlocked(name, call, msg)
+		}
+		writeError(w, code, msg)
+		return
+	}
+	if len(bytes.TrimSpace(result)) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if !json.Valid(result) {
+		// Functions returning a plain string, such as InvokeGuarantee
+		result, _ = json.Marshal(string(result))
+	}
+	w.Write(result)
+}
+
+// recordBlocked logs a call refused under a moratorium. The refusal is still
+// returned to the client if the log cannot be written.
+func (s *Server) recordBlocked(client string, call Call, msg string) {
+	err := s.Blocked.Record(moratorium.Attempt{
+		Time:           time.Now().UTC(),
+		Source:         "iu-rest",
+		Client:         client,
+		Org:            call.Org,
+		Function:       call.Function,
+		Args:           call.Args,
+		CourtOrderHash: string(call.Transient["courtOrderHash"]),
+		Error:          msg,
+	})
+	if err != nil {
+		log.Printf("failed to record blocked %s by %s: %v", call.Function, client, err)
+	}
+}
+
+// BuildCall turns a request into a contract call for route
+func BuildCall(r *http.Request, route Route, defaultOrg string) (Call, error) {
+	call := Call{
+		Org:       r.Header.Get(OrgHeader),
+		Channel:   r.Header.Get(ChannelHeader),
+		Function:  route.Function,
+		Args:      []string{},
+		Transient: map[string][]byte{},
+		Evaluate:  route.Evaluate(),
+	}
+	if call.Org == "" {
+		call.Org = defaultOrg
+	}
+	if call.Channel == "" {
+		call.Channel = route.Channel
+	}
+	if call.Channel == "" {
+		call.Channel = gateway.FinancialChannel
+	}
+
+	fields := map[string]json.RawMessage{}
+	if !call.Evaluate && r.Body != nil {
+		b, err := io.ReadAll(io.LimitReader(r.Body, 16<<20))
+		if err != nil {
+			return call, err
+		}
+		if len(bytes.TrimSpace(b)) > 0 {
+			if err := json.Unmarshal(b, &fields); err != nil {
+				return call, fmt.Errorf("request body must be a JSON object: %v", err)
+			}
+		}
+	}
+
+	for _, a := range route.Args {
+		var v string
+		switch a.In {
+		case "path":
+			v = r.PathValue(a.Name)
+		case "query":
+			v = r.URL.Query().Get(a.Name)
+		case "body":
+			var err error
+			if v, err = argString(fields[a.Name]); err != nil {
+				return call, fmt.Errorf("field %s: %v", a.Name, err)
+			}
+		}
+		call.Args = append(call.Args, v)
+	}
+
+	if name := route.Transient; name != "" {
+		raw, ok := fields[name]
+		if !ok || len(raw) == 0 || string(raw) == "null" {
+			return call, fmt.Errorf("field %s is required", name)
+		}
+		v, err := argString(raw)
+		if err != nil {
+			return call, fmt.Errorf("field %s: %v", name, err)
+		}
+		call.Transient[strings.ToLower(name)] = []byte(v)
+	}
+	if h := r.Header.Get(CourtOrderHeader); h != "" && !call.Evaluate {
+		call.Transient["courtOrderHash"] = []byte(h)
+	}
+	return call, nil
+}
+
+// argString converts a JSON value to the string form contractapi parses:
+// strings are passed as-is, everything else as its JSON text
+func argString(raw json.RawMessage) (string, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return "", nil
+	}
+	if raw[0] == '"' {
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return "", err
+		}
+		return s, nil
+	}
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, raw); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// errorStatuses maps chaincode error messages to HTTP status, first match
+// wins. Each pattern is anchored on how the chaincode words that kind of
+// error, so a word such as "only" or "must" elsewhere in a message does not
+// decide its status.
+var errorStatuses = []struct {
+	pattern *regexp.Regexp
+	code    int
+}{
+	// Argument conversion by contractapi and the chaincode's own validation
+	{regexp.MustCompile(`^Error managing parameter `), http.StatusBadRequest},
+	{regexp.MustCompile(`^invalid `), http.StatusBadRequest},
+	{regexp.MustCompile(`^unsupported `), http.StatusBadRequest},
+	{regexp.MustCompile(`^unknown org `), http.StatusBadRequest},
+	{regexp.MustCompile(`^[^:]* (is|are) required$`), http.StatusBadRequest},
+	{regexp.MustCompile(`^transient field '[^']+' is required$`), http.StatusBadRequest},
+	{regexp.MustCompile(`^[^:]* must (be|not|have) `), http.StatusBadRequest},
+	{regexp.MustCompile(`^[^:]* exceeds `), http.StatusBadRequest},
+	{regexp.MustCompile(`^moratorium in force for debtor \S+ under case \S+: courtOrderHash must `), http.StatusBadRequest},
+	{regexp.MustCompile(`^(default|insolvency case) \S+ is (against|filed on loan) \S+, not `), http.StatusBadRequest},
+	{regexp.MustCompile(`^participant \S+ (listed twice|is bound to )`), http.StatusBadRequest},
+	{regexp.MustCompile(`^(participant shares add up|a consortium needs|each participant needs|guarantor cannot be) `), http.StatusBadRequest},
+	{regexp.MustCompile(`^(an individual|a corporate borrower) has no `), http.StatusBadRequest},
+	{regexp.MustCompile(`^\S+ is before \S+$`), http.StatusBadRequest},
+	{regexp.MustCompile(`^\S+ \S+ is later than the transaction timestamp$`), http.StatusBadRequest},
+	{regexp.MustCompile(`^batch (hash mismatch|\S+ rejected): `), http.StatusBadRequest},
+	{regexp.MustCompile(`^transaction \S+ appears more than once in the batch$`), http.StatusBadRequest},
+
+	// Org and party checks
+	{regexp.MustCompile(`^only `), http.StatusForbidden},
+	{regexp.MustCompile(`^moratorium in force for debtor \S+ under case \S+: only `), http.StatusForbidden},
+	{regexp.MustCompile(`^creditor \S+ is bound to \S+, not \S+$`), http.StatusForbidden},
+	{regexp.MustCompile(`^no active institution is bound to `), http.StatusForbidden},
+	{regexp.MustCompile(`^tx creator does not have `), http.StatusForbidden},
+
+	// Missing records
+	{regexp.MustCompile(` does not exist$`), http.StatusNotFound},
+	{regexp.MustCompile(`^\S+ \S+ not found$`), http.StatusNotFound},
+	{regexp.MustCompile(` is not registered$`), http.StatusNotFound},
+	{regexp.MustCompile(` is not a registered (borrower or )?institution$`), http.StatusNotFound},
+	{regexp.MustCompile(`^source transaction \S+ has not been mirrored$`), http.StatusNotFound},
+	{regexp.MustCompile(`^no guarantee by `), http.StatusNotFound},
+	{regexp.MustCompile(`^no snapshot root is committed `), http.StatusNotFound},
+
+	// Duplicates and records in the wrong state
+	{regexp.MustCompile(` already (exists|imported|allocated|committed)`), http.StatusConflict},
+	{regexp.MustCompile(` already mirrored as `), http.StatusConflict},
+	{regexp.MustCompile(` is already `), http.StatusConflict},
+	{regexp.MustCompile(`^moratorium in force `), http.StatusConflict},
+	{regexp.MustCompile(` is not pending `), http.StatusConflict},
+	{regexp.MustCompile(` is not in [A-Z_]+ status$`), http.StatusConflict},
+	{regexp.MustCompile(` has not passed compliance check$`), http.StatusConflict},
+	{regexp.MustCompile(`^period \S+ has not ended$`), http.StatusConflict},
+	{regexp.MustCompile(`^(charge|guarantee|creditor|debtor|consortium loan|insolvency case|default) \S+ is [A-Z_]+\b`), http.StatusConflict},
+	{regexp.MustCompile(`^no claim by a participant `), http.StatusConflict},
+	{regexp.MustCompile(`^borrower \S+ has no authenticated default on loan `), http.StatusConflict},
+	{regexp.MustCompile(`^audit record \S+ was not mirrored from another channel$`), http.StatusConflict},
+}
+
+// ErrorStatus returns the HTTP status and message for an invocation error
+func ErrorStatus(err error) (int, string) {
+	msg := chaincodeMessage(err)
+	if isValidationError(msg) {
+		return http.StatusBadRequest, msg
+	}
+	for _, e := range errorStatuses {
+		if e.pattern.MatchString(msg) {
+			return e.code, msg
+		}
+	}
+	switch status.Code(err) {
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable, msg
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout, msg
+	case codes.PermissionDenied, codes.Unauthenticated:
+		return http.StatusForbidden, msg
+	}
+	return http.StatusInternalServerError, msg
+}
+
+// chaincodeMessage prefers the chaincode's own message from the peer error details
+func chaincodeMessage(err error) string {
+	full := gateway.ErrorMessage(err)
+	if i := strings.LastIndex(full, "): "); i >= 0 && full != err.Error() {
+		msg := full[i+3:]
+		// Strip the peer's "chaincode response 500, " prefix
+		if j := strings.Index(msg, "chaincode response "); j >= 0 {
+			if k := strings.Index(msg[j:], ", "); k >= 0 {
+				msg = msg[j+k+2:]
+			}
+		}
+		return msg
+	}
+	return full
+}
+
+// writeError writes an error body. A chaincode validation error is JSON
+// holding a message and the invalid fields, which are passed on as they are.
+func writeError(w http.ResponseWriter, code int, msg string) {
+	body := map[string]interface{}{"error": msg, "status": strconv.Itoa(code)}
+	if v, ok := parseValidationError(msg); ok {
+		body["error"], body["fields"] = v.Message, v.Fields
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(body)
+}
+
+// validationError is the JSON error the chaincode returns for invalid identifiers
+type validationError struct {
+	Message string          `json:"message"`
+	Fields  json.RawMessage `json:"fields"`
+}
+
+func parseValidationError(msg string) (validationError, bool) {
+	var v validationError
+	ok := json.Unmarshal([]byte(msg), &v) == nil && v.Message != "" && v.Fields != nil
+	return v, ok
+}
+
+func isValidationError(msg string) bool {
+	_, ok := parseValidationError(msg)
+	return ok
+}
@@ -0,0 +1,140 @@
+// Package snapshot builds the Merkle trees of iu-chaincode's periodic ledger
+// snapshots from the key history of an index, so that the chaincode only
+// stores each period's root. A snapshot covers every version of a
+// document, transaction or default written in a month (YYYY-MM) or a day
+// (YYYY-MM-DD), ordered by key and then by commit order. Records deleted
+// since are left out. Proofs are served from the same tree and checked with
+// the merkle package against the committed root alone.
+package snapshot
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"time"
+
+	"iu-chaincode/merkle"
+
+	"iu-tools/internal/indexer"
+)
+
+// recordTypes are the snapshotted index tables and the record type each
+// holds
+var recordTypes = map[string]string{
+	"documents":    "DOCUMENT",
+	"transactions": "TRANSACTION",
+	"defaults":     "DEFAULT",
+}
+
+// Leaf is one record version written in a snapshot's period
+type Leaf struct {
+	Key        string
+	RecordType string
+	TxID       string
+	Timestamp  time.Time
+	ValueHash  string
+}
+
+func (l Leaf) hash() []byte {
+	return merkle.LeafHash(merkle.LeafData(l.Key, l.TxID, l.ValueHash))
+}
+
+// Tree is the snapshot of one period
+type Tree struct {
+	Period string
+	From   time.Time
+	To     time.Time // exclusive
+	Leaves []Leaf
+	hashes [][]byte
+}
+
+// Proof shows that one record version is a leaf of a snapshot
+type Proof struct {
+	Period     string        `json:"period"`
+	Root       string        `json:"root"`
+	Key        string        `json:"key"`
+	RecordType string        `json:"recordType"`
+	TxID       string        `json:"txId"`
+	Timestamp  time.Time     `json:"timestamp"`
+	ValueHash  string        `json:"valueHash"`
+	LeafHash   string        `json:"leafHash"`
+	LeafIndex  int           `json:"leafIndex"`
+	LeafCount  int           `json:"leafCount"`
+	Path       []merkle.Step `json:"path"`
+}
+
+// Build reads the snapshot of period from the index. The index must hold a
+// write from after the period, so that none of the period's writes can
+// still be missing from it.
+func Build(store *indexer.Store, period string) (*Tree, error) {
+	from, to, err := merkle.ParsePeriod(period)
+	if err != nil {
+		return nil, err
+	}
+	last, err := store.LastWrite()
+	if err != nil {
+		return nil, err
+	}
+	if last.Before(to) {
+		return nil, fmt.Errorf("the index has no write after %s yet; run iu-indexer and try again", period)
+	}
+	versions, err := store.Versions(from, to, "documents", "transactions", "defaults")
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Tree{Period: period, From: from, To: to, Leaves: []Leaf{}}
+	for _, v := range versions {
+		t.Leaves = append(t.Leaves, Leaf{
+			Key:        v.Key,
+			RecordType: recordTypes[v.Table],
+			TxID:       v.TxID,
+			Timestamp:  v.WrittenAt,
+			ValueHash:  v.ValueHash,
+		})
+	}
+	// Versions come in commit order, which the stable sort keeps per key
+	sort.SliceStable(t.Leaves, func(i, j int) bool { return t.Leaves[i].Key < t.Leaves[j].Key })
+	t.hashes = make([][]byte, len(t.Leaves))
+	for i, l := range t.Leaves {
+		t.hashes[i] = l.hash()
+	}
+	return t, nil
+}
+
+// Root returns the hex root of the tree
+func (t *Tree) Root() string {
+	return hex.EncodeToString(merkle.Root(t.hashes))
+}
+
+// Proofs returns a proof for each version of key in the snapshot
+func (t *Tree) Proofs(key string) ([]Proof, error) {
+	root := t.Root()
+	proofs := []Proof{}
+	for i, l := range t.Leaves {
+		if l.Key != key {
+			continue
+		}
+		path, err := merkle.Proof(t.hashes, i)
+		if err != nil {
+			return nil, err
+		}
+		proofs = append(proofs, Proof{
+			Period:     t.Period,
+			Root:       root,
+			Key:        key,
+			RecordType: l.RecordType,
+			TxID:       l.TxID,
+			Timestamp:  l.Timestamp,
+			ValueHash:  l.ValueHash,
+			LeafHash:   hex.EncodeToString(t.hashes[i]),
+			LeafIndex:  i,
+			LeafCount:  len(t.Leaves),
+			Path:       path,
+		})
+	}
+	if len(proofs) == 0 {
+		return nil, fmt.Errorf("%s has no version in the snapshot of %s", key, t.Period)
+	}
+	return proofs, nil
+}
@@ -0,0 +1,82 @@
+package snapshot
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"iu-chaincode/merkle"
+
+	"iu-tools/internal/indexer"
+)
+
+// testIndex is an index whose key history is written directly: two
+// versions of TX1 and one of DEFAULT_DEF1 on 1 April, a version of DOC_D1
+// on 2 April, a deleted document and an account on 1 April
+func testIndex(t *testing.T) *indexer.Store {
+	t.Helper()
+	store, err := indexer.Open("sqlite:"+filepath.Join(t.TempDir(), "index.db"), "financial-operations-channel", "iu-chaincode")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	for _, stmt := range []string{
+		"INSERT INTO key_history VALUES ('TX1', 1, 0, 'tx-a', '2024-04-01T09:00:00.000000Z', false, 'h1')",
+		"INSERT INTO key_history VALUES ('DEFAULT_DEF1', 2, 0, 'tx-b', '2024-04-01T10:00:00.000000Z', false, 'h2')",
+		"INSERT INTO key_history VALUES ('TX1', 3, 0, 'tx-c', '2024-04-01T11:00:00.000000Z', false, 'h3')",
+		"INSERT INTO key_history VALUES ('ACC1', 3, 1, 'tx-d', '2024-04-01T11:00:00.000000Z', false, 'h4')",
+		"INSERT INTO key_history VALUES ('DOC_GONE', 3, 2, 'tx-e', '2024-04-01T11:00:00.000000Z', false, 'h5')",
+		"INSERT INTO key_history VALUES ('DOC_GONE', 4, 0, 'tx-f', '2024-04-01T12:00:00.000000Z', true, '')",
+		"INSERT INTO key_history VALUES ('DOC_D1', 5, 0, 'tx-g', '2024-04-02T08:00:00.000000Z', false, 'h6')",
+		"INSERT INTO transactions (key, last_block, last_tx_id, last_written_at, doc) VALUES ('TX1', 3, 'tx-c', '', '{}')",
+		"INSERT INTO defaults (key, last_block, last_tx_id, last_written_at, doc) VALUES ('DEFAULT_DEF1', 2, 'tx-b', '', '{}')",
+		"INSERT INTO accounts (key, last_block, last_tx_id, last_written_at, doc) VALUES ('ACC1', 3, 'tx-d', '', '{}')",
+		"INSERT INTO documents (key, last_block, last_tx_id, last_written_at, doc) VALUES ('DOC_D1', 5, 'tx-g', '', '{}')",
+	} {
+		if _, err := store.DB().Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+	return store
+}
+
+func TestBuild(t *testing.T) {
+	store := testIndex(t)
+	tree, err := Build(store, "2024-04-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, l := range tree.Leaves {
+		got = append(got, l.Key+"@"+l.TxID+"/"+l.RecordType)
+	}
+	if want := "DEFAULT_DEF1@tx-b/DEFAULT,TX1@tx-a/TRANSACTION,TX1@tx-c/TRANSACTION"; strings.Join(got, ",") != want {
+		t.Fatalf("leaves %v, want %s", got, want)
+	}
+
+	proofs, err := tree.Proofs("TX1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(proofs) != 2 || proofs[1].LeafIndex != 2 || proofs[1].LeafCount != 3 {
+		t.Fatalf("proofs %+v", proofs)
+	}
+	for _, p := range proofs {
+		ok, err := merkle.Verify(tree.Root(), merkle.LeafData(p.Key, p.TxID, p.ValueHash), p.Path)
+		if err != nil || !ok || p.Root != tree.Root() {
+			t.Fatalf("proof of %s does not verify: %v", p.TxID, err)
+		}
+	}
+	if _, err := tree.Proofs("ACC1"); err == nil || !strings.Contains(err.Error(), "no version") {
+		t.Fatalf("account proof: err %v", err)
+	}
+
+	// The 2 April write is the last in the index, so the month cannot be
+	// built yet
+	if _, err := Build(store, "2024-04"); err == nil || !strings.Contains(err.Error(), "run iu-indexer") {
+		t.Fatalf("index behind: err %v", err)
+	}
+	if _, err := Build(store, "April"); err == nil {
+		t.Fatal("invalid period accepted")
+	}
+}
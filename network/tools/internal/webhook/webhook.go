@@ -0,0 +1,305 @@
+// Package webhook delivers iu-chaincode events to HTTP endpoints of
+// off-chain systems. Each hook selects events by type and by the MSP of the
+// org that caused them, and receives a JSON body signed with HMAC-SHA256
+// under the hook's shared secret. Failed deliveries are retried with
+// exponential backoff and then written to a dead-letter store.
+package webhook
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"iu-chaincode/events"
+)
+
+// Headers set on every delivery
+const (
+	SignatureHeader = "X-IU-Signature" // "sha256=" + hex HMAC of the body
+	EventTypeHeader = "X-IU-Event-Type"
+	DeliveryHeader  = "X-IU-Delivery"
+)
+
+// Hook is one configured webhook endpoint
+type Hook struct {
+	Name       string   `json:"name"`
+	URL        string   `json:"url"`
+	Secret     string   `json:"secret"`
+	EventTypes []string `json:"eventTypes"` // empty means every type
+	Orgs       []string `json:"orgs"`       // actor MSP IDs; empty means every org
+}
+
+// Matches reports whether the hook wants env
+func (h Hook) Matches(env *events.Envelope) bool {
+	return contains(h.EventTypes, env.Type) && contains(h.Orgs, env.ActorMSP)
+}
+
+func contains(list []string, v string) bool {
+	if len(list) == 0 {
+		return true
+	}
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
+
+// Config is the dispatcher configuration file
+type Config struct {
+	Hooks          []Hook `json:"hooks"`
+	MaxAttempts    int    `json:"maxAttempts"`
+	InitialBackoff string `json:"initialBackoff"` // Go duration, e.g. "1s"
+	MaxBackoff     string `json:"maxBackoff"`
+}
+
+// LoadConfig reads a JSON Config
+func LoadConfig(path string) (*Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg Config
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return nil, fmt.Errorf("invalid webhook config %s: %v", path, err)
+	}
+	for _, h := range cfg.Hooks {
+		if h.Name == "" || h.URL == "" || h.Secret == "" {
+			return nil, fmt.Errorf("hook %q needs name, url and secret", h.Name)
+		}
+	}
+	return &cfg, nil
+}
+
+// Body is the JSON posted to a hook
+type Body struct {
+	Channel     string           `json:"channel"`
+	Chaincode   string           `json:"chaincode"`
+	BlockNumber uint64           `json:"blockNumber"`
+	Event       *events.Envelope `json:"event"`
+}
+
+// Delivery is one body bound for one hook
+type Delivery struct {
+	ID   string `json:"id"` // TxID and hook name, stable across replays
+	Hook string `json:"hook"`
+	URL  string `json:"url"`
+	Body Body   `json:"body"`
+}
+
+// DeadLetter is a delivery that exhausted its attempts
+type DeadLetter struct {
+	Delivery
+	Attempts int       `json:"attempts"`
+	LastErr  string    `json:"lastError"`
+	FailedAt time.Time `json:"failedAt"`
+}
+
+// DeadLetters stores failed deliveries for later inspection or redelivery
+type DeadLetters interface {
+	Add(DeadLetter) error
+}
+
+// FileDeadLetters appends dead letters to a JSON Lines file
+type FileDeadLetters struct {
+	Path string
+	mu   sync.Mutex
+}
+
+// Add appends d to the file
+func (f *FileDeadLetters) Add(d DeadLetter) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	b, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(f.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, err := file.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	return file.Sync()
+}
+
+// ReadDeadLetters returns every dead letter in a JSON Lines file
+func ReadDeadLetters(path string) ([]DeadLetter, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var letters []DeadLetter
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var d DeadLetter
+		if err := json.Unmarshal(scanner.Bytes(), &d); err != nil {
+			return nil, fmt.Errorf("invalid dead letter in %s: %v", path, err)
+		}
+		letters = append(letters, d)
+	}
+	return letters, scanner.Err()
+}
+
+// Sign returns the signature header value for body under secret
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a signature header value in constant time
+func Verify(secret string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
+
+// Dispatcher posts deliveries to hooks
+type Dispatcher struct {
+	Hooks          []Hook
+	Client         *http.Client
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	DeadLetters    DeadLetters
+	Logf           func(format string, args ...interface{})
+}
+
+// NewDispatcher builds a dispatcher from cfg with defaults of 5 attempts
+// and backoff from 1s doubling up to 1m
+func NewDispatcher(cfg *Config, deadLetters DeadLetters) (*Dispatcher, error) {
+	d := &Dispatcher{
+		Hooks:          cfg.Hooks,
+		Client:         &http.Client{Timeout: 10 * time.Second},
+		MaxAttempts:    5,
+		InitialBackoff: time.Second,
+		MaxBackoff:     time.Minute,
+		DeadLetters:    deadLetters,
+		Logf:           func(string, ...interface{}) {},
+	}
+	if cfg.MaxAttempts > 0 {
+		d.MaxAttempts = cfg.MaxAttempts
+	}
+	var err error
+	if cfg.InitialBackoff != "" {
+		if d.InitialBackoff, err = time.ParseDuration(cfg.InitialBackoff); err != nil {
+			return nil, fmt.Errorf("invalid initialBackoff: %v", err)
+		}
+	}
+	if cfg.MaxBackoff != "" {
+		if d.MaxBackoff, err = time.ParseDuration(cfg.MaxBackoff); err != nil {
+			return nil, fmt.Errorf("invalid maxBackoff: %v", err)
+		}
+	}
+	return d, nil
+}
+
+// Dispatch sends body to every matching hook. Deliveries that fail are
+// dead-lettered, so an error is returned only when ctx is cancelled or the
+// dead-letter store cannot be written.
+func (d *Dispatcher) Dispatch(ctx context.Context, body Body) error {
+	for _, h := range d.Hooks {
+		if !h.Matches(body.Event) {
+			continue
+		}
+		delivery := Delivery{
+			ID:   fmt.Sprintf("%s-%s", body.Event.TxID, h.Name),
+			Hook: h.Name,
+			URL:  h.URL,
+			Body: body,
+		}
+		if err := d.Deliver(ctx, h, delivery); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Deliver posts one delivery with retries, dead-lettering it if every attempt fails
+func (d *Dispatcher) Deliver(ctx context.Context, h Hook, delivery Delivery) error {
+	payload, err := json.Marshal(delivery.Body)
+	if err != nil {
+		return err
+	}
+	backoff := d.InitialBackoff
+	attempts := 0
+	var lastErr error
+	for attempts < d.MaxAttempts {
+		attempts++
+		retry, err := d.post(ctx, h, delivery, payload)
+		if err == nil {
+			d.Logf("delivered %s %s to %s", delivery.Body.Event.Type, delivery.ID, h.Name)
+			return nil
+		}
+		lastErr = err
+		if !retry || attempts == d.MaxAttempts {
+			break
+		}
+		d.Logf("delivery %s to %s failed (attempt %d): %v; retrying in %s", delivery.ID, h.Name, attempts, err, backoff)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > d.MaxBackoff {
+			backoff = d.MaxBackoff
+		}
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	d.Logf("delivery %s to %s dead-lettered after %d attempt(s): %v", delivery.ID, h.Name, attempts, lastErr)
+	return d.DeadLetters.Add(DeadLetter{
+		Delivery: delivery,
+		Attempts: attempts,
+		LastErr:  lastErr.Error(),
+		FailedAt: time.Now().UTC(),
+	})
+}
+
+// post sends the request once and reports whether a failure is worth retrying
+func (d *Dispatcher) post(ctx context.Context, h Hook, delivery Delivery, payload []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(payload))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, Sign(h.Secret, payload))
+	req.Header.Set(EventTypeHeader, delivery.Body.Event.Type)
+	req.Header.Set(DeliveryHeader, delivery.ID)
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, fmt.Errorf("%s returned %s", h.URL, resp.Status)
+	default:
+		return false, fmt.Errorf("%s rejected delivery: %s", h.URL, resp.Status)
+	}
+}
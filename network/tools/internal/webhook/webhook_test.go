@@ -0,0 +1,165 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"iu-chaincode/events"
+)
+
+func testBody(eventType, actorMSP string) Body {
+	return Body{
+		Channel:     "financial-operations-channel",
+		Chaincode:   "iu-chaincode",
+		BlockNumber: 7,
+		Event: &events.Envelope{
+			Type:          eventType,
+			SchemaVersion: events.SchemaVersion,
+			EntityKey:     "DOC_D1",
+			ActorMSP:      actorMSP,
+			TxID:          "tx1",
+			PayloadHash:   "abc",
+		},
+	}
+}
+
+func testDispatcher(t *testing.T, hooks ...Hook) (*Dispatcher, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "dead.jsonl")
+	d, err := NewDispatcher(&Config{Hooks: hooks, MaxAttempts: 3, InitialBackoff: "1ms", MaxBackoff: "2ms"}, &FileDeadLetters{Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d, path
+}
+
+func TestDispatchSignsAndFilters(t *testing.T) {
+	var received []Body
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		if !Verify("s3cret", b, r.Header.Get(SignatureHeader)) {
+			t.Errorf("bad signature %q", r.Header.Get(SignatureHeader))
+		}
+		if got := r.Header.Get(DeliveryHeader); got != "tx1-los" {
+			t.Errorf("delivery id = %q", got)
+		}
+		var body Body
+		if err := json.Unmarshal(b, &body); err != nil {
+			t.Fatal(err)
+		}
+		received = append(received, body)
+	}))
+	defer srv.Close()
+
+	d, _ := testDispatcher(t, Hook{
+		Name:       "los",
+		URL:        srv.URL,
+		Secret:     "s3cret",
+		EventTypes: []string{events.DocumentSubmitted, events.KYCApproved},
+		Orgs:       []string{"CreditorMSP"},
+	})
+
+	tests := []struct {
+		eventType, org string
+		delivered      bool
+	}{
+		{events.DocumentSubmitted, "CreditorMSP", true},
+		{events.KYCApproved, "CreditorMSP", true},
+		{events.DocumentSubmitted, "DebtorMSP", false},
+		{events.TransactionCreated, "CreditorMSP", false},
+	}
+	for _, tt := range tests {
+		before := len(received)
+		if err := d.Dispatch(context.Background(), testBody(tt.eventType, tt.org)); err != nil {
+			t.Fatal(err)
+		}
+		if got := len(received) > before; got != tt.delivered {
+			t.Errorf("%s from %s delivered = %v, want %v", tt.eventType, tt.org, got, tt.delivered)
+		}
+	}
+	if received[0].Event.EntityKey != "DOC_D1" || received[0].BlockNumber != 7 {
+		t.Errorf("unexpected body %+v", received[0])
+	}
+}
+
+func TestDeliverRetriesThenSucceeds(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	d, dead := testDispatcher(t, Hook{Name: "cms", URL: srv.URL, Secret: "k"})
+	if err := d.Dispatch(context.Background(), testBody(events.TransactionProcessed, "AdminMSP")); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 3 {
+		t.Errorf("calls = %d, want 3", calls)
+	}
+	if letters, _ := ReadDeadLetters(dead); len(letters) != 0 {
+		t.Errorf("unexpected dead letters %+v", letters)
+	}
+}
+
+func TestDeliverDeadLetters(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		wantCalls int32
+	}{
+		{"server errors exhaust retries", http.StatusInternalServerError, 3},
+		{"client errors are not retried", http.StatusBadRequest, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&calls, 1)
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+
+			d, dead := testDispatcher(t, Hook{Name: "cms", URL: srv.URL, Secret: "k"})
+			if err := d.Dispatch(context.Background(), testBody(events.KYCApproved, "AdminMSP")); err != nil {
+				t.Fatal(err)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", calls, tt.wantCalls)
+			}
+			letters, err := ReadDeadLetters(dead)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(letters) != 1 || letters[0].Attempts != int(tt.wantCalls) || letters[0].Hook != "cms" {
+				t.Fatalf("dead letters = %+v", letters)
+			}
+		})
+	}
+}
+
+func TestDeliverStopsOnCancel(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	d, dead := testDispatcher(t, Hook{Name: "cms", URL: srv.URL, Secret: "k"})
+	d.InitialBackoff = time.Hour
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := d.Dispatch(ctx, testBody(events.KYCApproved, "AdminMSP")); err == nil {
+		t.Fatal("expected cancellation error")
+	}
+	if letters, _ := ReadDeadLetters(dead); len(letters) != 0 {
+		t.Errorf("cancelled delivery was dead-lettered: %+v", letters)
+	}
+}
@@ -0,0 +1,66 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestCellRef(t *testing.T) {
+	for _, c := range []struct {
+		col, row int
+		want     string
+	}{{0, 0, "A1"}, {25, 9, "Z10"}, {26, 0, "AA1"}, {701, 1, "ZZ2"}, {702, 0, "AAA1"}} {
+		if got := CellRef(c.col, c.row); got != c.want {
+			t.Errorf("CellRef(%d, %d) = %s, want %s", c.col, c.row, got, c.want)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	sheets := []Sheet{
+		{Name: "Summary", Rows: [][]interface{}{{"Defaults filed", 3}, {"Amount", 1234.5}}},
+		{Name: "By creditor: Q1/2024", Rows: [][]interface{}{{"Creditor", "Name"}, {"C1", "A & B <Bank>"}}},
+	}
+	b, err := Write(sheets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, _ := Write(sheets)
+	if !bytes.Equal(b, again) {
+		t.Fatal("output is not reproducible")
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := map[string]string{}
+	for _, f := range zr.File {
+		r, _ := f.Open()
+		content, _ := io.ReadAll(r)
+		r.Close()
+		parts[f.Name] = string(content)
+	}
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/worksheets/sheet1.xml", "xl/worksheets/sheet2.xml"} {
+		if _, ok := parts[name]; !ok {
+			t.Fatalf("no part %s", name)
+		}
+	}
+	if !strings.Contains(parts["xl/workbook.xml"], `<sheet name="By creditor Q12024" sheetId="2" r:id="rId2"/>`) {
+		t.Fatalf("workbook.xml: %s", parts["xl/workbook.xml"])
+	}
+	if !strings.Contains(parts["xl/worksheets/sheet1.xml"], `<c r="B1"><v>3</v></c>`) ||
+		!strings.Contains(parts["xl/worksheets/sheet1.xml"], `<c r="B2"><v>1234.5</v></c>`) {
+		t.Fatalf("sheet1: %s", parts["xl/worksheets/sheet1.xml"])
+	}
+	if !strings.Contains(parts["xl/worksheets/sheet2.xml"], `<t xml:space="preserve">A &amp; B &lt;Bank&gt;</t>`) {
+		t.Fatalf("sheet2: %s", parts["xl/worksheets/sheet2.xml"])
+	}
+
+	if _, err := Write([]Sheet{{Name: "x", Rows: [][]interface{}{{true}}}}); err == nil {
+		t.Fatal("bool cell accepted")
+	}
+}